@@ -0,0 +1,39 @@
+// Command libmagicmix is magicmix as a C library, for Python, JavaScript, and other
+// tooling that wants to sort in-process rather than run the magicmix command for every
+// set. Build it with
+//
+//	go build -buildmode=c-shared -o libmagicmix.so ./cmd/libmagicmix
+//
+// which also writes libmagicmix.h. The API is two functions:
+//
+//	char *magicmix_sort(const char *request);
+//	void magicmix_free(char *response);
+//
+// magicmix_sort takes a JSON request and returns a JSON response, both UTF-8 and
+// NUL-terminated (see mix.SortRequest and mix.SortResponse); a failure comes back as
+// {"error": "..."}. The caller owns the response and releases it with magicmix_free.
+package main
+
+/*
+#include <stdlib.h>
+*/
+import "C"
+
+import (
+	"context"
+	"unsafe"
+
+	"github.com/YakDriver/magicmix/mix"
+)
+
+//export magicmix_sort
+func magicmix_sort(request *C.char) *C.char {
+	return C.CString(string(mix.SortJSON(context.Background(), []byte(C.GoString(request)))))
+}
+
+//export magicmix_free
+func magicmix_free(response *C.char) {
+	C.free(unsafe.Pointer(response))
+}
+
+func main() {}
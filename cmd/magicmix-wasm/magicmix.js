@@ -0,0 +1,47 @@
+// magicmix.js loads magicmix.wasm and wraps its API for JavaScript. Load Go's
+// wasm_exec.js first (make wasm copies both beside magicmix.wasm), then:
+//
+//   import { load } from "./magicmix.js";
+//   const magicmix = await load();
+//   const set = magicmix.sort({ csv: await file.text(), strategy: "flow", seed: 7 });
+//   download(set.csv);
+//
+// Everything runs in the page: the library never leaves the browser. A sort blocks
+// the thread it runs on, so sort large libraries from a Web Worker.
+
+// load fetches and starts magicmix.wasm, from source: a URL (by default beside this
+// file), the .wasm bytes, or a compiled WebAssembly.Module.
+export async function load(source = new URL("magicmix.wasm", import.meta.url)) {
+  if (typeof globalThis.Go !== "function") {
+    throw new Error("magicmix: load wasm_exec.js before magicmix.js");
+  }
+  const go = new globalThis.Go();
+  let instance;
+  if (source instanceof WebAssembly.Module) {
+    instance = await WebAssembly.instantiate(source, go.importObject);
+  } else if (source instanceof ArrayBuffer || ArrayBuffer.isView(source)) {
+    ({ instance } = await WebAssembly.instantiate(source, go.importObject));
+  } else {
+    ({ instance } = await WebAssembly.instantiateStreaming(fetch(source), go.importObject));
+  }
+  go.run(instance); // returns once main has set globalThis.magicmix and blocked
+
+  const api = globalThis.magicmix;
+  const call = (fn, request) => {
+    const response = JSON.parse(fn(JSON.stringify(request)));
+    if (response.error) {
+      throw new Error(`magicmix: ${response.error}`);
+    }
+    return response;
+  };
+  return {
+    // sort orders a library: { tracks: [...] } or { csv: "..." }, plus optional
+    // strategy, seed, limit, tempo_match, and audit_determinism. It returns the
+    // ordered tracks (and csv, for a CSV request), the seed, notes, and warnings.
+    sort: (request) => call(api.sort, request),
+    // score scores a set in the order given: { tracks: [...] } or { csv: "..." }.
+    score: (request) => call(api.score, request),
+    // strategies lists the strategies sort can use.
+    strategies: () => JSON.parse(api.strategies()),
+  };
+}
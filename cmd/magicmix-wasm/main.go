@@ -0,0 +1,42 @@
+//go:build js && wasm
+
+// Command magicmix-wasm is magicmix compiled to WebAssembly, so a web page can sort
+// and score a library entirely in the browser, without uploading it anywhere. Build
+// it with `make wasm`, which also copies Go's wasm_exec.js and the magicmix.js
+// wrapper beside magicmix.wasm.
+//
+// It sets a global magicmix object with three functions, each taking and returning
+// JSON text: sort (see mix.SortJSON), score (see mix.ScoreJSON), and strategies, which
+// lists the registered strategies. magicmix.js wraps them to take and return objects.
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"syscall/js"
+
+	"github.com/YakDriver/magicmix/mix"
+)
+
+func main() {
+	js.Global().Set("magicmix", js.ValueOf(map[string]any{
+		"sort":  js.FuncOf(jsonCall(mix.SortJSON)),
+		"score": js.FuncOf(jsonCall(mix.ScoreJSON)),
+		"strategies": js.FuncOf(func(js.Value, []js.Value) any {
+			out, _ := json.Marshal(mix.Strategies())
+			return string(out)
+		}),
+	}))
+	select {} // keep the functions callable
+}
+
+// jsonCall adapts a JSON API function to JavaScript: one string argument in, a
+// string out.
+func jsonCall(fn func(context.Context, []byte) []byte) func(js.Value, []js.Value) any {
+	return func(_ js.Value, args []js.Value) any {
+		if len(args) != 1 || args[0].Type() != js.TypeString {
+			return `{"error": "want one argument, the request as JSON text"}`
+		}
+		return string(fn(context.Background(), []byte(args[0].String())))
+	}
+}
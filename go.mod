@@ -2,6 +2,9 @@ module github.com/YakDriver/magicmix
 
 go 1.25.0
 
-require golang.org/x/term v0.44.0
+require (
+	go.yaml.in/yaml/v3 v3.0.4
+	golang.org/x/term v0.44.0
+)
 
 require golang.org/x/sys v0.46.0 // indirect
@@ -0,0 +1,75 @@
+package audiotags
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+)
+
+// readAIFF walks an AIFF (or AIFF-C) file's chunks: the length from COMM and the tags
+// from the ID3v2 tag in its "ID3 " chunk. The sound data is skipped unread.
+func readAIFF(f *os.File) (Tags, error) {
+	var tags Tags
+	form := make([]byte, 12)
+	if _, err := io.ReadFull(f, form); err != nil {
+		return tags, fmt.Errorf("read header: %w", err)
+	}
+	if string(form[:4]) != "FORM" || (string(form[8:]) != "AIFF" && string(form[8:]) != "AIFC") {
+		return tags, errors.New("not an AIFF file")
+	}
+	hdr := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(f, hdr); err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+				return tags, nil
+			}
+			return tags, fmt.Errorf("read chunk: %w", err)
+		}
+		id, size := string(hdr[:4]), int64(binary.BigEndian.Uint32(hdr[4:]))
+		padded := size + size&1 // chunks are padded to an even length
+		switch id {
+		case "COMM", "ID3 ", "id3 ":
+			chunk := make([]byte, padded)
+			if _, err := io.ReadFull(f, chunk); err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+				return tags, fmt.Errorf("read %s chunk: %w", id, err)
+			}
+			if id == "COMM" && len(chunk) >= 18 {
+				frames := binary.BigEndian.Uint32(chunk[2:6])
+				if rate := extendedFloat(chunk[8:18]); rate > 0 {
+					tags.Seconds = float64(frames) / rate
+				}
+			} else if id != "COMM" {
+				seconds := tags.Seconds
+				if err := parseID3(chunk[:size], &tags); err != nil {
+					return tags, err
+				}
+				if seconds > 0 {
+					tags.Seconds = seconds // the audio's own length beats a TLEN frame
+				}
+			}
+		default:
+			if _, err := f.Seek(padded, io.SeekCurrent); err != nil {
+				return tags, fmt.Errorf("skip chunk: %w", err)
+			}
+		}
+	}
+}
+
+// extendedFloat reads the 80-bit IEEE 754 extended-precision number AIFF gives its
+// sample rate in: a sign bit, a 15-bit exponent, and a 64-bit mantissa with an explicit
+// integer bit.
+func extendedFloat(b []byte) float64 {
+	exp := int(binary.BigEndian.Uint16(b[:2]) & 0x7fff)
+	mantissa := binary.BigEndian.Uint64(b[2:10])
+	if exp == 0 && mantissa == 0 {
+		return 0
+	}
+	v := math.Ldexp(float64(mantissa), exp-16383-63)
+	if b[0]&0x80 != 0 {
+		v = -v
+	}
+	return v
+}
@@ -0,0 +1,220 @@
+// Package audiotags reads a library straight from a folder of audio files: the title,
+// artist, genre, BPM, initial key, year, and length in each file's own tags (ID3v2 in
+// MP3 and AIFF files, Vorbis comments in FLAC), plus the key and energy Mixed In Key
+// writes into the comment ("8A - Energy 6"). Each track's Location is its file, so an
+// ordered set can be written as a playlist that plays.
+package audiotags
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Tags are the raw tag values magicmix reads from one file, as written.
+type Tags struct {
+	Title, Artist, Genre string
+	Album                string
+	BPM                  string
+	Key                  string // initial key, in any notation track.ParseKey reads
+	Energy               string // a dedicated energy tag, such as TXXX:EnergyLevel
+	Comment              string
+	Year                 string
+	Seconds              float64 // playing time; 0 when unknown
+	Cues                 *int    // cue points in the file's Serato markers; nil when it has none
+}
+
+// extensions are the audio files Load reads, by lower-cased extension.
+var extensions = map[string]func(*os.File, int64) (Tags, error){
+	".mp3":  readMP3,
+	".flac": func(f *os.File, _ int64) (Tags, error) { return readFLAC(f) },
+	".aif":  func(f *os.File, _ int64) (Tags, error) { return readAIFF(f) },
+	".aiff": func(f *os.File, _ int64) (Tags, error) { return readAIFF(f) },
+	".aifc": func(f *os.File, _ int64) (Tags, error) { return readAIFF(f) },
+}
+
+// IsAudio reports whether Load reads the file at path, going by its extension.
+func IsAudio(path string) bool {
+	_, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	return ok
+}
+
+// ReadFile reads the tags of one MP3, FLAC, or AIFF file.
+func ReadFile(path string) (Tags, error) {
+	read, ok := extensions[strings.ToLower(filepath.Ext(path))]
+	if !ok {
+		return Tags{}, fmt.Errorf("%s: not an MP3, FLAC, or AIFF file", path)
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return Tags{}, err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return Tags{}, err
+	}
+	tags, err := read(f, info.Size())
+	if err != nil {
+		return tags, fmt.Errorf("%s: %w", path, err)
+	}
+	return tags, nil
+}
+
+// Load reads every MP3, FLAC, and AIFF file under dir, in path order, into a library.
+// A file with no readable key is skipped with a warning, like a CSV row with none; a
+// file with no energy gets an inferred one, marked EnergyInferred.
+func Load(ctx context.Context, dir string) (csvio.Playlist, error) {
+	var paths []string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && IsAudio(path) && !strings.HasPrefix(d.Name(), "._") {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return csvio.Playlist{}, fmt.Errorf("read folder: %w", err)
+	}
+	if len(paths) == 0 {
+		return csvio.Playlist{}, fmt.Errorf("no MP3, FLAC, or AIFF files in %s", dir)
+	}
+	slices.Sort(paths)
+
+	var pl csvio.Playlist
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return csvio.Playlist{}, err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		tags, err := ReadFile(path)
+		if err != nil {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("%s: skipped: %v", rel, withoutPath(err, path)))
+			continue
+		}
+		t, warnings, err := tagsToTrack(tags, path)
+		for _, w := range warnings {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("%s: %s", rel, w))
+		}
+		if err != nil {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("%s: skipped: %v", rel, err))
+			continue
+		}
+		pl.Tracks = append(pl.Tracks, t)
+	}
+	for _, issue := range track.ValidateAll(pl.Tracks) {
+		if issue.Severity == track.SeverityWarning {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("track %d: %s", issue.Index+1, issue.Message))
+		}
+	}
+	return pl, nil
+}
+
+// withoutPath drops the path ReadFile prefixes its errors with, since the warning
+// names the file already.
+func withoutPath(err error, path string) string {
+	return strings.TrimPrefix(err.Error(), path+": ")
+}
+
+var (
+	// Mixed In Key writes "8A - Energy 6", or "8A - 6" with its short format, at the
+	// start of the comment.
+	commentKey     = regexp.MustCompile(`^\s*(\d{1,2}[ABab])\b`)
+	labelledEnergy = regexp.MustCompile(`(?i)\benergy\s*[:=]?\s*(\d{1,3})\b`)
+	keyDashEnergy  = regexp.MustCompile(`^\s*\d{1,2}[ABab]\s*-\s*(\d{1,2})\s*$`)
+	leadingYear    = regexp.MustCompile(`^\s*(\d{4})`)
+)
+
+// tagsToTrack builds a track from a file's tags. The title falls back to the file name
+// and the key to the comment's; an energy of 1-10 is scaled to 10-100.
+func tagsToTrack(tags Tags, path string) (track.Track, []string, error) {
+	var warnings []string
+	t := track.Track{
+		Title:  strings.TrimSpace(tags.Title),
+		Artist: strings.TrimSpace(tags.Artist),
+		Genre:  strings.TrimSpace(tags.Genre),
+		Album:  strings.TrimSpace(tags.Album),
+		Cues:   tags.Cues,
+	}
+	if t.Title == "" {
+		t.Title = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	t.Location = path
+
+	keyText := strings.TrimSpace(tags.Key)
+	if keyText == "" {
+		if m := commentKey.FindStringSubmatch(tags.Comment); m != nil {
+			keyText = m[1]
+		}
+	}
+	if keyText == "" {
+		return track.Track{}, nil, fmt.Errorf("no key (initial key tag or Mixed In Key comment)")
+	}
+	key, mods, err := track.ParseKeys(keyText)
+	if err != nil {
+		return track.Track{}, nil, err
+	}
+	t.Key, t.Modulations = key, mods
+
+	if s := strings.TrimSpace(tags.BPM); s != "" {
+		bpm, err := strconv.ParseFloat(strings.ReplaceAll(s, ",", "."), 64)
+		if err != nil || bpm <= 0 {
+			warnings = append(warnings, fmt.Sprintf("ignored invalid BPM %q", s))
+		} else {
+			t.BPM = bpm
+		}
+	}
+	if tags.Seconds > 0 {
+		seconds := int(math.Round(tags.Seconds))
+		t.Duration = &seconds
+	}
+	if m := leadingYear.FindStringSubmatch(tags.Year); m != nil {
+		if year, err := strconv.Atoi(m[1]); err == nil && year > 0 {
+			t.Year = &year
+		}
+	}
+
+	if energy, ok := parseEnergy(tags); ok {
+		t.Energy = energy
+	} else {
+		t.Energy = track.InferEnergy(t)
+		t.EnergyInferred = true
+	}
+	return t, warnings, nil
+}
+
+// parseEnergy reads a track's energy from a dedicated energy tag, or else from the
+// comment's "Energy 6" or "8A - 6".
+func parseEnergy(tags Tags) (int, bool) {
+	digits := strings.TrimSpace(tags.Energy)
+	if digits == "" {
+		if m := labelledEnergy.FindStringSubmatch(tags.Comment); m != nil {
+			digits = m[1]
+		} else if m := keyDashEnergy.FindStringSubmatch(tags.Comment); m != nil {
+			digits = m[1]
+		}
+	}
+	v, err := strconv.Atoi(digits)
+	if err != nil || v < 1 || v > 100 {
+		return 0, false
+	}
+	if v <= 10 {
+		v *= 10
+	}
+	return v, true
+}
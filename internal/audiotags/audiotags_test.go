@@ -0,0 +1,204 @@
+package audiotags
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// id3Tag builds an ID3v2.3 tag from frame IDs and their (already encoded) contents.
+func id3Tag(frames ...string) []byte {
+	var body bytes.Buffer
+	for i := 0; i+1 < len(frames); i += 2 {
+		body.WriteString(frames[i])
+		binary.Write(&body, binary.BigEndian, uint32(len(frames[i+1])))
+		body.Write([]byte{0, 0})
+		body.WriteString(frames[i+1])
+	}
+	n := body.Len()
+	hdr := []byte{'I', 'D', '3', 3, 0, 0, byte(n >> 21 & 0x7f), byte(n >> 14 & 0x7f), byte(n >> 7 & 0x7f), byte(n & 0x7f)}
+	return append(hdr, body.Bytes()...)
+}
+
+// mp3File is a tag followed by ten seconds of 128 kbit/s MPEG 1 Layer III frames.
+func mp3File(tag []byte) []byte {
+	frame := make([]byte, 417) // 144 * 128000 / 44100, unpadded
+	copy(frame, []byte{0xff, 0xfb, 0x90, 0x00})
+	audio := bytes.Repeat(frame, 383) // 10s at 38.28 frames a second
+	return append(tag, audio...)
+}
+
+func flacFile(comments ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString("fLaC")
+	info := make([]byte, 34)
+	// 44100 Hz, 20 bits from byte 10, then 36 bits of samples ending at byte 17.
+	info[10], info[11], info[12] = 44100>>12, 44100>>4&0xff, 44100&0xf<<4
+	binary.BigEndian.PutUint32(info[14:18], 44100*200)
+	b.Write([]byte{flacStreamInfo, 0, 0, 34})
+	b.Write(info)
+
+	var vc bytes.Buffer
+	binary.Write(&vc, binary.LittleEndian, uint32(4))
+	vc.WriteString("test")
+	binary.Write(&vc, binary.LittleEndian, uint32(len(comments)))
+	for _, c := range comments {
+		binary.Write(&vc, binary.LittleEndian, uint32(len(c)))
+		vc.WriteString(c)
+	}
+	n := vc.Len()
+	b.Write([]byte{0x80 | flacVorbisComment, byte(n >> 16), byte(n >> 8), byte(n)})
+	b.Write(vc.Bytes())
+	return b.Bytes()
+}
+
+func aiffFile(tag []byte) []byte {
+	comm := make([]byte, 18)
+	binary.BigEndian.PutUint16(comm[0:], 2)
+	binary.BigEndian.PutUint32(comm[2:], 48000*300)
+	binary.BigEndian.PutUint16(comm[6:], 16)
+	// 48000 as an 80-bit extended float: exponent 16383+15, mantissa 48000 << 48.
+	binary.BigEndian.PutUint16(comm[8:], 16383+15)
+	binary.BigEndian.PutUint64(comm[10:], 48000<<48)
+
+	var chunks bytes.Buffer
+	for _, c := range []struct {
+		id   string
+		data []byte
+	}{{"COMM", comm}, {"SSND", make([]byte, 8)}, {"ID3 ", tag}} {
+		chunks.WriteString(c.id)
+		binary.Write(&chunks, binary.BigEndian, uint32(len(c.data)))
+		chunks.Write(c.data)
+		if len(c.data)%2 == 1 {
+			chunks.WriteByte(0)
+		}
+	}
+	var b bytes.Buffer
+	b.WriteString("FORM")
+	binary.Write(&b, binary.BigEndian, uint32(4+chunks.Len()))
+	b.WriteString("AIFF")
+	b.Write(chunks.Bytes())
+	return b.Bytes()
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"a/Opening.mp3": mp3File(id3Tag(
+			"TIT2", "\x00Opening", "TPE1", "\x00Ana", "TCON", "\x00(13)Deep House", "TBPM", "\x00122",
+			"COMM", "\x00engiTunNORM\x00 0000 0000", "COMM", "\x00eng\x008A - Energy 6", "TYER", "\x002021")),
+		"b/Second.flac": flacFile("TITLE=Second", "ARTIST=Bo", "ARTIST=Cy", "BPM=124", "INITIALKEY=Dbm", "ENERGYLEVEL=7", "DATE=2019-05-01"),
+		"c/Third.aiff":  aiffFile(id3Tag("TIT2", "\x01\xff\xfeT\x00h\x00i\x00r\x00d\x00", "TBPM", "\x00126", "TKEY", "\x00Am")),
+		"d/NoKey.mp3":   mp3File(id3Tag("TIT2", "\x00No Key")),
+		"d/cover.jpg":   []byte("not audio"),
+	}
+	for name, data := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pl, err := Load(context.Background(), dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pl.Tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3 (the keyless one skipped); warnings %q", len(pl.Tracks), pl.Warnings)
+	}
+
+	mp3 := pl.Tracks[0]
+	if mp3.Title != "Opening" || mp3.Artist != "Ana" || mp3.Genre != "Deep House" || mp3.BPM != 122 || mp3.Key.String() != "8A" {
+		t.Errorf("mp3 track = %+v", mp3)
+	}
+	if mp3.Energy != 60 || mp3.EnergyInferred {
+		t.Errorf("mp3 energy = %d (inferred %v), want 60 from the Mixed In Key comment", mp3.Energy, mp3.EnergyInferred)
+	}
+	if mp3.Duration == nil || *mp3.Duration != 10 || mp3.Year == nil || *mp3.Year != 2021 {
+		t.Errorf("mp3 length %v and year %v, want 10s and 2021", mp3.Duration, mp3.Year)
+	}
+	if mp3.Location != filepath.Join(dir, "a/Opening.mp3") {
+		t.Errorf("mp3 location = %q", mp3.Location)
+	}
+
+	flac := pl.Tracks[1]
+	if flac.Artist != "Bo, Cy" || flac.Key.String() != "12A" || flac.Energy != 70 || flac.Year == nil || *flac.Year != 2019 {
+		t.Errorf("flac track = %+v", flac)
+	}
+	if flac.Duration == nil || *flac.Duration != 200 {
+		t.Errorf("flac length = %v, want 200s", flac.Duration)
+	}
+
+	aiff := pl.Tracks[2]
+	if aiff.Title != "Third" || aiff.Key.String() != "8A" || !aiff.EnergyInferred {
+		t.Errorf("aiff track = %+v", aiff)
+	}
+	if aiff.Duration == nil || *aiff.Duration != 300 {
+		t.Errorf("aiff length = %v, want 300s", aiff.Duration)
+	}
+
+	if !strings.Contains(strings.Join(pl.Warnings, "\n"), "NoKey.mp3: skipped") {
+		t.Errorf("expected a warning for the keyless file, got %q", pl.Warnings)
+	}
+
+	if _, err := Load(context.Background(), filepath.Join(dir, "missing")); err == nil {
+		t.Error("a missing folder should be an error")
+	}
+}
+
+// seratoObject builds a Serato Markers2 object holding entries of the given names.
+func seratoObject(names ...string) string {
+	var entries bytes.Buffer
+	entries.Write([]byte{1, 1})
+	for _, name := range names {
+		entries.WriteString(name + "\x00")
+		binary.Write(&entries, binary.BigEndian, uint32(3))
+		entries.Write([]byte{0, 0, 0})
+	}
+	entries.WriteByte(0)
+	return "\x01\x01" + base64.RawStdEncoding.EncodeToString(entries.Bytes()) + "\x00\x00"
+}
+
+func TestReadFileCountsSeratoCues(t *testing.T) {
+	dir := t.TempDir()
+	object := seratoObject("COLOR", "CUE", "CUE", "LOOP", "CUE", "BPMLOCK")
+	mp3 := filepath.Join(dir, "cued.mp3")
+	geob := "\x00application/octet-stream\x00\x00" + seratoMarkers + "\x00" + object
+	if err := os.WriteFile(mp3, mp3File(id3Tag("TIT2", "\x00Cued", "GEOB", geob)), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flac := filepath.Join(dir, "cued.flac")
+	comment := base64.StdEncoding.EncodeToString([]byte("application/octet-stream\x00\x00" + seratoMarkers + "\x00" + seratoObject("CUE")))
+	if err := os.WriteFile(flac, flacFile("TITLE=Cued", "SERATO_MARKERS_V2="+comment), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	bare := filepath.Join(dir, "bare.mp3")
+	if err := os.WriteFile(bare, mp3File(id3Tag("TIT2", "\x00Bare")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]int{mp3: 3, flac: 1} {
+		tags, err := ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if tags.Cues == nil || *tags.Cues != want {
+			t.Errorf("%s: cues = %v, want %d", filepath.Base(path), tags.Cues, want)
+		}
+	}
+	tags, err := ReadFile(bare)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tags.Cues != nil {
+		t.Errorf("a file without Serato markers should have unknown cues, got %d", *tags.Cues)
+	}
+}
@@ -0,0 +1,133 @@
+package audiotags
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// FLAC metadata block types magicmix reads.
+const (
+	flacStreamInfo    = 0
+	flacVorbisComment = 4
+)
+
+// readFLAC reads a FLAC file's metadata blocks: the length from STREAMINFO and the tags
+// from the Vorbis comment. Other blocks, such as cover art, are skipped unread.
+func readFLAC(f *os.File) (Tags, error) {
+	var tags Tags
+	magic := make([]byte, 4)
+	if _, err := io.ReadFull(f, magic); err != nil {
+		return tags, fmt.Errorf("read header: %w", err)
+	}
+	if string(magic) != "fLaC" {
+		return tags, errors.New("not a FLAC stream")
+	}
+	hdr := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(f, hdr); err != nil {
+			return tags, fmt.Errorf("read metadata: %w", err)
+		}
+		last, kind := hdr[0]&0x80 != 0, hdr[0]&0x7f
+		size := int64(hdr[1])<<16 | int64(hdr[2])<<8 | int64(hdr[3])
+		switch kind {
+		case flacStreamInfo, flacVorbisComment:
+			block := make([]byte, size)
+			if _, err := io.ReadFull(f, block); err != nil {
+				return tags, fmt.Errorf("read metadata: %w", err)
+			}
+			if kind == flacStreamInfo && len(block) >= 18 {
+				// 20 bits of sample rate, then channels and bits per sample, then 36 bits
+				// of total samples.
+				rate := uint64(block[10])<<12 | uint64(block[11])<<4 | uint64(block[12])>>4
+				samples := uint64(block[13]&0x0f)<<32 | uint64(binary.BigEndian.Uint32(block[14:18]))
+				if rate > 0 && samples > 0 {
+					tags.Seconds = float64(samples) / float64(rate)
+				}
+			} else if kind == flacVorbisComment {
+				readVorbisComment(block, &tags)
+			}
+		default:
+			if _, err := f.Seek(size, io.SeekCurrent); err != nil {
+				return tags, fmt.Errorf("skip metadata: %w", err)
+			}
+		}
+		if last {
+			return tags, nil
+		}
+	}
+}
+
+// readVorbisComment reads the FIELD=value comments of a Vorbis comment block: a
+// little-endian length-prefixed vendor string, a count, then each comment the same way.
+func readVorbisComment(b []byte, tags *Tags) {
+	next := func() (string, bool) {
+		if len(b) < 4 {
+			return "", false
+		}
+		n := int(binary.LittleEndian.Uint32(b))
+		if n < 0 || 4+n > len(b) {
+			return "", false
+		}
+		s := string(b[4 : 4+n])
+		b = b[4+n:]
+		return s, true
+	}
+	if _, ok := next(); !ok || len(b) < 4 {
+		return
+	}
+	count := int(binary.LittleEndian.Uint32(b))
+	b = b[4:]
+	for range count {
+		comment, ok := next()
+		if !ok {
+			return
+		}
+		field, value, ok := strings.Cut(comment, "=")
+		if !ok {
+			continue
+		}
+		setVorbisField(strings.ToUpper(field), value, tags)
+	}
+}
+
+// setVorbisField stores one Vorbis comment, keeping the first of a repeated field
+// except for ARTIST, whose values are joined.
+func setVorbisField(field, value string, tags *Tags) {
+	set := func(dst *string) {
+		if *dst == "" {
+			*dst = value
+		}
+	}
+	switch field {
+	case "TITLE":
+		set(&tags.Title)
+	case "ARTIST":
+		if tags.Artist != "" {
+			tags.Artist += ", " + value
+		} else {
+			tags.Artist = value
+		}
+	case "GENRE":
+		set(&tags.Genre)
+	case "ALBUM":
+		set(&tags.Album)
+	case "BPM", "TEMPO":
+		set(&tags.BPM)
+	case "INITIALKEY", "KEY":
+		set(&tags.Key)
+	case "COMMENT", "DESCRIPTION":
+		set(&tags.Comment)
+	case "DATE", "YEAR":
+		set(&tags.Year)
+	case "ENERGYLEVEL", "ENERGY":
+		set(&tags.Energy)
+	case "SERATO_MARKERS_V2":
+		if cues, ok := flacSeratoCues(value); ok && tags.Cues == nil {
+			tags.Cues = &cues
+		}
+	}
+}
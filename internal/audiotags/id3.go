@@ -0,0 +1,258 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+)
+
+// id3Header is the size of an ID3v2 tag header (and of a frame header from v2.3 on).
+const id3Header = 10
+
+// id3Size reads the size in an ID3v2 tag header, or 0 when hdr doesn't start one.
+func id3Size(hdr []byte) int {
+	if len(hdr) < id3Header || string(hdr[:3]) != "ID3" {
+		return 0
+	}
+	return id3Header + syncsafe(hdr[6:10])
+}
+
+// syncsafe reads a big-endian integer whose bytes each carry 7 bits.
+func syncsafe(b []byte) int {
+	n := 0
+	for _, c := range b {
+		n = n<<7 | int(c&0x7f)
+	}
+	return n
+}
+
+// v22Frames maps ID3v2.2's three-letter frame IDs to their v2.3 names.
+var v22Frames = map[string]string{
+	"TT2": "TIT2", "TP1": "TPE1", "TCO": "TCON", "TBP": "TBPM", "TKE": "TKEY",
+	"TYE": "TYER", "TLE": "TLEN", "COM": "COMM", "TXX": "TXXX", "GEO": "GEOB",
+}
+
+// parseID3 reads the frames magicmix uses from a whole ID3v2 tag, header included.
+func parseID3(tag []byte, tags *Tags) error {
+	if id3Size(tag) == 0 || len(tag) < id3Header {
+		return errors.New("not an ID3v2 tag")
+	}
+	version, flags := tag[3], tag[5]
+	if version < 2 || version > 4 {
+		return errors.New("unsupported ID3v2 version 2." + strconv.Itoa(int(version)))
+	}
+	body := tag[id3Header:]
+	if flags&0x80 != 0 && version < 4 {
+		body = unsynchronize(body)
+	}
+	if flags&0x40 != 0 && version > 2 && len(body) >= 4 {
+		// Skip the extended header: v2.3 gives its size without the size field itself,
+		// v2.4 as a syncsafe size including it.
+		size := int(binary.BigEndian.Uint32(body)) + 4
+		if version == 4 {
+			size = syncsafe(body[:4])
+		}
+		body = body[min(size, len(body)):]
+	}
+
+	idLen, headLen := 4, id3Header
+	if version == 2 {
+		idLen, headLen = 3, 6
+	}
+	for len(body) >= headLen && body[0] != 0 {
+		id := string(body[:idLen])
+		var size int
+		var frameFlags uint16
+		switch version {
+		case 2:
+			size = int(body[3])<<16 | int(body[4])<<8 | int(body[5])
+			id = v22Frames[id]
+		case 3:
+			size = int(binary.BigEndian.Uint32(body[4:8]))
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		default:
+			size = syncsafe(body[4:8])
+			frameFlags = binary.BigEndian.Uint16(body[8:10])
+		}
+		if size < 0 || headLen+size > len(body) {
+			break
+		}
+		data := body[headLen : headLen+size]
+		body = body[headLen+size:]
+		if data = frameData(version, frameFlags, data); data != nil {
+			readFrame(id, data, tags)
+		}
+	}
+	return nil
+}
+
+// frameData strips a frame's flag-driven extras from data, or returns nil for a frame
+// that is compressed or encrypted and can't be read.
+func frameData(version byte, flags uint16, data []byte) []byte {
+	switch version {
+	case 3:
+		if flags&0x00c0 != 0 { // compressed or encrypted
+			return nil
+		}
+		if flags&0x0020 != 0 && len(data) > 0 { // grouping identity
+			data = data[1:]
+		}
+	case 4:
+		if flags&0x000c != 0 {
+			return nil
+		}
+		if flags&0x0040 != 0 && len(data) > 0 {
+			data = data[1:]
+		}
+		if flags&0x0001 != 0 && len(data) >= 4 { // data length indicator
+			data = data[4:]
+		}
+		if flags&0x0002 != 0 {
+			data = unsynchronize(data)
+		}
+	}
+	return data
+}
+
+// unsynchronize undoes ID3's unsynchronisation, which inserts a zero after every 0xFF.
+func unsynchronize(b []byte) []byte {
+	return bytes.ReplaceAll(b, []byte{0xff, 0x00}, []byte{0xff})
+}
+
+func readFrame(id string, data []byte, tags *Tags) {
+	switch id {
+	case "TIT2":
+		tags.Title = id3Text(data)
+	case "TPE1":
+		tags.Artist = strings.ReplaceAll(id3Text(data), "\x00", ", ")
+	case "TALB":
+		tags.Album = id3Text(data)
+	case "TCON":
+		tags.Genre = id3Genre(id3Text(data))
+	case "TBPM":
+		tags.BPM = id3Text(data)
+	case "TKEY":
+		tags.Key = id3Text(data)
+	case "TYER", "TDRC":
+		tags.Year = id3Text(data)
+	case "TLEN":
+		if ms, err := strconv.Atoi(id3Text(data)); err == nil && ms > 0 {
+			tags.Seconds = float64(ms) / 1000
+		}
+	case "COMM":
+		// Encoding, a language code, then a description and the comment, each ended by
+		// the encoding's terminator. Players' bookkeeping comments (iTunNORM and the
+		// like) carry a description; the one a tagger writes usually doesn't.
+		if len(data) < 4 || tags.Comment != "" {
+			return
+		}
+		desc, text := splitTerminated(data[0], data[4:])
+		if !strings.HasPrefix(desc, "iTun") {
+			tags.Comment = decodeText(data[0], text)
+		}
+	case "GEOB":
+		// Encoding, a NUL-ended MIME type, then a file name and a description ended by
+		// the encoding's terminator, and the object.
+		if len(data) < 2 || tags.Cues != nil {
+			return
+		}
+		end := bytes.IndexByte(data[1:], 0)
+		if end < 0 {
+			return
+		}
+		_, rest := splitTerminated(data[0], data[2+end:])
+		desc, object := splitTerminated(data[0], rest)
+		if desc == seratoMarkers {
+			if cues, ok := seratoCues(object); ok {
+				tags.Cues = &cues
+			}
+		}
+	case "TXXX":
+		if len(data) < 2 {
+			return
+		}
+		desc, value := splitTerminated(data[0], data[1:])
+		switch strings.ToLower(strings.TrimSpace(desc)) {
+		case "energylevel", "energy", "energy level":
+			tags.Energy = decodeText(data[0], value)
+		case "initialkey", "key":
+			if tags.Key == "" {
+				tags.Key = decodeText(data[0], value)
+			}
+		}
+	}
+}
+
+// id3Text decodes a text frame, keeping multiple values apart with NULs.
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	return strings.TrimRight(decodeText(data[0], data[1:]), "\x00")
+}
+
+// id3Genre drops ID3v1-style numeric references, "(17)" or "17", from a genre, keeping
+// any text after them.
+func id3Genre(s string) string {
+	s, _, _ = strings.Cut(s, "\x00")
+	for strings.HasPrefix(s, "(") {
+		end := strings.IndexByte(s, ')')
+		if end < 0 {
+			break
+		}
+		s = s[end+1:]
+	}
+	if _, err := strconv.Atoi(strings.TrimSpace(s)); err == nil {
+		return ""
+	}
+	return strings.TrimSpace(s)
+}
+
+// splitTerminated splits b at the first terminator of the given text encoding (one NUL
+// byte, or two aligned NUL bytes for UTF-16), decoding the part before it.
+func splitTerminated(enc byte, b []byte) (head string, rest []byte) {
+	if enc == 1 || enc == 2 {
+		for i := 0; i+1 < len(b); i += 2 {
+			if b[i] == 0 && b[i+1] == 0 {
+				return decodeText(enc, b[:i]), b[i+2:]
+			}
+		}
+		return decodeText(enc, b), nil
+	}
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		return decodeText(enc, b[:i]), b[i+1:]
+	}
+	return decodeText(enc, b), nil
+}
+
+// decodeText decodes ID3 text: 0 is ISO-8859-1, 1 UTF-16 with a byte-order mark, 2
+// UTF-16BE, and 3 UTF-8.
+func decodeText(enc byte, b []byte) string {
+	switch enc {
+	case 0:
+		runes := make([]rune, len(b))
+		for i, c := range b {
+			runes[i] = rune(c)
+		}
+		return strings.TrimRight(string(runes), "\x00")
+	case 1, 2:
+		var order binary.ByteOrder = binary.BigEndian
+		if enc == 1 && len(b) >= 2 {
+			switch {
+			case b[0] == 0xff && b[1] == 0xfe:
+				order, b = binary.LittleEndian, b[2:]
+			case b[0] == 0xfe && b[1] == 0xff:
+				b = b[2:]
+			}
+		}
+		units := make([]uint16, len(b)/2)
+		for i := range units {
+			units[i] = order.Uint16(b[2*i:])
+		}
+		return strings.TrimRight(strings.ReplaceAll(string(utf16.Decode(units)), "\uFEFF", ""), "\x00")
+	}
+	return strings.TrimRight(string(b), "\x00")
+}
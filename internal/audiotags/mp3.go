@@ -0,0 +1,90 @@
+package audiotags
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// mp3Probe is how much audio after the tag readMP3 reads to find the first frame and
+// any Xing/Info header in it.
+const mp3Probe = 8 << 10
+
+// readMP3 reads an MP3's ID3v2 tag and, when the tag gives no length (TLEN), works it
+// out from the first audio frame: exactly from a Xing/Info header's frame count, or
+// from the bitrate for a constant-bitrate file.
+func readMP3(f *os.File, size int64) (Tags, error) {
+	var tags Tags
+	hdr := make([]byte, id3Header)
+	if _, err := io.ReadFull(f, hdr); err != nil {
+		return tags, fmt.Errorf("read header: %w", err)
+	}
+	audioStart := int64(0)
+	if n := id3Size(hdr); n > 0 {
+		tag := make([]byte, n)
+		copy(tag, hdr)
+		if _, err := io.ReadFull(f, tag[id3Header:]); err != nil {
+			return tags, fmt.Errorf("read ID3 tag: %w", err)
+		}
+		if err := parseID3(tag, &tags); err != nil {
+			return tags, err
+		}
+		audioStart = int64(n)
+		if hdr[5]&0x10 != 0 { // a footer follows the tag
+			audioStart += id3Header
+		}
+	}
+	if tags.Seconds > 0 {
+		return tags, nil
+	}
+	probe := make([]byte, mp3Probe)
+	n, err := f.ReadAt(probe, audioStart)
+	if err != nil && err != io.EOF {
+		return tags, fmt.Errorf("read audio: %w", err)
+	}
+	tags.Seconds = mp3Seconds(probe[:n], size-audioStart)
+	return tags, nil
+}
+
+// MPEG audio tables, indexed by version (0 = MPEG 1, 1 = MPEG 2 and 2.5).
+var (
+	mp3Bitrates    = [2][16]int{{0, 32, 40, 48, 56, 64, 80, 96, 112, 128, 160, 192, 224, 256, 320}, {0, 8, 16, 24, 32, 40, 48, 56, 64, 80, 96, 112, 128, 144, 160}}
+	mp3SideInfo    = [2][2]int{{32, 17}, {17, 9}}                                                             // stereo, mono
+	mp3SampleRates = [4][3]int{{11025, 12000, 8000}, {0, 0, 0}, {22050, 24000, 16000}, {44100, 48000, 32000}} // by the header's version bits
+)
+
+// mp3Seconds estimates the playing time of audioLen bytes of Layer III audio from the
+// first frame found in probe, or 0 when there is none.
+func mp3Seconds(probe []byte, audioLen int64) float64 {
+	for i := 0; i+4 <= len(probe); i++ {
+		if probe[i] != 0xff || probe[i+1]&0xe0 != 0xe0 {
+			continue
+		}
+		h := binary.BigEndian.Uint32(probe[i:])
+		versionBits, layer := h>>19&3, h>>17&3
+		bitrateIdx, rateIdx := h>>12&15, h>>10&3
+		if versionBits == 1 || layer != 1 || bitrateIdx == 0 || bitrateIdx == 15 || rateIdx == 3 {
+			continue // not a Layer III frame header
+		}
+		table, samples := 0, 1152.0
+		if versionBits != 3 {
+			table, samples = 1, 576
+		}
+		rate := float64(mp3SampleRates[versionBits][rateIdx])
+		// A Xing or Info header sits after the side information and counts the frames.
+		side := mp3SideInfo[table][0]
+		if h>>6&3 == 3 { // mono
+			side = mp3SideInfo[table][1]
+		}
+		if x := i + 4 + side; x+12 <= len(probe) {
+			if tag := string(probe[x : x+4]); (tag == "Xing" || tag == "Info") && probe[x+7]&1 != 0 {
+				frames := binary.BigEndian.Uint32(probe[x+8:])
+				return float64(frames) * samples / rate
+			}
+		}
+		bitrate := float64(mp3Bitrates[table][bitrateIdx]) * 1000
+		return float64(audioLen-int64(i)) * 8 / bitrate
+	}
+	return 0
+}
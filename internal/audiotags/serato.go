@@ -0,0 +1,77 @@
+package audiotags
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"strings"
+)
+
+// seratoMarkers is the name Serato gives its cue, loop, and color data: the
+// description of an ID3 GEOB frame, and the object header inside FLAC's
+// SERATO_MARKERS_V2 comment.
+const seratoMarkers = "Serato Markers2"
+
+// seratoCues counts the cue points in a Serato Markers2 object: a two-byte version,
+// then base64 text that decodes to a version of its own and a run of entries, each a
+// NUL-ended name ("CUE", "LOOP", "COLOR", …), a big-endian length, and that many bytes.
+// It reports false when b doesn't hold such an object.
+func seratoCues(b []byte) (int, bool) {
+	if !bytes.HasPrefix(b, []byte{1, 1}) {
+		return 0, false
+	}
+	data, ok := seratoBase64(string(b[2:]))
+	if !ok || !bytes.HasPrefix(data, []byte{1, 1}) {
+		return 0, false
+	}
+	data = data[2:]
+	cues := 0
+	for len(data) > 0 {
+		end := bytes.IndexByte(data, 0)
+		if end <= 0 || len(data) < end+5 {
+			break
+		}
+		name := string(data[:end])
+		size := int(binary.BigEndian.Uint32(data[end+1:]))
+		data = data[end+5:]
+		if size > len(data) {
+			break
+		}
+		data = data[size:]
+		if name == "CUE" {
+			cues++
+		}
+	}
+	return cues, true
+}
+
+// flacSeratoCues counts the cue points in a SERATO_MARKERS_V2 comment, which is base64
+// of a GEOB-like header (MIME type, file name, and description, each NUL-ended)
+// followed by the Markers2 object itself.
+func flacSeratoCues(value string) (int, bool) {
+	data, ok := seratoBase64(value)
+	if !ok {
+		return 0, false
+	}
+	_, object, found := bytes.Cut(data, []byte(seratoMarkers+"\x00"))
+	if !found {
+		return 0, false
+	}
+	return seratoCues(object)
+}
+
+// seratoBase64 decodes Serato's base64, which wraps its lines, drops padding, and may
+// end in a stray character, and is followed by NUL padding inside the tag.
+func seratoBase64(s string) ([]byte, bool) {
+	s = strings.Map(func(r rune) rune {
+		if r == '\n' || r == '\r' || r == 0 || r == '=' {
+			return -1
+		}
+		return r
+	}, s)
+	if len(s)%4 == 1 {
+		s = s[:len(s)-1]
+	}
+	data, err := base64.RawStdEncoding.DecodeString(s)
+	return data, err == nil
+}
@@ -0,0 +1,132 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/libcache"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// libraryFormat says how to read an input library.
+type libraryFormat struct {
+	name        playlistio.Format // "" to go by the file extension
+	energyField string            // the Rekordbox TRACK attribute holding energy; "" for the default
+	encoding    string            // a CSV's character encoding (see csvio.ParseEncoding); "" to detect it
+}
+
+// parseInputFormat validates --input-format against the registered readers; "" leaves
+// the choice to each input's extension.
+func parseInputFormat(s string) (playlistio.Format, error) {
+	if strings.TrimSpace(s) == "" {
+		return "", nil
+	}
+	return playlistio.ParseInputFormat(s)
+}
+
+// of resolves the format for one input path: the named format, or the one its
+// extension calls for (Rekordbox for .xml, JSON for .json, CSV otherwise). Standard
+// input has no extension, so it resolves to "" and playlistio.Load reads its content
+// to tell.
+func (f libraryFormat) of(path string) playlistio.Format {
+	if f.name != "" {
+		return f.name
+	}
+	if path == playlistio.Stdio {
+		return ""
+	}
+	return playlistio.InputFormatOf(path)
+}
+
+// loadLibrary is loadLibraryAs with the format taken from the path's extension.
+func loadLibrary(ctx context.Context, path string, noCache bool) (csvio.Playlist, error) {
+	return loadLibraryAs(ctx, path, noCache, libraryFormat{})
+}
+
+// loadLibraryAs reads a library in format f — a CSV file through the parsed-library
+// cache unless noCache is set, standard input and any other format with its registered
+// reader — inferring missing energies through the user's genre taxonomy, then
+// normalizes genres through it. A cache that cannot be located (no home directory)
+// degrades to plain parsing.
+func loadLibraryAs(ctx context.Context, path string, noCache bool, f libraryFormat) (csvio.Playlist, error) {
+	genres, err := loadGenres()
+	if err != nil {
+		return csvio.Playlist{}, err
+	}
+	var pl csvio.Playlist
+	ctx = csvio.WithGenres(ctx, genres)
+	if f.encoding != "" {
+		ctx = csvio.WithEncoding(ctx, f.encoding)
+	}
+	if format := f.of(path); format != playlistio.CSV || path == playlistio.Stdio {
+		pl, err = playlistio.Load(ctx, path, format, playlistio.ReadOptions{EnergyField: f.energyField})
+	} else {
+		var cache *libcache.Cache
+		if !noCache {
+			cache, _ = libcache.Default()
+		}
+		pl, _, err = cache.LoadPlaylist(ctx, path)
+	}
+	if err != nil {
+		return pl, err
+	}
+	// A cached parse may predate an edit to genres.json, and other formats' readers
+	// infer with the built-in taxonomy, so estimates are redone here.
+	for i := range pl.Tracks {
+		t := &pl.Tracks[i]
+		t.Genre = genres.Normalize(t.Genre)
+		if t.EnergyInferred {
+			t.Energy = track.InferEnergyWith(*t, genres)
+		}
+	}
+	return pl, nil
+}
+
+// loadGenres returns the built-in genre taxonomy extended with the user's genres.json
+// from the config directory, when there is one.
+func loadGenres() (*genre.Taxonomy, error) {
+	tx := genre.Default()
+	dir, err := config.Dir()
+	if err != nil {
+		return tx, nil
+	}
+	return tx, tx.LoadFile(filepath.Join(dir, "genres.json"))
+}
+
+// runCache handles `magicmix cache ...`; today only `clear`.
+func runCache(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix cache", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix cache clear\n\n")
+		_, _ = fmt.Fprintf(w, "Remove the parsed-library cache kept in the config directory.\n")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 || fs.Arg(0) != "clear" {
+		fs.Usage()
+		return errors.New("cache needs a command: clear")
+	}
+
+	cache, err := libcache.Default()
+	if err != nil {
+		return err
+	}
+	n, err := cache.Clear()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d cached library file(s) from %s\n", n, cache.Dir())
+	return nil
+}
@@ -0,0 +1,105 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"runtime"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// candidate is one seeded ordering from --candidates and how it scored.
+type candidate struct {
+	seed   int64
+	result strategy.Result
+	eval   float64 // eval.Evaluate total: the yardstick candidates are judged by
+	mix    float64 // strategy.ScoreMix total, the tie-break
+	err    error
+}
+
+// candidateSeeds returns n seeds: seed itself first, so one candidate is the plain
+// run, then seeds drawn from it, so the same seed always gives the same candidates.
+func candidateSeeds(seed int64, n int) []int64 {
+	seeds := make([]int64, n)
+	seeds[0] = seed
+	r := rand.New(rand.NewSource(seed))
+	for i := 1; i < n; i++ {
+		seeds[i] = r.Int63()
+	}
+	return seeds
+}
+
+// sortCandidates sorts tracks once per seed, concurrently, and returns every
+// candidate with the index of the best: a finished sort beats one stopped by the
+// timeout, then the lowest eval score (judged with opts) wins, then the lowest mix score, then the
+// earliest seed. A budget above zero limits each sort, from when it starts, so the
+// candidates waiting their turn keep all of theirs.
+func sortCandidates(ctx context.Context, sorter strategy.Sorter, tracks []track.Track, seeds []int64, budget time.Duration,
+	opts eval.Options) ([]candidate, int, error) {
+	cands := make([]candidate, len(seeds))
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	var wg sync.WaitGroup
+	for i, seed := range seeds {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			c := candidate{seed: seed}
+			sortCtx, cancel := maybeWithTimeout(strategy.WithSeed(ctx, seed), budget)
+			c.result, c.err = strategy.Sort(sortCtx, sorter, slices.Clone(tracks))
+			if cancel != nil {
+				cancel()
+			}
+			if c.err == nil {
+				c.eval = eval.EvaluateWith(c.result.Ordered, opts).Total
+				c.mix = strategy.ScoreMix(c.result.Ordered).Total
+			}
+			cands[i] = c
+		}()
+	}
+	wg.Wait()
+
+	best := -1
+	for i, c := range cands {
+		if c.err != nil {
+			return nil, 0, c.err
+		}
+		if best < 0 || betterCandidate(c, cands[best]) {
+			best = i
+		}
+	}
+	return cands, best, nil
+}
+
+func betterCandidate(a, b candidate) bool {
+	if a.result.Partial != b.result.Partial {
+		return !a.result.Partial
+	}
+	if a.eval != b.eval {
+		return a.eval < b.eval
+	}
+	return a.mix < b.mix
+}
+
+// printCandidates lists each candidate's seed and scores and marks the one kept.
+func printCandidates(cands []candidate, best int) {
+	fmt.Printf("Sorted %d candidates; kept #%d (lower is better):\n", len(cands), best+1)
+	for i, c := range cands {
+		mark := " "
+		if i == best {
+			mark = "*"
+		}
+		partial := ""
+		if c.result.Partial {
+			partial = " (stopped early)"
+		}
+		fmt.Printf(" %s #%-2d seed %-20d evaluate %8.2f  mix %8.2f%s\n", mark, i+1, c.seed, c.eval, c.mix, partial)
+	}
+}
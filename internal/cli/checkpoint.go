@@ -0,0 +1,72 @@
+package cli
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+// checkpointFile saves optimizer checkpoints to path, through a temporary file and
+// rename so an interruption mid-write leaves the previous checkpoint whole. The first
+// write error is kept for Err; the sort carries on without checkpoints.
+type checkpointFile struct {
+	path string
+	err  error
+}
+
+// save is a strategy.Checkpoints Save function.
+func (c *checkpointFile) save(cp strategy.Checkpoint) {
+	if c.err != nil {
+		return
+	}
+	data, err := json.Marshal(cp)
+	if err != nil {
+		c.err = err
+		return
+	}
+	dir := filepath.Dir(c.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		c.err = err
+		return
+	}
+	tmp, err := os.CreateTemp(dir, ".checkpoint-*")
+	if err != nil {
+		c.err = err
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr == nil && cerr == nil {
+		werr = os.Rename(tmp.Name(), c.path)
+	}
+	if werr != nil || cerr != nil {
+		_ = os.Remove(tmp.Name())
+		c.err = werr
+		if c.err == nil {
+			c.err = cerr
+		}
+	}
+}
+
+func (c *checkpointFile) Err() error {
+	if c.err != nil {
+		return fmt.Errorf("write checkpoint: %w", c.err)
+	}
+	return nil
+}
+
+// readCheckpoint reads a checkpoint written by --checkpoint.
+func readCheckpoint(path string) (*strategy.Checkpoint, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read checkpoint: %w", err)
+	}
+	var cp strategy.Checkpoint
+	if err := json.Unmarshal(data, &cp); err != nil {
+		return nil, fmt.Errorf("read checkpoint %s: %w", path, err)
+	}
+	return &cp, nil
+}
@@ -1,17 +1,30 @@
 package cli
 
 import (
+	"cmp"
 	"context"
+	"encoding/json"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
+	"maps"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"slices"
 	"strings"
+	"text/tabwriter"
 	"time"
 
+	"github.com/YakDriver/magicmix/internal/config"
 	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/libcache"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/rekordbox"
 	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
 )
 
 // Run is the entry point for the CLI application.
@@ -22,24 +35,145 @@ func Run() error {
 	return run(ctx, os.Args[1:])
 }
 
-func run(ctx context.Context, args []string) error {
-	if len(args) > 0 && args[0] == "tournament" {
-		return runTournament(ctx, args[1:])
+func run(ctx context.Context, args []string) (retErr error) {
+	if len(args) > 0 {
+		switch args[0] {
+		case "tournament":
+			return runTournament(ctx, args[1:])
+		case "crates":
+			return runCrates(ctx, args[1:])
+		case "cache":
+			return runCache(ctx, args[1:])
+		case "evaluate":
+			return runEvaluate(ctx, args[1:])
+		case "compare":
+			return runCompare(ctx, args[1:])
+		case "matrix":
+			return runMatrix(ctx, args[1:])
+		case "previews":
+			return runPreviews(ctx, args[1:])
+		case "libdiff":
+			return runLibdiff(ctx, args[1:])
+		case "partners":
+			return runPartners(ctx, args[1:])
+		case "fairness":
+			return runFairness(ctx, args[1:])
+		case "radio":
+			return runRadio(ctx, args[1:])
+		case "residency":
+			return runResidency(ctx, args[1:])
+		case "replan":
+			return runReplan(ctx, args[1:])
+		case "why":
+			return runWhy(ctx, args[1:])
+		case "style":
+			return runStyle(ctx, args[1:])
+		case "serve":
+			return runServe(ctx, args[1:])
+		case "run":
+			return runJob(ctx, args[1:])
+		case "workspace":
+			return runWorkspace(ctx, args[1:])
+		case "sort":
+			args = args[1:] // the main command, named
+		}
 	}
 
 	fs := flag.NewFlagSet("magicmix", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 
-	inputPath := fs.String("input", "", "Path to the input CSV file")
-	outputPath := fs.String("output", "", "Path to write the sorted CSV file")
+	var inputValues stringsFlag
+	fs.Var(&inputValues, "input", "Path to the input CSV, JSON, or Rekordbox XML library, a folder of MP3, FLAC, and AIFF files, or - for standard input; repeat as PATH:WEIGHT to blend libraries")
+	inputFormatName := fs.String("input-format", "", "Input format: csv, json, rekordbox, setlist, or folder (default: from the --input extension or a folder, or the content of standard input)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy, e.g. Comments, Grouping, or Rating")
+	encodingName := fs.String("encoding", "auto", "Character encoding of a CSV input: utf-8, windows-1252, latin-1, or auto to detect it; the output is written back in it")
+	outputPath := fs.String("output", "", "Path to write the sorted set, or - for standard output (a .m3u8, .m3u, .json, or .xml name writes a playlist; default: standard output for standard input)")
+	formatName := fs.String("output-format", "", "Output format: csv, m3u8, m3u, json, rekordbox, or setlist (default: from the --output extension)")
+	fs.StringVar(formatName, "format", "", "Alias of --output-format")
 	strategyName := fs.String("strategy", "default", "Sorting strategy to apply")
+	stagesSpec := fs.String("stages", "", "Order the set in stages, each by its own strategy: comma-separated strategy:size in set order, sized by tracks or length (rotation:30m,flow,anneal:60m); the stage without a size takes the rest")
 	listStrategies := fs.Bool("list-strategies", false, "List available strategies and exit")
 	limit := fs.Int("limit", 0, "Optional maximum number of tracks to write")
+	targetDuration := fs.Duration("target-duration", 0, "Pick tracks whose lengths add up to as close to this as possible without going over, e.g. 60m")
+	subsetName := fs.String("subset", "", "What --limit or --target-duration keeps when it picks a subset of the library: "+subsetNames()+" (default: the strategy's choice as it sorts)")
 	keepAll := fs.Bool("keep-all", false, "Keep every track; do not drop ones that don't fit the mix")
+	keepVersions := fs.Bool("keep-versions", false, "Keep every version of a song (remixes, edits) and space them apart")
+	dedup := fs.Bool("dedup", false, "Drop duplicate copies of a recording (same title and artist, or the same but for a tag like \"Remastered\" or \"Radio Edit\"), keeping the first")
+	normalizeSpec := fs.String("normalize", "", "Preprocessing steps to run before sorting, comma-separated: "+normalizeHelp()+"; all runs every step and -name skips one (all,-dedup)")
+	artistGap := fs.Int("artist-gap", 0, "Keep tracks by one artist at least this many positions apart (0 or 1 = no rule)")
+	albumGap := fs.Int("album-gap", 3, "Prefer tracks from one album (the album column) at least this many positions apart, where the mix allows (0 or 1 = off)")
+	preparedWeight := fs.Float64("prepared-weight", 1, "How hard to prefer tracks with cues set (the cues column, or Rekordbox and Serato cues) as opener, closer, and peak, against the mix score (0 = off)")
+	keyMemory := fs.Float64("key-memory", 0, "How hard to steer the set toward keys it has played less so far, against the mix score, so a long set doesn't settle into a few keys (0 = off; try 1)")
+	strictCamelot := fs.Bool("strict-camelot", false, "Forbid diagonal key moves (a number step with a mode change, such as 8A to 9B) instead of only discouraging them")
 	seedFlag := fs.Int64("seed", 0, "Optional seed for pseudo-random decisions (defaults to time-based)")
+	deterministic := fs.Bool("deterministic", false, "Without --seed, derive the seed from the input's contents and options so reruns match")
 	timeout := fs.Duration("timeout", 0, "Optional timeout for processing (e.g. 30s)")
+	budgetSpec := fs.String("budget", "", "Time limit for the sort by strategy, e.g. anneal=20s; each --candidates sort gets its own, within --timeout")
 	scoreOnly := fs.Bool("score", false, "Score the transitions in the input file (no sorting)")
 	scoreVerbose := fs.Bool("score-verbose", false, "Include detailed scoring breakdown (implies --score)")
+	segments := fs.Int("segments", strategy.DefaultSegments, "Break the --score report down into this many stretches of the set (0 = off)")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none (from BPM, genre, loudness, danceability)")
+	checkTags := fs.String("check-tags", "", "Look for energy and BPM tags that don't fit their track's tempo and genre: flag lists them, fix also sorts with the expected value (default off)")
+	checkGenres := fs.Bool("check-genres", false, "List tracks whose BPM and energy look implausible for their genre (e.g. 78 BPM, energy 12 techno) for review")
+	noCache := fs.Bool("no-cache", false, "Parse the input and sort afresh instead of using the parsed-library and result cache")
+	decisionLogPath := fs.String("decision-log", "", "Write the default planner's decisions as JSON lines to this file")
+	fixBefore := fs.Int("fix-before", 0, "Keep positions before N as they are and re-optimize from N on (1-based)")
+	fixAfter := fs.Int("fix-after", 0, "Keep positions after M as they are and re-optimize up to M (1-based)")
+	var placeSpecs stringsFlag
+	fs.Var(&placeSpecs, "place", "Placement rule FILTER@WINDOW, e.g. 'tag:singalong@last:60m' (repeatable)")
+	var poolSpecs stringsFlag
+	fs.Var(&poolSpecs, "pool", "Draw a part of the set from the tracks a filter matches, ROLE=FILTER with ROLE open, peak, or close, e.g. 'close=tag:closer'; sized from the set's length and --arc (repeatable)")
+	var noResetIn stringsFlag
+	fs.Var(&noResetIn, "no-reset-in", "Window with no energy resets, e.g. 'first:20%' (repeatable)")
+	openTrack := fs.String("open", "", "Open the set with this track, as \"Title|Artist\" (or just \"Title\")")
+	closeTrack := fs.String("close", "", "Close the set with this track, as \"Title|Artist\"")
+	var pinSpecs stringsFlag
+	fs.Var(&pinSpecs, "pin", "Keep a track in the set even with --limit, as \"Title|Artist\"; add @N to fix it at position N (@-1 = last), or @N.., @..N, or @N..M to keep it within a range (repeatable)")
+	lockMatching := fs.String("lock-matching", "", "Keep the tracks matching this filter (as well as any marked in a locked column) in their input order, with other tracks played between them, e.g. 'tag:backbone'")
+	minNew := fs.Int("min-new", 0, "Include at least N new tracks (see --new-weeks), spread through the set")
+	newWeeks := fs.Int("new-weeks", 4, "A track is new if added within this many weeks (or tagged \"new\")")
+	resetGap := fs.Int("reset-gap", 0, "Fewest tracks between energy resets (0 = no limit)")
+	excursions := fs.Int("excursions", 0, "Have the default strategy play a relative-mode excursion (8A→8B→9B→9A) about every N tracks (0 = off)")
+	resetOnWrap := fs.Bool("reset-on-wrap", false, "Allow energy resets only where the key wheel wraps past 12")
+	energySwing := fs.String("energy-swing", "", "How much the set's energy level should swing: flat (a lounge set holding one level), moderate, dramatic (peaks and valleys), or a number of energy points")
+	arcName := fs.String("arc", "", "Shape the whole set's energy: "+arcNames()+" (default: the strategy's own, such as default's repeating cycles)")
+	varietyFrom := fs.String("variety-from", "", "A previous set (CSV, JSON, or setlist) to differ from: avoid its transitions, opener, and closer")
+	varietyWeight := fs.Float64("variety-weight", 1, "How hard --variety-from pushes away from the previous set, against the mix score (1 = about one transition's cost per repeat)")
+	noStyle := fs.Bool("no-style", false, "Don't lean toward your mixing style (see magicmix style)")
+	humanFeel := fs.String("human-feel", "", "Add a little seeded imperfection to the finished set: subtle, natural, or loose (default off)")
+	candidates := fs.Int("candidates", 1, "Sort this many times with different seeds and keep the ordering the evaluate rubric scores best")
+	tempoMatchName := fs.String("tempo-match", "direct", "Tempo relationships the default strategy and the evaluate rubric treat as close: direct, half-double (87 with 174), or three-four (also 96 with 128)")
+	tieBreakName := fs.String("tie-break", "random", "How the default strategy chooses between equally good next tracks: random, popular, outro (longer outro), or older (earlier release)")
+	configPath := fs.String("config", "", "JSON file tuning the default strategy's weights, cycle lengths, and variety thresholds")
+	profileName := fs.String("profile", "", "Apply a named profile from profiles.json in the config directory; flags given here override it")
+	workspaceRef := fs.String("workspace", "", "Sort with a workspace's library, rules, tuning, and profile (see magicmix workspace), writing the set into it; a name, a directory, or a .zip of one. Flags given here override it")
+	explain := fs.Bool("explain", false, "Print why the default strategy placed each track: key move, energy move, and energy-cycle position")
+	explainColumn := fs.Bool("explain-column", false, "Also write those reasons as a Why column in CSV output (implies --explain)")
+	copyTo := fs.String("copy-to", "", "Also put the set's audio files in this folder, numbered in set order (\"01 - Artist - Title.mp3\"), for a USB stick that plays in order on any gear")
+	copyModeName := fs.String("copy-mode", copyModeCopy, "How --copy-to puts the files there: copy, link (symlinks), or move")
+	musicDir := fs.String("music-dir", "", "Folder of audio files for --copy-to: relative locations resolve against it, and tracks with no location are found in it as \"Artist - Title\" or \"Title\"")
+	wildcards := fs.Int("wildcards", 0, "Keep this many slots of the set for surprising picks (another genre, another era, a favorite) that still mix with their neighbors, marked in the output (0 = off)")
+	alternatives := fs.Int("alternatives", 0, "List this many next-best tracks for each transition of the set, with their scores, to swap in during rehearsal (0 = off)")
+	bpmMin := fs.Float64("bpm-min", 0, "Leave out tracks slower than this BPM (0 = no bound)")
+	bpmMax := fs.Float64("bpm-max", 0, "Leave out tracks faster than this BPM (0 = no bound)")
+	energyMin := fs.Int("energy-min", 0, "Leave out tracks with energy below this")
+	energyMax := fs.Int("energy-max", 100, "Leave out tracks with energy above this")
+	keysFilter := fs.String("keys", "", "Keep only tracks in these keys, comma-separated, e.g. 5A,6A,5B")
+	var excludeArtists stringsFlag
+	fs.Var(&excludeArtists, "exclude-artist", "Leave out tracks with a credited artist containing this, ignoring case and accents (repeatable)")
+	excludeColors := fs.String("exclude-color", "", "Leave out tracks with these color labels from the DJ software, comma-separated, e.g. red")
+	minPriority := fs.Int("min-priority", 0, "Leave out tracks rated below this priority (1-5, a Rekordbox rating's stars); unrated tracks stay")
+	broadcastSafe := fs.Bool("broadcast-safe", false, "Leave out tracks whose license column doesn't clear them for broadcast (cleared, licensed, yes); tracks with no license are left out too")
+	region := fs.String("region", "", "Leave out tracks whose regions column doesn't clear them in this territory, e.g. US; tracks with no regions stay")
+	colorPriority := fs.String("color-priority", "", "Give tracks with these color labels a priority, e.g. green=5,yellow=2 (1 = filler, 5 = favorite)")
+	sets := fs.Int("sets", 0, "Split the library into this many sets of tracks that mix well together, and order and write each (0 = one set)")
+	setSizeSpec := fs.String("set-size", "", "Split the library into sets of about this size: a track count (20) or a length (60m)")
+	annealBudget := fs.String("anneal-budget", "", "Search budget for the anneal strategy: iterations (e.g. 200000) or a duration (e.g. 20s)")
+	checkpointPath := fs.String("checkpoint", "", "Save the anneal strategy's progress to this file as it runs, to continue with --resume if interrupted")
+	auditDeterminism := fs.Bool("audit-determinism", false, "Run every sort twice from the seed and fail unless both give the same set")
+	summaryFormat := fs.String("summary-format", "text", "How to report the finished run: text, the lines printed as it goes, or json, one object on standard output (counts, score, warnings, output paths, timing) with those lines moved to standard error")
+	verbose := fs.Bool("verbose", false, "Show the sort's progress on standard error as it runs; Ctrl-C then stops it and writes the best set so far")
+	resumePath := fs.String("resume", "", "Continue an anneal run from this checkpoint file, checkpointing to it unless --checkpoint names another")
 
 	fs.Usage = func() {
 		w := fs.Output()
@@ -51,103 +185,875 @@ func run(ctx context.Context, args []string) error {
 	if err := fs.Parse(args); err != nil {
 		return err
 	}
+	var ws config.Workspace
+	if *workspaceRef != "" {
+		var err error
+		if ws, err = applyWorkspace(fs, *workspaceRef); err != nil {
+			return err
+		}
+	}
+	if *profileName != "" {
+		if err := applyProfile(fs, *profileName); err != nil {
+			return err
+		}
+	}
+	if *workspaceRef != "" {
+		reserved, err := workspaceOutput(fs, ws)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if retErr == nil {
+				if _, err := ws.PruneOutputs(); err != nil {
+					retErr = fmt.Errorf("workspace %s: %w", ws.Name, err)
+				}
+				return
+			}
+			// A run that failed leaves no set behind: drop the file it reserved, if
+			// nothing was written to it.
+			if info, err := os.Stat(reserved); reserved != "" && err == nil && info.Size() == 0 {
+				_ = os.Remove(reserved)
+			}
+		}()
+	}
 
 	if *listStrategies {
-		for _, name := range strategy.Names() {
-			fmt.Println(name)
-		}
+		printStrategies(os.Stdout)
 		return nil
 	}
 
-	if *inputPath == "" {
+	if len(inputValues) == 0 {
 		fs.Usage()
 		return errors.New("input path is required")
 	}
+	inputs, err := parseInputSpecs(inputValues)
+	if err != nil {
+		return err
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	encoding, err := csvio.ParseEncoding(*encodingName)
+	if err != nil {
+		return fmt.Errorf("--encoding: %w", err)
+	}
+	inFormat := libraryFormat{name: inputName, energyField: *energyField, encoding: encoding}
+	inputPath := inputs[0].path
+	resolvedOutput := resolvedOutputPath(*outputPath, inputPath)
+	outFormat, err := outputFormat(*formatName, resolvedOutput)
+	if err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		resolvedOutput = withFormatExt(resolvedOutput, outFormat)
+	}
+	if slices.Contains(inputPaths(inputs), playlistio.Stdio) {
+		// Read standard input up front: --deterministic hashes it before it is loaded.
+		data, err := playlistio.ReadAll(ctx, playlistio.Stdio)
+		if err != nil {
+			return err
+		}
+		ctx = playlistio.WithStdin(ctx, data)
+	}
 
 	if *limit < 0 {
 		return errors.New("limit must be non-negative")
 	}
+	if *targetDuration < 0 {
+		return errors.New("target-duration must be non-negative")
+	}
+	var subset *strategy.SubsetObjective
+	if *subsetName != "" {
+		o, err := strategy.ParseSubsetObjective(*subsetName)
+		if err != nil {
+			return fmt.Errorf("--subset: %w", err)
+		}
+		if *limit == 0 && *targetDuration == 0 {
+			return errors.New("--subset chooses what --limit or --target-duration keeps; give one of them")
+		}
+		if len(inputs) > 1 {
+			return errors.New("--subset takes a single input; several inputs are blended by their weights")
+		}
+		subset = &o
+	}
+	if *resetGap < 0 {
+		return errors.New("reset-gap must be non-negative")
+	}
+	if *artistGap < 0 || *albumGap < 0 {
+		return errors.New("artist-gap and album-gap must be non-negative")
+	}
+	if *preparedWeight < 0 {
+		return errors.New("--prepared-weight must be non-negative")
+	}
+	if *keyMemory < 0 {
+		return errors.New("--key-memory must be non-negative")
+	}
+	if *excursions < 0 {
+		return errors.New("excursions must be non-negative")
+	}
+	if *minNew < 0 || *newWeeks < 0 {
+		return errors.New("min-new and new-weeks must be non-negative")
+	}
+
+	if *fixBefore < 0 || *fixAfter < 0 {
+		return errors.New("fix-before and fix-after must be non-negative")
+	}
+	windowed := *fixBefore > 0 || *fixAfter > 0
+	if windowed && (*limit > 0 || *targetDuration > 0) {
+		return errors.New("limit and target-duration cannot be combined with fix-before or fix-after")
+	}
+	if windowed && len(inputs) > 1 {
+		return errors.New("fix-before and fix-after take a single input")
+	}
+	if windowed && (*openTrack != "" || *closeTrack != "" || len(pinSpecs) > 0) {
+		return errors.New("open, close, and pin cannot be combined with fix-before or fix-after")
+	}
+	if *candidates < 1 {
+		return errors.New("candidates must be at least 1")
+	}
+	if *candidates > 1 && (windowed || *decisionLogPath != "") {
+		return errors.New("candidates cannot be combined with fix-before, fix-after, or decision-log")
+	}
+	checkpointing := *checkpointPath != "" || *resumePath != ""
+	if checkpointing && (*candidates > 1 || windowed) {
+		return errors.New("checkpoint and resume cannot be combined with candidates, fix-before, or fix-after")
+	}
+
+	if *checkTags != "" && *checkTags != "flag" && *checkTags != "fix" {
+		return fmt.Errorf("--check-tags %q: want flag or fix", *checkTags)
+	}
+
+	filters, err := newLoadFilter(*bpmMin, *bpmMax, *energyMin, *energyMax, *keysFilter, excludeArtists, *excludeColors, *minPriority,
+		*broadcastSafe, *region)
+	if err != nil {
+		return err
+	}
+	colorPriorities, err := parseColorPriorities(*colorPriority)
+	if err != nil {
+		return err
+	}
+	normalization, err := parseNormalize(*normalizeSpec, *dedup)
+	if err != nil {
+		return err
+	}
+	budgets, err := parseBudgets(*budgetSpec)
+	if err != nil {
+		return err
+	}
+	if windowed && len(budgets) > 0 {
+		return errors.New("budget cannot be combined with fix-before or fix-after")
+	}
+	if windowed && (filters.active() || len(colorPriorities) > 0 || len(normalization) > 0) {
+		return errors.New("bpm-min, bpm-max, energy-min, energy-max, keys, exclude-artist, exclude-color, min-priority, " +
+			"broadcast-safe, region, color-priority, normalize, and dedup cannot be combined with fix-before or fix-after")
+	}
+
+	if *sets < 0 {
+		return errors.New("sets must be non-negative")
+	}
+	var size setSize
+	if *setSizeSpec != "" {
+		if *sets > 0 {
+			return errors.New("give sets or set-size, not both")
+		}
+		if size, err = parseSetSize("set-size", *setSizeSpec); err != nil {
+			return err
+		}
+	}
+	partitioned := *sets > 0 || *setSizeSpec != ""
+	if partitioned && (windowed || *limit > 0 || *targetDuration > 0 || *openTrack != "" || *closeTrack != "" ||
+		len(pinSpecs) > 0 || *minNew > 0 || *candidates > 1 || *decisionLogPath != "" || checkpointing || len(budgets) > 0) {
+		return errors.New("sets and set-size cannot be combined with fix-before, fix-after, limit, target-duration, open, close, pin, min-new, candidates, decision-log, checkpoint, resume, or budget")
+	}
+	if *alternatives < 0 {
+		return errors.New("alternatives must be non-negative")
+	}
+	if *alternatives > 0 && (partitioned || windowed) {
+		return errors.New("alternatives cannot be combined with sets, set-size, fix-before, or fix-after")
+	}
+	if *wildcards < 0 {
+		return errors.New("wildcards must be non-negative")
+	}
+	if *wildcards > 0 && (partitioned || windowed) {
+		return errors.New("wildcards cannot be combined with sets, set-size, fix-before, or fix-after")
+	}
+	if *wildcards > 0 && *limit > 0 && *wildcards >= *limit {
+		return errors.New("wildcards must leave room in the --limit for the set itself")
+	}
+	if len(poolSpecs) > 0 && (partitioned || windowed) {
+		return errors.New("pool cannot be combined with sets, set-size, fix-before, or fix-after")
+	}
+	var staged *strategy.StagedSorter
+	if *stagesSpec != "" {
+		if *strategyName != "default" {
+			return errors.New("give strategy or stages, not both")
+		}
+		if partitioned || windowed || *limit > 0 || *targetDuration > 0 || len(poolSpecs) > 0 {
+			return errors.New("stages cannot be combined with sets, set-size, fix-before, fix-after, limit, target-duration, or pool")
+		}
+		if staged, err = parseStages(*stagesSpec); err != nil {
+			return err
+		}
+	}
+	var folder *folderExport
+	if *copyTo != "" {
+		if partitioned || windowed {
+			return errors.New("copy-to cannot be combined with sets, set-size, fix-before, or fix-after")
+		}
+		mode, err := parseCopyMode(*copyModeName)
+		if err != nil {
+			return fmt.Errorf("--copy-mode: %w", err)
+		}
+		folder = &folderExport{dir: *copyTo, mode: mode, musicDir: *musicDir}
+	}
+	var summary *strategy.Summary
+	switch *summaryFormat {
+	case "text":
+	case "json":
+		if resolvedOutput == playlistio.Stdio {
+			return errors.New("--summary-format json prints the summary on standard output; give --output a file path")
+		}
+		if partitioned || windowed || *scoreOnly || *scoreVerbose {
+			return errors.New("--summary-format json reports one sorted set; it can't be combined with sets, set-size, fix-before, fix-after, or score")
+		}
+		summary = &strategy.Summary{Strategy: cmp.Or(*stagesSpec, *strategyName), Warnings: []string{}, Notes: []string{}, Outputs: []string{}}
+		defer reportSummary(summary, os.Stdout, time.Now(), &retErr)
+		defer redirectStdout(os.Stderr)()
+	default:
+		return fmt.Errorf("--summary-format %q: want text or json", *summaryFormat)
+	}
+	finish := func(set setResult, strategyName string) error {
+		if *checkGenres {
+			set.Warnings = append(slices.Clip(set.Warnings), set.GenreFit...)
+		}
+		if err := writeSet(ctx, set, resolvedOutput, outFormat, strategyName, *targetDuration, summary); err != nil {
+			return err
+		}
+		if folder != nil {
+			if summary != nil {
+				summary.Outputs = append(summary.Outputs, folder.dir)
+			}
+			return folder.write(set.Ordered)
+		}
+		return nil
+	}
+	if partitioned && resolvedOutput == playlistio.Stdio {
+		return errors.New("sets and set-size write one file per set; give --output a file path")
+	}
 
 	// Handle scoring mode
 	isScoring := *scoreOnly || *scoreVerbose
 	if isScoring {
-		return runScoring(*inputPath, *scoreVerbose)
+		if len(inputs) > 1 {
+			return errors.New("scoring takes a single input")
+		}
+		if *segments < 0 {
+			return errors.New("segments must be non-negative")
+		}
+		return runScoring(ctx, inputPath, inFormat, *scoreVerbose, *segments, *noCache, *inferEnergy)
+	}
+
+	if resolvedOutput == playlistio.Stdio {
+		// The set goes to standard output, so everything else printed goes to standard
+		// error and the set can be piped on.
+		ctx = playlistio.WithStdout(ctx, os.Stdout)
+		defer redirectStdout(os.Stderr)()
 	}
 
 	ctx, cancel := maybeWithTimeout(ctx, *timeout)
 	if cancel != nil {
 		defer cancel()
 	}
+	if checkpointing || *verbose {
+		// Ctrl-C stops the search like --timeout does, so the best set so far (and the
+		// last checkpoint) is written.
+		var stop context.CancelFunc
+		ctx, stop = signal.NotifyContext(ctx, os.Interrupt)
+		defer stop()
+	}
 
 	if *limit > 0 {
 		ctx = strategy.WithLimit(ctx, *limit)
 	}
+	feel, err := strategy.ParseHumanFeel(*humanFeel)
+	if err != nil {
+		return fmt.Errorf("--human-feel: %w", err)
+	}
+	ctx = strategy.WithHumanFeel(ctx, feel)
+	if *excursions > 0 {
+		ctx = strategy.WithExcursions(ctx, *excursions)
+	}
+	var arc *strategy.Arc
+	if *arcName != "" {
+		a, err := strategy.ParseArc(*arcName)
+		if err != nil {
+			return fmt.Errorf("--arc: %w", err)
+		}
+		arc = &a
+		ctx = strategy.WithArc(ctx, a)
+	}
+	var swing *strategy.EnergySwing
+	if *energySwing != "" {
+		s, err := strategy.ParseEnergySwing(*energySwing)
+		if err != nil {
+			return fmt.Errorf("--energy-swing: %w", err)
+		}
+		swing = &s
+		ctx = strategy.WithEnergySwing(ctx, s)
+	}
+	tempoMatch, err := track.ParseTempoMatch(*tempoMatchName)
+	if err != nil {
+		return fmt.Errorf("--tempo-match: %w", err)
+	}
+	ctx = strategy.WithTempoMatch(ctx, tempoMatch)
+	tieBreak, err := strategy.ParseTieBreak(*tieBreakName)
+	if err != nil {
+		return fmt.Errorf("--tie-break: %w", err)
+	}
+	ctx = strategy.WithTieBreak(ctx, tieBreak)
+	if *explain || *explainColumn {
+		ctx = strategy.WithExplain(ctx)
+	}
+	if *configPath != "" {
+		tuning, err := readTuning(*configPath)
+		if err != nil {
+			return err
+		}
+		ctx = strategy.WithTuning(ctx, tuning)
+	}
+	if *annealBudget != "" {
+		budget, err := parseAnnealBudget(*annealBudget)
+		if err != nil {
+			return err
+		}
+		ctx = strategy.WithAnnealBudget(ctx, budget)
+	}
+
+	var resume *strategy.Checkpoint
+	if *resumePath != "" {
+		if resume, err = readCheckpoint(*resumePath); err != nil {
+			return err
+		}
+	}
 
 	effectiveSeed := *seedFlag
-	if effectiveSeed == 0 {
+	seedSource := ""
+	switch {
+	case effectiveSeed != 0:
+	case resume != nil:
+		// The checkpoint's seed carries on its search; report it as the run's.
+		effectiveSeed, seedSource = resume.Seed, " (from the checkpoint)"
+	case *deterministic:
+		seed, err := inputSeed(ctx, inputPaths(inputs), orderingFlags(fs)...)
+		if err != nil {
+			return err
+		}
+		effectiveSeed, seedSource = seed, " (derived from the input)"
+	default:
 		effectiveSeed = time.Now().UnixNano()
 	}
 	ctx = strategy.WithSeed(ctx, effectiveSeed)
+	if *auditDeterminism {
+		ctx = strategy.WithDeterminismAudit(ctx)
+	}
+
+	genres, err := loadGenres()
+	if err != nil {
+		return err
+	}
+	ctx = strategy.WithGenres(ctx, genres)
+	for _, spec := range placeSpecs {
+		rule, err := parsePlacement(spec, genres)
+		if err != nil {
+			return err
+		}
+		ctx = strategy.WithPlacement(ctx, rule)
+	}
+	var pools []strategy.Pool
+	for _, spec := range poolSpecs {
+		pool, err := parsePool(spec, genres)
+		if err != nil {
+			return err
+		}
+		pools = append(pools, pool)
+	}
+	resets := strategy.ResetRule{MinGap: *resetGap, OnWrap: *resetOnWrap}
+	for _, spec := range noResetIn {
+		w, err := strategy.ParseWindow(spec)
+		if err != nil {
+			return fmt.Errorf("--no-reset-in: %w", err)
+		}
+		resets.Forbid = append(resets.Forbid, w)
+	}
+	ctx = strategy.WithResets(ctx, resets)
+	var previous []track.Track
+	if *varietyFrom != "" {
+		if *varietyWeight < 0 {
+			return errors.New("--variety-weight must be non-negative")
+		}
+		set, err := loadLibrary(ctx, *varietyFrom, *noCache)
+		if err != nil {
+			return fmt.Errorf("--variety-from: %w", err)
+		}
+		previous = set.Tracks
+		if *varietyWeight > 0 {
+			ctx = strategy.WithVariety(ctx, strategy.Variety{Previous: previous, Weight: *varietyWeight})
+		}
+	}
+	if !*noStyle {
+		style, path, ok, err := loadStyle()
+		if err != nil {
+			return err
+		}
+		if ok && !style.Empty() {
+			fmt.Printf("Leaning toward your mixing style from %s: %s\n", path, style.Summary())
+			ctx = strategy.WithStyle(ctx, style)
+		}
+	}
+
+	var sorter strategy.Sorter = staged
+	if staged == nil {
+		if sorter, err = strategy.Get(*strategyName); err != nil {
+			return err
+		}
+	}
+
+	if outFormat == playlistio.Setlist {
+		manifest, err := runManifest(ctx, fs, inputPaths(inputs), sorter.Name(), effectiveSeed)
+		if err != nil {
+			return err
+		}
+		ctx = playlistio.WithManifest(ctx, manifest)
+	}
 
-	sorter, err := strategy.Get(*strategyName)
+	// A run with a fixed seed is repeatable, so an identical earlier run's result can
+	// stand in for sorting again. The decision log, checkpoints, and the determinism audit
+	// need the sort to run.
+	var results *libcache.Cache // nil leaves the result uncached
+	resultID := ""
+	if !*noCache && !windowed && !partitioned && *decisionLogPath == "" && !checkpointing && !*auditDeterminism && (*seedFlag != 0 || *deterministic) {
+		if results, _ = libcache.Default(); results != nil {
+			var extra []string
+			if *minNew > 0 {
+				extra = append(extra, time.Now().Format(time.DateOnly)) // what counts as new changes daily
+			}
+			if resultID, err = resultKey(ctx, fs, inputPaths(inputs), effectiveSeed, extra...); err != nil {
+				return err
+			}
+			var set setResult
+			if results.LoadResult(resultID, &set) {
+				fmt.Printf("Using seed %d%s\n", set.Seed, set.SeedSource)
+				fmt.Println("Reused the result of an identical earlier run (use --no-cache to sort again)")
+				for _, note := range set.Notes {
+					fmt.Println(note)
+				}
+				if *checkGenres {
+					printGenreFit(set.GenreFit)
+				}
+				return finish(set, sorter.Name())
+			}
+		}
+	}
+
+	if *decisionLogPath != "" {
+		if sorter.Name() != "default" {
+			fmt.Printf("Note: --decision-log records the default strategy's planner; %s has nothing to log\n", sorter.Name())
+		}
+		log, err := openDecisionLog(*decisionLogPath)
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if cerr := log.Close(); cerr != nil && retErr == nil {
+				retErr = cerr
+			}
+		}()
+		ctx = strategy.WithDecisionRecorder(ctx, log.record)
+	}
+
+	playlist, sources, err := loadInputs(ctx, inputs, *noCache, inFormat)
 	if err != nil {
 		return err
 	}
 
-	playlist, err := csvio.LoadPlaylist(ctx, *inputPath)
+	warnings := playlist.Warnings
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
 	if err != nil {
 		return err
 	}
+	warnings = append(warnings, energyWarnings...)
+	// The result holds the genre check whether or not --check-genres asks for it, so the
+	// flag needn't split the result cache; finish adds it to the warnings.
+	fit := genreFit(ctx, playlist.Tracks)
+	var fitWarnings []string
+	if *checkGenres {
+		printGenreFit(fit)
+		fitWarnings = fit
+	}
+	if *checkTags != "" {
+		var tagWarnings []string
+		playlist.Tracks, tagWarnings = checkSuspectTags(ctx, playlist.Tracks, *checkTags)
+		warnings = append(warnings, tagWarnings...)
+	}
+
+	tracks := playlist.Tracks
+	if len(inputs) > 1 {
+		tracks, sources = dedupeInputs(tracks, sources, inputs)
+	}
+	if windowed {
+		fmt.Printf("Using seed %d%s\n", effectiveSeed, seedSource)
+		return runWindow(ctx, sorter, playlist, *fixBefore, *fixAfter, resolvedOutput, outFormat, append(warnings, fitWarnings...))
+	}
+	if len(colorPriorities) > 0 {
+		if n := applyColorPriorities(tracks, colorPriorities); n > 0 {
+			fmt.Printf("Set the priority of %d track(s) from their color (--color-priority)\n", n)
+		}
+	}
+	var drops dropLog
+	tracks, sources = runNormalize(normalization, tracks, sources, &drops)
+	tracks, sources = filters.apply(tracks, sources, &drops)
+	if len(tracks) == 0 {
+		return errors.New("the filters left no tracks to sort")
+	}
+	if *artistGap > 1 {
+		ctx = strategy.WithSeparation(ctx, strategy.ArtistSeparation(*artistGap))
+	}
+	if *albumGap > 1 {
+		ctx = strategy.WithSeparation(ctx, strategy.AlbumSeparation(*albumGap))
+	}
+	if slices.ContainsFunc(tracks, func(t track.Track) bool { return t.Cues != nil }) {
+		ctx = strategy.WithPrepared(ctx, *preparedWeight)
+	}
+	ctx = strategy.WithKeyMemory(ctx, *keyMemory)
+	if *strictCamelot {
+		ctx = strategy.WithStrictCamelot(ctx)
+	}
+	if *keepVersions {
+		ctx = strategy.WithSeparation(ctx, strategy.FamilySeparation(strategy.DefaultFamilyGap))
+	} else {
+		kept, alternates := strategy.OnePerFamilyIndexes(tracks)
+		if len(alternates) > 0 {
+			fmt.Printf("Skipped %d alternate version(s) of songs already in the set (use --keep-versions to include them):\n",
+				len(alternates))
+			for _, i := range alternates {
+				fmt.Printf("  - %q by %s\n", tracks[i].Title, tracks[i].Artist)
+				drops.add(tracks[i], "alternate version of a song already in the set (--keep-versions)")
+			}
+		}
+		keptTracks, keptSources := make([]track.Track, len(kept)), make([]int, len(kept))
+		for k, i := range kept {
+			keptTracks[k], keptSources[k] = tracks[i], sources[i]
+		}
+		tracks, sources = keptTracks, keptSources
+	}
 
-	result, err := strategy.Sort(ctx, sorter, playlist.Tracks)
+	if partitioned {
+		fmt.Printf("Using seed %d%s\n", effectiveSeed, seedSource)
+		n := *sets
+		if n == 0 {
+			n = size.count(tracks)
+		}
+		return runSets(ctx, sorter, playlist, tracks, n, size.length > 0, drops, resolvedOutput, outFormat, append(warnings, fitWarnings...))
+	}
+
+	pins, must, err := anchorRules(*openTrack, *closeTrack, pinSpecs, tracks)
 	if err != nil {
 		return err
 	}
+	ctx = strategy.WithMustInclude(strategy.WithPins(ctx, pins...), must...)
+	if ctx, err = withLockedOrder(ctx, tracks, *lockMatching, genres); err != nil {
+		return err
+	}
+	if len(pools) > 0 {
+		if ctx, err = withPools(ctx, pools, tracks, *limit, *targetDuration, arc); err != nil {
+			return err
+		}
+	}
+
+	pool := tracks
+	if len(inputs) > 1 {
+		if tracks, err = blendInputs(ctx, tracks, sources, inputs, *limit); err != nil {
+			return err
+		}
+		drops.diff(pool, tracks, "not drawn when blending inputs to --limit")
+	}
+	if *targetDuration > 0 {
+		if missing := countMissingLengths(tracks); missing > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d track(s) have no length; --target-duration counts them as the average length", missing))
+		}
+	}
+	if subset != nil {
+		n := 0
+		if *limit > 0 {
+			n = *limit - *wildcards
+		}
+		picked, err := subset.Select(ctx, tracks, n, targetDuration.Seconds(), strategy.Required(ctx))
+		if err != nil {
+			return err
+		}
+		chosen := make([]track.Track, len(picked))
+		for k, i := range picked {
+			chosen[k] = tracks[i]
+		}
+		if len(chosen) < len(tracks) {
+			fmt.Printf("Kept %d of %d track(s) by --subset %s: %s\n", len(chosen), len(tracks), subset.Name, subset.Description)
+		}
+		drops.diff(tracks, chosen, fmt.Sprintf("not chosen by --subset %s", subset.Name))
+		tracks = chosen
+	} else if *targetDuration > 0 {
+		picked, err := strategy.FitRuntime(ctx, tracks, targetDuration.Seconds(), strategy.Required(ctx))
+		if err != nil {
+			return err
+		}
+		fitted := make([]track.Track, len(picked))
+		for k, i := range picked {
+			fitted[k] = tracks[i]
+		}
+		drops.diff(tracks, fitted, fmt.Sprintf("over --target-duration %s", *targetDuration))
+		tracks = fitted
+	}
+
+	isNew := newMusicMatcher(*newWeeks, time.Now())
+	if *minNew > 0 {
+		ctx = withNewSpread(ctx, tracks, isNew, *minNew, *limit)
+	}
+
+	warnings = append(warnings, checkInventory(ctx, tracks)...)
+
+	// Checkpoints and --verbose progress follow the main sort (or the candidates' sorts)
+	// only; the re-sorts after it have other tracks.
+	sortCtx := ctx
+	if checkpointing {
+		if sorter.Name() != "anneal" {
+			fmt.Printf("Note: --checkpoint and --resume apply to the anneal strategy; %s runs start to finish\n", sorter.Name())
+		}
+		file := &checkpointFile{path: *checkpointPath}
+		if file.path == "" {
+			file.path = *resumePath
+		}
+		defer func() {
+			if err := file.Err(); err != nil && retErr == nil {
+				retErr = err
+			}
+		}()
+		sortCtx = strategy.WithCheckpoints(sortCtx, strategy.Checkpoints{Save: file.save, Resume: resume})
+	}
+	var progress *progressLine
+	if *verbose {
+		progress = newProgressLine(os.Stderr)
+		sortCtx = strategy.WithProgress(sortCtx, progress.report)
+	}
 
-	fmt.Printf("Using seed %d\n", effectiveSeed)
+	if staged != nil {
+		printStages(staged, tracks)
+	}
+	budget := budgets[sorter.Name()]
+	for _, name := range slices.Sorted(maps.Keys(budgets)) {
+		if name != sorter.Name() {
+			warnings = append(warnings, fmt.Sprintf("--budget gives %s a limit, but %s is sorting", name, sorter.Name()))
+		}
+	}
+	var result strategy.Result
+	if *candidates > 1 {
+		cands, best, err := sortCandidates(sortCtx, sorter, tracks, candidateSeeds(effectiveSeed, *candidates), budget,
+			eval.Options{TempoMatch: tempoMatch})
+		if progress != nil {
+			progress.finish()
+		}
+		if err != nil {
+			return err
+		}
+		printCandidates(cands, best)
+		result, effectiveSeed = cands[best].result, cands[best].seed
+		if best > 0 {
+			seedSource = fmt.Sprintf(" (candidate %d of %d)", best+1, *candidates)
+		}
+		ctx = strategy.WithSeed(ctx, effectiveSeed)
+	} else {
+		budgetCtx, cancel := maybeWithTimeout(sortCtx, budget)
+		result, err = strategy.Sort(budgetCtx, sorter, tracks)
+		if cancel != nil {
+			cancel()
+		}
+		if progress != nil {
+			progress.finish()
+		}
+		if err != nil {
+			return err
+		}
+	}
+	warnings = append(warnings, result.Warnings...)
+	limitReason := fmt.Sprintf("beyond --limit %d", *limit)
+	if !result.Partial {
+		// Strategies honour the limit themselves; the tracks they left out are the
+		// ones it cut.
+		drops.diff(tracks, result.Ordered, limitReason)
+	}
 
-	resolvedOutput := *outputPath
-	if resolvedOutput == "" {
-		resolvedOutput = deriveOutputPath(*inputPath)
+	fmt.Printf("Using seed %d%s\n", effectiveSeed, seedSource)
+	if *auditDeterminism {
+		fmt.Println("Determinism audit passed: every sort came out the same twice from its seed")
 	}
 
 	ordered := result.Ordered
+	confidence := result.Confidence
+	notes := result.Notes
+	reasons := reasonsByTrack(result)
 
-	if !*keepAll {
+	// A sort stopped by --timeout is saved as-is: there is no time left to re-sort
+	// after trimming.
+	if !*keepAll && !result.Partial {
 		const maxDropFraction = 0.10
-		kept, dropped := strategy.TrimOutliers(ordered, maxDropFraction)
+		kept, dropped := strategy.TrimOutliers(ordered, maxDropFraction, strategy.Required(ctx))
 		if len(dropped) > 0 {
 			// Re-optimize the kept tracks so the final sequence is clean.
 			if reordered, rerr := strategy.Sort(ctx, sorter, kept); rerr == nil {
-				ordered = reordered.Ordered
+				ordered, confidence, notes = reordered.Ordered, reordered.Confidence, reordered.Notes
+				reasons = reasonsByTrack(reordered)
+				warnings = append(warnings, reordered.Warnings...)
 			} else {
-				ordered = kept
+				ordered, confidence = kept, strategy.PlacementConfidence(kept)
 			}
 			fmt.Printf("Dropped %d of %d track(s) that didn't fit (use --keep-all to force all in):\n",
 				len(dropped), len(dropped)+len(ordered))
 			for _, d := range dropped {
 				fmt.Printf("  - %q by %s (roughness %.2f)\n", d.Track.Title, d.Track.Artist, d.MarginalCost)
+				drops.add(d.Track, fmt.Sprintf("didn't fit the mix (roughness %.2f; --keep-all)", d.MarginalCost))
 			}
 		}
 	}
 
-	if *limit > 0 && *limit < len(ordered) {
-		ordered = ordered[:*limit]
+	for _, note := range notes {
+		fmt.Println(note)
+	}
+
+	// Wildcards take their slots out of the limit.
+	if keep := *limit - *wildcards; *limit > 0 && keep < len(ordered) {
+		full := ordered
+		ordered = strategy.Truncate(ctx, ordered, keep)
+		drops.diff(full, ordered, limitReason)
+		confidence = strategy.PlacementConfidence(ordered)
 		fmt.Printf("Applying limit %d; writing first %d tracks\n", *limit, len(ordered))
 	}
 
-	if err := csvio.SaveInFormat(ctx, resolvedOutput, csvio.Playlist{
-		Header: playlist.Header,
-		CRLF:   playlist.CRLF,
-		Tracks: ordered,
-	}); err != nil {
+	if len(previous) > 0 {
+		notes = append(notes, varietyNote(previous, ordered))
+		fmt.Println(notes[len(notes)-1])
+	}
+	if swing != nil {
+		notes = append(notes, fmt.Sprintf("Energy swing %.1f (target %.1f)", strategy.Swing(ordered), swing.TargetFor(tracks)))
+		fmt.Println(notes[len(notes)-1])
+	}
+
+	if *minNew > 0 && !result.Partial {
+		var quotaWarnings []string
+		before := ordered
+		ordered, confidence, quotaWarnings = meetNewQuota(ctx, sorter, ordered, pool, isNew, *minNew)
+		warnings = append(warnings, quotaWarnings...)
+		drops.diff(before, ordered, "swapped out for a new track (--min-new)")
+	}
+
+	var wild []strategy.Wildcard
+	if *wildcards > 0 && !result.Partial {
+		ordered, wild = strategy.InsertWildcards(ctx, ordered, spareTracks(pool, ordered), *wildcards)
+		confidence = strategy.PlacementConfidence(ordered)
+		if len(wild) < *wildcards {
+			warnings = append(warnings, fmt.Sprintf("only %d wildcard(s) both surprise and mix with their neighbors; --wildcards asked for %d",
+				len(wild), *wildcards))
+		}
+	}
+
+	set := setResult{Header: playlist.Header, CRLF: playlist.CRLF, Encoding: playlist.Encoding, Ordered: ordered, Confidence: confidence,
+		Warnings: warnings, GenreFit: fit, Dropped: drops, Seed: effectiveSeed, SeedSource: seedSource, Notes: notes}
+	if len(wild) > 0 {
+		set.Wildcards = make([]string, len(ordered))
+		for _, w := range wild {
+			set.Wildcards[w.Position] = w.Reason
+		}
+	}
+	if *explainColumn {
+		set.Why = make([]string, len(ordered))
+		for i, t := range ordered {
+			set.Why[i] = reasons[t.ID()]
+		}
+	}
+	if *alternatives > 0 {
+		set.Alternatives = strategy.NextBest(ordered, spareTracks(tracks, ordered), *alternatives)
+	}
+	if result.Partial {
+		set.Unplaced = result.Unplaced
+	} else {
+		results.StoreResult(resultID, set)
+	}
+	return finish(set, sorter.Name())
+}
+
+// runWindow re-optimizes positions fixBefore..fixAfter (1-based, inclusive; 0 leaves
+// that end open) of the input's existing order and writes the whole set. Membership is
+// left alone: no versions are skipped and no outliers dropped, so the fixed positions
+// stay where they were.
+func runWindow(ctx context.Context, sorter strategy.Sorter, playlist csvio.Playlist, fixBefore, fixAfter int,
+	output string, format playlistio.Format, warnings []string) error {
+	tracks := playlist.Tracks
+	from, to := 1, len(tracks)
+	if fixBefore > 0 {
+		from = fixBefore
+	}
+	if fixAfter > 0 {
+		to = fixAfter
+	}
+	if from > to || to > len(tracks) {
+		return fmt.Errorf("cannot re-optimize positions %d-%d of a %d-track set", from, to, len(tracks))
+	}
+
+	result, err := strategy.SortWindow(ctx, sorter, tracks, from-1, to)
+	if err != nil {
 		return err
 	}
+	warnings = append(warnings, result.Warnings...)
+	for _, note := range result.Notes {
+		fmt.Println(note)
+	}
 
-	fmt.Printf("Wrote %d tracks using %s strategy to %s\n", len(ordered), sorter.Name(), resolvedOutput)
+	outputWarnings, err := saveOutput(ctx, output, format, csvio.Playlist{
+		Header:   playlist.Header,
+		CRLF:     playlist.CRLF,
+		Encoding: playlist.Encoding,
+		Tracks:   result.Ordered,
+	})
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, outputWarnings...)
+	fmt.Printf("Re-optimized positions %d-%d using %s strategy; wrote %d tracks to %s\n",
+		from, to, sorter.Name(), len(result.Ordered), output)
+	printCompromises(result.Confidence[from-1 : to])
+	printWarnings(warnings)
 	return nil
 }
 
+// reportSummary prints a --summary-format json summary to w once the run is over,
+// with how long it took since start and the error it ended with, if any. An error
+// printing it becomes the run's error.
+func reportSummary(s *strategy.Summary, w io.Writer, start time.Time, runErr *error) {
+	s.Elapsed = time.Since(start).Seconds()
+	if *runErr != nil {
+		s.Error = (*runErr).Error()
+	}
+	if err := json.NewEncoder(w).Encode(s); err != nil && *runErr == nil {
+		*runErr = fmt.Errorf("write summary: %w", err)
+	}
+}
+
+// redirectStdout points os.Stdout at w, so the status lines printed with fmt.Printf
+// go there, until the returned function restores it.
+func redirectStdout(w *os.File) func() {
+	saved := os.Stdout
+	os.Stdout = w
+	return func() { os.Stdout = saved }
+}
+
 func maybeWithTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
 	if timeout <= 0 {
 		return ctx, nil
@@ -155,12 +1061,17 @@ func maybeWithTimeout(ctx context.Context, timeout time.Duration) (context.Conte
 	return context.WithTimeout(ctx, timeout)
 }
 
-func runScoring(inputPath string, verbose bool) error {
-	ctx := context.Background()
-	tracks, err := csvio.Load(ctx, inputPath)
+func runScoring(ctx context.Context, inputPath string, format libraryFormat, verbose bool, segments int, noCache, inferEnergy bool) error {
+	playlist, err := loadLibraryAs(ctx, inputPath, noCache, format)
+	tracks := playlist.Tracks
 	if err != nil {
 		return fmt.Errorf("failed to read tracks from %s: %w", inputPath, err)
 	}
+	energyWarnings, err := checkEnergy(tracks, inferEnergy)
+	if err != nil {
+		return err
+	}
+	defer printWarnings(append(playlist.Warnings, energyWarnings...))
 
 	if len(tracks) <= 1 {
 		fmt.Printf("File %s contains %d track(s) - no transitions to score\n", inputPath, len(tracks))
@@ -183,6 +1094,9 @@ func runScoring(inputPath string, verbose bool) error {
 	if score.AcousticTotal > 0 {
 		fmt.Printf("  Acousticness:   %8.2f\n", score.AcousticTotal)
 	}
+	if score.PhraseTotal > 0 {
+		fmt.Printf("  Phrase:         %8.2f\n", score.PhraseTotal)
+	}
 
 	c := score.Contour
 	fmt.Printf("\nContour (energy shape): %8.2f\n", score.ContourTotal)
@@ -190,6 +1104,10 @@ func runScoring(inputPath string, verbose bool) error {
 	fmt.Printf("  Build smoothness: %.2f | jitter resets: %.2f | wave-count: %.2f\n",
 		c.SmoothnessPenalty, c.ResetPenalty, c.WaveCountPenalty)
 
+	if segments > 1 {
+		printSegments(strategy.ScoreSegments(tracks, strategy.DefaultWeights, segments))
+	}
+
 	if len(score.Worst) > 0 {
 		limit := 5
 		if verbose {
@@ -200,15 +1118,62 @@ func runScoring(inputPath string, verbose bool) error {
 			if i >= limit || d.Pairwise <= 0 {
 				break
 			}
-			fmt.Printf("  #%d %s (%s) -> %s (%s): %.2f [key %.2f tempo %.2f mood %.2f acoustic %.2f]\n",
+			fmt.Printf("  #%d %s (%s) -> %s (%s): %.2f [key %.2f tempo %.2f mood %.2f acoustic %.2f]%s\n",
 				d.Index+1, truncate(d.FromTitle, 24), d.FromKey, truncate(d.ToTitle, 24), d.ToKey,
-				d.Pairwise, d.Harmonic, d.Tempo, d.Valence, d.Acoustic)
+				d.Pairwise, d.Harmonic, d.Tempo, d.Valence, d.Acoustic, phraseNote(d))
 		}
 	}
 
+	rehearse := maxRehearsals
+	if verbose {
+		rehearse = score.Transitions
+	}
+	if len(strategy.HardestTransitions(strategy.Difficulties(tracks), rehearse)) > 0 {
+		fmt.Println()
+		printRehearsals(tracks, rehearse)
+	}
 	return nil
 }
 
+// printSegments prints where in the set the score's costs fall. Per-transition
+// averages make segments of different lengths comparable.
+func printSegments(segs []strategy.SegmentScore) {
+	if len(segs) == 0 {
+		return
+	}
+	fmt.Printf("\nBy segment (wave count excluded):\n")
+	for _, s := range segs {
+		fmt.Printf("  %-8s tracks %d-%d: %6.2f (%.3f per transition) [key %.2f tempo %.2f contour %.2f]\n",
+			s.Name, s.First+1, s.Last+1, s.Total, s.PerTransition, s.Harmonic, s.Tempo, s.Contour)
+		if d := s.Roughest; d != nil {
+			fmt.Printf("           roughest: #%d %s -> %s (%.2f)\n",
+				d.Index+1, truncate(d.FromTitle, 24), truncate(d.ToTitle, 24), d.Pairwise)
+		}
+	}
+}
+
+// phraseNote describes a transition's phrase structures, e.g. " phrase 16→32 bars
+// (mix on a 32-bar boundary)", or "" when either side is unknown. The boundary is
+// the shortest span where both structures start a phrase together.
+func phraseNote(d strategy.TransitionDetail) string {
+	if d.FromPhrase == 0 || d.ToPhrase == 0 {
+		return ""
+	}
+	if d.FromPhrase == d.ToPhrase {
+		return fmt.Sprintf(" phrase %d bars", d.FromPhrase)
+	}
+	return fmt.Sprintf(" phrase %d→%d bars (mix on a %d-bar boundary)",
+		d.FromPhrase, d.ToPhrase, lcm(d.FromPhrase, d.ToPhrase))
+}
+
+func lcm(a, b int) int {
+	x, y := a, b
+	for y != 0 {
+		x, y = y, x%y
+	}
+	return a / x * b
+}
+
 func truncate(s string, n int) string {
 	r := []rune(s)
 	if len(r) <= n {
@@ -217,14 +1182,167 @@ func truncate(s string, n int) string {
 	return string(r[:n-1]) + "…"
 }
 
+const (
+	// compromiseMargin is how much smoother (in coherence cost) an available alternative
+	// must have been before a placement is reported as a compromise.
+	compromiseMargin = 0.1
+	maxCompromises   = 5
+	// maxRehearsals is how many of the hardest transitions to list for practice.
+	maxRehearsals = 3
+)
+
+// printCompromises lists the placements where a smoother follow-up was passed over,
+// so the user knows which transitions to check by ear.
+func printCompromises(confidence []strategy.Confidence) {
+	forced := strategy.Compromises(confidence, compromiseMargin)
+	if len(forced) == 0 {
+		return
+	}
+	fmt.Printf("Least certain placements (%d; a smoother next track was available):\n", len(forced))
+	for i, c := range forced {
+		if i == maxCompromises {
+			fmt.Printf("  ... and %d more\n", len(forced)-maxCompromises)
+			break
+		}
+		fmt.Printf("  #%d %q (margin %+.2f; %q fit the previous track better)\n",
+			c.Position+1, c.Title, c.Margin, c.RunnerUp)
+	}
+}
+
+// printAlternatives lists, for each transition of the set, the next-best tracks that
+// were still unplayed at that point (see strategy.NextBest), so a pick that sounds
+// wrong in rehearsal comes with what to swap in.
+func printAlternatives(tracks []track.Track, alternatives [][]strategy.Alternative) {
+	if len(alternatives) < 2 {
+		return
+	}
+	fmt.Println("Alternatives at each transition (coherence cost; lower is smoother):")
+	for i := 1; i < len(tracks) && i < len(alternatives); i++ {
+		alts := make([]string, len(alternatives[i]))
+		for k, a := range alternatives[i] {
+			alts[k] = fmt.Sprintf("%q %.2f", a.Track.Title, a.Cost)
+			if a.Spare {
+				alts[k] += " (left out)"
+			}
+		}
+		if len(alts) == 0 {
+			alts = []string{"none left"}
+		}
+		fmt.Printf("  #%d %q -> %q %.2f; instead: %s\n", i, tracks[i-1].Title, tracks[i].Title,
+			strategy.TransitionCost(tracks[i-1], tracks[i], strategy.DefaultWeights), strings.Join(alts, ", "))
+	}
+}
+
+// printWildcards lists the tracks --wildcards played, with why each is a surprise.
+func printWildcards(tracks []track.Track, wildcards []string) {
+	if wildcards == nil {
+		return
+	}
+	fmt.Println("Wildcards (--wildcards):")
+	for i, reason := range wildcards {
+		if reason != "" {
+			fmt.Printf("  #%d %q by %s: %s\n", i+1, tracks[i].Title, tracks[i].Artist, reason)
+		}
+	}
+}
+
+// spareTracks returns the tracks of pool that set doesn't play.
+func spareTracks(pool, set []track.Track) []track.Track {
+	var spare dropLog
+	spare.diff(pool, set, "")
+	out := make([]track.Track, len(spare))
+	for i, d := range spare {
+		out[i] = d.Track
+	}
+	return out
+}
+
+// printRehearsals lists the n hardest transitions of a set to play (see
+// strategy.TransitionDifficulty), so the user knows which ones to practice.
+func printRehearsals(tracks []track.Track, n int) {
+	difficulty := strategy.Difficulties(tracks)
+	hardest := strategy.HardestTransitions(difficulty, n)
+	if len(hardest) == 0 {
+		return
+	}
+	fmt.Printf("Transitions to rehearse (difficulty %d-%d):\n", strategy.DifficultyEasy, strategy.DifficultyHardest)
+	for _, i := range hardest {
+		fmt.Printf("  #%d %q -> %q: %d (%s)\n", i+1, tracks[i].Title, tracks[i+1].Title,
+			difficulty[i].Rating, strings.Join(difficulty[i].Reasons, ", "))
+	}
+}
+
+// printStrategies prints each registered strategy with its hints and description.
+func printStrategies(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(tw, "STRATEGY\tQUALITY\tSPEED\tDESCRIPTION")
+	for _, info := range strategy.Infos() {
+		_, _ = fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", info.Name, orDash(info.Quality),
+			orDash(info.Speed), info.Description)
+	}
+	_ = tw.Flush()
+}
+
+// subsetNames lists the subset objectives for --subset's help.
+func subsetNames() string {
+	var names []string
+	for _, o := range strategy.SubsetObjectives() {
+		names = append(names, o.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+// arcNames lists the named arcs for --arc's help.
+func arcNames() string {
+	var names []string
+	for _, a := range strategy.Arcs() {
+		names = append(names, a.Name)
+	}
+	return strings.Join(names, ", ")
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// resolvedOutputPath is the --output value, or the default derived from the input:
+// standard output when the input is standard input.
+func resolvedOutputPath(output, input string) string {
+	if output != "" {
+		return output
+	}
+	if input == playlistio.Stdio {
+		return playlistio.Stdio
+	}
+	return deriveOutputPath(input)
+}
+
+// deriveOutputPath is the default output beside the input; a folder of audio files
+// gets an M3U8 playlist beside the folder, since its tracks carry their locations.
 func deriveOutputPath(input string) string {
-	dir := filepath.Dir(input)
-	base := filepath.Base(input)
+	if playlistio.InputFormatOf(input) == playlistio.Folder {
+		dir := filepath.Clean(input)
+		if base := filepath.Base(dir); base == "." || base == ".." || base == string(filepath.Separator) {
+			if abs, err := filepath.Abs(dir); err == nil {
+				dir = abs
+			}
+		}
+		return dir + "_magicmix" + playlistio.M3U8.Ext()
+	}
+	return suffixedPath(input, "_magicmix")
+}
+
+// suffixedPath inserts suffix before path's extension (adding .csv when it has none).
+func suffixedPath(path, suffix string) string {
+	dir := filepath.Dir(path)
+	base := filepath.Base(path)
 	ext := filepath.Ext(base)
 	name := strings.TrimSuffix(base, ext)
 	if ext == "" {
 		ext = ".csv"
 	}
-	outputName := fmt.Sprintf("%s_magicmix%s", name, ext)
-	return filepath.Join(dir, outputName)
+	return filepath.Join(dir, fmt.Sprintf("%s%s%s", name, suffix, ext))
 }
@@ -3,11 +3,41 @@ package cli
 import (
 	"context"
 	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/libcache"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
 )
 
+func TestMain(m *testing.M) {
+	// Keep the parsed-library cache out of the real config directory.
+	dir, err := os.MkdirTemp("", "magicmix-config")
+	if err != nil {
+		panic(err)
+	}
+	os.Setenv(config.DirEnv, dir)
+	code := m.Run()
+	os.RemoveAll(dir)
+	os.Exit(code)
+}
+
 func TestRunWithLimit(t *testing.T) {
 	dir := t.TempDir()
 	input := filepath.Join(dir, "tracks.csv")
@@ -36,6 +66,173 @@ func TestRunWithLimit(t *testing.T) {
 	}
 }
 
+func TestRunListsDroppedTracks(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track2 (Extended Mix)", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "70", "3A"},
+	})
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--limit", "2", "--keep-all"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	rows := readCSV(t, filepath.Join(dir, "out.dropped.csv"))
+	if len(rows) != 3 || !slices.Equal(rows[0], []string{"Title", "Artist", "BPM", "Key", "Energy", "Reason"}) {
+		t.Fatalf("want a header and 2 dropped tracks, got %v", rows)
+	}
+	reasons := rows[1][5] + "\n" + rows[2][5]
+	if !strings.Contains(reasons, "alternate version") || !strings.Contains(reasons, "--limit 2") {
+		t.Fatalf("want a version and a limit reason, got %q", reasons)
+	}
+}
+
+func TestRunFitsTargetDuration(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Length"},
+		{"Track1", "Artist1", "120", "50", "1A", "5:00"},
+		{"Track2", "Artist2", "121", "60", "2A", "4:00"},
+		{"Track3", "Artist3", "122", "70", "3A", "6:00"},
+		{"Track4", "Artist4", "123", "75", "4A", "10:00"},
+	})
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--target-duration", "15m", "--keep-all"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	rows := readCSV(t, output)
+	total := 0
+	for _, row := range rows[1:] {
+		var m, s int
+		if _, err := fmt.Sscanf(row[len(row)-1], "%d:%d", &m, &s); err != nil {
+			t.Fatalf("bad length in %v: %v", row, err)
+		}
+		total += m*60 + s
+	}
+	if total != 15*60 {
+		t.Fatalf("want tracks adding up to 15:00, got %d seconds in %v", total, rows)
+	}
+	dropped := readCSV(t, filepath.Join(dir, "out.dropped.csv"))
+	if len(dropped) < 2 || !strings.Contains(dropped[1][5], "--target-duration") {
+		t.Fatalf("want the trimmed tracks listed, got %v", dropped)
+	}
+}
+
+func TestRunAppliesProfile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "70", "3A"},
+	})
+	configDir := os.Getenv(config.DirEnv)
+	profiles := filepath.Join(configDir, config.ProfilesFile)
+	if err := os.WriteFile(profiles, []byte(`{"profiles": {"short": {"limit": 1, "strategy": "flow"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = os.Remove(profiles) })
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--profile", "short"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if rows := readCSV(t, output); len(rows) != 2 {
+		t.Fatalf("the profile's limit should leave 1 track, got %d rows", len(rows))
+	}
+	args := []string{"--input", input, "--output", output, "--profile", "short", "--limit", "2"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if rows := readCSV(t, output); len(rows) != 3 {
+		t.Fatalf("--limit 2 should override the profile, got %d rows", len(rows))
+	}
+	if err := run(context.Background(), []string{"--input", input, "--profile", "gig"}); err == nil {
+		t.Fatal("an unknown profile should be an error")
+	}
+}
+
+func TestRunWorkspace(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 6 {
+		rows = append(rows, []string{"T" + strconv.Itoa(i), "Artist" + strconv.Itoa(i), strconv.Itoa(120 + i),
+			strconv.Itoa(40 + 5*i), strconv.Itoa(1+i) + "A"})
+	}
+	writeCSV(t, input, rows)
+	rules := filepath.Join(dir, "rules.json")
+	if err := os.WriteFile(rules, []byte(`{"limit": 3, "open": "T4"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	if err := run(ctx, []string{"workspace", "init", "fridays", "--input", input, "--rules", rules, "--keep", "1"}); err != nil {
+		t.Fatalf("workspace init: %v", err)
+	}
+	if err := run(ctx, []string{"workspace", "init", "fridays", "--input", input}); err == nil {
+		t.Fatal("creating a workspace twice should be an error")
+	}
+	if err := run(ctx, []string{"sort", "--workspace", "fridays", "--seed", "1"}); err != nil {
+		t.Fatalf("sort --workspace: %v", err)
+	}
+	ws, err := config.LoadWorkspace(filepath.Join(os.Getenv(config.DirEnv), config.WorkspacesDir, "fridays"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sets, err := ws.Outputs()
+	if err != nil || len(sets) != 1 {
+		t.Fatalf("want the set written into the workspace, got %v, %v", sets, err)
+	}
+	got := readCSV(t, sets[0][0])[1:]
+	if len(got) != 3 || got[0][0] != "T4" {
+		t.Fatalf("want the workspace's rules, 3 tracks opening on T4; got %v", got)
+	}
+
+	// Flags given on the command line win over the workspace's rules.
+	output := filepath.Join(dir, "out.csv")
+	if err := run(ctx, []string{"--workspace", "fridays", "--seed", "1", "--limit", "5", "--output", output}); err != nil {
+		t.Fatalf("--workspace with --limit: %v", err)
+	}
+	if got := readCSV(t, output)[1:]; len(got) != 5 || got[0][0] != "T4" {
+		t.Fatalf("want --limit 5 over the rules' 3, still opening on T4; got %v", got)
+	}
+	if err := run(ctx, []string{"--workspace", "saturdays"}); err == nil || !strings.Contains(err.Error(), "have fridays") {
+		t.Fatalf("an unknown workspace should list the known ones, got %v", err)
+	}
+
+	// A packed workspace carries its library and profile to a machine without them.
+	profiles := filepath.Join(os.Getenv(config.DirEnv), config.ProfilesFile)
+	if err := os.WriteFile(profiles, []byte(`{"profiles": {"club": {"close": "T5"}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(ctx, []string{"workspace", "init", "saturdays", "--input", input, "--rules", rules, "--profile", "club"}); err != nil {
+		t.Fatalf("workspace init: %v", err)
+	}
+	archive := filepath.Join(dir, "away.zip")
+	if err := run(ctx, []string{"workspace", "pack", "saturdays", archive}); err != nil {
+		t.Fatalf("workspace pack: %v", err)
+	}
+	for _, path := range []string{profiles, input} {
+		if err := os.Remove(path); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := run(ctx, []string{"--workspace", archive, "--seed", "1", "--output", output}); err != nil {
+		t.Fatalf("--workspace with the archive: %v", err)
+	}
+	if got := readCSV(t, output)[1:]; len(got) != 3 || got[0][0] != "T4" || got[2][0] != "T5" {
+		t.Fatalf("want the packed rules and profile, 3 tracks from T4 to T5; got %v", got)
+	}
+}
+
 func TestRunWithNegativeLimit(t *testing.T) {
 	dir := t.TempDir()
 	input := filepath.Join(dir, "tracks.csv")
@@ -86,3 +283,1930 @@ func readCSV(t *testing.T, path string) [][]string {
 	}
 	return data
 }
+
+func TestRunCratesWritesOnePlaylistPerCrate(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "library.csv")
+	outDir := filepath.Join(dir, "crates")
+
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Slow1", "A", "90", "40", "8A"},
+		{"Slow2", "B", "91", "45", "9A"},
+		{"Fast1", "C", "128", "60", "8A"},
+		{"Fast2", "D", "129", "65", "2B"},
+	})
+
+	if err := run(context.Background(), []string{"crates", "--input", input, "--out-dir", outDir}); err != nil {
+		t.Fatalf("run crates: %v", err)
+	}
+
+	entries, err := os.ReadDir(outDir)
+	if err != nil {
+		t.Fatalf("ReadDir: %v", err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("got %d crate files, want 3", len(entries))
+	}
+	total := 0
+	for _, e := range entries {
+		total += len(readCSV(t, filepath.Join(outDir, e.Name()))) - 1 // minus header
+	}
+	if total != 4 {
+		t.Fatalf("crates hold %d tracks, want 4", total)
+	}
+}
+
+func TestRunMissingEnergyNeedsInferFlag(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Key", "Genre"},
+		{"Track1", "Artist1", "120", "1A", "House"},
+		{"Track2", "Artist2", "124", "2A", "Techno"},
+	})
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output}); err == nil {
+		t.Fatal("expected an error for missing energy without --infer-energy")
+	}
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--infer-energy", "--keep-all"}); err != nil {
+		t.Fatalf("run with --infer-energy returned error: %v", err)
+	}
+	if rows := readCSV(t, output); len(rows) != 3 {
+		t.Fatalf("expected 3 rows in output, got %d", len(rows))
+	}
+}
+
+func TestRunWritesGainColumn(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "loud.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Loudness"},
+		{"Loud1", "Artist1", "124", "50", "8A", "-8"},
+		{"Loud2", "Artist2", "125", "60", "9A", "-17.5"},
+		{"Loud3", "Artist3", "123", "55", "8B", ""},
+	})
+	output := filepath.Join(dir, "loud_out.csv")
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--no-cache"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	rows := readCSV(t, output)
+	if rows[0][6] != "Gain" {
+		t.Fatalf("header = %v, want a Gain column", rows[0])
+	}
+	gains := map[string]string{}
+	for _, row := range rows[1:] {
+		gains[row[0]] = row[6]
+	}
+	if gains["Loud1"] != "-6.0" || gains["Loud2"] != "+3.5" || gains["Loud3"] != "" {
+		t.Errorf("gains = %v", gains)
+	}
+}
+
+func TestRunCopiesSetToFolder(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music")
+	if err := os.Mkdir(music, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"usb1.mp3", "Artist2 - Usb2.FLAC"} {
+		if err := os.WriteFile(filepath.Join(music, name), []byte(name), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := filepath.Join(dir, "usb.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Location"},
+		{"Usb1", "AC/DC", "124", "50", "8A", "usb1.mp3"},
+		{"Usb2", "Artist2", "124", "55", "8A", ""},
+		{"Usb3", "Artist3", "124", "60", "8A", ""},
+	})
+	stick := filepath.Join(dir, "stick")
+	if err := run(context.Background(), []string{"--input", input, "--output", filepath.Join(dir, "out.csv"), "--no-cache",
+		"--copy-to", stick, "--music-dir", music}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	entries, err := os.ReadDir(stick)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	rows := readCSV(t, filepath.Join(dir, "out.csv"))
+	want := map[string]string{"Usb1": "AC_DC - Usb1.mp3", "Usb2": "Artist2 - Usb2.flac"}
+	var wantNames []string
+	for i, row := range rows[1:] {
+		if name, ok := want[row[0]]; ok {
+			wantNames = append(wantNames, fmt.Sprintf("%02d - %s", i+1, name))
+		}
+	}
+	slices.Sort(wantNames)
+	if !slices.Equal(names, wantNames) {
+		t.Fatalf("copied %v, want %v", names, wantNames)
+	}
+	if data, err := os.ReadFile(filepath.Join(stick, wantNames[0])); err != nil || len(data) == 0 {
+		t.Errorf("copy of %s = %q, %v", wantNames[0], data, err)
+	}
+	if err := run(context.Background(), []string{"--input", input, "--copy-to", stick, "--copy-mode", "burn"}); err == nil {
+		t.Error("an unknown --copy-mode should fail")
+	}
+}
+
+func TestRunWritesDecisionLog(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	logPath := filepath.Join(dir, "decisions.jsonl")
+
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "70", "3A"},
+	})
+
+	args := []string{"--input", input, "--output", filepath.Join(dir, "out.csv"),
+		"--keep-all", "--seed", "7", "--decision-log", logPath}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+
+	data, err := os.ReadFile(logPath)
+	if err != nil {
+		t.Fatalf("read decision log: %v", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d decision records, want 3", len(lines))
+	}
+	var rec struct {
+		Pass       int `json:"pass"`
+		Position   int `json:"position"`
+		Candidates []struct {
+			Title string `json:"title"`
+		} `json:"candidates"`
+	}
+	if err := json.Unmarshal([]byte(lines[2]), &rec); err != nil {
+		t.Fatalf("decode record: %v", err)
+	}
+	if rec.Pass != 1 || rec.Position != 2 || len(rec.Candidates) == 0 {
+		t.Fatalf("unexpected record: %+v", rec)
+	}
+}
+
+func TestInputSeedFollowsContentAndOptions(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+	})
+
+	seed := func(options ...string) int64 {
+		t.Helper()
+		s, err := inputSeed(context.Background(), []string{input}, options...)
+		if err != nil {
+			t.Fatalf("inputSeed: %v", err)
+		}
+		return s
+	}
+
+	first := seed("flow")
+	if first == 0 || seed("flow") != first {
+		t.Fatalf("seed is not stable: %d", first)
+	}
+	if seed("chave") == first {
+		t.Fatalf("changing an option kept the seed")
+	}
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+	})
+	if seed("flow") == first {
+		t.Fatalf("changing the input kept the seed")
+	}
+}
+
+func TestRunMatrixWritesCostsAndDistances(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "70", "8B"},
+	})
+
+	csvOut := filepath.Join(dir, "matrix.csv")
+	if err := run(context.Background(), []string{"matrix", "--input", input, "--output", csvOut}); err != nil {
+		t.Fatalf("matrix returned error: %v", err)
+	}
+	rows := readCSV(t, csvOut)
+	if len(rows) != 4 || len(rows[0]) != 4 || rows[1][0] != "Artist1 - Track1" || rows[1][1] != "0.0000" {
+		t.Fatalf("unexpected cost matrix: %v", rows)
+	}
+
+	jsonOut := filepath.Join(dir, "matrix.json")
+	if err := run(context.Background(), []string{"matrix", "--input", input, "--output", jsonOut,
+		"--format", "json", "--measure", "camelot"}); err != nil {
+		t.Fatalf("matrix returned error: %v", err)
+	}
+	data, err := os.ReadFile(jsonOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var m struct {
+		Values [][]float64 `json:"values"`
+	}
+	if err := json.Unmarshal(data, &m); err != nil {
+		t.Fatalf("decode matrix: %v", err)
+	}
+	if m.Values[0][1] != 1 || m.Values[0][2] != 6 {
+		t.Fatalf("camelot distances = %v, want 1A->2A 1 and 1A->8B 6", m.Values)
+	}
+}
+
+func TestRunEvaluate(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "set.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+	})
+	if err := run(context.Background(), []string{"evaluate", "--input", input}); err != nil {
+		t.Fatalf("evaluate returned error: %v", err)
+	}
+	if err := run(context.Background(), []string{"evaluate"}); err == nil {
+		t.Fatal("evaluate without --input should fail")
+	}
+}
+
+func TestRunPartnersWritesCounts(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "124", "50", "8A"},
+		{"Track2", "Artist2", "125", "60", "9A"},
+		{"Track3", "Artist3", "123", "55", "8B"},
+		{"Lonely", "Artist4", "124", "65", "2B"},
+	})
+	output := filepath.Join(dir, "partners.csv")
+	if err := run(context.Background(), []string{"partners", "--input", input, "--output", output}); err != nil {
+		t.Fatalf("partners returned error: %v", err)
+	}
+	rows := readCSV(t, output)
+	if len(rows) != 5 || rows[0][4] != "In" || rows[1][6] != "2" || rows[4][6] != "0" {
+		t.Fatalf("unexpected partner counts: %v", rows)
+	}
+	if err := run(context.Background(), []string{"partners", "--input", input, "--bpm-window", "0"}); err == nil {
+		t.Error("partners with a zero tempo window should fail")
+	}
+}
+
+func TestRunLibdiffWritesJSON(t *testing.T) {
+	dir := t.TempDir()
+	before := filepath.Join(dir, "before.csv")
+	writeCSV(t, before, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Diff1", "Artist1", "124", "50", "8A"},
+		{"Diff2", "Artist2", "125", "60", "9A"},
+		{"Diff3", "Artist3", "123", "55", "8B"},
+	})
+	after := filepath.Join(dir, "after.csv")
+	writeCSV(t, after, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Diff1", "Artist1", "124", "50", "8A"},
+		{"Diff2", "Artist2", "125", "60", "10A"},
+		{"Diff5", "Artist5", "140", "70", "3B"},
+	})
+	output := filepath.Join(dir, "diff.json")
+	if err := run(context.Background(), []string{"libdiff", "--output", output, before, after}); err != nil {
+		t.Fatalf("libdiff returned error: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var report libdiffReport
+	if err := json.Unmarshal(data, &report); err != nil {
+		t.Fatalf("libdiff JSON: %v", err)
+	}
+	if len(report.Added) != 1 || report.Added[0].Title != "Diff5" || len(report.Removed) != 1 || report.Removed[0].Title != "Diff3" {
+		t.Fatalf("added %v, removed %v", report.Added, report.Removed)
+	}
+	if len(report.Changed) != 1 || report.Changed[0].Fields[0].Field != "key" || report.Changed[0].Fields[0].After != "10A" {
+		t.Fatalf("changed %v", report.Changed)
+	}
+	if len(report.Keys) != 24 || report.Before.KeysCovered != 3 || report.After.KeysCovered != 3 || len(report.After.BPMGaps) != 1 {
+		t.Fatalf("unexpected shapes: %+v -> %+v", report.Before, report.After)
+	}
+	if err := run(context.Background(), []string{"libdiff", before}); err == nil {
+		t.Error("libdiff with one library should fail")
+	}
+}
+
+func TestRunPreviewsWritesScript(t *testing.T) {
+	dir := t.TempDir()
+	music := filepath.Join(dir, "music")
+	if err := os.Mkdir(music, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Artist2 - Preview2.mp3", "Preview3.flac"} {
+		if err := os.WriteFile(filepath.Join(music, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	input := filepath.Join(dir, "set.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Length", "Location"},
+		{"Preview1", "Artist1", "124", "50", "8A", "300", "/music/it's 1.mp3"},
+		{"Preview2", "Artist2", "125", "60", "9A", "200", ""},
+		{"Preview3", "Artist3", "123", "55", "8B", "", ""},
+		{"Preview4", "Artist4", "123", "55", "8B", "", ""},
+	})
+	script := filepath.Join(dir, "previews.sh")
+	if err := run(context.Background(), []string{"previews", "--input", input, "--music-dir", music,
+		"--seconds", "20", "--script", script}); err != nil {
+		t.Fatalf("previews returned error: %v", err)
+	}
+	data, err := os.ReadFile(script)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(data)
+	for _, want := range []string{
+		"# at 0:05:00: Artist1 - Preview1 -> Artist2 - Preview2\n",
+		`-sseof -20 -i '/music/it'\''s 1.mp3' -t 20 -i '` + filepath.Join(music, "Artist2 - Preview2.mp3") + "'",
+		"previews/02-preview2--preview3.mp3\n",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("script missing %q:\n%s", want, got)
+		}
+	}
+	if strings.Count(got, "\nffmpeg ") != 2 {
+		t.Errorf("want 2 clips (Preview4 has no file):\n%s", got)
+	}
+	if err := run(context.Background(), []string{"previews", "--input", input, "--seconds", "5", "--crossfade", "5"}); err == nil {
+		t.Error("previews with a crossfade as long as the clip should fail")
+	}
+}
+
+func TestRunJob(t *testing.T) {
+	dir := t.TempDir()
+	writeCSV(t, filepath.Join(dir, "lib.csv"), [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Location"},
+		{"Job1", "Artist1", "120", "50", "1A", "/music/1.mp3"},
+		{"Job2", "Artist2", "121", "60", "2A", "/music/2.mp3"},
+		{"Job3", "Artist3", "122", "55", "3A", "/music/3.mp3"},
+		{"Job4", "Artist4", "123", "65", "2B", "/music/4.mp3"},
+	})
+	var posted jobOutcome
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Errorf("webhook body: %v", err)
+		}
+	}))
+	defer hook.Close()
+	jobFile := filepath.Join(dir, "nightly.yaml")
+	writeJob := func(body string) {
+		t.Helper()
+		if err := os.WriteFile(jobFile, []byte(body), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	writeJob(`inputs: [lib.csv]
+strategy: {name: flow, seed: 3}
+constraints: {limit: 3}
+outputs: [set.csv, set.m3u8]
+notify: {webhook: ` + hook.URL + `}
+`)
+	if err := run(context.Background(), []string{"run", jobFile}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if rows := readCSV(t, filepath.Join(dir, "set.csv")); len(rows) != 4 {
+		t.Errorf("set has %d rows, want a header and 3 tracks", len(rows))
+	}
+	if data, err := os.ReadFile(filepath.Join(dir, "set.m3u8")); err != nil || strings.Count(string(data), "/music/") != 3 {
+		t.Errorf("playlist copy: %q, %v", data, err)
+	}
+	if posted.Status != "ok" || len(posted.Outputs) != 2 {
+		t.Errorf("webhook got %+v, want ok and both outputs", posted)
+	}
+
+	writeJob("inputs: [lib.csv]\nconstraints: {limt: 3}\nnotify: {webhook: " + hook.URL + ", on: failure}\n")
+	posted = jobOutcome{}
+	if err := run(context.Background(), []string{"run", jobFile}); err == nil {
+		t.Error("a job with an unknown flag should fail")
+	}
+	if posted.Status != "failed" || posted.Error == "" {
+		t.Errorf("webhook got %+v, want the failure", posted)
+	}
+
+	writeJob("inputs: [lib.csv]\nconstraints: {output: x.csv}\n")
+	if err := run(context.Background(), []string{"run", jobFile}); err == nil {
+		t.Error("a job setting output in a section should fail")
+	}
+}
+
+func TestRunFairness(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "lib.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 12 {
+		rows = append(rows, []string{fmt.Sprintf("Fair%d", i), fmt.Sprintf("Artist%d", i), "124",
+			strconv.Itoa(40 + 3*i), fmt.Sprintf("%d%c", 1+i%6, "AB"[i%2])})
+	}
+	writeCSV(t, input, rows)
+	args := []string{"fairness", "--input", input, "--strategies", "flow,rotation", "--limit", "6", "--runs", "2", "--seed", "4"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("fairness returned error: %v", err)
+	}
+	if err := run(context.Background(), []string{"fairness", "--input", input, "--limit", "12"}); err == nil {
+		t.Error("a set as big as the library leaves nothing to audit and should fail")
+	}
+}
+
+func TestRunCompare(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "lib.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "55", "3A"},
+		{"Track4", "Artist4", "123", "65", "2B"},
+	})
+	output := filepath.Join(dir, "out", "set.csv")
+	args := []string{"compare", "--input", input, "--strategies", "flow, default", "--seed", "3", "--output", output}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("compare returned error: %v", err)
+	}
+	for _, name := range []string{"flow", "default"} {
+		if rows := readCSV(t, filepath.Join(dir, "out", "set_"+name+".csv")); len(rows) != 5 {
+			t.Errorf("%s ordering has %d rows, want a header and 4 tracks", name, len(rows))
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out", "set_chave.csv")); err == nil {
+		t.Error("compare wrote a strategy --strategies didn't name")
+	}
+	if err := run(context.Background(), []string{"compare", "--input", input, "--strategies", "nope"}); err == nil {
+		t.Error("compare with an unknown strategy should fail")
+	}
+
+	// A budget too short for flow to finish still gets it scored, from what it placed.
+	// Four tracks are sorted exhaustively, too fast to stop, so this needs more.
+	big := filepath.Join(dir, "big.csv")
+	writeCSV(t, big, budgetTestRows())
+	printed, err := runStdout(t, "compare", "--input", big, "--strategies", "flow,default", "--seed", "3",
+		"--budget", "flow=1ns", "--output", output)
+	if err != nil {
+		t.Fatalf("compare with a budget returned error: %v", err)
+	}
+	if !strings.Contains(printed, "flow strategy stopped early") {
+		t.Errorf("want flow cut short by its budget, got\n%s", printed)
+	}
+	for _, line := range strings.Split(printed, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || (fields[0] != "flow" && fields[0] != "default") {
+			continue
+		}
+		if stopped := strings.HasSuffix(line, "(stopped at its 1ns limit)"); stopped != (fields[0] == "flow") {
+			t.Errorf("row %q: want only flow marked as stopped at its limit", line)
+		}
+	}
+	if rows := readCSV(t, filepath.Join(dir, "out", "set_flow.csv")); len(rows) != 31 {
+		t.Errorf("flow's partial ordering has %d rows, want a header and the 30 tracks it placed", len(rows))
+	}
+
+	args = []string{"compare", "--input", input, "--strategies", "flow,default", "--seed", "3", "--runs", "3"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("compare over several runs returned error: %v", err)
+	}
+	if err := run(context.Background(), []string{"compare", "--input", input, "--runs", "0"}); err == nil {
+		t.Error("compare with --runs 0 should fail")
+	}
+}
+
+// budgetTestRows is a library big enough that flow checks its time limit while it
+// sorts.
+func budgetTestRows() [][]string {
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 30 {
+		rows = append(rows, []string{"T" + strconv.Itoa(i), "Artist" + strconv.Itoa(i), strconv.Itoa(118 + i%9),
+			strconv.Itoa(40 + i%50), strconv.Itoa(1+i%12) + "A"})
+	}
+	return rows
+}
+
+// runStdout runs a command line and returns what it printed to standard output.
+func runStdout(t *testing.T, args ...string) (string, error) {
+	t.Helper()
+	stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	saved := os.Stdout
+	os.Stdout = stdout
+	runErr := run(context.Background(), args)
+	os.Stdout = saved
+	_ = stdout.Close()
+	data, err := os.ReadFile(stdout.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data), runErr
+}
+
+func TestRunCandidatesBudget(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, budgetTestRows())
+	output := filepath.Join(dir, "out.csv")
+
+	// Each candidate gets its own budget; all three are stopped by it, and kept whole.
+	printed, err := runStdout(t, "--input", input, "--output", output, "--strategy", "flow", "--seed", "3",
+		"--candidates", "3", "--budget", "flow=1ns,anneal=5s")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if n := strings.Count(printed, "(stopped early)"); n != 3 {
+		t.Errorf("%d candidate(s) stopped early, want all 3:\n%s", n, printed)
+	}
+	if !strings.Contains(printed, "--budget gives anneal a limit, but flow is sorting") {
+		t.Errorf("want a note that anneal's budget went unused:\n%s", printed)
+	}
+	if rows := readCSV(t, output); len(rows) != 31 {
+		t.Errorf("wrote %d rows, want a header and all 30 tracks", len(rows))
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--sets", "2", "--budget", "flow=1s"}); err == nil {
+		t.Error("budget with sets should fail")
+	}
+}
+
+func TestRunDeterministicSeed(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, budgetTestRows())
+	seedOf := func(args ...string) string {
+		t.Helper()
+		printed, err := runStdout(t, append([]string{"--input", input, "--deterministic", "--no-cache"}, args...)...)
+		if err != nil {
+			t.Fatalf("run %v: %v", args, err)
+		}
+		for _, line := range strings.Split(printed, "\n") {
+			if rest, ok := strings.CutPrefix(line, "Using seed "); ok {
+				return strings.Fields(rest)[0]
+			}
+		}
+		var summary strategy.Summary // --summary-format json moves the line to standard error
+		if err := json.Unmarshal([]byte(printed), &summary); err == nil && summary.Seed != 0 {
+			return strconv.FormatInt(summary.Seed, 10)
+		}
+		t.Fatalf("run %v printed no seed:\n%s", args, printed)
+		return ""
+	}
+
+	first := seedOf("--output", filepath.Join(dir, "a.csv"))
+	if again := seedOf("--output", filepath.Join(dir, "b.csv")); again != first {
+		t.Errorf("writing elsewhere changed the seed: %s, then %s", first, again)
+	}
+	// Flags that only add to the report, or check the run, leave the seed, and so the
+	// set, as they were.
+	titles := func(path string) []string {
+		var titles []string
+		for _, row := range readCSV(t, path)[1:] {
+			titles = append(titles, row[0])
+		}
+		return titles
+	}
+	want := titles(filepath.Join(dir, "a.csv"))
+	for _, flags := range [][]string{{"--explain"}, {"--explain-column"}, {"--alternatives", "2"}, {"--audit-determinism"}, {"--verbose"}, {"--summary-format", "json"}, {"--check-genres"}} {
+		output := filepath.Join(dir, "report.csv")
+		if got := seedOf(append(flags, "--output", output)...); got != first {
+			t.Errorf("%v changed the seed: %s, then %s", flags, first, got)
+		}
+		if got := titles(output); !slices.Equal(got, want) {
+			t.Errorf("%v changed the set:\n%v\nwant\n%v", flags, got, want)
+		}
+	}
+	// Every flag the result cache keys on feeds the seed too, not just a chosen few.
+	for _, flags := range [][]string{{"--artist-gap", "3"}, {"--energy-max", "80"}, {"--key-memory", "1"}} {
+		if got := seedOf(append(flags, "--output", filepath.Join(dir, "c.csv"))...); got == first {
+			t.Errorf("%v left the seed at %s", flags, got)
+		}
+	}
+}
+
+func TestRunCheckGenresReusesResult(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Genre"},
+		{"Misread Techno", "A", "78", "12", "8A", "Techno"},
+		{"Warehouse", "B", "130", "80", "8A", "Techno"},
+		{"Driver", "C", "131", "82", "9A", "Techno"},
+	})
+	args := []string{"--input", input, "--seed", "11", "--keep-all"}
+	if _, err := runStdout(t, append(args, "--output", filepath.Join(dir, "a.csv"))...); err != nil {
+		t.Fatal(err)
+	}
+	// The check doesn't change the ordering, so it reuses the result, and still reports.
+	printed, err := runStdout(t, append(args, "--output", filepath.Join(dir, "b.csv"), "--check-genres")...)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(printed, "Reused the result") || !strings.Contains(printed, "\"Misread Techno\" by A looks") {
+		t.Fatalf("--check-genres after a run without it printed:\n%s\nwant the reused result with the misfit reported", printed)
+	}
+}
+
+func TestSummarize(t *testing.T) {
+	s := summarize([]float64{1, 2, 3})
+	// mean 2, sd 1, half-width t(2) * 1 / sqrt(3)
+	half := 4.303 / math.Sqrt(3)
+	if s.mean != 2 || s.sd != 1 || math.Abs(s.lo-(2-half)) > 1e-9 || math.Abs(s.hi-(2+half)) > 1e-9 {
+		t.Errorf("summarize = %+v", s)
+	}
+	if one := summarize([]float64{5}); one.sd != 0 || one.lo != 5 || one.hi != 5 {
+		t.Errorf("summarize of one run = %+v, want no spread", one)
+	}
+	if got := formatSpread(s, 2); got != "2.00 ± 1.00 [-0.48, 4.48]" {
+		t.Errorf("formatSpread = %q", got)
+	}
+}
+
+func TestParseBudgets(t *testing.T) {
+	got, err := parseBudgets("default=1s, anneal=20s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]time.Duration{"default": time.Second, "anneal": 20 * time.Second}; !maps.Equal(got, want) {
+		t.Errorf("parseBudgets = %v, want %v", got, want)
+	}
+	for _, bad := range []string{"anneal", "nope=1s", "anneal=0s", "anneal=soon", "flow=1s,flow=2s"} {
+		if _, err := parseBudgets(bad); err == nil {
+			t.Errorf("parseBudgets(%q) should fail", bad)
+		}
+	}
+}
+
+func TestSortCandidatesKeepsBest(t *testing.T) {
+	var tracks []track.Track
+	for i := range 16 {
+		k, _ := track.ParseKey(strconv.Itoa(1+i*5%12) + "A")
+		tracks = append(tracks, track.Track{Title: "T" + strconv.Itoa(i), BPM: float64(110 + i*3%20), Energy: 30 + i*7%60, Key: k})
+	}
+	seeds := candidateSeeds(42, 5)
+	if seeds[0] != 42 || !slices.Equal(seeds, candidateSeeds(42, 5)) {
+		t.Fatalf("candidate seeds should start at the run's seed and repeat, got %v", seeds)
+	}
+	sorter, err := strategy.Get("default")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cands, best, err := sortCandidates(context.Background(), sorter, tracks, seeds, 0, eval.Options{})
+	if err != nil {
+		t.Fatalf("sortCandidates: %v", err)
+	}
+	for i, c := range cands {
+		if len(c.result.Ordered) != len(tracks) {
+			t.Fatalf("candidate %d placed %d of %d tracks", i, len(c.result.Ordered), len(tracks))
+		}
+		if c.eval < cands[best].eval {
+			t.Fatalf("candidate %d scores %.2f, better than the kept %.2f", i, c.eval, cands[best].eval)
+		}
+	}
+}
+
+func TestReadTuning(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "magicmix.json")
+	if err := os.WriteFile(path, []byte(`{"default": {"bpmWeight": 2.5, "cycleIdealTracks": 9, "genreBPMTolerance": {"Hip Hop": 12}}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tuning, err := readTuning(path)
+	if err != nil {
+		t.Fatalf("readTuning: %v", err)
+	}
+	if tuning.BPMWeight != 2.5 || tuning.CycleIdealTracks != 9 || tuning.KeyWeight != strategy.DefaultTuning.KeyWeight ||
+		tuning.GenreBPMTolerance["Hip Hop"] != 12 {
+		t.Fatalf("unexpected tuning %+v", tuning)
+	}
+
+	for _, bad := range []string{`{"default": {"bpmWieght": 2}}`, `{"flow": {}}`, `{"default": {"cycleMinTracks": 0}}`,
+		`{"default": {"genreBPMTolerance": {"Trance": 0}}}`} {
+		if err := os.WriteFile(path, []byte(bad), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := readTuning(path); err == nil {
+			t.Fatalf("readTuning(%s) should fail", bad)
+		}
+	}
+}
+
+func TestParseInputSpec(t *testing.T) {
+	tests := []struct {
+		in     string
+		path   string
+		weight float64
+		err    bool
+	}{
+		{"main.csv", "main.csv", 1, false},
+		{"main.csv:0.7", "main.csv", 0.7, false},
+		{`C:\music\main.csv`, `C:\music\main.csv`, 1, false},
+		{"main.csv:0", "", 0, true},
+	}
+	for _, tc := range tests {
+		got, err := parseInputSpec(tc.in)
+		if (err != nil) != tc.err || got.path != tc.path || got.weight != tc.weight {
+			t.Errorf("parseInputSpec(%q) = %+v, %v", tc.in, got, err)
+		}
+	}
+}
+
+func TestRunBlendsWeightedInputs(t *testing.T) {
+	dir := t.TempDir()
+	main := filepath.Join(dir, "main.csv")
+	promos := filepath.Join(dir, "promos.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, main, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"M1", "Artist1", "120", "50", "1A"},
+		{"M2", "Artist2", "121", "55", "2A"},
+		{"M3", "Artist3", "122", "60", "3A"},
+		{"M4", "Artist4", "123", "65", "4A"},
+	})
+	writeCSV(t, promos, [][]string{
+		{"Title", "Artist", "Key", "BPM", "Energy"},
+		{"P1", "Promo1", "5A", "124", "70"},
+		{"P2", "Promo2", "6A", "125", "75"},
+	})
+
+	args := []string{"--input", main + ":0.75", "--input", promos + ":0.25", "--output", output,
+		"--limit", "4", "--keep-all", "--seed", "1"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	rows := readCSV(t, output)
+	if len(rows) != 5 {
+		t.Fatalf("expected header + 4 rows, got %d", len(rows))
+	}
+	promoRows := 0
+	for _, r := range rows[1:] {
+		if strings.HasPrefix(r[0], "P") {
+			promoRows++
+		}
+	}
+	if promoRows != 1 {
+		t.Fatalf("got %d promo rows, want 1: %v", promoRows, rows)
+	}
+}
+
+func TestRunMinNewKeepsNewTracks(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	output := filepath.Join(dir, "out.csv")
+	recent := time.Now().AddDate(0, 0, -3).Format("2006-01-02")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Date Added"},
+		{"Old1", "Artist1", "120", "50", "1A", "2020-01-01"},
+		{"Old2", "Artist2", "121", "55", "2A", "2020-01-01"},
+		{"Old3", "Artist3", "122", "60", "3A", "2020-01-01"},
+		{"Old4", "Artist4", "123", "65", "4A", "2020-01-01"},
+		{"New1", "Artist5", "140", "90", "9B", recent},
+		{"New2", "Artist6", "90", "20", "11B", recent},
+	})
+
+	args := []string{"--input", input, "--output", output, "--limit", "4", "--keep-all",
+		"--min-new", "2", "--seed", "1"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	rows := readCSV(t, output)
+	if len(rows) != 5 {
+		t.Fatalf("expected header + 4 rows, got %d", len(rows))
+	}
+	newRows := 0
+	for _, r := range rows[1:] {
+		if strings.HasPrefix(r[0], "New") {
+			newRows++
+		}
+	}
+	if newRows != 2 {
+		t.Fatalf("got %d new tracks, want 2: %v", newRows, rows)
+	}
+}
+
+func TestRunPinsOpenerCloserAndMustInclude(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	output := filepath.Join(dir, "out.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 12 {
+		rows = append(rows, []string{"T" + strconv.Itoa(i), "Artist" + strconv.Itoa(i), strconv.Itoa(118 + i),
+			strconv.Itoa(40 + 4*i), strconv.Itoa(1+i%12) + "A"})
+	}
+	rows = append(rows, []string{"Odd One", "Outlier", "170", "95", "6B"})
+	writeCSV(t, input, rows)
+
+	for _, strategyName := range []string{"default", "flow"} {
+		args := []string{"--input", input, "--output", output, "--strategy", strategyName, "--limit", "6", "--seed", "1",
+			"--open", "T7|Artist7", "--close", "t2", "--pin", "Odd One|Outlier", "--pin", "T10@3", "--pin", "T11@..2"}
+		if err := run(context.Background(), args); err != nil {
+			t.Fatalf("%s: run returned error: %v", strategyName, err)
+		}
+		got := readCSV(t, output)[1:]
+		if len(got) != 6 || got[0][0] != "T7" || got[5][0] != "T2" || got[2][0] != "T10" {
+			t.Fatalf("%s: want T7 first, T10 third, T2 last in 6 tracks; got %v", strategyName, got)
+		}
+		if !slices.ContainsFunc(got, func(r []string) bool { return r[0] == "Odd One" }) {
+			t.Fatalf("%s: the must-include track was dropped: %v", strategyName, got)
+		}
+		if got[1][0] != "T11" {
+			t.Fatalf("%s: want T11 within the first 2, the only place left for it; got %v", strategyName, got)
+		}
+	}
+
+	err := run(context.Background(), []string{"--input", input, "--output", output, "--open", "Nope"})
+	if err == nil || !strings.Contains(err.Error(), "matches no track") {
+		t.Fatalf("an unknown opener should be an error, got %v", err)
+	}
+}
+
+func TestParsePinRanges(t *testing.T) {
+	for spec, want := range map[string][3]int{
+		"T1@3":      {3, 0, 0},
+		"T1@20..":   {0, 20, 0},
+		"T1@..10":   {0, 0, 10},
+		"T1@5..15":  {0, 5, 15},
+		"T1@-10..":  {0, -10, 0},
+		"T1@2..-2":  {0, 2, -2},
+		"T1@..-5":   {0, 0, -5},
+		"T1@ 4 .. ": {0, 4, 0},
+	} {
+		pin, pinned, err := parsePin(spec)
+		if err != nil || !pinned {
+			t.Errorf("parsePin(%q) = pinned %v, %v", spec, pinned, err)
+			continue
+		}
+		if got := [3]int{pin.Position, pin.Earliest, pin.Latest}; got != want {
+			t.Errorf("parsePin(%q): position, earliest, latest = %v; want %v", spec, got, want)
+		}
+	}
+	for spec, want := range map[string]string{
+		"T1@..":     "at least one end",
+		"T1@0..5":   "positions start at 1",
+		"T1@9..3":   "starts at 9, after it ends at 3",
+		"T1@-2..-5": "starts at -2, after it ends at -5",
+		"T1@x..":    "not a number",
+	} {
+		if _, _, err := parsePin(spec); err == nil || !strings.Contains(err.Error(), want) {
+			t.Errorf("parsePin(%q): error %v, want one saying %q", spec, err, want)
+		}
+	}
+}
+
+func TestRunSummaryJSON(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 8 {
+		rows = append(rows, []string{"T" + strconv.Itoa(i), "Artist" + strconv.Itoa(i), strconv.Itoa(120 + i),
+			strconv.Itoa(40 + 5*i), strconv.Itoa(1+i) + "A"})
+	}
+	writeCSV(t, input, rows)
+
+	// The summary goes to standard output, alone; the status lines go to standard error.
+	summaryJSON := func(args ...string) (strategy.Summary, error) {
+		t.Helper()
+		stdout, err := os.Create(filepath.Join(t.TempDir(), "stdout"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		saved := os.Stdout
+		os.Stdout = stdout
+		runErr := run(context.Background(), append([]string{"--summary-format", "json"}, args...))
+		os.Stdout = saved
+		_ = stdout.Close()
+		data, err := os.ReadFile(stdout.Name())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var s strategy.Summary
+		if err := json.Unmarshal(data, &s); err != nil {
+			t.Fatalf("standard output %q is not one JSON summary: %v", data, err)
+		}
+		return s, runErr
+	}
+
+	s, err := summaryJSON("--input", input, "--output", output, "--limit", "5", "--seed", "3", "--strategy", "flow")
+	if err != nil {
+		t.Fatalf("run: %v", err)
+	}
+	if s.Strategy != "flow" || s.Seed != 3 || s.Input != 8 || s.Tracks != 5 || s.Dropped != 3 || s.Error != "" {
+		t.Errorf("summary %+v; want flow's 5 of 8 tracks from seed 3", s)
+	}
+	if len(s.Outputs) != 2 || s.Outputs[0] != output || s.Outputs[1] != droppedPath(output) {
+		t.Errorf("outputs %v; want the set, then the dropped list", s.Outputs)
+	}
+	if s.Score <= 0 || s.Elapsed <= 0 {
+		t.Errorf("score %v, elapsed %v; want both", s.Score, s.Elapsed)
+	}
+
+	s, err = summaryJSON("--input", filepath.Join(dir, "missing.csv"), "--output", output)
+	if err == nil || s.Error == "" || s.Error != err.Error() {
+		t.Errorf("a failed run: error %v, summary error %q; want the error in both", err, s.Error)
+	}
+	if err := run(context.Background(), []string{"--input", input, "--output", "-", "--summary-format", "json"}); err == nil {
+		t.Error("a summary and the set can't share standard output")
+	}
+}
+
+func TestLoadInputsReportsEveryFailure(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.csv")
+	writeCSV(t, good, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+	})
+	specs := []inputSpec{{path: good, weight: 1}, {path: filepath.Join(dir, "a.csv"), weight: 1},
+		{path: good, weight: 1}, {path: filepath.Join(dir, "b.csv"), weight: 1}}
+
+	_, _, err := loadInputs(context.Background(), specs, true, libraryFormat{})
+	if err == nil || !strings.Contains(err.Error(), "a.csv") || !strings.Contains(err.Error(), "b.csv") {
+		t.Fatalf("want both missing inputs reported, got %v", err)
+	}
+
+	pl, source, err := loadInputs(context.Background(), []inputSpec{specs[0], specs[2]}, true, libraryFormat{})
+	if err != nil {
+		t.Fatalf("loadInputs: %v", err)
+	}
+	if len(pl.Tracks) != 2 || !slices.Equal(source, []int{0, 1}) {
+		t.Fatalf("merged %d tracks from sources %v", len(pl.Tracks), source)
+	}
+}
+
+func TestDedupeInputsKeepsOneCopy(t *testing.T) {
+	specs := []inputSpec{{path: "main.csv", weight: 1}, {path: "promos.csv", weight: 1}}
+	tracks := []track.Track{
+		{Title: "Cola", Artist: "CamelPhat & Elderbrook"},
+		{Title: "Solo", Artist: "Someone"},
+		{Title: "cola", Artist: "camelphat & elderbrook"},
+		{Title: "Cola", Artist: "Elderbrook, CamelPhat"},
+		{Title: "Cola (Extended Mix)", Artist: "CamelPhat & Elderbrook"},
+		{Title: "Solo", Artist: "Someone"},
+	}
+	source := []int{0, 0, 1, 1, 1, 0}
+
+	kept, keptSource := dedupeInputs(tracks, source, specs)
+	var got []string
+	for _, tr := range kept {
+		got = append(got, tr.Title)
+	}
+	want := []string{"Cola", "Solo", "Cola (Extended Mix)", "Solo"}
+	if !slices.Equal(got, want) {
+		t.Fatalf("dedupeInputs kept %q, want %q", got, want)
+	}
+	if !slices.Equal(keptSource, []int{0, 0, 1, 0}) {
+		t.Fatalf("sources = %v", keptSource)
+	}
+}
+
+func TestRunWritesPlaylistsWithCrossfades(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Location", "Outro"},
+		{"A", "Artist1", "124", "50", "8A", "/music/a.mp3", "0:12"},
+		{"B", "Artist2", "124", "55", "9A", "/music/b.mp3", "0:40"},
+		{"C", "Artist3", "125", "60", "10A", "/music/c.mp3", ""},
+	})
+
+	jsonOut := filepath.Join(dir, "set.json")
+	if err := run(context.Background(), []string{"--input", input, "--output", jsonOut, "--keep-all", "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err := os.ReadFile(jsonOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set playlistio.Playlist
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(set.Tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3", len(set.Tracks))
+	}
+	for i, tr := range set.Tracks {
+		last := i == len(set.Tracks)-1
+		if (tr.Crossfade == nil) != last || tr.Location == "" {
+			t.Fatalf("track %d: crossfade %v, location %q", i+1, tr.Crossfade, tr.Location)
+		}
+		if tr.Title == "A" && !last && *tr.Crossfade > 12 {
+			t.Fatalf("crossfade out of A (%.1fs) should fit its 12s outro", *tr.Crossfade)
+		}
+	}
+
+	m3uOut := filepath.Join(dir, "set.m3u")
+	if err := run(context.Background(), []string{"--input", input, "--output", m3uOut, "--keep-all", "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err = os.ReadFile(m3uOut)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m3u := string(data)
+	if !strings.HasPrefix(m3u, "#EXTM3U\n") || strings.Count(m3u, playlistio.CrossfadeDirective) != 2 || strings.Count(m3u, "/music/") != 3 {
+		t.Fatalf("unexpected M3U:\n%s", m3u)
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--format", "m3u8", "--keep-all", "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err = os.ReadFile(filepath.Join(dir, "in_magicmix.m3u8"))
+	if err != nil {
+		t.Fatalf("--format m3u8 should write <input>_magicmix.m3u8: %v", err)
+	}
+	if !strings.HasPrefix(string(data), "#EXTM3U\n") {
+		t.Fatalf("unexpected M3U8:\n%s", data)
+	}
+}
+
+// --output-format is the flag's name alongside --input-format; --format stays as an
+// alias of it.
+func TestRunOutputFormat(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+	})
+	if err := run(context.Background(), []string{"--input", input, "--output-format", "json", "--keep-all", "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "in_magicmix.json"))
+	if err != nil {
+		t.Fatalf("--output-format json should write <input>_magicmix.json: %v", err)
+	}
+	if !json.Valid(data) {
+		t.Fatalf("unexpected JSON:\n%s", data)
+	}
+}
+
+func TestRunRekordboxInAndOut(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "collection.xml")
+	var b strings.Builder
+	b.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<DJ_PLAYLISTS Version="1.0.0"><COLLECTION Entries="3">
+`)
+	for i, key := range []string{"Am", "Em", "C"} {
+		fmt.Fprintf(&b, `<TRACK TrackID="%d" Name="T%d" Artist="A%d" AverageBpm="124.00" Tonality="%s" Grouping="%d" Location="file://localhost/music/t%d.mp3"/>
+`, i+1, i, i, key, 5+i, i)
+	}
+	b.WriteString("</COLLECTION></DJ_PLAYLISTS>\n")
+	if err := os.WriteFile(input, []byte(b.String()), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--energy-field", "Grouping", "--keep-all", "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "collection_magicmix.xml"))
+	if err != nil {
+		t.Fatalf("an .xml input should write <input>_magicmix.xml: %v", err)
+	}
+	out := string(data)
+	if !strings.Contains(out, `Name="collection_magicmix"`) || strings.Count(out, `<TRACK Key=`) != 3 ||
+		!strings.Contains(out, `Location="file://localhost/music/t0.mp3"`) {
+		t.Fatalf("unexpected Rekordbox XML:\n%s", out)
+	}
+
+	csvOut := filepath.Join(dir, "set.csv")
+	if err := run(context.Background(), []string{"--input", input, "--output", csvOut, "--keep-all", "--seed", "1"}); err == nil ||
+		!strings.Contains(err.Error(), "--infer-energy") {
+		t.Fatalf("energy missing from the default Comments field should need --infer-energy, got %v", err)
+	}
+}
+
+func TestRunRadioWritesShowAndCueSheet(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "in.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Length"}}
+	for i := range 20 {
+		rows = append(rows, []string{
+			"T" + strconv.Itoa(i), "Artist" + strconv.Itoa(i), "124",
+			strconv.Itoa(40 + 5*(i%8)), strconv.Itoa(1+i%12) + "A", "4:00",
+		})
+	}
+	writeCSV(t, input, rows)
+	output := filepath.Join(dir, "show.csv")
+
+	args := []string{"radio", "--input", input, "--output", output, "--runtime", "1h", "--reset-every", "30m",
+		"--jingle", "0m", "--jingle", "30m/20s", "--energy", "first:12m=45", "--seed", "1"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	show := readCSV(t, output)
+	if len(show)-1 != 14 {
+		t.Fatalf("got %d tracks, want the 14 four-minute tracks that fit beside 30s of jingles", len(show)-1)
+	}
+
+	cues := readCSV(t, filepath.Join(dir, "show_cues.csv"))
+	if cues[1][2] != "jingle" || cues[1][0] != "0:00:00" {
+		t.Fatalf("first cue should be the opening jingle: %v", cues[1])
+	}
+	jingles, marked := 0, false
+	for _, c := range cues[1:] {
+		if c[2] == "jingle" {
+			jingles++
+		}
+		marked = marked || strings.HasSuffix(c[9], "reset at 0:30:00")
+	}
+	if jingles != 2 || !marked {
+		t.Fatalf("want 2 jingles and the half-hour reset noted:\n%v", cues)
+	}
+}
+
+func TestRunReusesCachedResult(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Track1", "Artist1", "120", "50", "1A"},
+		{"Track2", "Artist2", "121", "60", "2A"},
+		{"Track3", "Artist3", "122", "70", "3A"},
+		{"Track4", "Artist4", "123", "55", "4A"},
+	})
+	runTo := func(output string, extra ...string) {
+		t.Helper()
+		args := append([]string{"--input", input, "--output", output, "--seed", "41", "--strategy", "flow"}, extra...)
+		if err := run(context.Background(), args); err != nil {
+			t.Fatalf("run %v: %v", extra, err)
+		}
+	}
+	runTo(filepath.Join(dir, "first.csv"))
+
+	// Tamper with the cached result: a rerun that reads it writes the tampered order.
+	cache, err := libcache.Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := filepath.Glob(filepath.Join(cache.Dir(), "result-*.json"))
+	var key string
+	for _, e := range entries {
+		data, _ := os.ReadFile(e)
+		if strings.Contains(string(data), "Track4") {
+			key = strings.TrimSuffix(strings.TrimPrefix(filepath.Base(e), "result-"), ".json")
+		}
+	}
+	var set setResult
+	if key == "" || !cache.LoadResult(key, &set) {
+		t.Fatalf("no cached result among %v", entries)
+	}
+	slices.Reverse(set.Ordered)
+	cache.StoreResult(key, set)
+
+	runTo(filepath.Join(dir, "second.json"), "--output-format", "json")
+	data, err := os.ReadFile(filepath.Join(dir, "second.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc playlistio.Playlist
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if doc.Tracks[0].Title != set.Ordered[0].Title {
+		t.Errorf("second run opened with %q, want the cached %q", doc.Tracks[0].Title, set.Ordered[0].Title)
+	}
+
+	runTo(filepath.Join(dir, "third.csv"), "--no-cache")
+	first, third := readCSV(t, filepath.Join(dir, "first.csv")), readCSV(t, filepath.Join(dir, "third.csv"))
+	if !slices.EqualFunc(first, third, slices.Equal) {
+		t.Errorf("--no-cache run differs from the original:\n%v\n%v", first, third)
+	}
+}
+
+func TestRunSplitsIntoSets(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 12 {
+		rows = append(rows, []string{fmt.Sprintf("Track%d", i), fmt.Sprintf("Artist%d", i),
+			strconv.Itoa(100 + 3*i), strconv.Itoa(30 + 5*i), fmt.Sprintf("%dA", i%12+1)})
+	}
+	writeCSV(t, input, rows)
+
+	output := filepath.Join(dir, "out.csv")
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--set-size", "4", "--seed", "7"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	seen := map[string]bool{}
+	for i := 1; i <= 3; i++ {
+		set := readCSV(t, filepath.Join(dir, fmt.Sprintf("out_set%d.csv", i)))
+		for _, row := range set[1:] {
+			seen[row[0]] = true
+		}
+	}
+	if len(seen) != 12 {
+		t.Errorf("3 sets hold %d distinct tracks, want all 12", len(seen))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "out_set4.csv")); err == nil {
+		t.Error("wrote a fourth set for 12 tracks at 4 per set")
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--sets", "2", "--limit", "5"}); err == nil {
+		t.Error("--sets with --limit: want an error")
+	}
+}
+
+func TestRunResidencyKeepsNightsFresh(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Length"}}
+	for i := range 15 {
+		rows = append(rows, []string{fmt.Sprintf("Track%d", i), fmt.Sprintf("Artist%d", i),
+			strconv.Itoa(110 + i), strconv.Itoa(30 + 4*i), fmt.Sprintf("%dA", i%12+1), "5:00"})
+	}
+	writeCSV(t, input, rows)
+
+	output := filepath.Join(dir, "out.csv")
+	args := []string{"residency", "--input", input, "--output", output, "--nights", "3", "--night-size", "25m", "--seed", "7"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	seen := map[string]bool{}
+	for i := 1; i <= 3; i++ {
+		night := readCSV(t, filepath.Join(dir, fmt.Sprintf("out_night%d.csv", i)))
+		if len(night)-1 != 5 {
+			t.Fatalf("night %d has %d tracks, want the 5 five-minute tracks that fill 25m", i, len(night)-1)
+		}
+		for _, row := range night[1:] {
+			if seen[row[0]] {
+				t.Fatalf("night %d repeats %s", i, row[0])
+			}
+			seen[row[0]] = true
+		}
+	}
+
+	args = []string{"residency", "--input", input, "--nights", "2", "--night-size", "5", "--repeat-tolerance", "1.5"}
+	if err := run(context.Background(), args); err == nil {
+		t.Error("--repeat-tolerance 1.5: want an error")
+	}
+}
+
+func TestRunFiltersAtLoad(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Slow", "Artist1", "90", "50", "1A"},
+		{"Fast", "Artist2", "140", "60", "2A"},
+		{"Loud", "Artist3", "122", "95", "3A"},
+		{"OffKey", "Artist4", "122", "60", "9B"},
+		{"Banned", "The Banned Band", "122", "60", "2A"},
+		{"Featured", "Artist7 feat. BANNED BÄND", "122", "60", "2A"},
+		{"Keep1", "Artist5", "120", "55", "2A"},
+		{"Keep2", "Artist6", "124", "65", "3A"},
+	})
+
+	args := []string{"--input", input, "--output", output, "--keep-all",
+		"--bpm-min", "100", "--bpm-max", "130", "--energy-max", "90", "--keys", "1A, 2A,3A",
+		"--exclude-artist", "banned band"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var titles []string
+	for _, row := range readCSV(t, output)[1:] {
+		titles = append(titles, row[0])
+	}
+	slices.Sort(titles)
+	if !slices.Equal(titles, []string{"Keep1", "Keep2"}) {
+		t.Errorf("kept %v, want Keep1 and Keep2", titles)
+	}
+	dropped := readCSV(t, droppedPath(output))
+	if len(dropped) != 7 { // header + 6 filtered
+		t.Errorf("dropped sidecar has %d rows, want 7: %v", len(dropped), dropped)
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--keys", "13Z"}); err == nil {
+		t.Error("--keys 13Z: want an error")
+	}
+}
+
+func TestRunHonorsColorsAndPriorities(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Priority", "Color"},
+		{"DoNotPlay", "Artist1", "120", "50", "1A", "5", "Red"},
+		{"Filler", "Artist2", "121", "55", "2A", "1", ""},
+		{"Promoted", "Artist3", "122", "60", "3A", "1", "Green"},
+		{"Unrated", "Artist4", "123", "65", "4A", "", ""},
+		{"Favorite", "Artist5", "124", "70", "5A", "4", "#FF007F"},
+	})
+
+	args := []string{"--input", input, "--output", output, "--keep-all",
+		"--exclude-color", "red", "--min-priority", "2", "--color-priority", "green=5"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var titles []string
+	for _, row := range readCSV(t, output)[1:] {
+		titles = append(titles, row[0])
+	}
+	slices.Sort(titles)
+	if want := []string{"Favorite", "Promoted", "Unrated"}; !slices.Equal(titles, want) {
+		t.Errorf("kept %v, want %v", titles, want)
+	}
+
+	for _, bad := range [][]string{{"--exclude-color", "beige"}, {"--min-priority", "6"}, {"--color-priority", "green"},
+		{"--color-priority", "green=9"}} {
+		if err := run(context.Background(), append([]string{"--input", input, "--output", output}, bad...)); err == nil {
+			t.Errorf("%v: want an error", bad)
+		}
+	}
+}
+
+func TestRunVariesFromPreviousSet(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i := range 10 {
+		rows = append(rows, []string{fmt.Sprintf("Track%d", i), fmt.Sprintf("Artist%d", i),
+			strconv.Itoa(118 + i), strconv.Itoa(40 + 5*i), fmt.Sprintf("%dA", i%12+1)})
+	}
+	writeCSV(t, input, rows)
+
+	first := filepath.Join(dir, "first.csv")
+	base := []string{"--input", input, "--strategy", "flow", "--keep-all", "--seed", "3"}
+	if err := run(context.Background(), append(base, "--output", first)); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	second := filepath.Join(dir, "second.csv")
+	if err := run(context.Background(), append(base, "--output", second, "--variety-from", first, "--variety-weight", "5")); err != nil {
+		t.Fatalf("run with --variety-from returned error: %v", err)
+	}
+	a, b := readCSV(t, first)[1:], readCSV(t, second)[1:]
+	if len(a) != len(b) {
+		t.Fatalf("got %d tracks, want %d", len(b), len(a))
+	}
+	pairs := map[[2]string]bool{}
+	for i := 1; i < len(a); i++ {
+		pairs[[2]string{a[i-1][0], a[i][0]}], pairs[[2]string{a[i][0], a[i-1][0]}] = true, true
+	}
+	kept := 0
+	for i := 1; i < len(b); i++ {
+		if pairs[[2]string{b[i-1][0], b[i][0]}] {
+			kept++
+		}
+	}
+	if kept > len(a)/2 || b[0][0] == a[0][0] {
+		t.Errorf("second set keeps %d of %d transitions and opens on %s (first opened on %s)", kept, len(a)-1, b[0][0], a[0][0])
+	}
+
+	if err := run(context.Background(), append(base, "--output", second, "--variety-from", first, "--variety-weight", "-1")); err == nil {
+		t.Error("--variety-weight -1: want an error")
+	}
+}
+
+func TestRunReplanFollowsFeedback(t *testing.T) {
+	dir := t.TempDir()
+	setPath := filepath.Join(dir, "set.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Length"}}
+	for i := range 8 {
+		rows = append(rows, []string{fmt.Sprintf("Track%d", i), fmt.Sprintf("Artist%d", i),
+			strconv.Itoa(120 + i), strconv.Itoa(30 + 8*i), fmt.Sprintf("%dA", i%12+1), "4:00"})
+	}
+	writeCSV(t, setPath, rows)
+	feedback := filepath.Join(dir, "feedback.txt")
+	if err := os.WriteFile(feedback, []byte("# the floor tonight\n0:05:00,good\n9m bad\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out.csv")
+	args := []string{"replan", "--set", setPath, "--feedback", feedback, "--output", output, "--seed", "3"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	got := readCSV(t, output)[1:]
+	if len(got) != 8 {
+		t.Fatalf("re-planned set has %d tracks, want 8", len(got))
+	}
+	seen := map[string]bool{}
+	for i, row := range got {
+		if i <= 2 && row[0] != rows[i+1][0] {
+			t.Errorf("position %d is %s; the tracks played through 9m should stay as %s", i+1, row[0], rows[i+1][0])
+		}
+		if seen[row[0]] {
+			t.Errorf("%s plays twice", row[0])
+		}
+		seen[row[0]] = true
+	}
+
+	for _, bad := range [][]string{{"--elapsed", "6m"}, {"--crowd-weight", "-1"}} {
+		args := append([]string{"replan", "--set", setPath, "--feedback", feedback, "--output", output}, bad...)
+		if err := run(context.Background(), args); err == nil {
+			t.Errorf("%v: want an error", bad)
+		}
+	}
+	if _, _, err := parseReaction("5m meh"); err == nil {
+		t.Error(`parseReaction("5m meh"): want an error`)
+	}
+}
+
+func TestRunReplanWeavesRequests(t *testing.T) {
+	dir := t.TempDir()
+	setPath, libPath := filepath.Join(dir, "set.csv"), filepath.Join(dir, "library.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Length"}}
+	for i := range 6 {
+		rows = append(rows, []string{fmt.Sprintf("Set%d", i), "Resident", "124", strconv.Itoa(40 + 5*i), "8A", "4:00"})
+	}
+	writeCSV(t, setPath, rows)
+	writeCSV(t, libPath, append(slices.Clone(rows),
+		[]string{"Wanted", "Guest", "124", "55", "8A", "4:00"},
+		[]string{"Clash", "Guest", "150", "55", "2B", "4:00"}))
+	feedback := filepath.Join(dir, "feedback.txt")
+	requests := filepath.Join(dir, "requests.txt")
+	if err := os.WriteFile(feedback, []byte("1m good\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(requests, []byte("# from the floor\nWanted|Guest\nClash\nNowhere\nSet0\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out.csv")
+	args := []string{"replan", "--set", setPath, "--library", libPath, "--feedback", feedback, "--requests", requests,
+		"--output", output, "--seed", "3"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var titles []string
+	for _, row := range readCSV(t, output)[1:] {
+		titles = append(titles, row[0])
+	}
+	if i := slices.Index(titles, "Wanted"); i < 1 || i > strategy.DefaultRequestWindow {
+		t.Errorf("re-planned set %v: want the request woven in soon after the track playing", titles)
+	}
+	if slices.Contains(titles, "Clash") {
+		t.Errorf("re-planned set %v: the clashing request should be declined", titles)
+	}
+
+	q := newRequestQueue(requests)
+	if n, err := q.poll(); err != nil || n != 4 {
+		t.Fatalf("poll = %d, %v; want 4 requests", n, err)
+	}
+	if n, _ := q.poll(); n != 0 {
+		t.Errorf("second poll queued %d requests again", n)
+	}
+	lib := readCSV(t, libPath)
+	var set, pool []track.Track
+	for _, row := range lib[1:] {
+		k, _ := track.ParseKey(row[4])
+		bpm, _ := strconv.ParseFloat(row[2], 64)
+		energy, _ := strconv.Atoi(row[3])
+		tr := track.Track{Title: row[0], Artist: row[1], BPM: bpm, Energy: energy, Key: k}
+		pool = append(pool, tr)
+		if strings.HasPrefix(tr.Title, "Set") {
+			set = append(set, tr)
+		}
+	}
+	got, report := q.weave(set, pool, 0, strategy.DefaultRequestWindow)
+	if len(got) != len(set)+1 || len(report) != 4 {
+		t.Fatalf("weave = %d tracks, report %q; want one request placed and four lines", len(got), report)
+	}
+	for i, want := range []string{"plays at #", "no harmonically sensible spot", "not in the library", "already played at #1"} {
+		if !strings.Contains(report[i], want) {
+			t.Errorf("report[%d] = %q; want it to mention %q", i, report[i], want)
+		}
+	}
+}
+
+func TestExplainWhyNot(t *testing.T) {
+	cand := func(title, category string, key, energy float64) strategy.DecisionCandidate {
+		return strategy.DecisionCandidate{Title: title, Artist: "A", Category: category, KeyCost: key, EnergyCost: energy,
+			Total: key + energy}
+	}
+	decisions := []strategy.Decision{
+		{Position: 0, Candidates: []strategy.DecisionCandidate{{Title: "Opener", Artist: "A"}}},
+		{Position: 1, CategoryOrder: []string{"step+1", "other"}, Chosen: 0, Candidates: []strategy.DecisionCandidate{
+			cand("Winner", "step+1", 0, 1), cand("Close", "step+1", 0, 3), cand("Far", "other", 2, 0)}},
+		{Position: 2, CategoryOrder: []string{"step+1", "other"}, Chosen: 0, Candidates: []strategy.DecisionCandidate{
+			cand("Close", "step+1", 0, 1), cand("Far", "other", 2, 0)}},
+		{Position: 3, CategoryOrder: []string{"step+1", "other"}, Chosen: 0, Candidates: []strategy.DecisionCandidate{
+			cand("Far", "other", 2, 0)}},
+	}
+	for _, tc := range []struct {
+		ref      string
+		position int
+		want     string
+	}{
+		{"Close", 2, "scored 2.00 worse:\n  energy     +2.00"},
+		{"Far", 2, "ranks behind the winner's (step+1)"},
+		{"Winner", 2, "It was chosen here."},
+		{"Opener", 3, "already placed, at position 1"},
+		{"Missing", 2, "never placed it"},
+	} {
+		match, err := parseTrackRef(tc.ref)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var out strings.Builder
+		if err := explainWhyNot(&out, decisions, tc.position, match); err != nil {
+			t.Fatalf("%s at %d: %v", tc.ref, tc.position, err)
+		}
+		if !strings.Contains(out.String(), tc.want) {
+			t.Errorf("%s at %d:\n%s\nwant it to say %q", tc.ref, tc.position, out.String(), tc.want)
+		}
+	}
+	if err := explainWhyNot(io.Discard, decisions, 9, func(track.Track) bool { return true }); err == nil {
+		t.Error("position 9 of a 4-track log: want an error")
+	}
+}
+
+func TestParseNormalize(t *testing.T) {
+	cases := []struct {
+		spec  string
+		dedup bool
+		want  string
+	}{
+		{"", false, ""},
+		{"", true, "dedup"},
+		{"names,bpm", false, "bpm,names"},
+		{"all,-dedup", false, "bpm,energy,keys,names"},
+		{"all,-dedup", true, "bpm,energy,keys,names"},
+		{" Energy ", false, "energy"},
+	}
+	for _, c := range cases {
+		steps, err := parseNormalize(c.spec, c.dedup)
+		if err != nil {
+			t.Fatalf("parseNormalize(%q): %v", c.spec, err)
+		}
+		var names []string
+		for _, s := range steps {
+			names = append(names, s.name)
+		}
+		if got := strings.Join(names, ","); got != c.want {
+			t.Errorf("parseNormalize(%q, %v) = %s, want %s", c.spec, c.dedup, got, c.want)
+		}
+	}
+	if _, err := parseNormalize("bpm,tidy", false); err == nil {
+		t.Error("parseNormalize accepted an unknown step")
+	}
+}
+
+func TestRunNormalizesBeforeSorting(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "set.json")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Halftime", "Norm1", "62", "5", "1A"},
+		{"  Spaced   Out ", "Norm2", "124", "6", "1A"},
+		{"Spaced Out", "Norm2", "124", "6", "1A"},
+		{"Steady", "Norm3", "126", "7", "2A/2A"},
+	})
+
+	args := []string{"--input", input, "--output", output, "--keep-all", "--normalize", "all"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	data, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set playlistio.Playlist
+	if err := json.Unmarshal(data, &set); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	got := map[string]playlistio.Track{}
+	for _, tr := range set.Tracks {
+		got[tr.Title] = tr
+	}
+	if len(got) != 3 {
+		t.Fatalf("wrote %v, want the spaced copy dropped as a duplicate", set.Tracks)
+	}
+	if tr := got["Halftime"]; tr.BPM != "124" || tr.Energy != 50 {
+		t.Errorf("Halftime = %s BPM at energy %d, want 124 at 50", tr.BPM, tr.Energy)
+	}
+	if tr := got["Steady"]; tr.Key != "2A" {
+		t.Errorf("Steady is in %s, want its repeated key change dropped", tr.Key)
+	}
+}
+
+func TestRunStrictCamelot(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "library.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	for i, spec := range []string{"120,31,8A", "122,41,7A", "122,52,10B", "121,24,10B", "121,75,7A", "123,85,9A",
+		"124,76,10B", "122,24,8A", "122,79,9A", "123,74,10B", "121,42,9B", "121,23,9B"} {
+		rows = append(rows, append([]string{fmt.Sprintf("Strict%02d", i), fmt.Sprintf("Artist%d", i)}, strings.Split(spec, ",")...))
+	}
+	writeCSV(t, input, rows)
+	output := filepath.Join(dir, "out.csv")
+	diagonals := func(args ...string) int {
+		t.Helper()
+		if err := run(context.Background(), append([]string{"--input", input, "--output", output, "--seed", "2"}, args...)); err != nil {
+			t.Fatalf("run returned error: %v", err)
+		}
+		got, n := readCSV(t, output)[1:], 0
+		for k := 1; k < len(got); k++ {
+			a, _ := track.ParseKey(got[k-1][4])
+			b, _ := track.ParseKey(got[k][4])
+			if strategy.KeyTransition(a, b).Diagonal() {
+				n++
+			}
+		}
+		return n
+	}
+	if n := diagonals(); n == 0 {
+		t.Fatal("the library mixes without a diagonal move anyway; the test proves nothing")
+	}
+	if n := diagonals("--strict-camelot"); n > 0 {
+		t.Errorf("%d diagonal move(s) under --strict-camelot; want none", n)
+	}
+}
+
+func TestRunKeepsInputEncoding(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "export.csv")
+	data := "Title,Artist,BPM,Energy,Key\nD\xe9j\xe0 Vu,Beyonc\xe9,106,80,4A\nJe t'aime,Ga\xebl,108,60,5A\n"
+	if err := os.WriteFile(input, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(dir, "out.csv")
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--seed", "1"}); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	got, err := os.ReadFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, row := range []string{"D\xe9j\xe0 Vu,Beyonc\xe9,106,80,4A\n", "Je t'aime,Ga\xebl,108,60,5A\n"} {
+		if !strings.Contains(string(got), row) {
+			t.Errorf("output %q lacks %q in Windows-1252", got, row)
+		}
+	}
+
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--encoding", "utf-8"}); err == nil {
+		t.Error("--encoding utf-8 over Windows-1252 bytes: want an error")
+	}
+	if err := run(context.Background(), []string{"--input", input, "--output", output, "--encoding", "ebcdic"}); err == nil {
+		t.Error("--encoding ebcdic: want an error")
+	}
+}
+
+func TestRunStyleLearnsAndLeans(t *testing.T) {
+	dir := t.TempDir()
+	played := filepath.Join(dir, "played.csv")
+	writeCSV(t, played, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "Length"},
+		{"Open", "A1", "122", "40", "8A", "5:00"},
+		{"Lift", "A2", "123", "50", "9A", "5:00"},
+		{"Drop", "A3", "124", "60", "10A", "5:00"},
+	})
+	feedback := filepath.Join(dir, "feedback.txt")
+	if err := os.WriteFile(feedback, []byte("7m bad\n12m good\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	stylePath := filepath.Join(os.Getenv(config.DirEnv), config.StyleFile)
+	t.Cleanup(func() { _ = os.Remove(stylePath) })
+	args := []string{"style", "--reset", "--learn", played, "--feedback", feedback, "--favor", "+2", "--dislike", "Drop|A3>Lift"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("style returned error: %v", err)
+	}
+	data, err := os.ReadFile(stylePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var style strategy.Style
+	if err := json.Unmarshal(data, &style); err != nil {
+		t.Fatal(err)
+	}
+	if style.Sets != 1 || style.KeyMoves["+1"] != 1 || !slices.Equal(style.Favorites, []string{"+2"}) ||
+		!slices.Equal(style.Disliked, []strategy.Adjacency{{From: "Open|A1", To: "Lift|A2"}, {From: "Drop|A3", To: "Lift"}}) {
+		t.Fatalf("style = %+v", style)
+	}
+
+	// Lift may follow neither Open nor Drop, so the style opens with it.
+	output := filepath.Join(dir, "out.csv")
+	base := []string{"--input", played, "--output", output, "--seed", "1", "--keep-all"}
+	if err := run(context.Background(), base); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if got := readCSV(t, output)[1][0]; got != "Lift" {
+		t.Errorf("with the style the set opens with %s, want Lift", got)
+	}
+	if err := run(context.Background(), append(base, "--no-style")); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if got := readCSV(t, output)[2][0]; got != "Lift" {
+		t.Errorf("with --no-style %s plays second, want Lift between the others", got)
+	}
+	// A changed style is a different result, not the cached one.
+	if err := run(context.Background(), []string{"style", "--reset"}); err != nil {
+		t.Fatalf("style returned error: %v", err)
+	}
+	if err := run(context.Background(), base); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	if got := readCSV(t, output)[2][0]; got != "Lift" {
+		t.Errorf("after --reset %s plays second, want Lift between the others", got)
+	}
+
+	for _, bad := range [][]string{{"--feedback", feedback}, {"--favor", "+5"}, {"--dislike", "Open"}} {
+		if err := run(context.Background(), append([]string{"style"}, bad...)); err == nil {
+			t.Errorf("style %v: want an error", bad)
+		}
+	}
+}
+
+func TestRunSubsetKeepsByObjective(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "library.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Popularity"}}
+	for i := range 10 {
+		rows = append(rows, []string{fmt.Sprintf("Sub%d", i), fmt.Sprintf("Artist%d", i), "124", strconv.Itoa(40 + 3*i),
+			fmt.Sprintf("%dA", i%4+6), strconv.Itoa(10 * i)})
+	}
+	writeCSV(t, input, rows)
+	output := filepath.Join(dir, "out.csv")
+	args := []string{"--input", input, "--output", output, "--seed", "2", "--keep-all", "--limit", "3", "--subset", "popularity"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var got []string
+	for _, row := range readCSV(t, output)[1:] {
+		got = append(got, row[0])
+	}
+	slices.Sort(got)
+	if !slices.Equal(got, []string{"Sub7", "Sub8", "Sub9"}) {
+		t.Errorf("--subset popularity kept %v; want the three most popular", got)
+	}
+	if _, err := os.Stat(droppedPath(output)); err != nil {
+		t.Errorf("the tracks --subset left out should be listed: %v", err)
+	}
+
+	for _, bad := range [][]string{{"--subset", "popularity"}, {"--limit", "3", "--subset", "vibes"}} {
+		if err := run(context.Background(), append([]string{"--input", input, "--output", output}, bad...)); err == nil {
+			t.Errorf("%v: want an error", bad)
+		}
+	}
+}
+
+func TestRunPoolsPlaceOpenersPeakAndClosers(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "library.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key", "Length", "Tags"}}
+	for i := range 20 {
+		tag := ""
+		switch {
+		case i < 3:
+			tag = "opener"
+		case i >= 17:
+			tag = "closer"
+		}
+		rows = append(rows, []string{fmt.Sprintf("Pool%02d", i), fmt.Sprintf("Artist%d", i), strconv.Itoa(120 + i%4),
+			strconv.Itoa(20 + 4*i), fmt.Sprintf("%dA", i%3+7), "4:00", tag})
+	}
+	writeCSV(t, input, rows)
+	output := filepath.Join(dir, "out.csv")
+	base := []string{"--input", input, "--output", output, "--seed", "5", "--keep-all", "--pool", "open=tag:opener",
+		"--pool", "close=tag:closer"}
+	if err := run(context.Background(), append(base, "--pool", "peak=energy>=60")); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	got := readCSV(t, output)[1:]
+	if len(got) != 20 {
+		t.Fatalf("got %d tracks, want 20", len(got))
+	}
+	for _, pos := range []int{0, 1} {
+		if got[pos][0] != "Pool00" && got[pos][0] != "Pool01" {
+			t.Errorf("position %d is %s; want one of the two calmest openers", pos+1, got[pos][0])
+		}
+	}
+	for _, pos := range []int{18, 19} {
+		if got[pos][0] != "Pool17" && got[pos][0] != "Pool18" {
+			t.Errorf("position %d is %s; want one of the first two closers", pos+1, got[pos][0])
+		}
+	}
+
+	err := run(context.Background(), append(base, "--pool", "close=tag:encore", "--limit", "10"))
+	if err == nil || !strings.Contains(err.Error(), `close pool "tag:encore": 0 track(s) match, the set needs 1`) {
+		t.Errorf("run with an empty pool = %v; want it to fail naming the pool", err)
+	}
+	if err := run(context.Background(), append(base, "--pool", "finale=tag:closer")); err == nil {
+		t.Error("--pool finale=...: want an error")
+	}
+}
+
+func TestRunStagesOrdersEachStage(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	rows := [][]string{{"Title", "Artist", "BPM", "Energy", "Key"}}
+	keys := []string{"8A", "9A", "3B", "10A", "8B", "4A", "11A", "9B"}
+	for i, k := range keys {
+		rows = append(rows, []string{fmt.Sprintf("Staged%d", i), fmt.Sprintf("StageArtist%d", i),
+			strconv.Itoa(120 + i), strconv.Itoa(80 - i*8), k})
+	}
+	writeCSV(t, input, rows)
+
+	args := []string{"--input", input, "--output", output, "--keep-all", "--seed", "1", "--stages", "rotation:3,flow"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	written := readCSV(t, output)[1:]
+	if len(written) != len(keys) {
+		t.Fatalf("wrote %d tracks, want %d", len(written), len(keys))
+	}
+	for _, row := range written[:3] {
+		if energy, _ := strconv.Atoi(row[3]); energy > 40 {
+			t.Errorf("the warm-up stage plays %v; want the three quietest tracks first", written[:3])
+			break
+		}
+	}
+
+	args = append(args, "--strategy", "flow")
+	if err := run(context.Background(), args); err == nil || !strings.Contains(err.Error(), "not both") {
+		t.Errorf("--stages with --strategy: err = %v", err)
+	}
+}
+
+func TestRunBroadcastSafe(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key", "License", "Regions"},
+		{"OnAir1", "Cleared1", "120", "50", "1A", "cleared", ""},
+		{"OnAir2", "Cleared2", "121", "55", "1A", "licensed", "US;CA"},
+		{"OnAir3", "Pending", "122", "60", "2A", "pending", ""},
+		{"OnAir4", "Unknown", "122", "60", "2A", "", ""},
+		{"OnAir5", "Abroad", "123", "65", "2A", "cleared", "GB"},
+	})
+
+	args := []string{"--input", input, "--output", output, "--keep-all", "--broadcast-safe", "--region", "us"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var titles []string
+	for _, row := range readCSV(t, output)[1:] {
+		titles = append(titles, row[0])
+	}
+	slices.Sort(titles)
+	if !slices.Equal(titles, []string{"OnAir1", "OnAir2"}) {
+		t.Errorf("wrote %v, want only the tracks cleared in the US", titles)
+	}
+	dropped := readCSV(t, droppedPath(output))
+	var reasons []string
+	for _, row := range dropped[1:] {
+		reasons = append(reasons, strings.Join(row, ","))
+	}
+	joined := strings.Join(reasons, "\n")
+	for _, want := range []string{`license "pending"`, "no license on record", "--region US"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("dropped sidecar %q doesn't give the reason %q", joined, want)
+		}
+	}
+}
+
+func TestRunDedupKeepsOneCopy(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "tracks.csv")
+	output := filepath.Join(dir, "out.csv")
+	writeCSV(t, input, [][]string{
+		{"Title", "Artist", "BPM", "Energy", "Key"},
+		{"Song", "Artist1", "120", "50", "1A"},
+		{"Other", "Artist2", "121", "60", "2A"},
+		{"Song - Remastered 2011", "Artist1", "120", "50", "1A"},
+		{"Song (Extended Mix)", "Artist1", "122", "70", "3A"},
+		{"Third", "Artist1", "122", "65", "2A"},
+	})
+
+	args := []string{"--input", input, "--output", output, "--keep-all", "--keep-versions", "--dedup", "--artist-gap", "2"}
+	if err := run(context.Background(), args); err != nil {
+		t.Fatalf("run returned error: %v", err)
+	}
+	var titles []string
+	for _, row := range readCSV(t, output)[1:] {
+		titles = append(titles, row[0])
+	}
+	if len(titles) != 4 || slices.Contains(titles, "Song - Remastered 2011") || !slices.Contains(titles, "Song (Extended Mix)") {
+		t.Errorf("wrote %v, want every track but the remaster", titles)
+	}
+	dropped := readCSV(t, droppedPath(output))
+	if len(dropped) != 2 || !strings.Contains(strings.Join(dropped[1], ","), "duplicate of") {
+		t.Errorf("dropped sidecar = %v, want the remaster as a duplicate", dropped)
+	}
+}
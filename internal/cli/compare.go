@@ -0,0 +1,349 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"maps"
+	"math"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// comparison is one strategy's showing in `magicmix compare`.
+type comparison struct {
+	name    string
+	result  strategy.Result
+	rubric  eval.Score
+	mix     float64
+	elapsed time.Duration
+	limit   time.Duration // the time the strategy was given; 0 when unlimited
+
+	// perRun and mixRuns hold the rubric total per transition and the mix score of
+	// each --runs sort, the first being result's.
+	perRun, mixRuns []float64
+}
+
+// runCompare handles `magicmix compare ...`: it sorts one library with every
+// registered strategy (or those --strategies names) from the same seed, scores each
+// ordering with the eval rubric, and prints them side by side, so strategies can be
+// chosen and tuned on the same terms.
+func runCompare(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix compare", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input library: CSV, JSON, Rekordbox XML, or a folder of audio files")
+	inputFormatName := fs.String("input-format", "", "Input format (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	strategies := fs.String("strategies", "", "Comma-separated strategies to compare (default: every registered strategy)")
+	seedFlag := fs.Int64("seed", 0, "Seed every strategy sorts from (default: derived from the input)")
+	runs := fs.Int("runs", 1, "Sort with each strategy this many times, each from its own seed, and report each score's mean, spread, and 95% confidence interval")
+	outputPath := fs.String("output", "", "Also write each ordering to this path with _<strategy> added, e.g. set_flow.csv")
+	formatName := fs.String("output-format", "", "Format for --output (default: from its extension)")
+	timeout := fs.Duration("timeout", 0, "Stop each strategy after this long and score what it has (e.g. 30s)")
+	budgetSpec := fs.String("budget", "", "Per-strategy time limits overriding --timeout, e.g. default=1s,anneal=20s")
+	annealBudget := fs.String("anneal-budget", "", "Search budget for the anneal strategy: iterations or a duration")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	tempoMatchName := fs.String("tempo-match", "direct", "Tempo relationships that count as close: direct, half-double, or three-four")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix compare --input FILE [--strategies flow,anneal] [options]\n\n")
+		_, _ = fmt.Fprintf(w, "Sort a library with each strategy from the same seed and score every ordering\n")
+		_, _ = fmt.Fprintf(w, "with the evaluate rubric: total, key, BPM, and energy penalties, the mix score,\n")
+		_, _ = fmt.Fprintf(w, "and how long each took. Lower is better.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *runs < 1 {
+		return errors.New("runs must be at least 1")
+	}
+	names, err := compareStrategies(*strategies)
+	if err != nil {
+		return err
+	}
+	budgets, err := parseBudgets(*budgetSpec)
+	if err != nil {
+		return err
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	tempoMatch, err := track.ParseTempoMatch(*tempoMatchName)
+	if err != nil {
+		return fmt.Errorf("--tempo-match: %w", err)
+	}
+	ctx = strategy.WithTempoMatch(ctx, tempoMatch)
+	if *annealBudget != "" {
+		budget, err := parseAnnealBudget(*annealBudget)
+		if err != nil {
+			return err
+		}
+		ctx = strategy.WithAnnealBudget(ctx, budget)
+	}
+	outFormat, err := outputFormat(*formatName, *outputPath)
+	if err != nil {
+		return err
+	}
+
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, libraryFormat{name: inputName, energyField: *energyField})
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	warnings := append(playlist.Warnings, energyWarnings...)
+	defer func() { printWarnings(warnings) }()
+	for _, name := range slices.Sorted(maps.Keys(budgets)) {
+		if !slices.Contains(names, name) {
+			warnings = append(warnings, fmt.Sprintf("--budget gives %s a limit, but it isn't being compared", name))
+		}
+	}
+	if len(playlist.Tracks) <= 1 {
+		fmt.Printf("File %s contains %d track(s) - nothing to compare\n", *inputPath, len(playlist.Tracks))
+		return nil
+	}
+
+	seed, seedSource := *seedFlag, ""
+	if seed == 0 {
+		if seed, err = inputSeed(ctx, []string{*inputPath}, "compare", *energyField); err != nil {
+			return err
+		}
+		seedSource = " (derived from the input)"
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return err
+	}
+	ctx = strategy.WithGenres(strategy.WithSeed(ctx, seed), genres)
+	fmt.Printf("Comparing %d strategies on %d tracks from %s, seed %d%s\n", len(names), len(playlist.Tracks), *inputPath,
+		seed, seedSource)
+	if *runs > 1 {
+		fmt.Printf("Running each strategy %d times; the table shows the first run, the seed above\n", *runs)
+	}
+	seeds := candidateSeeds(seed, *runs)
+
+	results := make([]comparison, 0, len(names))
+	for _, name := range names {
+		sorter, err := strategy.Get(name)
+		if err != nil {
+			return err
+		}
+		limit := *timeout
+		if b, ok := budgets[name]; ok {
+			limit = b
+		}
+		sortCtx, cancel := maybeWithTimeout(ctx, limit)
+		start := time.Now()
+		res, err := strategy.Sort(sortCtx, sorter, playlist.Tracks)
+		elapsed := time.Since(start)
+		if cancel != nil {
+			cancel()
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		fmt.Printf("  %s: %d tracks in %s\n", name, len(res.Ordered), elapsed.Round(time.Millisecond))
+		for _, w := range res.Warnings {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", name, w))
+		}
+		c := comparison{name: name, result: res, elapsed: elapsed, limit: limit,
+			rubric: eval.EvaluateWith(res.Ordered, eval.Options{TempoMatch: tempoMatch}),
+			mix:    strategy.ScoreMix(res.Ordered).Total}
+		c.perRun, c.mixRuns = []float64{c.perTransition()}, []float64{c.mix}
+		for _, s := range seeds[1:] {
+			runCtx, cancel := maybeWithTimeout(strategy.WithSeed(ctx, s), limit)
+			rerun, err := strategy.Sort(runCtx, sorter, playlist.Tracks)
+			if cancel != nil {
+				cancel()
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			again := comparison{rubric: eval.EvaluateWith(rerun.Ordered, eval.Options{TempoMatch: tempoMatch})}
+			c.perRun = append(c.perRun, again.perTransition())
+			c.mixRuns = append(c.mixRuns, strategy.ScoreMix(rerun.Ordered).Total)
+		}
+		results = append(results, c)
+
+		if *outputPath != "" {
+			path := suffixedPath(withFormatExt(*outputPath, outFormat), "_"+name)
+			outputWarnings, err := saveOutput(ctx, path, outFormat, csvio.Playlist{
+				Header:   playlist.Header,
+				CRLF:     playlist.CRLF,
+				Encoding: playlist.Encoding,
+				Tracks:   res.Ordered,
+			})
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			warnings = append(warnings, outputWarnings...)
+		}
+	}
+	printComparison(results)
+	if *runs > 1 {
+		printRunSpread(results)
+	}
+	if *outputPath != "" {
+		fmt.Printf("Wrote each ordering beside %s, named for its strategy\n", *outputPath)
+	}
+	return nil
+}
+
+// compareStrategies resolves --strategies: the named strategies, checked against the
+// registry, or every registered one.
+func compareStrategies(spec string) ([]string, error) {
+	if strings.TrimSpace(spec) == "" {
+		return strategy.Names(), nil
+	}
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" || slices.Contains(names, name) {
+			continue
+		}
+		if _, ok := strategy.Describe(name); !ok {
+			return nil, fmt.Errorf("--strategies: unknown strategy %q (want %s)", name, strings.Join(strategy.Names(), ", "))
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return nil, errors.New("--strategies: name at least one strategy")
+	}
+	return names, nil
+}
+
+// perTransition is the rubric total per transition, which compares orderings that
+// kept different numbers of tracks. An ordering with no transitions has nothing to
+// compare and ranks last.
+func (c comparison) perTransition() float64 {
+	if n := len(c.rubric.Transitions); n > 0 {
+		return c.rubric.Total / float64(n)
+	}
+	return math.Inf(1)
+}
+
+// printComparison prints one row per strategy, best rubric total per transition first.
+func printComparison(results []comparison) {
+	slices.SortStableFunc(results, func(a, b comparison) int {
+		return cmp.Compare(a.perTransition(), b.perTransition())
+	})
+	fmt.Printf("\n%-12s %6s %9s %8s %8s %8s %8s %7s %9s\n", "strategy", "tracks", "per trans", "total", "key", "bpm",
+		"energy", "mix", "time")
+	for _, c := range results {
+		note := ""
+		if c.result.Partial {
+			note = fmt.Sprintf(" (stopped at its %s limit)", c.limit)
+		}
+		per := "-"
+		if len(c.rubric.Transitions) > 0 {
+			per = fmt.Sprintf("%.3f", c.perTransition())
+		}
+		fmt.Printf("%-12s %6d %9s %8.2f %8.2f %8.2f %8.2f %7.2f %9s%s\n", c.name, len(c.result.Ordered), per,
+			c.rubric.Total, c.rubric.KeyPenalty, c.rubric.BPMPenalty, c.rubric.EnergyPenalty, c.mix,
+			c.elapsed.Round(time.Millisecond), note)
+	}
+	fmt.Println("\nTotal is the evaluate rubric (key, BPM, and energy are its raw penalties), best per transition first;")
+	fmt.Println("mix is the mix score as --score reports it. Lower is better.")
+}
+
+// spread summarizes one score over several runs: the mean, the sample standard
+// deviation, and the 95% confidence interval of the mean (Student's t).
+type spread struct {
+	mean, sd, lo, hi float64
+}
+
+// summarize computes the spread of values; an infinite value (a run with no
+// transitions) makes the whole spread infinite.
+func summarize(values []float64) spread {
+	n := float64(len(values))
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / n
+	if len(values) < 2 || math.IsInf(mean, 0) {
+		return spread{mean: mean, lo: mean, hi: mean}
+	}
+	sq := 0.0
+	for _, v := range values {
+		sq += (v - mean) * (v - mean)
+	}
+	sd := math.Sqrt(sq / (n - 1))
+	half := tCritical95(len(values)-1) * sd / math.Sqrt(n)
+	return spread{mean: mean, sd: sd, lo: mean - half, hi: mean + half}
+}
+
+// tCritical95 is the two-sided 95% critical value of Student's t with df degrees of
+// freedom; past 30 the normal value serves.
+func tCritical95(df int) float64 {
+	table := []float64{12.706, 4.303, 3.182, 2.776, 2.571, 2.447, 2.365, 2.306, 2.262, 2.228,
+		2.201, 2.179, 2.160, 2.145, 2.131, 2.120, 2.110, 2.101, 2.093, 2.086,
+		2.080, 2.074, 2.069, 2.064, 2.060, 2.056, 2.052, 2.048, 2.045, 2.042}
+	if df >= 1 && df <= len(table) {
+		return table[df-1]
+	}
+	return 1.960
+}
+
+// printRunSpread prints, per strategy, the mean, standard deviation, and 95%
+// confidence interval of the rubric score per transition and the mix score over every
+// run, best mean first, and says whether the best strategy's lead over the next is
+// more than the runs' scatter.
+func printRunSpread(results []comparison) {
+	type row struct {
+		name     string
+		per, mix spread
+	}
+	rows := make([]row, len(results))
+	for i, c := range results {
+		rows[i] = row{name: c.name, per: summarize(c.perRun), mix: summarize(c.mixRuns)}
+	}
+	slices.SortStableFunc(rows, func(a, b row) int { return cmp.Compare(a.per.mean, b.per.mean) })
+
+	fmt.Printf("\nOver %d runs (mean ± standard deviation, [95%% confidence interval]):\n", len(results[0].perRun))
+	fmt.Printf("%-12s %-34s %s\n", "strategy", "per trans", "mix")
+	for _, r := range rows {
+		fmt.Printf("%-12s %-34s %s\n", r.name, formatSpread(r.per, 3), formatSpread(r.mix, 2))
+	}
+	if len(rows) < 2 || math.IsInf(rows[0].per.mean, 0) {
+		return
+	}
+	best, next := rows[0], rows[1]
+	if best.per.hi < next.per.lo {
+		fmt.Printf("%s scores best per transition, and its interval clears %s's: the lead is more than chance\n",
+			best.name, next.name)
+	} else {
+		fmt.Printf("%s and %s overlap at 95%%: on this library the gap between them may be chance; more --runs narrow it\n",
+			best.name, next.name)
+	}
+}
+
+// formatSpread renders a spread as "1.234 ± 0.056 [1.190, 1.278]".
+func formatSpread(s spread, digits int) string {
+	if math.IsInf(s.mean, 0) {
+		return "-"
+	}
+	return fmt.Sprintf("%.*f ± %.*f [%.*f, %.*f]", digits, s.mean, digits, s.sd, digits, s.lo, digits, s.hi)
+}
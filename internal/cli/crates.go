@@ -0,0 +1,81 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+// runCrates handles `magicmix crates ...`: it partitions a library into harmonic
+// crates (adjacent-key neighborhoods within a tempo band) and writes one playlist per
+// crate into an output directory.
+func runCrates(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix crates", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input CSV file")
+	outDir := fs.String("out-dir", "", "Directory to write one CSV per crate (default <input>_crates)")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix crates --input FILE [--out-dir DIR]\n\n")
+		_, _ = fmt.Fprintf(w, "Split a library into harmonic crates: tracks within a few Camelot steps of\n")
+		_, _ = fmt.Fprintf(w, "each other and in the same tempo band. Writes one CSV per crate.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+
+	playlist, err := loadLibrary(ctx, *inputPath, *noCache)
+	if err != nil {
+		return err
+	}
+
+	dir := *outDir
+	if dir == "" {
+		dir = deriveCratesDir(*inputPath)
+	}
+
+	crates := strategy.Crates(playlist.Tracks)
+	for i, c := range crates {
+		path := filepath.Join(dir, fmt.Sprintf("%02d_%s.csv", i+1, c.Name))
+		if err := csvio.SaveInFormat(ctx, path, csvio.Playlist{
+			Header:   playlist.Header,
+			CRLF:     playlist.CRLF,
+			Encoding: playlist.Encoding,
+			Tracks:   c.Tracks,
+		}); err != nil {
+			return err
+		}
+		keys := make([]string, len(c.Keys))
+		for k, key := range c.Keys {
+			keys[k] = key.String()
+		}
+		fmt.Printf("  %-24s %3d track(s)  keys %s\n", c.Name, len(c.Tracks), strings.Join(keys, " "))
+	}
+
+	fmt.Printf("Wrote %d crate(s) from %d tracks to %s\n", len(crates), len(playlist.Tracks), dir)
+	printWarnings(playlist.Warnings)
+	return nil
+}
+
+func deriveCratesDir(input string) string {
+	dir := filepath.Dir(input)
+	base := filepath.Base(input)
+	name := strings.TrimSuffix(base, filepath.Ext(base))
+	return filepath.Join(dir, name+"_crates")
+}
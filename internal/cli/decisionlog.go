@@ -0,0 +1,63 @@
+package cli
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+// decisionLog writes planner decisions as JSON lines. A run may sort more than once
+// (re-optimizing after outliers are dropped), so each record carries the pass it
+// belongs to; a pass starts at every position-0 record.
+type decisionLog struct {
+	file *os.File
+	buf  *bufio.Writer
+	enc  *json.Encoder
+	pass int
+	err  error
+}
+
+type decisionRecord struct {
+	Pass int `json:"pass"`
+	strategy.Decision
+}
+
+func openDecisionLog(path string) (*decisionLog, error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return nil, fmt.Errorf("create decision log directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return nil, fmt.Errorf("create decision log: %w", err)
+	}
+	buf := bufio.NewWriter(f)
+	return &decisionLog{file: f, buf: buf, enc: json.NewEncoder(buf)}, nil
+}
+
+// record is a strategy.DecisionRecorder. The first write error is kept for Close.
+func (l *decisionLog) record(d strategy.Decision) {
+	if l.err != nil {
+		return
+	}
+	if d.Position == 0 {
+		l.pass++
+	}
+	l.err = l.enc.Encode(decisionRecord{Pass: l.pass, Decision: d})
+}
+
+func (l *decisionLog) Close() error {
+	if err := l.buf.Flush(); err != nil && l.err == nil {
+		l.err = err
+	}
+	if err := l.file.Close(); err != nil && l.err == nil {
+		l.err = err
+	}
+	if l.err != nil {
+		return fmt.Errorf("write decision log: %w", l.err)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// droppedTrack is a library track that didn't make the written set, and why.
+type droppedTrack struct {
+	Track  track.Track
+	Reason string
+}
+
+// dropLog collects the tracks each step of a run leaves out, in the order they went.
+type dropLog []droppedTrack
+
+func (d *dropLog) add(t track.Track, reason string) {
+	*d = append(*d, droppedTrack{Track: t, Reason: reason})
+}
+
+// diff records the tracks of before that after no longer has.
+func (d *dropLog) diff(before, after []track.Track, reason string) {
+	left := map[string]int{}
+	for _, t := range after {
+		left[t.ID()]++
+	}
+	for _, t := range before {
+		if id := t.ID(); left[id] > 0 {
+			left[id]--
+			continue
+		}
+		d.add(t, reason)
+	}
+}
+
+// settle forgets dropped tracks a later step brought back into final.
+func (d *dropLog) settle(final []track.Track) {
+	in := map[string]int{}
+	for _, t := range final {
+		in[t.ID()]++
+	}
+	kept := (*d)[:0]
+	for _, dt := range *d {
+		if id := dt.Track.ID(); in[id] > 0 {
+			in[id]--
+			continue
+		}
+		kept = append(kept, dt)
+	}
+	*d = kept
+}
+
+// droppedPath is the sidecar next to output that lists dropped tracks:
+// set.csv → set.dropped.csv.
+func droppedPath(output string) string {
+	return strings.TrimSuffix(output, filepath.Ext(output)) + ".dropped.csv"
+}
+
+// writeDropped writes the log as CSV with one row per track and its reason.
+func writeDropped(path string, drops dropLog) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create dropped-tracks directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create dropped-tracks file: %w", err)
+	}
+	w := csv.NewWriter(f)
+	_ = w.Write([]string{"Title", "Artist", "BPM", "Key", "Energy", "Reason"})
+	for _, d := range drops {
+		t := d.Track
+		_ = w.Write([]string{t.Title, t.Artist, t.TempoString(), t.KeyString(), strconv.Itoa(t.Energy), d.Reason})
+	}
+	w.Flush()
+	if err := w.Error(); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("write dropped tracks: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("write dropped tracks: %w", err)
+	}
+	return nil
+}
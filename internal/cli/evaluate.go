@@ -0,0 +1,129 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runEvaluate handles `magicmix evaluate ...`: it scores an already-ordered set with
+// the eval rubric, transition by transition, so magicmix output and hand-made sets
+// can be compared on the same terms.
+func runEvaluate(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix evaluate", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the ordered set: a CSV file, Rekordbox XML collection, or setlist")
+	inputFormatName := fs.String("input-format", "", "Input format: csv, rekordbox, or setlist (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	tempoMatchName := fs.String("tempo-match", "direct", "Tempo relationships that count as no jump: direct, half-double, or three-four")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix evaluate --input FILE\n\n")
+		_, _ = fmt.Fprintf(w, "Score a set in the order given: key, BPM, and energy penalties per transition,\n")
+		_, _ = fmt.Fprintf(w, "then the totals. Lower is better.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	tempoMatch, err := track.ParseTempoMatch(*tempoMatchName)
+	if err != nil {
+		return fmt.Errorf("--tempo-match: %w", err)
+	}
+
+	format := libraryFormat{name: inputName, energyField: *energyField}
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, format)
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	defer printWarnings(append(playlist.Warnings, energyWarnings...))
+
+	tracks := playlist.Tracks
+	if len(tracks) <= 1 {
+		fmt.Printf("File %s contains %d track(s) - no transitions to evaluate\n", *inputPath, len(tracks))
+		return nil
+	}
+
+	score := eval.EvaluateWith(tracks, eval.Options{TempoMatch: tempoMatch})
+	fmt.Printf("=== EVALUATION of %s ===\n", *inputPath)
+	for _, tr := range score.Transitions {
+		fmt.Printf("  #%-3d %-24s %4s %6.1f %3d -> %-24s %4s %6.1f %3d: %6.2f [key %.1f bpm %.1f energy %+.1f]%s\n",
+			tr.Index+1, truncate(tr.From.Title, 24), tr.From.KeyString(), tr.From.BPM, tr.From.Energy,
+			truncate(tr.To.Title, 24), tr.To.KeyString(), tr.To.BPM, tr.To.Energy,
+			tr.Total, tr.KeyPenalty, tr.BPMPenalty, tr.EnergyPenalty, transitionFlags(tr))
+	}
+
+	fmt.Printf("\nTotal: %.2f (lower is better) | per transition: %.3f | transitions: %d\n",
+		score.Total, score.Total/float64(len(score.Transitions)), len(score.Transitions))
+	fmt.Printf("  Key: %.2f | BPM: %.2f | energy: %.2f\n", score.KeyPenalty, score.BPMPenalty, score.EnergyPenalty)
+	fmt.Printf("  Invalid transitions: %d | big key jumps: %d | wheel wraps: %d\n",
+		score.InvalidTransitions, score.BigJumps, score.Wraps)
+	fmt.Printf("  Mix score (as --score reports it): %.2f\n", strategy.ScoreMix(tracks).Total)
+	if format.of(*inputPath) == playlistio.Setlist {
+		return checkSetlistScore(ctx, *inputPath, tracks)
+	}
+	return nil
+}
+
+// checkSetlistScore compares the score a setlist records with its tracks' score now,
+// so a shared set can be checked against what its author saw. Reading it already
+// checked its digest.
+func checkSetlistScore(ctx context.Context, path string, tracks []track.Track) error {
+	data, err := playlistio.ReadAll(ctx, path)
+	if err != nil {
+		return err
+	}
+	doc, err := playlistio.ReadSetlist(data)
+	if err != nil {
+		return err
+	}
+	m := doc.Manifest
+	fmt.Printf("\nSetlist made with the %s strategy, seed %d", m.Strategy, m.Seed)
+	if m.Created != "" {
+		fmt.Printf(", on %s", m.Created)
+	}
+	fmt.Println("; its digest checks out")
+	mix, rubric := strategy.ScoreMix(tracks).Total, eval.Evaluate(tracks).Total
+	if mix == doc.Score.Mix && rubric == doc.Score.Rubric {
+		fmt.Printf("  Recorded scores match: mix %.2f, rubric %.2f\n", mix, rubric)
+		return nil
+	}
+	fmt.Printf("  Recorded scores differ: mix %.2f (now %.2f), rubric %.2f (now %.2f); the scoring has changed since it was made\n",
+		doc.Score.Mix, mix, doc.Score.Rubric, rubric)
+	return nil
+}
+
+// transitionFlags names what stands out about a transition, e.g. " (invalid, wraps)".
+func transitionFlags(tr eval.Transition) string {
+	flags := tr.Flags()
+	if len(flags) == 0 {
+		return ""
+	}
+	return " (" + strings.Join(flags, ", ") + ")"
+}
@@ -0,0 +1,46 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+)
+
+// outputFormat resolves --output-format: the named format, or the output path's extension
+// when the flag is empty.
+func outputFormat(flagValue, path string) (playlistio.Format, error) {
+	if flagValue == "" {
+		return playlistio.FormatOf(path), nil
+	}
+	return playlistio.ParseFormat(flagValue)
+}
+
+// withFormatExt gives a derived output path the extension of its format, so
+// --output-format m3u8 without --output writes <input>_magicmix.m3u8. Standard output
+// is left as it is.
+func withFormatExt(path string, f playlistio.Format) string {
+	if path == playlistio.Stdio || playlistio.FormatOf(path) == f {
+		return path
+	}
+	return strings.TrimSuffix(path, filepath.Ext(path)) + f.Ext()
+}
+
+// saveOutput writes an ordered set in format f and returns warnings about the output,
+// such as playlist entries with no file to play.
+func saveOutput(ctx context.Context, path string, f playlistio.Format, pl csvio.Playlist) ([]string, error) {
+	if err := playlistio.Save(ctx, path, f, pl); err != nil {
+		return nil, err
+	}
+	if f == playlistio.CSV {
+		return nil, nil
+	}
+	if missing := playlistio.MissingLocations(pl.Tracks); missing > 0 {
+		return []string{fmt.Sprintf("%d track(s) in %s have no location, so players can't find their files (add a location column)",
+			missing, path)}, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,161 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runFairness handles `magicmix fairness ...`: it has each strategy make several sets
+// from one library, a limited number of tracks each, and reports how each key and
+// mode is used against its share of the library (see strategy.KeyUtilization), so a
+// strategy that strands some keys shows up with a number to tune against.
+func runFairness(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix fairness", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input library: CSV, JSON, Rekordbox XML, or a folder of audio files")
+	inputFormatName := fs.String("input-format", "", "Input format (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	strategies := fs.String("strategies", "", "Comma-separated strategies to audit (default: every registered strategy)")
+	limit := fs.Int("limit", 0, "Tracks in each set (default: half the library)")
+	runs := fs.Int("runs", 5, "Sets each strategy makes, each from its own seed")
+	seedFlag := fs.Int64("seed", 0, "Seed of the first run; the others are derived from it (default: derived from the input)")
+	below := fs.Float64("below", 0.75, "Flag keys and modes played at less than this share of their fair use")
+	timeout := fs.Duration("timeout", 0, "Stop each sort after this long and count what it has (e.g. 5s)")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix fairness --input FILE [--strategies flow,default] [--limit 20] [--runs 5]\n\n")
+		_, _ = fmt.Fprintf(w, "Have each strategy make several limited sets from the library and report how\n")
+		_, _ = fmt.Fprintf(w, "often it plays each key and mode against its share of the library: 1.00 is its\n")
+		_, _ = fmt.Fprintf(w, "fair share, lower means the strategy strands those tracks.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *limit < 0 {
+		return errors.New("limit must be non-negative")
+	}
+	if *runs < 1 {
+		return errors.New("runs must be at least 1")
+	}
+	names, err := compareStrategies(*strategies)
+	if err != nil {
+		return err
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, libraryFormat{name: inputName, energyField: *energyField})
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	warnings := append(playlist.Warnings, energyWarnings...)
+	defer func() { printWarnings(warnings) }()
+	tracks := playlist.Tracks
+	size := *limit
+	if size == 0 {
+		size = len(tracks) / 2
+	}
+	if size < 2 || size >= len(tracks) {
+		return fmt.Errorf("%d tracks in %s: need a set of at least 2 that leaves some out (--limit)", len(tracks), *inputPath)
+	}
+
+	seed := *seedFlag
+	if seed == 0 {
+		if seed, err = inputSeed(ctx, []string{*inputPath}, "fairness", *energyField); err != nil {
+			return err
+		}
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return err
+	}
+	ctx = strategy.WithLimit(strategy.WithGenres(ctx, genres), size)
+	seeds := candidateSeeds(seed, *runs)
+	fmt.Printf("Auditing key use on %d tracks from %s: %d set(s) of %d per strategy, seed %d\n",
+		len(tracks), *inputPath, *runs, size, seed)
+
+	keys := map[string][]strategy.KeyUse{}
+	modes := map[string][]strategy.KeyUse{}
+	for _, name := range names {
+		sorter, err := strategy.Get(name)
+		if err != nil {
+			return err
+		}
+		sets := make([][]track.Track, 0, *runs)
+		for _, s := range seeds {
+			sortCtx, cancel := maybeWithTimeout(strategy.WithSeed(ctx, s), *timeout)
+			res, err := strategy.Sort(sortCtx, sorter, tracks)
+			if cancel != nil {
+				cancel()
+			}
+			if err != nil {
+				return fmt.Errorf("%s: %w", name, err)
+			}
+			sets = append(sets, strategy.Truncate(ctx, res.Ordered, size))
+		}
+		keys[name], modes[name] = strategy.KeyUtilization(tracks, sets)
+	}
+	printFairness(names, keys, modes, *below)
+	return nil
+}
+
+// printFairness prints a row per key, then per mode, with each strategy's utilization,
+// and lists, per strategy, the ones below the threshold.
+func printFairness(names []string, keys, modes map[string][]strategy.KeyUse, below float64) {
+	tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	_, _ = fmt.Fprintf(tw, "\nKEY\tTRACKS\t%s\t\n", strings.Join(names, "\t"))
+	flagged := map[string][]string{}
+	rows := func(uses map[string][]strategy.KeyUse) {
+		for i, u := range uses[names[0]] {
+			cells := make([]string, len(names))
+			for s, name := range names {
+				v := uses[name][i]
+				cells[s] = fmt.Sprintf("%.2f", v.Utilization)
+				if v.Utilization < below {
+					cells[s] += "*"
+					flagged[name] = append(flagged[name], fmt.Sprintf("%s %.2f", v.Name, v.Utilization))
+				}
+			}
+			_, _ = fmt.Fprintf(tw, "%s\t%d\t%s\t\n", u.Name, u.Library, strings.Join(cells, "\t"))
+		}
+	}
+	rows(keys)
+	_, _ = fmt.Fprintln(tw, strings.Repeat("\t", len(names)+2))
+	rows(modes)
+	_ = tw.Flush()
+	fmt.Println("\n1.00 is a key's fair share of the sets, by its share of the library.")
+	if len(flagged) == 0 {
+		fmt.Printf("No strategy plays any key or mode below %.2f of its share.\n", below)
+		return
+	}
+	fmt.Printf("Underused (*, below %.2f):\n", below)
+	for _, name := range names {
+		if len(flagged[name]) > 0 {
+			fmt.Printf("  %s: %s\n", name, strings.Join(flagged[name], ", "))
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// loadFilter holds the load-time filters (--bpm-min, --bpm-max, --energy-min,
+// --energy-max, --keys, --exclude-artist, --exclude-color, --min-priority,
+// --broadcast-safe, --region), which take tracks out of the library before anything is
+// sorted.
+type loadFilter struct {
+	bpmMin, bpmMax       float64 // 0 = no bound
+	energyMin, energyMax int
+	keys                 []track.Key
+	excludeArtists       []string // normalized (see track.NormalizeArtist)
+	excludeColors        []string // track.Colors
+	minPriority          int      // 0 = no bound; unrated tracks always pass
+	broadcastSafe        bool     // only tracks whose license clears them
+	region               string   // upper-cased; "" = any
+}
+
+// newLoadFilter checks the filter flags' values and compiles them. keys is a comma-
+// separated list in any notation track.ParseKey reads, and excludeColors one of colors
+// as track.ParseColor reads them.
+func newLoadFilter(bpmMin, bpmMax float64, energyMin, energyMax int, keys string, excludeArtists []string,
+	excludeColors string, minPriority int, broadcastSafe bool, region string) (loadFilter, error) {
+	f := loadFilter{bpmMin: bpmMin, bpmMax: bpmMax, energyMin: energyMin, energyMax: energyMax, minPriority: minPriority,
+		broadcastSafe: broadcastSafe, region: strings.ToUpper(strings.TrimSpace(region))}
+	if bpmMin < 0 || bpmMax < 0 || (bpmMax > 0 && bpmMin > bpmMax) {
+		return loadFilter{}, fmt.Errorf("--bpm-min %g and --bpm-max %g: want 0 <= min <= max", bpmMin, bpmMax)
+	}
+	if energyMin < 0 || energyMax > 100 || energyMin > energyMax {
+		return loadFilter{}, fmt.Errorf("--energy-min %d and --energy-max %d: want 0 <= min <= max <= 100", energyMin, energyMax)
+	}
+	for _, s := range strings.Split(keys, ",") {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		k, err := track.ParseKey(s)
+		if err != nil {
+			return loadFilter{}, fmt.Errorf("--keys: %w", err)
+		}
+		f.keys = append(f.keys, k)
+	}
+	for _, a := range excludeArtists {
+		if a = track.NormalizeArtist(a); a != "" {
+			f.excludeArtists = append(f.excludeArtists, a)
+		}
+	}
+	for _, s := range strings.Split(excludeColors, ",") {
+		c, err := track.ParseColor(s)
+		if err != nil {
+			return loadFilter{}, fmt.Errorf("--exclude-color: %w", err)
+		}
+		if c != "" {
+			f.excludeColors = append(f.excludeColors, c)
+		}
+	}
+	if minPriority < 0 || minPriority > 5 {
+		return loadFilter{}, fmt.Errorf("--min-priority %d: want 1-5, or 0 for no bound", minPriority)
+	}
+	return f, nil
+}
+
+// active reports whether any filter is set.
+func (f loadFilter) active() bool {
+	return f.bpmMin > 0 || f.bpmMax > 0 || f.energyMin > 0 || f.energyMax < 100 || len(f.keys) > 0 ||
+		len(f.excludeArtists) > 0 || len(f.excludeColors) > 0 || f.minPriority > 0 || f.broadcastSafe || f.region != ""
+}
+
+// exclude says which flag keeps t out of the library and why; flag is "" when t passes
+// every filter. A track that modulates passes --keys if any of its keys is listed.
+func (f loadFilter) exclude(t track.Track) (flag, reason string) {
+	switch {
+	case f.bpmMin > 0 && t.BPM < f.bpmMin:
+		return "--bpm-min", fmt.Sprintf("BPM %g is below --bpm-min %g", t.BPM, f.bpmMin)
+	case f.bpmMax > 0 && t.BPM > f.bpmMax:
+		return "--bpm-max", fmt.Sprintf("BPM %g is above --bpm-max %g", t.BPM, f.bpmMax)
+	case t.Energy < f.energyMin:
+		return "--energy-min", fmt.Sprintf("energy %d is below --energy-min %d", t.Energy, f.energyMin)
+	case t.Energy > f.energyMax:
+		return "--energy-max", fmt.Sprintf("energy %d is above --energy-max %d", t.Energy, f.energyMax)
+	}
+	if len(f.keys) > 0 && !f.keyListed(t) {
+		return "--keys", fmt.Sprintf("key %s is not in --keys", t.Key)
+	}
+	for _, a := range f.excludeArtists {
+		if slices.ContainsFunc(track.Artists(t.Artist), func(name string) bool { return strings.Contains(name, a) }) {
+			return "--exclude-artist", fmt.Sprintf("artist matches --exclude-artist %q", a)
+		}
+	}
+	if slices.Contains(f.excludeColors, t.Color) {
+		return "--exclude-color", fmt.Sprintf("color %s is in --exclude-color", t.Color)
+	}
+	if t.Priority != nil && *t.Priority < f.minPriority {
+		return "--min-priority", fmt.Sprintf("priority %d is below --min-priority %d", *t.Priority, f.minPriority)
+	}
+	if f.broadcastSafe && !t.Cleared() {
+		if t.License == "" {
+			return "--broadcast-safe", "no license on record for --broadcast-safe"
+		}
+		return "--broadcast-safe", fmt.Sprintf("license %q is not cleared for --broadcast-safe", t.License)
+	}
+	if f.region != "" && !t.ClearedIn(f.region) {
+		return "--region", fmt.Sprintf("not cleared for --region %s, only in %s", f.region, strings.Join(t.Regions, ", "))
+	}
+	return "", ""
+}
+
+// parseColorPriorities reads --color-priority, a comma-separated list of COLOR=N with
+// N a 1-5 priority, such as "green=5,yellow=2".
+func parseColorPriorities(spec string) (map[string]int, error) {
+	out := map[string]int{}
+	for _, part := range strings.Split(spec, ",") {
+		if part = strings.TrimSpace(part); part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return nil, fmt.Errorf("--color-priority %q: want COLOR=N, e.g. green=5", part)
+		}
+		c, err := track.ParseColor(name)
+		if err != nil || c == "" {
+			return nil, fmt.Errorf("--color-priority %q: unknown color (want %s)", part, strings.Join(track.Colors, ", "))
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || n < 1 || n > 5 {
+			return nil, fmt.Errorf("--color-priority %q: want a priority of 1-5", part)
+		}
+		out[c] = n
+	}
+	return out, nil
+}
+
+// applyColorPriorities gives each track whose color is in priorities that priority,
+// in place of any rating of its own, and returns how many it set.
+func applyColorPriorities(tracks []track.Track, priorities map[string]int) int {
+	set := 0
+	for i := range tracks {
+		if p, ok := priorities[tracks[i].Color]; ok {
+			tracks[i].Priority = &p
+			set++
+		}
+	}
+	return set
+}
+
+func (f loadFilter) keyListed(t track.Track) bool {
+	for _, k := range append([]track.Key{t.Key}, t.Modulations...) {
+		for _, want := range f.keys {
+			if k == want {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// apply removes the tracks the filters exclude from tracks and the parallel sources,
+// logging each in drops, and prints how many each filter took out.
+func (f loadFilter) apply(tracks []track.Track, sources []int, drops *dropLog) ([]track.Track, []int) {
+	if !f.active() {
+		return tracks, sources
+	}
+	var keptTracks []track.Track
+	var keptSources []int
+	counts := map[string]int{}
+	var flags []string // in the order first seen
+	for i, t := range tracks {
+		flag, reason := f.exclude(t)
+		if flag == "" {
+			keptTracks, keptSources = append(keptTracks, t), append(keptSources, sources[i])
+			continue
+		}
+		if counts[flag] == 0 {
+			flags = append(flags, flag)
+		}
+		counts[flag]++
+		drops.add(t, reason)
+	}
+	if excluded := len(tracks) - len(keptTracks); excluded > 0 {
+		fmt.Printf("Filtered out %d of %d track(s) at load:\n", excluded, len(tracks))
+		for _, flag := range flags {
+			fmt.Printf("  - %d by %s\n", counts[flag], flag)
+		}
+	}
+	return keptTracks, keptSources
+}
+
+// genreFit warns of each track strategy.GenreMisfits finds implausible for its genre.
+// The tags are left as they are.
+func genreFit(ctx context.Context, tracks []track.Track) []string {
+	var warnings []string
+	for _, m := range strategy.GenreMisfits(ctx, tracks) {
+		t := tracks[m.Index]
+		warnings = append(warnings, fmt.Sprintf("%q by %s looks %s for %s: %s", t.Title, t.Artist, m.Severity, m.Genre, strings.Join(m.Reasons, "; ")))
+	}
+	return warnings
+}
+
+// printGenreFit lists genreFit's warnings for review, as --check-genres asks.
+func printGenreFit(warnings []string) {
+	if len(warnings) == 0 {
+		return
+	}
+	fmt.Printf("Found %d track(s) whose tags don't fit their genre (--check-genres); review them:\n", len(warnings))
+	for _, w := range warnings {
+		fmt.Printf("  - %s\n", w)
+	}
+}
+
+// checkSuspectTags lists the energy and BPM tags strategy.Suspects finds doubtful. In
+// "fix" mode it returns tracks with those tags set to their expected values for
+// sorting; in "flag" mode it leaves them be. Either way each one is warned about, so
+// the tag can be corrected at its source.
+func checkSuspectTags(ctx context.Context, tracks []track.Track, mode string) ([]track.Track, []string) {
+	suspects := strategy.Suspects(ctx, tracks)
+	if len(suspects) == 0 {
+		return tracks, nil
+	}
+	fmt.Printf("Found %d suspect tag(s) (--check-tags %s):\n", len(suspects), mode)
+	var warnings []string
+	for _, s := range suspects {
+		t := tracks[s.Index]
+		fmt.Printf("  - %q by %s: %s %g, expected about %g; %s\n", t.Title, t.Artist, s.Field, s.Value, s.Expected, s.Reason)
+		warning := fmt.Sprintf("%q by %s: %s %g looks mis-tagged (expected about %g)", t.Title, t.Artist, s.Field, s.Value, s.Expected)
+		if mode == "fix" {
+			warning += fmt.Sprintf("; sorted as %g", s.Expected)
+		}
+		warnings = append(warnings, warning)
+	}
+	if mode == "fix" {
+		tracks = strategy.FixSuspects(tracks, suspects)
+	}
+	return tracks, warnings
+}
@@ -0,0 +1,591 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/audiotags"
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/filter"
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// stringsFlag is a repeatable string flag: each occurrence appends a value.
+type stringsFlag []string
+
+func (f *stringsFlag) String() string { return strings.Join(*f, ", ") }
+
+func (f *stringsFlag) Set(v string) error {
+	*f = append(*f, v)
+	return nil
+}
+
+// applyProfile sets fs's flags from the named profile in the profiles file. Flags
+// given on the command line win: a profile value is used only for a flag that wasn't
+// set, and a repeatable flag given on the command line replaces the profile's list.
+func applyProfile(fs *flag.FlagSet, name string) error {
+	path, err := config.ProfilesPath()
+	if err != nil {
+		return err
+	}
+	profile, err := config.LoadProfile(path, name)
+	if err != nil {
+		return err
+	}
+	return applySettings(fs, fmt.Sprintf("profile %q", name), profile, []string{"profile"}, nil)
+}
+
+// applySettings sets fs's flags from settings, as applyProfile does, naming source in
+// errors. Settings may not name a reserved flag; resolve, when not nil, rewrites each
+// value first.
+func applySettings(fs *flag.FlagSet, source string, settings config.Profile, reserved []string, resolve func(flagName, v string) string) error {
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	for _, flagName := range settings.Flags() {
+		if slices.Contains(reserved, flagName) {
+			return fmt.Errorf("%s: --%s can't be set here", source, flagName)
+		}
+		if fs.Lookup(flagName) == nil {
+			return fmt.Errorf("%s: unknown flag %q", source, flagName)
+		}
+		if given[flagName] {
+			continue
+		}
+		for _, v := range settings[flagName] {
+			if resolve != nil {
+				v = resolve(flagName, v)
+			}
+			if err := fs.Set(flagName, v); err != nil {
+				return fmt.Errorf("%s: --%s %s: %w", source, flagName, v, err)
+			}
+		}
+	}
+	return nil
+}
+
+// readTuning reads a --config file: strategy tuning keyed by strategy name, today only
+// the default strategy's, e.g. {"default": {"bpmWeight": 2, "cycleIdealTracks": 12}}.
+// Settings left out keep their defaults; unknown ones are an error, so a typo can't
+// pass silently.
+func readTuning(path string) (strategy.Tuning, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return strategy.Tuning{}, fmt.Errorf("read config: %w", err)
+	}
+	var file map[string]json.RawMessage
+	if err := json.Unmarshal(data, &file); err != nil {
+		return strategy.Tuning{}, fmt.Errorf("parse config %s: %w", path, err)
+	}
+	tuning := strategy.DefaultTuning
+	for name, raw := range file {
+		if name != "default" {
+			return strategy.Tuning{}, fmt.Errorf("config %s: %q has no tuning (only the default strategy does)", path, name)
+		}
+		dec := json.NewDecoder(bytes.NewReader(raw))
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(&tuning); err != nil {
+			return strategy.Tuning{}, fmt.Errorf("config %s: %w", path, err)
+		}
+	}
+	if err := tuning.Validate(); err != nil {
+		return strategy.Tuning{}, fmt.Errorf("config %s: %w", path, err)
+	}
+	return tuning, nil
+}
+
+// parsePlacement reads a --place rule written as FILTER@WINDOW, e.g.
+// "tag:singalong@last:60m" or "energy>=80@peak".
+func parsePlacement(spec string, genres *genre.Taxonomy) (strategy.Placement, error) {
+	at := strings.LastIndex(spec, "@")
+	if at < 0 {
+		return strategy.Placement{}, fmt.Errorf("--place %q: want FILTER@WINDOW", spec)
+	}
+	expr, err := filter.ParseWith(spec[:at], genres)
+	if err != nil {
+		return strategy.Placement{}, fmt.Errorf("--place: %w", err)
+	}
+	window, err := strategy.ParseWindow(spec[at+1:])
+	if err != nil {
+		return strategy.Placement{}, fmt.Errorf("--place: %w", err)
+	}
+	return strategy.Placement{Name: spec, Match: expr.Match, Window: window}, nil
+}
+
+// parsePool reads a --pool spec written as ROLE=FILTER, e.g. "open=tag:opener" or
+// "peak=energy>=80".
+func parsePool(spec string, genres *genre.Taxonomy) (strategy.Pool, error) {
+	name, expr, ok := strings.Cut(spec, "=")
+	if !ok {
+		return strategy.Pool{}, fmt.Errorf("--pool %q: want ROLE=FILTER, e.g. close=tag:closer", spec)
+	}
+	role, err := strategy.ParsePoolRole(name)
+	if err != nil {
+		return strategy.Pool{}, fmt.Errorf("--pool %q: %w", spec, err)
+	}
+	f, err := filter.ParseWith(expr, genres)
+	if err != nil {
+		return strategy.Pool{}, fmt.Errorf("--pool: %w", err)
+	}
+	return strategy.Pool{Name: strings.TrimSpace(expr), Role: role, Match: f.Match}, nil
+}
+
+// parseTrackRef reads a track reference written as "Title|Artist", or just "Title",
+// into a matcher. Titles compare case-insensitively; the artist matches when any
+// credited artist does (see track.SharesArtist).
+func parseTrackRef(spec string) (func(track.Track) bool, error) {
+	title, artist, _ := strings.Cut(spec, "|")
+	title, artist = strings.TrimSpace(title), strings.TrimSpace(artist)
+	if title == "" {
+		return nil, fmt.Errorf("%q: want \"Title|Artist\" or \"Title\"", spec)
+	}
+	return func(t track.Track) bool {
+		return strings.EqualFold(strings.TrimSpace(t.Title), title) &&
+			(artist == "" || track.SharesArtist(t.Artist, artist))
+	}, nil
+}
+
+// parsePin reads a --pin spec: a track reference, optionally followed by @POSITION
+// (1-based; negative counts from the end, so @-1 is the closer) or a range of them,
+// @FROM..TO, with either end left open: @20.. is no earlier than 20th, @..10 within
+// the first 10. Without a position the track only has to make the set.
+func parsePin(spec string) (pin strategy.Pin, pinned bool, err error) {
+	ref := spec
+	if at := strings.LastIndex(spec, "@"); at >= 0 {
+		pos := strings.TrimSpace(spec[at+1:])
+		if from, to, ranged := strings.Cut(pos, ".."); ranged {
+			if pin.Earliest, pin.Latest, err = parsePinRange(from, to); err != nil {
+				return strategy.Pin{}, false, fmt.Errorf("--pin %q: %w", spec, err)
+			}
+			ref, pinned = spec[:at], true
+		} else if n, perr := strconv.Atoi(pos); perr == nil {
+			if n == 0 {
+				return strategy.Pin{}, false, fmt.Errorf("--pin %q: positions start at 1 (or -1 for the last track)", spec)
+			}
+			ref, pin.Position, pinned = spec[:at], n, true
+		}
+	}
+	match, err := parseTrackRef(ref)
+	if err != nil {
+		return strategy.Pin{}, false, fmt.Errorf("--pin %w", err)
+	}
+	pin.Name, pin.Match = spec, match
+	return pin, pinned, nil
+}
+
+// parsePinRange reads the two ends of a --pin range, either of which may be empty.
+func parsePinRange(from, to string) (earliest, latest int, err error) {
+	ends := [2]int{}
+	for i, s := range []string{from, to} {
+		if s = strings.TrimSpace(s); s == "" {
+			continue
+		}
+		if ends[i], err = strconv.Atoi(s); err != nil {
+			return 0, 0, fmt.Errorf("position %q is not a number", s)
+		}
+		if ends[i] == 0 {
+			return 0, 0, errors.New("positions start at 1 (or -1 for the last track)")
+		}
+	}
+	switch earliest, latest = ends[0], ends[1]; {
+	case earliest == 0 && latest == 0:
+		return 0, 0, errors.New("a range needs at least one end, as in @20.. or @..10")
+	case earliest != 0 && latest != 0 && (earliest > 0) == (latest > 0) && earliest > latest:
+		return 0, 0, fmt.Errorf("the range starts at %d, after it ends at %d", earliest, latest)
+	}
+	return earliest, latest, nil
+}
+
+// anchorRules turns --open, --close, and --pin into pins and must-include rules, and
+// checks each names a track in tracks.
+func anchorRules(open, close string, pinSpecs []string, tracks []track.Track) ([]strategy.Pin, []strategy.MustInclude, error) {
+	var pins []strategy.Pin
+	var must []strategy.MustInclude
+	for _, a := range []struct {
+		flag, spec string
+		pos        int
+	}{{"--open", open, 1}, {"--close", close, -1}} {
+		if a.spec == "" {
+			continue
+		}
+		match, err := parseTrackRef(a.spec)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%s %w", a.flag, err)
+		}
+		pins = append(pins, strategy.Pin{Name: a.flag + " " + a.spec, Match: match, Position: a.pos})
+	}
+	for _, spec := range pinSpecs {
+		pin, pinned, err := parsePin(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		if pinned {
+			pins = append(pins, pin)
+		} else {
+			must = append(must, strategy.MustInclude{Name: pin.Name, Match: pin.Match})
+		}
+	}
+
+	names := make([]string, 0, len(pins)+len(must))
+	matchers := make([]func(track.Track) bool, 0, len(pins)+len(must))
+	for _, p := range pins {
+		names, matchers = append(names, p.Name), append(matchers, p.Match)
+	}
+	for _, m := range must {
+		names, matchers = append(names, m.Name), append(matchers, m.Match)
+	}
+	for i, match := range matchers {
+		if !slices.ContainsFunc(tracks, match) {
+			return nil, nil, fmt.Errorf("%s matches no track in the set", names[i])
+		}
+	}
+	return pins, must, nil
+}
+
+// withLockedOrder keeps the locked tracks, those marked in the input's locked column
+// and those matching --lock-matching, in their order in tracks, and in the set.
+func withLockedOrder(ctx context.Context, tracks []track.Track, lockMatching string, genres *genre.Taxonomy) (context.Context, error) {
+	isLocked := func(t track.Track) bool { return t.Locked }
+	if lockMatching != "" {
+		expr, err := filter.ParseWith(lockMatching, genres)
+		if err != nil {
+			return ctx, fmt.Errorf("--lock-matching: %w", err)
+		}
+		if !slices.ContainsFunc(tracks, expr.Match) {
+			return ctx, fmt.Errorf("--lock-matching %q matches no track in the set", lockMatching)
+		}
+		isLocked = func(t track.Track) bool { return t.Locked || expr.Match(t) }
+	}
+	var backbone []track.Track
+	for _, t := range tracks {
+		if isLocked(t) {
+			backbone = append(backbone, t)
+		}
+	}
+	if len(backbone) < 2 {
+		return ctx, nil
+	}
+	fmt.Printf("Keeping %d locked track(s) in their input order\n", len(backbone))
+	ctx = strategy.WithLockedOrder(ctx, backbone)
+	return strategy.WithMustInclude(ctx, strategy.MustInclude{Name: "locked track", Match: isLocked}), nil
+}
+
+// withPools sizes the --pool pools for the set tracks will make, limit tracks or
+// about targetDuration long when those are set, under arc, and keeps each pool's
+// chosen tracks in the set and in its window. It fails, listing every pool short of
+// tracks, before anything is sorted.
+func withPools(ctx context.Context, pools []strategy.Pool, tracks []track.Track, limit int, targetDuration time.Duration,
+	arc *strategy.Arc) (context.Context, error) {
+	setTracks := len(tracks)
+	if limit > 0 {
+		setTracks = min(setTracks, limit)
+	}
+	if targetDuration > 0 {
+		setTracks = min(setTracks, max(1, int(targetDuration/avgTrackLength(tracks))))
+	}
+	plans, err := strategy.PlanPools(pools, tracks, setTracks, arc)
+	if err != nil {
+		return ctx, fmt.Errorf("--pool: not enough tracks for a set of %d:\n%w", setTracks, err)
+	}
+	fmt.Printf("Drawing from %d pool(s) for a set of %d tracks:\n", len(plans), setTracks)
+	for _, p := range plans {
+		fmt.Printf("  - %s (%s): %d of %d matching track(s), %.0f-%.0f%% through the set\n",
+			p.Role, p.Name, p.Need, p.Have, 100*p.Window.Lo, 100*p.Window.Hi)
+		must, rule := p.Rules(tracks)
+		ctx = strategy.WithPlacement(strategy.WithMustInclude(ctx, must...), rule)
+	}
+	return ctx, nil
+}
+
+// parseAnnealBudget reads --anneal-budget: a plain number is an iteration count,
+// anything else a duration such as "20s".
+func parseAnnealBudget(spec string) (strategy.AnnealBudget, error) {
+	if n, err := strconv.Atoi(spec); err == nil {
+		if n <= 0 {
+			return strategy.AnnealBudget{}, fmt.Errorf("--anneal-budget %q: iterations must be positive", spec)
+		}
+		return strategy.AnnealBudget{Iterations: n}, nil
+	}
+	d, err := time.ParseDuration(spec)
+	if err != nil || d <= 0 {
+		return strategy.AnnealBudget{}, fmt.Errorf("--anneal-budget %q: want an iteration count or a positive duration", spec)
+	}
+	// A time budget alone should not stop early because of the default iteration cap.
+	return strategy.AnnealBudget{Iterations: math.MaxInt, Duration: d}, nil
+}
+
+// parseBudgets reads --budget: comma-separated strategy=duration pairs, e.g.
+// "default=1s,anneal=20s", giving each named strategy its own time limit.
+func parseBudgets(spec string) (map[string]time.Duration, error) {
+	budgets := map[string]time.Duration{}
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(part, "=")
+		name = strings.TrimSpace(name)
+		if !ok {
+			return nil, fmt.Errorf("--budget %q: want strategy=duration, e.g. anneal=20s", part)
+		}
+		if _, known := strategy.Describe(name); !known {
+			return nil, fmt.Errorf("--budget: unknown strategy %q (want %s)", name, strings.Join(strategy.Names(), ", "))
+		}
+		if _, dup := budgets[name]; dup {
+			return nil, fmt.Errorf("--budget: %s is given twice", name)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("--budget %q: want a positive duration, e.g. 20s", part)
+		}
+		budgets[name] = d
+	}
+	return budgets, nil
+}
+
+// parseStages reads --stages: comma-separated strategy:size stages in set order, e.g.
+// "rotation:30m,flow,anneal:60m", each sized by a track count or a length as
+// --set-size is. The one stage without a size takes the rest of the set.
+func parseStages(spec string) (*strategy.StagedSorter, error) {
+	var stages []strategy.Stage
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, sizeSpec, sized := strings.Cut(part, ":")
+		s, err := strategy.Get(strings.TrimSpace(name))
+		if err != nil {
+			return nil, fmt.Errorf("--stages: %w", err)
+		}
+		stage := strategy.Stage{Sorter: s}
+		if sized {
+			size, err := parseSetSize("stages", sizeSpec)
+			if err != nil {
+				return nil, err
+			}
+			stage.Tracks, stage.Seconds = size.tracks, size.length.Seconds()
+		}
+		stages = append(stages, stage)
+	}
+	staged, err := strategy.NewStagedSorter(stages)
+	if err != nil {
+		return nil, fmt.Errorf("--stages: %w", err)
+	}
+	return staged, nil
+}
+
+// printStages shows which tracks each stage of a staged sort plays.
+func printStages(s *strategy.StagedSorter, tracks []track.Track) {
+	fmt.Println("Stages, quietest first:")
+	for k, group := range s.Split(tracks) {
+		name := s.Stages()[k].Sorter.Name()
+		if len(group) == 0 {
+			fmt.Printf("  %d. %s: no tracks left for it\n", k+1, name)
+			continue
+		}
+		lo, hi := group[0].Energy, group[0].Energy
+		for _, t := range group {
+			lo, hi = min(lo, t.Energy), max(hi, t.Energy)
+		}
+		fmt.Printf("  %d. %s: %d track(s), %s, energy %d-%d\n", k+1, name, len(group), formatClock(setLength(group)), lo, hi)
+	}
+}
+
+// checkEnergy refuses tracks whose energy had to be inferred unless the user opted in
+// with --infer-energy, and otherwise returns a warning saying how many were estimated.
+func checkEnergy(tracks []track.Track, allowInferred bool) ([]string, error) {
+	inferred := 0
+	for _, t := range tracks {
+		if t.EnergyInferred {
+			inferred++
+		}
+	}
+	if inferred == 0 {
+		return nil, nil
+	}
+	if !allowInferred {
+		return nil, fmt.Errorf("%d track(s) have no energy value; pass --infer-energy to estimate it from BPM, genre, loudness, and danceability", inferred)
+	}
+	return []string{fmt.Sprintf("inferred energy for %d track(s) from BPM, genre, loudness, and danceability", inferred)}, nil
+}
+
+// maxWarningLines caps the warning block; the rest are counted, not listed.
+const maxWarningLines = 10
+
+// printWarnings prints the run's collected warnings, once each, as a block at the end
+// of the run.
+func printWarnings(warnings []string) {
+	seen := map[string]bool{}
+	var unique []string
+	for _, w := range warnings {
+		if !seen[w] {
+			seen[w] = true
+			unique = append(unique, w)
+		}
+	}
+	if len(unique) == 0 {
+		return
+	}
+	fmt.Printf("\nWarnings (%d):\n", len(unique))
+	for i, w := range unique {
+		if i == maxWarningLines {
+			fmt.Printf("  ... and %d more\n", len(unique)-maxWarningLines)
+			break
+		}
+		fmt.Printf("  - %s\n", w)
+	}
+}
+
+// checkInventory prints, before a sort that may take a while, what
+// strategy.Inventory finds the tracks short of, so a set the library can't make says
+// so in moments. It returns the warnings to report again with the rest.
+func checkInventory(ctx context.Context, tracks []track.Track) []string {
+	inventory := strategy.Inventory(ctx, tracks)
+	if len(inventory) > 0 {
+		fmt.Printf("Before sorting, the library looks short for this set (%d):\n", len(inventory))
+		for _, w := range inventory {
+			fmt.Printf("  - %s\n", w)
+		}
+	}
+	return inventory
+}
+
+// inputSeed derives a seed from the input files' contents and the options that shape
+// the result, so a rerun of the same command over the same files orders them the same
+// way.
+func inputSeed(ctx context.Context, paths []string, options ...string) (int64, error) {
+	sum, err := hashInputs(ctx, paths, options...)
+	if err != nil {
+		return 0, err
+	}
+	seed := int64(binary.BigEndian.Uint64(sum) >> 1)
+	if seed == 0 {
+		seed = 1 // 0 means "no seed"
+	}
+	return seed, nil
+}
+
+// hashInputs is a SHA-256 digest of the input files' contents and options. A folder of
+// audio files is hashed by its listing (see folderManifest) rather than read whole.
+func hashInputs(ctx context.Context, paths []string, options ...string) ([]byte, error) {
+	h := sha256.New()
+	for _, path := range paths {
+		data, err := inputContent(ctx, path)
+		if err != nil {
+			return nil, err
+		}
+		h.Write(data)
+		h.Write([]byte{0})
+	}
+	for _, o := range options {
+		h.Write([]byte{0})
+		h.Write([]byte(o))
+	}
+	return h.Sum(nil), nil
+}
+
+// inputContent is what stands for an input's content in digests: the file, or a
+// folder's listing (see folderManifest).
+func inputContent(ctx context.Context, path string) ([]byte, error) {
+	if playlistio.InputFormatOf(path) == playlistio.Folder {
+		return folderManifest(path)
+	}
+	return playlistio.ReadAll(ctx, path)
+}
+
+// folderManifest lists the audio files under dir with their sizes and modification
+// times, which change whenever a file or its tags do.
+func folderManifest(dir string) ([]byte, error) {
+	var b bytes.Buffer
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !audiotags.IsAudio(path) {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, _ := filepath.Rel(dir, path)
+		fmt.Fprintf(&b, "%s\x00%d\x00%d\n", filepath.ToSlash(rel), info.Size(), info.ModTime().UnixNano())
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read folder: %w", err)
+	}
+	return b.Bytes(), nil
+}
+
+// newMusicMatcher reports tracks added within the last weeks weeks of now, or tagged
+// "new".
+func newMusicMatcher(weeks int, now time.Time) func(track.Track) bool {
+	since := now.AddDate(0, 0, -7*weeks)
+	return func(t track.Track) bool {
+		if t.Added != nil && !t.Added.Before(since) {
+			return true
+		}
+		return slices.Contains(t.Tags, "new")
+	}
+}
+
+// withNewSpread adds the rule that spaces new tracks through the set. It expects the
+// set to hold every new track in tracks, or at least min of them.
+func withNewSpread(ctx context.Context, tracks []track.Track, isNew func(track.Track) bool, min, limit int) context.Context {
+	size := len(tracks)
+	if limit > 0 && limit < size {
+		size = limit
+	}
+	count := 0
+	for _, t := range tracks {
+		if isNew(t) {
+			count++
+		}
+	}
+	count = max(count, min)
+	if rule, ok := strategy.SpreadSeparation("new", isNew, count, size); ok {
+		ctx = strategy.WithSeparation(ctx, rule)
+	}
+	return ctx
+}
+
+// meetNewQuota swaps new tracks from pool into a set that has fewer than min of them,
+// re-sorts it, and warns when the library doesn't have enough.
+func meetNewQuota(ctx context.Context, sorter strategy.Sorter, ordered, pool []track.Track,
+	isNew func(track.Track) bool, min int) ([]track.Track, []strategy.Confidence, []string) {
+	var warnings []string
+	set, swapped := strategy.MeetQuota(ordered, pool, isNew, min)
+	confidence := strategy.PlacementConfidence(set)
+	if swapped > 0 {
+		fmt.Printf("Swapped in %d new track(s) to include at least %d (--min-new)\n", swapped, min)
+		if res, err := strategy.Sort(ctx, sorter, set); err == nil && len(res.Ordered) == len(set) {
+			set, confidence = res.Ordered, res.Confidence
+			warnings = append(warnings, res.Warnings...)
+		}
+	}
+	have := 0
+	for _, t := range set {
+		if isNew(t) {
+			have++
+		}
+	}
+	if have < min {
+		warnings = append(warnings, fmt.Sprintf("only %d new track(s) available; --min-new asked for %d", have, min))
+	}
+	return set, confidence, warnings
+}
@@ -0,0 +1,217 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// inputSpec is one --input value: a path with an optional blend weight, as in
+// "promos.csv:0.3".
+type inputSpec struct {
+	path   string
+	weight float64
+}
+
+// parseInputSpec reads "path" or "path:weight". A suffix after the last colon that is
+// not a number is part of the path (so "C:\music.csv" still works).
+func parseInputSpec(s string) (inputSpec, error) {
+	if i := strings.LastIndex(s, ":"); i > 0 {
+		if w, err := strconv.ParseFloat(s[i+1:], 64); err == nil {
+			if w <= 0 {
+				return inputSpec{}, fmt.Errorf("input %q: weight must be positive", s)
+			}
+			return inputSpec{path: s[:i], weight: w}, nil
+		}
+	}
+	return inputSpec{path: s, weight: 1}, nil
+}
+
+func parseInputSpecs(values []string) ([]inputSpec, error) {
+	specs := make([]inputSpec, len(values))
+	for i, v := range values {
+		spec, err := parseInputSpec(v)
+		if err != nil {
+			return nil, err
+		}
+		specs[i] = spec
+	}
+	return specs, nil
+}
+
+func inputPaths(specs []inputSpec) []string {
+	paths := make([]string, len(specs))
+	for i, s := range specs {
+		paths[i] = s.path
+	}
+	return paths
+}
+
+// inputLoaders bounds how many inputs load at once.
+const inputLoaders = 4
+
+// loadInputs loads the input libraries concurrently and merges them in the order
+// given. source[i] is the index into specs of the library track i came from. Every
+// input that fails to load is reported, not just the first. When the libraries'
+// columns differ, the merged playlist drops its header and the raw rows, so it is
+// written in magicmix's own schema.
+func loadInputs(ctx context.Context, specs []inputSpec, noCache bool, format libraryFormat) (csvio.Playlist, []int, error) {
+	loaded := make([]csvio.Playlist, len(specs))
+	errs := make([]error, len(specs))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for range min(inputLoaders, len(specs)) {
+		wg.Go(func() {
+			for s := range jobs {
+				loaded[s], errs[s] = loadLibraryAs(ctx, specs[s].path, noCache, format)
+				if errs[s] != nil && len(specs) > 1 {
+					errs[s] = fmt.Errorf("%s: %w", specs[s].path, errs[s])
+				}
+			}
+		})
+	}
+	for s := range specs {
+		jobs <- s
+	}
+	close(jobs)
+	wg.Wait()
+	if err := errors.Join(errs...); err != nil {
+		return csvio.Playlist{}, nil, err
+	}
+
+	var merged csvio.Playlist
+	var source []int
+	sameColumns := true
+	for s, pl := range loaded {
+		if s == 0 {
+			merged.Header, merged.CRLF, merged.Encoding = pl.Header, pl.CRLF, pl.Encoding
+		} else if !slices.Equal(pl.Header, merged.Header) {
+			sameColumns = false
+		}
+		for _, w := range pl.Warnings {
+			if len(specs) > 1 {
+				w = specs[s].path + ": " + w
+			}
+			merged.Warnings = append(merged.Warnings, w)
+		}
+		merged.Tracks = append(merged.Tracks, pl.Tracks...)
+		for range pl.Tracks {
+			source = append(source, s)
+		}
+	}
+	if !sameColumns {
+		merged.Header = nil
+		for i := range merged.Tracks {
+			merged.Tracks[i].Raw = nil
+		}
+	}
+	return merged, source, nil
+}
+
+// dedupeInputs keeps one copy of each track that appears in more than one input — the
+// copy from the earliest input — and reports which inputs held it. Copies match
+// exactly (same title and artist, ignoring case) or fuzzily (same track.RecordingKey,
+// e.g. reordered artists or a featured artist moved into the title); fuzzy matches
+// list the spelling that was dropped. Repeats within the kept copy's own input are left
+// alone.
+func dedupeInputs(tracks []track.Track, source []int, specs []inputSpec) ([]track.Track, []int) {
+	type group struct {
+		first   int
+		sources []int
+		fuzzy   []int
+	}
+	byKey := map[string]*group{}
+	var groups []*group
+	drop := map[int]bool{}
+	for i, t := range tracks {
+		key := track.RecordingKey(t.Title, t.Artist)
+		g, ok := byKey[key]
+		if !ok {
+			g = &group{first: i, sources: []int{source[i]}}
+			byKey[key] = g
+			continue
+		}
+		if source[i] == source[g.first] {
+			continue
+		}
+		if len(g.sources) == 1 {
+			groups = append(groups, g)
+		}
+		if !slices.Contains(g.sources, source[i]) {
+			g.sources = append(g.sources, source[i])
+		}
+		drop[i] = true
+		first := tracks[g.first]
+		if !strings.EqualFold(strings.TrimSpace(t.Title), strings.TrimSpace(first.Title)) ||
+			!strings.EqualFold(strings.TrimSpace(t.Artist), strings.TrimSpace(first.Artist)) {
+			g.fuzzy = append(g.fuzzy, i)
+		}
+	}
+	if len(groups) == 0 {
+		return tracks, source
+	}
+
+	fmt.Printf("Merged %d track(s) found in more than one input, keeping the first input's copy:\n", len(groups))
+	for _, g := range groups {
+		names := make([]string, len(g.sources))
+		for k, s := range g.sources {
+			names[k] = specs[s].path
+		}
+		first := tracks[g.first]
+		fmt.Printf("  - %q by %s: %s\n", first.Title, first.Artist, strings.Join(names, ", "))
+		for _, i := range g.fuzzy {
+			fmt.Printf("      also as %q by %s in %s\n", tracks[i].Title, tracks[i].Artist, specs[source[i]].path)
+		}
+	}
+	keptTracks := make([]track.Track, 0, len(tracks)-len(drop))
+	keptSource := make([]int, 0, len(tracks)-len(drop))
+	for i := range tracks {
+		if !drop[i] {
+			keptTracks = append(keptTracks, tracks[i])
+			keptSource = append(keptSource, source[i])
+		}
+	}
+	return keptTracks, keptSource
+}
+
+// blendInputs draws a limit-sized set from several libraries in proportion to their
+// weights. Without a limit every track is used and the weights have nothing to decide.
+func blendInputs(ctx context.Context, tracks []track.Track, source []int, specs []inputSpec,
+	limit int) ([]track.Track, error) {
+	weights := make([]float64, len(specs))
+	weighted := false
+	for i, s := range specs {
+		weights[i] = s.weight
+		weighted = weighted || s.weight != 1
+	}
+	if limit <= 0 || limit >= len(tracks) {
+		if weighted {
+			fmt.Println("Note: input weights only matter with --limit; using every track from every input")
+		}
+		return tracks, nil
+	}
+
+	picked, err := strategy.Blend(ctx, tracks, source, weights, limit)
+	if err != nil {
+		return nil, err
+	}
+	blended := make([]track.Track, len(picked))
+	counts := make([]int, len(specs))
+	for k, i := range picked {
+		blended[k] = tracks[i]
+		counts[source[i]]++
+	}
+	fmt.Printf("Blended %d tracks from %d inputs:\n", len(blended), len(specs))
+	for i, s := range specs {
+		fmt.Printf("  %3d from %s (weight %g)\n", counts[i], s.path, s.weight)
+	}
+	return blended, nil
+}
@@ -0,0 +1,366 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+
+	"github.com/YakDriver/magicmix/internal/playlistio"
+)
+
+// job is a job file for `magicmix run`: one whole pipeline, from the inputs to where
+// the set goes and who hears about it, so a scheduler runs `magicmix run nightly.yaml`
+// instead of a long command line. It looks like
+//
+//	inputs: [library.csv, promos.csv:0.3]
+//	enrich:
+//	  infer-energy: true
+//	  check-tags: fix
+//	strategy:
+//	  name: flow
+//	  seed: 42
+//	constraints:
+//	  limit: 40
+//	  place: ["tag:anthem@last:30m"]
+//	outputs: [sets/friday.csv, sets/friday.m3u8]
+//	notify:
+//	  command: ./upload.sh
+//	  webhook: https://hooks.example.com/magicmix
+//
+// Every setting in enrich, strategy, and constraints is a flag of the main command
+// (strategy's name is --strategy); the sections only group them for the reader.
+// Relative paths are relative to the job file, so the job runs the same from any
+// working directory.
+type job struct {
+	Inputs      []string  `yaml:"inputs"`
+	Enrich      jobFlags  `yaml:"enrich"`
+	Strategy    jobFlags  `yaml:"strategy"`
+	Constraints jobFlags  `yaml:"constraints"`
+	Outputs     []string  `yaml:"outputs"`
+	Notify      jobNotify `yaml:"notify"`
+}
+
+// jobFlags maps flag names (without dashes) to their values, several for a
+// repeatable flag, as a profile does.
+type jobFlags map[string]jobValue
+
+// jobValue is one setting: a scalar, or a list of them for a repeatable flag.
+type jobValue []string
+
+func (v *jobValue) UnmarshalYAML(node *yaml.Node) error {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		*v = jobValue{node.Value}
+		return nil
+	case yaml.SequenceNode:
+		values := make(jobValue, len(node.Content))
+		for i, item := range node.Content {
+			if item.Kind != yaml.ScalarNode {
+				return fmt.Errorf("line %d: want a string, number, or boolean", item.Line)
+			}
+			values[i] = item.Value
+		}
+		*v = values
+		return nil
+	}
+	return fmt.Errorf("line %d: want a string, number, boolean, or a list of them", node.Line)
+}
+
+// jobNotify says who hears how a job went. Command runs with the outcome in its
+// environment (MAGICMIX_JOB, MAGICMIX_STATUS, MAGICMIX_ERROR, MAGICMIX_OUTPUTS); a
+// single string runs through sh, a list runs as is. Webhook receives the same as a
+// JSON POST. On is when to notify: always (the default), success, or failure.
+type jobNotify struct {
+	Command jobValue `yaml:"command"`
+	Webhook string   `yaml:"webhook"`
+	On      string   `yaml:"on"`
+}
+
+// jobReserved are the flags a job sets from its own fields.
+var jobReserved = []string{"input", "output", "output-format", "format", "strategy"}
+
+// jobPathFlags are the flags whose values are paths, resolved against the job file.
+var jobPathFlags = []string{"config", "variety-from", "decision-log", "checkpoint", "resume"}
+
+// jobNotifyTimeout bounds the notify command and webhook, so a hung hook can't hold
+// the scheduler's slot.
+const jobNotifyTimeout = 30 * time.Second
+
+// runJob handles `magicmix run JOBFILE`: it runs the pipeline the job file describes,
+// writes the set to each output, and notifies.
+func runJob(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix run", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	dryRun := fs.Bool("dry-run", false, "Print the command the job runs, and do nothing")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix run [--dry-run] JOBFILE\n\n")
+		_, _ = fmt.Fprintf(w, "Run the whole pipeline a YAML (or JSON) job file describes: inputs, enrichment,\n")
+		_, _ = fmt.Fprintf(w, "strategy, constraints, outputs, and notifications.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errors.New("one job file is required")
+	}
+	path := fs.Arg(0)
+	j, err := loadJob(path)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(path)
+	mainArgs, err := j.args(dir)
+	if err != nil {
+		return fmt.Errorf("job %s: %w", path, err)
+	}
+	outputs := make([]string, len(j.Outputs))
+	for i, o := range j.Outputs {
+		outputs[i] = jobPath(dir, o)
+	}
+	if *dryRun {
+		fmt.Printf("magicmix %s\n", shellJoin(mainArgs))
+		for _, o := range outputs[min(1, len(outputs)):] {
+			fmt.Printf("then copy the set to %s\n", o)
+		}
+		return nil
+	}
+
+	start := time.Now()
+	jobErr := run(ctx, mainArgs)
+	if jobErr == nil && len(outputs) > 1 {
+		jobErr = copyJobOutputs(ctx, outputs[0], outputs[1:])
+	}
+	if jobErr != nil {
+		jobErr = fmt.Errorf("job %s: %w", path, jobErr)
+	}
+	if err := j.Notify.send(ctx, path, outputs, time.Since(start), jobErr); err != nil {
+		return errors.Join(jobErr, fmt.Errorf("job %s: notify: %w", path, err))
+	}
+	return jobErr
+}
+
+// loadJob reads and checks a job file. Unknown fields are an error, so a typo can't
+// pass silently.
+func loadJob(path string) (job, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return job{}, fmt.Errorf("read job: %w", err)
+	}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	var j job
+	if err := dec.Decode(&j); err != nil {
+		return job{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(j.Inputs) == 0 {
+		return job{}, fmt.Errorf("job %s: inputs is required", path)
+	}
+	if len(j.Outputs) > 1 {
+		if slices.Contains(j.Outputs, playlistio.Stdio) {
+			return job{}, fmt.Errorf("job %s: standard output (-) must be the only output", path)
+		}
+		if playlistio.FormatOf(j.Outputs[0]) != playlistio.CSV {
+			return job{}, fmt.Errorf("job %s: with several outputs, list a CSV first: the others are copied from it", path)
+		}
+	}
+	switch j.Notify.On {
+	case "", "always", "success", "failure":
+	default:
+		return job{}, fmt.Errorf("job %s: notify on %q: want always, success, or failure", path, j.Notify.On)
+	}
+	return j, nil
+}
+
+// args is the main command line the job runs, in a stable order: the inputs, each
+// section's flags by name, and the first output.
+func (j job) args(dir string) ([]string, error) {
+	var args []string
+	for _, in := range j.Inputs {
+		spec, err := parseInputSpec(in)
+		if err != nil {
+			return nil, err
+		}
+		value := jobPath(dir, spec.path)
+		if spec.weight != 1 {
+			value += ":" + strconv.FormatFloat(spec.weight, 'g', -1, 64)
+		}
+		args = append(args, "--input", value)
+	}
+	seen := map[string]string{}
+	for _, section := range []struct {
+		name  string
+		flags jobFlags
+	}{{"enrich", j.Enrich}, {"strategy", j.Strategy}, {"constraints", j.Constraints}} {
+		names := make([]string, 0, len(section.flags))
+		for name := range section.flags {
+			names = append(names, name)
+		}
+		slices.Sort(names)
+		for _, name := range names {
+			flagName := strings.TrimLeft(name, "-")
+			if section.name == "strategy" && flagName == "name" {
+				flagName = "strategy"
+			} else if slices.Contains(jobReserved, flagName) {
+				return nil, fmt.Errorf("%s: %s is set by the job itself (use inputs, outputs, or strategy's name)", section.name, name)
+			}
+			if other, ok := seen[flagName]; ok {
+				return nil, fmt.Errorf("%s: %s is already set in %s", section.name, name, other)
+			}
+			seen[flagName] = section.name
+			for _, v := range section.flags[name] {
+				if slices.Contains(jobPathFlags, flagName) {
+					v = jobPath(dir, v)
+				}
+				args = append(args, "--"+flagName+"="+v)
+			}
+		}
+	}
+	if len(j.Outputs) > 1 && (seen["sets"] != "" || seen["set-size"] != "") {
+		return nil, errors.New("sets and set-size write one file per set; give one output")
+	}
+	if len(j.Outputs) > 0 {
+		args = append(args, "--output", jobPath(dir, j.Outputs[0]))
+	}
+	return args, nil
+}
+
+// jobPath resolves a path in a job file against the file's directory. Standard input
+// and output stay as they are.
+func jobPath(dir, path string) string {
+	if path == playlistio.Stdio || path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(dir, path)
+}
+
+// copyJobOutputs writes the set in the CSV at from to each of the other outputs, in
+// the format its name calls for.
+func copyJobOutputs(ctx context.Context, from string, outputs []string) error {
+	pl, err := loadLibraryAs(ctx, from, true, libraryFormat{name: playlistio.CSV})
+	if err != nil {
+		return fmt.Errorf("read the set back from %s: %w", from, err)
+	}
+	pl.Warnings = nil
+	var warnings []string
+	for _, o := range outputs {
+		f, err := outputFormat("", o)
+		if err != nil {
+			return err
+		}
+		w, err := saveOutput(ctx, o, f, pl)
+		if err != nil {
+			return fmt.Errorf("write %s: %w", o, err)
+		}
+		warnings = append(warnings, w...)
+		fmt.Printf("Copied the set to %s\n", o)
+	}
+	printWarnings(warnings)
+	return nil
+}
+
+// jobOutcome is what a notification says about a finished job.
+type jobOutcome struct {
+	Job     string   `json:"job"`
+	Status  string   `json:"status"`
+	Error   string   `json:"error,omitempty"`
+	Outputs []string `json:"outputs,omitempty"`
+	Seconds float64  `json:"seconds"`
+}
+
+// send runs the notify command and posts to the webhook, when n says to for this
+// outcome. Both are tried; their errors are joined.
+func (n jobNotify) send(ctx context.Context, path string, outputs []string, elapsed time.Duration, jobErr error) error {
+	status := "ok"
+	if jobErr != nil {
+		status = "failed"
+	}
+	if (n.On == "success" && jobErr != nil) || (n.On == "failure" && jobErr == nil) {
+		return nil
+	}
+	outcome := jobOutcome{Job: path, Status: status, Outputs: outputs, Seconds: elapsed.Round(time.Millisecond).Seconds()}
+	if jobErr != nil {
+		outcome.Error = jobErr.Error()
+	}
+	ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), jobNotifyTimeout)
+	defer cancel()
+
+	var errs []error
+	if len(n.Command) > 0 {
+		errs = append(errs, n.runCommand(ctx, outcome))
+	}
+	if n.Webhook != "" {
+		errs = append(errs, n.post(ctx, outcome))
+	}
+	return errors.Join(errs...)
+}
+
+func (n jobNotify) runCommand(ctx context.Context, outcome jobOutcome) error {
+	var cmd *exec.Cmd
+	if len(n.Command) == 1 {
+		cmd = exec.CommandContext(ctx, "sh", "-c", n.Command[0])
+	} else {
+		cmd = exec.CommandContext(ctx, n.Command[0], n.Command[1:]...)
+	}
+	cmd.Env = append(os.Environ(),
+		"MAGICMIX_JOB="+outcome.Job,
+		"MAGICMIX_STATUS="+outcome.Status,
+		"MAGICMIX_ERROR="+outcome.Error,
+		"MAGICMIX_OUTPUTS="+strings.Join(outcome.Outputs, string(os.PathListSeparator)))
+	cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("command: %w", err)
+	}
+	return nil
+}
+
+func (n jobNotify) post(ctx context.Context, outcome jobOutcome) error {
+	body, err := json.Marshal(outcome)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, n.Webhook, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("webhook: %w", err)
+	}
+	_ = resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook: %s", resp.Status)
+	}
+	return nil
+}
+
+// shellJoin quotes args for a shell where they need it, for --dry-run.
+func shellJoin(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		if a == "" || strings.ContainsAny(a, " \t\n'\"\\$`*?[]#~;&|<>(){}") {
+			a = "'" + strings.ReplaceAll(a, "'", `'\''`) + "'"
+		}
+		quoted[i] = a
+	}
+	return strings.Join(quoted, " ")
+}
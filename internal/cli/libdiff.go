@@ -0,0 +1,205 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runLibdiff handles `magicmix libdiff OLD NEW`: it compares two snapshots of a
+// library (see strategy.DiffLibraries), listing the tracks added, removed, and
+// retagged, and how the changes moved its key coverage, tempo gaps, and partners.
+// --json prints the same report as JSON instead; --output also writes it to a file.
+func runLibdiff(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix libdiff", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputFormatName := fs.String("input-format", "", "Input format of both libraries (default: from each file's extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	asJSON := fs.Bool("json", false, "Print the report as JSON instead of text")
+	outputPath := fs.String("output", "", "Also write the report as JSON to this file")
+	noCache := fs.Bool("no-cache", false, "Parse the inputs afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix libdiff [--json] [--output FILE] OLD NEW\n\n")
+		_, _ = fmt.Fprintf(w, "Compare two snapshots of a library: the tracks added, removed, and retagged, and\n")
+		_, _ = fmt.Fprintf(w, "how the changes affect sorting (key coverage, BPM gaps, partnerless tracks).\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errors.New("an old and a new library are required")
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	oldPath, newPath := fs.Arg(0), fs.Arg(1)
+	format := libraryFormat{name: inputName, energyField: *energyField}
+	before, err := loadLibraryAs(ctx, oldPath, *noCache, format)
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", oldPath, err)
+	}
+	after, err := loadLibraryAs(ctx, newPath, *noCache, format)
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", newPath, err)
+	}
+	defer printWarnings(append(before.Warnings, after.Warnings...))
+
+	diff := strategy.DiffLibraries(before.Tracks, after.Tracks)
+	report := newLibdiffReport(oldPath, newPath, diff)
+	if *outputPath != "" {
+		f, err := os.Create(*outputPath)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", *outputPath, err)
+		}
+		if err := writeLibdiffJSON(f, report); err != nil {
+			_ = f.Close()
+			return fmt.Errorf("failed to write %s: %w", *outputPath, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", *outputPath, err)
+		}
+	}
+	if *asJSON {
+		return writeLibdiffJSON(os.Stdout, report)
+	}
+	return writeLibdiffText(os.Stdout, report)
+}
+
+type libdiffChange struct {
+	matrixTrack
+	Fields []strategy.FieldChange `json:"changes"`
+}
+
+type libdiffKey struct {
+	Key    string `json:"key"`
+	Before int    `json:"before"`
+	After  int    `json:"after"`
+}
+
+type libdiffReport struct {
+	Old     string                `json:"old"`
+	New     string                `json:"new"`
+	Added   []matrixTrack         `json:"added"`
+	Removed []matrixTrack         `json:"removed"`
+	Changed []libdiffChange       `json:"changed"`
+	Keys    []libdiffKey          `json:"keys"`
+	Before  strategy.LibraryShape `json:"before"`
+	After   strategy.LibraryShape `json:"after"`
+}
+
+func newLibdiffReport(oldPath, newPath string, d strategy.LibraryDiff) libdiffReport {
+	compact := func(tracks []track.Track) []matrixTrack {
+		out := make([]matrixTrack, len(tracks))
+		for i, t := range tracks {
+			out[i] = matrixTrack{Title: t.Title, Artist: t.Artist, Key: t.KeyString(), BPM: t.TempoString(), Energy: t.Energy}
+		}
+		return out
+	}
+	r := libdiffReport{Old: oldPath, New: newPath, Added: compact(d.Added), Removed: compact(d.Removed),
+		Changed: make([]libdiffChange, len(d.Changed)), Before: d.Before, After: d.After}
+	for i, c := range d.Changed {
+		r.Changed[i] = libdiffChange{matrixTrack: compact([]track.Track{c.After})[0], Fields: c.Fields}
+	}
+	for i, k := range strategy.WheelKeys() {
+		r.Keys = append(r.Keys, libdiffKey{Key: k.String(), Before: d.Before.Keys[i], After: d.After.Keys[i]})
+	}
+	return r
+}
+
+func writeLibdiffJSON(w io.Writer, r libdiffReport) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+func writeLibdiffText(w io.Writer, r libdiffReport) error {
+	_, _ = fmt.Fprintf(w, "%s -> %s: %d tracks -> %d; %d added, %d removed, %d retagged\n",
+		r.Old, r.New, r.Before.Tracks, r.After.Tracks, len(r.Added), len(r.Removed), len(r.Changed))
+	list := func(heading string, tracks []matrixTrack) {
+		if len(tracks) == 0 {
+			return
+		}
+		_, _ = fmt.Fprintf(w, "\n%s:\n", heading)
+		for _, t := range tracks {
+			_, _ = fmt.Fprintf(w, "  %-40s %4s %6s\n", truncate(t.Artist+" - "+t.Title, 40), t.Key, t.BPM)
+		}
+	}
+	list("Added", r.Added)
+	list("Removed", r.Removed)
+	if len(r.Changed) > 0 {
+		_, _ = fmt.Fprintf(w, "\nRetagged:\n")
+		for _, c := range r.Changed {
+			parts := make([]string, len(c.Fields))
+			for i, f := range c.Fields {
+				parts[i] = fmt.Sprintf("%s %s -> %s", f.Field, orNone(f.Before), orNone(f.After))
+			}
+			_, _ = fmt.Fprintf(w, "  %-40s %s\n", truncate(c.Artist+" - "+c.Title, 40), strings.Join(parts, ", "))
+		}
+	}
+
+	_, _ = fmt.Fprintf(w, "\nKeys covered: %d of 24 -> %d of 24\n", r.Before.KeysCovered, r.After.KeysCovered)
+	var gained, lost []string
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, k := range r.Keys {
+		switch {
+		case k.Before == 0 && k.After > 0:
+			gained = append(gained, k.Key)
+		case k.Before > 0 && k.After == 0:
+			lost = append(lost, k.Key)
+		}
+		if k.Before != k.After {
+			_, _ = fmt.Fprintf(tw, "  %s\t%d -> %d\t%+d\n", k.Key, k.Before, k.After, k.After-k.Before)
+		}
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	if len(gained) > 0 {
+		_, _ = fmt.Fprintf(w, "  Now covered: %s\n", strings.Join(gained, ", "))
+	}
+	if len(lost) > 0 {
+		_, _ = fmt.Fprintf(w, "  No longer covered: %s\n", strings.Join(lost, ", "))
+	}
+
+	_, _ = fmt.Fprintf(w, "BPM gaps over %.0f%%: %s -> %s\n", strategy.DefaultPartnerWindow,
+		bpmGaps(r.Before.BPMGaps), bpmGaps(r.After.BPMGaps))
+	_, _ = fmt.Fprintf(w, "Tracks with no partner: %d -> %d\n", r.Before.Partnerless, r.After.Partnerless)
+	_, _ = fmt.Fprintf(w, "Mean partners per track: %.1f -> %.1f\n", r.Before.MeanPartners, r.After.MeanPartners)
+	return nil
+}
+
+// bpmGaps lists tempo gaps as "128-140, 150-174", or "none".
+func bpmGaps(gaps []strategy.BPMGap) string {
+	if len(gaps) == 0 {
+		return "none"
+	}
+	parts := make([]string, len(gaps))
+	for i, g := range gaps {
+		parts[i] = fmt.Sprintf("%g-%g", g.From, g.To)
+	}
+	return strings.Join(parts, ", ")
+}
+
+func orNone(s string) string {
+	if s == "" {
+		return "(none)"
+	}
+	return s
+}
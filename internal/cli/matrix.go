@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runMatrix handles `magicmix matrix ...`: it writes the pairwise transition matrix
+// for a library as CSV or JSON, for users who want to run their own clustering or
+// path experiments on the costs magicmix uses.
+func runMatrix(ctx context.Context, args []string) (retErr error) {
+	fs := flag.NewFlagSet("magicmix matrix", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input CSV file")
+	outputPath := fs.String("output", "", "File to write the matrix to (default stdout)")
+	format := fs.String("format", "csv", "Output format: csv or json")
+	measure := fs.String("measure", "cost", "Matrix values: cost (magicmix's pairwise transition cost) or camelot (key distance)")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix matrix --input FILE [--format csv|json] [--measure cost|camelot] [--output FILE]\n\n")
+		_, _ = fmt.Fprintf(w, "Write the pairwise transition matrix for a library. Row i, column j is\n")
+		_, _ = fmt.Fprintf(w, "playing track j right after track i.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *format != "csv" && *format != "json" {
+		return fmt.Errorf("unknown format %q (want csv or json)", *format)
+	}
+
+	playlist, err := loadLibrary(ctx, *inputPath, *noCache)
+	if err != nil {
+		return err
+	}
+
+	var values [][]float64
+	switch *measure {
+	case "cost":
+		values = strategy.PairwiseCosts(playlist.Tracks, strategy.DefaultWeights)
+	case "camelot":
+		values = keyDistances(playlist.Tracks)
+	default:
+		return fmt.Errorf("unknown measure %q (want cost or camelot)", *measure)
+	}
+
+	out := io.Writer(os.Stdout)
+	if *outputPath != "" {
+		f, err := os.Create(*outputPath)
+		if err != nil {
+			return fmt.Errorf("create matrix file: %w", err)
+		}
+		defer func() {
+			if cerr := f.Close(); cerr != nil && retErr == nil {
+				retErr = cerr
+			}
+		}()
+		out = f
+	}
+
+	if *format == "json" {
+		err = writeMatrixJSON(out, playlist.Tracks, *measure, values)
+	} else {
+		err = writeMatrixCSV(out, playlist.Tracks, values)
+	}
+	if err != nil {
+		return err
+	}
+	// On stdout the matrix is the whole output, so it isn't followed by a report.
+	if *outputPath != "" {
+		fmt.Printf("Wrote %dx%d %s matrix to %s\n", len(values), len(values), *measure, *outputPath)
+		printWarnings(playlist.Warnings)
+	}
+	return nil
+}
+
+// keyDistances is the Camelot distance from each track's exit key to every other
+// track's entry key.
+func keyDistances(tracks []track.Track) [][]float64 {
+	dist := make([][]float64, len(tracks))
+	for i, a := range tracks {
+		dist[i] = make([]float64, len(tracks))
+		for j, b := range tracks {
+			if i != j {
+				dist[i][j] = float64(a.ExitKey().Distance(b.EntryKey()))
+			}
+		}
+	}
+	return dist
+}
+
+func matrixLabel(t track.Track) string {
+	return t.Artist + " - " + t.Title
+}
+
+// writeMatrixCSV writes a labeled square matrix: the header row and first column name
+// the tracks.
+func writeMatrixCSV(w io.Writer, tracks []track.Track, values [][]float64) error {
+	cw := csv.NewWriter(w)
+	header := make([]string, 0, len(tracks)+1)
+	header = append(header, "from \\ to")
+	for _, t := range tracks {
+		header = append(header, matrixLabel(t))
+	}
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	for i, row := range values {
+		rec := make([]string, 0, len(row)+1)
+		rec = append(rec, matrixLabel(tracks[i]))
+		for _, v := range row {
+			rec = append(rec, strconv.FormatFloat(v, 'f', 4, 64))
+		}
+		if err := cw.Write(rec); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+type matrixTrack struct {
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Key    string `json:"key"`
+	BPM    string `json:"bpm"`
+	Energy int    `json:"energy"`
+}
+
+type matrixFile struct {
+	Measure string        `json:"measure"`
+	Tracks  []matrixTrack `json:"tracks"`
+	Values  [][]float64   `json:"values"`
+}
+
+func writeMatrixJSON(w io.Writer, tracks []track.Track, measure string, values [][]float64) error {
+	file := matrixFile{Measure: measure, Values: values, Tracks: make([]matrixTrack, len(tracks))}
+	for i, t := range tracks {
+		file.Tracks[i] = matrixTrack{Title: t.Title, Artist: t.Artist, Key: t.KeyString(),
+			BPM: t.TempoString(), Energy: t.Energy}
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(file)
+}
@@ -0,0 +1,234 @@
+package cli
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// The tempo range the bpm step folds half- and double-time tags into.
+const (
+	normalizeMinBPM = 70.0
+	normalizeMaxBPM = 180.0
+)
+
+// normalizeStep is one named step of the preprocessing pipeline --normalize runs
+// before sorting. apply returns the tracks and the parallel sources it leaves, and a
+// line for each change it made.
+type normalizeStep struct {
+	name  string
+	help  string
+	apply func(tracks []track.Track, sources []int, drops *dropLog) ([]track.Track, []int, []string)
+}
+
+// normalizeSteps is the pipeline, in the order its steps run whatever order
+// --normalize names them in: tags are corrected before names are cleaned, and names
+// are cleaned before duplicates are looked for.
+var normalizeSteps = []normalizeStep{
+	{"bpm", fmt.Sprintf("fold half- and double-time tempos into %.0f-%.0f BPM", normalizeMinBPM, normalizeMaxBPM), normalizeBPM},
+	{"energy", "rescale a library tagged 1-10 to the 0-100 energy scale", normalizeEnergy},
+	{"keys", "drop key changes that repeat the key before them (8A/8A)", normalizeKeys},
+	{"names", "trim and collapse the spacing of titles, artists, and albums", normalizeNames},
+	{"dedup", "drop duplicate copies of a recording, keeping the first", normalizeDedup},
+}
+
+// stepNames lists the pipeline's step names, in order.
+func stepNames() []string {
+	names := make([]string, len(normalizeSteps))
+	for i, s := range normalizeSteps {
+		names[i] = s.name
+	}
+	return names
+}
+
+// normalizeHelp describes the steps for the --normalize usage line.
+func normalizeHelp() string {
+	parts := make([]string, len(normalizeSteps))
+	for i, s := range normalizeSteps {
+		parts[i] = s.name + " (" + s.help + ")"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// parseNormalize reads --normalize: a comma-separated list of steps to run, where
+// "all" turns on every step and "-name" turns one off again ("all,-dedup"). dedup
+// adds the dedup step, for --dedup. The steps come back in pipeline order.
+func parseNormalize(spec string, dedup bool) ([]normalizeStep, error) {
+	on := map[string]bool{"dedup": dedup}
+	for _, part := range strings.Split(spec, ",") {
+		name := strings.ToLower(strings.TrimSpace(part))
+		if name == "" {
+			continue
+		}
+		enable := !strings.HasPrefix(name, "-")
+		name = strings.TrimPrefix(name, "-")
+		if name == "all" {
+			for _, s := range normalizeSteps {
+				on[s.name] = enable
+			}
+			continue
+		}
+		if !slices.Contains(stepNames(), name) {
+			return nil, fmt.Errorf("--normalize: unknown step %q (want all or %s)", name, strings.Join(stepNames(), ", "))
+		}
+		on[name] = enable
+	}
+	var steps []normalizeStep
+	for _, s := range normalizeSteps {
+		if on[s.name] {
+			steps = append(steps, s)
+		}
+	}
+	return steps, nil
+}
+
+// runNormalize applies steps in order, printing what each one changed.
+func runNormalize(steps []normalizeStep, tracks []track.Track, sources []int, drops *dropLog) ([]track.Track, []int) {
+	for _, s := range steps {
+		var changes []string
+		tracks, sources, changes = s.apply(tracks, sources, drops)
+		if len(changes) == 0 {
+			continue
+		}
+		fmt.Printf("Normalized %d track(s) (--normalize %s):\n", len(changes), s.name)
+		for i, c := range changes {
+			if i == maxWarningLines {
+				fmt.Printf("  ... and %d more\n", len(changes)-maxWarningLines)
+				break
+			}
+			fmt.Printf("  - %s\n", c)
+		}
+	}
+	return tracks, sources
+}
+
+// normalizeBPM doubles tempos below normalizeMinBPM and halves those above
+// normalizeMaxBPM, the usual misreadings of a tempo by half or double time. A
+// variable-tempo track's end tempo moves with its start.
+func normalizeBPM(tracks []track.Track, sources []int, _ *dropLog) ([]track.Track, []int, []string) {
+	var changes []string
+	out := slices.Clone(tracks)
+	for i, t := range out {
+		if t.BPM <= 0 {
+			continue
+		}
+		factor := 1.0
+		for t.BPM*factor < normalizeMinBPM {
+			factor *= 2
+		}
+		for t.BPM*factor > normalizeMaxBPM {
+			factor /= 2
+		}
+		if factor == 1 {
+			continue
+		}
+		before := t.TempoString()
+		out[i].BPM *= factor
+		out[i].BPMEnd *= factor
+		changes = append(changes, fmt.Sprintf("%q by %s: %s BPM to %s", t.Title, t.Artist, before, out[i].TempoString()))
+	}
+	return out, sources, changes
+}
+
+// normalizeEnergy multiplies tagged energies by ten when none is above 10, as a
+// library tagged on a 1-10 scale reads; inferred energies are already 0-100.
+func normalizeEnergy(tracks []track.Track, sources []int, _ *dropLog) ([]track.Track, []int, []string) {
+	tagged := 0
+	for _, t := range tracks {
+		if t.EnergyInferred {
+			continue
+		}
+		if t.Energy > 10 {
+			return tracks, sources, nil
+		}
+		if t.Energy > 0 {
+			tagged++
+		}
+	}
+	if tagged == 0 {
+		return tracks, sources, nil
+	}
+	var changes []string
+	out := slices.Clone(tracks)
+	for i, t := range out {
+		if t.EnergyInferred || t.Energy == 0 {
+			continue
+		}
+		out[i].Energy *= 10
+		changes = append(changes, fmt.Sprintf("%q by %s: energy %d to %d", t.Title, t.Artist, t.Energy, out[i].Energy))
+	}
+	return out, sources, changes
+}
+
+// normalizeKeys drops each key change that repeats the key before it, so a track
+// tagged "8A/8A" reads as staying in 8A. Key notations themselves (Open Key,
+// musical) are converted to Camelot as the input is read.
+func normalizeKeys(tracks []track.Track, sources []int, _ *dropLog) ([]track.Track, []int, []string) {
+	var changes []string
+	out := slices.Clone(tracks)
+	for i, t := range out {
+		if len(t.Modulations) == 0 {
+			continue
+		}
+		var mods []track.Key
+		prev := t.Key
+		for _, k := range t.Modulations {
+			if k != prev {
+				mods = append(mods, k)
+			}
+			prev = k
+		}
+		if len(mods) == len(t.Modulations) {
+			continue
+		}
+		out[i].Modulations = mods
+		changes = append(changes, fmt.Sprintf("%q by %s: key %s to %s", t.Title, t.Artist, t.KeyString(), out[i].KeyString()))
+	}
+	return out, sources, changes
+}
+
+// normalizeNames trims titles, artists, and albums and collapses runs of spaces in
+// them, so stray spacing neither shows in the set nor keeps duplicates apart.
+func normalizeNames(tracks []track.Track, sources []int, _ *dropLog) ([]track.Track, []int, []string) {
+	var changes []string
+	out := slices.Clone(tracks)
+	for i := range out {
+		var fixed []string
+		for _, f := range []struct {
+			name string
+			val  *string
+		}{{"title", &out[i].Title}, {"artist", &out[i].Artist}, {"album", &out[i].Album}} {
+			if clean := strings.Join(strings.Fields(*f.val), " "); clean != *f.val {
+				fixed = append(fixed, fmt.Sprintf("%s %q to %q", f.name, *f.val, clean))
+				*f.val = clean
+			}
+		}
+		if len(fixed) > 0 {
+			changes = append(changes, strings.Join(fixed, ", "))
+		}
+	}
+	return out, sources, changes
+}
+
+// normalizeDedup removes the later copies of each recording (see
+// strategy.DuplicateIndexes), logging each in drops.
+func normalizeDedup(tracks []track.Track, sources []int, drops *dropLog) ([]track.Track, []int, []string) {
+	kept, dups := strategy.DuplicateIndexes(tracks)
+	if len(dups) == 0 {
+		return tracks, sources, nil
+	}
+	changes := make([]string, len(dups))
+	for i, d := range dups {
+		dup, of := tracks[d.Index], tracks[d.Of]
+		changes[i] = fmt.Sprintf("dropped %q by %s, a copy of %q", dup.Title, dup.Artist, of.Title)
+		drops.add(dup, fmt.Sprintf("duplicate of %q by %s (dedup)", of.Title, of.Artist))
+	}
+	keptTracks, keptSources := make([]track.Track, len(kept)), make([]int, len(kept))
+	for k, i := range kept {
+		keptTracks[k], keptSources[k] = tracks[i], sources[i]
+	}
+	return keptTracks, keptSources, changes
+}
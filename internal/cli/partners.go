@@ -0,0 +1,162 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runPartners handles `magicmix partners ...`: it counts each track's compatible
+// partners in the library (see strategy.CountPartners) and lists the tracks with too
+// few, with the keys and tempos to look for when buying around them. --output also
+// writes every track's counts as CSV.
+func runPartners(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix partners", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input library: CSV, JSON, Rekordbox XML, or a folder of audio files")
+	inputFormatName := fs.String("input-format", "", "Input format (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	outputPath := fs.String("output", "", "Also write every track's partner counts to this CSV file")
+	window := fs.Float64("bpm-window", strategy.DefaultPartnerWindow, "Largest tempo gap, in percent, between partners (half and double time count)")
+	minPartners := fs.Int("min-partners", 3, "Flag tracks with fewer partners than this into or out of them")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix partners --input FILE [--bpm-window 6] [--min-partners 3] [--output FILE]\n\n")
+		_, _ = fmt.Fprintf(w, "Count each track's compatible partners in the library, by key and tempo, and\n")
+		_, _ = fmt.Fprintf(w, "list the tracks with too few: they force jumps in any set they're in.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *window <= 0 {
+		return errors.New("--bpm-window must be positive")
+	}
+	if *minPartners < 1 {
+		return errors.New("--min-partners must be at least 1")
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, libraryFormat{name: inputName, energyField: *energyField})
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	defer printWarnings(playlist.Warnings)
+	tracks := playlist.Tracks
+	partners := strategy.CountPartners(tracks, *window)
+
+	if *outputPath != "" {
+		if err := writePartnersCSV(*outputPath, tracks, partners); err != nil {
+			return err
+		}
+	}
+
+	var flagged []int
+	for i, p := range partners {
+		if p.Fewest() < *minPartners {
+			flagged = append(flagged, i)
+		}
+	}
+	slices.SortStableFunc(flagged, func(i, j int) int {
+		return cmp.Or(cmp.Compare(partners[i].Fewest(), partners[j].Fewest()), cmp.Compare(partners[i].Either, partners[j].Either))
+	})
+	fmt.Printf("%d tracks from %s; %d have fewer than %d partners into or out of them (tempo within %g%%):\n",
+		len(tracks), *inputPath, len(flagged), *minPartners, *window)
+	for _, i := range flagged {
+		t, p := tracks[i], partners[i]
+		fmt.Printf("  %-40s %4s %6s  %2d in, %2d out; look for %s\n", truncate(matrixLabel(t), 40), t.KeyString(),
+			t.TempoString(), p.In, p.Out, partnerWanted(t, p, *minPartners, *window))
+	}
+	if *outputPath != "" {
+		fmt.Printf("Wrote partner counts for %d tracks to %s\n", len(tracks), *outputPath)
+	}
+	return nil
+}
+
+// partnerWanted describes the tracks that would give t partners on the side it lacks
+// them: the keys that fit, and the tempos within the window, e.g. "8A, 9A, 7A, or 8B
+// at 117-131 BPM".
+func partnerWanted(t track.Track, p strategy.Partners, minPartners int, window float64) string {
+	var keys []track.Key
+	add := func(k track.Key) { keys = append(keys, k, k.Transpose(1), k.Transpose(-1), k.Relative()) }
+	bpm := t.BPM
+	if p.In < minPartners {
+		add(t.EntryKey())
+	}
+	if p.Out < minPartners {
+		add(t.ExitKey())
+		if p.In >= minPartners {
+			bpm = t.ExitBPM()
+		}
+	}
+	if t.AnyKey() {
+		keys = nil
+	}
+	names := make([]string, 0, len(keys))
+	for _, k := range keys {
+		if name := k.String(); !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	var want string
+	switch len(names) {
+	case 0:
+		want = "any key"
+	case 1:
+		want = names[0]
+	default:
+		want = strings.Join(names[:len(names)-1], ", ") + ", or " + names[len(names)-1]
+	}
+	if bpm <= 0 {
+		return want
+	}
+	return fmt.Sprintf("%s at %.0f-%.0f BPM", want, math.Floor(bpm*(1-window/100)), math.Ceil(bpm*(1+window/100)))
+}
+
+// writePartnersCSV writes one row per track: who it is and its partner counts.
+func writePartnersCSV(path string, tracks []track.Track, partners []strategy.Partners) (retErr error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create partners file: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && retErr == nil {
+			retErr = cerr
+		}
+	}()
+	cw := csv.NewWriter(f)
+	if err := cw.Write([]string{"Title", "Artist", "Key", "BPM", "In", "Out", "Partners"}); err != nil {
+		return err
+	}
+	for i, t := range tracks {
+		p := partners[i]
+		if err := cw.Write([]string{t.Title, t.Artist, t.KeyString(), t.TempoString(),
+			strconv.Itoa(p.In), strconv.Itoa(p.Out), strconv.Itoa(p.Either)}); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
@@ -0,0 +1,221 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runPreviews handles `magicmix previews ...` (experimental): for each transition of
+// an ordered set it cuts a clip of the end of one track into the start of the next,
+// so every planned mix can be auditioned in a few minutes. By default it writes the
+// ffmpeg commands as a shell script; --render runs them.
+func runPreviews(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix previews", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the ordered set: CSV, JSON, Rekordbox XML, setlist, or a folder of audio files")
+	inputFormatName := fs.String("input-format", "", "Input format (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	musicDir := fs.String("music-dir", "", "Folder of audio files: relative locations resolve against it, and tracks with no location are found in it as \"Artist - Title\" or \"Title\"")
+	outDir := fs.String("out-dir", "previews", "Folder the clips are written to")
+	seconds := fs.Int("seconds", 30, "Seconds of each track in a clip: the end of the outgoing track and the start of the incoming one")
+	crossfade := fs.Int("crossfade", 0, "Overlap the two halves of each clip by this many seconds (0 = straight cut)")
+	scriptPath := fs.String("script", "", "Write the ffmpeg script to this file (default: standard output)")
+	render := fs.Bool("render", false, "Run ffmpeg to make the clips instead of writing a script")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix previews --input SET [--music-dir DIR] [--seconds 30] [--script FILE | --render]\n\n")
+		_, _ = fmt.Fprintf(w, "Experimental. Make a short clip of every transition in a set, the end of one track\n")
+		_, _ = fmt.Fprintf(w, "into the start of the next, with ffmpeg: as a shell script, or rendered directly.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *seconds < 1 {
+		return errors.New("--seconds must be at least 1")
+	}
+	if *crossfade < 0 || *crossfade >= *seconds {
+		return fmt.Errorf("--crossfade must be from 0 to %d seconds", *seconds-1)
+	}
+	if *render && *scriptPath != "" {
+		return errors.New("--render and --script can't be combined")
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, libraryFormat{name: inputName, energyField: *energyField})
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	defer printWarnings(playlist.Warnings)
+
+	files, err := trackFiles(playlist.Tracks, *musicDir)
+	if err != nil {
+		return err
+	}
+	previews, skipped := planPreviews(playlist.Tracks, files, *outDir, *seconds, *crossfade)
+	for _, s := range skipped {
+		fmt.Fprintf(os.Stderr, "Skipping %s: no audio file found\n", s)
+	}
+	if len(previews) == 0 {
+		return errors.New("no transitions to preview: tracks need a location, or a file in --music-dir")
+	}
+
+	if *render {
+		ffmpeg, err := exec.LookPath("ffmpeg")
+		if err != nil {
+			return fmt.Errorf("--render needs ffmpeg on the PATH: %w", err)
+		}
+		if err := os.MkdirAll(*outDir, 0o755); err != nil {
+			return fmt.Errorf("create %s: %w", *outDir, err)
+		}
+		for _, p := range previews {
+			cmd := exec.CommandContext(ctx, ffmpeg, p.args...)
+			cmd.Stdout, cmd.Stderr = os.Stdout, os.Stderr
+			if err := cmd.Run(); err != nil {
+				return fmt.Errorf("ffmpeg for %s: %w", p.clip, err)
+			}
+			fmt.Printf("%s  %s\n", p.clip, p.label)
+		}
+		fmt.Printf("Wrote %d transition previews to %s\n", len(previews), *outDir)
+		return nil
+	}
+
+	if *scriptPath == "" {
+		return writePreviewScript(os.Stdout, previews, *outDir)
+	}
+	f, err := os.Create(*scriptPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", *scriptPath, err)
+	}
+	if err := writePreviewScript(f, previews, *outDir); err != nil {
+		_ = f.Close()
+		return fmt.Errorf("failed to write %s: %w", *scriptPath, err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", *scriptPath, err)
+	}
+	fmt.Printf("Wrote an ffmpeg script for %d transition previews to %s\n", len(previews), *scriptPath)
+	return nil
+}
+
+// preview is one transition clip: the ffmpeg arguments that make it, where it goes,
+// and a label naming the transition and when it happens in the set.
+type preview struct {
+	clip  string
+	label string
+	args  []string
+}
+
+// planPreviews lays out a clip for each transition whose two tracks both have a file:
+// the last seconds of the outgoing track (ffmpeg seeks from its end, so its length
+// needn't be known), then the first seconds of the incoming one, cut straight or
+// crossfaded. Labels carry the set time of the transition while every track before it
+// has a length. skipped names the tracks with no file.
+func planPreviews(tracks []track.Track, files []string, outDir string, seconds, crossfade int) (previews []preview, skipped []string) {
+	for i, t := range tracks {
+		if files[i] == "" {
+			skipped = append(skipped, matrixLabel(t))
+		}
+	}
+	filter := "[0:a][1:a]concat=n=2:v=0:a=1[out]"
+	if crossfade > 0 {
+		filter = fmt.Sprintf("[0:a][1:a]acrossfade=d=%d[out]", crossfade)
+	}
+	secs := strconv.Itoa(seconds)
+	var elapsed time.Duration
+	timed := true
+	for i := 0; i+1 < len(tracks); i++ {
+		a, b := tracks[i], tracks[i+1]
+		if a.Duration == nil {
+			timed = false
+		} else {
+			elapsed += time.Duration(*a.Duration) * time.Second
+		}
+		if files[i] == "" || files[i+1] == "" {
+			continue
+		}
+		clip := filepath.Join(outDir, fmt.Sprintf("%02d-%s--%s.mp3", i+1, clipName(a.Title), clipName(b.Title)))
+		label := fmt.Sprintf("%s -> %s", matrixLabel(a), matrixLabel(b))
+		if timed {
+			label = fmt.Sprintf("at %s: %s", formatClock(elapsed), label)
+		}
+		previews = append(previews, preview{clip: clip, label: label, args: []string{
+			"-hide_banner", "-loglevel", "error", "-y",
+			"-sseof", "-" + secs, "-i", files[i],
+			"-t", secs, "-i", files[i+1],
+			"-filter_complex", filter, "-map", "[out]", clip,
+		}})
+	}
+	return previews, skipped
+}
+
+// clipName reduces a title to letters, digits, and dashes for a clip's file name.
+func clipName(title string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(title) {
+		if r >= 'a' && r <= 'z' || r >= '0' && r <= '9' {
+			b.WriteRune(r)
+			dash = false
+		} else if !dash && b.Len() > 0 {
+			b.WriteByte('-')
+			dash = true
+		}
+	}
+	name := strings.TrimSuffix(b.String(), "-")
+	if len(name) > 30 {
+		name = strings.TrimSuffix(name[:30], "-")
+	}
+	if name == "" {
+		name = "track"
+	}
+	return name
+}
+
+// writePreviewScript writes a POSIX shell script running ffmpeg once per preview.
+func writePreviewScript(w io.Writer, previews []preview, outDir string) error {
+	_, _ = fmt.Fprintf(w, "#!/bin/sh\n# Transition previews written by magicmix previews; needs ffmpeg.\nset -e\n")
+	_, _ = fmt.Fprintf(w, "mkdir -p %s\n", shellQuote(outDir))
+	for _, p := range previews {
+		quoted := make([]string, len(p.args))
+		for i, a := range p.args {
+			quoted[i] = shellQuote(a)
+		}
+		if _, err := fmt.Fprintf(w, "\n# %s\nffmpeg %s\n", p.label, strings.Join(quoted, " ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shellQuote quotes s for a POSIX shell, leaving plain words as they are.
+func shellQuote(s string) string {
+	if s != "" && strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || strings.ContainsRune("-_./:=", r))
+	}) < 0 {
+		return s
+	}
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,76 @@
+package cli
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+// progressEvery is how often --verbose redraws the progress line; a log that isn't a
+// terminal gets a new line every progressLogEvery instead.
+const (
+	progressEvery    = 200 * time.Millisecond
+	progressLogEvery = 2 * time.Second
+)
+
+// progressLine shows a sort's progress on w for --verbose: one line redrawn in place
+// on a terminal, or an occasional line in a log.
+type progressLine struct {
+	w        io.Writer
+	terminal bool
+	last     time.Time
+	shown    bool
+}
+
+func newProgressLine(f *os.File) *progressLine {
+	info, err := f.Stat()
+	return &progressLine{w: f, terminal: err == nil && info.Mode()&os.ModeCharDevice != 0}
+}
+
+// report is a strategy.ProgressFunc.
+func (l *progressLine) report(p strategy.Progress) {
+	every := progressLogEvery
+	if l.terminal {
+		every = progressEvery
+	}
+	if l.shown && time.Since(l.last) < every {
+		return
+	}
+	l.last, l.shown = time.Now(), true
+	if l.terminal {
+		_, _ = fmt.Fprintf(l.w, "\r\033[K%s", formatProgress(p))
+	} else {
+		_, _ = fmt.Fprintln(l.w, formatProgress(p))
+	}
+}
+
+// finish ends the redrawn line so later output starts on a fresh one.
+func (l *progressLine) finish() {
+	if l.terminal && l.shown {
+		_, _ = fmt.Fprintln(l.w)
+	}
+}
+
+// formatProgress renders p as "flow improving 3/60 · 412/412 placed · score 12.34 · 4.2s".
+func formatProgress(p strategy.Progress) string {
+	var b strings.Builder
+	b.WriteString(p.Strategy)
+	if p.Stage != "" {
+		b.WriteString(" " + p.Stage)
+	}
+	if p.Steps > 0 {
+		fmt.Fprintf(&b, " %d/%d", p.Step, p.Steps)
+	} else if p.Step > 0 {
+		fmt.Fprintf(&b, " %d", p.Step)
+	}
+	fmt.Fprintf(&b, " · %d/%d placed", p.Placed, p.Total)
+	if p.Score != 0 {
+		fmt.Fprintf(&b, " · score %.2f", p.Score)
+	}
+	fmt.Fprintf(&b, " · %.1fs", p.Elapsed.Seconds())
+	return b.String()
+}
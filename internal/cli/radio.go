@@ -0,0 +1,439 @@
+package cli
+
+import (
+	"cmp"
+	"context"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// jingle is a station ID or other placeholder the show plays at a set time.
+type jingle struct {
+	name   string
+	at     time.Duration
+	length time.Duration
+}
+
+// parseJingle reads a --jingle value: a time into the show, optionally followed by
+// "/LENGTH", as in "30m" or "1h/15s".
+func parseJingle(spec string, length time.Duration) (jingle, error) {
+	at, l, hasLength := strings.Cut(spec, "/")
+	j := jingle{length: length}
+	var err error
+	if j.at, err = time.ParseDuration(strings.TrimSpace(at)); err != nil || j.at < 0 {
+		return jingle{}, fmt.Errorf("--jingle %q: want TIME or TIME/LENGTH, e.g. 30m/15s", spec)
+	}
+	if hasLength {
+		if j.length, err = time.ParseDuration(strings.TrimSpace(l)); err != nil || j.length <= 0 {
+			return jingle{}, fmt.Errorf("--jingle %q: bad length", spec)
+		}
+	}
+	return j, nil
+}
+
+// parseEnergyTarget reads an --energy value written as WINDOW=LEVEL, e.g. "0m-20m=40"
+// or "last:30m=75".
+func parseEnergyTarget(spec string) (strategy.EnergyTarget, error) {
+	eq := strings.LastIndex(spec, "=")
+	if eq < 0 {
+		return strategy.EnergyTarget{}, fmt.Errorf("--energy %q: want WINDOW=LEVEL", spec)
+	}
+	window, err := strategy.ParseWindow(spec[:eq])
+	if err != nil {
+		return strategy.EnergyTarget{}, fmt.Errorf("--energy: %w", err)
+	}
+	level, err := strconv.Atoi(strings.TrimSpace(spec[eq+1:]))
+	if err != nil || level < 0 || level > 100 {
+		return strategy.EnergyTarget{}, fmt.Errorf("--energy %q: level must be 0-100", spec)
+	}
+	return strategy.EnergyTarget{Name: spec, Window: window, Energy: level}, nil
+}
+
+// runRadio handles `magicmix radio ...`: it fills a fixed-length show from a library,
+// with energy resets at the top of each hour, jingles at set times, and per-segment
+// energy targets, and writes the ordering plus a cue sheet.
+func runRadio(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix radio", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input CSV file or Rekordbox XML collection")
+	outputPath := fs.String("output", "", "Destination for the ordered show (default <input>_radio.csv; .m3u8/.m3u/.json/.xml write playlists)")
+	formatName := fs.String("output-format", "", "Output format: csv, m3u8, m3u, json, or rekordbox (default: from the --output extension)")
+	fs.StringVar(formatName, "format", "", "Alias of --output-format")
+	cuePath := fs.String("cue-sheet", "", "Destination for the cue sheet CSV (default <output>_cues.csv)")
+	runtime := fs.Duration("runtime", 0, "Total show length, e.g. 2h (required)")
+	resetEvery := fs.Duration("reset-every", time.Hour, "Put an energy reset at every multiple of this into the show (0 = none)")
+	jingleLength := fs.Duration("jingle-length", 10*time.Second, "Length of a --jingle that doesn't give one")
+	var jingleSpecs stringsFlag
+	fs.Var(&jingleSpecs, "jingle", "Jingle or station ID at TIME[/LENGTH] into the show, e.g. '30m' or '1h/15s' (repeatable)")
+	var energySpecs stringsFlag
+	fs.Var(&energySpecs, "energy", "Energy target WINDOW=LEVEL for a segment, e.g. 'first:20m=40' (repeatable)")
+	strategyName := fs.String("strategy", "flow", "Sorting strategy to apply")
+	seedFlag := fs.Int64("seed", 0, "Deterministic seed (0 = time-based)")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	broadcastSafe := fs.Bool("broadcast-safe", false, "Only play tracks whose license column clears them for broadcast; tracks with no license are left out")
+	region := fs.String("region", "", "Only play tracks cleared in this territory by their regions column, e.g. US; tracks with no regions stay")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix radio --input FILE --runtime 2h [options]\n\n")
+		_, _ = fmt.Fprintf(w, "Fill a fixed-length radio show: energy resets at the top of each hour, jingles\n")
+		_, _ = fmt.Fprintf(w, "at set times, and per-segment energy targets. Writes the ordering and a cue sheet.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *runtime <= 0 {
+		return errors.New("--runtime is required, e.g. --runtime 2h")
+	}
+	if *resetEvery < 0 {
+		return errors.New("--reset-every must be non-negative")
+	}
+
+	var jingles []jingle
+	for _, spec := range jingleSpecs {
+		j, err := parseJingle(spec, *jingleLength)
+		if err != nil {
+			return err
+		}
+		if j.at >= *runtime {
+			return fmt.Errorf("--jingle %q is after the end of the show", spec)
+		}
+		jingles = append(jingles, j)
+	}
+	slices.SortStableFunc(jingles, func(a, b jingle) int { return cmp.Compare(a.at, b.at) })
+	for i := range jingles {
+		jingles[i].name = fmt.Sprintf("ID %d", i+1)
+	}
+	output := *outputPath
+	if output == "" {
+		output = suffixedPath(*inputPath, "_radio")
+	}
+	outFormat, err := outputFormat(*formatName, output)
+	if err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		output = withFormatExt(output, outFormat)
+	}
+	var targets []strategy.EnergyTarget
+	for _, spec := range energySpecs {
+		target, err := parseEnergyTarget(spec)
+		if err != nil {
+			return err
+		}
+		targets = append(targets, target)
+	}
+
+	sorter, err := strategy.Get(*strategyName)
+	if err != nil {
+		return err
+	}
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	ctx = strategy.WithSeed(ctx, seed)
+
+	playlist, err := loadLibrary(ctx, *inputPath, *noCache)
+	if err != nil {
+		return err
+	}
+	warnings := playlist.Warnings
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, energyWarnings...)
+	clearance, err := newLoadFilter(0, 0, 0, 100, "", nil, "", 0, *broadcastSafe, *region)
+	if err != nil {
+		return err
+	}
+	var drops dropLog
+	cleared, _ := clearance.apply(playlist.Tracks, make([]int, len(playlist.Tracks)), &drops)
+	for _, d := range drops {
+		fmt.Printf("    %q by %s: %s\n", d.Track.Title, d.Track.Artist, d.Reason)
+	}
+	if len(cleared) == 0 {
+		return errors.New("no track in the library is cleared to play")
+	}
+	tracks, alternates := strategy.OnePerFamily(cleared)
+	if len(alternates) > 0 {
+		fmt.Printf("Skipped %d alternate version(s) of songs already in the library\n", len(alternates))
+	}
+	if missing := countMissingLengths(tracks); missing > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d track(s) have no length; timings assume the average length", missing))
+	}
+
+	// Jingles take their time out of the show; the music fills the rest.
+	music := *runtime
+	for _, j := range jingles {
+		music -= j.length
+	}
+	picked, err := strategy.FitRuntime(ctx, tracks, music.Seconds(), nil)
+	if err != nil {
+		return err
+	}
+	show := make([]track.Track, len(picked))
+	for k, i := range picked {
+		show[k] = tracks[i]
+	}
+
+	// Grid times are in show time; the ordering rules measure music time, so each mark
+	// moves earlier by the jingles that play before it.
+	var marks []float64
+	if *resetEvery > 0 {
+		for at := *resetEvery; at < *runtime; at += *resetEvery {
+			marks = append(marks, musicMinutes(at, jingles))
+		}
+	}
+	ctx = strategy.WithResets(ctx, strategy.ResetRule{At: marks})
+	musicTargets := slices.Clone(targets)
+	for i, target := range musicTargets {
+		if target.Window.Minutes && !target.Window.FromEnd {
+			musicTargets[i].Window.Lo = musicMinutes(time.Duration(target.Window.Lo*float64(time.Minute)), jingles)
+			musicTargets[i].Window.Hi = musicMinutes(time.Duration(target.Window.Hi*float64(time.Minute)), jingles)
+		}
+	}
+	ctx = strategy.WithEnergyTargets(ctx, musicTargets...)
+	warnings = append(warnings, checkInventory(ctx, show)...)
+
+	result, err := strategy.Sort(ctx, sorter, show)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, result.Warnings...)
+	fmt.Printf("Using seed %d\n", seed)
+
+	outputWarnings, err := saveOutput(ctx, output, outFormat, csvio.Playlist{
+		Header:   playlist.Header,
+		CRLF:     playlist.CRLF,
+		Encoding: playlist.Encoding,
+		Tracks:   result.Ordered,
+	})
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, outputWarnings...)
+
+	cues := buildCues(result.Ordered, jingles, *resetEvery, *runtime, targets)
+	cueSheet := *cuePath
+	if cueSheet == "" {
+		cueSheet = strings.TrimSuffix(output, filepath.Ext(output)) + "_cues.csv"
+	}
+	if err := writeCueSheet(cueSheet, cues); err != nil {
+		return err
+	}
+
+	end := time.Duration(0)
+	if len(cues) > 0 {
+		last := cues[len(cues)-1]
+		end = last.start + last.length
+	}
+	fmt.Printf("Wrote a %s show of %d tracks and %d jingle(s) using %s strategy to %s (cue sheet %s)\n",
+		formatClock(end), len(result.Ordered), len(jingles), sorter.Name(), output, cueSheet)
+	if short := *runtime - end; short > 0 {
+		fmt.Printf("The show runs %s short of %s; no remaining track fits the gap\n", formatClock(short), formatClock(*runtime))
+	}
+	printWarnings(warnings)
+	return nil
+}
+
+// musicMinutes converts a time into the show to minutes of music before it, leaving
+// out the jingles scheduled earlier.
+func musicMinutes(at time.Duration, jingles []jingle) float64 {
+	for _, j := range jingles {
+		if j.at < at {
+			at -= j.length
+		}
+	}
+	return max(at, 0).Minutes()
+}
+
+func countMissingLengths(tracks []track.Track) int {
+	missing := 0
+	for _, t := range tracks {
+		if t.Duration == nil || *t.Duration <= 0 {
+			missing++
+		}
+	}
+	return missing
+}
+
+// cue is one row of a cue sheet: a track or a jingle and when it starts.
+type cue struct {
+	start, length time.Duration
+	jingle        bool
+	title, artist string
+	t             track.Track
+	segment       string
+	note          string
+}
+
+// buildCues lays the ordered tracks and the jingles out on the show clock. Each jingle
+// plays at the track boundary nearest its time. A track starting nearest a reset mark
+// is noted as the hour's reset, or as a missed one when it doesn't drop in energy.
+func buildCues(ordered []track.Track, jingles []jingle, resetEvery, runtime time.Duration,
+	targets []strategy.EnergyTarget) []cue {
+	var cues []cue
+	elapsed := time.Duration(0)
+	next := 0
+	avgLength := avgTrackLength(ordered)
+	for k, t := range ordered {
+		length := avgLength
+		if t.Duration != nil && *t.Duration > 0 {
+			length = time.Duration(*t.Duration) * time.Second
+		}
+		for next < len(jingles) && jingles[next].at <= elapsed+length/2 {
+			j := jingles[next]
+			cues = append(cues, cue{start: elapsed, length: j.length, jingle: true, title: j.name})
+			elapsed += j.length
+			next++
+		}
+		c := cue{start: elapsed, length: length, title: t.Title, artist: t.Artist, t: t}
+		if k > 0 && strategy.IsReset(ordered[k-1], t) {
+			c.note = "reset"
+		}
+		cues = append(cues, c)
+		elapsed += length
+	}
+	for ; next < len(jingles); next++ {
+		j := jingles[next]
+		cues = append(cues, cue{start: elapsed, length: j.length, jingle: true, title: j.name})
+		elapsed += j.length
+	}
+
+	if resetEvery > 0 {
+		for at := resetEvery; at < runtime; at += resetEvery {
+			nearest := -1
+			for i, c := range cues {
+				if c.jingle || i == 0 {
+					continue
+				}
+				if nearest < 0 || absDuration(c.start-at) < absDuration(cues[nearest].start-at) {
+					nearest = i
+				}
+			}
+			if nearest < 0 {
+				continue
+			}
+			mark := "reset at " + formatClock(at)
+			if cues[nearest].note == "" {
+				mark = "missed reset at " + formatClock(at)
+			}
+			cues[nearest].note = mark
+		}
+	}
+
+	total := elapsed
+	for i := range cues {
+		for _, target := range targets {
+			lo, hi := target.Window.Lo, target.Window.Hi
+			if !target.Window.Minutes {
+				lo, hi = lo*total.Minutes(), hi*total.Minutes()
+			}
+			if target.Window.FromEnd {
+				lo, hi = total.Minutes()-hi, total.Minutes()-lo
+			}
+			if m := cues[i].start.Minutes(); m >= lo && m < hi {
+				cues[i].segment = target.Name
+				break
+			}
+		}
+	}
+	return cues
+}
+
+// setLength is how long tracks play back to back, counting a track with no length as
+// the average of the others.
+func setLength(tracks []track.Track) time.Duration {
+	avg := avgTrackLength(tracks)
+	total := time.Duration(0)
+	for _, t := range tracks {
+		if t.Duration != nil && *t.Duration > 0 {
+			total += time.Duration(*t.Duration) * time.Second
+		} else {
+			total += avg
+		}
+	}
+	return total
+}
+
+func avgTrackLength(tracks []track.Track) time.Duration {
+	known, sum := 0, 0
+	for _, t := range tracks {
+		if t.Duration != nil && *t.Duration > 0 {
+			known++
+			sum += *t.Duration
+		}
+	}
+	if known == 0 {
+		return 210 * time.Second
+	}
+	return time.Duration(sum/known) * time.Second
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
+
+// formatClock renders a duration as h:mm:ss.
+func formatClock(d time.Duration) string {
+	s := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%d:%02d:%02d", s/3600, (s%3600)/60, s%60)
+}
+
+// writeCueSheet writes the show's running order as CSV, one row per track or jingle.
+func writeCueSheet(path string, cues []cue) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("create cue sheet directory: %w", err)
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("create cue sheet: %w", err)
+	}
+	defer func() {
+		if cerr := f.Close(); cerr != nil && err == nil {
+			err = fmt.Errorf("close cue sheet: %w", cerr)
+		}
+	}()
+
+	w := csv.NewWriter(f)
+	if err := w.Write([]string{"Start", "Length", "Type", "Title", "Artist", "BPM", "Key", "Energy", "Segment", "Note"}); err != nil {
+		return err
+	}
+	for _, c := range cues {
+		row := []string{formatClock(c.start), formatClock(c.length), "track", c.title, c.artist,
+			c.t.TempoString(), c.t.KeyString(), strconv.Itoa(c.t.Energy), c.segment, c.note}
+		if c.jingle {
+			row = []string{formatClock(c.start), formatClock(c.length), "jingle", c.title, "", "", "", "", c.segment, c.note}
+		}
+		if err := w.Write(row); err != nil {
+			return err
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
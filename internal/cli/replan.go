@@ -0,0 +1,411 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// reaction is one line of a feedback file: how the floor took the set at a moment.
+type reaction struct {
+	at   time.Duration
+	good bool
+}
+
+// parseReaction reads a feedback line, "TIME REACTION [note]" with the fields split by
+// spaces or commas: a time into the set (42m, 1h5m, 0:42:00, or 42:00) and good or bad
+// (also +/-, up/down, yes/no). ok is false for a blank line, a # comment, or a header
+// starting with "time".
+func parseReaction(line string) (r reaction, ok bool, err error) {
+	fields := strings.FieldsFunc(line, func(c rune) bool { return c == ',' || c == ' ' || c == '\t' })
+	if len(fields) == 0 || strings.HasPrefix(fields[0], "#") || strings.EqualFold(fields[0], "time") {
+		return reaction{}, false, nil
+	}
+	if len(fields) < 2 {
+		return reaction{}, false, fmt.Errorf("feedback %q: want TIME and good or bad", line)
+	}
+	if r.at, err = parseSetTime(fields[0]); err != nil {
+		return reaction{}, false, fmt.Errorf("feedback %q: %w", line, err)
+	}
+	switch strings.ToLower(fields[1]) {
+	case "good", "+", "up", "yes":
+		r.good = true
+	case "bad", "-", "down", "no":
+	default:
+		return reaction{}, false, fmt.Errorf("feedback %q: reaction must be good or bad", line)
+	}
+	return r, true, nil
+}
+
+// parseSetTime reads a time into the set as a duration (42m) or a clock, h:mm:ss or
+// m:ss.
+func parseSetTime(s string) (time.Duration, error) {
+	if d, err := time.ParseDuration(s); err == nil && d >= 0 {
+		return d, nil
+	}
+	parts := strings.Split(s, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("time %q: want a duration (42m) or a clock (0:42:00)", s)
+	}
+	total := 0
+	for _, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("time %q: want a duration (42m) or a clock (0:42:00)", s)
+		}
+		total = total*60 + n
+	}
+	return time.Duration(total) * time.Second, nil
+}
+
+// readReactions reads a whole feedback file.
+func readReactions(r io.Reader) ([]reaction, error) {
+	var out []reaction
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		rc, ok, err := parseReaction(sc.Text())
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			out = append(out, rc)
+		}
+	}
+	return out, sc.Err()
+}
+
+// trackAt is the position of the track playing at a time into the set, counting a
+// track with no length as the average; times past the end fall on the last track.
+func trackAt(tracks []track.Track, at time.Duration) int {
+	avg := avgTrackLength(tracks)
+	start := time.Duration(0)
+	for i, t := range tracks {
+		length := avg
+		if t.Duration != nil && *t.Duration > 0 {
+			length = time.Duration(*t.Duration) * time.Second
+		}
+		if at < start+length {
+			return i
+		}
+		start += length
+	}
+	return len(tracks) - 1
+}
+
+// crowdOf gathers the tracks the reactions fell on.
+func crowdOf(set []track.Track, reactions []reaction) strategy.Crowd {
+	var c strategy.Crowd
+	for _, r := range reactions {
+		t := set[trackAt(set, r.at)]
+		if r.good {
+			c.Liked = append(c.Liked, t)
+		} else {
+			c.Disliked = append(c.Disliked, t)
+		}
+	}
+	return c
+}
+
+// replan keeps set through the track playing at elapsed and re-plans the tracks after
+// it from pool (the set's own tracks when pool is nil), leaning toward what the crowd
+// liked: the same number of tracks, following on from the one playing. It returns the
+// new set and the position of the track playing.
+func replan(ctx context.Context, sorter strategy.Sorter, set, pool []track.Track, crowd strategy.Crowd,
+	elapsed time.Duration) ([]track.Track, int, error) {
+	now := trackAt(set, elapsed)
+	count := len(set) - now - 1
+	if count == 0 {
+		return set, now, nil
+	}
+	played := make(map[string]bool, now+1)
+	for _, t := range set[:now+1] {
+		played[t.ID()] = true
+	}
+	if pool == nil {
+		pool = set[now+1:]
+	}
+	current := set[now]
+	candidates := []track.Track{current}
+	for _, t := range pool {
+		if !played[t.ID()] {
+			candidates = append(candidates, t)
+		}
+	}
+
+	if crowd.Weight > 0 {
+		crowd.Horizon = count + 1
+		ctx = strategy.WithCrowd(ctx, crowd)
+	}
+	ctx = strategy.WithPins(ctx, strategy.Pin{Name: current.Title, Position: 1,
+		Match: func(t track.Track) bool { return t.ID() == current.ID() }})
+	res, err := strategy.Sort(strategy.WithLimit(ctx, count+1), sorter, candidates)
+	if err != nil {
+		return nil, now, err
+	}
+	if res.Partial {
+		return nil, now, errors.New("sorting stopped before finishing")
+	}
+	upcoming := strategy.Truncate(ctx, res.Ordered, count+1)
+	if len(upcoming) == 0 || upcoming[0].ID() != current.ID() {
+		return nil, now, fmt.Errorf("the re-planned set doesn't follow on from %q", current.Title)
+	}
+	out := append(append([]track.Track(nil), set[:now]...), upcoming...)
+	return out, now, nil
+}
+
+// runReplan handles `magicmix replan ...`: mid-set, it reads how the floor has taken
+// the set so far (a feedback file, or live events on standard input) and re-plans the
+// rest of it toward the energy, genre, and era of the moments that went down well.
+func runReplan(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix replan", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	setPath := fs.String("set", "", "The set being played, in order: CSV, JSON, or setlist (required)")
+	libraryPath := fs.String("library", "", "Library to draw the rest of the set from (default: the set's own remaining tracks)")
+	feedbackPath := fs.String("feedback", "", "Feedback file of TIME good|bad lines, or - to read them live from standard input (required)")
+	elapsedFlag := fs.String("elapsed", "", "Time into the set now, e.g. 42m or 0:42:00 (default: the last feedback's time)")
+	outputPath := fs.String("output", "", "Destination for the re-planned set (default <set>_replan.csv)")
+	formatName := fs.String("output-format", "", "Output format: csv, m3u8, m3u, json, rekordbox, or setlist (default: from the --output extension)")
+	strategyName := fs.String("strategy", "flow", "Sorting strategy to apply")
+	seedFlag := fs.Int64("seed", 0, "Deterministic seed (0 = time-based)")
+	crowdWeight := fs.Float64("crowd-weight", 1, "How hard the feedback leans the rest of the set, against the mix score (0 = not at all)")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	noCache := fs.Bool("no-cache", false, "Parse the inputs afresh instead of using the parsed-library cache")
+	requestsPath := fs.String("requests", "", "File of audience requests, a Title|Artist per line, re-read as the set goes on")
+	requestWindow := fs.Int("request-window", strategy.DefaultRequestWindow, "How many upcoming tracks a request may be woven in among")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix replan --set SET --feedback FILE|- [--library LIBRARY] [options]\n\n")
+		_, _ = fmt.Fprintf(w, "Re-plan the rest of a set from how the floor has taken it: each feedback line\n")
+		_, _ = fmt.Fprintf(w, "marks a time into the set as good or bad, and the tracks still to play lean\n")
+		_, _ = fmt.Fprintf(w, "toward the energy, genre, and era of the good moments. With --feedback -, each\n")
+		_, _ = fmt.Fprintf(w, "line typed on standard input re-plans and rewrites the output at once; a line\n")
+		_, _ = fmt.Fprintf(w, "\"request Title|Artist\" (or a line added to the --requests file) weaves an\n")
+		_, _ = fmt.Fprintf(w, "audience request in where it mixes, or says why it is declined.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *setPath == "" {
+		fs.Usage()
+		return errors.New("--set is required")
+	}
+	if *feedbackPath == "" {
+		return errors.New("--feedback is required: a file of TIME good|bad lines, or - for live events")
+	}
+	if *crowdWeight < 0 {
+		return errors.New("--crowd-weight must be non-negative")
+	}
+	if *requestWindow < 1 {
+		return errors.New("--request-window must be at least 1")
+	}
+	live := *feedbackPath == playlistio.Stdio
+	var elapsed time.Duration
+	if *elapsedFlag != "" {
+		if live {
+			return errors.New("--elapsed applies to a feedback file; live events carry their own times")
+		}
+		var err error
+		if elapsed, err = parseSetTime(*elapsedFlag); err != nil {
+			return fmt.Errorf("--elapsed: %w", err)
+		}
+	}
+	output := *outputPath
+	if output == "" {
+		output = suffixedPath(*setPath, "_replan")
+	}
+	outFormat, err := outputFormat(*formatName, output)
+	if err != nil {
+		return err
+	}
+	if *outputPath == "" {
+		output = withFormatExt(output, outFormat)
+	}
+
+	sorter, err := strategy.Get(*strategyName)
+	if err != nil {
+		return err
+	}
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return err
+	}
+	ctx = strategy.WithGenres(strategy.WithSeed(ctx, seed), genres)
+
+	playlist, err := loadLibrary(ctx, *setPath, *noCache)
+	if err != nil {
+		return fmt.Errorf("failed to read the set from %s: %w", *setPath, err)
+	}
+	warnings := playlist.Warnings
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, energyWarnings...)
+	defer func() { printWarnings(warnings) }()
+	if len(playlist.Tracks) == 0 {
+		return fmt.Errorf("the set %s has no tracks", *setPath)
+	}
+	var pool []track.Track
+	if *libraryPath != "" {
+		library, err := loadLibrary(ctx, *libraryPath, *noCache)
+		if err != nil {
+			return fmt.Errorf("failed to read tracks from %s: %w", *libraryPath, err)
+		}
+		libraryWarnings, err := checkEnergy(library.Tracks, *inferEnergy)
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, library.Warnings...)
+		warnings = append(warnings, libraryWarnings...)
+		pool = library.Tracks
+	}
+
+	set := playlist.Tracks
+	var reactions []reaction
+	requests := newRequestQueue(*requestsPath)
+	step := func() error {
+		crowd := crowdOf(set, reactions)
+		crowd.Weight = *crowdWeight
+		next, now, err := replan(strategy.WithMustInclude(ctx, requests.accepted...), sorter, set, pool, crowd, elapsed)
+		if err != nil {
+			return err
+		}
+		var report []string
+		set, report = requests.weave(next, pool, now, *requestWindow)
+		outputWarnings, err := saveOutput(ctx, output, outFormat, csvio.Playlist{
+			Header:   playlist.Header,
+			CRLF:     playlist.CRLF,
+			Encoding: playlist.Encoding,
+			Tracks:   set,
+		})
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, outputWarnings...)
+		printReplan(set, now, elapsed, crowd, output)
+		printRequests(report)
+		return nil
+	}
+
+	if !live {
+		f, err := os.Open(*feedbackPath)
+		if err != nil {
+			return fmt.Errorf("open feedback: %w", err)
+		}
+		reactions, err = readReactions(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		last := time.Duration(0)
+		for _, r := range reactions {
+			last = max(last, r.at)
+		}
+		if *elapsedFlag == "" {
+			elapsed = last
+		} else if last > elapsed {
+			return fmt.Errorf("feedback at %s is after --elapsed %s", formatClock(last), formatClock(elapsed))
+		}
+		if _, err := requests.poll(); err != nil {
+			return err
+		}
+		return step()
+	}
+
+	fmt.Printf("Reading feedback from standard input: TIME good|bad per line, e.g. \"42m good\", or \"%sTitle|Artist\"\n", requestPrefix)
+	if _, err := requests.poll(); err != nil {
+		return err
+	}
+	// Standard input is read on its own goroutine so a watched --requests file can be
+	// polled between lines.
+	sc := bufio.NewScanner(os.Stdin)
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		for sc.Scan() {
+			lines <- sc.Text()
+		}
+	}()
+	var tick <-chan time.Time
+	if *requestsPath != "" {
+		ticker := time.NewTicker(requestPollInterval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-tick:
+			added, err := requests.poll()
+			if err != nil {
+				return err
+			}
+			if added == 0 {
+				continue
+			}
+		case line, open := <-lines:
+			if !open {
+				return sc.Err()
+			}
+			if spec, ok := strings.CutPrefix(strings.TrimSpace(line), requestPrefix); ok {
+				requests.add(spec)
+				break
+			}
+			r, ok, err := parseReaction(line)
+			if err != nil {
+				fmt.Fprintln(os.Stderr, err)
+				continue
+			}
+			if !ok {
+				continue
+			}
+			if r.at < elapsed {
+				fmt.Fprintf(os.Stderr, "feedback at %s is before the last (%s); ignored\n", formatClock(r.at), formatClock(elapsed))
+				continue
+			}
+			reactions, elapsed = append(reactions, r), r.at
+		}
+		if err := step(); err != nil {
+			return err
+		}
+	}
+}
+
+// printReplan reports a re-plan: what the crowd has liked, where the set is, and what
+// plays next.
+func printReplan(set []track.Track, now int, elapsed time.Duration, crowd strategy.Crowd, output string) {
+	fmt.Printf("At %s, playing #%d %q by %s\n", formatClock(elapsed), now+1, set[now].Title, set[now].Artist)
+	if summary := crowd.Summary(); summary != "" {
+		fmt.Printf("  Crowd: %s\n", summary)
+	}
+	upcoming := set[now+1:]
+	if len(upcoming) == 0 {
+		fmt.Println("  Nothing left to re-plan: this is the last track")
+		return
+	}
+	fmt.Printf("  Re-planned the remaining %d track(s) to %s; next up:\n", len(upcoming), output)
+	for i, t := range upcoming[:min(5, len(upcoming))] {
+		fmt.Printf("    %d. %q by %s (energy %d)\n", now+2+i, t.Title, t.Artist, t.Energy)
+	}
+}
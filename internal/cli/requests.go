@@ -0,0 +1,148 @@
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// requestPrefix starts a live line that is an audience request rather than feedback:
+// "request Title|Artist".
+const requestPrefix = "request "
+
+// requestPollInterval is how often live replan re-reads a watched --requests file.
+const requestPollInterval = 2 * time.Second
+
+// requestQueue is the running queue of audience requests for replan: lines from a
+// watched --requests file and "request ..." lines typed live, each woven in once.
+type requestQueue struct {
+	path     string          // watched file; "" for none
+	seen     map[string]bool // file lines already queued
+	pending  []string        // requests not yet woven in, as written
+	accepted []strategy.MustInclude
+}
+
+func newRequestQueue(path string) *requestQueue {
+	return &requestQueue{path: path, seen: map[string]bool{}}
+}
+
+// add queues a request, "Title|Artist" or "Title".
+func (q *requestQueue) add(spec string) {
+	if spec = strings.TrimSpace(spec); spec != "" {
+		q.pending = append(q.pending, spec)
+	}
+}
+
+// poll re-reads the watched file and queues the lines it hasn't seen, skipping blank
+// lines and # comments; it returns how many it queued. A file not there yet is an
+// empty queue.
+func (q *requestQueue) poll() (int, error) {
+	if q.path == "" {
+		return 0, nil
+	}
+	f, err := os.Open(q.path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("open requests: %w", err)
+	}
+	defer func() { _ = f.Close() }()
+	added := 0
+	sc := bufio.NewScanner(f)
+	for sc.Scan() {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || strings.HasPrefix(line, "#") || q.seen[line] {
+			continue
+		}
+		q.seen[line] = true
+		q.add(line)
+		added++
+	}
+	return added, sc.Err()
+}
+
+// weave takes the pending requests and works each into the tracks after set[now],
+// from pool (the set's own tracks when pool is nil), where it mixes sensibly within
+// window tracks (see strategy.PlaceRequest). It returns the new set and a line per
+// request on what became of it; the ones it declines say why.
+func (q *requestQueue) weave(set, pool []track.Track, now, window int) ([]track.Track, []string) {
+	var report []string
+	for _, spec := range q.pending {
+		match, err := parseTrackRef(spec)
+		if err != nil {
+			report = append(report, fmt.Sprintf("- %s: declined, %v", spec, err))
+			continue
+		}
+		if i := indexOf(set[:now+1], match); i >= 0 {
+			report = append(report, fmt.Sprintf("- %q by %s: declined, already played at #%d", set[i].Title, set[i].Artist, i+1))
+			continue
+		}
+		if i := indexOf(set[now+1:], match); i >= 0 {
+			t := set[now+1+i]
+			q.accept(spec, t)
+			report = append(report, fmt.Sprintf("+ %q by %s: already coming up at #%d", t.Title, t.Artist, now+2+i))
+			continue
+		}
+		from := pool
+		if from == nil {
+			from = set
+		}
+		i := indexOf(from, match)
+		if i < 0 {
+			where := "the library"
+			if pool == nil {
+				where = "the set"
+			}
+			report = append(report, fmt.Sprintf("- %s: declined, not in %s", spec, where))
+			continue
+		}
+		t := from[i]
+		spot, ok := strategy.PlaceRequest(set[now], set[now+1:], t, window)
+		if !ok {
+			report = append(report, fmt.Sprintf("- %q by %s: declined, no harmonically sensible spot in the next %d track(s) (%s)",
+				t.Title, t.Artist, min(window, len(set)-now-1), spot.Reason))
+			continue
+		}
+		at := now + 1 + spot.Position
+		set = append(set[:at:at], append([]track.Track{t}, set[at:]...)...)
+		q.accept(spec, t)
+		report = append(report, fmt.Sprintf("+ %q by %s: plays at #%d, after %q", t.Title, t.Artist, at+1, set[at-1].Title))
+	}
+	q.pending = nil
+	return set, report
+}
+
+// accept remembers a woven-in request, so later re-plans keep it in the set.
+func (q *requestQueue) accept(spec string, t track.Track) {
+	id := t.ID()
+	q.accepted = append(q.accepted, strategy.MustInclude{Name: spec, Match: func(t track.Track) bool { return t.ID() == id }})
+}
+
+// indexOf is the position of the first track match takes, or -1.
+func indexOf(tracks []track.Track, match func(track.Track) bool) int {
+	for i, t := range tracks {
+		if match(t) {
+			return i
+		}
+	}
+	return -1
+}
+
+// printRequests reports what became of the requests woven in at a step.
+func printRequests(report []string) {
+	if len(report) == 0 {
+		return
+	}
+	fmt.Println("  Requests:")
+	for _, line := range report {
+		fmt.Printf("    %s\n", line)
+	}
+}
@@ -0,0 +1,145 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+// runResidency handles `magicmix residency ...`: it plans a set for each of several
+// nights from one library (see strategy.PlanResidency), keeping tracks from coming back
+// night after night, and writes night i to the output with a _nightI suffix.
+func runResidency(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix residency", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	inputPath := fs.String("input", "", "Path to the input library: CSV, JSON, Rekordbox XML, or a folder of audio files")
+	inputFormatName := fs.String("input-format", "", "Input format (default: from the --input extension)")
+	energyField := fs.String("energy-field", rekordbox.DefaultEnergyField, "Rekordbox TRACK attribute holding energy")
+	outputPath := fs.String("output", "", "Path for the sets, with _night1, _night2, … added (default <input>_residency.csv)")
+	formatName := fs.String("output-format", "", "Output format: csv, m3u8, m3u, json, rekordbox, or setlist (default: from the --output extension)")
+	nights := fs.Int("nights", 0, "How many nights to plan (required)")
+	nightSize := fs.String("night-size", "", "Size of each night's set: a track count (20) or a length (2h) (required)")
+	tolerance := fs.Float64("repeat-tolerance", 0, "Largest share of a night's tracks, 0-1, that may have played on an earlier night")
+	strategyName := fs.String("strategy", "flow", "Sorting strategy to apply")
+	seedFlag := fs.Int64("seed", 0, "Deterministic seed (0 = time-based)")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix residency --input FILE --nights 5 --night-size 2h [options]\n\n")
+		_, _ = fmt.Fprintf(w, "Plan a set for each night of a residency from one library, each one sound on its\n")
+		_, _ = fmt.Fprintf(w, "own, repeating tracks across nights no more than --repeat-tolerance allows.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *inputPath == "" {
+		fs.Usage()
+		return errors.New("input path is required")
+	}
+	if *nights < 1 {
+		return errors.New("--nights is required, e.g. --nights 5")
+	}
+	if *nightSize == "" {
+		return errors.New("--night-size is required, e.g. --night-size 2h or --night-size 25")
+	}
+	size, err := parseSetSize("night-size", *nightSize)
+	if err != nil {
+		return err
+	}
+	if *tolerance < 0 || *tolerance > 1 {
+		return errors.New("--repeat-tolerance must be between 0 and 1")
+	}
+	inputName, err := parseInputFormat(*inputFormatName)
+	if err != nil {
+		return err
+	}
+	output := *outputPath
+	if output == "" {
+		output = suffixedPath(*inputPath, "_residency")
+	}
+	outFormat, err := outputFormat(*formatName, output)
+	if err != nil {
+		return err
+	}
+	output = withFormatExt(output, outFormat)
+
+	sorter, err := strategy.Get(*strategyName)
+	if err != nil {
+		return err
+	}
+	seed := *seedFlag
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return err
+	}
+	ctx = strategy.WithGenres(strategy.WithSeed(ctx, seed), genres)
+
+	playlist, err := loadLibraryAs(ctx, *inputPath, *noCache, libraryFormat{name: inputName, energyField: *energyField})
+	if err != nil {
+		return fmt.Errorf("failed to read tracks from %s: %w", *inputPath, err)
+	}
+	warnings := playlist.Warnings
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, energyWarnings...)
+	tracks, alternates := strategy.OnePerFamily(playlist.Tracks)
+	if len(alternates) > 0 {
+		fmt.Printf("Skipped %d alternate version(s) of songs already in the library\n", len(alternates))
+	}
+	if size.length > 0 {
+		if missing := countMissingLengths(tracks); missing > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d track(s) have no length; nights are filled counting them as the average length", missing))
+		}
+	}
+
+	plan, err := strategy.PlanResidency(ctx, sorter, tracks, strategy.Residency{Nights: *nights, Tracks: size.tracks,
+		Seconds: size.length.Seconds(), RepeatTolerance: *tolerance})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Planned %d night(s) from %d tracks using %s strategy, seed %d:\n", len(plan), len(tracks), sorter.Name(), seed)
+	distinct := map[string]bool{}
+	repeats := 0
+	for i, night := range plan {
+		for _, w := range night.Warnings {
+			warnings = append(warnings, fmt.Sprintf("night %d: %s", i+1, w))
+		}
+		path := suffixedPath(output, fmt.Sprintf("_night%d", i+1))
+		outputWarnings, err := saveOutput(ctx, path, outFormat, csvio.Playlist{
+			Header:   playlist.Header,
+			CRLF:     playlist.CRLF,
+			Encoding: playlist.Encoding,
+			Tracks:   night.Ordered,
+		})
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, outputWarnings...)
+		for _, t := range night.Ordered {
+			distinct[t.ID()] = true
+		}
+		repeats += night.Repeats
+		fmt.Printf("  %-10s %3d track(s)  %s  %3d repeat(s)  energy %3.0f  to %s\n", fmt.Sprintf("night %d", i+1),
+			len(night.Ordered), formatClock(setLength(night.Ordered)), night.Repeats, averageEnergy(night.Ordered), path)
+	}
+	fmt.Printf("%d distinct track(s) across the residency; %d repeat(s) of a track from an earlier night\n", len(distinct), repeats)
+	printWarnings(warnings)
+	return nil
+}
@@ -0,0 +1,229 @@
+package cli
+
+import (
+	"context"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// setResult is what a run has decided once sorting is done: the set to write and what
+// to report about it. An identical rerun reads it back from the result cache (see
+// resultKey) instead of sorting again.
+type setResult struct {
+	Header     []string
+	CRLF       bool
+	Encoding   string
+	Ordered    []track.Track
+	Confidence []strategy.Confidence
+	Warnings   []string
+	// GenreFit warns of the tracks whose tags don't fit their genre, for --check-genres
+	// to report. It is kept whether or not the flag is given, so the flag doesn't
+	// split the cache.
+	GenreFit   []string
+	Dropped    dropLog
+	Seed       int64
+	SeedSource string
+	Notes      []string
+	// Why is the reason for each track of Ordered, for the --explain-column column.
+	Why []string
+	// Alternatives are the --alternatives next-best tracks for each placement of Ordered.
+	Alternatives [][]strategy.Alternative
+	// Wildcards is, for each track of Ordered, why --wildcards played it; "" for the
+	// tracks the strategy picked, and nil when there are none.
+	Wildcards []string
+
+	// Unplaced are the tracks a sort stopped by --timeout never placed. Such a result
+	// is never cached.
+	Unplaced []track.Track `json:"-"`
+}
+
+// resultNeutralFlags are the flags that don't change the ordering: where and how it is
+// written, and flags whose effect the key takes in some other way (the seed itself,
+// the profile's flags, the tuning file's contents).
+var resultNeutralFlags = map[string]bool{
+	"output": true, "output-format": true, "format": true, "no-cache": true, "timeout": true, "decision-log": true,
+	"seed": true, "deterministic": true, "profile": true, "config": true, "variety-from": true,
+	"list-strategies": true, "score": true, "score-verbose": true, "segments": true,
+	"copy-to": true, "copy-mode": true, "music-dir": true, "workspace": true, "audit-determinism": true,
+	"verbose": true, "summary-format": true, "check-genres": true,
+}
+
+// reportFlags add to what a result reports without changing its ordering. The result
+// key includes them, since the cached result holds what they add, but the
+// --deterministic seed does not (see orderingFlags), so asking why each track was
+// placed doesn't place them differently.
+var reportFlags = map[string]bool{"explain": true, "explain-column": true, "alternatives": true}
+
+// resultKey identifies a run's result for the result cache: a digest of the inputs'
+// contents, every flag that shapes the ordering, the seed, and the files those options
+// read (the --config tuning, the --variety-from set, genres.json, and the mixing
+// style). Flags that only say where and how to write the set are left out, so
+// rerunning to write another format reuses the result.
+// extra adds anything else the result depends on, such as today's date.
+func resultKey(ctx context.Context, fs *flag.FlagSet, paths []string, seed int64, extra ...string) (string, error) {
+	options := append([]string{strconv.FormatInt(seed, 10)}, resultFlags(fs)...)
+	files := []string{fs.Lookup("config").Value.String(), fs.Lookup("variety-from").Value.String()}
+	if dir, err := config.Dir(); err == nil {
+		files = append(files, filepath.Join(dir, "genres.json"), filepath.Join(dir, config.StyleFile))
+	}
+	for _, path := range files {
+		if path == "" {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return "", err
+		}
+		options = append(options, path+"="+string(data))
+	}
+	sum, err := hashInputs(ctx, paths, append(options, extra...)...)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(sum), nil
+}
+
+// resultFlags lists every flag the result key covers, as name=value: all but
+// resultNeutralFlags.
+func resultFlags(fs *flag.FlagSet) []string {
+	var options []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if !resultNeutralFlags[f.Name] {
+			options = append(options, f.Name+"="+f.Value.String())
+		}
+	})
+	return options
+}
+
+// orderingFlags lists the flags that shape the ordering, as name=value: resultFlags
+// less reportFlags. The --deterministic seed is drawn from it, so an option the result
+// key covers changes the seed too unless it only adds to the report.
+func orderingFlags(fs *flag.FlagSet) []string {
+	return slices.DeleteFunc(resultFlags(fs), func(option string) bool {
+		name, _, _ := strings.Cut(option, "=")
+		return reportFlags[name]
+	})
+}
+
+// reasonsByTrack maps each track of res.Ordered to the reason it was placed (see
+// strategy.WithExplain), by track ID, so the reasons survive later trimming and
+// swapping.
+func reasonsByTrack(res strategy.Result) map[string]string {
+	reasons := make(map[string]string, len(res.Explanations))
+	for i, why := range res.Explanations {
+		reasons[res.Ordered[i].ID()] = why
+	}
+	return reasons
+}
+
+// writeSet writes set to output in format and reports on it: the tracks left out (in
+// the dropped sidecar), any it never placed, the least certain placements, and the
+// warnings.
+func writeSet(ctx context.Context, set setResult, output string, format playlistio.Format, strategyName string,
+	targetDuration time.Duration, summary *strategy.Summary) error {
+	pl := csvio.Playlist{Header: set.Header, CRLF: set.CRLF, Encoding: set.Encoding, Tracks: set.Ordered}
+	warnings := set.Warnings
+	if set.Why != nil {
+		if format == playlistio.CSV || format == playlistio.Setlist {
+			pl.Extra = []csvio.Column{{Name: "Why", Values: set.Why}}
+		} else {
+			warnings = append(warnings, fmt.Sprintf("--explain-column only applies to CSV and setlist output, not %s", format))
+		}
+	}
+	if set.Wildcards != nil && (format == playlistio.CSV || format == playlistio.Setlist) {
+		pl.Extra = append(pl.Extra, csvio.Column{Name: "Wildcard", Values: set.Wildcards})
+	}
+	if gains, ok := gainColumn(set.Ordered); ok && format == playlistio.CSV && !slices.ContainsFunc(set.Header, isGainColumn) {
+		pl.Extra = append(pl.Extra, gains)
+	}
+	outputWarnings, err := saveOutput(ctx, output, format, pl)
+	if err != nil {
+		return err
+	}
+	warnings = append(warnings, outputWarnings...)
+
+	fmt.Printf("Wrote %d tracks using %s strategy to %s\n", len(set.Ordered), strategyName, output)
+	if targetDuration > 0 {
+		fmt.Printf("Set length %s (target %s)\n", formatClock(setLength(set.Ordered)), formatClock(targetDuration))
+	}
+	drops := set.Dropped
+	if drops.settle(set.Ordered); len(drops) > 0 && output != playlistio.Stdio {
+		path := droppedPath(output)
+		if err := writeDropped(path, drops); err != nil {
+			return err
+		}
+		fmt.Printf("Listed the %d track(s) left out, with the reason for each, in %s\n", len(drops), path)
+	}
+	if len(set.Unplaced) > 0 && output != playlistio.Stdio {
+		unplacedOutput := suffixedPath(output, "_unplaced")
+		if _, err := saveOutput(ctx, unplacedOutput, format, csvio.Playlist{
+			Header:   set.Header,
+			CRLF:     set.CRLF,
+			Encoding: set.Encoding,
+			Tracks:   set.Unplaced,
+		}); err != nil {
+			return err
+		}
+		fmt.Printf("Sorting stopped before finishing; the %d track(s) it had not placed, in input order, are in %s\n",
+			len(set.Unplaced), unplacedOutput)
+	}
+	printWildcards(set.Ordered, set.Wildcards)
+	printCompromises(set.Confidence)
+	printAlternatives(set.Ordered, set.Alternatives)
+	printRehearsals(set.Ordered, maxRehearsals)
+	printWarnings(warnings)
+	if summary != nil {
+		written := []string{output}
+		if len(drops) > 0 && output != playlistio.Stdio {
+			written = append(written, droppedPath(output))
+		}
+		if len(set.Unplaced) > 0 && output != playlistio.Stdio {
+			written = append(written, suffixedPath(output, "_unplaced"))
+		}
+		*summary = strategy.Result{Ordered: set.Ordered, Unplaced: set.Unplaced, Partial: len(set.Unplaced) > 0,
+			Notes: set.Notes, Warnings: warnings, Strategy: strategyName, Seed: set.Seed,
+			Input: len(set.Ordered) + len(drops) + len(set.Unplaced)}.Summary()
+		summary.Outputs = written
+	}
+	return nil
+}
+
+// gainColumn is the suggested gain of each track in dB (see strategy.SuggestGain), as
+// a signed CSV column; false when no track has a loudness.
+func gainColumn(tracks []track.Track) (csvio.Column, bool) {
+	col := csvio.Column{Name: "Gain", Values: make([]string, len(tracks))}
+	known := false
+	for i, t := range tracks {
+		if gain, ok := strategy.SuggestGain(t); ok {
+			col.Values[i] = strconv.FormatFloat(gain, 'f', 1, 64)
+			if gain > 0 {
+				col.Values[i] = "+" + col.Values[i]
+			}
+			known = true
+		}
+	}
+	return col, known
+}
+
+// isGainColumn reports whether a header cell is a Gain column an earlier run wrote,
+// which re-sorting its output keeps.
+func isGainColumn(name string) bool {
+	return strings.EqualFold(strings.TrimSpace(name), "gain")
+}
@@ -0,0 +1,328 @@
+package cli
+
+import (
+	"bytes"
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// webUI is the page `magicmix serve` hosts: one self-contained file, with its styles,
+// script, and charts inline, so the server needs nothing beside the binary.
+//
+//go:embed web/index.html
+var webUI []byte
+
+// maxLibraryBytes caps an uploaded library, so one request can't exhaust memory.
+const maxLibraryBytes = 32 << 20
+
+// runServe handles `magicmix serve ...`: it hosts the web UI, where a library can be
+// uploaded or pasted, sorted with options set by form controls, looked over on energy
+// and key charts, and downloaded in any registered format.
+func runServe(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix serve", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on; the default serves this machine only")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	listener, err := net.Listen("tcp", *addr)
+	if err != nil {
+		return err
+	}
+	srv := &http.Server{Handler: newServeHandler(), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		<-ctx.Done()
+		_ = srv.Close()
+	}()
+	fmt.Printf("Serving the magicmix web UI at http://%s/ (Ctrl-C to stop)\n", listener.Addr())
+	if err := srv.Serve(listener); !errors.Is(err, http.ErrServerClosed) {
+		return err
+	}
+	return nil
+}
+
+// newServeHandler routes the web UI and the API it calls:
+//
+//	GET  /               the page
+//	GET  /api/options    the strategies and output formats, for the form
+//	POST /api/sort       sort a library; with a format, the set comes back as a download
+//	GET  /v1/strategies  every strategy with its hints, as --list-strategies prints them
+func newServeHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		_, _ = w.Write(webUI)
+	})
+	mux.HandleFunc("GET /api/options", serveOptions)
+	mux.HandleFunc("POST /api/sort", serveSort)
+	mux.HandleFunc("GET /v1/strategies", serveStrategies)
+	return mux
+}
+
+// serveOption is a strategy or output format as the form lists it.
+type serveOption struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+func serveOptions(w http.ResponseWriter, _ *http.Request) {
+	var options struct {
+		Strategies []serveOption `json:"strategies"`
+		Formats    []serveOption `json:"formats"`
+	}
+	for _, info := range strategy.Infos() {
+		options.Strategies = append(options.Strategies, serveOption{Name: info.Name, Description: info.Description})
+	}
+	for _, info := range playlistio.Writers() {
+		options.Formats = append(options.Formats, serveOption{Name: string(info.Name), Description: info.Description})
+	}
+	writeJSONResponse(w, http.StatusOK, options)
+}
+
+// serveStrategy is a strategy's registry entry as GET /v1/strategies lists it.
+type serveStrategy struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Quality     string `json:"quality,omitempty"`
+	Speed       string `json:"speed,omitempty"`
+}
+
+func serveStrategies(w http.ResponseWriter, _ *http.Request) {
+	strategies := []serveStrategy{}
+	for _, info := range strategy.Infos() {
+		strategies = append(strategies, serveStrategy{Name: info.Name, Description: info.Description, Quality: info.Quality, Speed: info.Speed})
+	}
+	writeJSONResponse(w, http.StatusOK, strategies)
+}
+
+// serveRequest is the sort form: the library (an uploaded file or pasted text, in any
+// readable format) and the options the UI offers, named as the CLI's flags are.
+type serveRequest struct {
+	library  []byte
+	strategy string
+	seed     int64
+	limit    int
+	keepAll  bool
+	explain  bool
+	format   playlistio.Format // "" to answer with the set as JSON for the page
+}
+
+func parseServeRequest(r *http.Request) (serveRequest, error) {
+	r.Body = http.MaxBytesReader(nil, r.Body, maxLibraryBytes)
+	if err := r.ParseMultipartForm(maxLibraryBytes); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+		return serveRequest{}, fmt.Errorf("read form: %w", err)
+	}
+	req := serveRequest{strategy: r.FormValue("strategy"), keepAll: r.FormValue("keep-all") != "", explain: r.FormValue("explain") != ""}
+	if file, _, err := r.FormFile("file"); err == nil {
+		defer file.Close()
+		if req.library, err = io.ReadAll(file); err != nil {
+			return serveRequest{}, fmt.Errorf("read upload: %w", err)
+		}
+	}
+	if len(bytes.TrimSpace(req.library)) == 0 {
+		req.library = []byte(r.FormValue("library"))
+	}
+	if len(bytes.TrimSpace(req.library)) == 0 {
+		return serveRequest{}, errors.New("no library: upload a file or paste one")
+	}
+	if req.strategy == "" {
+		req.strategy = "default"
+	}
+	var err error
+	if s := strings.TrimSpace(r.FormValue("seed")); s != "" {
+		if req.seed, err = strconv.ParseInt(s, 10, 64); err != nil {
+			return serveRequest{}, fmt.Errorf("seed %q: not a number", s)
+		}
+	}
+	if s := strings.TrimSpace(r.FormValue("limit")); s != "" {
+		if req.limit, err = strconv.Atoi(s); err != nil || req.limit < 0 {
+			return serveRequest{}, fmt.Errorf("limit %q: want a whole number, 0 for no limit", s)
+		}
+	}
+	if s := r.FormValue("format"); s != "" {
+		if req.format, err = playlistio.ParseFormat(s); err != nil {
+			return serveRequest{}, err
+		}
+	}
+	return req, nil
+}
+
+// serveTrack is one placement of the sorted set, with what the charts plot.
+type serveTrack struct {
+	Title  string  `json:"title"`
+	Artist string  `json:"artist"`
+	Key    string  `json:"key"`
+	BPM    float64 `json:"bpm"`
+	Energy int     `json:"energy"`
+	Why    string  `json:"why,omitempty"`
+}
+
+// serveSet is the sorted set as the page shows it.
+type serveSet struct {
+	Seed     int64        `json:"seed"`
+	Strategy string       `json:"strategy"`
+	Tracks   []serveTrack `json:"tracks"`
+	Dropped  []serveDrop  `json:"dropped"`
+	Notes    []string     `json:"notes"`
+	Warnings []string     `json:"warnings"`
+}
+
+type serveDrop struct {
+	id     string
+	Title  string `json:"title"`
+	Artist string `json:"artist"`
+	Reason string `json:"reason"`
+}
+
+// serveSort sorts the posted library. The page gets the set as JSON along with the
+// seed used; downloading posts the same form with that seed and a format, so the file
+// holds the very ordering on screen.
+func serveSort(w http.ResponseWriter, r *http.Request) {
+	req, err := parseServeRequest(r)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	ctx := r.Context()
+	pl, err := playlistio.Load(playlistio.WithStdin(csvio.WithGenres(ctx, genres), req.library), playlistio.Stdio, "", playlistio.ReadOptions{})
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	set, err := sortForServe(ctx, req, pl)
+	if err != nil {
+		writeJSONError(w, http.StatusBadRequest, err)
+		return
+	}
+	if req.format == "" {
+		writeJSONResponse(w, http.StatusOK, set.page)
+		return
+	}
+
+	var out bytes.Buffer
+	ordered := csvio.Playlist{Header: pl.Header, CRLF: pl.CRLF, Encoding: pl.Encoding, Tracks: set.ordered}
+	if req.explain && req.format == playlistio.CSV {
+		why := make([]string, len(set.page.Tracks))
+		for i, t := range set.page.Tracks {
+			why[i] = t.Why
+		}
+		ordered.Extra = []csvio.Column{{Name: "Why", Values: why}}
+	}
+	if err := playlistio.Save(playlistio.WithStdout(ctx, &out), playlistio.Stdio, req.format, ordered); err != nil {
+		writeJSONError(w, http.StatusInternalServerError, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%q", "magicmix"+req.format.Ext()))
+	_, _ = w.Write(out.Bytes())
+}
+
+// servedSet is a sort's outcome: the tracks to write and the page's view of them.
+type servedSet struct {
+	ordered []track.Track
+	page    serveSet
+}
+
+// sortForServe sorts pl as the CLI would with the request's options: it drops the
+// tracks that don't fit unless keep-all is set, then applies the limit.
+func sortForServe(ctx context.Context, req serveRequest, pl csvio.Playlist) (servedSet, error) {
+	sorter, err := strategy.Get(req.strategy)
+	if err != nil {
+		return servedSet{}, err
+	}
+	genres, err := loadGenres()
+	if err != nil {
+		return servedSet{}, err
+	}
+	seed := req.seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	ctx = strategy.WithGenres(strategy.WithSeed(ctx, seed), genres)
+	if req.limit > 0 {
+		ctx = strategy.WithLimit(ctx, req.limit)
+	}
+	if req.explain {
+		ctx = strategy.WithExplain(ctx)
+	}
+
+	page := serveSet{Seed: seed, Strategy: sorter.Name(), Dropped: []serveDrop{}}
+	res, err := strategy.Sort(ctx, sorter, pl.Tracks)
+	if err != nil {
+		return servedSet{}, err
+	}
+	page.Warnings = append(page.Warnings, res.Warnings...)
+	ordered, notes, reasons := res.Ordered, res.Notes, reasonsByTrack(res)
+	if !req.keepAll && !res.Partial {
+		kept, dropped := strategy.TrimOutliers(ordered, 0.10, strategy.Required(ctx))
+		if len(dropped) > 0 {
+			ordered = kept
+			if reordered, err := strategy.Sort(ctx, sorter, kept); err == nil {
+				ordered, notes, reasons = reordered.Ordered, reordered.Notes, reasonsByTrack(reordered)
+				page.Warnings = append(page.Warnings, reordered.Warnings...)
+			}
+			for _, d := range dropped {
+				page.Dropped = append(page.Dropped, serveDrop{id: d.Track.ID(), Title: d.Track.Title, Artist: d.Track.Artist,
+					Reason: fmt.Sprintf("didn't fit the mix (roughness %.2f)", d.MarginalCost)})
+			}
+		}
+	}
+	if req.limit > 0 && req.limit < len(ordered) {
+		ordered = strategy.Truncate(ctx, ordered, req.limit)
+	}
+	// Strategies honour the limit themselves, so whatever else is missing is what it cut.
+	placed := map[string]bool{}
+	for _, t := range ordered {
+		placed[t.ID()] = true
+	}
+	for _, d := range page.Dropped {
+		placed[d.id] = true
+	}
+	for _, t := range pl.Tracks {
+		if !placed[t.ID()] {
+			placed[t.ID()] = true
+			page.Dropped = append(page.Dropped, serveDrop{Title: t.Title, Artist: t.Artist,
+				Reason: fmt.Sprintf("beyond limit %d", req.limit)})
+		}
+	}
+
+	page.Notes = notes
+	page.Tracks = make([]serveTrack, len(ordered))
+	for i, t := range ordered {
+		page.Tracks[i] = serveTrack{Title: t.Title, Artist: t.Artist, Key: t.Key.String(), BPM: t.BPM, Energy: t.Energy,
+			Why: reasons[t.ID()]}
+	}
+	return servedSet{ordered: ordered, page: page}, nil
+}
+
+func writeJSONResponse(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeJSONError(w http.ResponseWriter, status int, err error) {
+	writeJSONResponse(w, status, map[string]string{"error": err.Error()})
+}
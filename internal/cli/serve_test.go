@@ -0,0 +1,109 @@
+package cli
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+)
+
+func TestServeSortsAndDownloads(t *testing.T) {
+	srv := httptest.NewServer(newServeHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK || !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/html") {
+		t.Fatalf("GET / = %d %s, want the page", resp.StatusCode, resp.Header.Get("Content-Type"))
+	}
+
+	form := url.Values{
+		"library": {"Title,Artist,BPM,Energy,Key\nTrack1,Artist1,120,50,1A\nTrack2,Artist2,121,60,2A\nTrack3,Artist3,122,70,3A\n"},
+		"seed":    {"42"},
+		"limit":   {"2"},
+		"explain": {"on"},
+	}
+	resp, err = http.PostForm(srv.URL+"/api/sort", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var set serveSet
+	err = json.NewDecoder(resp.Body).Decode(&set)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("sort = %d, %v", resp.StatusCode, err)
+	}
+	if set.Seed != 42 || len(set.Tracks) != 2 || len(set.Dropped) != 1 {
+		t.Fatalf("sorted set = %+v, want 2 tracks and 1 left out with seed 42", set)
+	}
+	if set.Tracks[0].Why == "" {
+		t.Errorf("explained set has no reason for its opener: %+v", set.Tracks[0])
+	}
+
+	form.Set("format", "m3u8")
+	resp, err = http.PostForm(srv.URL+"/api/sort", form)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var body bytes.Buffer
+	_, _ = body.ReadFrom(resp.Body)
+	resp.Body.Close()
+	if !strings.Contains(resp.Header.Get("Content-Disposition"), "magicmix.m3u8") || !strings.HasPrefix(body.String(), "#EXTM3U") {
+		t.Fatalf("download = %q with %q, want an M3U8 attachment", body.String(), resp.Header.Get("Content-Disposition"))
+	}
+	if first := strings.Index(body.String(), set.Tracks[0].Title); first < 0 || first > strings.Index(body.String(), set.Tracks[1].Title) {
+		t.Errorf("download doesn't list the set in its sorted order:\n%s", body.String())
+	}
+
+	resp, err = http.PostForm(srv.URL+"/api/sort", url.Values{"library": {" "}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("sorting no library = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+}
+
+func TestServeListsStrategies(t *testing.T) {
+	srv := httptest.NewServer(newServeHandler())
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/v1/strategies")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []serveStrategy
+	err = json.NewDecoder(resp.Body).Decode(&listed)
+	resp.Body.Close()
+	if err != nil || resp.StatusCode != http.StatusOK {
+		t.Fatalf("GET /v1/strategies = %d, %v", resp.StatusCode, err)
+	}
+	infos := strategy.Infos()
+	if len(listed) != len(infos) {
+		t.Fatalf("listed %d strategies, want the %d registered", len(listed), len(infos))
+	}
+	for i, info := range infos {
+		got := listed[i]
+		if got.Name != info.Name || got.Description != info.Description || got.Quality != info.Quality || got.Speed != info.Speed {
+			t.Errorf("listed %+v, want %+v", got, info)
+		}
+	}
+
+	resp, err = http.Post(srv.URL+"/v1/strategies", "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusMethodNotAllowed {
+		t.Errorf("POST /v1/strategies = %d, want %d", resp.StatusCode, http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"path/filepath"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/playlistio"
+)
+
+// runManifest records how this run made its set, for a setlist: the strategy, the
+// seed, the ordering flags it was given (those the result key counts, less --input),
+// and each input's name and content digest.
+func runManifest(ctx context.Context, fs *flag.FlagSet, paths []string, strategyName string, seed int64) (playlistio.Manifest, error) {
+	m := playlistio.Manifest{
+		Created:  time.Now().UTC().Format(time.RFC3339),
+		Strategy: strategyName,
+		Seed:     seed,
+		Options:  map[string]string{},
+	}
+	fs.Visit(func(f *flag.Flag) {
+		if !resultNeutralFlags[f.Name] && f.Name != "input" {
+			m.Options[f.Name] = f.Value.String()
+		}
+	})
+	for _, path := range paths {
+		data, err := inputContent(ctx, path)
+		if err != nil {
+			return playlistio.Manifest{}, err
+		}
+		sum := sha256.Sum256(data)
+		m.Inputs = append(m.Inputs, playlistio.InputDigest{Name: filepath.Base(path), SHA256: hex.EncodeToString(sum[:])})
+	}
+	return m, nil
+}
@@ -0,0 +1,112 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// setSize is a --set-size value: a number of tracks, or a length of playtime.
+type setSize struct {
+	tracks int
+	length time.Duration
+}
+
+// parseSetSize reads the value of a set-size flag such as --set-size: a track count
+// such as "20" or a duration such as "60m".
+func parseSetSize(name, s string) (setSize, error) {
+	s = strings.TrimSpace(s)
+	if n, err := strconv.Atoi(s); err == nil {
+		if n < 1 {
+			return setSize{}, fmt.Errorf("--%s %q: want at least 1 track", name, s)
+		}
+		return setSize{tracks: n}, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return setSize{}, fmt.Errorf("--%s %q: want a track count (20) or a length (60m)", name, s)
+	}
+	return setSize{length: d}, nil
+}
+
+// count is how many sets of this size tracks make, rounding to the nearest whole set.
+func (s setSize) count(tracks []track.Track) int {
+	n := float64(len(tracks)) / float64(s.tracks)
+	if s.length > 0 {
+		n = float64(setLength(tracks)) / float64(s.length)
+	}
+	return max(1, int(math.Round(n)))
+}
+
+// runSets splits tracks into n sets (see strategy.Partition), orders each with sorter,
+// and writes set i to output with a _setI suffix. The tracks left out before
+// partitioning (drops) are listed beside the output.
+func runSets(ctx context.Context, sorter strategy.Sorter, playlist csvio.Playlist, tracks []track.Track, n int,
+	byLength bool, drops dropLog, output string, format playlistio.Format, warnings []string) error {
+	if byLength {
+		if missing := countMissingLengths(tracks); missing > 0 {
+			warnings = append(warnings, fmt.Sprintf("%d track(s) have no length; sets are balanced counting them as the average length", missing))
+		}
+	}
+	groups := strategy.Partition(tracks, n, byLength)
+	if len(groups) < n {
+		warnings = append(warnings, fmt.Sprintf("only %d track(s), so %d set(s) rather than %d", len(tracks), len(groups), n))
+	}
+	fmt.Printf("Split %d tracks into %d set(s), calmest first:\n", len(tracks), len(groups))
+	for i, group := range groups {
+		res, err := strategy.Sort(ctx, sorter, group)
+		if err != nil {
+			return fmt.Errorf("set %d: %w", i+1, err)
+		}
+		if res.Partial {
+			return fmt.Errorf("set %d: sorting stopped before finishing; raise --timeout", i+1)
+		}
+		for _, note := range res.Notes {
+			fmt.Printf("  set %d: %s\n", i+1, note)
+		}
+		for _, w := range res.Warnings {
+			warnings = append(warnings, fmt.Sprintf("set %d: %s", i+1, w))
+		}
+		path := suffixedPath(output, fmt.Sprintf("_set%d", i+1))
+		outputWarnings, err := saveOutput(ctx, path, format, csvio.Playlist{
+			Header:   playlist.Header,
+			CRLF:     playlist.CRLF,
+			Encoding: playlist.Encoding,
+			Tracks:   res.Ordered,
+		})
+		if err != nil {
+			return err
+		}
+		warnings = append(warnings, outputWarnings...)
+		fmt.Printf("  %-24s %3d track(s)  %s  energy %3.0f  to %s\n", fmt.Sprintf("set %d", i+1), len(res.Ordered),
+			formatClock(setLength(res.Ordered)), averageEnergy(res.Ordered), path)
+	}
+	if len(drops) > 0 {
+		path := droppedPath(output)
+		if err := writeDropped(path, drops); err != nil {
+			return err
+		}
+		fmt.Printf("Listed the %d track(s) left out, with the reason for each, in %s\n", len(drops), path)
+	}
+	printWarnings(warnings)
+	return nil
+}
+
+func averageEnergy(tracks []track.Track) float64 {
+	if len(tracks) == 0 {
+		return 0
+	}
+	sum := 0
+	for _, t := range tracks {
+		sum += t.Energy
+	}
+	return float64(sum) / float64(len(tracks))
+}
@@ -0,0 +1,224 @@
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// loadStyle reads the mixing style kept in the config directory; ok is false when
+// there is none yet.
+func loadStyle() (s strategy.Style, path string, ok bool, err error) {
+	if path, err = config.StylePath(); err != nil {
+		return s, "", false, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return s, path, false, nil
+	}
+	if err != nil {
+		return s, path, false, fmt.Errorf("read style: %w", err)
+	}
+	if err := json.Unmarshal(data, &s); err != nil {
+		return s, path, false, fmt.Errorf("parse %s: %w", path, err)
+	}
+	return s, path, true, nil
+}
+
+// saveStyle writes s to path, creating the config directory if needed.
+func saveStyle(path string, s strategy.Style) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("write style: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write style: %w", err)
+	}
+	return nil
+}
+
+// parseAdjacency reads a --dislike spec, "Title|Artist>Title|Artist" (artists
+// optional): the first track, then the one not to follow it.
+func parseAdjacency(spec string) (strategy.Adjacency, error) {
+	from, to, ok := strings.Cut(spec, ">")
+	from, to = strings.TrimSpace(from), strings.TrimSpace(to)
+	if !ok {
+		return strategy.Adjacency{}, fmt.Errorf("--dislike %q: want \"Title|Artist>Title|Artist\"", spec)
+	}
+	for _, ref := range []string{from, to} {
+		if _, err := parseTrackRef(ref); err != nil {
+			return strategy.Adjacency{}, fmt.Errorf("--dislike %w", err)
+		}
+	}
+	return strategy.Adjacency{From: from, To: to}, nil
+}
+
+// trackRef writes a track as a reference parseTrackRef reads back.
+func trackRef(t track.Track) string {
+	if t.Artist == "" {
+		return t.Title
+	}
+	return t.Title + "|" + t.Artist
+}
+
+// runStyle handles `magicmix style ...`: it shows, learns, and edits the mixing style
+// kept in the config directory, which every run then leans toward (see
+// strategy.WithStyle) unless given --no-style.
+func runStyle(ctx context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix style", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var learn, favor, dislike stringsFlag
+	fs.Var(&learn, "learn", "A set you played (CSV, JSON, or setlist) to learn your key moves, tempo changes, and energy jumps from; repeatable")
+	feedback := fs.String("feedback", "", "Crowd feedback on the one --learn set, as replan reads it: each bad reaction dislikes the transition into the track it fell on")
+	fs.Var(&favor, "favor", fmt.Sprintf("A key move you always welcome (%s); repeatable", strings.Join(strategy.KeyMoves(), ", ")))
+	fs.Var(&dislike, "dislike", "Two tracks not to play back to back, \"Title|Artist>Title|Artist\"; repeatable")
+	bpmDelta := fs.Float64("bpm-delta", 0, "The tempo change, in BPM, you are happy to make between tracks")
+	energyJump := fs.Float64("energy-jump", 0, "The energy jump you are happy to make between tracks")
+	weight := fs.Float64("weight", 0, "How hard your style leans each set, against the mix score (1 = the default)")
+	reset := fs.Bool("reset", false, "Forget the style and start again")
+	noCache := fs.Bool("no-cache", false, "Parse --learn sets afresh instead of using the parsed-library cache")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix style [--learn SET [--feedback FILE]] [--favor +1] [--dislike \"A|X>B|Y\"] [--reset]\n\n")
+		_, _ = fmt.Fprintf(w, "Show or build your mixing style, kept in %s in the config directory: the key\n", config.StyleFile)
+		_, _ = fmt.Fprintf(w, "moves, tempo changes, and energy jumps you make, and pairs you don't play back to\n")
+		_, _ = fmt.Fprintf(w, "back. Every run leans toward it unless given --no-style.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+	if *feedback != "" && len(learn) != 1 {
+		return errors.New("--feedback needs exactly one --learn set, the one the feedback is on")
+	}
+	for _, move := range favor {
+		if !slices.Contains(strategy.KeyMoves(), move) {
+			return fmt.Errorf("--favor %q: want one of %s", move, strings.Join(strategy.KeyMoves(), ", "))
+		}
+	}
+	if *bpmDelta < 0 || *energyJump < 0 || *weight < 0 {
+		return errors.New("--bpm-delta, --energy-jump, and --weight must be non-negative")
+	}
+	var disliked []strategy.Adjacency
+	for _, spec := range dislike {
+		adj, err := parseAdjacency(spec)
+		if err != nil {
+			return err
+		}
+		disliked = append(disliked, adj)
+	}
+
+	style, path, _, err := loadStyle()
+	if err != nil {
+		return err
+	}
+	changed := *reset
+	if *reset {
+		style = strategy.Style{}
+	}
+	for _, setPath := range learn {
+		set, err := loadLibrary(ctx, setPath, *noCache)
+		if err != nil {
+			return fmt.Errorf("--learn: %w", err)
+		}
+		style.Learn(set.Tracks)
+		fmt.Printf("Learned %d transition(s) from %s\n", max(len(set.Tracks)-1, 0), setPath)
+		changed = true
+		if *feedback == "" {
+			continue
+		}
+		f, err := os.Open(*feedback)
+		if err != nil {
+			return fmt.Errorf("open feedback: %w", err)
+		}
+		reactions, err := readReactions(f)
+		_ = f.Close()
+		if err != nil {
+			return err
+		}
+		for _, r := range reactions {
+			if i := trackAt(set.Tracks, r.at); !r.good && i > 0 {
+				style.Dislike(strategy.Adjacency{From: trackRef(set.Tracks[i-1]), To: trackRef(set.Tracks[i])})
+			}
+		}
+	}
+	for _, move := range favor {
+		if !slices.Contains(style.Favorites, move) {
+			style.Favorites = append(style.Favorites, move)
+		}
+		changed = true
+	}
+	for _, adj := range disliked {
+		style.Dislike(adj)
+		changed = true
+	}
+	fs.Visit(func(f *flag.Flag) {
+		switch f.Name {
+		case "bpm-delta":
+			style.BPMDelta, changed = *bpmDelta, true
+		case "energy-jump":
+			style.EnergyJump, changed = *energyJump, true
+		case "weight":
+			style.Weight, changed = *weight, true
+		}
+	})
+
+	if changed {
+		if err := saveStyle(path, style); err != nil {
+			return err
+		}
+		fmt.Printf("Saved your mixing style to %s\n", path)
+	}
+	printStyle(style)
+	return nil
+}
+
+// printStyle reports a style: what was learned from, and what it leans toward.
+func printStyle(s strategy.Style) {
+	if s.Empty() {
+		fmt.Println("No mixing style yet: teach one with --learn, or declare it with --favor, --bpm-delta, --energy-jump, and --dislike.")
+		return
+	}
+	fmt.Printf("Your mixing style, from %d set(s) and %d transition(s):\n", s.Sets, s.Transitions)
+	for _, move := range strategy.KeyMoves() {
+		if share, ok := s.KeyMoves[move]; ok {
+			fmt.Printf("  key move %-8s %3.0f%%\n", move, 100*share)
+		}
+	}
+	if len(s.Favorites) > 0 {
+		fmt.Printf("  Favorite key moves: %s\n", strings.Join(s.Favorites, ", "))
+	}
+	if s.BPMDelta > 0 {
+		fmt.Printf("  Tempo changes up to %g BPM\n", s.BPMDelta)
+	}
+	if s.EnergyJump > 0 {
+		fmt.Printf("  Energy jumps up to %g\n", s.EnergyJump)
+	}
+	for _, d := range s.Disliked {
+		fmt.Printf("  Not back to back: %s, then %s\n", d.From, d.To)
+	}
+	if s.Weight > 0 {
+		fmt.Printf("  Weight %g\n", s.Weight)
+	}
+}
@@ -31,6 +31,8 @@ func runTournament(ctx context.Context, args []string) error {
 	minutes := fs.Float64("time", 0, "Target set length in minutes (e.g. 180 for 3 hours)")
 	variety := fs.Float64("variety", defaultVariety, "Diversity: how hard over-represented vibes are pared down")
 	seedFlag := fs.Int64("seed", 0, "Optional seed for deterministic pairing")
+	inferEnergy := fs.Bool("infer-energy", false, "Estimate energy for tracks that have none (from BPM, genre, loudness, danceability)")
+	noCache := fs.Bool("no-cache", false, "Parse the input afresh instead of using the parsed-library cache")
 
 	fs.Usage = func() {
 		w := fs.Output()
@@ -53,10 +55,15 @@ func runTournament(ctx context.Context, args []string) error {
 		return errors.New("--time (minutes) is required and must be positive")
 	}
 
-	playlist, err := csvio.LoadPlaylist(ctx, *inputPath)
+	playlist, err := loadLibrary(ctx, *inputPath, *noCache)
 	if err != nil {
 		return err
 	}
+	energyWarnings, err := checkEnergy(playlist.Tracks, *inferEnergy)
+	if err != nil {
+		return err
+	}
+	defer printWarnings(append(playlist.Warnings, energyWarnings...))
 	tracks := playlist.Tracks
 	if len(tracks) < 2 {
 		return fmt.Errorf("need at least 2 tracks to run a tournament, got %d", len(tracks))
@@ -93,9 +100,10 @@ func runTournament(ctx context.Context, args []string) error {
 		resolvedOutput = deriveTournamentOutput(*inputPath)
 	}
 	if err := csvio.SaveInFormat(ctx, resolvedOutput, csvio.Playlist{
-		Header: playlist.Header,
-		CRLF:   playlist.CRLF,
-		Tracks: res.Kept,
+		Header:   playlist.Header,
+		CRLF:     playlist.CRLF,
+		Encoding: playlist.Encoding,
+		Tracks:   res.Kept,
 	}); err != nil {
 		return err
 	}
@@ -155,7 +163,7 @@ func songTitle(t track.Track) string {
 }
 
 func songMeta(t track.Track) string {
-	head := []string{t.Key.String(), fmt.Sprintf("%.0fbpm", t.BPM)}
+	head := []string{t.KeyString(), t.TempoString() + "bpm"}
 	if t.Duration != nil {
 		head = append(head, fmt.Sprintf("%d:%02d", *t.Duration/60, *t.Duration%60))
 	}
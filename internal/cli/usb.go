@@ -0,0 +1,173 @@
+package cli
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// audioExts are the files --music-dir looks among for a track with no location.
+var audioExts = map[string]bool{".mp3": true, ".flac": true, ".aif": true, ".aiff": true, ".wav": true, ".m4a": true}
+
+// trackFiles finds each track's audio file: its location, resolved against musicDir
+// when relative, or else a file in musicDir named "Artist - Title" or "Title". A track
+// with neither gets "".
+func trackFiles(tracks []track.Track, musicDir string) ([]string, error) {
+	byName := map[string]string{}
+	if musicDir != "" {
+		entries, err := os.ReadDir(musicDir)
+		if err != nil {
+			return nil, fmt.Errorf("--music-dir: %w", err)
+		}
+		for _, e := range entries {
+			ext := filepath.Ext(e.Name())
+			if e.IsDir() || !audioExts[strings.ToLower(ext)] {
+				continue
+			}
+			byName[strings.ToLower(strings.TrimSuffix(e.Name(), ext))] = filepath.Join(musicDir, e.Name())
+		}
+	}
+	files := make([]string, len(tracks))
+	for i, t := range tracks {
+		switch {
+		case t.Location != "" && (musicDir == "" || filepath.IsAbs(t.Location)):
+			files[i] = t.Location
+		case t.Location != "":
+			files[i] = filepath.Join(musicDir, t.Location)
+		case byName[strings.ToLower(t.Artist+" - "+t.Title)] != "":
+			files[i] = byName[strings.ToLower(t.Artist+" - "+t.Title)]
+		default:
+			files[i] = byName[strings.ToLower(t.Title)]
+		}
+	}
+	return files, nil
+}
+
+// Ways --copy-mode puts a set's files into the --copy-to folder.
+const (
+	copyModeCopy = "copy"
+	copyModeLink = "link"
+	copyModeMove = "move"
+)
+
+// folderExport is --copy-to: a folder to fill with the set's audio files, numbered in
+// set order, so any player or CDJ that sorts by name plays the set.
+type folderExport struct {
+	dir      string
+	mode     string // copyModeCopy, copyModeLink, or copyModeMove
+	musicDir string
+}
+
+func parseCopyMode(s string) (string, error) {
+	switch mode := strings.ToLower(strings.TrimSpace(s)); mode {
+	case copyModeCopy, copyModeLink, copyModeMove:
+		return mode, nil
+	}
+	return "", fmt.Errorf("unknown copy mode %q (want copy, link, or move)", s)
+}
+
+// write puts each track's file into the folder as "01 - Artist - Title.mp3", keeping
+// its extension, and reports what it did. Tracks whose file can't be found are left
+// out with a warning; a file already there under the same name is replaced.
+func (e folderExport) write(tracks []track.Track) error {
+	files, err := trackFiles(tracks, e.musicDir)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(e.dir, 0o755); err != nil {
+		return fmt.Errorf("--copy-to: %w", err)
+	}
+	width := max(2, len(fmt.Sprint(len(tracks))))
+	var warnings []string
+	written := 0
+	for i, t := range tracks {
+		src := files[i]
+		if src == "" {
+			warnings = append(warnings, fmt.Sprintf("no audio file for %q, left out of %s (add a location column or --music-dir)",
+				matrixLabel(t), e.dir))
+			continue
+		}
+		dst := filepath.Join(e.dir, numberedName(i+1, width, t, filepath.Ext(src)))
+		if err := placeFile(src, dst, e.mode); err != nil {
+			if errors.Is(err, os.ErrNotExist) {
+				warnings = append(warnings, fmt.Sprintf("%s not found, left out of %s", src, e.dir))
+				continue
+			}
+			return fmt.Errorf("--copy-to: %w", err)
+		}
+		written++
+	}
+	verb := map[string]string{copyModeCopy: "Copied", copyModeLink: "Linked", copyModeMove: "Moved"}[e.mode]
+	fmt.Printf("%s %d of %d tracks into %s, numbered in set order\n", verb, written, len(tracks), e.dir)
+	printWarnings(warnings)
+	return nil
+}
+
+// numberedName is a track's file name in set order: "07 - Artist - Title.mp3", with
+// the characters FAT32 and other USB file systems refuse replaced.
+func numberedName(pos, width int, t track.Track, ext string) string {
+	name := t.Title
+	if t.Artist != "" {
+		name = t.Artist + " - " + t.Title
+	}
+	name = strings.Map(func(r rune) rune {
+		if r < ' ' || strings.ContainsRune(`/\:*?"<>|`, r) {
+			return '_'
+		}
+		return r
+	}, strings.TrimSpace(name))
+	name = strings.TrimRight(name, ". ")
+	return fmt.Sprintf("%0*d - %s%s", width, pos, name, strings.ToLower(ext))
+}
+
+// placeFile copies, symlinks, or moves src to dst, replacing dst.
+func placeFile(src, dst, mode string) error {
+	if _, err := os.Stat(src); err != nil {
+		return err
+	}
+	if err := os.Remove(dst); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	switch mode {
+	case copyModeLink:
+		abs, err := filepath.Abs(src)
+		if err != nil {
+			return err
+		}
+		return os.Symlink(abs, dst)
+	case copyModeMove:
+		if err := os.Rename(src, dst); err == nil {
+			return nil
+		}
+		// Across file systems, such as onto a USB stick, a move is a copy and a delete.
+		if err := copyFile(src, dst); err != nil {
+			return err
+		}
+		return os.Remove(src)
+	}
+	return copyFile(src, dst)
+}
+
+func copyFile(src, dst string) (err error) {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = in.Close() }()
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := out.Close(); cerr != nil && err == nil {
+			err = cerr
+		}
+	}()
+	_, err = io.Copy(out, in)
+	return err
+}
@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// varietyNote reports how much of the previous set (--variety-from) ordered keeps:
+// its transitions, either way round, and its opener and closer.
+func varietyNote(previous, ordered []track.Track) string {
+	pairs := map[[2]string]bool{}
+	for i := 1; i < len(previous); i++ {
+		a, b := previous[i-1].ID(), previous[i].ID()
+		pairs[[2]string{a, b}], pairs[[2]string{b, a}] = true, true
+	}
+	kept := 0
+	for i := 1; i < len(ordered); i++ {
+		if pairs[[2]string{ordered[i-1].ID(), ordered[i].ID()}] {
+			kept++
+		}
+	}
+	note := fmt.Sprintf("Kept %d of the previous set's %d transition(s) (--variety-from)", kept, max(len(previous)-1, 0))
+	if len(ordered) > 0 && len(previous) > 0 {
+		switch {
+		case ordered[0].ID() == previous[0].ID() && ordered[len(ordered)-1].ID() == previous[len(previous)-1].ID():
+			note += "; same opener and closer"
+		case ordered[0].ID() == previous[0].ID():
+			note += "; same opener"
+		case ordered[len(ordered)-1].ID() == previous[len(previous)-1].ID():
+			note += "; same closer"
+		}
+	}
+	return note
+}
@@ -0,0 +1,182 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// runWhy handles `magicmix why ...`: from a run's --decision-log, it explains why a
+// track was not the one the default planner chose at a position, setting its score
+// components beside the winner's.
+func runWhy(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix why", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	logPath := fs.String("decision-log", "", "Decision log written by a run's --decision-log (required)")
+	position := fs.Int("position", 0, "Position in the set to ask about, 1-based (required)")
+	trackRef := fs.String("track", "", "Track to ask about, as \"Title|Artist\" (or just \"Title\") (required)")
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix why --decision-log FILE --position N --track \"Title|Artist\"\n\n")
+		_, _ = fmt.Fprintf(w, "Explain why the default strategy didn't put a track at a position, from a run's\n")
+		_, _ = fmt.Fprintf(w, "--decision-log: its score components against the chosen track's.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if *logPath == "" || *position < 1 || *trackRef == "" {
+		fs.Usage()
+		return errors.New("decision-log, a position of 1 or more, and track are required")
+	}
+	match, err := parseTrackRef(*trackRef)
+	if err != nil {
+		return fmt.Errorf("--track %w", err)
+	}
+	decisions, err := readDecisionLog(*logPath)
+	if err != nil {
+		return err
+	}
+	return explainWhyNot(os.Stdout, decisions, *position, match)
+}
+
+// readDecisionLog reads a decision log and returns the decisions of its last pass: the
+// sort whose ordering the run wrote.
+func readDecisionLog(path string) ([]strategy.Decision, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open decision log: %w", err)
+	}
+	defer f.Close()
+
+	var decisions []strategy.Decision
+	pass := 0
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 64<<20) // a decision lists every candidate, so lines run long
+	for line := 1; scanner.Scan(); line++ {
+		var rec decisionRecord
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("decision log line %d: %w", line, err)
+		}
+		if rec.Pass != pass {
+			decisions, pass = nil, rec.Pass
+		}
+		decisions = append(decisions, rec.Decision)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read decision log: %w", err)
+	}
+	if len(decisions) == 0 {
+		return nil, fmt.Errorf("decision log %s is empty; was it written by the default strategy?", path)
+	}
+	return decisions, nil
+}
+
+// explainWhyNot writes why the track match picks was not chosen at position (1-based).
+func explainWhyNot(w io.Writer, decisions []strategy.Decision, position int, match func(track.Track) bool) error {
+	matches := func(c strategy.DecisionCandidate) bool {
+		return match(track.Track{Title: c.Title, Artist: c.Artist})
+	}
+	placedAt := func() int {
+		for _, d := range decisions {
+			if d.Chosen >= 0 && matches(d.Candidates[d.Chosen]) {
+				return d.Position + 1
+			}
+		}
+		return 0
+	}()
+	idx := slices.IndexFunc(decisions, func(d strategy.Decision) bool { return d.Position == position-1 })
+	if idx < 0 {
+		return fmt.Errorf("the decision log has no position %d (the planner placed %d tracks)", position, len(decisions))
+	}
+	d := decisions[idx]
+	if d.Chosen < 0 {
+		return fmt.Errorf("the decision log records no choice at position %d", position)
+	}
+	winner := d.Candidates[d.Chosen]
+
+	_, _ = fmt.Fprintf(w, "Position %d", position)
+	if d.State.Prev != "" {
+		_, _ = fmt.Fprintf(w, " (after %q in %s; energy cycle %d, track %d of ~%d)", d.State.Prev, d.State.PrevKey,
+			d.State.Cycle+1, d.State.TracksInCycle+1, d.State.DesiredCycleLen)
+	}
+	_, _ = fmt.Fprintf(w, "\n  chosen: %s\n", describeCandidate(winner))
+
+	switch {
+	case matches(winner):
+		_, _ = fmt.Fprintln(w, "It was chosen here.")
+		return nil
+	case placedAt == 0:
+		_, _ = fmt.Fprintln(w, "The planner never placed it: it isn't in the library the log covers, or it was left out before sorting.")
+		return nil
+	case placedAt < position:
+		_, _ = fmt.Fprintf(w, "It was already placed, at position %d.\n", placedAt)
+		return nil
+	}
+
+	if d.Position == 0 {
+		_, _ = fmt.Fprintf(w, "The opener is picked by a start heuristic (the lowest-energy fit near the median tempo), not by score. It went at position %d.\n", placedAt)
+		return nil
+	}
+	c := slices.IndexFunc(d.Candidates, matches)
+	if c < 0 {
+		_, _ = fmt.Fprintf(w, "It wasn't a candidate here: it was held for a pinned position, or only tracks the set must include still fit. It went at position %d.\n", placedAt)
+		return nil
+	}
+	asked := d.Candidates[c]
+	_, _ = fmt.Fprintf(w, "  asked:  %s\n", describeCandidate(asked))
+	_, _ = fmt.Fprintf(w, "It ranked %d of %d candidates and went at position %d.\n", c+1, len(d.Candidates), placedAt)
+	if rank, winnerRank := slices.Index(d.CategoryOrder, asked.Category), slices.Index(d.CategoryOrder, winner.Category); rank > winnerRank {
+		_, _ = fmt.Fprintf(w, "Its key move (%s) ranks behind the winner's (%s) in this position's category order (%s); the planner only weighs the first category with a candidate, whatever the scores.\n",
+			asked.Category, winner.Category, strings.Join(d.CategoryOrder, ", "))
+		return nil
+	}
+	if asked.Total-winner.Total < 1e-6 {
+		_, _ = fmt.Fprintln(w, "It scored as well as the winner; the seeded tie-break picked the winner.")
+		return nil
+	}
+	_, _ = fmt.Fprintf(w, "It scored %.2f worse:\n", asked.Total-winner.Total)
+	for _, part := range []struct {
+		name         string
+		asked, chose float64
+	}{
+		{"key", asked.KeyCost, winner.KeyCost},
+		{"bpm", asked.BPMCost, winner.BPMCost},
+		{"energy", asked.EnergyCost, winner.EnergyCost},
+		{"priority", asked.PriorityCost, winner.PriorityCost},
+		{"inventory", asked.Inventory, winner.Inventory},
+	} {
+		if diff := part.asked - part.chose; diff > 1e-6 || diff < -1e-6 {
+			_, _ = fmt.Fprintf(w, "  %-9s %+6.2f (%.2f vs %.2f)\n", part.name, diff, part.asked, part.chose)
+		}
+	}
+	return nil
+}
+
+// describeCandidate is one line about a candidate: the track, its key move, and its
+// score with the components that make it up.
+func describeCandidate(c strategy.DecisionCandidate) string {
+	s := fmt.Sprintf("%q by %s (%s, %s BPM, energy %d", c.Title, c.Artist, c.Key, c.BPM, c.Energy)
+	if c.Category != "" {
+		s += ", " + c.Category
+	}
+	s += ")"
+	if c.Category == "" {
+		return s // the opener has no score
+	}
+	return s + fmt.Sprintf(" total %.2f = key %.2f + bpm %.2f + energy %.2f + priority %.2f + inventory %.2f",
+		c.Total, c.KeyCost, c.BPMCost, c.EnergyCost, c.PriorityCost, c.Inventory)
+}
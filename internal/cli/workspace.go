@@ -0,0 +1,330 @@
+package cli
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+)
+
+// workspaceReserved are the flags a workspace sets from its own fields, so its rules
+// file may not.
+var workspaceReserved = []string{"input", "output", "workspace", "profile", "config"}
+
+// applyWorkspace sets fs's flags from the workspace ref names (see
+// config.FindWorkspace): its inputs, tuning file, rules, and profile. Flags given on
+// the command line win, as over a profile; the workspace's rules win over its
+// profile, which run applies after unless the workspace carries its own (see
+// config.Workspace.EmbeddedProfile).
+func applyWorkspace(fs *flag.FlagSet, ref string) (config.Workspace, error) {
+	dir, err := config.FindWorkspace(ref)
+	if err != nil {
+		return config.Workspace{}, err
+	}
+	ws, err := config.LoadWorkspace(dir)
+	if err != nil {
+		return config.Workspace{}, err
+	}
+	rules, err := ws.LoadRules()
+	if err != nil {
+		return config.Workspace{}, fmt.Errorf("workspace %s: %w", ws.Name, err)
+	}
+	embedded, err := ws.EmbeddedProfile()
+	if err != nil {
+		return config.Workspace{}, fmt.Errorf("workspace %s: %w", ws.Name, err)
+	}
+	given := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) { given[f.Name] = true })
+	if !given["input"] {
+		for _, in := range ws.Inputs {
+			spec, err := parseInputSpec(in)
+			if err != nil {
+				return config.Workspace{}, fmt.Errorf("workspace %s: %w", ws.Name, err)
+			}
+			value := jobPath(ws.Dir, spec.path)
+			if spec.weight != 1 {
+				value += ":" + strconv.FormatFloat(spec.weight, 'g', -1, 64)
+			}
+			if err := fs.Set("input", value); err != nil {
+				return config.Workspace{}, err
+			}
+		}
+	}
+	for flagName, v := range map[string]string{"config": ws.Path(ws.Config), "profile": ws.Profile} {
+		if flagName == "profile" && embedded != nil {
+			continue
+		}
+		if v != "" && !given[flagName] {
+			if err := fs.Set(flagName, v); err != nil {
+				return config.Workspace{}, err
+			}
+		}
+	}
+	source := fmt.Sprintf("workspace %s, %s", ws.Name, ws.Rules)
+	err = applySettings(fs, source, rules, workspaceReserved, func(flagName, v string) string {
+		if slices.Contains(jobPathFlags, flagName) {
+			return jobPath(ws.Dir, v)
+		}
+		return v
+	})
+	if err == nil && embedded != nil && !given["profile"] {
+		err = applySettings(fs, fmt.Sprintf("workspace %s, profile %q", ws.Name, ws.Profile), embedded, []string{"profile"}, nil)
+	}
+	return ws, err
+}
+
+// workspaceOutput points --output, unless given, at a new set in the workspace's
+// outputs, in the --output-format asked for, and returns the file it reserved there
+// (see config.Workspace.NextOutput).
+func workspaceOutput(fs *flag.FlagSet, ws config.Workspace) (string, error) {
+	if fs.Lookup("output").Value.String() != "" {
+		return "", nil
+	}
+	f, err := outputFormat(fs.Lookup("output-format").Value.String(), ".csv")
+	if err != nil {
+		return "", err
+	}
+	path, err := ws.NextOutput(time.Now(), f.Ext())
+	if err != nil {
+		return "", fmt.Errorf("workspace %s: %w", ws.Name, err)
+	}
+	return path, fs.Set("output", path)
+}
+
+// runWorkspace handles `magicmix workspace ...`: it creates, lists, shows, and packs
+// workspaces (see config.Workspace), which the main command sorts with by name
+// through --workspace.
+func runWorkspace(_ context.Context, args []string) error {
+	fs := flag.NewFlagSet("magicmix workspace", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	var inputs stringsFlag
+	fs.Var(&inputs, "input", "init: the library the workspace sorts, as --input takes it; repeatable")
+	rules := fs.String("rules", "", "init: a JSON file of flags, as a profile's settings, copied into the workspace as its rules")
+	tuning := fs.String("config", "", "init: a --config tuning file, copied into the workspace")
+	profile := fs.String("profile", "", "init: a profile from profiles.json to apply under the workspace's rules")
+	keep := fs.Int("keep", 0, fmt.Sprintf("init: how many written sets to keep (0 = %d, -1 = all)", config.DefaultKeep))
+
+	fs.Usage = func() {
+		w := fs.Output()
+		_, _ = fmt.Fprintf(w, "Usage: magicmix workspace init NAME --input LIBRARY [--rules FILE] [--config FILE] [--profile NAME] [--keep N]\n")
+		_, _ = fmt.Fprintf(w, "       magicmix workspace list\n")
+		_, _ = fmt.Fprintf(w, "       magicmix workspace show NAME\n")
+		_, _ = fmt.Fprintf(w, "       magicmix workspace pack NAME FILE.zip\n\n")
+		_, _ = fmt.Fprintf(w, "Keep a recurring gig's library, rules, tuning, and profile together, with the sets\n")
+		_, _ = fmt.Fprintf(w, "written from them, in the config directory's %s folder; then sort with\n", config.WorkspacesDir)
+		_, _ = fmt.Fprintf(w, "magicmix --workspace NAME. Pack one into a .zip to take it to another machine,\n")
+		_, _ = fmt.Fprintf(w, "where --workspace FILE.zip unpacks it.\n\nOptions:\n")
+		fs.PrintDefaults()
+	}
+
+	if len(args) == 0 {
+		fs.Usage()
+		return errors.New("a workspace command is required: init, list, show, or pack")
+	}
+	command, rest := args[0], args[1:]
+	// The name may come before the options, as in `workspace init fridays --input x`.
+	var positional []string
+	for len(rest) > 0 && !strings.HasPrefix(rest[0], "-") {
+		positional, rest = append(positional, rest[0]), rest[1:]
+	}
+	if err := fs.Parse(rest); err != nil {
+		return err
+	}
+	positional = append(positional, fs.Args()...)
+
+	want := map[string]int{"init": 1, "list": 0, "show": 1, "pack": 2}
+	n, ok := want[command]
+	if !ok {
+		fs.Usage()
+		return fmt.Errorf("unknown workspace command %q (want init, list, show, or pack)", command)
+	}
+	if len(positional) != n {
+		fs.Usage()
+		return fmt.Errorf("workspace %s takes %d argument(s), got %d", command, n, len(positional))
+	}
+	if command != "init" && (len(inputs) > 0 || *rules != "" || *tuning != "" || *profile != "" || *keep != 0) {
+		return errors.New("--input, --rules, --config, --profile, and --keep are for workspace init")
+	}
+
+	switch command {
+	case "init":
+		return initWorkspace(positional[0], inputs, *rules, *tuning, *profile, *keep)
+	case "list":
+		names, err := config.ListWorkspaces()
+		if err != nil {
+			return err
+		}
+		if len(names) == 0 {
+			fmt.Println("No workspaces yet: create one with magicmix workspace init NAME --input LIBRARY.")
+		}
+		for _, name := range names {
+			fmt.Println(name)
+		}
+		return nil
+	case "show":
+		dir, err := config.FindWorkspace(positional[0])
+		if err != nil {
+			return err
+		}
+		ws, err := config.LoadWorkspace(dir)
+		if err != nil {
+			return err
+		}
+		return printWorkspace(ws)
+	default:
+		dir, err := config.FindWorkspace(positional[0])
+		if err != nil {
+			return err
+		}
+		if err := config.PackWorkspace(dir, positional[1]); err != nil {
+			return err
+		}
+		fmt.Printf("Packed workspace %s into %s\n", positional[0], positional[1])
+		return nil
+	}
+}
+
+// initWorkspace creates the workspace called name. Libraries stay where they are, by
+// path relative to the workspace, so it sorts the same from any directory and a
+// library kept beside the workspaces folder still resolves where it is copied to; the
+// rules and tuning files are copied in, so editing them changes this workspace alone.
+func initWorkspace(name string, inputs []string, rules, tuning, profile string, keep int) error {
+	if name == "" || !filepath.IsLocal(name) || strings.ContainsAny(name, `/\`) {
+		return fmt.Errorf("workspace name %q: want a plain name, such as fridays", name)
+	}
+	if len(inputs) == 0 {
+		return errors.New("workspace init: --input is required")
+	}
+	if keep < -1 {
+		return errors.New("--keep must be -1 (keep all), 0 (the default), or more")
+	}
+	dir, err := config.WorkspacePath(name)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(filepath.Join(dir, config.WorkspaceFile)); err == nil {
+		return fmt.Errorf("workspace %s already exists in %s", name, dir)
+	}
+	ws := config.Workspace{Name: name, Dir: dir, Profile: profile, Keep: keep}
+	for _, in := range inputs {
+		spec, err := parseInputSpec(in)
+		if err != nil {
+			return err
+		}
+		if spec.path == playlistio.Stdio {
+			return errors.New("workspace init: a workspace can't read standard input; give a library file")
+		}
+		path, err := filepath.Abs(spec.path)
+		if err != nil {
+			return err
+		}
+		if rel, err := filepath.Rel(dir, path); err == nil {
+			path = rel
+		}
+		if spec.weight != 1 {
+			path += ":" + strconv.FormatFloat(spec.weight, 'g', -1, 64)
+		}
+		ws.Inputs = append(ws.Inputs, path)
+	}
+	if rules != "" {
+		if _, err := readRules(rules); err != nil {
+			return err
+		}
+	}
+	if tuning != "" {
+		if _, err := readTuning(tuning); err != nil {
+			return err
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("write workspace: %w", err)
+	}
+	for _, c := range []struct {
+		from, to string
+		field    *string
+	}{{rules, config.RulesFile, &ws.Rules}, {tuning, "config.json", &ws.Config}} {
+		if c.from == "" {
+			continue
+		}
+		data, err := os.ReadFile(c.from)
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dir, c.to), data, 0o644); err != nil {
+			return fmt.Errorf("write workspace: %w", err)
+		}
+		*c.field = c.to
+	}
+	if ws.Rules == config.RulesFile {
+		ws.Rules = "" // found there by default
+	}
+	if err := config.SaveWorkspace(ws); err != nil {
+		return err
+	}
+	fmt.Printf("Created workspace %s in %s; sort with magicmix --workspace %s\n", name, dir, name)
+	return nil
+}
+
+// readRules checks a rules file the way the workspace will read it.
+func readRules(path string) (config.Profile, error) {
+	ws := config.Workspace{Rules: path}
+	rules, err := ws.LoadRules()
+	if err != nil {
+		return nil, err
+	}
+	for _, flagName := range rules.Flags() {
+		if slices.Contains(workspaceReserved, flagName) {
+			return nil, fmt.Errorf("%s: --%s can't be set in rules; the workspace sets it", path, flagName)
+		}
+	}
+	return rules, nil
+}
+
+// printWorkspace reports a workspace: what it sorts with, and its recent sets.
+func printWorkspace(ws config.Workspace) error {
+	fmt.Printf("Workspace %s (%s)\n", ws.Name, ws.Dir)
+	for _, in := range ws.Inputs {
+		fmt.Printf("  Library: %s\n", in)
+	}
+	rules, err := ws.LoadRules()
+	if err != nil {
+		return err
+	}
+	for _, flagName := range rules.Flags() {
+		fmt.Printf("  Rule: --%s %s\n", flagName, strings.Join(rules[flagName], ", "))
+	}
+	if ws.Config != "" {
+		fmt.Printf("  Tuning: %s\n", ws.Path(ws.Config))
+	}
+	embedded, err := ws.EmbeddedProfile()
+	if err != nil {
+		return err
+	}
+	switch {
+	case embedded != nil:
+		fmt.Printf("  Profile: %s, packed in %s\n", ws.Profile, config.ProfileFile)
+	case ws.Profile != "":
+		fmt.Printf("  Profile: %s\n", ws.Profile)
+	}
+	sets, err := ws.Outputs()
+	if err != nil {
+		return err
+	}
+	if len(sets) == 0 {
+		fmt.Println("  No sets written yet")
+	}
+	for _, set := range sets {
+		fmt.Printf("  Set: %s\n", set[0])
+	}
+	return nil
+}
@@ -0,0 +1,39 @@
+// Package config locates magicmix's per-user configuration directory, where caches
+// and other persistent state live, and reads the named profiles and workspaces kept
+// there.
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// DirEnv overrides the configuration directory when set.
+const DirEnv = "MAGICMIX_CONFIG_DIR"
+
+// Dir returns the configuration directory: $MAGICMIX_CONFIG_DIR when set, otherwise
+// "magicmix" under the platform's user configuration directory (e.g.
+// ~/.config/magicmix on Linux). The directory is not created.
+func Dir() (string, error) {
+	if dir := os.Getenv(DirEnv); dir != "" {
+		return dir, nil
+	}
+	base, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("locate config directory: %w", err)
+	}
+	return filepath.Join(base, "magicmix"), nil
+}
+
+// StyleFile is the file in the config directory that holds the user's mixing style.
+const StyleFile = "style.json"
+
+// StylePath is where the mixing style is kept: StyleFile in Dir.
+func StylePath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, StyleFile), nil
+}
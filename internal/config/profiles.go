@@ -0,0 +1,108 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// ProfilesFile is the file in the config directory that holds named profiles.
+const ProfilesFile = "profiles.json"
+
+// Profile is a named bundle of command-line settings for one kind of gig: flag names
+// (without dashes) and their values, several for a repeatable flag such as place.
+type Profile map[string][]string
+
+// Flags returns the profile's flag names in a stable order.
+func (p Profile) Flags() []string {
+	names := make([]string, 0, len(p))
+	for name := range p {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// ProfilesPath is where profiles are read from: ProfilesFile in Dir.
+func ProfilesPath() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, ProfilesFile), nil
+}
+
+// LoadProfile reads the profile called name from the profiles file at path, which
+// looks like
+//
+//	{"profiles": {"club": {"strategy": "flow", "limit": 40, "place": ["tag:anthem@last:30m"]}}}
+//
+// A value may be a string, number, or boolean, or a list of them for a repeatable
+// flag.
+func LoadProfile(path, name string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("profile %q: no profiles file at %s", name, path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("read profiles: %w", err)
+	}
+	var file struct {
+		Profiles map[string]map[string]json.RawMessage `json:"profiles"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	raw, ok := file.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(file.Profiles))
+		for n := range file.Profiles {
+			names = append(names, n)
+		}
+		slices.Sort(names)
+		return nil, fmt.Errorf("unknown profile %q in %s (have %s)", name, path, strings.Join(names, ", "))
+	}
+	p := make(Profile, len(raw))
+	for flag, value := range raw {
+		values, err := profileValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("profile %q, %s: %w", name, flag, err)
+		}
+		p[strings.TrimLeft(flag, "-")] = values
+	}
+	return p, nil
+}
+
+// profileValues turns one JSON setting into flag values.
+func profileValues(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	dec.UseNumber()
+	var v any
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	items, ok := v.([]any)
+	if !ok {
+		items = []any{v}
+	}
+	values := make([]string, len(items))
+	for i, item := range items {
+		switch item := item.(type) {
+		case string:
+			values[i] = item
+		case json.Number:
+			values[i] = item.String()
+		case bool:
+			values[i] = fmt.Sprint(item)
+		default:
+			return nil, fmt.Errorf("want a string, number, boolean, or a list of them, got %s", raw)
+		}
+	}
+	return values, nil
+}
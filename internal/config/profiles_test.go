@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestLoadProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ProfilesFile)
+	data := `{"profiles": {
+		"club": {"strategy": "flow", "limit": 40, "keep-all": true, "--place": ["tag:anthem@last:30m", "energy<40@first:10%"]},
+		"radio": {"strategy": "default"}
+	}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := LoadProfile(path, "club")
+	if err != nil {
+		t.Fatalf("LoadProfile: %v", err)
+	}
+	if !slices.Equal(p.Flags(), []string{"keep-all", "limit", "place", "strategy"}) {
+		t.Fatalf("flags = %v", p.Flags())
+	}
+	if p["limit"][0] != "40" || p["keep-all"][0] != "true" || len(p["place"]) != 2 {
+		t.Fatalf("unexpected values %v", p)
+	}
+
+	if _, err := LoadProfile(path, "wedding"); err == nil || !strings.Contains(err.Error(), "have club, radio") {
+		t.Fatalf("an unknown profile should list the known ones, got %v", err)
+	}
+	if _, err := LoadProfile(filepath.Join(t.TempDir(), ProfilesFile), "club"); err == nil {
+		t.Fatal("a missing profiles file should be an error")
+	}
+}
@@ -0,0 +1,555 @@
+package config
+
+import (
+	"archive/zip"
+	"bytes"
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Workspace layout: WorkspacesDir in Dir holds one directory per workspace, named for
+// it, each with WorkspaceFile, an optional RulesFile, and the sets written from it in
+// OutputsDir. A workspace unpacked from PackWorkspace's archive also has its profile in
+// ProfileFile and the files it named outside its directory in PackedDir.
+const (
+	WorkspacesDir = "workspaces"
+	WorkspaceFile = "workspace.json"
+	RulesFile     = "rules.json"
+	OutputsDir    = "outputs"
+	ProfileFile   = "profile.json"
+	PackedDir     = "packed"
+)
+
+// DefaultKeep is how many sets a workspace keeps in OutputsDir unless it says.
+const DefaultKeep = 10
+
+// outputStamp names each set a workspace writes, so the names sort oldest first.
+const outputStamp = "20060102-150405"
+
+// Workspace bundles what a recurring gig sorts with: the library, the rules, the
+// strategy tuning, and a profile, kept with the sets written from them. Its file looks
+// like
+//
+//	{"inputs": ["../../music/library.csv", "promos.csv:0.3"], "rules": "rules.json",
+//	 "config": "tuning.json", "profile": "club", "keep": 10}
+//
+// Inputs are --input values; Rules is a JSON file of flags shaped like a profile's
+// settings ({"artist-gap": 3, "place": ["tag:anthem@last:30m"]}); Config is a --config
+// tuning file; Profile names a profile in ProfilesFile, or in ProfileFile when the
+// workspace has one (see EmbeddedProfile). Relative paths are relative to the
+// workspace's directory, and Rules defaults to RulesFile there when it exists.
+type Workspace struct {
+	Name    string   `json:"-"`
+	Dir     string   `json:"-"`
+	Inputs  []string `json:"inputs"`
+	Rules   string   `json:"rules,omitempty"`
+	Config  string   `json:"config,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+	Keep    int      `json:"keep,omitempty"` // sets kept in OutputsDir; 0 is DefaultKeep, -1 keeps all
+}
+
+// WorkspacePath is the directory of the workspace called name: name in WorkspacesDir.
+func WorkspacePath(name string) (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, WorkspacesDir, name), nil
+}
+
+// FindWorkspace resolves a workspace reference: the name of one in WorkspacesDir, a
+// directory holding WorkspaceFile, or a .zip archive of one (see PackWorkspace),
+// which is unpacked into WorkspacesDir under the archive's base name the first time
+// it is used and found there after.
+func FindWorkspace(ref string) (string, error) {
+	if strings.EqualFold(filepath.Ext(ref), ".zip") {
+		dir, err := WorkspacePath(strings.TrimSuffix(filepath.Base(ref), filepath.Ext(ref)))
+		if err != nil {
+			return "", err
+		}
+		if _, err := os.Stat(filepath.Join(dir, WorkspaceFile)); err == nil {
+			return dir, nil
+		}
+		if err := unpackWorkspace(ref, dir); err != nil {
+			return "", fmt.Errorf("workspace %s: %w", ref, err)
+		}
+		return dir, nil
+	}
+	if _, err := os.Stat(filepath.Join(ref, WorkspaceFile)); err == nil {
+		return ref, nil
+	}
+	if strings.ContainsRune(ref, filepath.Separator) || strings.ContainsRune(ref, '/') {
+		return "", fmt.Errorf("workspace %s: no %s there", ref, WorkspaceFile)
+	}
+	dir, err := WorkspacePath(ref)
+	if err != nil {
+		return "", err
+	}
+	if _, err := os.Stat(filepath.Join(dir, WorkspaceFile)); err != nil {
+		names, _ := ListWorkspaces()
+		if len(names) == 0 {
+			return "", fmt.Errorf("unknown workspace %q (create one with magicmix workspace init)", ref)
+		}
+		return "", fmt.Errorf("unknown workspace %q (have %s)", ref, strings.Join(names, ", "))
+	}
+	return dir, nil
+}
+
+// LoadWorkspace reads the workspace in dir. Unknown fields are an error, so a typo
+// can't pass silently.
+func LoadWorkspace(dir string) (Workspace, error) {
+	path := filepath.Join(dir, WorkspaceFile)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return Workspace{}, fmt.Errorf("read workspace: %w", err)
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var w Workspace
+	if err := dec.Decode(&w); err != nil {
+		return Workspace{}, fmt.Errorf("parse %s: %w", path, err)
+	}
+	if len(w.Inputs) == 0 {
+		return Workspace{}, fmt.Errorf("workspace %s: inputs is required", path)
+	}
+	if w.Keep < -1 {
+		return Workspace{}, fmt.Errorf("workspace %s: keep must be -1 (keep all), 0 (the default of %d), or more", path, DefaultKeep)
+	}
+	w.Name, w.Dir = filepath.Base(filepath.Clean(dir)), dir
+	if w.Rules == "" {
+		if _, err := os.Stat(filepath.Join(dir, RulesFile)); err == nil {
+			w.Rules = RulesFile
+		}
+	}
+	return w, nil
+}
+
+// SaveWorkspace writes w's file into w.Dir, creating the directory if needed.
+func SaveWorkspace(w Workspace) error {
+	data, err := json.MarshalIndent(w, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Join(w.Dir, OutputsDir), 0o755); err != nil {
+		return fmt.Errorf("write workspace: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(w.Dir, WorkspaceFile), append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("write workspace: %w", err)
+	}
+	return nil
+}
+
+// ListWorkspaces names the workspaces in WorkspacesDir, sorted.
+func ListWorkspaces() ([]string, error) {
+	dir, err := WorkspacePath("")
+	if err != nil {
+		return nil, err
+	}
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list workspaces: %w", err)
+	}
+	var names []string
+	for _, e := range entries {
+		if _, err := os.Stat(filepath.Join(dir, e.Name(), WorkspaceFile)); e.IsDir() && err == nil {
+			names = append(names, e.Name())
+		}
+	}
+	return names, nil
+}
+
+// LoadRules reads the workspace's rules file into flags; a workspace with none has no
+// rules.
+func (w Workspace) LoadRules() (Profile, error) {
+	if w.Rules == "" {
+		return Profile{}, nil
+	}
+	return readSettings(w.Path(w.Rules), "rules")
+}
+
+// EmbeddedProfile reads the profile PackWorkspace packed into ProfileFile, which the
+// workspace sorts with in place of the one named in ProfilesFile. It is nil when there
+// is none, as in a workspace that was never packed.
+func (w Workspace) EmbeddedProfile() (Profile, error) {
+	path := filepath.Join(w.Dir, ProfileFile)
+	if _, err := os.Stat(path); errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	return readSettings(path, "profile")
+}
+
+// readSettings reads a JSON file of flags shaped like a profile's settings; what names
+// it in errors.
+func readSettings(path, what string) (Profile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read %s: %w", what, err)
+	}
+	var raw map[string]json.RawMessage
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse %s: %w", path, err)
+	}
+	p := make(Profile, len(raw))
+	for flag, value := range raw {
+		values, err := profileValues(value)
+		if err != nil {
+			return nil, fmt.Errorf("%s, %s: %w", path, flag, err)
+		}
+		p[strings.TrimLeft(flag, "-")] = values
+	}
+	return p, nil
+}
+
+// Path resolves a path in the workspace's file against its directory.
+func (w Workspace) Path(path string) string {
+	if path == "" || filepath.IsAbs(path) {
+		return path
+	}
+	return filepath.Join(w.Dir, path)
+}
+
+// NextOutput reserves the file for a set written now, with extension ext: one in
+// OutputsDir named for the time, with a counter after it (-2, -3, …) when a set was
+// already written that second. The file is created empty, so two runs at once can't
+// take the same name.
+func (w Workspace) NextOutput(now time.Time, ext string) (string, error) {
+	dir := filepath.Join(w.Dir, OutputsDir)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", fmt.Errorf("reserve output: %w", err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", fmt.Errorf("reserve output: %w", err)
+	}
+	taken := map[string]bool{}
+	for _, e := range entries {
+		key, _, _ := strings.Cut(e.Name(), ".")
+		taken[key] = true
+	}
+	stamp := now.Format(outputStamp)
+	for n := 1; ; n++ {
+		key := stamp
+		if n > 1 {
+			key += "-" + strconv.Itoa(n)
+		}
+		if taken[key] {
+			continue
+		}
+		path := filepath.Join(dir, key+ext)
+		f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		if err != nil {
+			return "", fmt.Errorf("reserve output: %w", err)
+		}
+		return path, f.Close()
+	}
+}
+
+// outputKey reads the name of a file in OutputsDir: the time its set was written and
+// its counter (see NextOutput), which the set's files share. ok is false for a file
+// that isn't part of a set.
+func outputKey(name string) (stamp string, n int, ok bool) {
+	key, _, _ := strings.Cut(name, ".")
+	if len(key) < len(outputStamp) {
+		return "", 0, false
+	}
+	stamp, rest := key[:len(outputStamp)], key[len(outputStamp):]
+	if _, err := time.Parse(outputStamp, stamp); err != nil {
+		return "", 0, false
+	}
+	if rest == "" {
+		return stamp, 1, true
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(rest, "-"))
+	if !strings.HasPrefix(rest, "-") || err != nil || n < 2 {
+		return "", 0, false
+	}
+	return stamp, n, true
+}
+
+// Outputs lists the sets in OutputsDir, newest first, each with the files written
+// beside it (such as its list of dropped tracks).
+func (w Workspace) Outputs() ([][]string, error) {
+	entries, err := os.ReadDir(filepath.Join(w.Dir, OutputsDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("list outputs: %w", err)
+	}
+	type set struct {
+		stamp string
+		n     int
+		paths []string
+	}
+	var sets []*set
+	byKey := map[string]*set{}
+	for _, e := range entries {
+		stamp, n, ok := outputKey(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		key := stamp + "-" + strconv.Itoa(n)
+		s := byKey[key]
+		if s == nil {
+			s = &set{stamp: stamp, n: n}
+			byKey[key] = s
+			sets = append(sets, s)
+		}
+		s.paths = append(s.paths, filepath.Join(w.Dir, OutputsDir, e.Name()))
+	}
+	slices.SortFunc(sets, func(a, b *set) int {
+		return cmp.Or(strings.Compare(b.stamp, a.stamp), b.n-a.n)
+	})
+	out := make([][]string, len(sets))
+	for i, s := range sets {
+		out[i] = s.paths
+	}
+	return out, nil
+}
+
+// PruneOutputs removes the sets in OutputsDir beyond the newest Keep, and returns how
+// many it removed.
+func (w Workspace) PruneOutputs() (int, error) {
+	keep := w.Keep
+	switch keep {
+	case -1:
+		return 0, nil
+	case 0:
+		keep = DefaultKeep
+	}
+	sets, err := w.Outputs()
+	if err != nil || len(sets) <= keep {
+		return 0, err
+	}
+	for _, set := range sets[keep:] {
+		for _, path := range set {
+			if err := os.Remove(path); err != nil {
+				return 0, fmt.Errorf("prune outputs: %w", err)
+			}
+		}
+	}
+	return len(sets) - keep, nil
+}
+
+// PackWorkspace writes the workspace in dir, outputs and all, to a .zip archive at
+// path, to copy to another machine. The archive stands alone: inputs, rules, and tuning
+// the workspace names outside its directory are packed into PackedDir, with the packed
+// WorkspaceFile naming them there, and its profile is packed into ProfileFile. An
+// archive written inside the workspace leaves itself out.
+func PackWorkspace(dir, path string) (err error) {
+	w, err := LoadWorkspace(dir)
+	if err != nil {
+		return err
+	}
+	profile, err := w.EmbeddedProfile()
+	if err != nil {
+		return err
+	}
+	if profile == nil && w.Profile != "" {
+		profiles, err := ProfilesPath()
+		if err != nil {
+			return err
+		}
+		if profile, err = LoadProfile(profiles, w.Profile); err != nil {
+			return fmt.Errorf("pack workspace: %w", err)
+		}
+	}
+
+	// Files outside the workspace go into PackedDir under their base names, numbered
+	// when two share one.
+	external := map[string]string{} // archive name to the file's path
+	pack := func(ref string) string {
+		src := w.Path(ref)
+		if rel, err := filepath.Rel(dir, src); err == nil && filepath.IsLocal(rel) {
+			return ref
+		}
+		base := filepath.Base(src)
+		name := PackedDir + "/" + base
+		for n := 2; ; n++ {
+			if prev, ok := external[name]; ok && prev == src {
+				return name
+			}
+			_, err := os.Stat(filepath.Join(dir, filepath.FromSlash(name)))
+			if _, ok := external[name]; !ok && errors.Is(err, fs.ErrNotExist) {
+				external[name] = src
+				return name
+			}
+			name = fmt.Sprintf("%s/%d-%s", PackedDir, n, base)
+		}
+	}
+	packed := w
+	packed.Inputs = make([]string, len(w.Inputs))
+	for i, in := range w.Inputs {
+		p, weight := splitWeight(in)
+		packed.Inputs[i] = pack(p) + weight
+	}
+	if w.Rules != "" {
+		if packed.Rules = pack(w.Rules); packed.Rules == RulesFile {
+			packed.Rules = "" // found there by default
+		}
+	}
+	if w.Config != "" {
+		packed.Config = pack(w.Config)
+	}
+	manifest, err := json.MarshalIndent(packed, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("pack workspace: %w", err)
+	}
+	defer func() { err = errors.Join(err, f.Close()) }()
+	self, err := f.Stat()
+	if err != nil {
+		return fmt.Errorf("pack workspace: %w", err)
+	}
+	zw := zip.NewWriter(f)
+	err = writeZipEntry(zw, WorkspaceFile, append(manifest, '\n'))
+	if err == nil && profile != nil {
+		var data []byte
+		if data, err = json.MarshalIndent(profile, "", "  "); err == nil {
+			err = writeZipEntry(zw, ProfileFile, append(data, '\n'))
+		}
+	}
+	if err == nil {
+		err = packTree(zw, dir, "", func(rel string, info fs.FileInfo) bool {
+			return os.SameFile(info, self) || rel == WorkspaceFile || (rel == ProfileFile && profile != nil)
+		})
+	}
+	names := make([]string, 0, len(external))
+	for name := range external {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		if err != nil {
+			break
+		}
+		err = packTree(zw, external[name], name, func(string, fs.FileInfo) bool { return false })
+	}
+	if err := errors.Join(err, zw.Close()); err != nil {
+		return fmt.Errorf("pack workspace: %w", err)
+	}
+	return nil
+}
+
+// packTree adds the file at src to zw as name, or, for a directory, each file under
+// it, named by its path relative to src under name, except those skip leaves out.
+func packTree(zw *zip.Writer, src, name string, skip func(rel string, info fs.FileInfo) bool) error {
+	return filepath.WalkDir(src, func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		rel, err := filepath.Rel(src, p)
+		if err != nil {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if skip(rel, info) {
+			return nil
+		}
+		entry := name
+		switch {
+		case entry == "":
+			entry = rel
+		case rel != ".":
+			entry += "/" + rel
+		}
+		w, err := zw.Create(entry)
+		if err != nil {
+			return err
+		}
+		in, err := os.Open(p)
+		if err != nil {
+			return err
+		}
+		defer func() { _ = in.Close() }()
+		_, err = io.Copy(w, in)
+		return err
+	})
+}
+
+func writeZipEntry(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// splitWeight splits the :WEIGHT an input may end with, as --input takes it, from its
+// path.
+func splitWeight(input string) (path, weight string) {
+	if i := strings.LastIndex(input, ":"); i > 0 {
+		if _, err := strconv.ParseFloat(input[i+1:], 64); err == nil {
+			return input[:i], input[i:]
+		}
+	}
+	return input, ""
+}
+
+// unpackWorkspace extracts a PackWorkspace archive into dir. An archive without
+// WorkspaceFile, or with an entry that would land outside dir, is an error.
+func unpackWorkspace(archive, dir string) error {
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = zr.Close() }()
+	if !slices.ContainsFunc(zr.File, func(f *zip.File) bool { return f.Name == WorkspaceFile }) {
+		return fmt.Errorf("no %s in the archive", WorkspaceFile)
+	}
+	for _, f := range zr.File {
+		if !filepath.IsLocal(f.Name) {
+			return fmt.Errorf("archive entry %q is outside the workspace", f.Name)
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		if err := extract(f, filepath.Join(dir, filepath.FromSlash(f.Name))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func extract(f *zip.File, path string) (err error) {
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	src, err := f.Open()
+	if err != nil {
+		return err
+	}
+	defer func() { _ = src.Close() }()
+	dst, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer func() { err = errors.Join(err, dst.Close()) }()
+	_, err = io.Copy(dst, src)
+	return err
+}
@@ -0,0 +1,172 @@
+package config
+
+import (
+	"archive/zip"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path, data string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWorkspace(t *testing.T) {
+	t.Setenv(DirEnv, t.TempDir())
+	dir, err := WorkspacePath("fridays")
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeFile(t, filepath.Join(dir, WorkspaceFile), `{"inputs": ["library.csv"], "profile": "club", "keep": 2}`)
+	writeFile(t, filepath.Join(dir, RulesFile), `{"artist-gap": 3, "--place": ["tag:anthem@last:30m"]}`)
+
+	found, err := FindWorkspace("fridays")
+	if err != nil || found != dir {
+		t.Fatalf("FindWorkspace(fridays) = %q, %v; want %q", found, err, dir)
+	}
+	if found, err := FindWorkspace(dir); err != nil || found != dir {
+		t.Fatalf("FindWorkspace(its directory) = %q, %v", found, err)
+	}
+	if _, err := FindWorkspace("mondays"); err == nil || !strings.Contains(err.Error(), "have fridays") {
+		t.Fatalf("an unknown workspace should list the known ones, got %v", err)
+	}
+
+	ws, err := LoadWorkspace(dir)
+	if err != nil {
+		t.Fatalf("LoadWorkspace: %v", err)
+	}
+	if ws.Name != "fridays" || ws.Rules != RulesFile || ws.Profile != "club" {
+		t.Fatalf("loaded %+v", ws)
+	}
+	rules, err := ws.LoadRules()
+	if err != nil || !slices.Equal(rules.Flags(), []string{"artist-gap", "place"}) || rules["artist-gap"][0] != "3" {
+		t.Fatalf("LoadRules = %v, %v", rules, err)
+	}
+
+	// Three sets written, each with a file beside it; keep 2 removes the oldest.
+	start := time.Date(2026, 10, 16, 21, 0, 0, 0, time.UTC)
+	for i := range 3 {
+		out, err := ws.NextOutput(start.Add(time.Duration(i)*time.Hour), ".csv")
+		if err != nil {
+			t.Fatalf("NextOutput: %v", err)
+		}
+		writeFile(t, out, "Title\n")
+		writeFile(t, strings.TrimSuffix(out, ".csv")+".dropped.csv", "Title\n")
+	}
+	writeFile(t, filepath.Join(dir, OutputsDir, "notes.txt"), "not a set")
+	if removed, err := ws.PruneOutputs(); err != nil || removed != 1 {
+		t.Fatalf("PruneOutputs = %d, %v; want 1 set removed", removed, err)
+	}
+	sets, err := ws.Outputs()
+	if err != nil || len(sets) != 2 || len(sets[0]) != 2 || filepath.Base(sets[0][0]) != "20261016-230000.csv" {
+		t.Fatalf("Outputs = %v, %v; want the newest 2 sets, newest first", sets, err)
+	}
+	if _, err := os.Stat(filepath.Join(dir, OutputsDir, "notes.txt")); err != nil {
+		t.Error("PruneOutputs removed a file that isn't a set")
+	}
+
+	// Two sets in one second get a counter, and list newest first.
+	late := start.Add(3 * time.Hour)
+	var same []string
+	for range 2 {
+		out, err := ws.NextOutput(late, ".m3u8")
+		if err != nil {
+			t.Fatalf("NextOutput: %v", err)
+		}
+		same = append(same, filepath.Base(out))
+	}
+	if !slices.Equal(same, []string{"20261017-000000.m3u8", "20261017-000000-2.m3u8"}) {
+		t.Fatalf("NextOutput within one second = %v, want the second numbered", same)
+	}
+	if sets, _ := ws.Outputs(); len(sets) != 4 || filepath.Base(sets[0][0]) != same[1] || filepath.Base(sets[1][0]) != same[0] {
+		t.Fatalf("Outputs = %v, want the numbered set first", sets)
+	}
+
+	bad := filepath.Join(t.TempDir(), "bad")
+	writeFile(t, filepath.Join(bad, WorkspaceFile), `{"inputs": ["a.csv"], "keeep": 3}`)
+	if _, err := LoadWorkspace(bad); err == nil {
+		t.Error("an unknown field should be an error")
+	}
+}
+
+func TestPackWorkspace(t *testing.T) {
+	config := t.TempDir()
+	t.Setenv(DirEnv, config)
+	writeFile(t, filepath.Join(config, ProfilesFile), `{"profiles": {"club": {"strategy": "flow", "limit": 40}}}`)
+	music := t.TempDir()
+	writeFile(t, filepath.Join(music, "library.csv"), "Title\nA\n")
+	writeFile(t, filepath.Join(music, "other", "library.csv"), "Title\nB\n")
+	src := filepath.Join(t.TempDir(), "residency")
+	writeFile(t, filepath.Join(src, WorkspaceFile), fmt.Sprintf(`{"inputs": [%q, %q], "profile": "club"}`,
+		filepath.Join(music, "library.csv"), filepath.Join(music, "other", "library.csv")+":0.5"))
+	writeFile(t, filepath.Join(src, RulesFile), `{"limit": 30}`)
+	writeFile(t, filepath.Join(src, OutputsDir, "20261016-210000.csv"), "Title\n")
+
+	// Packed inside the workspace, the archive leaves itself out.
+	archive := filepath.Join(src, "residency.zip")
+	if err := PackWorkspace(src, archive); err != nil {
+		t.Fatalf("PackWorkspace: %v", err)
+	}
+	zr, err := zip.OpenReader(archive)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	_ = zr.Close()
+	slices.Sort(names)
+	want := []string{OutputsDir + "/20261016-210000.csv", PackedDir + "/2-library.csv", PackedDir + "/library.csv",
+		ProfileFile, RulesFile, WorkspaceFile}
+	if !slices.Equal(names, want) {
+		t.Fatalf("packed %v, want %v", names, want)
+	}
+
+	// The archive stands alone: the libraries and profile it names are gone here.
+	if err := os.RemoveAll(music); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(filepath.Join(config, ProfilesFile)); err != nil {
+		t.Fatal(err)
+	}
+	dir, err := FindWorkspace(archive)
+	if err != nil {
+		t.Fatalf("FindWorkspace(archive): %v", err)
+	}
+	if want, _ := WorkspacePath("residency"); dir != want {
+		t.Fatalf("unpacked into %s, want %s", dir, want)
+	}
+	ws, err := LoadWorkspace(dir)
+	if err != nil || ws.Rules != RulesFile {
+		t.Fatalf("the unpacked workspace = %+v, %v", ws, err)
+	}
+	if want := []string{PackedDir + "/library.csv", PackedDir + "/2-library.csv:0.5"}; !slices.Equal(ws.Inputs, want) {
+		t.Fatalf("unpacked inputs = %v, want %v", ws.Inputs, want)
+	}
+	for _, in := range ws.Inputs {
+		path, _ := splitWeight(in)
+		if _, err := os.Stat(ws.Path(path)); err != nil {
+			t.Errorf("input %s: %v", in, err)
+		}
+	}
+	if profile, err := ws.EmbeddedProfile(); err != nil || profile["limit"][0] != "40" || ws.Profile != "club" {
+		t.Errorf("EmbeddedProfile = %v, %v; want club's settings", profile, err)
+	}
+	if sets, _ := ws.Outputs(); len(sets) != 1 {
+		t.Errorf("the unpacked workspace has %d set(s), want its 1", len(sets))
+	}
+	if names, err := ListWorkspaces(); err != nil || !slices.Equal(names, []string{"residency"}) {
+		t.Errorf("ListWorkspaces = %v, %v", names, err)
+	}
+}
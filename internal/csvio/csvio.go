@@ -10,7 +10,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"github.com/YakDriver/magicmix/internal/genre"
 	"github.com/YakDriver/magicmix/internal/track"
 )
 
@@ -20,6 +22,25 @@ type Playlist struct {
 	Header []string // the input header row; nil when the file had no recognizable header
 	CRLF   bool     // the input used \r\n line endings
 	Tracks []track.Track
+
+	// Encoding is the input's character encoding when it wasn't plain UTF-8, such as
+	// Windows1252 or UTF8BOM; the playlist is written back in it.
+	Encoding string
+
+	// Warnings are non-fatal problems found while reading: unusual tempos and cells
+	// that were ignored because they could not be parsed.
+	Warnings []string
+
+	// Extra are columns to append when writing, after the input's own or the canonical
+	// ones, such as the reason each track was placed. They are never read.
+	Extra []Column
+}
+
+// Column is an extra output column: its header and one value per track, in track
+// order.
+type Column struct {
+	Name   string
+	Values []string
 }
 
 // Load reads tracks from a CSV file on disk. It is a convenience wrapper around
@@ -51,7 +72,25 @@ func LoadPlaylist(ctx context.Context, path string) (Playlist, error) {
 	if err != nil {
 		return Playlist{}, fmt.Errorf("open input: %w", err)
 	}
+	return ParsePlaylist(ctx, data)
+}
+
+// ParsePlaylist is LoadPlaylist for CSV content already in memory.
+func ParsePlaylist(ctx context.Context, data []byte) (Playlist, error) {
+	if err := ctx.Err(); err != nil {
+		return Playlist{}, err
+	}
+	data, enc, err := decode(data, InputEncoding(ctx))
+	if err != nil {
+		return Playlist{}, fmt.Errorf("read csv: %w", err)
+	}
 	pl := Playlist{CRLF: bytes.Contains(data, []byte("\r\n"))}
+	if enc != UTF8 {
+		pl.Encoding = enc
+	}
+	if enc == Windows1252 && InputEncoding(ctx) == "" {
+		pl.Warnings = append(pl.Warnings, "the file isn't valid UTF-8, so it was read as Windows-1252; give its encoding if accented names look wrong")
+	}
 
 	reader := csv.NewReader(bytes.NewReader(data))
 	reader.TrimLeadingSpace = true
@@ -77,11 +116,12 @@ func LoadPlaylist(ctx context.Context, path string) (Playlist, error) {
 
 	if columns, ok := detectHeader(records[0]); ok {
 		pl.Header = records[0]
-		tracks, err := parseMapped(records[1:], columns)
+		tracks, warnings, err := parseMapped(records[1:], columns, genresFromContext(ctx), csvLine)
 		if err != nil {
 			return Playlist{}, err
 		}
 		pl.Tracks = tracks
+		pl.Warnings = append(pl.Warnings, warnings...)
 		return pl, nil
 	}
 
@@ -90,9 +130,38 @@ func LoadPlaylist(ctx context.Context, path string) (Playlist, error) {
 		return Playlist{}, err
 	}
 	pl.Tracks = tracks
+	for _, issue := range track.ValidateAll(tracks) {
+		if issue.Severity == track.SeverityWarning {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("track %d: %s", issue.Index+1, issue.Message))
+		}
+	}
 	return pl, nil
 }
 
+// ParseRows is ParsePlaylist for records already split into named cells, such as the
+// objects of a JSON document: header names each cell of the rows, which are read as a
+// CSV with that header row would be. The rows have no file layout to echo, so the
+// playlist keeps no header or raw cells and is written in magicmix's own schema.
+func ParseRows(ctx context.Context, header []string, rows [][]string) (Playlist, error) {
+	if err := ctx.Err(); err != nil {
+		return Playlist{}, err
+	}
+	columns, ok := detectHeader(header)
+	if !ok {
+		return Playlist{}, fmt.Errorf("need title, bpm, and key fields, got %s", strings.Join(header, ", "))
+	}
+	tracks, warnings, err := parseMapped(rows, columns, genresFromContext(ctx), func(i int) string {
+		return fmt.Sprintf("record %d", i+1)
+	})
+	if err != nil {
+		return Playlist{}, err
+	}
+	for i := range tracks {
+		tracks[i].Raw = nil
+	}
+	return Playlist{Tracks: tracks, Warnings: warnings}, nil
+}
+
 // column identifies a canonical field the reader knows how to use.
 type column int
 
@@ -108,6 +177,23 @@ const (
 	colAcousticness
 	colLength
 	colYear
+	colTags
+	colGenre
+	colLoudness
+	colEnergyInferred
+	colPhrase
+	colAdded
+	colIntro
+	colOutro
+	colLocation
+	colFingerprint
+	colPriority
+	colColor
+	colAlbum
+	colCues
+	colLocked
+	colLicense
+	colRegions
 )
 
 // columnSynonyms maps normalized header names to canonical columns.
@@ -121,8 +207,26 @@ var columnSynonyms = map[string]column{
 	"valence": colValence, "mood": colValence,
 	"pop": colPopularity, "popularity": colPopularity,
 	"acoustic": colAcousticness, "acousticness": colAcousticness,
-	"length": colLength, "duration": colLength, "len": colLength,
+	"length": colLength, "duration": colLength, "len": colLength, "duration seconds": colLength,
 	"release": colYear, "released": colYear, "year": colYear,
+	"tags": colTags, "tag": colTags, "labels": colTags,
+	"genre": colGenre, "genres": colGenre, "style": colGenre,
+	"album": colAlbum, "ep": colAlbum, "album title": colAlbum,
+	"loudness": colLoudness, "loud": colLoudness, "lufs": colLoudness,
+	"energy inferred": colEnergyInferred,
+	"phrase":          colPhrase, "phrase bars": colPhrase, "bars": colPhrase,
+	"date added": colAdded, "added": colAdded, "added on": colAdded, "date_added": colAdded,
+	"intro": colIntro, "intro length": colIntro, "mix in": colIntro, "intro seconds": colIntro,
+	"outro": colOutro, "outro length": colOutro, "mix out": colOutro, "outro seconds": colOutro,
+	"location": colLocation, "path": colLocation, "file": colLocation, "file path": colLocation,
+	"filename": colLocation,
+	"priority": colPriority, "rating": colPriority, "stars": colPriority,
+	"locked": colLocked, "lock": colLocked, "keep order": colLocked, "backbone": colLocked,
+	"cues": colCues, "cue count": colCues, "cue points": colCues, "hot cues": colCues,
+	"color": colColor, "colour": colColor, "track color": colColor, "track colour": colColor,
+	"license": colLicense, "licence": colLicense, "clearance": colLicense, "license status": colLicense,
+	"regions": colRegions, "region": colRegions, "territories": colRegions, "territory": colRegions,
+	"fingerprint": colFingerprint, "acoustid": colFingerprint, "acoustid fingerprint": colFingerprint,
 }
 
 // normalizeHeader lowercases and strips surrounding spaces and trailing dots so
@@ -132,7 +236,8 @@ func normalizeHeader(s string) string {
 }
 
 // detectHeader builds a column index from a row of header names. It only reports a
-// header when the core ordering signals (title, bpm, energy, key) are all present.
+// header when the core ordering signals (title, bpm, key) are all present; energy may
+// be missing, in which case it is inferred per track.
 func detectHeader(row []string) (map[column]int, bool) {
 	columns := make(map[column]int)
 	for i, cell := range row {
@@ -142,7 +247,7 @@ func detectHeader(row []string) (map[column]int, bool) {
 			}
 		}
 	}
-	for _, required := range []column{colTitle, colBPM, colEnergy, colKey} {
+	for _, required := range []column{colTitle, colBPM, colKey} {
 		if _, ok := columns[required]; !ok {
 			return nil, false
 		}
@@ -150,23 +255,74 @@ func detectHeader(row []string) (map[column]int, bool) {
 	return columns, true
 }
 
-func parseMapped(rows [][]string, columns map[column]int) ([]track.Track, error) {
+// csvLine names data row i of a CSV for messages: the header is line 1.
+func csvLine(i int) string {
+	return fmt.Sprintf("line %d", i+2)
+}
+
+// parseMapped reads rows through columns; where names row i in errors and warnings.
+func parseMapped(rows [][]string, columns map[column]int, genres *genre.Taxonomy, where func(int) string) ([]track.Track, []string, error) {
 	tracks := make([]track.Track, 0, len(rows))
+	var warnings []string
 	for i, record := range rows {
 		if isBlank(record) {
 			continue
 		}
-		tr, err := recordToTrack(record, columns)
+		tr, err := recordToTrack(record, columns, genres)
 		if err != nil {
-			return nil, fmt.Errorf("line %d: %w", i+2, err) // +2: header is line 1
+			return nil, nil, fmt.Errorf("%s: %w", where(i), err)
 		}
 		tr.Raw = record
 		tracks = append(tracks, tr)
+		for _, w := range rowWarnings(record, columns, tr) {
+			warnings = append(warnings, fmt.Sprintf("%s: %s", where(i), w))
+		}
 	}
-	return tracks, nil
+	return tracks, warnings, nil
 }
 
-func recordToTrack(record []string, columns map[column]int) (track.Track, error) {
+// rowWarnings reports the track's validation warnings (such as an unusual tempo) and
+// every optional cell that had a value the parser could not use (and so ignored).
+func rowWarnings(record []string, columns map[column]int, t track.Track) []string {
+	var out []string
+	for _, issue := range t.Validate() {
+		if issue.Severity == track.SeverityWarning {
+			out = append(out, issue.Message)
+		}
+	}
+	ignored := []struct {
+		col  column
+		name string
+		ok   bool
+	}{
+		{colDanceability, "danceability", t.Danceability != nil},
+		{colValence, "valence", t.Valence != nil},
+		{colPopularity, "popularity", t.Popularity != nil},
+		{colAcousticness, "acousticness", t.Acousticness != nil},
+		{colLength, "length", t.Duration != nil},
+		{colYear, "release", t.Year != nil},
+		{colPhrase, "phrase", t.Phrase != nil},
+		{colLoudness, "loudness", t.Loudness != nil},
+		{colAdded, "date added", t.Added != nil},
+		{colIntro, "intro", t.Intro != nil},
+		{colOutro, "outro", t.Outro != nil},
+		{colPriority, "priority", t.Priority != nil},
+		{colCues, "cues", t.Cues != nil},
+		{colColor, "color", t.Color != ""},
+	}
+	for _, c := range ignored {
+		j, present := columns[c.col]
+		if !present || j >= len(record) || c.ok {
+			continue
+		}
+		if v := strings.TrimSpace(record[j]); v != "" {
+			out = append(out, fmt.Sprintf("ignored invalid %s %q", c.name, v))
+		}
+	}
+	return out
+}
+
+func recordToTrack(record []string, columns map[column]int, genres *genre.Taxonomy) (track.Track, error) {
 	field := func(c column) (string, bool) {
 		j, ok := columns[c]
 		if !ok || j >= len(record) {
@@ -179,33 +335,158 @@ func recordToTrack(record []string, columns map[column]int) (track.Track, error)
 	artist, _ := field(colArtist)
 
 	bpmStr, _ := field(colBPM)
-	bpm, err := strconv.ParseFloat(bpmStr, 64)
+	bpm, bpmEnd, err := track.ParseTempo(bpmStr)
 	if err != nil {
-		return track.Track{}, fmt.Errorf("invalid bpm %q: %w", bpmStr, err)
+		return track.Track{}, err
 	}
 
 	energyStr, _ := field(colEnergy)
-	energy, err := parseScale(energyStr)
-	if err != nil {
-		return track.Track{}, fmt.Errorf("invalid energy: %w", err)
+	energy := 0
+	if energyStr != "" {
+		energy, err = parseScale(energyStr)
+		if err != nil {
+			return track.Track{}, fmt.Errorf("invalid energy: %w", err)
+		}
 	}
 
 	keyStr, _ := field(colKey)
-	key, err := track.ParseKey(keyStr)
+	key, mods, err := track.ParseKeys(keyStr)
 	if err != nil {
 		return track.Track{}, err
 	}
 
-	tr := track.Track{Title: title, Artist: artist, BPM: bpm, Energy: energy, Key: key}
+	tr := track.Track{Title: title, Artist: artist, BPM: bpm, BPMEnd: bpmEnd, Energy: energy, Key: key, Modulations: mods}
 	tr.Danceability = optionalScale(field(colDanceability))
 	tr.Valence = optionalScale(field(colValence))
 	tr.Popularity = optionalScale(field(colPopularity))
 	tr.Acousticness = optionalScale(field(colAcousticness))
 	tr.Duration = optionalDuration(field(colLength))
 	tr.Year = optionalYear(field(colYear))
+	tr.Tags = optionalTags(field(colTags))
+	tr.Phrase = optionalPositive(field(colPhrase))
+	tr.Genre, _ = field(colGenre)
+	tr.Album, _ = field(colAlbum)
+	tr.Loudness = optionalFloat(field(colLoudness))
+	tr.Added = optionalDate(field(colAdded))
+	tr.Intro = optionalDuration(field(colIntro))
+	tr.Outro = optionalDuration(field(colOutro))
+	tr.Location, _ = field(colLocation)
+	tr.Fingerprint, _ = field(colFingerprint)
+	tr.License, _ = field(colLicense)
+	if s, ok := field(colRegions); ok {
+		tr.Regions = track.ParseRegions(s)
+	}
+	tr.Priority = optionalPriority(field(colPriority))
+	tr.Cues = optionalCount(field(colCues))
+	if mark, ok := field(colLocked); ok {
+		tr.Locked = isYes(mark)
+	}
+	if s, ok := field(colColor); ok {
+		tr.Color, _ = track.ParseColor(s) // an unknown color is warned about and ignored
+	}
+	if energyStr == "" {
+		if genres != nil {
+			tr.Energy = track.InferEnergyWith(tr, genres)
+		} else {
+			tr.Energy = track.InferEnergy(tr)
+		}
+		tr.EnergyInferred = true
+	} else if mark, ok := field(colEnergyInferred); ok {
+		tr.EnergyInferred = isYes(mark)
+	}
 	return tr, nil
 }
 
+// optionalPositive parses an optional positive integer, returning nil when absent or
+// unparseable.
+func optionalPositive(s string, present bool) *int {
+	if !present || s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// optionalCount parses an optional count, zero or more, returning nil when absent or
+// unparseable.
+func optionalCount(s string, present bool) *int {
+	if !present || s == "" {
+		return nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < 0 {
+		return nil
+	}
+	return &v
+}
+
+// optionalPriority parses an optional 1-5 priority, returning nil when absent or out
+// of range.
+func optionalPriority(s string, present bool) *int {
+	v := optionalPositive(s, present)
+	if v == nil || *v > 5 {
+		return nil
+	}
+	return v
+}
+
+// optionalFloat parses an optional decimal signal, returning nil when absent or
+// unparseable.
+func optionalFloat(s string, present bool) *float64 {
+	if !present || s == "" {
+		return nil
+	}
+	v, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return nil
+	}
+	return &v
+}
+
+// dateLayouts are the date formats accepted for "date added", most specific first.
+var dateLayouts = []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02", "2006/01/02"}
+
+// optionalDate parses an optional date such as "2024-05-01" or an RFC 3339 timestamp,
+// returning nil when absent or unparseable.
+func optionalDate(s string, present bool) *time.Time {
+	if !present || s == "" {
+		return nil
+	}
+	for _, layout := range dateLayouts {
+		if d, err := time.Parse(layout, s); err == nil {
+			return &d
+		}
+	}
+	return nil
+}
+
+// isYes reports whether a flag cell reads as true ("yes", "y", "true", "1", "x").
+func isYes(s string) bool {
+	switch strings.ToLower(s) {
+	case "yes", "y", "true", "1", "x":
+		return true
+	}
+	return false
+}
+
+// optionalTags splits a tags cell on commas, semicolons, or pipes into lowercase
+// labels, returning nil when absent or empty.
+func optionalTags(s string, present bool) []string {
+	if !present {
+		return nil
+	}
+	var tags []string
+	for _, part := range strings.FieldsFunc(s, func(r rune) bool { return r == ',' || r == ';' || r == '|' }) {
+		if tag := strings.ToLower(strings.TrimSpace(part)); tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
 // optionalYear extracts a 4-digit release year from values like "2024-05-01" or
 // "2024", returning nil when absent or unparseable.
 func optionalYear(s string, present bool) *int {
@@ -323,8 +604,36 @@ func Save(_ context.Context, path string, tracks []track.Track) error {
 // extra columns, and line endings — with only the rows reordered. Otherwise it falls
 // back to the canonical schema.
 func SaveInFormat(_ context.Context, path string, pl Playlist) error {
+	return createFile(path, func(w io.Writer) error {
+		return WriteInFormat(w, pl)
+	})
+}
+
+// WriteInFormat is SaveInFormat for an io.Writer, such as standard output. pl.Extra
+// columns follow the others. The file is in pl.Encoding, unless some character can't
+// be written in it; then it is UTF-8 with a byte-order mark, which spreadsheets read
+// correctly too.
+func WriteInFormat(w io.Writer, pl Playlist) error {
+	if pl.Encoding != "" {
+		var buf bytes.Buffer
+		plain := pl
+		plain.Encoding = ""
+		if err := WriteInFormat(&buf, plain); err != nil {
+			return err
+		}
+		out, ok := encode(buf.Bytes(), pl.Encoding)
+		if !ok {
+			out, _ = encode(buf.Bytes(), UTF8BOM)
+		}
+		_, err := w.Write(out)
+		return err
+	}
 	passthrough := len(pl.Tracks) > 0 && allHaveRaw(pl.Tracks)
-	return writeCSV(path, pl.CRLF && passthrough, func(w *csv.Writer) error {
+	return writeCSVTo(w, pl.CRLF && passthrough, func(cw *csv.Writer) error {
+		w := &columnWriter{w: cw, extra: pl.Extra, row: -1}
+		if passthrough && pl.Header == nil {
+			w.row = 0 // no header row to name the columns in
+		}
 		if !passthrough {
 			return writeCanonical(w, pl.Tracks)
 		}
@@ -342,6 +651,38 @@ func SaveInFormat(_ context.Context, path string, pl Playlist) error {
 	})
 }
 
+// rowWriter is where rows go: a csv.Writer, or a columnWriter in front of one.
+type rowWriter interface {
+	Write(record []string) error
+}
+
+// columnWriter appends extra columns to the rows it passes on: their names to the
+// header row, then each column's next value to each track's row.
+type columnWriter struct {
+	w     *csv.Writer
+	extra []Column
+	row   int // the track whose row is next; -1 while the header row is
+}
+
+func (c *columnWriter) Write(record []string) error {
+	if len(c.extra) == 0 {
+		return c.w.Write(record)
+	}
+	out := append([]string(nil), record...)
+	for _, col := range c.extra {
+		switch {
+		case c.row < 0:
+			out = append(out, col.Name)
+		case c.row < len(col.Values):
+			out = append(out, col.Values[c.row])
+		default:
+			out = append(out, "")
+		}
+	}
+	c.row++
+	return c.w.Write(out)
+}
+
 func allHaveRaw(tracks []track.Track) bool {
 	for _, t := range tracks {
 		if t.Raw == nil {
@@ -352,7 +693,14 @@ func allHaveRaw(tracks []track.Track) bool {
 }
 
 // writeCSV opens path (creating parent dirs), hands a writer to write, then flushes.
-func writeCSV(path string, useCRLF bool, write func(*csv.Writer) error) (err error) {
+func writeCSV(path string, useCRLF bool, write func(*csv.Writer) error) error {
+	return createFile(path, func(w io.Writer) error {
+		return writeCSVTo(w, useCRLF, write)
+	})
+}
+
+// createFile creates path (and its directory), hands it to write, and closes it.
+func createFile(path string, write func(io.Writer) error) (err error) {
 	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 		return fmt.Errorf("create output directory: %w", err)
 	}
@@ -366,8 +714,12 @@ func writeCSV(path string, useCRLF bool, write func(*csv.Writer) error) (err err
 			err = fmt.Errorf("close output: %w", cerr)
 		}
 	}()
+	return write(file)
+}
 
-	writer := csv.NewWriter(file)
+// writeCSVTo hands a CSV writer on w to write, then flushes.
+func writeCSVTo(w io.Writer, useCRLF bool, write func(*csv.Writer) error) error {
+	writer := csv.NewWriter(w)
 	writer.UseCRLF = useCRLF
 	if werr := write(writer); werr != nil {
 		return werr
@@ -378,7 +730,7 @@ func writeCSV(path string, useCRLF bool, write func(*csv.Writer) error) (err err
 
 // writeCanonical writes tracks in magicmix's own schema: the core five columns plus
 // whichever optional signals any track carries.
-func writeCanonical(writer *csv.Writer, tracks []track.Track) error {
+func writeCanonical(writer rowWriter, tracks []track.Track) error {
 	// Preserve optional signals only when at least one track carries them, so
 	// legacy 5-column files round-trip unchanged while rich files keep their data.
 	var hasDance, hasValence, hasPop, hasAcoustic bool
@@ -402,6 +754,35 @@ func writeCanonical(writer *csv.Writer, tracks []track.Track) error {
 			break
 		}
 	}
+	var hasTags bool
+	for _, t := range tracks {
+		if len(t.Tags) > 0 {
+			hasTags = true
+			break
+		}
+	}
+
+	var hasPhrase, hasGenre, hasAlbum, hasLoudness, hasInferred, hasAdded bool
+	var hasIntro, hasOutro, hasLocation, hasFingerprint, hasPriority, hasCues, hasLocked, hasColor bool
+	var hasLicense, hasRegions bool
+	for _, t := range tracks {
+		hasAdded = hasAdded || t.Added != nil
+		hasIntro = hasIntro || t.Intro != nil
+		hasOutro = hasOutro || t.Outro != nil
+		hasLocation = hasLocation || t.Location != ""
+		hasFingerprint = hasFingerprint || t.Fingerprint != ""
+		hasPriority = hasPriority || t.Priority != nil
+		hasCues = hasCues || t.Cues != nil
+		hasLocked = hasLocked || t.Locked
+		hasColor = hasColor || t.Color != ""
+		hasLicense = hasLicense || t.License != ""
+		hasRegions = hasRegions || len(t.Regions) > 0
+		hasPhrase = hasPhrase || t.Phrase != nil
+		hasGenre = hasGenre || t.Genre != ""
+		hasAlbum = hasAlbum || t.Album != ""
+		hasLoudness = hasLoudness || t.Loudness != nil
+		hasInferred = hasInferred || t.EnergyInferred
+	}
 
 	header := []string{"Title", "Artist", "BPM", "Energy", "Key"}
 	if hasDance {
@@ -422,6 +803,57 @@ func writeCanonical(writer *csv.Writer, tracks []track.Track) error {
 	if hasYear {
 		header = append(header, "Release")
 	}
+	if hasTags {
+		header = append(header, "Tags")
+	}
+	if hasPhrase {
+		header = append(header, "Phrase")
+	}
+	if hasGenre {
+		header = append(header, "Genre")
+	}
+	if hasAlbum {
+		header = append(header, "Album")
+	}
+	if hasLoudness {
+		header = append(header, "Loudness")
+	}
+	if hasInferred {
+		header = append(header, "Energy Inferred")
+	}
+	if hasAdded {
+		header = append(header, "Date Added")
+	}
+	if hasIntro {
+		header = append(header, "Intro")
+	}
+	if hasOutro {
+		header = append(header, "Outro")
+	}
+	if hasLocation {
+		header = append(header, "Location")
+	}
+	if hasPriority {
+		header = append(header, "Priority")
+	}
+	if hasCues {
+		header = append(header, "Cues")
+	}
+	if hasLocked {
+		header = append(header, "Locked")
+	}
+	if hasColor {
+		header = append(header, "Color")
+	}
+	if hasLicense {
+		header = append(header, "License")
+	}
+	if hasRegions {
+		header = append(header, "Regions")
+	}
+	if hasFingerprint {
+		header = append(header, "Fingerprint")
+	}
 	if err := writer.Write(header); err != nil {
 		return fmt.Errorf("write header: %w", err)
 	}
@@ -430,9 +862,9 @@ func writeCanonical(writer *csv.Writer, tracks []track.Track) error {
 		row := []string{
 			t.Title,
 			t.Artist,
-			strconv.FormatFloat(t.BPM, 'f', -1, 64),
+			t.TempoString(),
 			strconv.Itoa(t.Energy),
-			t.Key.String(),
+			t.KeyString(),
 		}
 		if hasDance {
 			row = append(row, optIntString(t.Danceability))
@@ -452,6 +884,73 @@ func writeCanonical(writer *csv.Writer, tracks []track.Track) error {
 		if hasYear {
 			row = append(row, optIntString(t.Year))
 		}
+		if hasTags {
+			row = append(row, strings.Join(t.Tags, "; "))
+		}
+		if hasPhrase {
+			row = append(row, optIntString(t.Phrase))
+		}
+		if hasGenre {
+			row = append(row, t.Genre)
+		}
+		if hasAlbum {
+			row = append(row, t.Album)
+		}
+		if hasLoudness {
+			loud := ""
+			if t.Loudness != nil {
+				loud = strconv.FormatFloat(*t.Loudness, 'f', -1, 64)
+			}
+			row = append(row, loud)
+		}
+		if hasInferred {
+			mark := ""
+			if t.EnergyInferred {
+				mark = "yes"
+			}
+			row = append(row, mark)
+		}
+		if hasAdded {
+			added := ""
+			if t.Added != nil {
+				added = t.Added.Format("2006-01-02")
+			}
+			row = append(row, added)
+		}
+		if hasIntro {
+			row = append(row, formatDuration(t.Intro))
+		}
+		if hasOutro {
+			row = append(row, formatDuration(t.Outro))
+		}
+		if hasLocation {
+			row = append(row, t.Location)
+		}
+		if hasPriority {
+			row = append(row, optIntString(t.Priority))
+		}
+		if hasCues {
+			row = append(row, optIntString(t.Cues))
+		}
+		if hasLocked {
+			mark := ""
+			if t.Locked {
+				mark = "yes"
+			}
+			row = append(row, mark)
+		}
+		if hasColor {
+			row = append(row, t.Color)
+		}
+		if hasLicense {
+			row = append(row, t.License)
+		}
+		if hasRegions {
+			row = append(row, strings.Join(t.Regions, ";"))
+		}
+		if hasFingerprint {
+			row = append(row, t.Fingerprint)
+		}
 		if err := writer.Write(row); err != nil {
 			return fmt.Errorf("write row: %w", err)
 		}
@@ -484,13 +983,13 @@ func looksLikeData(record []string) bool {
 	if len(record) < 5 {
 		return false
 	}
-	if _, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64); err != nil {
+	if _, _, err := track.ParseTempo(record[2]); err != nil {
 		return false
 	}
 	if _, err := strconv.Atoi(strings.TrimSpace(record[3])); err != nil {
 		return false
 	}
-	if _, err := track.ParseKey(record[4]); err != nil {
+	if _, _, err := track.ParseKeys(record[4]); err != nil {
 		return false
 	}
 	return true
@@ -500,9 +999,9 @@ func parseRecord(record []string) (track.Track, error) {
 	title := strings.TrimSpace(record[0])
 	artist := strings.TrimSpace(record[1])
 
-	bpm, err := strconv.ParseFloat(strings.TrimSpace(record[2]), 64)
+	bpm, bpmEnd, err := track.ParseTempo(record[2])
 	if err != nil {
-		return track.Track{}, fmt.Errorf("invalid bpm: %w", err)
+		return track.Track{}, err
 	}
 
 	energy, err := strconv.Atoi(strings.TrimSpace(record[3]))
@@ -513,16 +1012,18 @@ func parseRecord(record []string) (track.Track, error) {
 		return track.Track{}, fmt.Errorf("energy out of range: %d", energy)
 	}
 
-	key, err := track.ParseKey(record[4])
+	key, mods, err := track.ParseKeys(record[4])
 	if err != nil {
 		return track.Track{}, err
 	}
 
 	return track.Track{
-		Title:  title,
-		Artist: artist,
-		BPM:    bpm,
-		Energy: energy,
-		Key:    key,
+		Title:       title,
+		Artist:      artist,
+		BPM:         bpm,
+		BPMEnd:      bpmEnd,
+		Energy:      energy,
+		Key:         key,
+		Modulations: mods,
 	}, nil
 }
@@ -4,9 +4,12 @@ import (
 	"context"
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+	"time"
 
 	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/genre"
 	"github.com/YakDriver/magicmix/internal/track"
 )
 
@@ -74,6 +77,139 @@ func TestLoadExtendedColumns(t *testing.T) {
 	assertSignal(t, "year", got.Year, 2026)        // RELEASE 2026-05-29
 }
 
+func TestLoadReadsAlbum(t *testing.T) {
+	path := writeTempFile(t, "Title,Artist,BPM,Energy,Key,EP\nOne,A,124,60,8A,Summer EP\nTwo,B,124,60,8A,\n")
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if tracks[0].Album != "Summer EP" || tracks[1].Album != "" {
+		t.Fatalf("albums = %q, %q; want Summer EP and none", tracks[0].Album, tracks[1].Album)
+	}
+	out := filepath.Join(t.TempDir(), "out.csv")
+	if err := csvio.Save(context.Background(), out, tracks); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if again, err := csvio.Load(context.Background(), out); err != nil || again[0].Album != "Summer EP" {
+		t.Fatalf("album lost in the canonical schema: %v %+v", err, again)
+	}
+}
+
+func TestLoadReadsCues(t *testing.T) {
+	path := writeTempFile(t, "Title,Artist,BPM,Energy,Key,Hot Cues\nOne,A,124,60,8A,5\nTwo,B,124,60,8A,0\nThree,C,124,60,8A,\n")
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if tracks[0].Cues == nil || *tracks[0].Cues != 5 || tracks[1].Cues == nil || *tracks[1].Cues != 0 || tracks[2].Cues != nil {
+		t.Fatalf("cues = %v, %v, %v; want 5, 0, and unknown", tracks[0].Cues, tracks[1].Cues, tracks[2].Cues)
+	}
+	out := filepath.Join(t.TempDir(), "out.csv")
+	if err := csvio.Save(context.Background(), out, tracks); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if again, err := csvio.Load(context.Background(), out); err != nil || again[1].Cues == nil || *again[1].Cues != 0 {
+		t.Fatalf("cues lost in the canonical schema: %v %+v", err, again)
+	}
+}
+
+func TestLoadReadsLocked(t *testing.T) {
+	path := writeTempFile(t, "Title,Artist,BPM,Energy,Key,Keep Order\nOne,A,124,60,8A,yes\nTwo,B,124,60,8A,\n")
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if !tracks[0].Locked || tracks[1].Locked {
+		t.Fatalf("locked = %v, %v; want the first only", tracks[0].Locked, tracks[1].Locked)
+	}
+	out := filepath.Join(t.TempDir(), "out.csv")
+	if err := csvio.Save(context.Background(), out, tracks); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if again, err := csvio.Load(context.Background(), out); err != nil || !again[0].Locked {
+		t.Fatalf("lock lost in the canonical schema: %v %+v", err, again)
+	}
+}
+
+func TestLoadReadsLicenseAndRegions(t *testing.T) {
+	path := writeTempFile(t, "Title,Artist,BPM,Energy,Key,Clearance,Territories\nOne,A,124,60,8A,Cleared,us; gb\nTwo,B,124,60,8A,,\n")
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if tracks[0].License != "Cleared" || !slices.Equal(tracks[0].Regions, []string{"US", "GB"}) {
+		t.Fatalf("license %q, regions %v; want Cleared in US and GB", tracks[0].License, tracks[0].Regions)
+	}
+	if tracks[1].License != "" || tracks[1].Regions != nil {
+		t.Fatalf("an empty license and regions read as %q, %v", tracks[1].License, tracks[1].Regions)
+	}
+	out := filepath.Join(t.TempDir(), "out.csv")
+	if err := csvio.Save(context.Background(), out, tracks); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	if again, err := csvio.Load(context.Background(), out); err != nil || !slices.Equal(again[0].Regions, tracks[0].Regions) {
+		t.Fatalf("regions lost in the canonical schema: %v %+v", err, again)
+	}
+}
+
+func TestLoadInfersMissingEnergy(t *testing.T) {
+	data := "Title,Artist,BPM,Key,Genre,Loud\n" +
+		"Slow,A,80,8A,Ambient,-14\n" +
+		"Fast,B,128,9A,Techno,-6\n" +
+		"Known,C,120,9A,House,\n"
+
+	path := writeTempFile(t, data)
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	slow, fast := tracks[0], tracks[1]
+	if !slow.EnergyInferred || !fast.EnergyInferred {
+		t.Fatalf("energy not marked inferred: %+v %+v", slow, fast)
+	}
+	if slow.Energy >= fast.Energy {
+		t.Fatalf("inferred energy slow %d >= fast %d", slow.Energy, fast.Energy)
+	}
+	if slow.Genre != "Ambient" || slow.Loudness == nil || *slow.Loudness != -14 {
+		t.Fatalf("genre/loudness not captured: %+v", slow)
+	}
+
+	// The canonical schema carries the mark so a saved file still says which values
+	// were estimated.
+	out := filepath.Join(t.TempDir(), "out.csv")
+	if err := csvio.Save(context.Background(), out, tracks); err != nil {
+		t.Fatalf("Save returned error: %v", err)
+	}
+	again, err := csvio.Load(context.Background(), out)
+	if err != nil {
+		t.Fatalf("reload returned error: %v", err)
+	}
+	if !again[0].EnergyInferred || again[0].Energy != slow.Energy {
+		t.Fatalf("inferred mark lost on round trip: %+v", again[0])
+	}
+}
+
+func TestLoadInfersEnergyWithGenres(t *testing.T) {
+	path := writeTempFile(t, "Title,Artist,BPM,Key,Genre\nPad,A,100,8A,Drone\nRef,B,100,8A,Ambient\n")
+	tracks, err := csvio.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if tracks[0].Energy == tracks[1].Energy {
+		t.Fatalf("unknown genre inferred like Ambient without an alias: %d", tracks[0].Energy)
+	}
+
+	genres := genre.Default()
+	genres.AddAlias("drone", "Ambient")
+	tracks, err = csvio.Load(csvio.WithGenres(context.Background(), genres), path)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if tracks[0].Energy != tracks[1].Energy {
+		t.Fatalf("aliased Drone inferred %d, Ambient %d; want the same", tracks[0].Energy, tracks[1].Energy)
+	}
+}
+
 func TestLoadOptionalSignalsAbsent(t *testing.T) {
 	// Only the core columns are present; extended signals must be nil.
 	data := "Title,Artist,BPM,Energy,Key\n" +
@@ -142,9 +278,10 @@ func TestSave(t *testing.T) {
 
 func TestSaveRoundTripsExtendedSignals(t *testing.T) {
 	d, v := 63, 45
-	yr := 2024
+	yr, fav := 2024, 5
+	added := time.Date(2026, 9, 30, 0, 0, 0, 0, time.UTC)
 	tracks := []track.Track{
-		{Title: "A", Artist: "X", BPM: 120, Energy: 50, Key: track.Key{Number: 1, Mode: track.ModeA}, Danceability: &d, Valence: &v, Year: &yr},
+		{Title: "A", Artist: "X", BPM: 120, Energy: 50, Key: track.Key{Number: 1, Mode: track.ModeA}, Danceability: &d, Valence: &v, Year: &yr, Added: &added, Priority: &fav},
 		{Title: "B", Artist: "Y", BPM: 121, Energy: 60, Key: track.Key{Number: 2, Mode: track.ModeB}},
 	}
 	dir := t.TempDir()
@@ -168,6 +305,12 @@ func TestSaveRoundTripsExtendedSignals(t *testing.T) {
 	if reloaded[0].Year == nil || *reloaded[0].Year != 2024 {
 		t.Fatalf("year not preserved: %+v", reloaded[0])
 	}
+	if reloaded[0].Added == nil || !reloaded[0].Added.Equal(added) || reloaded[1].Added != nil {
+		t.Fatalf("date added not preserved: %v, %v", reloaded[0].Added, reloaded[1].Added)
+	}
+	if reloaded[0].Priority == nil || *reloaded[0].Priority != 5 || reloaded[1].Priority != nil {
+		t.Fatalf("priority not preserved: %v, %v", reloaded[0].Priority, reloaded[1].Priority)
+	}
 	// Second track had no danceability; it must round-trip as absent.
 	if reloaded[1].Danceability != nil {
 		t.Fatalf("expected absent danceability to stay nil, got %v", *reloaded[1].Danceability)
@@ -208,3 +351,27 @@ func writeTempFile(t *testing.T, data string) string {
 	}
 	return file.Name()
 }
+
+func TestLoadPlaylistCollectsWarnings(t *testing.T) {
+	data := "Title,Artist,BPM,Energy,Key,Valence,Length,Priority\n" +
+		"Fine,A,120,50,8A,40,3:30,4\n" +
+		"Typo,B,1200,60,9A,lots,3:10,9\n"
+
+	pl, err := csvio.LoadPlaylist(context.Background(), writeTempFile(t, data))
+	if err != nil {
+		t.Fatalf("LoadPlaylist returned error: %v", err)
+	}
+	want := []string{
+		`line 3: unusual BPM 1200 for "Typo"`,
+		`line 3: ignored invalid valence "lots"`,
+		`line 3: ignored invalid priority "9"`,
+	}
+	if len(pl.Warnings) != len(want) {
+		t.Fatalf("got warnings %q, want %q", pl.Warnings, want)
+	}
+	for i := range want {
+		if pl.Warnings[i] != want[i] {
+			t.Errorf("warning %d = %q, want %q", i, pl.Warnings[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package csvio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"unicode/utf8"
+)
+
+// Character encodings a CSV can come in. Tracks always hold UTF-8; a playlist
+// remembers its file's encoding so it is written back in it.
+const (
+	UTF8        = "utf-8"
+	UTF8BOM     = "utf-8-bom" // UTF-8 led by a byte-order mark, as Excel saves it
+	Windows1252 = "windows-1252"
+	Latin1      = "latin-1"
+)
+
+// bom is the UTF-8 byte-order mark.
+var bom = []byte("\ufeff")
+
+// windows1252 maps the bytes 0x80-0x9F, where Windows-1252 departs from Latin-1, to
+// the characters they stand for. The five bytes it leaves undefined read as Latin-1.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8D, 'Ž', 0x8F,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9D, 'ž', 'Ÿ',
+}
+
+// ParseEncoding reads an encoding name: utf-8, windows-1252 (or cp1252), or latin-1
+// (or iso-8859-1). "" and "auto" return "", which detects it.
+func ParseEncoding(s string) (string, error) {
+	switch strings.NewReplacer("-", "", "_", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(s))) {
+	case "", "auto":
+		return "", nil
+	case "utf8":
+		return UTF8, nil
+	case "windows1252", "cp1252", "win1252":
+		return Windows1252, nil
+	case "latin1", "iso88591", "l1":
+		return Latin1, nil
+	}
+	return "", fmt.Errorf("unknown encoding %q (want auto, %s, %s, or %s)", s, UTF8, Windows1252, Latin1)
+}
+
+type contextKey string
+
+const encodingContextKey contextKey = "csvio.encoding"
+
+// WithEncoding reads CSVs as enc (see ParseEncoding) instead of detecting it; "" goes
+// back to detecting.
+func WithEncoding(ctx context.Context, enc string) context.Context {
+	return context.WithValue(ctx, encodingContextKey, enc)
+}
+
+// InputEncoding is the encoding WithEncoding set, or "" to detect it.
+func InputEncoding(ctx context.Context) string {
+	enc, _ := ctx.Value(encodingContextKey).(string)
+	return enc
+}
+
+// decode converts data in enc ("" to detect) to UTF-8, and names the encoding it was
+// in. Detection takes valid UTF-8, with or without a byte-order mark, as UTF-8, and
+// anything else as Windows-1252, the usual encoding of a CSV saved on Windows; a
+// byte-order mark always means UTF-8. Declared UTF-8 that isn't valid is an error.
+func decode(data []byte, enc string) ([]byte, string, error) {
+	if rest, ok := bytes.CutPrefix(data, bom); ok {
+		data, enc = rest, UTF8BOM
+	}
+	if enc == "" {
+		enc = UTF8
+		if !utf8.Valid(data) {
+			enc = Windows1252
+		}
+	}
+	switch enc {
+	case UTF8, UTF8BOM:
+		if !utf8.Valid(data) {
+			line := 1 + bytes.Count(data[:invalidAt(data)], []byte("\n"))
+			return nil, "", fmt.Errorf("line %d is not valid UTF-8; give its encoding, such as %s", line, Windows1252)
+		}
+		return data, enc, nil
+	}
+	var b strings.Builder
+	b.Grow(len(data) + len(data)/8)
+	for _, c := range data {
+		if enc == Windows1252 && c >= 0x80 && c < 0xA0 {
+			b.WriteRune(windows1252[c-0x80])
+		} else {
+			b.WriteRune(rune(c))
+		}
+	}
+	return []byte(b.String()), enc, nil
+}
+
+// invalidAt is the offset of the first byte of data that isn't valid UTF-8.
+func invalidAt(data []byte) int {
+	for i := 0; i < len(data); {
+		r, size := utf8.DecodeRune(data[i:])
+		if r == utf8.RuneError && size == 1 {
+			return i
+		}
+		i += size
+	}
+	return len(data)
+}
+
+// encode converts UTF-8 text to enc. ok is false when text has a character enc can't
+// hold; the caller then writes UTF-8 rather than lose it.
+func encode(text []byte, enc string) (out []byte, ok bool) {
+	switch enc {
+	case "", UTF8:
+		return text, true
+	case UTF8BOM:
+		return append(bytes.Clone(bom), text...), true
+	}
+	out = make([]byte, 0, len(text))
+	for _, r := range string(text) {
+		b, ok := encodeRune(r, enc)
+		if !ok {
+			return nil, false
+		}
+		out = append(out, b)
+	}
+	return out, true
+}
+
+func encodeRune(r rune, enc string) (byte, bool) {
+	if enc == Windows1252 {
+		if r >= 0x80 && r < 0xA0 && windows1252[r-0x80] != r {
+			return 0, false // a byte Windows-1252 spends on another character
+		}
+		for i, c := range windows1252 {
+			if c == r {
+				return byte(0x80 + i), true
+			}
+		}
+	}
+	if r > 0xFF {
+		return 0, false
+	}
+	return byte(r), true
+}
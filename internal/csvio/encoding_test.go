@@ -0,0 +1,85 @@
+package csvio_test
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+)
+
+// "Beyoncé – Déjà Vu" in Windows-1252: é is 0xE9, à 0xE0, and the en dash 0x96, a
+// byte Latin-1 spends on a control character.
+var cp1252Input = []byte("Title,Artist,BPM,Energy,Key\r\nD\xe9j\xe0 Vu,Beyonc\xe9 \x96 Jay-Z,106,80,4A\r\nHello,Adele,79,45,5A\r\n")
+
+func TestParsePlaylistDetectsWindows1252(t *testing.T) {
+	pl, err := csvio.ParsePlaylist(context.Background(), cp1252Input)
+	if err != nil {
+		t.Fatalf("ParsePlaylist: %v", err)
+	}
+	if got := pl.Tracks[0].Title + " by " + pl.Tracks[0].Artist; got != "Déjà Vu by Beyoncé – Jay-Z" {
+		t.Errorf("first track = %q", got)
+	}
+	if pl.Encoding != csvio.Windows1252 || len(pl.Warnings) != 1 {
+		t.Errorf("encoding %q, warnings %q; want Windows-1252 detected and said", pl.Encoding, pl.Warnings)
+	}
+
+	// Reordered and written back, the file keeps its encoding byte for byte.
+	pl.Tracks[0], pl.Tracks[1] = pl.Tracks[1], pl.Tracks[0]
+	var out bytes.Buffer
+	if err := csvio.WriteInFormat(&out, pl); err != nil {
+		t.Fatalf("WriteInFormat: %v", err)
+	}
+	if want := "D\xe9j\xe0 Vu,Beyonc\xe9 \x96 Jay-Z,106,80,4A\r\n"; !strings.HasSuffix(out.String(), want) {
+		t.Errorf("written %q; want the row in Windows-1252", out.String())
+	}
+
+	// A character Windows-1252 can't hold sends the whole file out as UTF-8.
+	pl.Extra = []csvio.Column{{Name: "Note", Values: []string{"", "→ peak"}}}
+	out.Reset()
+	if err := csvio.WriteInFormat(&out, pl); err != nil {
+		t.Fatalf("WriteInFormat: %v", err)
+	}
+	if got := out.String(); !strings.HasPrefix(got, "\ufeff") || !strings.Contains(got, "Déjà Vu,Beyoncé – Jay-Z,106,80,4A,→ peak") {
+		t.Errorf("written %q; want UTF-8 with a byte-order mark", got)
+	}
+}
+
+func TestParsePlaylistWithEncoding(t *testing.T) {
+	ctx := csvio.WithEncoding(context.Background(), csvio.Latin1)
+	pl, err := csvio.ParsePlaylist(ctx, cp1252Input)
+	if err != nil {
+		t.Fatalf("ParsePlaylist: %v", err)
+	}
+	if got := pl.Tracks[0].Artist; got != "Beyoncé \u0096 Jay-Z" || len(pl.Warnings) != 0 {
+		t.Errorf("artist %q, warnings %q; want 0x96 read as Latin-1 and no warning", got, pl.Warnings)
+	}
+
+	ctx = csvio.WithEncoding(context.Background(), csvio.UTF8)
+	if _, err := csvio.ParsePlaylist(ctx, cp1252Input); err == nil || !strings.Contains(err.Error(), "line 2 is not valid UTF-8") {
+		t.Errorf("declared UTF-8 over Windows-1252 bytes: error %v", err)
+	}
+
+	bom := []byte("\ufeffTitle,Artist,BPM,Energy,Key\nDéjà Vu,Beyoncé,106,80,4A\n")
+	pl, err = csvio.ParsePlaylist(context.Background(), bom)
+	if err != nil || len(pl.Tracks) != 1 || pl.Encoding != csvio.UTF8BOM {
+		t.Fatalf("ParsePlaylist with a byte-order mark = %+v, %v", pl, err)
+	}
+	var out bytes.Buffer
+	if err := csvio.WriteInFormat(&out, pl); err != nil || !bytes.Equal(out.Bytes(), bom) {
+		t.Errorf("written %q, %v; want the input back, byte-order mark and all", out.String(), err)
+	}
+}
+
+func TestParseEncoding(t *testing.T) {
+	for in, want := range map[string]string{"": "", "auto": "", "UTF8": csvio.UTF8, "cp1252": csvio.Windows1252,
+		"Windows-1252": csvio.Windows1252, "ISO-8859-1": csvio.Latin1, "latin1": csvio.Latin1} {
+		if got, err := csvio.ParseEncoding(in); err != nil || got != want {
+			t.Errorf("ParseEncoding(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := csvio.ParseEncoding("ebcdic"); err == nil {
+		t.Error("ParseEncoding(ebcdic): want an error")
+	}
+}
@@ -0,0 +1,21 @@
+package csvio
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+)
+
+const genresContextKey contextKey = "csvio.genres"
+
+// WithGenres resolves genres through genres, such as the built-in taxonomy extended
+// with the user's aliases, when estimating the energy of tracks that have none (see
+// track.InferEnergyWith). Without it the built-in taxonomy is used.
+func WithGenres(ctx context.Context, genres *genre.Taxonomy) context.Context {
+	return context.WithValue(ctx, genresContextKey, genres)
+}
+
+func genresFromContext(ctx context.Context) *genre.Taxonomy {
+	genres, _ := ctx.Value(genresContextKey).(*genre.Taxonomy)
+	return genres
+}
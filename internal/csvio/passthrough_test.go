@@ -108,3 +108,33 @@ func writeCanonicalFixture(t *testing.T) string {
 	}
 	return path
 }
+
+func TestWriteInFormatAppendsExtraColumns(t *testing.T) {
+	pl, err := csvio.ParsePlaylist(context.Background(), []byte(passthroughInput))
+	if err != nil {
+		t.Fatal(err)
+	}
+	pl.Extra = []csvio.Column{{Name: "Why", Values: []string{"opener", "step+1"}}}
+
+	var passthrough strings.Builder
+	if err := csvio.WriteInFormat(&passthrough, pl); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimRight(passthrough.String(), "\r\n"), "\r\n")
+	if !strings.HasSuffix(lines[0], ",RND,Why") || !strings.HasSuffix(lines[1], ",opener") ||
+		!strings.HasSuffix(lines[2], ",step+1") || !strings.HasSuffix(lines[3], ",1018,") {
+		t.Fatalf("extra column not appended:\n%s", passthrough.String())
+	}
+
+	for i := range pl.Tracks {
+		pl.Tracks[i].Raw = nil
+	}
+	var canonical strings.Builder
+	if err := csvio.WriteInFormat(&canonical, pl); err != nil {
+		t.Fatal(err)
+	}
+	lines = strings.Split(strings.TrimSpace(canonical.String()), "\n")
+	if !strings.HasSuffix(lines[0], ",Why") || !strings.HasSuffix(lines[1], ",opener") {
+		t.Fatalf("extra column not appended to the canonical schema:\n%s", canonical.String())
+	}
+}
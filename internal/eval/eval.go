@@ -0,0 +1,183 @@
+// Package eval scores an already-ordered set with a simple DJ rubric: penalties for
+// key moves, BPM jumps, and energy spikes, with a reward for strong resets after a
+// climb. It is independent of the scoring model strategies optimize
+// (strategy.ScoreMix), which makes it a fair yardstick for comparing magicmix output
+// with hand-made sets; only what a key move is (strategy.NewTransition) is shared, so
+// both agree on a step, a wrap, and a mode change.
+package eval
+
+import (
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Rubric tuning. Tempo moves within bpmSlack and energy rises within energyRiseSlack
+// are free; an energy drop deeper than resetDrop is a reset and earns up to
+// maxResetReward. After staleAfter transitions without a reset or wheel wrap, every
+// non-falling step costs stalePenalty.
+const (
+	bpmSlack        = 3.0
+	bpmRate         = 0.4
+	energyRiseSlack = 14.0
+	energyRiseRate  = 0.3
+	resetDrop       = 12.0
+	resetRate       = 0.25
+	maxResetReward  = 4.0
+	staleAfter      = 12
+	stalePenalty    = 0.5
+
+	keyWeight    = 0.6
+	bpmWeight    = 0.2
+	energyWeight = 0.2
+)
+
+// Transition is the rubric's verdict on one step of a set, from track Index to Index+1.
+type Transition struct {
+	Index    int
+	From, To track.Track
+
+	// KeyStep is how far the key moves clockwise round the Camelot wheel (0-11);
+	// Wrapped is set when the move passes 12. Both are strategy.NewTransition's.
+	KeyStep    int
+	Wrapped    bool
+	ModeChange bool
+	BigJump    bool // a key move of three or more steps
+	Invalid    bool // a move that doesn't mix harmonically
+
+	KeyPenalty    float64
+	BPMPenalty    float64
+	EnergyPenalty float64 // negative for a reset's reward
+	Total         float64
+}
+
+// Score is a whole set's rubric result. Lower is better.
+type Score struct {
+	Total              float64
+	KeyPenalty         float64
+	BPMPenalty         float64
+	EnergyPenalty      float64
+	Wraps              int
+	BigJumps           int
+	InvalidTransitions int
+
+	Transitions []Transition
+}
+
+// Options adjust how the rubric judges a set.
+type Options struct {
+	// TempoMatch says which tempo relationships count as no jump, such as half and
+	// double time; "" compares tempos directly.
+	TempoMatch track.TempoMatch
+}
+
+// Evaluate scores tracks in the order given. A set of fewer than two tracks scores 0.
+func Evaluate(tracks []track.Track) Score {
+	return EvaluateWith(tracks, Options{})
+}
+
+// EvaluateWith scores tracks like Evaluate, adjusted by opts.
+func EvaluateWith(tracks []track.Track, opts Options) Score {
+	var score Score
+	if len(tracks) <= 1 {
+		return score
+	}
+
+	sinceReset := 0
+	for i := 1; i < len(tracks); i++ {
+		prev, next := tracks[i-1], tracks[i]
+		move := strategy.NewTransition(prev, next)
+		tr := Transition{Index: i - 1, From: prev, To: next, KeyStep: move.Steps, Wrapped: move.Wrap, ModeChange: move.ModeChange}
+		if tr.Wrapped {
+			sinceReset = 0
+		}
+
+		// A key-agnostic track can't clash, so the move is judged on tempo and energy.
+		if !move.AnyKey {
+			switch tr.KeyStep {
+			case 0:
+				tr.KeyPenalty += 3
+			case 1:
+				if tr.ModeChange {
+					tr.KeyPenalty += 4
+					tr.Invalid = true
+				}
+			case 2:
+				if tr.ModeChange {
+					tr.KeyPenalty += 6
+					tr.Invalid = true
+				}
+			case 3:
+				tr.KeyPenalty += 4
+				tr.BigJump = true
+				if tr.ModeChange {
+					tr.KeyPenalty += 6
+					tr.Invalid = true
+				}
+			default:
+				tr.KeyPenalty += float64(tr.KeyStep * tr.KeyStep)
+				tr.BigJump, tr.Invalid = true, true
+			}
+			if tr.Wrapped && tr.KeyStep > 2 {
+				tr.KeyPenalty += 3
+			}
+		}
+
+		if delta := track.TempoGap(prev.ExitBPM(), next.EntryBPM(), opts.TempoMatch); delta > bpmSlack {
+			tr.BPMPenalty = (delta - bpmSlack) * bpmRate
+		}
+
+		delta := float64(next.Energy - prev.Energy)
+		if delta > energyRiseSlack {
+			tr.EnergyPenalty += (delta - energyRiseSlack) * energyRiseRate
+		} else if delta < -resetDrop {
+			tr.EnergyPenalty -= math.Min(maxResetReward, (-delta-resetDrop)*resetRate)
+			sinceReset = 0
+		}
+		sinceReset++
+		if sinceReset > staleAfter && delta >= 0 {
+			tr.EnergyPenalty += stalePenalty
+		}
+
+		tr.Total = tr.KeyPenalty*keyWeight + tr.BPMPenalty*bpmWeight + tr.EnergyPenalty*energyWeight
+		score.add(tr)
+	}
+	score.Total = score.KeyPenalty*keyWeight + score.BPMPenalty*bpmWeight + score.EnergyPenalty*energyWeight
+	return score
+}
+
+// Flags names what stands out about the transition: "invalid", "big jump", "wraps",
+// and "reset".
+func (tr Transition) Flags() []string {
+	var flags []string
+	if tr.Invalid {
+		flags = append(flags, "invalid")
+	}
+	if tr.BigJump {
+		flags = append(flags, "big jump")
+	}
+	if tr.Wrapped {
+		flags = append(flags, "wraps")
+	}
+	if tr.EnergyPenalty < 0 {
+		flags = append(flags, "reset")
+	}
+	return flags
+}
+
+func (s *Score) add(tr Transition) {
+	s.KeyPenalty += tr.KeyPenalty
+	s.BPMPenalty += tr.BPMPenalty
+	s.EnergyPenalty += tr.EnergyPenalty
+	if tr.Wrapped {
+		s.Wraps++
+	}
+	if tr.BigJump {
+		s.BigJumps++
+	}
+	if tr.Invalid {
+		s.InvalidTransitions++
+	}
+	s.Transitions = append(s.Transitions, tr)
+}
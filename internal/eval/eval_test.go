@@ -0,0 +1,93 @@
+package eval
+
+import (
+	"math"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func mk(key string, bpm float64, energy int) track.Track {
+	k, err := track.ParseKey(key)
+	if err != nil {
+		panic(err)
+	}
+	return track.Track{Title: key, BPM: bpm, Energy: energy, Key: k}
+}
+
+func TestEvaluateScoresEachTransition(t *testing.T) {
+	set := []track.Track{
+		mk("8A", 120, 50),
+		mk("9A", 121, 55),  // clean step
+		mk("10B", 130, 80), // +1 with a mode change; 6 BPM over; 11 energy over
+		mk("3A", 128, 40),  // wraps 5 steps back to 3: big jump; a reset
+	}
+	s := Evaluate(set)
+	if len(s.Transitions) != 3 {
+		t.Fatalf("want 3 transitions, got %d", len(s.Transitions))
+	}
+	if tr := s.Transitions[0]; tr.Total != 0 || tr.Invalid {
+		t.Fatalf("a clean step should be free, got %+v", tr)
+	}
+	if tr := s.Transitions[1]; !tr.Invalid || tr.KeyPenalty != 4 ||
+		math.Abs(tr.BPMPenalty-6*bpmRate) > 1e-9 || math.Abs(tr.EnergyPenalty-11*energyRiseRate) > 1e-9 {
+		t.Fatalf("unexpected mode-change step %+v", tr)
+	}
+	if tr := s.Transitions[2]; !tr.Wrapped || !tr.BigJump || tr.KeyStep != 5 || tr.EnergyPenalty >= 0 {
+		t.Fatalf("unexpected wrapped reset %+v", tr)
+	}
+	if s.Wraps != 1 || s.BigJumps != 1 || s.InvalidTransitions != 2 {
+		t.Fatalf("counts = %d wraps, %d jumps, %d invalid", s.Wraps, s.BigJumps, s.InvalidTransitions)
+	}
+	sum := 0.0
+	for _, tr := range s.Transitions {
+		sum += tr.Total
+	}
+	if math.Abs(sum-s.Total) > 1e-9 {
+		t.Fatalf("transition totals add to %.3f, set total %.3f", sum, s.Total)
+	}
+	if Evaluate(set[:1]).Total != 0 {
+		t.Fatal("a single track has nothing to score")
+	}
+}
+
+func TestEvaluateWithTempoMatch(t *testing.T) {
+	set := []track.Track{mk("8A", 87, 50), mk("8A", 174, 55)}
+	if Evaluate(set).BPMPenalty == 0 {
+		t.Fatal("87 to 174 should be a jump when tempos compare directly")
+	}
+	if s := EvaluateWith(set, Options{TempoMatch: track.TempoHalfDouble}); s.BPMPenalty != 0 {
+		t.Fatalf("87 to 174 is double time, got BPM penalty %.2f", s.BPMPenalty)
+	}
+}
+
+func TestEvaluateReadsKeyMovesAsThePlannerDoes(t *testing.T) {
+	modulating := mk("8A", 124, 50)
+	modulating.Modulations = []track.Key{mk("3A", 0, 0).Key}
+	set := []track.Track{modulating, mk("4A", 124, 52), mk("2A", 124, 54)}
+	s := Evaluate(set)
+	for i, tr := range s.Transitions {
+		move := strategy.NewTransition(set[i], set[i+1])
+		if tr.KeyStep != move.Steps || tr.Wrapped != move.Wrap || tr.ModeChange != move.ModeChange {
+			t.Errorf("transition %d = step %d, wrapped %v, mode change %v; want %+v", i, tr.KeyStep, tr.Wrapped, tr.ModeChange, move)
+		}
+	}
+	if tr := s.Transitions[0]; tr.KeyPenalty != 0 || tr.Wrapped {
+		t.Errorf("leaving 8A/3A for 4A is a clean step from its exit key, got %+v", tr)
+	}
+}
+
+func TestEvaluateReadsEntryAndExit(t *testing.T) {
+	ramp := mk("8A", 100, 50)
+	ramp.BPMEnd = 128
+	if s := Evaluate([]track.Track{ramp, mk("9A", 128, 52)}); s.BPMPenalty != 0 {
+		t.Errorf("leaving a 100-128 ramp for 128 BPM costs %.2f, want nothing", s.BPMPenalty)
+	}
+	sweep := mk("2B", 124, 50)
+	sweep.Tags = []string{track.AnyKeyTag}
+	s := Evaluate([]track.Track{mk("8A", 124, 50), sweep, mk("9A", 124, 50)})
+	if s.KeyPenalty != 0 || s.InvalidTransitions != 0 || s.BigJumps != 0 {
+		t.Errorf("moves through an anykey track = %+v, want no key penalty", s)
+	}
+}
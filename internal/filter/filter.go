@@ -0,0 +1,226 @@
+// Package filter parses small track-matching expressions such as
+// `tag:singalong energy>=70 !artist:"daft punk"`, used wherever a feature needs to
+// select tracks by a user-written rule.
+//
+// An expression is a list of space-separated terms, all of which must match. A term is
+// `field op value`, optionally prefixed with `!` to negate it; a bare word is shorthand
+// for `tag:word`. String fields (title, artist, tag) support `:` (contains, or
+// membership for tags) and `=` (exact, case-insensitive). Numeric fields (bpm,
+// energy, year, dance, valence, pop, acoustic, phrase, priority) support `:`/`=`, `<`, `<=`, `>`, `>=`;
+// a track lacking an optional signal never matches a term on it. `key` matches a
+// key exactly, in any notation track.ParseKey reads (any of the keys of a track that modulates),
+// and `color` a color label as track.ParseColor reads it. `genre` compares normalized genres: `genre=tech-house` matches
+// that genre exactly, `genre:house` matches it or any genre in its family.
+package filter
+
+import (
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Expr is a parsed filter expression.
+type Expr struct {
+	src    string
+	terms  []term
+	genres *genre.Taxonomy
+}
+
+type term struct {
+	negate bool
+	field  string
+	op     string
+	value  string
+	num    float64
+	key    track.Key
+}
+
+// ops a term may use. The earliest operator in a term wins, and on a tie the longer
+// one, so "<=" is never read as "<".
+var ops = []string{"<=", ">=", "<", ">", "=", ":"}
+
+var numericFields = map[string]func(track.Track) (float64, bool){
+	"bpm":      func(t track.Track) (float64, bool) { return t.BPM, true },
+	"energy":   func(t track.Track) (float64, bool) { return float64(t.Energy), true },
+	"year":     func(t track.Track) (float64, bool) { return optional(t.Year) },
+	"dance":    func(t track.Track) (float64, bool) { return optional(t.Danceability) },
+	"valence":  func(t track.Track) (float64, bool) { return optional(t.Valence) },
+	"pop":      func(t track.Track) (float64, bool) { return optional(t.Popularity) },
+	"acoustic": func(t track.Track) (float64, bool) { return optional(t.Acousticness) },
+	"phrase":   func(t track.Track) (float64, bool) { return optional(t.Phrase) },
+	"priority": func(t track.Track) (float64, bool) { return optional(t.Priority) },
+}
+
+var stringFields = map[string]bool{"title": true, "artist": true, "tag": true, "genre": true}
+
+// Parse compiles an expression. An empty expression matches every track.
+func Parse(expr string) (Expr, error) {
+	return ParseWith(expr, genre.Default())
+}
+
+// ParseWith is Parse resolving genre terms through a custom taxonomy.
+func ParseWith(expr string, genres *genre.Taxonomy) (Expr, error) {
+	words, err := split(expr)
+	if err != nil {
+		return Expr{}, err
+	}
+	e := Expr{src: strings.TrimSpace(expr), genres: genres}
+	for _, w := range words {
+		t, err := parseTerm(w)
+		if err != nil {
+			return Expr{}, fmt.Errorf("filter %q: %w", expr, err)
+		}
+		e.terms = append(e.terms, t)
+	}
+	return e, nil
+}
+
+// String returns the expression as written.
+func (e Expr) String() string { return e.src }
+
+// Match reports whether every term matches t.
+func (e Expr) Match(t track.Track) bool {
+	for _, term := range e.terms {
+		if term.match(t, e.genres) == term.negate {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTerm(w string) (term, error) {
+	t := term{}
+	if strings.HasPrefix(w, "!") {
+		t.negate = true
+		w = w[1:]
+	}
+	opAt, op := -1, ""
+	for _, candidate := range ops {
+		if i := strings.Index(w, candidate); i > 0 && (opAt < 0 || i < opAt || (i == opAt && len(candidate) > len(op))) {
+			opAt, op = i, candidate
+		}
+	}
+	if opAt < 0 {
+		t.field, t.op, t.value = "tag", ":", strings.ToLower(w)
+		return t, nil
+	}
+	t.field = strings.ToLower(w[:opAt])
+	t.op = op
+	t.value = strings.ToLower(strings.Trim(w[opAt+len(op):], `"`))
+
+	switch {
+	case t.field == "key":
+		if op != ":" && op != "=" {
+			return t, fmt.Errorf("key supports only = or :")
+		}
+		k, err := track.ParseKey(t.value)
+		if err != nil {
+			return t, err
+		}
+		t.key = k
+	case t.field == "color":
+		if op != ":" && op != "=" {
+			return t, fmt.Errorf("color supports only = or :")
+		}
+		c, err := track.ParseColor(t.value)
+		if err != nil {
+			return t, err
+		}
+		t.value = c
+	case numericFields[t.field] != nil:
+		n, err := strconv.ParseFloat(t.value, 64)
+		if err != nil {
+			return t, fmt.Errorf("%s needs a number, got %q", t.field, t.value)
+		}
+		t.num = n
+	case stringFields[t.field]:
+		if op != ":" && op != "=" {
+			return t, fmt.Errorf("%s supports only = or :", t.field)
+		}
+	default:
+		return t, fmt.Errorf("unknown field %q", t.field)
+	}
+	return t, nil
+}
+
+func (t term) match(tr track.Track, genres *genre.Taxonomy) bool {
+	switch {
+	case t.field == "key":
+		return slices.Contains(tr.Keys(), t.key)
+	case t.field == "color":
+		return tr.Color != "" && tr.Color == t.value
+	case t.field == "genre":
+		if tr.Genre == "" {
+			return false
+		}
+		if t.op == "=" {
+			return genres.Same(tr.Genre, t.value)
+		}
+		return genres.Same(tr.Genre, t.value) || genres.Same(genres.Family(tr.Genre), t.value)
+	case t.field == "tag":
+		return slices.Contains(tr.Tags, t.value)
+	case stringFields[t.field]:
+		v := strings.ToLower(tr.Title)
+		if t.field == "artist" {
+			v = strings.ToLower(tr.Artist)
+		}
+		if t.op == "=" {
+			return v == t.value
+		}
+		return strings.Contains(v, t.value)
+	}
+	v, ok := numericFields[t.field](tr)
+	if !ok {
+		return false
+	}
+	switch t.op {
+	case "<":
+		return v < t.num
+	case "<=":
+		return v <= t.num
+	case ">":
+		return v > t.num
+	case ">=":
+		return v >= t.num
+	}
+	return v == t.num
+}
+
+// split breaks an expression on spaces, keeping double-quoted values together.
+func split(expr string) ([]string, error) {
+	var words []string
+	var cur strings.Builder
+	quoted := false
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			cur.WriteRune(r)
+		case r == ' ' && !quoted:
+			if cur.Len() > 0 {
+				words = append(words, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quoted {
+		return nil, fmt.Errorf("filter %q: unterminated quote", expr)
+	}
+	if cur.Len() > 0 {
+		words = append(words, cur.String())
+	}
+	return words, nil
+}
+
+func optional(p *int) (float64, bool) {
+	if p == nil {
+		return 0, false
+	}
+	return float64(*p), true
+}
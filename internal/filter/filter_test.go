@@ -0,0 +1,65 @@
+package filter_test
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/filter"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestMatch(t *testing.T) {
+	year := 1999
+	tr := track.Track{
+		Title:  "One More Time",
+		Artist: "Daft Punk",
+		BPM:    123,
+		Energy: 80,
+		Key:    track.Key{Number: 8, Mode: track.ModeB},
+		Year:   &year,
+		Tags:   []string{"singalong", "vocal"},
+		Genre:  "Tech-House",
+		Color:  "red",
+	}
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{"", true},
+		{"singalong", true},
+		{"tag:instrumental", false},
+		{"!tag:instrumental", true},
+		{`artist:"daft punk"`, true},
+		{"artist=daft", false},
+		{"title:more", true},
+		{"energy>=80 bpm<125", true},
+		{"energy>80", false},
+		{"key=8B", true},
+		{"year<2000", true},
+		{"valence>10", false}, // absent signal never matches
+		{"genre:house", true}, // family match
+		{`genre="tech house"`, true},
+		{"genre=house", false},
+		{"genre:techno", false},
+		{"color=red", true},
+		{"color:#FF1010", true}, // read as the nearest color label
+		{"!color:green", true},
+		{"priority<3", false}, // unrated
+	}
+	for _, tc := range tests {
+		expr, err := filter.Parse(tc.expr)
+		if err != nil {
+			t.Fatalf("Parse(%q): %v", tc.expr, err)
+		}
+		if got := expr.Match(tr); got != tc.want {
+			t.Errorf("%q matched %v, want %v", tc.expr, got, tc.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	for _, expr := range []string{"mood:happy", "color:beige", "color>red", "bpm>fast", "artist>a", "key=13A", `title:"open`} {
+		if _, err := filter.Parse(expr); err == nil {
+			t.Errorf("Parse(%q) expected error", expr)
+		}
+	}
+}
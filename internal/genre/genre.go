@@ -0,0 +1,188 @@
+// Package genre normalizes free-text genre tags ("Tech House", "tech-house",
+// "Techhouse") to canonical names and groups them into families ("Tech House" is in
+// the "House" family), so genre-aware features compare like with like.
+//
+// A built-in taxonomy covers common dance and popular genres; users extend it with an
+// alias file (see LoadFile).
+package genre
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// builtin lists canonical genres by family. Each family name is also a genre.
+var builtin = map[string][]string{
+	"House":         {"Deep House", "Tech House", "Progressive House", "Afro House", "Electro House", "Future House", "Bass House", "Soulful House", "Funky House", "Acid House", "Melodic House"},
+	"Techno":        {"Minimal Techno", "Melodic Techno", "Hard Techno", "Detroit Techno", "Acid Techno"},
+	"Trance":        {"Progressive Trance", "Uplifting Trance", "Psytrance", "Vocal Trance"},
+	"Drum and Bass": {"Liquid Drum and Bass", "Neurofunk", "Jungle", "Jump Up"},
+	"Dubstep":       {"Riddim", "Brostep", "Bass Music", "Future Bass", "Trap (EDM)"},
+	"Breaks":        {"Breakbeat", "UK Garage", "Speed Garage", "2-Step"},
+	"Hard Dance":    {"Hardstyle", "Hardcore", "Gabber", "Happy Hardcore"},
+	"Disco":         {"Nu Disco", "Italo Disco", "Disco House"},
+	"Downtempo":     {"Ambient", "Chillout", "Lo-Fi", "Trip Hop", "Chillwave"},
+	"Hip Hop":       {"Rap", "Trap", "Boom Bap", "Drill"},
+	"R&B":           {"Neo Soul", "Contemporary R&B"},
+	"Pop":           {"Dance Pop", "Synth Pop", "Electropop", "K-Pop", "Indie Pop", "Teen Pop"},
+	"Rock":          {"Indie Rock", "Alternative", "Punk", "Metal", "Hard Rock", "Classic Rock"},
+	"Soul":          {"Funk", "Motown", "Gospel"},
+	"Latin":         {"Reggaeton", "Salsa", "Bachata", "Cumbia", "Latin Pop", "Moombahton"},
+	"Reggae":        {"Dancehall", "Dub", "Ska"},
+	"Afrobeats":     {"Amapiano", "Afrobeat", "Afro Pop"},
+	"Jazz":          {"Swing", "Bebop", "Smooth Jazz", "Nu Jazz"},
+	"Country":       {"Americana", "Bluegrass"},
+	"Folk":          {"Singer-Songwriter", "Acoustic"},
+	"Classical":     {"Orchestral", "Soundtrack", "Neoclassical"},
+	"Electronic":    {"EDM", "Electronica", "Big Room", "Synthwave", "IDM"},
+}
+
+// builtinAliases maps spellings that folding alone cannot reconcile.
+var builtinAliases = map[string]string{
+	"dnb": "Drum and Bass", "d&b": "Drum and Bass", "drum n bass": "Drum and Bass",
+	"drum & bass": "Drum and Bass", "drumnbass": "Drum and Bass", "liquid dnb": "Liquid Drum and Bass",
+	"hiphop": "Hip Hop", "hip-hop/rap": "Hip Hop", "rap/hip hop": "Hip Hop",
+	"rnb": "R&B", "r and b": "R&B", "rhythm and blues": "R&B",
+	"psy trance": "Psytrance", "psy": "Psytrance", "goa": "Psytrance",
+	"lofi": "Lo-Fi", "lo fi hip hop": "Lo-Fi", "chill out": "Chillout", "chill": "Chillout",
+	"ukg": "UK Garage", "garage": "UK Garage",
+	"electro pop": "Electropop", "synthpop": "Synth Pop",
+	"alt rock": "Alternative", "alternative rock": "Alternative", "heavy metal": "Metal",
+	"afrobeats": "Afrobeats", "afro": "Afrobeats",
+	"indie dance": "Nu Disco", "disco/nu disco": "Nu Disco",
+	"soul/funk": "Soul", "funk/soul": "Soul",
+	"dance": "Electronic", "dance/electronic": "Electronic", "electronic dance": "EDM",
+}
+
+// Taxonomy resolves genre spellings to canonical names and families.
+type Taxonomy struct {
+	canon  map[string]string // folded spelling -> canonical name
+	family map[string]string // canonical name -> family
+}
+
+// Default returns a taxonomy holding only the built-in genres and aliases.
+func Default() *Taxonomy {
+	tx := &Taxonomy{canon: map[string]string{}, family: map[string]string{}}
+	for fam, members := range builtin {
+		tx.addGenre(fam, fam)
+		for _, m := range members {
+			tx.addGenre(m, fam)
+		}
+	}
+	for alias, name := range builtinAliases {
+		tx.canon[fold(alias)] = name
+	}
+	return tx
+}
+
+func (tx *Taxonomy) addGenre(name, family string) {
+	tx.canon[fold(name)] = name
+	tx.family[name] = family
+}
+
+// Normalize returns the canonical name for a genre tag. Multi-genre cells
+// ("House, Tech House") use their first entry. Unknown genres come back trimmed with
+// their spacing tidied, so identical spellings still group; "" stays "".
+func (tx *Taxonomy) Normalize(raw string) string {
+	first := strings.TrimSpace(raw)
+	if i := strings.IndexAny(first, ",;|"); i >= 0 {
+		first = strings.TrimSpace(first[:i])
+	}
+	if first == "" {
+		return ""
+	}
+	if name, ok := tx.canon[fold(first)]; ok {
+		return name
+	}
+	return strings.Join(strings.Fields(first), " ")
+}
+
+// Family returns the family of a genre tag (normalizing it first), or the normalized
+// genre itself when it belongs to no known family.
+func (tx *Taxonomy) Family(raw string) string {
+	name := tx.Normalize(raw)
+	if fam, ok := tx.family[name]; ok {
+		return fam
+	}
+	return name
+}
+
+// Same reports whether two genre tags normalize to the same canonical genre.
+func (tx *Taxonomy) Same(a, b string) bool {
+	return fold(tx.Normalize(a)) == fold(tx.Normalize(b))
+}
+
+// AddAlias maps a spelling to a canonical genre. If name is not yet a known genre it
+// becomes one, in its own family.
+func (tx *Taxonomy) AddAlias(alias, name string) {
+	name = strings.TrimSpace(name)
+	if known, ok := tx.canon[fold(name)]; ok {
+		name = known
+	} else {
+		tx.addGenre(name, name)
+	}
+	tx.canon[fold(alias)] = name
+}
+
+// AddGenre adds a canonical genre to a family, creating the family if needed.
+func (tx *Taxonomy) AddGenre(name, family string) {
+	family = strings.TrimSpace(family)
+	if known, ok := tx.canon[fold(family)]; ok {
+		family = known
+	} else {
+		tx.addGenre(family, family)
+	}
+	tx.addGenre(strings.TrimSpace(name), family)
+}
+
+// File is the on-disk form of user extensions:
+//
+//	{
+//	  "genres":  {"Organic House": "House"},
+//	  "aliases": {"melodic": "Melodic Techno", "edm trap": "Trap (EDM)"}
+//	}
+//
+// Genres are added before aliases, so an alias may point at a genre the same file
+// defines.
+type File struct {
+	Genres  map[string]string `json:"genres"`  // genre -> family
+	Aliases map[string]string `json:"aliases"` // spelling -> genre
+}
+
+// LoadFile extends tx with the genres and aliases in a JSON file. A missing file is
+// not an error.
+func (tx *Taxonomy) LoadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("read genre file: %w", err)
+	}
+	var f File
+	if err := json.Unmarshal(data, &f); err != nil {
+		return fmt.Errorf("parse genre file %s: %w", path, err)
+	}
+	for name, fam := range f.Genres {
+		tx.AddGenre(name, fam)
+	}
+	for alias, name := range f.Aliases {
+		tx.AddAlias(alias, name)
+	}
+	return nil
+}
+
+// fold reduces a spelling to lowercase letters and digits, so "Tech House",
+// "tech-house", and "Techhouse" compare equal. "&" is kept, as in "R&B" and "D&B".
+func fold(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '&' {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
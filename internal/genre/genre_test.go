@@ -0,0 +1,62 @@
+package genre
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	tx := Default()
+	tests := map[string]string{
+		"Tech House":        "Tech House",
+		"tech-house":        "Tech House",
+		"Techhouse":         "Tech House",
+		"DnB":               "Drum and Bass",
+		"drum & bass":       "Drum and Bass",
+		"Hip-Hop":           "Hip Hop",
+		"rnb":               "R&B",
+		"House, Tech House": "House",
+		"  Witch   House ":  "Witch House", // unknown: tidied, not dropped
+		"":                  "",
+	}
+	for in, want := range tests {
+		if got := tx.Normalize(in); got != want {
+			t.Errorf("Normalize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestFamily(t *testing.T) {
+	tx := Default()
+	if got := tx.Family("deep-house"); got != "House" {
+		t.Errorf("Family(deep-house) = %q, want House", got)
+	}
+	if got := tx.Family("Witch House"); got != "Witch House" {
+		t.Errorf("Family(Witch House) = %q, want itself", got)
+	}
+}
+
+func TestLoadFileExtends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "genres.json")
+	data := `{"genres": {"Organic House": "House"}, "aliases": {"organic": "Organic House", "melodic": "Melodic Techno"}}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tx := Default()
+	if err := tx.LoadFile(path); err != nil {
+		t.Fatalf("LoadFile: %v", err)
+	}
+	if got := tx.Normalize("organic"); got != "Organic House" {
+		t.Errorf("Normalize(organic) = %q", got)
+	}
+	if got := tx.Family("organic-house"); got != "House" {
+		t.Errorf("Family(organic-house) = %q", got)
+	}
+	if got := tx.Family("melodic"); got != "Techno" {
+		t.Errorf("Family(melodic) = %q", got)
+	}
+	if err := tx.LoadFile(filepath.Join(t.TempDir(), "missing.json")); err != nil {
+		t.Errorf("missing file should not error: %v", err)
+	}
+}
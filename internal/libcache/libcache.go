@@ -0,0 +1,200 @@
+// Package libcache caches parsed libraries on disk, keyed by a hash of the file's
+// contents, so repeated runs over the same large export skip parsing. An entry is only
+// ever used for byte-identical input; any edit to the file is a cache miss. It also
+// keeps finished results under keys the caller derives (see StoreResult), so an
+// identical run can skip its work altogether.
+//
+// The cache is best-effort: a missing, unreadable, or stale entry falls back to
+// parsing, and a failed write never fails the load.
+package libcache
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/config"
+	"github.com/YakDriver/magicmix/internal/csvio"
+)
+
+// formatVersion is bumped whenever the parsed representation changes (new Track
+// fields, different parsing rules), so entries written by older builds are ignored.
+const formatVersion = 19
+
+// Cache is a directory of parsed-library entries. A nil *Cache is valid and simply
+// parses every time.
+type Cache struct {
+	dir string
+}
+
+// entry is the on-disk form of one cached library.
+type entry struct {
+	Version  int            `json:"version"`
+	Playlist csvio.Playlist `json:"playlist"`
+}
+
+// resultEntry is the on-disk form of one cached result.
+type resultEntry struct {
+	Version int             `json:"version"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// New returns a cache stored in dir.
+func New(dir string) *Cache {
+	return &Cache{dir: dir}
+}
+
+// Default returns the cache in the "cache" subdirectory of the config directory.
+func Default() (*Cache, error) {
+	dir, err := config.Dir()
+	if err != nil {
+		return nil, err
+	}
+	return New(filepath.Join(dir, "cache")), nil
+}
+
+// Dir returns the directory holding the cache entries.
+func (c *Cache) Dir() string {
+	if c == nil {
+		return ""
+	}
+	return c.dir
+}
+
+// LoadPlaylist is csvio.LoadPlaylist backed by the cache. hit reports whether the
+// parsed library came from the cache.
+func (c *Cache) LoadPlaylist(ctx context.Context, path string) (pl csvio.Playlist, hit bool, err error) {
+	if c == nil {
+		pl, err = csvio.LoadPlaylist(ctx, path)
+		return pl, false, err
+	}
+	if err := ctx.Err(); err != nil {
+		return csvio.Playlist{}, false, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return csvio.Playlist{}, false, fmt.Errorf("open input: %w", err)
+	}
+	// A declared encoding reads the same bytes differently, so it keys the entry too.
+	h := sha256.New()
+	h.Write(data)
+	h.Write([]byte(csvio.InputEncoding(ctx)))
+	entryPath := filepath.Join(c.dir, hex.EncodeToString(h.Sum(nil))+".json")
+
+	if pl, ok := c.read(entryPath); ok {
+		return pl, true, nil
+	}
+	pl, err = csvio.ParsePlaylist(ctx, data)
+	if err != nil {
+		return csvio.Playlist{}, false, err
+	}
+	c.write(entryPath, pl)
+	return pl, false, nil
+}
+
+func (c *Cache) read(path string) (csvio.Playlist, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return csvio.Playlist{}, false
+	}
+	var e entry
+	if err := json.Unmarshal(data, &e); err != nil || e.Version != formatVersion {
+		return csvio.Playlist{}, false
+	}
+	return e.Playlist, true
+}
+
+func (c *Cache) write(path string, pl csvio.Playlist) {
+	data, err := json.Marshal(entry{Version: formatVersion, Playlist: pl})
+	if err != nil {
+		return
+	}
+	c.writeFile(path, data)
+}
+
+// LoadResult reads the result stored under key into v, reporting whether there was one
+// that could be read.
+func (c *Cache) LoadResult(key string, v any) bool {
+	if c == nil {
+		return false
+	}
+	data, err := os.ReadFile(c.resultPath(key))
+	if err != nil {
+		return false
+	}
+	var e resultEntry
+	if err := json.Unmarshal(data, &e); err != nil || e.Version != formatVersion {
+		return false
+	}
+	return json.Unmarshal(e.Result, v) == nil
+}
+
+// StoreResult stores v, which must marshal to JSON, under key: a name the caller
+// derives from everything the result depends on, such as a hex digest. Like a parsed
+// library, it is stored best-effort.
+func (c *Cache) StoreResult(key string, v any) {
+	if c == nil {
+		return
+	}
+	result, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(resultEntry{Version: formatVersion, Result: result})
+	if err != nil {
+		return
+	}
+	c.writeFile(c.resultPath(key), data)
+}
+
+func (c *Cache) resultPath(key string) string {
+	return filepath.Join(c.dir, "result-"+key+".json")
+}
+
+// writeFile stores an entry via a temporary file and rename, so a concurrent reader
+// never sees a partial entry. Errors are ignored; the next run simply parses again.
+func (c *Cache) writeFile(path string, data []byte) {
+	if err := os.MkdirAll(c.dir, 0o755); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(c.dir, ".entry-*")
+	if err != nil {
+		return
+	}
+	_, werr := tmp.Write(data)
+	cerr := tmp.Close()
+	if werr != nil || cerr != nil || os.Rename(tmp.Name(), path) != nil {
+		_ = os.Remove(tmp.Name())
+	}
+}
+
+// Clear removes every cache entry, libraries and results, and reports how many it
+// removed.
+func (c *Cache) Clear() (int, error) {
+	if c == nil {
+		return 0, nil
+	}
+	names, err := os.ReadDir(c.dir)
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, n := range names {
+		if n.IsDir() || !strings.HasSuffix(n.Name(), ".json") {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, n.Name())); err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
@@ -0,0 +1,112 @@
+package libcache
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const library = "Title,Artist,BPM,Energy,Key,Length,Tags\n" +
+	"One,A,120,50,8A,3:30,vocal\n" +
+	"Two,B,122,60,9A,,\n"
+
+func TestLoadPlaylistHitsOnSecondRun(t *testing.T) {
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "lib.csv")
+	if err := os.WriteFile(input, []byte(library), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(t.TempDir())
+
+	first, hit, err := c.LoadPlaylist(ctx, input)
+	if err != nil || hit {
+		t.Fatalf("first load: hit=%v err=%v", hit, err)
+	}
+	second, hit, err := c.LoadPlaylist(ctx, input)
+	if err != nil || !hit {
+		t.Fatalf("second load: hit=%v err=%v", hit, err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("cached playlist differs:\n%+v\n%+v", first, second)
+	}
+}
+
+func TestLoadPlaylistMissesWhenFileChanges(t *testing.T) {
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "lib.csv")
+	if err := os.WriteFile(input, []byte(library), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	c := New(t.TempDir())
+	if _, _, err := c.LoadPlaylist(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(input, []byte(library+"Three,C,124,70,10A,,\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pl, hit, err := c.LoadPlaylist(ctx, input)
+	if err != nil || hit {
+		t.Fatalf("edited file: hit=%v err=%v", hit, err)
+	}
+	if len(pl.Tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3", len(pl.Tracks))
+	}
+}
+
+func TestCorruptEntryFallsBackAndClears(t *testing.T) {
+	ctx := context.Background()
+	input := filepath.Join(t.TempDir(), "lib.csv")
+	if err := os.WriteFile(input, []byte(library), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	c := New(dir)
+	if _, _, err := c.LoadPlaylist(ctx, input); err != nil {
+		t.Fatal(err)
+	}
+	entries, _ := filepath.Glob(filepath.Join(dir, "*.json"))
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries, want 1", len(entries))
+	}
+	if err := os.WriteFile(entries[0], []byte("{not json"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	pl, hit, err := c.LoadPlaylist(ctx, input)
+	if err != nil || hit || len(pl.Tracks) != 2 {
+		t.Fatalf("corrupt entry: hit=%v err=%v tracks=%d", hit, err, len(pl.Tracks))
+	}
+
+	n, err := c.Clear()
+	if err != nil || n != 1 {
+		t.Fatalf("Clear = %d, %v; want 1", n, err)
+	}
+}
+
+func TestResultRoundTripsAndClears(t *testing.T) {
+	c := New(t.TempDir())
+	type result struct{ Order []string }
+	var got result
+	if c.LoadResult("abc", &got) {
+		t.Fatal("hit before anything was stored")
+	}
+	c.StoreResult("abc", result{Order: []string{"Two", "One"}})
+	if !c.LoadResult("abc", &got) || !reflect.DeepEqual(got.Order, []string{"Two", "One"}) {
+		t.Fatalf("stored result not read back: %+v", got)
+	}
+	if c.LoadResult("abd", &got) {
+		t.Error("hit under another key")
+	}
+	if n, err := c.Clear(); err != nil || n != 1 {
+		t.Fatalf("Clear() = %d, %v; want 1", n, err)
+	}
+	if c.LoadResult("abc", &got) {
+		t.Error("hit after Clear")
+	}
+	var nilCache *Cache
+	nilCache.StoreResult("abc", result{})
+	if nilCache.LoadResult("abc", &got) {
+		t.Error("nil cache hit")
+	}
+}
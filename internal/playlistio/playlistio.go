@@ -0,0 +1,371 @@
+// Package playlistio reads libraries and writes ordered sets in every format magicmix
+// knows, through registries of readers and writers keyed by format name (see
+// RegisterReader and RegisterWriter), so a format can be added — by magicmix or an
+// embedder — without touching the callers. Built in are CSV, JSON, and Rekordbox XML,
+// both ways, a folder of tagged audio files to read (see audiotags), extended M3U
+// (.m3u, .m3u8) for players, and the shareable Setlist, both ways; M3U, JSON, and
+// setlists carry a suggested crossfade for each transition (see
+// strategy.SuggestCrossfade) and a suggested gain for each track with a loudness (see
+// strategy.SuggestGain). CSV stays in csvio, Rekordbox XML in
+// rekordbox, and tag reading in audiotags; the registered readers and writers hand off
+// to them. Every reader and writer takes the path Stdio to mean standard input or
+// output.
+package playlistio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Format is an output format.
+type Format string
+
+const (
+	CSV  Format = "csv"
+	M3U  Format = "m3u"
+	M3U8 Format = "m3u8"
+	JSON Format = "json"
+
+	Rekordbox Format = "rekordbox" // a Rekordbox XML collection with the set as a playlist
+	Folder    Format = "folder"    // a folder of tagged MP3, FLAC, and AIFF files; input only
+)
+
+// ParseFormat reads an output format name such as "m3u8" (case-insensitive, with or
+// without a leading dot); a registered extension works too, so "xml" is Rekordbox.
+func ParseFormat(s string) (Format, error) {
+	if f, ok := lookup(s, Writers()); ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown format %q (want %s)", s, formatNames(Writers()))
+}
+
+// FormatOf infers the output format from a path's extension, defaulting to CSV.
+func FormatOf(path string) Format {
+	if f, ok := lookup(filepath.Ext(path), Writers()); ok {
+		return f
+	}
+	return CSV
+}
+
+// ParseInputFormat is ParseFormat for the formats that can be read.
+func ParseInputFormat(s string) (Format, error) {
+	if f, ok := lookup(s, Readers()); ok {
+		return f, nil
+	}
+	return "", fmt.Errorf("unknown input format %q (want %s)", s, formatNames(Readers()))
+}
+
+// InputFormatOf infers the input format from a path's extension, defaulting to CSV;
+// a directory is a Folder.
+func InputFormatOf(path string) Format {
+	if path != Stdio {
+		if info, err := os.Stat(path); err == nil && info.IsDir() {
+			return Folder
+		}
+	}
+	if f, ok := lookup(filepath.Ext(path), Readers()); ok {
+		return f
+	}
+	return CSV
+}
+
+// Ext is the file extension for the format, with its dot: its writer's first
+// extension, or the name itself.
+func (f Format) Ext() string {
+	if reg, ok := writers[f]; ok && len(reg.info.Extensions) > 0 {
+		return reg.info.Extensions[0]
+	}
+	return "." + string(f)
+}
+
+// CrossfadeDirective is the M3U line carrying a suggested crossfade. It follows a
+// track's #EXTINF line and gives the seconds to overlap that track into the next one,
+// then the style: "#EXT-X-CROSSFADE:8.5,short". Software that doesn't know it skips
+// it as a comment.
+const CrossfadeDirective = "#EXT-X-CROSSFADE:"
+
+// GainDirective is the M3U line carrying a track's suggested gain in dB (see
+// strategy.SuggestGain), after its #EXTINF line: "#EXT-X-GAIN:-2.5". Like
+// CrossfadeDirective, it is a comment to software that doesn't know it.
+const GainDirective = "#EXT-X-GAIN:"
+
+// Load reads path with the reader registered for format f. Reading Stdio with f ""
+// detects the format from the content (see Detect).
+func Load(ctx context.Context, path string, f Format, opts ReadOptions) (csvio.Playlist, error) {
+	if path == Stdio && f == "" {
+		data, err := ReadAll(ctx, path)
+		if err != nil {
+			return csvio.Playlist{}, err
+		}
+		f, ctx = Detect(data), WithStdin(ctx, data)
+	}
+	read, err := GetReader(f)
+	if err != nil {
+		return csvio.Playlist{}, err
+	}
+	return read(ctx, path, opts)
+}
+
+// Save writes pl to path with the writer registered for format f. CSV goes through
+// csvio.SaveInFormat and keeps the input's columns; a Rekordbox playlist is named
+// after the file. Every writer sends Stdio to standard output (see WithStdout).
+func Save(ctx context.Context, path string, f Format, pl csvio.Playlist) error {
+	write, err := GetWriter(f)
+	if err != nil {
+		return err
+	}
+	return write(ctx, path, pl)
+}
+
+// MissingLocations counts tracks with no file location, which a playlist can only list
+// by name.
+func MissingLocations(tracks []track.Track) int {
+	missing := 0
+	for _, t := range tracks {
+		if t.Location == "" {
+			missing++
+		}
+	}
+	return missing
+}
+
+// WriteM3U writes tracks as an extended M3U playlist in UTF-8: an #EXTINF line per
+// track (length in seconds, or -1 when unknown, then "Artist - Title"), its suggested
+// gain when its loudness is known (see GainDirective), the crossfade into the next
+// track (see CrossfadeDirective), and the track's location. Tracks with
+// no location are listed by artist and title.
+func WriteM3U(w io.Writer, tracks []track.Track) error {
+	fades := strategy.Crossfades(tracks)
+	var b strings.Builder
+	b.WriteString("#EXTM3U\n")
+	for i, t := range tracks {
+		length := -1
+		if t.Duration != nil {
+			length = *t.Duration
+		}
+		fmt.Fprintf(&b, "#EXTINF:%d,%s - %s\n", length, t.Artist, t.Title)
+		if gain, ok := strategy.SuggestGain(t); ok {
+			fmt.Fprintf(&b, "%s%s\n", GainDirective, strconv.FormatFloat(gain, 'f', -1, 64))
+		}
+		if i < len(fades) {
+			fmt.Fprintf(&b, "%s%s,%s\n", CrossfadeDirective,
+				strconv.FormatFloat(fades[i].Seconds, 'f', -1, 64), fades[i].Style)
+		}
+		entry := t.Location
+		if entry == "" {
+			entry = t.Artist + " - " + t.Title
+		}
+		b.WriteString(entry + "\n")
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// Track is one entry of a JSON playlist. It carries the same signals as a CSV row,
+// each omitted when the track has none, so the playlist reads back in (see ParseJSON).
+// Gain is the suggested gain in dB (see strategy.SuggestGain), absent when the loudness
+// is unknown. Crossfade fields describe the fade into the next track and are absent on
+// the last.
+type Track struct {
+	Position       int      `json:"position"`
+	Title          string   `json:"title"`
+	Artist         string   `json:"artist"`
+	ID             string   `json:"id"`
+	Location       string   `json:"location,omitempty"`
+	Key            string   `json:"key"`
+	BPM            string   `json:"bpm"`
+	Energy         int      `json:"energy"`
+	EnergyInferred bool     `json:"energy_inferred,omitempty"`
+	Duration       *int     `json:"duration_seconds,omitempty"`
+	Danceability   *int     `json:"danceability,omitempty"`
+	Valence        *int     `json:"valence,omitempty"`
+	Popularity     *int     `json:"popularity,omitempty"`
+	Acousticness   *int     `json:"acousticness,omitempty"`
+	Year           *int     `json:"year,omitempty"`
+	Tags           []string `json:"tags,omitempty"`
+	Phrase         *int     `json:"phrase,omitempty"`
+	Genre          string   `json:"genre,omitempty"`
+	Album          string   `json:"album,omitempty"`
+	Loudness       *float64 `json:"loudness,omitempty"`
+	Gain           *float64 `json:"gain_db,omitempty"`
+	Added          string   `json:"date_added,omitempty"`
+	Intro          *int     `json:"intro_seconds,omitempty"`
+	Outro          *int     `json:"outro_seconds,omitempty"`
+	Priority       *int     `json:"priority,omitempty"`
+	Cues           *int     `json:"cues,omitempty"`
+	Locked         bool     `json:"locked,omitempty"`
+	Color          string   `json:"color,omitempty"`
+	License        string   `json:"license,omitempty"`
+	Regions        []string `json:"regions,omitempty"`
+	Fingerprint    string   `json:"fingerprint,omitempty"`
+	Crossfade      *float64 `json:"crossfade_seconds,omitempty"`
+	Style          string   `json:"crossfade_style,omitempty"`
+}
+
+// Playlist is the JSON playlist document.
+type Playlist struct {
+	Tracks []Track `json:"tracks"`
+}
+
+// WriteJSON writes tracks as an indented JSON Playlist.
+func WriteJSON(w io.Writer, tracks []track.Track) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(Playlist{Tracks: JSONTracks(tracks)})
+}
+
+// JSONTracks converts a set to Playlist tracks, each with its position and the
+// suggested crossfade into the next.
+func JSONTracks(tracks []track.Track) []Track {
+	fades := strategy.Crossfades(tracks)
+	out := make([]Track, len(tracks))
+	for i, t := range tracks {
+		pt := Track{Position: i + 1, Title: t.Title, Artist: t.Artist, ID: t.ID(), Location: t.Location,
+			Key: t.KeyString(), BPM: t.TempoString(), Energy: t.Energy, EnergyInferred: t.EnergyInferred,
+			Duration: t.Duration, Danceability: t.Danceability, Valence: t.Valence, Popularity: t.Popularity,
+			Acousticness: t.Acousticness, Year: t.Year, Tags: t.Tags, Phrase: t.Phrase, Genre: t.Genre,
+			Album: t.Album, Loudness: t.Loudness, Intro: t.Intro, Outro: t.Outro, Priority: t.Priority, Color: t.Color,
+			Cues: t.Cues, Locked: t.Locked, Fingerprint: t.Fingerprint, License: t.License, Regions: t.Regions}
+		if t.Added != nil {
+			pt.Added = t.Added.Format("2006-01-02")
+		}
+		if gain, ok := strategy.SuggestGain(t); ok {
+			pt.Gain = &gain
+		}
+		if i < len(fades) {
+			pt.Crossfade, pt.Style = &fades[i].Seconds, fades[i].Style
+		}
+		out[i] = pt
+	}
+	return out
+}
+
+// ParseJSON reads tracks from JSON: an array of objects whose fields are named like CSV
+// columns ("title", "bpm", "key", "duration_seconds", …), or a Playlist document such
+// as WriteJSON writes, so magicmix's own JSON reads back in. An underscore in a field
+// name stands for a space; values may be strings, numbers, booleans, or lists (of
+// tags), and unknown fields are ignored as unknown CSV columns are.
+func ParseJSON(ctx context.Context, data []byte) (csvio.Playlist, error) {
+	if err := ctx.Err(); err != nil {
+		return csvio.Playlist{}, err
+	}
+	data = bytes.TrimSpace(data)
+	if bytes.HasPrefix(data, []byte("{")) {
+		var doc struct {
+			Tracks json.RawMessage `json:"tracks"`
+		}
+		if err := json.Unmarshal(data, &doc); err != nil {
+			return csvio.Playlist{}, fmt.Errorf("read json: %w", err)
+		}
+		if doc.Tracks == nil {
+			return csvio.Playlist{}, errors.New(`read json: want an array of tracks or an object with a "tracks" array`)
+		}
+		data = doc.Tracks
+	}
+	var objects []json.RawMessage
+	if err := json.Unmarshal(data, &objects); err != nil {
+		return csvio.Playlist{}, fmt.Errorf("read json: %w", err)
+	}
+	if len(objects) == 0 {
+		return csvio.Playlist{}, nil
+	}
+
+	// Fields become columns in the order they first appear.
+	var header []string
+	index := map[string]int{}
+	rows := make([][]string, len(objects))
+	for i, obj := range objects {
+		fields, err := jsonFields(obj)
+		if err != nil {
+			return csvio.Playlist{}, fmt.Errorf("record %d: %w", i+1, err)
+		}
+		row := make([]string, len(header))
+		for _, f := range fields {
+			j, ok := index[f.name]
+			if !ok {
+				j = len(header)
+				index[f.name] = j
+				header = append(header, f.name)
+			}
+			for len(row) <= j {
+				row = append(row, "")
+			}
+			row[j] = f.value
+		}
+		rows[i] = row
+	}
+	return csvio.ParseRows(ctx, header, rows)
+}
+
+// jsonField is one field of a JSON track as a CSV column name and cell.
+type jsonField struct {
+	name, value string
+}
+
+// jsonFields reads a JSON object's fields in document order.
+func jsonFields(obj json.RawMessage) ([]jsonField, error) {
+	dec := json.NewDecoder(bytes.NewReader(obj))
+	dec.UseNumber()
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, errors.New("want an object")
+	}
+	var fields []jsonField
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		name, _ := tok.(string)
+		var v any
+		if err := dec.Decode(&v); err != nil {
+			return nil, err
+		}
+		value, err := jsonCell(v)
+		if err != nil {
+			return nil, fmt.Errorf("field %q: %w", name, err)
+		}
+		fields = append(fields, jsonField{name: strings.ReplaceAll(name, "_", " "), value: value})
+	}
+	return fields, nil
+}
+
+// jsonCell renders a JSON value as the CSV cell that means the same: numbers as
+// written, true as "yes", and lists joined with semicolons.
+func jsonCell(v any) (string, error) {
+	switch v := v.(type) {
+	case nil:
+		return "", nil
+	case string:
+		return v, nil
+	case json.Number:
+		return v.String(), nil
+	case bool:
+		if v {
+			return "yes", nil
+		}
+		return "", nil
+	case []any:
+		parts := make([]string, len(v))
+		for i, e := range v {
+			s, err := jsonCell(e)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = s
+		}
+		return strings.Join(parts, "; "), nil
+	}
+	return "", errors.New("want a string, number, boolean, or list")
+}
@@ -0,0 +1,213 @@
+package playlistio
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestFormats(t *testing.T) {
+	for path, want := range map[string]Format{
+		"set.m3u8": M3U8, "set.M3U": M3U, "set.json": JSON, "set.csv": CSV, "set": CSV, "set.txt": CSV,
+		"set.xml": Rekordbox, "set.setlist": Setlist,
+	} {
+		if got := FormatOf(path); got != want {
+			t.Errorf("FormatOf(%q) = %q, want %q", path, got, want)
+		}
+	}
+	if f, err := ParseFormat(".M3U8"); err != nil || f != M3U8 {
+		t.Errorf("ParseFormat(.M3U8) = %q, %v", f, err)
+	}
+	if Rekordbox.Ext() != ".xml" {
+		t.Errorf("Rekordbox.Ext() = %q, want .xml", Rekordbox.Ext())
+	}
+	if f, err := ParseInputFormat("xml"); err != nil || f != Rekordbox {
+		t.Errorf("ParseInputFormat(xml) = %q, %v", f, err)
+	}
+	if _, err := ParseInputFormat("m3u8"); err == nil {
+		t.Error("ParseInputFormat accepted a format with no reader")
+	}
+	if _, err := ParseFormat("pls"); err == nil {
+		t.Error("ParseFormat accepted an unknown format")
+	}
+}
+
+func playlistTracks() []track.Track {
+	secs, lufs := 215, -17.5
+	return []track.Track{
+		{Title: "Cola", Artist: "CamelPhat", BPM: 122, Energy: 60, Key: track.Key{Number: 8, Mode: track.ModeA},
+			Duration: &secs, Location: "/music/cola.mp3"},
+		{Title: "Innerbloom", Artist: "RÜFÜS DU SOL", BPM: 122, Energy: 55, Key: track.Key{Number: 9, Mode: track.ModeA},
+			Loudness: &lufs},
+	}
+}
+
+func TestWriteM3U(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteM3U(&buf, playlistTracks()); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	want := []string{"#EXTM3U", "#EXTINF:215,CamelPhat - Cola", "", "/music/cola.mp3",
+		"#EXTINF:-1,RÜFÜS DU SOL - Innerbloom", "#EXT-X-GAIN:3.5", "RÜFÜS DU SOL - Innerbloom"}
+	if len(lines) != len(want) {
+		t.Fatalf("got %d lines, want %d:\n%s", len(lines), len(want), buf.String())
+	}
+	for i, w := range want {
+		if w == "" {
+			if !strings.HasPrefix(lines[i], CrossfadeDirective) {
+				t.Errorf("line %d = %q, want a crossfade directive", i+1, lines[i])
+			}
+			continue
+		}
+		if lines[i] != w {
+			t.Errorf("line %d = %q, want %q", i+1, lines[i], w)
+		}
+	}
+}
+
+func TestWriteJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, playlistTracks()); err != nil {
+		t.Fatal(err)
+	}
+	var doc Playlist
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tracks) != 2 || doc.Tracks[0].Crossfade == nil || doc.Tracks[1].Crossfade != nil {
+		t.Fatalf("unexpected playlist: %s", buf.String())
+	}
+	if doc.Tracks[0].Key != "8A" || doc.Tracks[1].Position != 2 || doc.Tracks[0].Gain != nil ||
+		doc.Tracks[1].Gain == nil || *doc.Tracks[1].Gain != 3.5 {
+		t.Fatalf("unexpected fields: %+v", doc.Tracks)
+	}
+}
+
+func TestParseJSON(t *testing.T) {
+	data := []byte(`[
+		{"title": "Cola", "artist": "CamelPhat", "bpm": 122, "key": "8A", "energy": 60,
+		 "duration_seconds": 215, "tags": ["Deep", "late"], "energy_inferred": false, "mood": null},
+		{"title": "Innerbloom", "bpm": "122", "key": "9A", "intro_seconds": 32}
+	]`)
+	pl, err := ParseJSON(context.Background(), data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pl.Tracks) != 2 || pl.Header != nil {
+		t.Fatalf("got %d tracks, header %v", len(pl.Tracks), pl.Header)
+	}
+	cola, bloom := pl.Tracks[0], pl.Tracks[1]
+	if cola.BPM != 122 || cola.Energy != 60 || cola.Duration == nil || *cola.Duration != 215 ||
+		strings.Join(cola.Tags, ",") != "deep,late" || cola.Raw != nil {
+		t.Errorf("unexpected first track: %+v", cola)
+	}
+	if !bloom.EnergyInferred || bloom.Intro == nil || *bloom.Intro != 32 {
+		t.Errorf("unexpected second track: %+v", bloom)
+	}
+
+	if _, err := ParseJSON(context.Background(), []byte(`[{"title": "Cola", "bpm": {"x": 1}, "key": "8A"}]`)); err == nil ||
+		!strings.Contains(err.Error(), "record 1") {
+		t.Errorf("nested object: err = %v, want a record 1 error", err)
+	}
+	if _, err := ParseJSON(context.Background(), []byte(`[{"title": "Cola"}]`)); err == nil {
+		t.Error("accepted tracks with no bpm or key")
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	tracks := playlistTracks()
+	year, added := 2019, time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+	tracks[0].Year, tracks[0].Added, tracks[0].Genre = &year, &added, "House"
+	var buf bytes.Buffer
+	if err := WriteJSON(&buf, tracks); err != nil {
+		t.Fatal(err)
+	}
+	pl, err := ParseJSON(context.Background(), buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pl.Tracks) != 2 {
+		t.Fatalf("got %d tracks, want 2", len(pl.Tracks))
+	}
+	got := pl.Tracks[0]
+	if got.Title != "Cola" || got.Location != "/music/cola.mp3" || got.Key != tracks[0].Key ||
+		got.Year == nil || *got.Year != 2019 || got.Added == nil || !got.Added.Equal(added) || got.Genre != "House" {
+		t.Errorf("round trip lost fields: %+v", got)
+	}
+}
+
+func TestSetlistRoundTrip(t *testing.T) {
+	tracks := playlistTracks()
+	ctx := WithManifest(context.Background(), Manifest{Strategy: "flow", Seed: 7, Options: map[string]string{"limit": "2"}})
+	pl := csvio.Playlist{Tracks: tracks, Extra: []csvio.Column{{Name: "Why", Values: []string{"opener", "key step +1"}}}}
+	var buf bytes.Buffer
+	if err := WriteSetlist(ctx, &buf, pl); err != nil {
+		t.Fatal(err)
+	}
+	if got := Detect(buf.Bytes()); got != Setlist {
+		t.Errorf("Detect(setlist) = %q", got)
+	}
+
+	doc, err := ReadSetlist(buf.Bytes())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if doc.Manifest.Strategy != "flow" || doc.Manifest.Seed != 7 || doc.Manifest.Options["limit"] != "2" {
+		t.Errorf("manifest = %+v", doc.Manifest)
+	}
+	if len(doc.Transitions) != 1 || doc.Transitions[0].Why != "key step +1" {
+		t.Errorf("transitions = %+v", doc.Transitions)
+	}
+	read, err := doc.ReadTracks(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strategy.ScoreMix(read).Total; got != doc.Score.Mix {
+		t.Errorf("rescored mix %v, recorded %v", got, doc.Score.Mix)
+	}
+
+	edited := bytes.Replace(buf.Bytes(), []byte(`"seed": 7`), []byte(`"seed": 8`), 1)
+	if _, err := ReadSetlist(edited); err == nil || !strings.Contains(err.Error(), "digest") {
+		t.Errorf("edited setlist: err = %v, want a digest mismatch", err)
+	}
+}
+
+func TestLoadStdinDetectsFormat(t *testing.T) {
+	for name, input := range map[Format]string{
+		CSV:  "Title,BPM,Key,Energy\nCola,122,8A,60\n",
+		JSON: ` [{"title": "Cola", "bpm": 122, "key": "8A", "energy": 60}]`,
+		Rekordbox: `<?xml version="1.0"?><DJ_PLAYLISTS><COLLECTION>` +
+			`<TRACK Name="Cola" AverageBpm="122" Tonality="8A" Comments="Energy 6"/></COLLECTION></DJ_PLAYLISTS>`,
+	} {
+		if got := Detect([]byte(input)); got != name {
+			t.Errorf("Detect(%s) = %q", name, got)
+		}
+		ctx := WithStdin(context.Background(), []byte(input))
+		pl, err := Load(ctx, Stdio, "", ReadOptions{})
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(pl.Tracks) != 1 || pl.Tracks[0].Title != "Cola" {
+			t.Errorf("%s: got %+v", name, pl.Tracks)
+		}
+	}
+}
+
+func TestSaveStdout(t *testing.T) {
+	var buf bytes.Buffer
+	ctx := WithStdout(context.Background(), &buf)
+	if err := Save(ctx, Stdio, JSON, csvio.Playlist{Tracks: playlistTracks()}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), `"title": "Innerbloom"`) {
+		t.Errorf("standard output got %q", buf.String())
+	}
+}
@@ -0,0 +1,230 @@
+package playlistio
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/audiotags"
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/rekordbox"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Reader reads a library or set from path.
+type Reader func(ctx context.Context, path string, opts ReadOptions) (csvio.Playlist, error)
+
+// ReadOptions are settings a Reader may honor; each reader ignores what it has no use
+// for.
+type ReadOptions struct {
+	// EnergyField names the field holding energy in a format that keeps it somewhere
+	// free-form, such as a Rekordbox attribute; "" is the format's default.
+	EnergyField string
+}
+
+// Writer writes an ordered set to path, creating directories as needed.
+type Writer func(ctx context.Context, path string, pl csvio.Playlist) error
+
+// FormatInfo describes a registered format.
+type FormatInfo struct {
+	Name        Format
+	Description string
+	// Extensions are the file extensions, with their dot, that mean this format when
+	// no format is named. A Writer's first extension is the one Ext gives.
+	Extensions []string
+}
+
+type readerRegistration struct {
+	info   FormatInfo
+	reader Reader
+}
+
+type writerRegistration struct {
+	info   FormatInfo
+	writer Writer
+}
+
+var (
+	readers = map[Format]readerRegistration{}
+	writers = map[Format]writerRegistration{}
+)
+
+func init() {
+	csvInfo := FormatInfo{Name: CSV, Description: "comma-separated columns matched by header name", Extensions: []string{".csv"}}
+	RegisterReader(csvInfo, func(ctx context.Context, path string, _ ReadOptions) (csvio.Playlist, error) {
+		data, err := ReadAll(ctx, path)
+		if err != nil {
+			return csvio.Playlist{}, err
+		}
+		return csvio.ParsePlaylist(ctx, data)
+	})
+	RegisterWriter(csvInfo, func(ctx context.Context, path string, pl csvio.Playlist) error {
+		if path == Stdio {
+			return csvio.WriteInFormat(Stdout(ctx), pl)
+		}
+		return csvio.SaveInFormat(ctx, path, pl)
+	})
+
+	RegisterWriter(FormatInfo{Name: M3U8, Description: "extended M3U in UTF-8, with crossfades",
+		Extensions: []string{".m3u8"}}, StreamWriter(WriteM3U))
+	RegisterWriter(FormatInfo{Name: M3U, Description: "extended M3U, with crossfades",
+		Extensions: []string{".m3u"}}, StreamWriter(WriteM3U))
+	RegisterWriter(FormatInfo{Name: JSON, Description: "JSON playlist with crossfades",
+		Extensions: []string{".json"}}, StreamWriter(WriteJSON))
+	RegisterReader(FormatInfo{Name: JSON, Description: "JSON array of tracks with CSV's fields, or a JSON playlist",
+		Extensions: []string{".json"}}, func(ctx context.Context, path string, _ ReadOptions) (csvio.Playlist, error) {
+		data, err := ReadAll(ctx, path)
+		if err != nil {
+			return csvio.Playlist{}, err
+		}
+		return ParseJSON(ctx, data)
+	})
+
+	rekordboxInfo := FormatInfo{Name: Rekordbox, Description: "Rekordbox XML collection; the set is a playlist named after the file",
+		Extensions: []string{".xml"}}
+	RegisterReader(rekordboxInfo, func(ctx context.Context, path string, opts ReadOptions) (csvio.Playlist, error) {
+		data, err := ReadAll(ctx, path)
+		if err != nil {
+			return csvio.Playlist{}, err
+		}
+		return rekordbox.Parse(ctx, data, rekordbox.Options{EnergyField: opts.EnergyField})
+	})
+	RegisterWriter(rekordboxInfo, func(ctx context.Context, path string, pl csvio.Playlist) error {
+		name := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+		if path == Stdio {
+			name = "magicmix"
+		}
+		return StreamWriter(func(w io.Writer, tracks []track.Track) error {
+			return rekordbox.Write(w, tracks, name)
+		})(ctx, path, pl)
+	})
+
+	setlistInfo := FormatInfo{Name: Setlist, Description: "shareable set with its provenance, score, and transition notes, sealed by a digest",
+		Extensions: []string{".setlist"}}
+	RegisterWriter(setlistInfo, streamPlaylist(WriteSetlist))
+	RegisterReader(setlistInfo, func(ctx context.Context, path string, _ ReadOptions) (csvio.Playlist, error) {
+		data, err := ReadAll(ctx, path)
+		if err != nil {
+			return csvio.Playlist{}, err
+		}
+		return ParseSetlist(ctx, data)
+	})
+
+	RegisterReader(FormatInfo{Name: Folder, Description: "folder of MP3, FLAC, and AIFF files, read from their tags"},
+		func(ctx context.Context, path string, _ ReadOptions) (csvio.Playlist, error) {
+			return audiotags.Load(ctx, path)
+		})
+}
+
+// RegisterReader adds or replaces the reader for info.Name.
+func RegisterReader(info FormatInfo, r Reader) {
+	readers[info.Name] = readerRegistration{info: info, reader: r}
+}
+
+// RegisterWriter adds or replaces the writer for info.Name.
+func RegisterWriter(info FormatInfo, w Writer) {
+	writers[info.Name] = writerRegistration{info: info, writer: w}
+}
+
+// GetReader returns the reader registered for f.
+func GetReader(f Format) (Reader, error) {
+	reg, ok := readers[f]
+	if !ok {
+		return nil, fmt.Errorf("no reader for format %q", f)
+	}
+	return reg.reader, nil
+}
+
+// GetWriter returns the writer registered for f.
+func GetWriter(f Format) (Writer, error) {
+	reg, ok := writers[f]
+	if !ok {
+		return nil, fmt.Errorf("no writer for format %q", f)
+	}
+	return reg.writer, nil
+}
+
+// Readers describes every readable format, sorted by name.
+func Readers() []FormatInfo {
+	infos := make([]FormatInfo, 0, len(readers))
+	for _, reg := range readers {
+		infos = append(infos, reg.info)
+	}
+	return sortInfos(infos)
+}
+
+// Writers describes every writable format, sorted by name.
+func Writers() []FormatInfo {
+	infos := make([]FormatInfo, 0, len(writers))
+	for _, reg := range writers {
+		infos = append(infos, reg.info)
+	}
+	return sortInfos(infos)
+}
+
+func sortInfos(infos []FormatInfo) []FormatInfo {
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
+
+// StreamWriter makes a Writer from a function that writes tracks to an io.Writer: it
+// creates the file (and its directory) and hands it over, or hands over standard
+// output when path is Stdio.
+func StreamWriter(write func(io.Writer, []track.Track) error) Writer {
+	return streamPlaylist(func(_ context.Context, w io.Writer, pl csvio.Playlist) error {
+		return write(w, pl.Tracks)
+	})
+}
+
+// streamPlaylist is StreamWriter for a function that needs the whole playlist.
+func streamPlaylist(write func(context.Context, io.Writer, csvio.Playlist) error) Writer {
+	return func(ctx context.Context, path string, pl csvio.Playlist) (err error) {
+		if path == Stdio {
+			return write(ctx, Stdout(ctx), pl)
+		}
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			return fmt.Errorf("create output directory: %w", err)
+		}
+		file, err := os.Create(path)
+		if err != nil {
+			return fmt.Errorf("create output: %w", err)
+		}
+		defer func() {
+			if cerr := file.Close(); cerr != nil && err == nil {
+				err = fmt.Errorf("close output: %w", cerr)
+			}
+		}()
+		return write(ctx, file, pl)
+	}
+}
+
+// lookup resolves a format name such as "m3u8" (case-insensitive, with or without a
+// leading dot) among infos: a registered name, or else a registered extension.
+func lookup(s string, infos []FormatInfo) (Format, bool) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSpace(s)), ".")
+	for _, info := range infos {
+		if string(info.Name) == name {
+			return info.Name, true
+		}
+	}
+	for _, info := range infos {
+		for _, ext := range info.Extensions {
+			if strings.EqualFold(strings.TrimPrefix(ext, "."), name) {
+				return info.Name, true
+			}
+		}
+	}
+	return "", false
+}
+
+func formatNames(infos []FormatInfo) string {
+	names := make([]string, len(infos))
+	for i, info := range infos {
+		names[i] = string(info.Name)
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,218 @@
+package playlistio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/eval"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Setlist is the Format of a shareable set: one JSON document bundling the ordered
+// tracks with how they were made (a Manifest), the set's score, and a note on every
+// transition, sealed with a digest of the rest so a reader can tell it hasn't been
+// edited since.
+const Setlist Format = "setlist"
+
+const (
+	setlistMarker  = "magicmix-setlist"
+	setlistVersion = 1
+	manifestKey    = contextKey("playlistio.manifest")
+)
+
+// SetlistDocument is the setlist file. Digest is "sha256:" and the hex SHA-256 of the
+// document's compact JSON with Digest empty; Score and Transitions are computed from
+// Tracks as written, so rescoring the tracks read back gives the same numbers.
+type SetlistDocument struct {
+	Format      string           `json:"format"`
+	Version     int              `json:"version"`
+	Digest      string           `json:"digest"`
+	Manifest    Manifest         `json:"manifest"`
+	Score       SetlistScore     `json:"score"`
+	Transitions []TransitionNote `json:"transitions"`
+	Tracks      []Track          `json:"tracks"`
+}
+
+// Manifest records how a set was made, so another magicmix user can rerun it.
+// Options are the flags the run was given that shape the ordering, by name; Inputs
+// are the libraries read, each with the SHA-256 of its content.
+type Manifest struct {
+	Created  string            `json:"created,omitempty"` // RFC 3339
+	Strategy string            `json:"strategy,omitempty"`
+	Seed     int64             `json:"seed"`
+	Options  map[string]string `json:"options,omitempty"`
+	Inputs   []InputDigest     `json:"inputs,omitempty"`
+}
+
+// InputDigest identifies an input library by name and content.
+type InputDigest struct {
+	Name   string `json:"name"`
+	SHA256 string `json:"sha256"`
+}
+
+// SetlistScore is the set's score on both of magicmix's yardsticks: the mix score
+// strategies optimize (strategy.ScoreMix, with its breakdown) and the DJ rubric
+// `magicmix evaluate` uses (eval.Evaluate). Lower is better.
+type SetlistScore struct {
+	Mix      float64 `json:"mix"`
+	Harmonic float64 `json:"harmonic"`
+	Tempo    float64 `json:"tempo"`
+	Valence  float64 `json:"valence"`
+	Acoustic float64 `json:"acoustic"`
+	Phrase   float64 `json:"phrase"`
+	Contour  float64 `json:"contour"`
+
+	Rubric             float64 `json:"rubric"`
+	InvalidTransitions int     `json:"invalid_transitions"`
+	BigJumps           int     `json:"big_jumps"`
+	Wraps              int     `json:"wraps"`
+}
+
+// TransitionNote describes the transition from the track at position From to the one
+// at To (1-based): its mix-score cost, its rubric cost and flags (see
+// eval.Transition.Flags), and, when the run explained its choices, why the next track
+// was placed there.
+type TransitionNote struct {
+	From   int      `json:"from"`
+	To     int      `json:"to"`
+	Cost   float64  `json:"cost"`
+	Rubric float64  `json:"rubric"`
+	Flags  []string `json:"flags,omitempty"`
+	Why    string   `json:"why,omitempty"`
+}
+
+// WithManifest has the setlist writer record m as the set's provenance.
+func WithManifest(ctx context.Context, m Manifest) context.Context {
+	return context.WithValue(ctx, manifestKey, m)
+}
+
+func manifestFromContext(ctx context.Context) Manifest {
+	m, _ := ctx.Value(manifestKey).(Manifest)
+	return m
+}
+
+// NewSetlist builds the setlist document for pl's tracks. A column of pl.Extra named
+// "Why" gives the reason each track was placed.
+func NewSetlist(ctx context.Context, pl csvio.Playlist, m Manifest) (SetlistDocument, error) {
+	doc := SetlistDocument{Format: setlistMarker, Version: setlistVersion, Manifest: m, Tracks: JSONTracks(pl.Tracks)}
+
+	// Score the tracks as a reader will see them.
+	tracks, err := setlistTracks(ctx, doc.Tracks)
+	if err != nil {
+		return SetlistDocument{}, err
+	}
+	mix := strategy.ScoreMix(tracks)
+	rubric := eval.Evaluate(tracks)
+	doc.Score = SetlistScore{Mix: mix.Total, Harmonic: mix.HarmonicTotal, Tempo: mix.TempoTotal,
+		Valence: mix.ValenceTotal, Acoustic: mix.AcousticTotal, Phrase: mix.PhraseTotal, Contour: mix.ContourTotal,
+		Rubric: rubric.Total, InvalidTransitions: rubric.InvalidTransitions, BigJumps: rubric.BigJumps, Wraps: rubric.Wraps}
+
+	var why []string
+	for _, col := range pl.Extra {
+		if strings.EqualFold(col.Name, "Why") {
+			why = col.Values
+		}
+	}
+	doc.Transitions = make([]TransitionNote, len(rubric.Transitions))
+	for i, tr := range rubric.Transitions {
+		note := TransitionNote{From: i + 1, To: i + 2, Cost: strategy.TransitionCost(tracks[i], tracks[i+1], strategy.DefaultWeights),
+			Rubric: tr.Total, Flags: tr.Flags()}
+		if i+1 < len(why) {
+			note.Why = why[i+1]
+		}
+		doc.Transitions[i] = note
+	}
+	doc.Digest, err = setlistDigest(doc)
+	return doc, err
+}
+
+// WriteSetlist writes pl as an indented setlist document with the manifest set by
+// WithManifest.
+func WriteSetlist(ctx context.Context, w io.Writer, pl csvio.Playlist) error {
+	doc, err := NewSetlist(ctx, pl, manifestFromContext(ctx))
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(doc)
+}
+
+// ReadSetlist reads a setlist document and checks its digest, so a set edited since
+// magicmix wrote it is refused rather than trusted.
+func ReadSetlist(data []byte) (SetlistDocument, error) {
+	var doc SetlistDocument
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return SetlistDocument{}, fmt.Errorf("read setlist: %w", err)
+	}
+	if doc.Format != setlistMarker {
+		return SetlistDocument{}, errors.New("read setlist: not a magicmix setlist")
+	}
+	if doc.Version > setlistVersion {
+		return SetlistDocument{}, fmt.Errorf("read setlist: version %d needs a newer magicmix (this one reads up to %d)",
+			doc.Version, setlistVersion)
+	}
+	want, err := setlistDigest(doc)
+	if err != nil {
+		return SetlistDocument{}, err
+	}
+	if doc.Digest != want {
+		return SetlistDocument{}, errors.New("read setlist: the digest doesn't match; the file was changed after magicmix wrote it")
+	}
+	return doc, nil
+}
+
+// ParseSetlist reads a setlist's tracks, in order, after checking it (see ReadSetlist).
+func ParseSetlist(ctx context.Context, data []byte) (csvio.Playlist, error) {
+	doc, err := ReadSetlist(data)
+	if err != nil {
+		return csvio.Playlist{}, err
+	}
+	raw, err := json.Marshal(doc.Tracks)
+	if err != nil {
+		return csvio.Playlist{}, err
+	}
+	return ParseJSON(ctx, raw)
+}
+
+// ReadTracks reads the setlist's tracks back as ParseSetlist does.
+func (doc SetlistDocument) ReadTracks(ctx context.Context) ([]track.Track, error) {
+	return setlistTracks(ctx, doc.Tracks)
+}
+
+func setlistTracks(ctx context.Context, tracks []Track) ([]track.Track, error) {
+	raw, err := json.Marshal(tracks)
+	if err != nil {
+		return nil, err
+	}
+	pl, err := ParseJSON(ctx, raw)
+	return pl.Tracks, err
+}
+
+// setlistDigest is the digest doc should carry.
+func setlistDigest(doc SetlistDocument) (string, error) {
+	doc.Digest = ""
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return "sha256:" + hex.EncodeToString(sum[:]), nil
+}
+
+// isSetlist reports whether data, a JSON object, is a setlist document.
+func isSetlist(data []byte) bool {
+	var head struct {
+		Format string `json:"format"`
+	}
+	return bytes.HasPrefix(data, []byte("{")) && json.Unmarshal(data, &head) == nil && head.Format == setlistMarker
+}
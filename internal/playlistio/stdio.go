@@ -0,0 +1,80 @@
+package playlistio
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+)
+
+// Stdio is the path that means standard input when reading and standard output when
+// writing, so magicmix can sit in a shell pipeline.
+const Stdio = "-"
+
+type contextKey string
+
+const (
+	stdinContextKey  contextKey = "playlistio.stdin"
+	stdoutContextKey contextKey = "playlistio.stdout"
+)
+
+// WithStdin makes data what reading Stdio returns, in place of the process's standard
+// input. Load uses it to hand a reader input it has already read to detect the format;
+// a caller that needs standard input more than once can read it up front the same way.
+func WithStdin(ctx context.Context, data []byte) context.Context {
+	return context.WithValue(ctx, stdinContextKey, data)
+}
+
+// WithStdout sends what is written to Stdio to w in place of the process's standard
+// output.
+func WithStdout(ctx context.Context, w io.Writer) context.Context {
+	return context.WithValue(ctx, stdoutContextKey, w)
+}
+
+// Stdout is where writing to Stdio goes: the writer set by WithStdout, or os.Stdout.
+func Stdout(ctx context.Context) io.Writer {
+	if w, ok := ctx.Value(stdoutContextKey).(io.Writer); ok {
+		return w
+	}
+	return os.Stdout
+}
+
+// ReadAll reads the file at path, or standard input (see WithStdin) when path is
+// Stdio. Readers use it so that "-" works for every format.
+func ReadAll(ctx context.Context, path string) ([]byte, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if path != Stdio {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("open input: %w", err)
+		}
+		return data, nil
+	}
+	if data, ok := ctx.Value(stdinContextKey).([]byte); ok {
+		return data, nil
+	}
+	data, err := io.ReadAll(os.Stdin)
+	if err != nil {
+		return nil, fmt.Errorf("read standard input: %w", err)
+	}
+	return data, nil
+}
+
+// Detect guesses the format of a library from its content: a Rekordbox collection
+// starts with "<", JSON with "[" or "{" (a setlist being a JSON object that says it is
+// one), and anything else is read as CSV.
+func Detect(data []byte) Format {
+	data = bytes.TrimLeft(bytes.TrimPrefix(data, []byte("\ufeff")), " \t\r\n")
+	switch {
+	case bytes.HasPrefix(data, []byte("<")):
+		return Rekordbox
+	case isSetlist(data):
+		return Setlist
+	case bytes.HasPrefix(data, []byte("[")), bytes.HasPrefix(data, []byte("{")):
+		return JSON
+	}
+	return CSV
+}
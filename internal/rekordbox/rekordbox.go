@@ -0,0 +1,344 @@
+// Package rekordbox reads and writes Rekordbox XML collections (File > Export
+// Collection in xml format). Reading maps each COLLECTION/TRACK into a track.Track;
+// writing emits a collection of the ordered tracks and a playlist node listing them in
+// order, which Rekordbox imports from its XML bridge.
+package rekordbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math"
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultEnergyField is the TRACK attribute energy is read from when Options leaves it
+// empty. Mixed In Key and most taggers write energy into the comments.
+const DefaultEnergyField = "Comments"
+
+// ratingMax is Rekordbox's five-star rating: 0-255 in steps of 51.
+const ratingMax = 255
+
+// Options controls how a collection is read.
+type Options struct {
+	// EnergyField names the TRACK attribute holding each track's energy, such as
+	// "Comments", "Grouping", or "Rating" (case-insensitive). Text fields are read as
+	// "Energy 7" or a bare number, 1-10 scaled to 10-100; Rating maps stars to 0-100.
+	// Tracks with no readable energy get an inferred one, marked EnergyInferred.
+	EnergyField string
+}
+
+// document is the subset of DJ_PLAYLISTS magicmix reads and writes.
+type document struct {
+	XMLName    xml.Name     `xml:"DJ_PLAYLISTS"`
+	Version    string       `xml:"Version,attr"`
+	Product    *product     `xml:"PRODUCT"`
+	Collection collection   `xml:"COLLECTION"`
+	Playlists  *playlistSet `xml:"PLAYLISTS"`
+}
+
+type product struct {
+	Name    string `xml:"Name,attr"`
+	Version string `xml:"Version,attr"`
+	Company string `xml:"Company,attr"`
+}
+
+type collection struct {
+	Entries int           `xml:"Entries,attr"`
+	Tracks  []trackRecord `xml:"TRACK"`
+}
+
+// trackRecord keeps every attribute of a TRACK, so the energy field can be any of them,
+// and its POSITION_MARKs: the hot cues, memory cues, and loops set on it.
+type trackRecord struct {
+	Attrs []xml.Attr `xml:",any,attr"`
+	Marks []struct{} `xml:"POSITION_MARK"`
+}
+
+func (r trackRecord) attr(name string) string {
+	for _, a := range r.Attrs {
+		if strings.EqualFold(a.Name.Local, name) {
+			return strings.TrimSpace(a.Value)
+		}
+	}
+	return ""
+}
+
+type playlistSet struct {
+	Root node `xml:"NODE"`
+}
+
+type node struct {
+	Type    int        `xml:"Type,attr"`
+	Name    string     `xml:"Name,attr"`
+	Count   *int       `xml:"Count,attr"`
+	KeyType *int       `xml:"KeyType,attr"`
+	Entries *int       `xml:"Entries,attr"`
+	Nodes   []node     `xml:"NODE"`
+	Tracks  []entryRef `xml:"TRACK"`
+}
+
+type entryRef struct {
+	Key string `xml:"Key,attr"`
+}
+
+// Load reads a Rekordbox XML collection from disk.
+func Load(ctx context.Context, path string, opts Options) (csvio.Playlist, error) {
+	if err := ctx.Err(); err != nil {
+		return csvio.Playlist{}, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return csvio.Playlist{}, fmt.Errorf("open input: %w", err)
+	}
+	return Parse(ctx, data, opts)
+}
+
+// Parse is Load for XML content already in memory. Every track in the collection is
+// read, in collection order; tracks with no usable key are skipped with a warning,
+// since every strategy needs one.
+func Parse(ctx context.Context, data []byte, opts Options) (csvio.Playlist, error) {
+	if err := ctx.Err(); err != nil {
+		return csvio.Playlist{}, err
+	}
+	var doc document
+	if err := xml.NewDecoder(bytes.NewReader(data)).Decode(&doc); err != nil {
+		return csvio.Playlist{}, fmt.Errorf("read rekordbox xml: %w", err)
+	}
+	field := opts.EnergyField
+	if field == "" {
+		field = DefaultEnergyField
+	}
+
+	// An export without a single mark most likely left them out, so cue counts are
+	// only read when some track has one.
+	marked := slices.ContainsFunc(doc.Collection.Tracks, func(rec trackRecord) bool { return len(rec.Marks) > 0 })
+
+	var pl csvio.Playlist
+	for i, rec := range doc.Collection.Tracks {
+		t, warnings, err := recordToTrack(rec, field)
+		for _, w := range warnings {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("track %d (%s): %s", i+1, describe(rec), w))
+		}
+		if err != nil {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("track %d (%s): skipped: %v", i+1, describe(rec), err))
+			continue
+		}
+		if marked {
+			cues := len(rec.Marks)
+			t.Cues = &cues
+		}
+		pl.Tracks = append(pl.Tracks, t)
+	}
+	for _, issue := range track.ValidateAll(pl.Tracks) {
+		if issue.Severity == track.SeverityWarning {
+			pl.Warnings = append(pl.Warnings, fmt.Sprintf("track %d: %s", issue.Index+1, issue.Message))
+		}
+	}
+	return pl, nil
+}
+
+func describe(rec trackRecord) string {
+	if artist := rec.attr("Artist"); artist != "" {
+		return artist + " - " + rec.attr("Name")
+	}
+	return rec.attr("Name")
+}
+
+func recordToTrack(rec trackRecord, energyField string) (track.Track, []string, error) {
+	var warnings []string
+	tonality := rec.attr("Tonality")
+	if tonality == "" {
+		return track.Track{}, nil, fmt.Errorf("no key (Tonality)")
+	}
+	key, err := track.ParseKey(tonality)
+	if err != nil {
+		return track.Track{}, nil, err
+	}
+	t := track.Track{Title: rec.attr("Name"), Artist: rec.attr("Artist"), Key: key, Genre: rec.attr("Genre"),
+		Album: rec.attr("Album")}
+
+	if s := rec.attr("AverageBpm"); s != "" {
+		if t.BPM, err = strconv.ParseFloat(s, 64); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignored invalid AverageBpm %q", s))
+		}
+	}
+	t.Duration = positive(rec.attr("TotalTime"))
+	t.Year = positive(rec.attr("Year"))
+	if s := rec.attr("DateAdded"); s != "" {
+		if added, err := time.Parse(time.DateOnly, s); err == nil {
+			t.Added = &added
+		}
+	}
+	t.Location = locationPath(rec.attr("Location"))
+	if !strings.EqualFold(energyField, "Rating") {
+		t.Priority = stars(rec.attr("Rating"))
+	}
+	if s := rec.attr("Colour"); s != "" {
+		if t.Color, err = track.ParseColor(s); err != nil {
+			warnings = append(warnings, fmt.Sprintf("ignored invalid Colour %q", s))
+		}
+	}
+
+	if energy, ok := parseEnergy(energyField, rec.attr(energyField)); ok {
+		t.Energy = energy
+	} else {
+		t.Energy = track.InferEnergy(t)
+		t.EnergyInferred = true
+	}
+	return t, warnings, nil
+}
+
+func positive(s string) *int {
+	v, err := strconv.Atoi(s)
+	if err != nil || v <= 0 {
+		return nil
+	}
+	return &v
+}
+
+// ratingPerStar is Rekordbox's Rating step: 0 for none, 51 for one star, up to 255
+// for five.
+const ratingPerStar = 51
+
+// stars turns a Rating into a 1-5 priority, or nil for an unrated track.
+func stars(s string) *int {
+	v := positive(s)
+	if v == nil {
+		return nil
+	}
+	n := min(5, max(1, (*v+ratingPerStar/2)/ratingPerStar))
+	return &n
+}
+
+var (
+	labelledEnergy = regexp.MustCompile(`(?i)\benergy\s*[:=]?\s*(\d{1,3})\b`)
+	bareEnergy     = regexp.MustCompile(`^\d{1,3}$`)
+)
+
+// parseEnergy reads an energy from the value of the energy field. Rating is stars
+// (0-255) scaled to 0-100, unrated meaning unknown; any other field is text holding
+// "Energy 7" (as Mixed In Key writes it) or just a number, where 1-10 is scaled to
+// 10-100.
+func parseEnergy(field, value string) (int, bool) {
+	if value == "" {
+		return 0, false
+	}
+	if strings.EqualFold(field, "Rating") {
+		v, err := strconv.Atoi(value)
+		if err != nil || v <= 0 || v > ratingMax {
+			return 0, false
+		}
+		return int(math.Round(float64(v) * 100 / ratingMax)), true
+	}
+	digits := ""
+	if m := labelledEnergy.FindStringSubmatch(value); m != nil {
+		digits = m[1]
+	} else if bareEnergy.MatchString(value) {
+		digits = value
+	}
+	v, err := strconv.Atoi(digits)
+	if err != nil || v < 0 || v > 100 {
+		return 0, false
+	}
+	if v <= 10 {
+		v *= 10
+	}
+	return v, true
+}
+
+// locationPath turns a Rekordbox Location URL (file://localhost/Users/me/a%20b.mp3)
+// into a plain file path. Windows drive paths lose the URL's leading slash. Anything
+// that isn't a file URL is kept as written.
+func locationPath(loc string) string {
+	u, err := url.Parse(loc)
+	if err != nil || u.Scheme != "file" {
+		return loc
+	}
+	p := u.Path
+	if len(p) > 2 && p[0] == '/' && p[2] == ':' {
+		p = p[1:]
+	}
+	return p
+}
+
+// locationURL is the inverse of locationPath: a file URL for a path, or the location
+// unchanged when it already has a scheme.
+func locationURL(loc string) string {
+	if loc == "" || strings.Contains(loc, "://") {
+		return loc
+	}
+	p := strings.ReplaceAll(loc, `\`, "/")
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return "file://localhost" + (&url.URL{Path: p}).EscapedPath()
+}
+
+// Write writes tracks as a Rekordbox XML collection with one playlist, named name,
+// holding them in order. Energy has no Rekordbox attribute, so it is not written, and
+// neither are cue counts, which Rekordbox keeps as the cues themselves.
+func Write(w io.Writer, tracks []track.Track, name string) error {
+	count, entries, keyType := 1, len(tracks), 0
+	list := node{Type: 1, Name: name, KeyType: &keyType, Entries: &entries}
+	doc := document{
+		Version:    "1.0.0",
+		Product:    &product{Name: "magicmix"},
+		Collection: collection{Entries: len(tracks)},
+		Playlists:  &playlistSet{Root: node{Type: 0, Name: "ROOT", Count: &count, Nodes: []node{list}}},
+	}
+	for i, t := range tracks {
+		id := strconv.Itoa(i + 1)
+		attrs := []xml.Attr{
+			{Name: xml.Name{Local: "TrackID"}, Value: id},
+			{Name: xml.Name{Local: "Name"}, Value: t.Title},
+			{Name: xml.Name{Local: "Artist"}, Value: t.Artist},
+			{Name: xml.Name{Local: "Genre"}, Value: t.Genre},
+			{Name: xml.Name{Local: "Album"}, Value: t.Album},
+			{Name: xml.Name{Local: "AverageBpm"}, Value: strconv.FormatFloat(t.BPM, 'f', 2, 64)},
+			{Name: xml.Name{Local: "Tonality"}, Value: t.Key.String()},
+		}
+		if t.Duration != nil {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "TotalTime"}, Value: strconv.Itoa(*t.Duration)})
+		}
+		if t.Year != nil {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "Year"}, Value: strconv.Itoa(*t.Year)})
+		}
+		if t.Added != nil {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "DateAdded"}, Value: t.Added.Format(time.DateOnly)})
+		}
+		if t.Location != "" {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "Location"}, Value: locationURL(t.Location)})
+		}
+		if t.Priority != nil {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "Rating"}, Value: strconv.Itoa(*t.Priority * ratingPerStar)})
+		}
+		if rgb, ok := track.ColorRGB(t.Color); ok {
+			attrs = append(attrs, xml.Attr{Name: xml.Name{Local: "Colour"}, Value: fmt.Sprintf("0x%06X", rgb)})
+		}
+		doc.Collection.Tracks = append(doc.Collection.Tracks, trackRecord{Attrs: attrs})
+		doc.Playlists.Root.Nodes[0].Tracks = append(doc.Playlists.Root.Nodes[0].Tracks, entryRef{Key: id})
+	}
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+	if err := enc.Encode(doc); err != nil {
+		return fmt.Errorf("write rekordbox xml: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
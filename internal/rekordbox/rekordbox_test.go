@@ -0,0 +1,139 @@
+package rekordbox
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+)
+
+const collectionXML = `<?xml version="1.0" encoding="UTF-8"?>
+<DJ_PLAYLISTS Version="1.0.0">
+  <PRODUCT Name="rekordbox" Version="6.8.5" Company="AlphaTheta"/>
+  <COLLECTION Entries="4">
+    <TRACK TrackID="10" Name="Opening" Artist="Ana" Genre="House" AverageBpm="122.00" Tonality="Am"
+      TotalTime="360" Year="2021" DateAdded="2024-03-05" Comments="8A - Energy 6" Rating="102"
+      Location="file://localhost/Users/me/Music/Ana%20-%20Opening.mp3">
+      <TEMPO Inizio="0.025" Bpm="122.00" Metro="4/4" Battito="1"/>
+      <POSITION_MARK Name="" Type="0" Start="0.025" Num="0"/>
+      <POSITION_MARK Name="drop" Type="0" Start="61.2" Num="1"/>
+      <POSITION_MARK Name="" Type="0" Start="120.4" Num="-1"/>
+    </TRACK>
+    <TRACK TrackID="11" Name="Second" Artist="Bo" AverageBpm="124.00" Tonality="9B" Comments="72"
+      Location="file://localhost/C:/Music/Second.mp3"/>
+    <TRACK TrackID="12" Name="Unknown" Artist="Cy" AverageBpm="126.00" Tonality="" Comments="Energy 5"/>
+    <TRACK TrackID="13" Name="Untagged" Artist="Di" AverageBpm="128.00" Tonality="F#m" Comments="great closer"/>
+  </COLLECTION>
+</DJ_PLAYLISTS>
+`
+
+func TestParse(t *testing.T) {
+	pl, err := Parse(context.Background(), []byte(collectionXML), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pl.Tracks) != 3 {
+		t.Fatalf("got %d tracks, want 3 (the keyless one skipped)", len(pl.Tracks))
+	}
+	first := pl.Tracks[0]
+	if first.Title != "Opening" || first.Artist != "Ana" || first.BPM != 122 || first.Key.String() != "8A" {
+		t.Errorf("first track = %+v", first)
+	}
+	if first.Energy != 60 || first.EnergyInferred {
+		t.Errorf("energy = %d (inferred %v), want 60 from the comments", first.Energy, first.EnergyInferred)
+	}
+	if first.Duration == nil || *first.Duration != 360 || first.Year == nil || *first.Year != 2021 || first.Added == nil {
+		t.Errorf("optional fields not read: %+v", first)
+	}
+	if first.Priority == nil || *first.Priority != 2 || pl.Tracks[1].Priority != nil {
+		t.Errorf("priority should be the Rating's stars: %v, %v", first.Priority, pl.Tracks[1].Priority)
+	}
+	if first.Cues == nil || *first.Cues != 3 || pl.Tracks[1].Cues == nil || *pl.Tracks[1].Cues != 0 {
+		t.Errorf("cues should count the POSITION_MARKs: %v, %v", first.Cues, pl.Tracks[1].Cues)
+	}
+	if first.Location != "/Users/me/Music/Ana - Opening.mp3" {
+		t.Errorf("location = %q", first.Location)
+	}
+	if got := pl.Tracks[1]; got.Energy != 72 || got.Location != "C:/Music/Second.mp3" {
+		t.Errorf("second track energy %d location %q", got.Energy, got.Location)
+	}
+	if got := pl.Tracks[2]; !got.EnergyInferred || got.Key.String() != "11A" {
+		t.Errorf("untagged track should have inferred energy and key 11A: %+v", got)
+	}
+	if !strings.Contains(strings.Join(pl.Warnings, "\n"), "Cy - Unknown): skipped") {
+		t.Errorf("expected a warning for the keyless track, got %q", pl.Warnings)
+	}
+}
+
+func TestParseEnergyField(t *testing.T) {
+	pl, err := Parse(context.Background(), []byte(collectionXML), Options{EnergyField: "rating"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pl.Tracks[0].Energy != 40 {
+		t.Errorf("two stars should be energy 40, got %d", pl.Tracks[0].Energy)
+	}
+	if !pl.Tracks[1].EnergyInferred {
+		t.Error("an unrated track should have inferred energy")
+	}
+}
+
+func TestParseEnergy(t *testing.T) {
+	tests := []struct {
+		field, value string
+		want         int
+		ok           bool
+	}{
+		{"Comments", "Energy 7", 70, true},
+		{"Comments", "1A - energy: 3 - peak", 30, true},
+		{"Comments", "85", 85, true},
+		{"Grouping", "10", 100, true},
+		{"Comments", "track 4 of 12", 0, false},
+		{"Comments", "", 0, false},
+		{"Rating", "255", 100, true},
+		{"Rating", "0", 0, false},
+	}
+	for _, tt := range tests {
+		got, ok := parseEnergy(tt.field, tt.value)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("parseEnergy(%q, %q) = %d, %v; want %d, %v", tt.field, tt.value, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestWriteRoundTrip(t *testing.T) {
+	pl, err := Parse(context.Background(), []byte(collectionXML), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Write(&buf, pl.Tracks, "Friday set"); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<NODE Type="1" Name="Friday set" KeyType="0" Entries="3">`,
+		`Location="file://localhost/Users/me/Music/Ana%20-%20Opening.mp3"`,
+		`Location="file://localhost/C:/Music/Second.mp3"`,
+		`Tonality="8A"`,
+		`Rating="102"`,
+		`<TRACK Key="3"></TRACK>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %s:\n%s", want, out)
+		}
+	}
+
+	back, err := Parse(context.Background(), buf.Bytes(), Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(back.Tracks) != 3 {
+		t.Fatalf("round trip read %d tracks, want 3", len(back.Tracks))
+	}
+	for i, tr := range back.Tracks {
+		if tr.Title != pl.Tracks[i].Title || tr.Key != pl.Tracks[i].Key || tr.Location != pl.Tracks[i].Location {
+			t.Errorf("track %d changed in the round trip: %+v", i, tr)
+		}
+	}
+}
@@ -0,0 +1,43 @@
+package strategy
+
+import (
+	"cmp"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Alternative is a track that could have followed the previous one instead of the
+// pick, with the pairwise coherence cost of that transition. Spare marks a track the
+// set left out, rather than one it plays later.
+type Alternative struct {
+	Track track.Track
+	Cost  float64
+	Spare bool
+}
+
+// NextBest lists, for each placement of an ordering, the n tracks that follow the
+// previous track most smoothly among those still unplayed at that point: the set's
+// later tracks and the spare ones it left out. Like PlacementConfidence it is
+// index-aligned with ordered, and the first track, with no previous one, has none.
+// Each list is cheapest first, so when a pick sounds wrong in rehearsal the first
+// entries are what to try in its place.
+func NextBest(ordered, spare []track.Track, n int) [][]Alternative {
+	out := make([][]Alternative, len(ordered))
+	if n <= 0 {
+		return out
+	}
+	for i := 1; i < len(ordered); i++ {
+		prev := ordered[i-1]
+		alts := make([]Alternative, 0, len(ordered)-i-1+len(spare))
+		for _, t := range ordered[i+1:] {
+			alts = append(alts, Alternative{Track: t, Cost: coherenceCost(prev, t, DefaultWeights)})
+		}
+		for _, t := range spare {
+			alts = append(alts, Alternative{Track: t, Cost: coherenceCost(prev, t, DefaultWeights), Spare: true})
+		}
+		slices.SortStableFunc(alts, func(a, b Alternative) int { return cmp.Compare(a.Cost, b.Cost) })
+		out[i] = alts[:min(n, len(alts))]
+	}
+	return out
+}
@@ -0,0 +1,37 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestNextBestListsUnplayedTracksCheapestFirst(t *testing.T) {
+	mk := func(title string, num int) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}}
+	}
+	ordered := []track.Track{mk("start", 8), mk("far", 2), mk("near", 9), mk("last", 10)}
+	spare := []track.Track{mk("spare", 8)}
+
+	next := NextBest(ordered, spare, 2)
+	if len(next) != len(ordered) || next[0] != nil {
+		t.Fatalf("want no alternatives for the opener, aligned with the set: %+v", next)
+	}
+	if len(next[1]) != 2 || next[1][0].Track.Title != "spare" || !next[1][0].Spare || next[1][1].Track.Title != "near" {
+		t.Fatalf("after %q: %+v, want the spare 8A, then 9A", "start", next[1])
+	}
+	if next[1][0].Cost > next[1][1].Cost {
+		t.Errorf("alternatives not cheapest first: %+v", next[1])
+	}
+	for _, alt := range next[2] {
+		if alt.Track.Title == "start" || alt.Track.Title == "far" || alt.Track.Title == "near" {
+			t.Errorf("%q was already played or is the pick: %+v", alt.Track.Title, next[2])
+		}
+	}
+	if len(next[3]) != 1 || next[3][0].Track.Title != "spare" {
+		t.Errorf("before the last track only the spare is left: %+v", next[3])
+	}
+	if got := NextBest(ordered, spare, 0); got[1] != nil {
+		t.Errorf("n = 0 lists alternatives: %+v", got)
+	}
+}
@@ -0,0 +1,247 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const annealStrategyName = "anneal"
+
+const annealBudgetContextKey contextKey = "strategy.annealBudget"
+
+// Annealing defaults: iterations per track, capped so large libraries stay quick, and
+// the final temperature as a fraction of the starting one.
+const (
+	annealItersPerTrack = 5000
+	annealMaxIters      = 500000
+	annealCooling       = 1e-3
+	annealCheckEvery    = 1024
+)
+
+// AnnealBudget bounds how long the anneal strategy searches; it cools on whichever
+// limit runs out first. Zero fields use the defaults: Iterations scales with the set
+// size, and Duration is unlimited (the context's deadline still applies). When the
+// budget runs out the best ordering found so far is polished and returned; only the
+// context's deadline makes a result partial.
+type AnnealBudget struct {
+	Iterations int
+	Duration   time.Duration
+}
+
+// WithAnnealBudget sets the anneal strategy's search budget.
+func WithAnnealBudget(ctx context.Context, budget AnnealBudget) context.Context {
+	return context.WithValue(ctx, annealBudgetContextKey, budget)
+}
+
+func annealBudgetFromContext(ctx context.Context) AnnealBudget {
+	if ctx == nil {
+		return AnnealBudget{}
+	}
+	budget, _ := ctx.Value(annealBudgetContextKey).(AnnealBudget)
+	return budget
+}
+
+// AnnealSorter minimizes the same score as FlowSorter, but where flow's local search
+// only accepts improvements, annealing also accepts some worsening moves early on, so
+// it can climb out of the corners a greedy start paints itself into on long sets. It
+// starts from flow's best greedy path, makes random 2-opt and or-opt moves under a
+// cooling temperature, and finishes with flow's local search on the best ordering seen.
+type AnnealSorter struct {
+	weights Weights
+}
+
+func NewAnnealSorter() *AnnealSorter {
+	return &AnnealSorter{weights: DefaultWeights}
+}
+
+func (s *AnnealSorter) Name() string {
+	return annealStrategyName
+}
+
+func (s *AnnealSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.Track, error) {
+	n := len(tracks)
+	seq := make([]track.Track, n)
+	for i, t := range tracks {
+		seq[i] = t.Clone()
+	}
+	if n <= 2 {
+		return seq, nil
+	}
+
+	seed, ok := seedFromContext(ctx)
+	if !ok || seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed))
+
+	matrix := buildCostMatrix(seq, s.weights)
+	matrix.rules = bindRules(ctx, seq)
+	matrix.progress = progressFromContext(ctx)
+
+	budget := annealBudgetFromContext(ctx)
+	checkpoints := checkpointsFromContext(ctx)
+	var st annealState
+	if cp := checkpoints.Resume; cp != nil {
+		if err := cp.matches(annealStrategyName, seq); err != nil {
+			return nil, err
+		}
+		seed, st = cp.Seed, annealState{
+			cur:     append([]int(nil), cp.Current...),
+			best:    append([]int(nil), cp.Best...),
+			k:       cp.Iteration,
+			iters:   cp.Iterations,
+			t0:      cp.Temperature,
+			elapsed: time.Duration(cp.ElapsedSeconds * float64(time.Second)),
+		}
+	} else {
+		st = matrix.newAnnealState(matrix.bestGreedy(chooseStarts(seq, rng)), budget, rng)
+	}
+	var save func(*annealState)
+	if checkpoints.Save != nil {
+		ids := trackIDs(seq)
+		save = func(st *annealState) {
+			checkpoints.Save(Checkpoint{
+				Strategy: annealStrategyName, Seed: seed, Tracks: ids,
+				Best: st.best, Current: st.cur, Iteration: st.k, Iterations: st.iters,
+				Temperature: st.t0, ElapsedSeconds: st.elapsed.Seconds(),
+			})
+		}
+	}
+
+	err := matrix.anneal(ctx, &st, budget, seed, checkpoints.Every, save)
+	perm := st.best
+	if err == nil {
+		perm, err = matrix.localSearch(ctx, perm)
+	}
+
+	out := make([]track.Track, n)
+	for i, idx := range perm {
+		out[i] = seq[idx]
+	}
+	if err != nil {
+		return nil, partial(out, err)
+	}
+	return out, nil
+}
+
+// annealState is where a run of simulated annealing stands: the current and best
+// orderings, k of iters iterations done, the starting temperature, and the time spent.
+// A Checkpoint carries it from one run to the next.
+type annealState struct {
+	cur, best []int
+	k, iters  int
+	t0        float64
+	elapsed   time.Duration
+}
+
+// newAnnealState starts annealing from perm.
+func (cm *costMatrix) newAnnealState(perm []int, budget AnnealBudget, rng *rand.Rand) annealState {
+	iters := budget.Iterations
+	if iters <= 0 {
+		iters = min(annealItersPerTrack*cm.n, annealMaxIters)
+	}
+	return annealState{
+		cur:   append([]int(nil), perm...),
+		best:  append([]int(nil), perm...),
+		iters: iters,
+		t0:    cm.startTemperature(perm, cm.pathCost(perm), rng),
+	}
+}
+
+// anneal runs simulated annealing on st until its iterations or budget.Duration run
+// out, leaving the best ordering it visited in st.best. When ctx is done it stops with
+// ctx's error. The moves are drawn from an RNG reseeded from seed every
+// annealCheckEvery iterations, so a run resumed from a checkpoint makes the same moves
+// the uninterrupted run would have. save, when not nil, gets st every checkpointEvery,
+// when the search finishes, and when ctx is done.
+func (cm *costMatrix) anneal(ctx context.Context, st *annealState, budget AnnealBudget, seed int64, checkpointEvery time.Duration, save func(*annealState)) error {
+	if st.t0 <= 0 {
+		st.k = st.iters
+		return nil
+	}
+	start, lastSave := time.Now().Add(-st.elapsed), time.Now()
+	checkpoint := func() {
+		if save != nil {
+			st.elapsed, lastSave = time.Since(start), time.Now()
+			save(st)
+		}
+	}
+
+	curCost, bestCost := cm.pathCost(st.cur), cm.pathCost(st.best)
+	next := make([]int, len(st.cur))
+	steps := st.iters
+	if steps == math.MaxInt { // a time budget alone; the iterations don't measure progress
+		steps = 0
+	}
+	var rng *rand.Rand
+	// progress runs from 0 to 1 over the budget, by iterations or elapsed time,
+	// whichever is further along, and sets the temperature.
+	timeProgress := 0.0
+	for ; st.k < st.iters; st.k++ {
+		if st.k%annealCheckEvery == 0 || rng == nil {
+			if err := ctx.Err(); err != nil {
+				checkpoint()
+				return err
+			}
+			if budget.Duration > 0 {
+				if timeProgress = float64(time.Since(start)) / float64(budget.Duration); timeProgress >= 1 {
+					st.k = st.iters
+					break
+				}
+			}
+			if time.Since(lastSave) >= checkpointEvery {
+				checkpoint()
+			}
+			rng = rand.New(rand.NewSource(seed + int64(st.k)))
+			cm.progress.report(Progress{Stage: StageAnnealing, Placed: cm.n, Total: cm.n, Step: st.k, Steps: steps, Score: bestCost})
+		}
+		progress := max(float64(st.k)/float64(st.iters), timeProgress)
+		temp := st.t0 * math.Pow(annealCooling, progress)
+		randomMove(next, st.cur, rng)
+		c := cm.pathCost(next)
+		if delta := c - curCost; delta < 0 || rng.Float64() < math.Exp(-delta/temp) {
+			st.cur, next = next, st.cur
+			curCost = c
+			if curCost < bestCost-improvementEps {
+				copy(st.best, st.cur)
+				bestCost = curCost
+			}
+		}
+	}
+	checkpoint()
+	return nil
+}
+
+// startTemperature is the mean cost change of a sample of random moves from perm, so
+// a typical worsening move is accepted about a third of the time at the start.
+func (cm *costMatrix) startTemperature(perm []int, cost float64, rng *rand.Rand) float64 {
+	const samples = 100
+	scratch := make([]int, len(perm))
+	total := 0.0
+	for range samples {
+		randomMove(scratch, perm, rng)
+		total += math.Abs(cm.pathCost(scratch) - cost)
+	}
+	return total / samples
+}
+
+// randomMove writes into dst a random neighbor of src: half the time a 2-opt segment
+// reversal, otherwise an or-opt relocation of one to three tracks.
+func randomMove(dst, src []int, rng *rand.Rand) {
+	n := len(src)
+	if rng.Intn(2) == 0 {
+		i, j := rng.Intn(n), rng.Intn(n)
+		if i > j {
+			i, j = j, i
+		}
+		copy(dst, src)
+		reverseSegment(dst, i, j)
+		return
+	}
+	l := 1 + rng.Intn(min(3, n-1))
+	relocateSegment(dst, src, rng.Intn(n-l+1), l, rng.Intn(n-l+1))
+}
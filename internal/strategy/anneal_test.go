@@ -0,0 +1,116 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func annealTestTracks(n int) []track.Track {
+	rng := rand.New(rand.NewSource(3))
+	tracks := make([]track.Track, n)
+	for i := range tracks {
+		tracks[i] = track.Track{
+			Title:  fmt.Sprintf("t%02d", i),
+			BPM:    float64(100 + rng.Intn(35)),
+			Energy: 20 + rng.Intn(75),
+			Key:    track.Key{Number: 1 + rng.Intn(12), Mode: []track.Mode{track.ModeA, track.ModeB}[rng.Intn(2)]},
+		}
+	}
+	return tracks
+}
+
+func TestAnnealBeatsGreedyStart(t *testing.T) {
+	tracks := annealTestTracks(40)
+	ctx := WithAnnealBudget(WithSeed(context.Background(), 5), AnnealBudget{Iterations: 40000})
+	ordered, err := NewAnnealSorter().Sort(ctx, tracks)
+	if err != nil {
+		t.Fatalf("Sort error: %v", err)
+	}
+	if len(ordered) != len(tracks) {
+		t.Fatalf("got %d tracks, want %d", len(ordered), len(tracks))
+	}
+
+	cm := buildCostMatrix(tracks, DefaultWeights)
+	index := make(map[string]int, len(tracks))
+	for i, tr := range tracks {
+		index[tr.Title] = i
+	}
+	perm := make([]int, len(ordered))
+	for i, tr := range ordered {
+		perm[i] = index[tr.Title]
+	}
+	greedy := cm.bestGreedy(chooseStarts(tracks, rand.New(rand.NewSource(5))))
+	if got, start := cm.pathCost(perm), cm.pathCost(greedy); got > start {
+		t.Fatalf("anneal ordering (%.3f) worse than its greedy start (%.3f)", got, start)
+	}
+
+	again, err := NewAnnealSorter().Sort(ctx, tracks)
+	if err != nil {
+		t.Fatalf("Sort error: %v", err)
+	}
+	if titles(again) != titles(ordered) {
+		t.Fatalf("same seed, different orders:\n%s\n%s", titles(ordered), titles(again))
+	}
+}
+
+func TestAnnealHonorsDurationBudget(t *testing.T) {
+	tracks := annealTestTracks(60)
+	ctx := WithAnnealBudget(WithSeed(context.Background(), 1),
+		AnnealBudget{Iterations: 1 << 30, Duration: 20 * time.Millisecond})
+	done := make(chan struct{})
+	var ordered []track.Track
+	var err error
+	go func() {
+		ordered, err = NewAnnealSorter().Sort(ctx, tracks)
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("anneal ignored its duration budget")
+	}
+	if err != nil || len(ordered) != len(tracks) {
+		t.Fatalf("got %d tracks, err %v; want all %d", len(ordered), err, len(tracks))
+	}
+}
+
+func TestAnnealResumesFromCheckpoint(t *testing.T) {
+	tracks := annealTestTracks(30)
+	ctx := WithAnnealBudget(WithSeed(context.Background(), 9), AnnealBudget{Iterations: 20000})
+
+	var saved []Checkpoint
+	save := func(cp Checkpoint) {
+		cp.Best = append([]int(nil), cp.Best...)
+		cp.Current = append([]int(nil), cp.Current...)
+		saved = append(saved, cp)
+	}
+	full, err := NewAnnealSorter().Sort(WithCheckpoints(ctx, Checkpoints{Every: time.Nanosecond, Save: save}), tracks)
+	if err != nil {
+		t.Fatalf("Sort error: %v", err)
+	}
+	if len(saved) < 3 || saved[len(saved)-1].Iteration != saved[len(saved)-1].Iterations {
+		t.Fatalf("want periodic checkpoints ending with a finished one, got %d", len(saved))
+	}
+
+	mid := saved[len(saved)/2]
+	if mid.Iteration == 0 || mid.Iteration >= mid.Iterations {
+		t.Fatalf("middle checkpoint at iteration %d of %d", mid.Iteration, mid.Iterations)
+	}
+	// A different seed in the context doesn't matter: the checkpoint's carries on.
+	resumed, err := NewAnnealSorter().Sort(WithCheckpoints(WithSeed(ctx, 1), Checkpoints{Resume: &mid}), tracks)
+	if err != nil {
+		t.Fatalf("resumed Sort error: %v", err)
+	}
+	if titles(resumed) != titles(full) {
+		t.Fatalf("resumed run differs from the uninterrupted one:\n%s\n%s", titles(full), titles(resumed))
+	}
+
+	if _, err := NewAnnealSorter().Sort(WithCheckpoints(ctx, Checkpoints{Resume: &mid}), tracks[1:]); err == nil {
+		t.Fatal("resuming with a different library should fail")
+	}
+}
@@ -0,0 +1,81 @@
+package strategy
+
+import (
+	"fmt"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// bridgeKeyJumps moves key-agnostic tracks (track.Track.AnyKey) to where they heal
+// the worst key jumps: a track that clashes with nothing is wasted between two keys
+// that already mix, and earns its place between two that don't. Each move relocates
+// one such track to the position that lowers the mix score plus any soft-rule penalty
+// most, without breaking the hard rules any further, until no move helps. It returns
+// the new ordering and a note for each key-agnostic track that bridges two keys that
+// would otherwise clash.
+func bridgeKeyJumps(ordered []track.Track, rules []orderRule) ([]track.Track, []string) {
+	n := len(ordered)
+	var agnostic []int
+	for i, t := range ordered {
+		if t.AnyKey() {
+			agnostic = append(agnostic, i)
+		}
+	}
+	if len(agnostic) == 0 || len(agnostic) == n {
+		return ordered, nil
+	}
+
+	hard, soft := splitRules(rules)
+	perm := identity(n)
+	seq := make([]track.Track, n)
+	objective := func(perm []int) float64 {
+		for k, idx := range perm {
+			seq[k] = ordered[idx]
+		}
+		return mixTotal(seq, DefaultWeights) + rulesCost(soft, perm)
+	}
+	cur, hardPen := objective(perm), rulesCost(hard, perm)
+
+	scratch := make([]int, n)
+	for {
+		bestObj := cur - improvementEps
+		var best []int
+		for _, idx := range agnostic {
+			i := slices.Index(perm, idx)
+			for p := range n {
+				if p == i {
+					continue
+				}
+				relocateSegment(scratch, perm, i, 1, p)
+				if len(hard) > 0 && rulesCost(hard, scratch) > hardPen+improvementEps {
+					continue
+				}
+				if obj := objective(scratch); obj < bestObj {
+					bestObj = obj
+					best = append(best[:0], scratch...)
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		copy(perm, best)
+		cur = bestObj
+	}
+
+	out := make([]track.Track, n)
+	for k, idx := range perm {
+		out[k] = ordered[idx]
+	}
+	var notes []string
+	for k := 1; k+1 < n; k++ {
+		prev, t, next := out[k-1], out[k], out[k+1]
+		if !t.AnyKey() || prev.AnyKey() || next.AnyKey() || prev.ExitKey().Compatible(next.EntryKey()) {
+			continue
+		}
+		notes = append(notes, fmt.Sprintf("Key-agnostic %q at %d bridges %s into %s", t.Title, k+1,
+			prev.ExitKey(), next.EntryKey()))
+	}
+	return out, notes
+}
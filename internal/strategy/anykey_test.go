@@ -0,0 +1,51 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSortBridgesKeyJumpsWithAnyKeyTracks(t *testing.T) {
+	// Two runs of tracks in keys that clash (8A, 2B) and one sweep with a nominal key
+	// that fits the first run: the sweep belongs in the seam between the runs.
+	var tracks []track.Track
+	for i := range 12 {
+		key := "8A"
+		if i >= 6 {
+			key = "2B"
+		}
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("t%02d", i), BPM: 124, Energy: 40 + 4*i, Key: mustKey(key)})
+	}
+	sweep := track.Track{Title: "sweep", BPM: 124, Energy: 60, Key: mustKey("8A"), Tags: []string{track.AnyKeyTag}}
+	tracks = append(tracks, sweep)
+
+	if c := harmonicCost(NewTransition(tracks[0], sweep)) + harmonicCost(NewTransition(sweep, tracks[6])); c != 0 {
+		t.Fatalf("harmonic cost around the sweep = %g, want 0", c)
+	}
+
+	ctx := WithSeed(context.Background(), 3)
+	for _, name := range []string{defaultStrategyName, flowStrategyName, annealStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := res.Ordered
+		at := -1
+		for i, tr := range got {
+			if tr.AnyKey() {
+				at = i
+			}
+		}
+		if at <= 0 || at == len(got)-1 || got[at-1].Key.Compatible(got[at+1].Key) {
+			t.Errorf("%s: the sweep is at %d of %d, not bridging a key clash", name, at+1, len(got))
+			continue
+		}
+		if len(res.Notes) == 0 {
+			t.Errorf("%s: no note says the sweep bridges the clash", name)
+		}
+	}
+}
@@ -0,0 +1,148 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Arc tuning. An arc's levels span the library's energy from its arcLowQuantile to its
+// arcHighQuantile, so one stray track doesn't stretch the scale; a track within
+// arcTolerance of the arc's target is fine, and beyond that costs targetUnit per
+// targetTolerance of excess, as an energy target does.
+const (
+	arcLowQuantile  = 0.1
+	arcHighQuantile = 0.9
+	arcTolerance    = 12.0
+)
+
+// Arc shapes the energy of a whole set. Its target at each point is a level from 0
+// (the library's calmest tracks) to 1 (its most energetic): a line from Start to End,
+// with Waves build-and-release swells of Depth riding on it.
+type Arc struct {
+	Name        string
+	Description string
+	Start, End  float64 // levels at the first and last track
+	Waves       int     // swells across the set; 0 for a plain line
+	Depth       float64 // height of each swell, in levels
+}
+
+// arcs are the named arcs, in the order they are listed.
+var arcs = []Arc{
+	{Name: "build", Description: "rise steadily from calm to busy, for a warm-up slot", Start: 0.05, End: 0.85},
+	{Name: "peak", Description: "start high and stay high, for a peak-time slot", Start: 0.75, End: 0.95},
+	{Name: "waves", Description: "rolling builds and releases around the middle of the library", Start: 0.4, End: 0.6, Waves: 3, Depth: 0.6},
+	{Name: "closing", Description: "wind down from busy to calm, to end a night", Start: 0.85, End: 0.05},
+}
+
+// Arcs returns the named arcs.
+func Arcs() []Arc {
+	return append([]Arc(nil), arcs...)
+}
+
+// ParseArc looks up a named arc.
+func ParseArc(name string) (Arc, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	names := make([]string, len(arcs))
+	for i, a := range arcs {
+		if a.Name == name {
+			return a, nil
+		}
+		names[i] = a.Name
+	}
+	return Arc{}, fmt.Errorf("unknown arc %q (want %s)", name, strings.Join(names, ", "))
+}
+
+// Level is the arc's target level at progress through the set (0 at the first track,
+// 1 at the last), from 0 to 1. Each swell builds for its span and drops at the end.
+func (a Arc) Level(progress float64) float64 {
+	progress = math.Max(0, math.Min(1, progress))
+	level := a.Start + (a.End-a.Start)*progress
+	if a.Waves > 0 {
+		phase := progress * float64(a.Waves)
+		phase -= math.Floor(phase)
+		if progress == 1 {
+			phase = 1
+		}
+		level += a.Depth * (phase - 0.5)
+	}
+	return math.Max(0, math.Min(1, level))
+}
+
+const arcContextKey contextKey = "strategy.arc"
+
+// WithArc shapes the set's energy to a. The default strategy plans toward it in place
+// of its repeating energy cycles, the flow strategy optimizes it directly, and Sort
+// repairs any other strategy's output that strays from it.
+func WithArc(ctx context.Context, a Arc) context.Context {
+	return context.WithValue(ctx, arcContextKey, &a)
+}
+
+func arcFromContext(ctx context.Context) *Arc {
+	if ctx == nil {
+		return nil
+	}
+	a, _ := ctx.Value(arcContextKey).(*Arc)
+	return a
+}
+
+// arcScale maps an arc's levels onto energies: the arcLowQuantile and arcHighQuantile
+// energies of the tracks it shapes.
+type arcScale struct {
+	arc    Arc
+	lo, hi float64
+}
+
+func newArcScale(a Arc, tracks []track.Track) arcScale {
+	energies := make([]int, len(tracks))
+	for i, t := range tracks {
+		energies[i] = t.Energy
+	}
+	sort.Ints(energies)
+	return arcScale{arc: a, lo: quantileInts(energies, arcLowQuantile), hi: quantileInts(energies, arcHighQuantile)}
+}
+
+// energy is the arc's target energy at progress through the set.
+func (s arcScale) energy(progress float64) float64 {
+	return s.lo + (s.hi-s.lo)*s.arc.Level(progress)
+}
+
+// boundArc is an arc resolved against one track list.
+type boundArc struct {
+	scale  arcScale
+	energy []float64
+	timing *boundPlacement // reuses placement's playtime positions
+}
+
+func bindArc(tracks []track.Track, a Arc) *boundArc {
+	b := &boundArc{scale: newArcScale(a, tracks), energy: make([]float64, len(tracks)), timing: bindPlacement(tracks, nil)}
+	for i, t := range tracks {
+		b.energy[i] = float64(t.Energy)
+	}
+	return b
+}
+
+// visit calls fn with every position of perm whose track strays from the arc, and
+// the cost of straying.
+func (b *boundArc) visit(perm []int, fn func(k int, cost float64)) {
+	at := b.timing.starts(perm)
+	for k, idx := range perm {
+		if off := math.Abs(b.energy[idx]-b.scale.energy(at[k])) - arcTolerance; off > 0 {
+			fn(k, targetUnit*off/targetTolerance)
+		}
+	}
+}
+
+func (b *boundArc) cost(perm []int) float64 {
+	total := 0.0
+	b.visit(perm, func(_ int, cost float64) { total += cost })
+	return total
+}
+
+func (b *boundArc) conflicts(perm []int, out []bool) {
+	b.visit(perm, func(k int, _ float64) { out[k] = true })
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestArcShapesSetEnergy(t *testing.T) {
+	build, err := ParseArc("Build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if build.Level(0) >= build.Level(0.5) || build.Level(0.5) >= build.Level(1) {
+		t.Errorf("build arc levels %.2f, %.2f, %.2f should rise", build.Level(0), build.Level(0.5), build.Level(1))
+	}
+	waves, _ := ParseArc("waves")
+	if waves.Level(0.3) <= waves.Level(0.34) {
+		t.Errorf("waves arc should drop between swells: %.2f then %.2f", waves.Level(0.3), waves.Level(0.34))
+	}
+	if _, err := ParseArc("sideways"); err == nil {
+		t.Error("ParseArc(sideways): want an error")
+	}
+
+	var tracks []track.Track
+	for i := range 12 {
+		// Energies shuffled against key order, so the key walk alone won't sort them.
+		energy := 30 + (i*5)%12*5
+		tracks = append(tracks, track.Track{Title: "t", BPM: 124, Energy: energy,
+			Key: track.Key{Number: i%12 + 1, Mode: track.ModeA}})
+	}
+	closing, _ := ParseArc("closing")
+	for _, sorter := range []Sorter{NewDefaultSorter(), NewFlowSorter()} {
+		res, err := Sort(WithArc(WithSeed(context.Background(), 1), closing), sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: Sort: %v", sorter.Name(), err)
+		}
+		first, last := res.Ordered[:3], res.Ordered[len(res.Ordered)-3:]
+		if meanEnergy(first) <= meanEnergy(last)+20 {
+			t.Errorf("%s: closing arc should wind down, got energies %v", sorter.Name(), energies(res.Ordered))
+		}
+	}
+}
+
+func energies(tracks []track.Track) []int {
+	out := make([]int, len(tracks))
+	for i, t := range tracks {
+		out[i] = t.Energy
+	}
+	return out
+}
@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const auditContextKey contextKey = "strategy.audit"
+
+// WithDeterminismAudit has Sort run every sort twice from the same seed and input and
+// fail with a *NondeterminismError unless both give the same Result, byte for byte:
+// the same tracks in the same order, with the same notes, warnings, and explanations.
+// It guards callers who rely on a seed to reproduce a set against anything that
+// creeps in to break that, such as map iteration order or a time-based default. A
+// sort takes twice as long under audit.
+//
+// The context needs a seed (see WithSeed). A sort stopped early, or one bounded by
+// time such as an anneal budget Duration, can't repeat exactly and fails the audit.
+func WithDeterminismAudit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, auditContextKey, true)
+}
+
+func auditFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	audit, _ := ctx.Value(auditContextKey).(bool)
+	return audit
+}
+
+// NondeterminismError reports a determinism audit's failure: the two runs of Strategy
+// differ first at Position (1-based), which holds First in the first run and Second in
+// the second. Position is 0 when the orderings match and what differs is Detail, such
+// as the notes or warnings.
+type NondeterminismError struct {
+	Strategy      string
+	Position      int
+	First, Second string
+	Detail        string
+}
+
+func (e *NondeterminismError) Error() string {
+	if e.Position > 0 {
+		return fmt.Sprintf("determinism audit: the %s strategy gave two orderings from one seed: position %d is %s in the first run and %s in the second",
+			e.Strategy, e.Position, e.First, e.Second)
+	}
+	return fmt.Sprintf("determinism audit: the %s strategy gave two results from one seed: %s differ", e.Strategy, e.Detail)
+}
+
+// auditedSort is Sort under WithDeterminismAudit. Only the first run reports progress
+// and saves checkpoints; each run gets its own copy of tracks, so a sorter that
+// changed its input couldn't hide behind it.
+func auditedSort(ctx context.Context, s Sorter, tracks []track.Track) (Result, error) {
+	if _, ok := seedFromContext(ctx); !ok {
+		return Result{}, errors.New("determinism audit: no seed to repeat the sort from")
+	}
+	first, err := sortOnce(ctx, s, slices.Clone(tracks))
+	if err != nil {
+		return Result{}, err
+	}
+	again := withoutCheckpoints(context.WithValue(ctx, progressContextKey, ProgressFunc(nil)))
+	second, err := sortOnce(again, s, slices.Clone(tracks))
+	if err != nil {
+		return Result{}, err
+	}
+	if first.Partial || second.Partial {
+		return Result{}, fmt.Errorf("determinism audit: the %s strategy stopped early, so its runs can't be compared; "+
+			"allow it more time", s.Name())
+	}
+	if err := compareResults(s.Name(), first, second); err != nil {
+		return Result{}, err
+	}
+	return first, nil
+}
+
+// compareResults returns a *NondeterminismError unless a and b are the same.
+func compareResults(name string, a, b Result) error {
+	for i := range min(len(a.Ordered), len(b.Ordered)) {
+		if x, y := a.Ordered[i], b.Ordered[i]; x.ID() != y.ID() {
+			return &NondeterminismError{Strategy: name, Position: i + 1, First: describe(x), Second: describe(y)}
+		}
+	}
+	if len(a.Ordered) != len(b.Ordered) {
+		return &NondeterminismError{Strategy: name,
+			Detail: fmt.Sprintf("the track counts (%d and %d)", len(a.Ordered), len(b.Ordered))}
+	}
+	for _, part := range []struct {
+		detail string
+		a, b   any
+	}{
+		{"the tracks' fields", a.Ordered, b.Ordered},
+		{"the notes", a.Notes, b.Notes},
+		{"the warnings", a.Warnings, b.Warnings},
+		{"the explanations", a.Explanations, b.Explanations},
+		{"the confidences", a.Confidence, b.Confidence},
+	} {
+		x, errA := json.Marshal(part.a)
+		y, errB := json.Marshal(part.b)
+		if err := errors.Join(errA, errB); err != nil {
+			return fmt.Errorf("determinism audit: %w", err)
+		}
+		if !bytes.Equal(x, y) {
+			return &NondeterminismError{Strategy: name, Detail: part.detail}
+		}
+	}
+	return nil
+}
+
+// describe names t for an audit report.
+func describe(t track.Track) string {
+	return fmt.Sprintf("%q by %s", t.Title, t.Artist)
+}
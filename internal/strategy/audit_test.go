@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// flakySorter reverses its input on every other call, as a sorter with hidden state
+// might.
+type flakySorter struct{ calls int }
+
+func (*flakySorter) Name() string { return "flaky" }
+func (s *flakySorter) Sort(_ context.Context, tracks []track.Track) ([]track.Track, error) {
+	s.calls++
+	out := append([]track.Track(nil), tracks...)
+	if s.calls%2 == 0 {
+		out[0], out[len(out)-1] = out[len(out)-1], out[0]
+	}
+	return out, nil
+}
+
+func TestDeterminismAudit(t *testing.T) {
+	tracks := annealTestTracks(30)
+	ctx := WithDeterminismAudit(WithExplain(WithSeed(context.Background(), 5)))
+	for _, name := range Names() {
+		s, _ := Get(name)
+		if _, err := Sort(ctx, s, tracks); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	_, err := Sort(ctx, &flakySorter{}, tracks)
+	var nondet *NondeterminismError
+	if !errors.As(err, &nondet) || nondet.Position != 1 {
+		t.Errorf("flaky sorter: err = %v, want a NondeterminismError at position 1", err)
+	}
+
+	if _, err := Sort(WithDeterminismAudit(context.Background()), NewFlowSorter(), tracks); err == nil {
+		t.Error("an audit with no seed should fail")
+	}
+}
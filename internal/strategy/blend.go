@@ -0,0 +1,157 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Blend picks n of tracks, drawing from each source in proportion to its weight — say
+// mostly proven tracks plus a sprinkle of new promos. source[i] is the index into
+// weights of the source tracks[i] came from. Within each source's share it keeps the
+// tracks that fit the whole pool best: the pool is ordered as flow would order it, and
+// the tracks whose removal would most improve that ordering go first. A source too
+// small for its share contributes everything and the rest is split among the others.
+// Tracks the context requires (see Required) are kept ahead of the rest of their
+// source. It returns the indices of the picked tracks, in input order.
+func Blend(ctx context.Context, tracks []track.Track, source []int, weights []float64, n int) ([]int, error) {
+	if len(source) != len(tracks) {
+		return nil, fmt.Errorf("blend: %d source labels for %d tracks", len(source), len(tracks))
+	}
+	if n <= 0 || n >= len(tracks) {
+		return identity(len(tracks)), nil
+	}
+	sizes := make([]int, len(weights))
+	for _, s := range source {
+		sizes[s]++
+	}
+	quotas := blendQuotas(n, weights, sizes)
+
+	gains, err := fitGains(ctx, tracks)
+	if err != nil {
+		return nil, err
+	}
+	bySource := make([][]int, len(weights))
+	for i, s := range source {
+		bySource[s] = append(bySource[s], i)
+	}
+	// Pinned and must-include tracks go to the front of their source's share.
+	first := make([]bool, len(tracks))
+	if required := Required(ctx); required != nil {
+		for i, t := range tracks {
+			first[i] = required(t)
+		}
+	}
+	keep := make([]bool, len(tracks))
+	for s, idx := range bySource {
+		slices.SortStableFunc(idx, func(a, b int) int {
+			if first[a] != first[b] {
+				if first[a] {
+					return -1
+				}
+				return 1
+			}
+			return cmp.Compare(gains[a], gains[b])
+		})
+		for _, i := range idx[:quotas[s]] {
+			keep[i] = true
+		}
+	}
+
+	picked := make([]int, 0, n)
+	for i, k := range keep {
+		if k {
+			picked = append(picked, i)
+		}
+	}
+	return picked, nil
+}
+
+// fitGains orders tracks for flow and returns, per track, how much the ordering's score
+// would improve without it (higher fits worse).
+func fitGains(ctx context.Context, tracks []track.Track) ([]float64, error) {
+	seed, ok := seedFromContext(ctx)
+	if !ok || seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	matrix := buildCostMatrix(tracks, DefaultWeights)
+	perm, err := matrix.localSearch(ctx, matrix.bestGreedy(chooseStarts(tracks, rand.New(rand.NewSource(seed)))))
+	if err != nil {
+		return nil, err
+	}
+
+	ordered := make([]track.Track, len(perm))
+	for k, idx := range perm {
+		ordered[k] = tracks[idx]
+	}
+	base := mixTotal(ordered, DefaultWeights)
+	gains := make([]float64, len(tracks))
+	scratch := make([]track.Track, 0, len(ordered))
+	for k, idx := range perm {
+		scratch = append(append(scratch[:0], ordered[:k]...), ordered[k+1:]...)
+		gains[idx] = base - mixTotal(scratch, DefaultWeights)
+	}
+	return gains, nil
+}
+
+// blendQuotas splits n tracks among sources in proportion to weights, capping each at
+// its size and handing the excess to the uncapped sources. Fractions are rounded by
+// largest remainder, so the quotas sum to n whenever the sources hold that many.
+func blendQuotas(n int, weights []float64, sizes []int) []int {
+	quotas := make([]int, len(weights))
+	active := make([]bool, len(weights))
+	for s := range weights {
+		active[s] = weights[s] > 0 && sizes[s] > 0
+	}
+
+	left := n
+	for {
+		total := 0.0
+		for s, a := range active {
+			if a {
+				total += weights[s]
+			}
+		}
+		if total == 0 || left == 0 {
+			return quotas
+		}
+		capped := false
+		for s, a := range active {
+			if a && float64(left)*weights[s]/total >= float64(sizes[s]) {
+				quotas[s], active[s] = sizes[s], false
+				left -= sizes[s]
+				capped = true
+			}
+		}
+		if capped {
+			continue
+		}
+
+		type share struct {
+			s    int
+			frac float64
+		}
+		var shares []share
+		assigned := 0
+		for s, a := range active {
+			if !a {
+				continue
+			}
+			ideal := float64(left) * weights[s] / total
+			quotas[s] = int(math.Floor(ideal))
+			assigned += quotas[s]
+			shares = append(shares, share{s, ideal - math.Floor(ideal)})
+		}
+		slices.SortStableFunc(shares, func(a, b share) int { return cmp.Compare(b.frac, a.frac) })
+		for i := 0; i < left-assigned; i++ {
+			quotas[shares[i%len(shares)].s]++
+		}
+		return quotas
+	}
+}
@@ -0,0 +1,60 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestBlendQuotas(t *testing.T) {
+	tests := []struct {
+		n       int
+		weights []float64
+		sizes   []int
+		want    []int
+	}{
+		{10, []float64{0.7, 0.3}, []int{20, 20}, []int{7, 3}},
+		{10, []float64{1, 1, 1}, []int{20, 20, 20}, []int{4, 3, 3}},
+		// A source smaller than its share gives everything; the rest goes elsewhere.
+		{10, []float64{0.5, 0.5}, []int{20, 2}, []int{8, 2}},
+		{5, []float64{1, 0}, []int{3, 10}, []int{3, 0}},
+	}
+	for _, tc := range tests {
+		if got := blendQuotas(tc.n, tc.weights, tc.sizes); !slices.Equal(got, tc.want) {
+			t.Errorf("blendQuotas(%d, %v, %v) = %v, want %v", tc.n, tc.weights, tc.sizes, got, tc.want)
+		}
+	}
+}
+
+func TestBlendDrawsProportionallyAndKeepsBestFits(t *testing.T) {
+	mk := func(title string, num int, bpm float64) track.Track {
+		return track.Track{Title: title, BPM: bpm, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}}
+	}
+	tracks := []track.Track{
+		mk("m1", 1, 120), mk("m2", 2, 121), mk("m3", 3, 122), mk("m4", 4, 123), mk("m5", 5, 124),
+		mk("p1", 6, 125), mk("p-odd", 11, 90), mk("p2", 7, 126),
+	}
+	source := []int{0, 0, 0, 0, 0, 1, 1, 1}
+
+	picked, err := Blend(WithSeed(context.Background(), 1), tracks, source, []float64{0.5, 0.5}, 4)
+	if err != nil {
+		t.Fatalf("Blend returned error: %v", err)
+	}
+	if len(picked) != 4 || !slices.IsSorted(picked) {
+		t.Fatalf("picked = %v, want 4 indices in input order", picked)
+	}
+	fromPromos := 0
+	for _, i := range picked {
+		if source[i] == 1 {
+			fromPromos++
+		}
+		if tracks[i].Title == "p-odd" {
+			t.Fatalf("picked the promo that fits worst: %v", picked)
+		}
+	}
+	if fromPromos != 2 {
+		t.Fatalf("picked %d promos, want 2", fromPromos)
+	}
+}
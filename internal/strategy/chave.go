@@ -2,7 +2,9 @@ package strategy
 
 import (
 	"context"
+	"errors"
 	"math/rand"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -56,7 +58,7 @@ func (s *ChaveSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.T
 
 	chaves, err := composeChaves(ctx, pool, rng)
 	if err != nil {
-		return nil, err
+		return nil, partial(slices.Concat(chaves...), err)
 	}
 	out := make([]track.Track, 0, len(pool))
 	for _, ch := range chaves {
@@ -65,7 +67,8 @@ func (s *ChaveSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.T
 	return out, nil
 }
 
-// composeChaves carves the pool into themed, intensity-building chaves.
+// composeChaves carves the pool into themed, intensity-building chaves. When ctx is
+// done it returns the chaves composed so far with ctx's error.
 func composeChaves(ctx context.Context, pool []track.Track, rng *rand.Rand) ([][]track.Track, error) {
 	tagger, signals := newTagger(pool)
 	cooldown := map[string]int{}
@@ -73,7 +76,7 @@ func composeChaves(ctx context.Context, pool []track.Track, rng *rand.Rand) ([][
 
 	for len(pool) >= chaveMinSize {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return chaves, err
 		}
 		for tag := range cooldown {
 			if cooldown[tag] > 0 {
@@ -90,7 +93,7 @@ func composeChaves(ctx context.Context, pool []track.Track, rng *rand.Rand) ([][
 
 		chave, err := flowChave(ctx, wave)
 		if err != nil {
-			return nil, err
+			return chaves, err
 		}
 		chaves = append(chaves, chave)
 
@@ -103,12 +106,12 @@ func composeChaves(ctx context.Context, pool []track.Track, rng *rand.Rand) ([][
 	// Remainder: break whatever is left into bounded, flowed closing chaves.
 	for len(pool) > 0 {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return chaves, err
 		}
 		wave, leftover := drawWave(pool, rng)
 		chave, err := flowChave(ctx, wave)
 		if err != nil {
-			return nil, err
+			return chaves, err
 		}
 		chaves = append(chaves, chave)
 		pool = leftover
@@ -120,8 +123,11 @@ func composeChaves(ctx context.Context, pool []track.Track, rng *rand.Rand) ([][
 // build. It runs the flow optimizer, then falls back to a plain intensity ramp if the
 // flowed order does not build — so a chave always rises from calm to peak.
 func flowChave(ctx context.Context, wave []track.Track) ([]track.Track, error) {
-	ordered, err := NewFlowSorter().Sort(ctx, wave)
-	if err != nil {
+	ordered, err := NewFlowSorter().Sort(withoutRules(ctx), wave)
+	var stopped *PartialError
+	if errors.As(err, &stopped) {
+		ordered = stopped.Placed
+	} else if err != nil {
 		return nil, err
 	}
 	if !buildsWell(ordered) {
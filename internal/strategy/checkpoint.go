@@ -0,0 +1,112 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultCheckpointEvery is how often a long optimization saves a checkpoint when
+// Checkpoints.Every is zero.
+const DefaultCheckpointEvery = 30 * time.Second
+
+const checkpointContextKey contextKey = "strategy.checkpoints"
+
+// Checkpoint is a snapshot of a long optimization, the best ordering found so far plus
+// enough of the optimizer's state to carry on from where it stood. Orderings are
+// indices into the tracks the sort was given; Tracks holds their IDs, so a resume can
+// tell it was handed the same library in the same order. Only the anneal strategy
+// writes and resumes checkpoints.
+type Checkpoint struct {
+	Strategy string   `json:"strategy"`
+	Seed     int64    `json:"seed"`
+	Tracks   []string `json:"tracks"`
+	Best     []int    `json:"best"`
+	Current  []int    `json:"current"`
+	// Iteration of Iterations is how far the search has got; a finished search has
+	// only the final polish left.
+	Iteration      int     `json:"iteration"`
+	Iterations     int     `json:"iterations"`
+	Temperature    float64 `json:"temperature"` // the starting temperature
+	ElapsedSeconds float64 `json:"elapsed_seconds"`
+}
+
+// Checkpoints has a long optimization save its progress every Every (default
+// DefaultCheckpointEvery), when it finishes searching, and when its context is done,
+// and resume from Resume instead of starting afresh. Save must not keep the
+// Checkpoint's slices. Strategy.Sort hands checkpoints to the top-level sort only,
+// not the re-sorts it runs afterwards.
+type Checkpoints struct {
+	Every  time.Duration
+	Save   func(Checkpoint)
+	Resume *Checkpoint
+}
+
+// WithCheckpoints turns on checkpointing for strategies that support it.
+func WithCheckpoints(ctx context.Context, c Checkpoints) context.Context {
+	return context.WithValue(ctx, checkpointContextKey, c)
+}
+
+func checkpointsFromContext(ctx context.Context) Checkpoints {
+	if ctx == nil {
+		return Checkpoints{}
+	}
+	c, _ := ctx.Value(checkpointContextKey).(Checkpoints)
+	if c.Every <= 0 {
+		c.Every = DefaultCheckpointEvery
+	}
+	return c
+}
+
+// withoutCheckpoints hides checkpointing from nested sorts, whose tracks differ.
+func withoutCheckpoints(ctx context.Context) context.Context {
+	return context.WithValue(ctx, checkpointContextKey, Checkpoints{})
+}
+
+// matches reports why c can't resume a sort of tracks by the named strategy, or nil
+// when it can.
+func (c *Checkpoint) matches(strategy string, tracks []track.Track) error {
+	if c.Strategy != strategy {
+		return fmt.Errorf("checkpoint is from the %s strategy, not %s", c.Strategy, strategy)
+	}
+	if len(c.Tracks) != len(tracks) {
+		return fmt.Errorf("checkpoint has %d tracks, the library %d", len(c.Tracks), len(tracks))
+	}
+	for i, t := range tracks {
+		if c.Tracks[i] != t.ID() {
+			return fmt.Errorf("checkpoint was written for a different library: track %d (%q) doesn't match", i+1, t.Title)
+		}
+	}
+	if !isPermutation(c.Best, len(tracks)) || !isPermutation(c.Current, len(tracks)) {
+		return errors.New("checkpoint orderings are damaged")
+	}
+	if c.Iteration < 0 || c.Iteration > c.Iterations {
+		return fmt.Errorf("checkpoint iteration %d is outside 0..%d", c.Iteration, c.Iterations)
+	}
+	return nil
+}
+
+func isPermutation(perm []int, n int) bool {
+	if len(perm) != n {
+		return false
+	}
+	seen := make([]bool, n)
+	for _, i := range perm {
+		if i < 0 || i >= n || seen[i] {
+			return false
+		}
+		seen[i] = true
+	}
+	return true
+}
+
+func trackIDs(tracks []track.Track) []string {
+	ids := make([]string, len(tracks))
+	for i, t := range tracks {
+		ids[i] = t.ID()
+	}
+	return ids
+}
@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Confidence says how clearly a placement beat its alternatives. For each track after
+// the first, it compares the transition into it with the best transition the same
+// previous track could have made into any track still unplaced at that point (the
+// runner-up). A large positive margin is a robust pick; a negative one means a
+// smoother follow-up was available and the strategy gave it up for the set as a whole —
+// a compromise worth reviewing by ear.
+type Confidence struct {
+	Position int
+	Title    string
+	// Cost is the pairwise coherence cost of the transition into this track.
+	Cost float64
+	// RunnerUp is the best alternative's title and cost; empty when no track was left.
+	RunnerUp     string
+	RunnerUpCost float64
+	// Margin is RunnerUpCost - Cost: positive when the chosen track fit best.
+	Margin float64
+}
+
+// PlacementConfidence rates every placement of an ordering. The first track has no
+// previous track to compare against and is reported with a zero margin, as is the last,
+// which had no alternatives left.
+func PlacementConfidence(ordered []track.Track) []Confidence {
+	out := make([]Confidence, len(ordered))
+	for i, t := range ordered {
+		c := Confidence{Position: i, Title: t.Title}
+		if i > 0 {
+			prev := ordered[i-1]
+			c.Cost = coherenceCost(prev, t, DefaultWeights)
+			best := math.Inf(1)
+			for _, alt := range ordered[i+1:] {
+				if cost := coherenceCost(prev, alt, DefaultWeights); cost < best {
+					best, c.RunnerUp = cost, alt.Title
+				}
+			}
+			if c.RunnerUp != "" {
+				c.RunnerUpCost = best
+				c.Margin = best - c.Cost
+			}
+		}
+		out[i] = c
+	}
+	return out
+}
+
+// Compromises returns the placements whose margin is below -threshold, least
+// confident first.
+func Compromises(conf []Confidence, threshold float64) []Confidence {
+	var out []Confidence
+	for _, c := range conf {
+		if c.Margin < -threshold {
+			out = append(out, c)
+		}
+	}
+	slices.SortStableFunc(out, func(a, b Confidence) int { return cmp.Compare(a.Margin, b.Margin) })
+	return out
+}
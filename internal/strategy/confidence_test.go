@@ -0,0 +1,34 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestPlacementConfidenceFlagsPassedOverFits(t *testing.T) {
+	mk := func(title string, num int) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}}
+	}
+	// After 8A, 9A was available but 2A was played.
+	ordered := []track.Track{mk("start", 8), mk("far", 2), mk("near", 9)}
+
+	conf := PlacementConfidence(ordered)
+	if len(conf) != len(ordered) {
+		t.Fatalf("got %d ratings for %d tracks", len(conf), len(ordered))
+	}
+	if conf[0].Margin != 0 || conf[2].Margin != 0 || conf[2].RunnerUp != "" {
+		t.Fatalf("first and last placements have nothing to compare: %+v", conf)
+	}
+	if conf[1].RunnerUp != "near" || conf[1].Margin >= 0 {
+		t.Fatalf("placement of %q should lose to %q: %+v", "far", "near", conf[1])
+	}
+
+	forced := Compromises(conf, 0.1)
+	if len(forced) != 1 || forced[0].Title != "far" {
+		t.Fatalf("Compromises = %+v, want only %q", forced, "far")
+	}
+	if got := Compromises(PlacementConfidence([]track.Track{mk("start", 8), mk("near", 9), mk("far", 2)}), 0.1); len(got) != 0 {
+		t.Fatalf("a best-fit ordering reported compromises: %+v", got)
+	}
+}
@@ -22,6 +22,7 @@ type KeyBucket struct {
 // ConstanceBuckets manages 24 buckets (12 numbers x 2 modes)
 type ConstanceBuckets struct {
 	buckets map[track.Key]*KeyBucket
+	keys    []track.Key // bucket keys in wheel order, so ties break the same way every run
 	rng     *rand.Rand
 }
 
@@ -36,6 +37,7 @@ func NewConstanceBuckets(tracks []track.Track, rng *rand.Rand) *ConstanceBuckets
 	for num := 1; num <= 12; num++ {
 		for _, mode := range []track.Mode{track.ModeA, track.ModeB} {
 			key := track.Key{Number: num, Mode: mode}
+			cb.keys = append(cb.keys, key)
 			cb.buckets[key] = &KeyBucket{
 				Key:    key,
 				Tracks: []track.Track{},
@@ -91,7 +93,7 @@ func (cb *ConstanceBuckets) GetTrackFromBucket(key track.Key, prev track.Track,
 // scoreCandidateInBucket scores a track within a bucket
 func (cb *ConstanceBuckets) scoreCandidateInBucket(candidate, prev track.Track, energyState *EnergyRampState) float64 {
 	// BPM compatibility (minimize change)
-	bpmDiff := math.Abs(candidate.BPM - prev.BPM)
+	bpmDiff := math.Abs(candidate.EntryBPM() - prev.ExitBPM())
 	bpmScore := math.Max(0, 1.0-bpmDiff/20.0)
 
 	// Energy compatibility based on ramp state
@@ -329,7 +331,7 @@ func (s *ConstanceSorter) generateSingleMix(tracks []track.Track, seed int64, ta
 		}
 
 		// Track same-key runs
-		if prevTrack != nil && nextTrack.Key.Number == prevTrack.Key.Number && nextTrack.Key.Mode == prevTrack.Key.Mode {
+		if prevTrack != nil && nextTrack.Key.Number == prevTrack.ExitKey().Number && nextTrack.Key.Mode == prevTrack.ExitKey().Mode {
 			sameKeyRunCount++
 		} else {
 			sameKeyRunCount = 0
@@ -378,7 +380,8 @@ func (s *ConstanceSorter) pickBestStartingTrack(buckets *ConstanceBuckets, energ
 	found := false
 
 	// Look through all buckets for the best starting track
-	for key, bucket := range buckets.buckets {
+	for _, key := range buckets.keys {
+		bucket := buckets.buckets[key]
 		if len(bucket.Tracks) == 0 {
 			continue
 		}
@@ -452,13 +455,13 @@ func (s *ConstanceSorter) shouldStopForQuality(buckets *ConstanceBuckets, prev t
 	// If we're in a long same-key run, check if we can break out of it
 	if sameKeyRunCount >= maxSameKeyRun {
 		// Can we make any good transitions from current key?
-		if !s.hasGoodTransitionsAvailable(buckets, prev.Key) {
+		if !s.hasGoodTransitionsAvailable(buckets, prev.ExitKey()) {
 			return true // Stop - we'd be forced into more bad same-key transitions
 		}
 	}
 
 	// Check if we have enough variety left for quality mixing
-	availableTransitions := s.countAvailableTransitions(buckets, prev.Key)
+	availableTransitions := s.countAvailableTransitions(buckets, prev.ExitKey())
 	if availableTransitions < 2 {
 		// We're running low on good options - consider stopping
 		totalRemaining := buckets.GetTotalTracks()
@@ -474,20 +477,12 @@ func (s *ConstanceSorter) shouldStopForQuality(buckets *ConstanceBuckets, prev t
 // hasGoodTransitionsAvailable checks if we can make quality transitions from a key
 func (s *ConstanceSorter) hasGoodTransitionsAvailable(buckets *ConstanceBuckets, from track.Key) bool {
 	// Check for NumIncrease transitions (60% preference)
-	nextNum := from.Number + 1
-	if nextNum > 12 {
-		nextNum = 1
-	}
-	if buckets.GetBucketCount(track.Key{Number: nextNum, Mode: from.Mode}) > 0 {
+	if buckets.GetBucketCount(from.Transpose(1)) > 0 {
 		return true
 	}
 
 	// Check for SparkleJump transitions (10% preference)
-	oppositeMode := track.ModeA
-	if from.Mode == track.ModeA {
-		oppositeMode = track.ModeB
-	}
-	if buckets.GetBucketCount(track.Key{Number: nextNum, Mode: oppositeMode}) > 0 {
+	if buckets.GetBucketCount(from.Transpose(1).Relative()) > 0 {
 		return true
 	}
 
@@ -499,20 +494,12 @@ func (s *ConstanceSorter) countAvailableTransitions(buckets *ConstanceBuckets, f
 	count := 0
 
 	// Count NumIncrease option
-	nextNum := from.Number + 1
-	if nextNum > 12 {
-		nextNum = 1
-	}
-	if buckets.GetBucketCount(track.Key{Number: nextNum, Mode: from.Mode}) > 0 {
+	if buckets.GetBucketCount(from.Transpose(1)) > 0 {
 		count++
 	}
 
 	// Count SparkleJump option
-	oppositeMode := track.ModeA
-	if from.Mode == track.ModeA {
-		oppositeMode = track.ModeB
-	}
-	if buckets.GetBucketCount(track.Key{Number: nextNum, Mode: oppositeMode}) > 0 {
+	if buckets.GetBucketCount(from.Transpose(1).Relative()) > 0 {
 		count++
 	}
 
@@ -552,7 +539,7 @@ func (s *ConstanceSorter) pickNextTrackByRulesWithBuckets(buckets *ConstanceBuck
 
 	// Try each transition type until we find a track
 	for _, transType := range transitionTypes {
-		targetKeys := s.getTargetKeysForTransition(prev.Key, transType)
+		targetKeys := s.getTargetKeysForTransition(prev.ExitKey(), transType)
 
 		for _, targetKey := range targetKeys {
 			if buckets.GetBucketCount(targetKey) > 0 {
@@ -668,7 +655,7 @@ func (s *ConstanceSorter) scoreEnergyAwareCandidate(candidate, prev track.Track,
 	}
 
 	// BPM compatibility
-	bpmDiff := math.Abs(candidate.BPM - prev.BPM)
+	bpmDiff := math.Abs(candidate.EntryBPM() - prev.ExitBPM())
 	bpmScore := math.Max(0, 1.0-bpmDiff/20.0)
 
 	return energyScore*0.7 + bpmScore*0.3
@@ -680,12 +667,13 @@ func (s *ConstanceSorter) pickBestAvailableTrackWithEnergyConstraints(buckets *C
 	bestScore := -1000.0
 	found := false
 
-	for key, bucket := range buckets.buckets {
+	for _, key := range buckets.keys {
+		bucket := buckets.buckets[key]
 		if len(bucket.Tracks) == 0 {
 			continue
 		}
 
-		harmonicScore := s.scoreHarmonicTransition(prev.Key, key)
+		harmonicScore := s.scoreHarmonicTransition(prev.ExitKey(), key)
 
 		for _, t := range bucket.Tracks {
 			energyScore := s.scoreEnergyAwareCandidate(t, prev, energyState, trackPosition)
@@ -723,15 +711,7 @@ func (s *ConstanceSorter) getTargetKeysForTransition(from track.Key, transType T
 	switch transType {
 	case SparkleJump:
 		// +1 num, switch mode (2A->3B, 12B->1A)
-		nextNum := from.Number + 1
-		if nextNum > 12 {
-			nextNum = 1
-		}
-		oppositeMode := track.ModeA
-		if from.Mode == track.ModeA {
-			oppositeMode = track.ModeB
-		}
-		return []track.Key{{Number: nextNum, Mode: oppositeMode}}
+		return []track.Key{from.Transpose(1).Relative()}
 
 	case SameKey:
 		// Exact same key (8A->8A)
@@ -739,11 +719,7 @@ func (s *ConstanceSorter) getTargetKeysForTransition(from track.Key, transType T
 
 	case NumIncrease:
 		// +1 num, same mode (5B->6B, 12A->1A)
-		nextNum := from.Number + 1
-		if nextNum > 12 {
-			nextNum = 1
-		}
-		return []track.Key{{Number: nextNum, Mode: from.Mode}}
+		return []track.Key{from.Transpose(1)}
 
 	default:
 		return []track.Key{}
@@ -752,10 +728,7 @@ func (s *ConstanceSorter) getTargetKeysForTransition(from track.Key, transType T
 
 // scoreHarmonicTransition scores how good a key transition is harmonically
 func (s *ConstanceSorter) scoreHarmonicTransition(from, to track.Key) float64 {
-	numDiff := to.Number - from.Number
-	if numDiff < 0 {
-		numDiff += 12 // Handle wrap-around
-	}
+	numDiff := from.Steps(to)
 
 	sameLetter := from.Mode == to.Mode
 
@@ -0,0 +1,132 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const (
+	crateBandPct  = 6.0 // a tempo band spans at most this percent above its slowest track
+	crateArcWidth = 3   // a crate covers this many neighboring Camelot numbers (both modes)
+
+	crateWorstHarmonic = 1.3 // harmonicCost's ceiling
+)
+
+// Crate is a harmonic neighborhood of a library: tracks whose keys sit within a short
+// arc of the Camelot wheel and whose tempos fall in the same band, so any two of them
+// mix cleanly. Crates are a library-organization aid, not an ordering.
+type Crate struct {
+	Name   string      // e.g. "8-10_120-127bpm"
+	Keys   []track.Key // distinct keys present, sorted around the wheel
+	BPMLo  float64
+	BPMHi  float64
+	Tracks []track.Track
+}
+
+// Crates partitions a library into harmonic crates. Tracks are first split into tempo
+// bands (a band closes once a track is more than crateBandPct faster than the band's
+// slowest), then each band is cut into arcs of crateArcWidth adjacent Camelot numbers.
+// The arc boundaries are rotated to whichever offset keeps the most harmonically
+// compatible tracks together, scored with the same harmonicCost the sorters minimize.
+// Every track lands in exactly one crate; empty crates are omitted.
+func Crates(tracks []track.Track) []Crate {
+	if len(tracks) == 0 {
+		return nil
+	}
+	byBPM := make([]track.Track, len(tracks))
+	copy(byBPM, tracks)
+	sort.SliceStable(byBPM, func(a, b int) bool { return byBPM[a].BPM < byBPM[b].BPM })
+
+	var crates []Crate
+	for _, band := range tempoBands(byBPM) {
+		crates = append(crates, keyArcs(band)...)
+	}
+	return crates
+}
+
+// tempoBands splits BPM-sorted tracks into contiguous bands.
+func tempoBands(sorted []track.Track) [][]track.Track {
+	var bands [][]track.Track
+	start := 0
+	for i := 1; i <= len(sorted); i++ {
+		if i < len(sorted) && sorted[i].BPM <= sorted[start].BPM*(1+crateBandPct/100) {
+			continue
+		}
+		bands = append(bands, sorted[start:i])
+		start = i
+	}
+	return bands
+}
+
+// keyArcs cuts one tempo band into Camelot arcs, choosing the arc offset that keeps
+// the most harmonic compatibility inside crates (pairs are credited by how far their
+// harmonicCost sits below the worst possible move).
+func keyArcs(band []track.Track) []Crate {
+	bestOffset := 0
+	bestFit := math.Inf(-1)
+	for offset := range crateArcWidth {
+		fit := 0.0
+		for _, g := range groupByArc(band, offset) {
+			for i := range g {
+				for j := i + 1; j < len(g); j++ {
+					fit += crateWorstHarmonic - harmonicCost(KeyTransition(g[i].Key, g[j].Key))
+				}
+			}
+		}
+		if fit > bestFit+improvementEps {
+			bestFit = fit
+			bestOffset = offset
+		}
+	}
+
+	lo, hi := band[0].BPM, band[len(band)-1].BPM
+	var crates []Crate
+	for arc, g := range groupByArc(band, bestOffset) {
+		if len(g) == 0 {
+			continue
+		}
+		first := track.Key{Number: 1}.Transpose(arc*crateArcWidth + bestOffset)
+		last := first.Transpose(crateArcWidth - 1)
+		crates = append(crates, Crate{
+			Name:   fmt.Sprintf("%d-%d_%.0f-%.0fbpm", first.Number, last.Number, lo, hi),
+			Keys:   distinctKeys(g),
+			BPMLo:  lo,
+			BPMHi:  hi,
+			Tracks: g,
+		})
+	}
+	return crates
+}
+
+// groupByArc buckets tracks by which wheel arc their key number falls in. Arcs start
+// at Camelot number offset+1 and each span crateArcWidth numbers.
+func groupByArc(band []track.Track, offset int) [][]track.Track {
+	groups := make([][]track.Track, (12+crateArcWidth-1)/crateArcWidth)
+	start := track.Key{Number: 1}.Transpose(offset)
+	for _, t := range band {
+		arc := start.Steps(t.Key) / crateArcWidth
+		groups[arc] = append(groups[arc], t)
+	}
+	return groups
+}
+
+func distinctKeys(tracks []track.Track) []track.Key {
+	seen := map[track.Key]bool{}
+	var keys []track.Key
+	for _, t := range tracks {
+		if !seen[t.Key] {
+			seen[t.Key] = true
+			keys = append(keys, t.Key)
+		}
+	}
+	sort.Slice(keys, func(a, b int) bool {
+		if keys[a].Number != keys[b].Number {
+			return keys[a].Number < keys[b].Number
+		}
+		return keys[a].Mode < keys[b].Mode
+	})
+	return keys
+}
@@ -0,0 +1,63 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestCratesPlaceEveryTrackOnce(t *testing.T) {
+	tracks := chaveTracks(60)
+	crates := Crates(tracks)
+	if len(crates) == 0 {
+		t.Fatal("expected at least one crate")
+	}
+	seen := map[string]int{}
+	for _, c := range crates {
+		for _, tr := range c.Tracks {
+			seen[tr.Title]++
+		}
+	}
+	if len(seen) != len(tracks) {
+		t.Fatalf("crates cover %d distinct tracks, want %d", len(seen), len(tracks))
+	}
+	for title, n := range seen {
+		if n != 1 {
+			t.Fatalf("%s placed in %d crates, want 1", title, n)
+		}
+	}
+}
+
+func TestCratesStayWithinBandAndArc(t *testing.T) {
+	for _, c := range Crates(chaveTracks(60)) {
+		for _, tr := range c.Tracks {
+			if tr.BPM < c.BPMLo || tr.BPM > c.BPMHi {
+				t.Fatalf("crate %s: %s at %.0f bpm outside band", c.Name, tr.Title, tr.BPM)
+			}
+			if tr.BPM > c.BPMLo*(1+crateBandPct/100) {
+				t.Fatalf("crate %s: band wider than %.0f%%", c.Name, crateBandPct)
+			}
+		}
+		for _, a := range c.Keys {
+			for _, b := range c.Keys {
+				d := (b.Number - a.Number + 12) % 12
+				if d > crateArcWidth-1 && 12-d > crateArcWidth-1 {
+					t.Fatalf("crate %s mixes distant keys %s and %s", c.Name, a, b)
+				}
+			}
+		}
+	}
+}
+
+func TestCratesSplitTempoBands(t *testing.T) {
+	tracks := []track.Track{
+		mkTrack("slow1", 90, 40, "8A"),
+		mkTrack("slow2", 92, 40, "8A"),
+		mkTrack("fast1", 128, 60, "8A"),
+		mkTrack("fast2", 130, 60, "9A"),
+	}
+	crates := Crates(tracks)
+	if len(crates) != 2 {
+		t.Fatalf("got %d crates, want 2 (one per tempo band)", len(crates))
+	}
+}
@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Crossfade styles, from the longest overlap to the shortest.
+const (
+	FadeBlend = "blend" // a full phrase of both tracks playing together
+	FadeShort = "short" // a few bars: the tracks fit, but not well enough to linger
+	FadeCut   = "cut"   // a quick handover: a key or tempo clash, or an energy reset
+)
+
+// Crossfade limits, in bars and seconds.
+const (
+	fadeDefaultPhrase = 16 // bars blended when the outgoing track's phrase length is unknown
+	fadeShortBars     = 8
+	fadeCutBars       = 2
+	fadeMinSeconds    = 1.0
+	fadeMaxSeconds    = 30.0
+	fadeBeatsPerBar   = 4
+	fadeDefaultBPM    = 120
+)
+
+// Crossfade is a suggested overlap between one track and the next.
+type Crossfade struct {
+	Seconds float64
+	Style   string // FadeBlend, FadeShort, or FadeCut
+}
+
+// SuggestCrossfade suggests how long to overlap a into b. The transition sets the
+// style — a smooth key and tempo match blends over a phrase (a's phrase length, 16
+// bars when unknown), a workable one over a few bars, and a clash or an energy reset is
+// a quick cut — and a's exit tempo turns bars into seconds. The overlap then fits
+// inside a's outro and b's intro when the data has them, and inside a quarter of
+// either track's length, clamped to 1-30 seconds.
+func SuggestCrossfade(a, b track.Track) Crossfade {
+	harmonic := harmonicCost(NewTransition(a, b))
+	tempo := tempoCost(a.ExitBPM(), b.EntryBPM())
+	reset := IsReset(a, b)
+
+	fade := Crossfade{Style: FadeCut}
+	bars := float64(fadeCutBars)
+	switch {
+	case reset:
+	case harmonic <= 0.2 && tempo <= 0.3:
+		fade.Style, bars = FadeBlend, fadeDefaultPhrase
+		if a.Phrase != nil && *a.Phrase > 0 {
+			bars = float64(*a.Phrase)
+		}
+	case harmonic <= 0.55 && tempo <= 0.6:
+		fade.Style, bars = FadeShort, fadeShortBars
+	}
+
+	bpm := a.ExitBPM()
+	if bpm <= 0 {
+		bpm = fadeDefaultBPM
+	}
+	secs := bars * fadeBeatsPerBar * 60 / bpm
+	for _, limit := range []*int{a.Outro, b.Intro} {
+		if limit != nil && *limit > 0 {
+			secs = math.Min(secs, float64(*limit))
+		}
+	}
+	for _, length := range []*int{a.Duration, b.Duration} {
+		if length != nil && *length > 0 {
+			secs = math.Min(secs, float64(*length)/4)
+		}
+	}
+	secs = math.Max(fadeMinSeconds, math.Min(fadeMaxSeconds, secs))
+	fade.Seconds = math.Round(secs*10) / 10
+	return fade
+}
+
+// Crossfades suggests the overlap for each transition of an ordering: element i is the
+// fade from tracks[i] into tracks[i+1].
+func Crossfades(tracks []track.Track) []Crossfade {
+	if len(tracks) < 2 {
+		return nil
+	}
+	fades := make([]Crossfade, len(tracks)-1)
+	for i := range fades {
+		fades[i] = SuggestCrossfade(tracks[i], tracks[i+1])
+	}
+	return fades
+}
@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSuggestCrossfade(t *testing.T) {
+	k := func(s string) track.Key {
+		key, err := track.ParseKey(s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return key
+	}
+	a := track.Track{Title: "a", BPM: 120, Energy: 60, Key: k("8A")}
+
+	cases := []struct {
+		name  string
+		from  track.Track
+		to    track.Track
+		style string
+		secs  float64
+	}{
+		{"blend over a phrase", a, track.Track{BPM: 121, Energy: 62, Key: k("9A")}, FadeBlend, 30},
+		{"phrase length from data", track.Track{BPM: 120, Energy: 60, Key: k("8A"), Phrase: intPtr(8)},
+			track.Track{BPM: 120, Energy: 60, Key: k("8A")}, FadeBlend, 16},
+		{"short for a rougher key", a, track.Track{BPM: 120, Energy: 60, Key: k("11A")}, FadeShort, 16},
+		{"cut on a clash", a, track.Track{BPM: 96, Energy: 60, Key: k("2B")}, FadeCut, 4},
+		{"cut on a reset", a, track.Track{BPM: 120, Energy: 30, Key: k("8A")}, FadeCut, 4},
+		{"fits the outro and intro", track.Track{BPM: 120, Energy: 60, Key: k("8A"), Outro: intPtr(12)},
+			track.Track{BPM: 120, Energy: 60, Key: k("8A"), Intro: intPtr(20)}, FadeBlend, 12},
+	}
+	for _, c := range cases {
+		got := SuggestCrossfade(c.from, c.to)
+		if got.Style != c.style || got.Seconds != c.secs {
+			t.Errorf("%s: got %s %.1fs, want %s %.1fs", c.name, got.Style, got.Seconds, c.style, c.secs)
+		}
+	}
+	if fades := Crossfades([]track.Track{a, a, a}); len(fades) != 2 {
+		t.Errorf("Crossfades over 3 tracks gave %d fades, want 2", len(fades))
+	}
+}
@@ -0,0 +1,185 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// crowdUnit is the crowd rule's penalty for the least liked track at the very next
+// position of the set, about a rough transition's cost; it tapers to nothing across
+// the rule's horizon.
+const crowdUnit = 3.0
+
+// Crowd is how the floor has taken the set so far: the tracks playing when it
+// responded well (Liked) and poorly (Disliked). A sort under WithCrowd leans the next
+// Horizon positions (0 for the whole set) toward tracks like the liked ones — in
+// energy, genre, and era — and away from tracks like the disliked ones, soonest first.
+// Weight scales the lean against the mix score (0 counts as 1).
+type Crowd struct {
+	Liked, Disliked []track.Track
+	Horizon         int
+	Weight          float64
+}
+
+const crowdContextKey contextKey = "strategy.crowd"
+
+// WithCrowd leans the set toward what the crowd liked, as a soft rule (see
+// weighSoftRules). Under a limit the lean also decides which tracks make the set,
+// since the set keeps the head of the ordering.
+func WithCrowd(ctx context.Context, c Crowd) context.Context {
+	if len(c.Liked) == 0 && len(c.Disliked) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, crowdContextKey, &c)
+}
+
+func crowdFromContext(ctx context.Context) *Crowd {
+	if ctx == nil {
+		return nil
+	}
+	c, _ := ctx.Value(crowdContextKey).(*Crowd)
+	return c
+}
+
+// Affinity is how much t resembles what the crowd liked more than what it didn't,
+// from -1 to 1: its mean likeness to the liked tracks less its mean likeness to the
+// disliked ones.
+func (c Crowd) Affinity(t track.Track, tx *genre.Taxonomy) float64 {
+	mean := func(refs []track.Track) float64 {
+		if len(refs) == 0 {
+			return 0
+		}
+		sum := 0.0
+		for _, r := range refs {
+			sum += likeness(t, r, tx)
+		}
+		return sum / float64(len(refs))
+	}
+	return mean(c.Liked) - mean(c.Disliked)
+}
+
+// likeness is how alike two tracks are, 0 to 1, on what both have of energy (within
+// 20), genre (the same genre, or half for the same family), and era (within 10
+// years).
+func likeness(a, b track.Track, tx *genre.Taxonomy) float64 {
+	sum := math.Max(0, 1-math.Abs(float64(a.Energy-b.Energy))/20)
+	n := 1.0
+	if a.Genre != "" && b.Genre != "" {
+		switch {
+		case tx.Same(a.Genre, b.Genre):
+			sum++
+		case tx.Family(a.Genre) == tx.Family(b.Genre):
+			sum += 0.5
+		}
+		n++
+	}
+	if a.Year != nil && b.Year != nil {
+		sum += math.Max(0, 1-math.Abs(float64(*a.Year-*b.Year))/10)
+		n++
+	}
+	return sum / n
+}
+
+// Summary describes what the crowd liked and didn't, for a report: the energy,
+// most common genre, and decade of each group.
+func (c Crowd) Summary() string {
+	var parts []string
+	if len(c.Liked) > 0 {
+		parts = append(parts, "liked "+describeGroup(c.Liked))
+	}
+	if len(c.Disliked) > 0 {
+		parts = append(parts, "not "+describeGroup(c.Disliked))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func describeGroup(tracks []track.Track) string {
+	energy := 0
+	genres := map[string]int{}
+	var years []int
+	for _, t := range tracks {
+		energy += t.Energy
+		if t.Genre != "" {
+			genres[t.Genre]++
+		}
+		if t.Year != nil {
+			years = append(years, *t.Year)
+		}
+	}
+	out := fmt.Sprintf("energy ~%d", energy/len(tracks))
+	if len(genres) > 0 {
+		names := make([]string, 0, len(genres))
+		for g := range genres {
+			names = append(names, g)
+		}
+		slices.SortFunc(names, func(a, b string) int {
+			return cmp.Or(cmp.Compare(genres[b], genres[a]), cmp.Compare(a, b))
+		})
+		out += ", " + names[0]
+	}
+	if len(years) > 0 {
+		slices.Sort(years)
+		out += fmt.Sprintf(", %ds", years[len(years)/2]/10*10)
+	}
+	return fmt.Sprintf("%s (%d moment(s))", out, len(tracks))
+}
+
+// boundCrowd is a Crowd resolved against one track list: each track's dislike,
+// (1 - affinity) / 2, from 0 for the most liked kind of track to 1 for the least.
+type boundCrowd struct {
+	unit    float64
+	horizon int
+	dislike []float64
+}
+
+func bindCrowd(tracks []track.Track, c Crowd, tx *genre.Taxonomy) *boundCrowd {
+	b := &boundCrowd{unit: crowdUnit, horizon: c.Horizon, dislike: make([]float64, len(tracks))}
+	if c.Weight > 0 {
+		b.unit *= c.Weight
+	}
+	for i, t := range tracks {
+		b.dislike[i] = (1 - c.Affinity(t, tx)) / 2
+	}
+	return b
+}
+
+func (*boundCrowd) soft() {}
+
+// span is how many leading positions of an n-track ordering the lean covers.
+func (b *boundCrowd) span(n int) int {
+	if b.horizon > 0 && b.horizon < n {
+		return b.horizon
+	}
+	return n
+}
+
+// cost weighs each track's dislike by how soon it plays: fully at the first position,
+// tapering to nothing at the horizon.
+func (b *boundCrowd) cost(perm []int) float64 {
+	h := b.span(len(perm))
+	total := 0.0
+	for k := range h {
+		total += b.unit * (1 - float64(k)/float64(h)) * b.dislike[perm[k]]
+	}
+	return total
+}
+
+// conflicts marks the positions within the horizon whose track is less liked than one
+// playing after it.
+func (b *boundCrowd) conflicts(perm []int, out []bool) {
+	best := math.Inf(1)
+	for k := len(perm) - 1; k >= 0; k-- {
+		d := b.dislike[perm[k]]
+		if k < b.span(len(perm)) && d > best+improvementEps {
+			out[k] = true
+		}
+		best = math.Min(best, d)
+	}
+}
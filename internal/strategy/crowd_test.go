@@ -0,0 +1,46 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestCrowdAffinity(t *testing.T) {
+	year := func(y int) *int { return &y }
+	liked := track.Track{Energy: 80, Genre: "Techno", Year: year(1995)}
+	disliked := track.Track{Energy: 30, Genre: "Disco", Year: year(1978)}
+	c := Crowd{Liked: []track.Track{liked}, Disliked: []track.Track{disliked}}
+	tx := genre.Default()
+
+	if a := c.Affinity(liked, tx); a <= 0.5 {
+		t.Errorf("a liked track's affinity is %.2f, want well above 0", a)
+	}
+	if a := c.Affinity(disliked, tx); a >= -0.5 {
+		t.Errorf("a disliked track's affinity is %.2f, want well below 0", a)
+	}
+	if a := c.Affinity(track.Track{Energy: 76, Genre: "Techno"}, tx); a <= 0 {
+		t.Errorf("a track like the liked one has affinity %.2f, want above 0", a)
+	}
+}
+
+func TestSortLeansTowardCrowd(t *testing.T) {
+	tracks := chaveTracks(30)
+	ctx := WithSeed(context.Background(), 4)
+	sorter, _ := Get(flowStrategyName)
+	head := func(ctx context.Context) float64 {
+		res, err := Sort(WithLimit(ctx, 10), sorter, tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return meanEnergy(Truncate(ctx, res.Ordered, 10))
+	}
+
+	loud := Crowd{Liked: []track.Track{{Energy: 95}}, Disliked: []track.Track{{Energy: 20}}, Weight: 3}
+	quiet := Crowd{Liked: []track.Track{{Energy: 20}}, Disliked: []track.Track{{Energy: 95}}, Weight: 3}
+	if up, down := head(WithCrowd(ctx, loud)), head(WithCrowd(ctx, quiet)); up <= down+10 {
+		t.Errorf("the first 10 tracks average energy %.0f leaning loud and %.0f leaning quiet; want loud well above", up, down)
+	}
+}
@@ -0,0 +1,161 @@
+package strategy
+
+import (
+	"context"
+	"sort"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// categoryNames label the default planner's transition categories, by index.
+var categoryNames = [...]string{"step+1", "step+2", "same-number", "mode-flip", "other"}
+
+// Decision records one placement made by the default planner: the state it decided
+// from, every candidate it weighed with its score breakdown, and which one it took. Position 0 is the opening pick, which is chosen by a separate start heuristic
+// and so carries no candidates or category order.
+type Decision struct {
+	Position      int                 `json:"position"`
+	State         DecisionState       `json:"state"`
+	CategoryOrder []string            `json:"category_order,omitempty"`
+	Candidates    []DecisionCandidate `json:"candidates"`
+	Chosen        int                 `json:"chosen"` // index into Candidates
+}
+
+// DecisionState is a snapshot of the planner state before a placement.
+type DecisionState struct {
+	Prev                 string  `json:"prev,omitempty"`
+	PrevKey              string  `json:"prev_key,omitempty"`
+	Cycle                int     `json:"cycle"`
+	TracksInCycle        int     `json:"tracks_in_cycle"`
+	DesiredCycleLen      int     `json:"desired_cycle_len"`
+	CycleStartEnergy     float64 `json:"cycle_start_energy"`
+	KeyRunLength         int     `json:"key_run_length"`
+	StepsSinceStep1      int     `json:"steps_since_step1"`
+	StepsSinceStep2      int     `json:"steps_since_step2"`
+	StepsSinceLetterFlip int     `json:"steps_since_letter_flip"`
+	StepsSinceEnergyDrop int     `json:"steps_since_energy_drop"`
+	Remaining            int     `json:"remaining"`
+}
+
+// DecisionCandidate is one candidate and its score. Key, BPM, and Energy are the
+// weighted transition costs; Inventory is everything else the planner adds (key
+// inventory, variety, and run pressure). Lower totals are better.
+type DecisionCandidate struct {
+	Title      string  `json:"title"`
+	Artist     string  `json:"artist"`
+	Key        string  `json:"key"`
+	BPM        string  `json:"bpm"`
+	Energy     int     `json:"energy"`
+	Category   string  `json:"category,omitempty"`
+	KeyCost    float64 `json:"key_cost"`
+	BPMCost    float64 `json:"bpm_cost"`
+	EnergyCost float64 `json:"energy_cost"`
+	// PriorityCost is the pull of the track's Priority; negative for a favorite.
+	PriorityCost float64 `json:"priority_cost,omitempty"`
+	Inventory    float64 `json:"inventory"`
+	Total        float64 `json:"total"`
+}
+
+// DecisionRecorder receives each Decision as the planner makes it.
+type DecisionRecorder func(Decision)
+
+const decisionContextKey contextKey = "strategy.decisions"
+
+// WithDecisionRecorder asks the default strategy to report every placement to rec.
+// Other strategies ignore it. Recording does not change the ordering.
+func WithDecisionRecorder(ctx context.Context, rec DecisionRecorder) context.Context {
+	if rec == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, decisionContextKey, rec)
+}
+
+func decisionRecorderFromContext(ctx context.Context) DecisionRecorder {
+	if ctx == nil {
+		return nil
+	}
+	if rec, ok := ctx.Value(decisionContextKey).(DecisionRecorder); ok {
+		return rec
+	}
+	return nil
+}
+
+// scoredCandidate is a candidate as chooseNextIndex saw it.
+type scoredCandidate struct {
+	idx      int
+	category int
+	trans    Transition
+	score    float64
+}
+
+// snapshot captures the state fields a Decision reports.
+func (p *mixPlanner) snapshot(state *mixState) DecisionState {
+	s := DecisionState{
+		Cycle:                state.cycleIndex,
+		TracksInCycle:        state.tracksInCycle,
+		DesiredCycleLen:      state.desiredCycleLen,
+		CycleStartEnergy:     state.cycleStartEnergy,
+		KeyRunLength:         state.keyNumberRunLength,
+		StepsSinceStep1:      state.stepsSinceStep1,
+		StepsSinceStep2:      state.stepsSinceStep2,
+		StepsSinceLetterFlip: state.stepsSinceLetterFlip,
+		StepsSinceEnergyDrop: state.stepsSinceEnergyDrop,
+		Remaining:            len(p.remaining),
+	}
+	if state.prevSet {
+		s.Prev = state.prev.Title
+		s.PrevKey = state.prev.KeyString()
+	}
+	return s
+}
+
+// decision builds the record for a placement: every eligible candidate, in ascending
+// score order, so a later question about any of them ("why not this one?") can be
+// answered from the log.
+func (p *mixPlanner) decision(position int, state *mixState, scored []scoredCandidate, order []int, chosen int) Decision {
+	d := Decision{Position: position, State: p.snapshot(state), Chosen: -1}
+	for _, c := range order {
+		d.CategoryOrder = append(d.CategoryOrder, categoryNames[c])
+	}
+
+	ranked := append([]scoredCandidate(nil), scored...)
+	sort.SliceStable(ranked, func(a, b int) bool { return ranked[a].score < ranked[b].score })
+	for i, c := range ranked {
+		d.Candidates = append(d.Candidates, p.describe(state, p.remaining[c.idx], c))
+		if c.idx == chosen {
+			d.Chosen = i
+		}
+	}
+	return d
+}
+
+// describe breaks down the score of t, weighed as c.
+func (p *mixPlanner) describe(state *mixState, t track.Track, c scoredCandidate) DecisionCandidate {
+	keyCost := keyTransitionCost(state, c.trans) * p.tuning.KeyWeight
+	bpmCost := bpmTransitionCost(state, t, p.stats) * p.tuning.BPMWeight
+	energyCost := energyTransitionCost(state, t, c.trans, p.stats, p.desiredCycleLength) * p.tuning.EnergyWeight
+	priority := priorityCost(state, t, p.coverage())
+	return DecisionCandidate{
+		Title:        t.Title,
+		Artist:       t.Artist,
+		Key:          t.KeyString(),
+		BPM:          t.TempoString(),
+		Energy:       t.Energy,
+		Category:     categoryNames[c.category],
+		KeyCost:      keyCost,
+		BPMCost:      bpmCost,
+		EnergyCost:   energyCost,
+		PriorityCost: priority,
+		Inventory:    c.score - keyCost - bpmCost - energyCost - priority,
+		Total:        c.score,
+	}
+}
+
+// startDecision records the opening pick.
+func (p *mixPlanner) startDecision(start track.Track) Decision {
+	return Decision{
+		State:      DecisionState{DesiredCycleLen: p.desiredCycleLength, Remaining: len(p.remaining) + 1},
+		Candidates: []DecisionCandidate{{Title: start.Title, Artist: start.Artist, Key: start.KeyString(), BPM: start.TempoString(), Energy: start.Energy}},
+		Chosen:     0,
+	}
+}
@@ -2,44 +2,137 @@ package strategy
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"math"
 	"math/rand"
+	"slices"
 	"sort"
 	"time"
 
 	"github.com/YakDriver/magicmix/internal/track"
 )
 
-const (
-	defaultStrategyName = "default"
-
-	cycleMinTracks   = 6
-	cycleMaxTracks   = 10
-	cycleIdealTracks = 8
-
-	keyWeight    = 12.0
-	bpmWeight    = 0.8
-	energyWeight = 2.0
-
-	varietyStepThreshold    = 2
-	varietyStepWeight       = 3.0
-	varietyLetterThreshold  = 5
-	varietyLetterWeight     = 2.5
-	varietyEnergyThreshold  = 5
-	varietyEnergyReward     = 1.5
-	varietyEnergyPenalty    = 0.6
-	energyDropThreshold     = 10
-	startSelectionTolerance = 1.0
-)
+const defaultStrategyName = "default"
+
+// Tuning holds the default planner's knobs: how long its energy cycles run, how it
+// weighs key, BPM, and energy costs against each other, and when it starts pushing for
+// variety. Raise BPMWeight for tighter tempo matching or the cycle lengths for longer
+// builds. The JSON names are what a --config file uses.
+type Tuning struct {
+	// Energy cycles (a build and its reset) last between CycleMinTracks and
+	// CycleMaxTracks tracks, as close to CycleIdealTracks as the set divides.
+	CycleMinTracks   int `json:"cycleMinTracks"`
+	CycleMaxTracks   int `json:"cycleMaxTracks"`
+	CycleIdealTracks int `json:"cycleIdealTracks"`
+
+	// Each candidate's cost is its key, BPM, and energy costs weighted by these.
+	KeyWeight    float64 `json:"keyWeight"`
+	BPMWeight    float64 `json:"bpmWeight"`
+	EnergyWeight float64 `json:"energyWeight"`
+
+	// Variety: after this many tracks without a +1/+2 step, a letter flip (8A to 8B),
+	// or an energy drop, the planner rewards the move it has been missing by the weight
+	// per extra track (and, for energy, penalizes going without by EnergyPenalty).
+	VarietyStepThreshold   int     `json:"varietyStepThreshold"`
+	VarietyStepWeight      float64 `json:"varietyStepWeight"`
+	VarietyLetterThreshold int     `json:"varietyLetterThreshold"`
+	VarietyLetterWeight    float64 `json:"varietyLetterWeight"`
+	VarietyEnergyThreshold int     `json:"varietyEnergyThreshold"`
+	VarietyEnergyReward    float64 `json:"varietyEnergyReward"`
+	VarietyEnergyPenalty   float64 `json:"varietyEnergyPenalty"`
+
+	// EnergyDropThreshold is the fall in energy that counts as a drop.
+	EnergyDropThreshold float64 `json:"energyDropThreshold"`
+	// StartSelectionTolerance is how close to the best opener's score another track
+	// must be to share the seeded pick.
+	StartSelectionTolerance float64 `json:"startSelectionTolerance"`
+
+	// PriorityWeight is what each level of a track's Priority away from the middle (3)
+	// is worth: favorites are kept when the set is cut to a limit and drift toward the
+	// peak of each energy cycle, filler the other way. 0 ignores priority.
+	PriorityWeight float64 `json:"priorityWeight"`
+
+	// GenreBPMTolerance maps a genre or genre family to the tempo change, in BPM, it
+	// takes as easily as the stock cost takes a 5 BPM change: 12 for Hip Hop lets it
+	// jump freely, 3 for Trance holds it tight. A transition between two genres uses
+	// the stricter; genres not listed use 5.
+	GenreBPMTolerance map[string]float64 `json:"genreBPMTolerance,omitempty"`
+}
+
+// DefaultTuning is the tuning the default strategy uses unless told otherwise.
+var DefaultTuning = Tuning{
+	CycleMinTracks:   6,
+	CycleMaxTracks:   10,
+	CycleIdealTracks: 8,
+
+	KeyWeight:    12.0,
+	BPMWeight:    0.8,
+	EnergyWeight: 2.0,
+
+	VarietyStepThreshold:    2,
+	VarietyStepWeight:       3.0,
+	VarietyLetterThreshold:  5,
+	VarietyLetterWeight:     2.5,
+	VarietyEnergyThreshold:  5,
+	VarietyEnergyReward:     1.5,
+	VarietyEnergyPenalty:    0.6,
+	EnergyDropThreshold:     10,
+	StartSelectionTolerance: 1.0,
+	PriorityWeight:          4.0,
+}
+
+// Validate reports a tuning the planner can't work with.
+func (t Tuning) Validate() error {
+	switch {
+	case t.CycleMinTracks < 1:
+		return errors.New("cycleMinTracks must be at least 1")
+	case t.CycleIdealTracks < t.CycleMinTracks || t.CycleMaxTracks < t.CycleIdealTracks:
+		return fmt.Errorf("cycle lengths must run min <= ideal <= max, got %d, %d, %d",
+			t.CycleMinTracks, t.CycleIdealTracks, t.CycleMaxTracks)
+	case t.KeyWeight < 0 || t.BPMWeight < 0 || t.EnergyWeight < 0 || t.PriorityWeight < 0:
+		return errors.New("key, BPM, energy, and priority weights must be non-negative")
+	case !positiveValues(t.GenreBPMTolerance):
+		return errors.New("genre BPM tolerances must be positive")
+	case t.VarietyStepThreshold < 0 || t.VarietyLetterThreshold < 0 || t.VarietyEnergyThreshold < 0 ||
+		t.EnergyDropThreshold < 0:
+		return errors.New("thresholds must be non-negative")
+	}
+	return nil
+}
+
+const tuningContextKey contextKey = "strategy.tuning"
+
+// WithTuning has the default strategy (and Simulate) plan with t instead of
+// DefaultTuning. A sorter made by NewDefaultSorterWith keeps its own tuning.
+func WithTuning(ctx context.Context, t Tuning) context.Context {
+	return context.WithValue(ctx, tuningContextKey, t)
+}
+
+func tuningFromContext(ctx context.Context) Tuning {
+	if ctx != nil {
+		if t, ok := ctx.Value(tuningContextKey).(Tuning); ok {
+			return t
+		}
+	}
+	return DefaultTuning
+}
 
 // DefaultSorter applies heuristic ordering to balance key continuity, BPM smoothness,
 // and energy cycling while respecting Camelot key constraints.
-type DefaultSorter struct{}
+type DefaultSorter struct {
+	tuning *Tuning // nil plans with the context's tuning
+}
 
 func NewDefaultSorter() *DefaultSorter {
 	return &DefaultSorter{}
 }
 
+// NewDefaultSorterWith returns a default sorter that always plans with t.
+func NewDefaultSorterWith(t Tuning) *DefaultSorter {
+	return &DefaultSorter{tuning: &t}
+}
+
 func (s *DefaultSorter) Name() string {
 	return defaultStrategyName
 }
@@ -57,14 +150,33 @@ func (s *DefaultSorter) Sort(ctx context.Context, tracks []track.Track) ([]track
 	if limit := limitFromContext(ctx); limit > 0 && limit < targetCount {
 		targetCount = limit
 	}
+	if s.tuning != nil {
+		ctx = WithTuning(ctx, *s.tuning)
+	}
 	planner := newMixPlanner(ctx, tracks, targetCount)
 
 	ordered := make([]track.Track, 0, targetCount)
+	progress := progressFromContext(ctx)
 
-	startIdx := planner.chooseStartIndex()
+	explain := explainLogFromContext(ctx)
+	if explain != nil {
+		explain.reset()
+	}
+
+	startIdx := planner.anchorAt(0)
+	pinned := startIdx >= 0
+	if !pinned {
+		startIdx = planner.chooseStartIndex()
+	}
 	start := planner.take(startIdx)
+	if planner.recorder != nil {
+		planner.recorder(planner.startDecision(start))
+	}
 
 	state := planner.initialState(start)
+	if explain != nil {
+		explain.record("", start, explainOpener(start, pinned, planner.arc))
+	}
 	ordered = append(ordered, start)
 	if len(ordered) >= targetCount {
 		return ordered, nil
@@ -73,30 +185,55 @@ func (s *DefaultSorter) Sort(ctx context.Context, tracks []track.Track) ([]track
 	for planner.remainingCount() > 0 && len(ordered) < targetCount {
 		select {
 		case <-ctx.Done():
-			return nil, ctx.Err()
+			return nil, partial(ordered, ctx.Err())
 		default:
 		}
 
-		idx := planner.chooseNextIndex(&state)
+		idx := planner.anchorAt(len(ordered))
+		pinned := idx >= 0
+		if !pinned {
+			idx = planner.chooseNextIndex(&state)
+		}
+		if explain != nil {
+			explain.record(state.prev.ID(), planner.remaining[idx], planner.explainPlacement(&state, planner.remaining[idx], pinned))
+		}
 		next := planner.take(idx)
 		state.advance(next)
 		ordered = append(ordered, next)
+		progress.report(Progress{Stage: StagePlacing, Placed: len(ordered), Total: targetCount})
 	}
 
 	return ordered, nil
 }
 
 // mixPlanner owns the dataset under consideration and tracks remaining inventory.
+// Pinned tracks wait in remaining for their reserved position; slot and required run
+// parallel to remaining.
 type mixPlanner struct {
 	remaining          []track.Track
+	slot               []int  // the 0-based position a pinned track is reserved for; -1 when free
+	required           []bool // the track is pinned or must-include
 	stats              mixStats
 	desiredCycleLength int
 	countsByKey        map[track.Key]int
 	countsByNumber     map[int]int
-	targetIntervals    map[int]float64 // Target spacing for each key number
+	byKey              map[track.Key][]int // indices into remaining, by key, each in EntryBPM order; see chooseByCategory
+	pinned             int                 // remaining tracks held for a pin
+	anyKey             int                 // remaining key-agnostic tracks
+	floorCache         [2][]float64        // scoreFloors.energy's storage, reused each placement
+	waiting            int                 // remaining must-include tracks not held for a pin
+	targetIntervals    map[int]float64     // Target spacing for each key number
 	rng                *rand.Rand
 	totalTracks        int
 	targetCount        int
+	recorder           DecisionRecorder // nil unless the run is being logged
+	tuning             *Tuning
+	tempoMatch         track.TempoMatch
+	bpmTolerance       map[string]float64 // by raw genre; absent means defaultBPMTolerance
+	arc                *arcScale          // nil unless WithArc shapes the set
+	excursionEvery     int                // tracks between relative-mode excursions; 0 = off
+	tieBreak           TieBreak
+	strictCamelot      bool // diagonal moves are chosen only when nothing else is left
 }
 
 type mixStats struct {
@@ -123,12 +260,15 @@ type mixState struct {
 	stepsSinceLetterFlip int
 	stepsSinceEnergyDrop int
 	stepsSinceKeyNumber  map[int]int // How many tracks since we last used each key number
-}
-
-type transition struct {
-	diff       int
-	wrap       bool
-	modeChange bool
+	tuning               *Tuning
+	tempoMatch           track.TempoMatch // which tempo relationships count as close
+	bpmTolerance         map[string]float64
+	arc                  *arcScale // nil unless WithArc shapes the set
+	position             int       // of prev, 0-based
+	lastPosition         int       // of the set's last track
+	excursionEvery       int       // see WithExcursions; 0 = off
+	excursionStage       int       // how far the excursion under way has got
+	sinceExcursion       int       // tracks since the last excursion closed
 }
 
 func newMixPlanner(ctx context.Context, tracks []track.Track, targetCount int) *mixPlanner {
@@ -157,7 +297,8 @@ func newMixPlanner(ctx context.Context, tracks []track.Track, targetCount int) *
 		}
 	}
 
-	desired := idealCycleLength(len(remaining))
+	tuning := tuningFromContext(ctx)
+	desired := idealCycleLength(len(remaining), &tuning)
 
 	seed, ok := seedFromContext(ctx)
 	if !ok || seed == 0 {
@@ -165,16 +306,70 @@ func newMixPlanner(ctx context.Context, tracks []track.Track, targetCount int) *
 	}
 	rng := rand.New(rand.NewSource(seed))
 
+	slot := make([]int, len(remaining))
+	for i := range slot {
+		slot[i] = -1
+	}
+	pinned, target := claimPins(remaining, pinsFromContext(ctx), targetCount)
+	for p, idx := range pinned {
+		if idx >= 0 && target[p] >= 0 {
+			slot[idx] = target[p]
+		}
+	}
+	required := make([]bool, len(remaining))
+	if match := Required(ctx); match != nil {
+		for i, t := range remaining {
+			required[i] = match(t)
+		}
+	}
+	var arc *arcScale
+	if a := arcFromContext(ctx); a != nil {
+		scale := newArcScale(*a, remaining)
+		arc = &scale
+	}
+
+	byKey := make(map[track.Key][]int, len(countsByKey))
+	pinnedCount, waiting, anyKey := 0, 0, 0
+	for i, t := range remaining {
+		byKey[t.Key] = append(byKey[t.Key], i)
+		if t.AnyKey() {
+			anyKey++
+		}
+		switch {
+		case slot[i] >= 0:
+			pinnedCount++
+		case required[i]:
+			waiting++
+		}
+	}
+	for _, bucket := range byKey {
+		sort.SliceStable(bucket, func(a, b int) bool { return remaining[bucket[a]].EntryBPM() < remaining[bucket[b]].EntryBPM() })
+	}
+
 	return &mixPlanner{
 		remaining:          remaining,
+		slot:               slot,
+		required:           required,
 		stats:              stats,
 		desiredCycleLength: desired,
 		countsByKey:        countsByKey,
 		countsByNumber:     countsByNumber,
+		byKey:              byKey,
+		pinned:             pinnedCount,
+		anyKey:             anyKey,
+		waiting:            waiting,
 		targetIntervals:    targetIntervals,
 		rng:                rng,
 		totalTracks:        len(tracks),
 		targetCount:        targetCount,
+		recorder:           decisionRecorderFromContext(ctx),
+		tuning:             &tuning,
+		tempoMatch:         tempoMatchFromContext(ctx),
+		bpmTolerance:       genreBPMTolerance(remaining, tuning.GenreBPMTolerance, genresFromContext(ctx)),
+		arc:                arc,
+		excursionEvery:     excursionsFromContext(ctx),
+		tieBreak:           tieBreakFromContext(ctx),
+		strictCamelot:      strictCamelotFromContext(ctx),
 	}
 }
 
@@ -200,16 +395,16 @@ func analyzeMixStats(tracks []track.Track) mixStats {
 	}
 }
 
-func idealCycleLength(total int) int {
-	if total <= cycleMinTracks {
+func idealCycleLength(total int, t *Tuning) int {
+	if total <= t.CycleMinTracks {
 		if total == 0 {
-			return cycleMinTracks
+			return t.CycleMinTracks
 		}
 		return total
 	}
 
-	estimatedCycles := int(math.Max(1, math.Round(float64(total)/float64(cycleIdealTracks))))
-	return min(max(int(math.Round(float64(total)/float64(estimatedCycles))), cycleMinTracks), cycleMaxTracks)
+	estimatedCycles := int(math.Max(1, math.Round(float64(total)/float64(t.CycleIdealTracks))))
+	return min(max(int(math.Round(float64(total)/float64(estimatedCycles))), t.CycleMinTracks), t.CycleMaxTracks)
 }
 
 func (p *mixPlanner) initialState(start track.Track) mixState {
@@ -236,6 +431,12 @@ func (p *mixPlanner) initialState(start track.Track) mixState {
 		stepsSinceLetterFlip: 0,
 		stepsSinceEnergyDrop: 0,
 		stepsSinceKeyNumber:  stepsSinceKeyNumber,
+		tuning:               p.tuning,
+		tempoMatch:           p.tempoMatch,
+		bpmTolerance:         p.bpmTolerance,
+		arc:                  p.arc,
+		lastPosition:         p.targetCount - 1,
+		excursionEvery:       p.excursionEvery,
 	}
 	return state
 }
@@ -244,6 +445,9 @@ func (p *mixPlanner) chooseStartIndex() int {
 	// First, collect all tracks grouped by key number to ensure we have good options
 	keyGroups := make(map[int][]int)
 	for idx, candidate := range p.remaining {
+		if p.slot[idx] >= 0 {
+			continue
+		}
 		keyGroups[candidate.Key.Number] = append(keyGroups[candidate.Key.Number], idx)
 	}
 
@@ -302,11 +506,11 @@ func (p *mixPlanner) chooseStartIndex() int {
 	for _, idx := range keyNumberCandidates {
 		candidate := p.remaining[idx]
 		score := p.startScoreWithinKey(candidate)
-		if score < bestScore-startSelectionTolerance {
+		if score < bestScore-p.tuning.StartSelectionTolerance {
 			bestScore = score
 			candidates = candidates[:0]
 			candidates = append(candidates, idx)
-		} else if score <= bestScore+startSelectionTolerance {
+		} else if score <= bestScore+p.tuning.StartSelectionTolerance {
 			candidates = append(candidates, idx)
 		}
 	}
@@ -322,6 +526,9 @@ func (p *mixPlanner) startScoreWithinKey(candidate track.Track) float64 {
 	// Modified scoring function that doesn't heavily favor key frequency
 	// Instead focuses on energy and BPM characteristics for good mixing
 	energyTarget := p.stats.energyLow
+	if p.arc != nil {
+		energyTarget = p.arc.energy(0)
+	}
 	energyDiff := math.Abs(float64(candidate.Energy) - energyTarget)
 	bpmDiff := math.Abs(candidate.BPM - p.stats.bpmMedian)
 
@@ -336,61 +543,289 @@ func (p *mixPlanner) startScoreWithinKey(candidate track.Track) float64 {
 }
 
 func (p *mixPlanner) chooseNextIndex(state *mixState) int {
-	type choice struct {
-		idx   int
-		score float64
-		set   bool
+	if p.recorder == nil && state.prevSet {
+		return p.chooseByCategory(state)
 	}
 
-	var buckets [5]choice
-
+	var scored []scoredCandidate
+	placed := p.totalTracks - len(p.remaining)
+	mustFill := p.mustFill(placed)
+	anchor := p.anchorAt(placed + 1)
 	for idx, candidate := range p.remaining {
+		if !p.eligible(idx, mustFill) {
+			continue
+		}
 		trans := computeTransition(state, candidate)
-		score := p.transitionScoreWithTransition(state, candidate, trans)
+		scored = append(scored, scoredCandidate{
+			idx:      idx,
+			category: categorizeTransition(state, trans),
+			trans:    trans,
+			score:    p.candidateScore(state, candidate, trans, anchor),
+		})
+	}
 
-		category := categorizeTransition(state, trans)
-		if category < 0 || category >= len(buckets) {
-			continue
+	// Only the first category with a candidate is decided between, as chooseByCategory
+	// does, so recording a decision draws the same tie-breaks as not recording one.
+	chosen := -1
+	order := categoryOrder(state)
+	for _, category := range order {
+		var in []scoredCandidate
+		for _, c := range scored {
+			if c.category == category {
+				in = append(in, c)
+			}
+		}
+		if idx, ok := p.pick(in); ok {
+			chosen = idx
+			break
 		}
+	}
+	if chosen < 0 {
+		chosen = p.firstFree()
+	}
 
-		best := &buckets[category]
-		if !best.set || score < best.score-1e-6 {
-			best.idx = idx
-			best.score = score
-			best.set = true
-		} else if best.set && closeFloat(score, best.score) {
-			if p.rng.Intn(2) == 0 {
-				best.idx = idx
-				best.score = score
+	if p.recorder != nil {
+		p.recorder(p.decision(placed, state, scored, order, chosen))
+	}
+	return chosen
+}
+
+// chooseByCategory is chooseNextIndex without the full scan. It scores only the
+// tracks whose keys fall in each category, in category order, and stops at the first
+// category with an eligible track: a category always wins outright over the ones after
+// it. Within a key it scores the tracks nearest prev's tempo first and stops once the
+// tempo cost alone puts the rest above the best score so far (see scanKey), so a
+// placement scores the tracks near the tempo and energy it wants rather than every
+// track in a key.
+func (p *mixPlanner) chooseByCategory(state *mixState) int {
+	placed := p.totalTracks - len(p.remaining)
+	mustFill := p.mustFill(placed)
+	anchor := p.anchorAt(placed + 1)
+	exit := state.prev.ExitKey()
+	near := []track.Key{exit.Transpose(1), exit.Transpose(2), exit, exit.Relative()}
+	floor := p.floors(state, anchor)
+
+	for _, category := range categoryOrder(state) {
+		var scored []scoredCandidate
+		best := math.Inf(1)
+		if category < len(near) {
+			scored = p.scanKey(state, near[category], category, floor, &best, scored, mustFill, anchor)
+		} else {
+			for key := range p.byKey {
+				if !slices.Contains(near, key) {
+					scored = p.scanKey(state, key, category, floor, &best, scored, mustFill, anchor)
+				}
 			}
 		}
+		if idx, ok := p.pick(scored); ok {
+			return idx
+		}
 	}
+	return p.firstFree()
+}
 
-	order := categoryOrder(state)
-	for _, category := range order {
-		if buckets[category].set {
-			return buckets[category].idx
+// scoreFloors bound, for one placement, the parts of a candidate's score its own
+// track decides (see trackScore), so scanKey can tell which tracks can't catch up with
+// the best without scoring them.
+type scoreFloors struct {
+	prune    bool         // false when an anchor's setup cost, which isn't bounded, rules pruning out
+	lowest   int          // the lowest energy in the library, energy[w][0]'s
+	energy   [2][]float64 // the weighted energy cost of each energy, by whether the move wraps
+	least    [2]float64   // the least of each energy
+	bpmScale float64      // the least a tempo gap is scaled by: the prev genre's tolerance
+}
+
+// floors works out the scoreFloors for the placement after state.
+func (p *mixPlanner) floors(state *mixState, anchor int) scoreFloors {
+	f := scoreFloors{
+		prune:    anchor < 0 && len(p.stats.energySorted) > 0,
+		bpmScale: defaultBPMTolerance / state.exitBPMTolerance(),
+	}
+	if !f.prune {
+		return f
+	}
+	f.lowest = p.stats.energySorted[0]
+	span := p.stats.energySorted[len(p.stats.energySorted)-1] - f.lowest + 1
+	for w, wrap := range []bool{false, true} {
+		// Reuse the last placement's tables.
+		f.energy[w] = slices.Grow(p.floorCache[w][:0], span)[:span]
+		p.floorCache[w] = f.energy[w]
+		f.least[w] = math.Inf(1)
+		for e := range f.energy[w] {
+			cost := energyTransitionCost(state, track.Track{Energy: f.lowest + e}, Transition{Wrap: wrap}, p.stats, p.desiredCycleLength) * p.tuning.EnergyWeight
+			f.energy[w][e] = cost
+			f.least[w] = math.Min(f.least[w], cost)
 		}
 	}
+	return f
+}
 
+// bound is the least candidate, a track of a key whose score without its own track's
+// part is keyFloor, can score after state.
+func (p *mixPlanner) bound(state *mixState, f scoreFloors, keyFloor float64, candidate track.Track, wrap int) float64 {
+	gap := track.TempoGap(state.prev.ExitBPM(), candidate.EntryBPM(), state.tempoMatch)
+	return keyFloor + f.energy[wrap][candidate.Energy-f.lowest] +
+		bpmGapFloor(gap*f.bpmScale)*p.tuning.BPMWeight + priorityCost(state, candidate, p.coverage())
+}
+
+// pruneSlack keeps scanKey from skipping a track that rounding alone could tie.
+const pruneSlack = 1e-3
+
+// scanKey appends to scored the eligible tracks of key, scored as category, skipping
+// any whose bound puts it above best by more than closeFloat: pick could never take
+// it. It walks the key's tracks outward from each tempo prev matches, and stops a walk
+// once the tempo cost of the next track alone puts it out of reach, since the tracks
+// further out cost more still. So a placement scores a handful of tracks near the
+// tempo and energy it wants; only the cheap walk past the others grows with the
+// library. best is lowered as tracks are scored.
+func (p *mixPlanner) scanKey(state *mixState, key track.Key, category int, f scoreFloors, best *float64, scored []scoredCandidate, mustFill bool, anchor int) []scoredCandidate {
+	bucket := p.byKey[key]
+	if len(bucket) == 0 {
+		return scored
+	}
+	trans := KeyTransition(state.prev.ExitKey(), key)
+	trans.AnyKey = state.prev.AnyKey()
+	keyFloor := p.keyScore(state, key, trans)
+	if p.anyKey > 0 && !trans.AnyKey {
+		// A key-agnostic track of key moves there for less.
+		trans.AnyKey = true
+		keyFloor = math.Min(keyFloor, p.keyScore(state, key, trans))
+	}
+	wrap := boolIndex(trans.Wrap)
+	// The least any track of key can score but for its tempo; priorities cost no less
+	// than a filler's or a favorite's.
+	rest := keyFloor + f.least[wrap]
+	for _, priority := range []int{1, 5} {
+		rest += math.Min(0, priorityCost(state, track.Track{Priority: &priority}, p.coverage()))
+	}
+
+	// visit scores the track at bucket[at], or reports false once the walk from target
+	// is out of reach.
+	visit := func(at int, target float64) bool {
+		idx := bucket[at]
+		candidate := p.remaining[idx]
+		if f.prune {
+			gap := math.Abs(candidate.EntryBPM() - target)
+			if rest+bpmGapFloor(gap*f.bpmScale)*p.tuning.BPMWeight > *best+pruneSlack {
+				return false
+			}
+			if p.bound(state, f, keyFloor, candidate, wrap) > *best+pruneSlack {
+				return true
+			}
+		}
+		if p.eligible(idx, mustFill) {
+			trans := computeTransition(state, candidate)
+			score := p.candidateScore(state, candidate, trans, anchor)
+			scored = append(scored, scoredCandidate{idx: idx, category: category, trans: trans, score: score})
+			*best = math.Min(*best, score)
+		}
+		return true
+	}
+	for _, target := range state.tempoMatch.Matches(state.prev.ExitBPM()) {
+		from := sort.Search(len(bucket), func(i int) bool { return p.remaining[bucket[i]].EntryBPM() >= target })
+		for at := from; at < len(bucket) && visit(at, target); at++ {
+		}
+		for at := from - 1; at >= 0 && visit(at, target); at-- {
+		}
+	}
+	return scored
+}
+
+func boolIndex(b bool) int {
+	if b {
+		return 1
+	}
 	return 0
 }
 
+// pick chooses among one category's scored candidates: the lowest score, with the
+// candidates within closeFloat of it tied and settled by consider in remaining order.
+// The full scan and chooseByCategory both choose this way, so the tracks
+// chooseByCategory leaves unscored, which score above the lowest, can't change the
+// choice. It reports false when there are no candidates.
+func (p *mixPlanner) pick(scored []scoredCandidate) (int, bool) {
+	if len(scored) == 0 {
+		return -1, false
+	}
+	low := scored[0].score
+	for _, c := range scored[1:] {
+		low = math.Min(low, c.score)
+	}
+	var tied []scoredCandidate
+	for _, c := range scored {
+		if c.score <= low+1e-6 {
+			tied = append(tied, c)
+		}
+	}
+	sort.Slice(tied, func(a, b int) bool { return tied[a].idx < tied[b].idx })
+
+	var best choice
+	for i, c := range tied {
+		// A track scanned from two matching tempos is scored twice; it counts once.
+		if i == 0 || c.idx != tied[i-1].idx {
+			p.consider(&best, c.idx, c.score)
+		}
+	}
+	return best.idx, true
+}
+
+// choice is the best candidate seen so far in one category.
+type choice struct {
+	idx   int
+	score float64
+	set   bool
+}
+
+// eligible reports whether the track at idx may be placed next: it is not held for a
+// pin, and it is a must-include track when only those still fit.
+func (p *mixPlanner) eligible(idx int, mustFill bool) bool {
+	return p.slot[idx] < 0 && (!mustFill || p.required[idx])
+}
+
+// candidateScore is the transition score for candidate, plus the cost of setting up
+// the anchor pinned to the next position, if there is one.
+func (p *mixPlanner) candidateScore(state *mixState, candidate track.Track, trans Transition, anchor int) float64 {
+	score := p.transitionScoreWithTransition(state, candidate, trans)
+	if anchor >= 0 {
+		// Set up a pinned track due next instead of arriving at it cold.
+		score += p.tuning.KeyWeight * coherenceCost(candidate, p.remaining[anchor], DefaultWeights)
+	}
+	return score
+}
+
+// consider keeps idx as best if it scores lower or, on a tie, if the tie-break policy
+// prefers it; a coin flip decides the ties the policy can't.
+func (p *mixPlanner) consider(best *choice, idx int, score float64) {
+	switch {
+	case !best.set || score < best.score-1e-6:
+		*best = choice{idx: idx, score: score, set: true}
+	case closeFloat(score, best.score):
+		pref := p.tieBreak.prefer(p.remaining[idx], p.remaining[best.idx])
+		if pref < 0 || pref == 0 && p.rng.Intn(2) == 0 {
+			best.idx = idx
+			best.score = score
+		}
+	}
+}
+
 func categoryOrder(state *mixState) []int {
 	order := []int{0, 1, 2, 3, 4}
 	if state == nil || !state.prevSet {
 		return order
 	}
 
-	if state.stepsSinceStep2 >= varietyStepThreshold {
+	if state.stepsSinceStep2 >= state.tuning.VarietyStepThreshold {
 		order = []int{1, 0, 2, 3, 4}
-	} else if state.stepsSinceStep1 >= varietyStepThreshold {
+	} else if state.stepsSinceStep1 >= state.tuning.VarietyStepThreshold {
 		order = []int{0, 1, 2, 3, 4}
 	}
 
-	if state.stepsSinceLetterFlip >= varietyLetterThreshold {
+	if state.stepsSinceLetterFlip >= state.tuning.VarietyLetterThreshold {
 		order = append([]int{3}, order...)
 	}
+	if want := state.excursionNext(); want >= 0 {
+		order = append([]int{want}, order...)
+	}
 
 	seen := make(map[int]struct{}, len(order))
 	unique := make([]int, 0, len(order))
@@ -404,68 +839,75 @@ func categoryOrder(state *mixState) []int {
 	return unique
 }
 
-func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate track.Track, trans transition) float64 {
-	keyCost := keyTransitionCost(state, trans)
+func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate track.Track, trans Transition) float64 {
+	return p.keyScore(state, candidate.Key, trans) + p.trackScore(state, candidate, trans)
+}
+
+// trackScore is the part of a candidate's score its own energy, tempo, and priority
+// decide. The rest, keyScore, is the same for every track in the candidate's key.
+func (p *mixPlanner) trackScore(state *mixState, candidate track.Track, trans Transition) float64 {
 	energyCost := energyTransitionCost(state, candidate, trans, p.stats, p.desiredCycleLength)
 	bpmCost := bpmTransitionCost(state, candidate, p.stats)
+	return bpmCost*p.tuning.BPMWeight + energyCost*p.tuning.EnergyWeight + priorityCost(state, candidate, p.coverage())
+}
+
+// keyScore is the part of the score for moving into key by trans that doesn't depend
+// on which track of that key moves there: the key move itself and the inventory of
+// keys left.
+func (p *mixPlanner) keyScore(state *mixState, key track.Key, trans Transition) float64 {
+	keyCost := keyTransitionCost(state, trans)
 
-	remainingCount := float64(p.countsByNumber[candidate.Key.Number])
+	remainingCount := float64(p.countsByNumber[key.Number])
 	flexCost := 0.0
 	if remainingCount > 0 {
 		flexCost = 1.0 / remainingCount
 	}
 
-	total := keyCost*keyWeight + bpmCost*bpmWeight + energyCost*energyWeight + flexCost
-
-	coverage := 1.0
-	if p.totalTracks > 0 {
-		coverage = float64(p.targetCount) / float64(p.totalTracks)
-		if coverage > 1 {
-			coverage = 1
-		}
-		if coverage < 0.1 {
-			coverage = 0.1
-		}
+	total := keyCost*p.tuning.KeyWeight + flexCost
+	if p.strictCamelot && state.prevSet && trans.Diagonal() {
+		total += strictPlannerCost
 	}
 
+	coverage := p.coverage()
+
 	if state.prevSet {
-		if trans.diff > 0 {
-			if remainingCurrent := float64(p.countsByNumber[state.prev.Key.Number]); remainingCurrent > 0 {
+		if trans.Steps > 0 {
+			if remainingCurrent := float64(p.countsByNumber[state.prev.ExitKey().Number]); remainingCurrent > 0 {
 				total += remainingCurrent * 0.5 * coverage
 			}
-			if remainingMode := float64(p.countsByKey[state.prev.Key]); remainingMode > 0 {
+			if remainingMode := float64(p.countsByKey[state.prev.ExitKey()]); remainingMode > 0 {
 				total += remainingMode * 1.0 * coverage
 			}
 		}
 
-		if trans.diff >= 3 && hasShallowStepOption(state, p, 2) {
-			total += float64((trans.diff-2)*8) + 18
+		if trans.Steps >= 3 && hasShallowStepOption(state, p, 2) {
+			total += float64((trans.Steps-2)*8) + 18
 		}
 	}
 
 	if state.prevSet {
-		if trans.diff == 1 {
-			if state.stepsSinceStep1 >= varietyStepThreshold {
-				bonus := float64(state.stepsSinceStep1-varietyStepThreshold+1) * varietyStepWeight
+		if trans.Steps == 1 {
+			if state.stepsSinceStep1 >= p.tuning.VarietyStepThreshold {
+				bonus := float64(state.stepsSinceStep1-p.tuning.VarietyStepThreshold+1) * p.tuning.VarietyStepWeight
 				total -= bonus
 			}
-			if state.stepsSinceStep2 >= varietyStepThreshold+1 {
-				pen := float64(state.stepsSinceStep2-varietyStepThreshold) * (varietyStepWeight * 0.7)
+			if state.stepsSinceStep2 >= p.tuning.VarietyStepThreshold+1 {
+				pen := float64(state.stepsSinceStep2-p.tuning.VarietyStepThreshold) * (p.tuning.VarietyStepWeight * 0.7)
 				total += pen
 			}
 		}
-		if trans.diff == 2 {
-			if state.stepsSinceStep2 >= varietyStepThreshold {
-				bonus := float64(state.stepsSinceStep2-varietyStepThreshold+1) * varietyStepWeight
+		if trans.Steps == 2 {
+			if state.stepsSinceStep2 >= p.tuning.VarietyStepThreshold {
+				bonus := float64(state.stepsSinceStep2-p.tuning.VarietyStepThreshold+1) * p.tuning.VarietyStepWeight
 				total -= bonus
 			}
-			if state.stepsSinceStep1 >= varietyStepThreshold+1 {
-				pen := float64(state.stepsSinceStep1-varietyStepThreshold) * (varietyStepWeight * 0.7)
+			if state.stepsSinceStep1 >= p.tuning.VarietyStepThreshold+1 {
+				pen := float64(state.stepsSinceStep1-p.tuning.VarietyStepThreshold) * (p.tuning.VarietyStepWeight * 0.7)
 				total += pen
 			}
 		}
-		if trans.diff == 0 && trans.modeChange && state.stepsSinceLetterFlip >= varietyLetterThreshold {
-			bonus := float64(state.stepsSinceLetterFlip-varietyLetterThreshold+1) * varietyLetterWeight
+		if trans.Steps == 0 && trans.ModeChange && state.stepsSinceLetterFlip >= p.tuning.VarietyLetterThreshold {
+			bonus := float64(state.stepsSinceLetterFlip-p.tuning.VarietyLetterThreshold+1) * p.tuning.VarietyLetterWeight
 			total -= bonus
 		}
 	}
@@ -474,28 +916,28 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 	// large clusters sooner.
 	baseWeight := 0.6 * coverage
 	total -= remainingCount * baseWeight
-	total -= float64(p.countsByKey[candidate.Key]) * baseWeight
+	total -= float64(p.countsByKey[key]) * baseWeight
 
 	// Encourage candidates matching start-of-cycle energy expectations when a wrap is imminent.
-	if trans.wrap && trans.diff > 2 && state.tracksInCycle < cycleMinTracks {
+	if trans.Wrap && trans.Steps > 2 && state.tracksInCycle < p.tuning.CycleMinTracks {
 		total += 7
 	}
 
-	if trans.wrap && trans.diff > 2 {
+	if trans.Wrap && trans.Steps > 2 {
 		// Slight extra penalty for wrapping with large jumps while previous key still has inventory.
 		if state.prevSet {
-			if remainingCurrent := float64(p.countsByNumber[state.prev.Key.Number]); remainingCurrent > 0 {
+			if remainingCurrent := float64(p.countsByNumber[state.prev.ExitKey().Number]); remainingCurrent > 0 {
 				total += remainingCurrent * 2
 			}
 		}
 	}
 
-	if state.prevSet && trans.diff == 0 {
+	if state.prevSet && trans.Steps == 0 {
 		total += float64(state.sameNumberStreak+1) * 6
 	}
 
 	// Enhanced key number run penalty system per user requirements
-	if state.prevSet && candidate.Key.Number == state.prev.Key.Number {
+	if state.prevSet && key.Number == state.prev.ExitKey().Number {
 		runLength := state.keyNumberRunLength + 1
 		switch {
 		case runLength <= 2:
@@ -524,7 +966,7 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 		mixProgress := tracksPlayed / originalTotal
 
 		// Calculate variety opportunity score for this candidate
-		varietyScore := calculateVarietyOpportunityScore(p, candidate, mixProgress, totalRemaining)
+		varietyScore := calculateVarietyOpportunityScore(p, key, mixProgress, totalRemaining)
 		total += varietyScore
 	}
 
@@ -533,12 +975,12 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 	tracksPlayed := originalTotal - float64(len(p.remaining))
 	mixProgress := tracksPlayed / originalTotal // 0.0 = start, 1.0 = end
 
-	keyCount := float64(p.countsByNumber[candidate.Key.Number])
+	keyCount := float64(p.countsByNumber[key.Number])
 	keyInventoryRatio := keyCount / originalTotal
 
 	// Calculate ideal burn rate for this position in the mix
 	idealKeysUsedByNow := keyCount * mixProgress
-	actualKeysUsed := keyCount - float64(countRemainingForKey(p.remaining, candidate.Key.Number))
+	actualKeysUsed := keyCount - float64(p.countsByNumber[key.Number])
 	burnRateDeviation := actualKeysUsed - idealKeysUsedByNow
 
 	// Apply position-aware burn rate pressure
@@ -592,8 +1034,8 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 
 	// NEW: Smart distribution-based scoring
 	if state.prevSet {
-		stepsSince := float64(state.stepsSinceKeyNumber[candidate.Key.Number])
-		targetInterval := p.targetIntervals[candidate.Key.Number]
+		stepsSince := float64(state.stepsSinceKeyNumber[key.Number])
+		targetInterval := p.targetIntervals[key.Number]
 
 		if targetInterval > 0 {
 			// Calculate how overdue this key is based on target spacing
@@ -607,7 +1049,7 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 				// This key is overdue - give it a bonus
 				bonus := (overdueFactor - 1.2) * 20.0
 				total -= bonus
-			} else if overdueFactor < 0.4 && candidate.Key.Number == state.prev.Key.Number {
+			} else if overdueFactor < 0.4 && key.Number == state.prev.ExitKey().Number {
 				// This key was just used and is not due yet - strong penalty for consecutive use
 				prematurePenalty := (0.4 - overdueFactor) * 60.0
 				total += prematurePenalty
@@ -615,17 +1057,17 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 		}
 
 		// ENHANCED: Proactive run prevention with lookahead
-		if state.keyNumberRunLength >= 3 && candidate.Key.Number != state.prev.Key.Number {
+		if state.keyNumberRunLength >= 3 && key.Number != state.prev.ExitKey().Number {
 			// Reward breaking out of long runs
 			breakoutBonus := float64(state.keyNumberRunLength-2) * 25.0
 			total -= breakoutBonus
 		}
 
 		// NEW: Predictive run prevention - look ahead to prevent future long runs
-		if candidate.Key.Number == state.prev.Key.Number {
+		if key.Number == state.prev.ExitKey().Number {
 			// This would extend the current run - calculate future risk
 			futureRunLength := state.keyNumberRunLength + 1
-			keysRemainingOfThisType := float64(countRemainingForKey(p.remaining, candidate.Key.Number))
+			keysRemainingOfThisType := float64(p.countsByNumber[key.Number])
 
 			// If we're at high risk of creating a very long run, severely penalize
 			if futureRunLength >= 2 && keysRemainingOfThisType > 5 && mixProgress > 0.7 {
@@ -640,9 +1082,9 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 		}
 
 		// NEW: Diversity promotion - prefer keys that create better variety
-		if candidate.Key.Number != state.prev.Key.Number {
+		if key.Number != state.prev.ExitKey().Number {
 			// This creates variety - check if we should give it extra credit
-			keysOfThisTypeRemaining := float64(countRemainingForKey(p.remaining, candidate.Key.Number))
+			keysOfThisTypeRemaining := float64(p.countsByNumber[key.Number])
 			totalKeysRemaining := float64(len(p.remaining))
 
 			if keysOfThisTypeRemaining/totalKeysRemaining < 0.15 { // This key type is becoming rare
@@ -656,26 +1098,15 @@ func (p *mixPlanner) transitionScoreWithTransition(state *mixState, candidate tr
 	return total
 }
 
-// countRemainingForKey counts how many tracks of a specific key number remain
-func countRemainingForKey(remaining []track.Track, keyNumber int) int {
-	count := 0
-	for _, t := range remaining {
-		if t.Key.Number == keyNumber {
-			count++
-		}
-	}
-	return count
-}
-
 // calculateVarietyOpportunityScore determines how much to prefer/penalize a candidate
 // based on strategic variety management to prevent late-game monotony
-func calculateVarietyOpportunityScore(p *mixPlanner, candidate track.Track, mixProgress, totalRemaining float64) float64 {
-	keyNumber := candidate.Key.Number
+func calculateVarietyOpportunityScore(p *mixPlanner, key track.Key, mixProgress, totalRemaining float64) float64 {
+	keyNumber := key.Number
 	keyCount := float64(p.countsByNumber[keyNumber])
 	originalTotal := float64(p.totalTracks)
 	keyInventoryRatio := keyCount / originalTotal
 
-	keysRemainingOfThisType := float64(countRemainingForKey(p.remaining, keyNumber))
+	keysRemainingOfThisType := float64(p.countsByNumber[keyNumber])
 
 	// Strategy 1: Aggressive early burning of high-frequency keys
 	if keyInventoryRatio > 0.15 { // High frequency keys like 10A/10B (29 tracks)
@@ -714,28 +1145,28 @@ func calculateVarietyOpportunityScore(p *mixPlanner, candidate track.Track, mixP
 	return 0.0
 }
 
-func categorizeTransition(state *mixState, trans transition) int {
+func categorizeTransition(state *mixState, trans Transition) int {
 	if !state.prevSet {
 		return 0
 	}
 
-	if trans.modeChange && trans.diff > 0 {
+	if trans.ModeChange && trans.Steps > 0 {
 		return 4
 	}
 
-	switch trans.diff {
+	switch trans.Steps {
 	case 1:
-		if trans.modeChange {
+		if trans.ModeChange {
 			return 4
 		}
 		return 0
 	case 2:
-		if trans.modeChange {
+		if trans.ModeChange {
 			return 4
 		}
 		return 1
 	case 0:
-		if trans.modeChange {
+		if trans.ModeChange {
 			return 3
 		}
 		return 2
@@ -744,31 +1175,31 @@ func categorizeTransition(state *mixState, trans transition) int {
 	}
 }
 
+// hasShallowStepOption reports whether any remaining track is reachable from the
+// previous track in at most maxStep clockwise steps without a mode change, or sits on
+// the same number in either mode.
 func hasShallowStepOption(state *mixState, p *mixPlanner, maxStep int) bool {
 	if !state.prevSet {
 		return true
 	}
-	for _, cand := range p.remaining {
-		trans := computeTransition(state, cand)
-		if trans.diff == 0 {
-			return true
-		}
-		if trans.modeChange {
-			continue
-		}
-		if trans.diff > 0 && trans.diff <= maxStep {
+	exit := state.prev.ExitKey()
+	if p.countsByNumber[exit.Number] > 0 {
+		return true
+	}
+	for step := 1; step <= maxStep; step++ {
+		if p.countsByKey[exit.Transpose(step)] > 0 {
 			return true
 		}
 	}
 	return false
 }
 
-func keyTransitionCost(state *mixState, trans transition) float64 {
-	if !state.prevSet {
+func keyTransitionCost(state *mixState, trans Transition) float64 {
+	if !state.prevSet || trans.AnyKey {
 		return 0
 	}
 
-	diff := trans.diff
+	diff := trans.Steps
 	cost := 0.0
 
 	switch diff {
@@ -784,13 +1215,13 @@ func keyTransitionCost(state *mixState, trans transition) float64 {
 		cost = float64(diff*diff) / 2
 	}
 
-	if trans.modeChange && diff > 0 {
+	if trans.ModeChange && diff > 0 {
 		// Strongly discourage changing mode while stepping the number forward, only allow if
 		// no alternatives exist.
 		cost += 12
 	}
 
-	if trans.wrap && diff > 2 {
+	if trans.Wrap && diff > 2 {
 		// Discourage large downward wraps; relax as the cycle matures or inventory dwindles.
 		basePenalty := 6.0
 		if state.tracksInCycle >= state.desiredCycleLen-1 {
@@ -804,7 +1235,10 @@ func keyTransitionCost(state *mixState, trans transition) float64 {
 	return cost
 }
 
-func energyTransitionCost(state *mixState, candidate track.Track, trans transition, stats mixStats, desiredCycleLen int) float64 {
+func energyTransitionCost(state *mixState, candidate track.Track, trans Transition, stats mixStats, desiredCycleLen int) float64 {
+	if state.arc != nil {
+		return arcEnergyCost(state, candidate)
+	}
 	energy := float64(candidate.Energy)
 
 	if !state.prevSet {
@@ -815,17 +1249,17 @@ func energyTransitionCost(state *mixState, candidate track.Track, trans transiti
 	delta := energy - float64(state.prev.Energy)
 	drop := -delta
 
-	if trans.wrap {
+	if trans.Wrap {
 		target := stats.energyLow
 		cost := math.Abs(energy-target) / 6
 		if drop < 12 {
 			cost += (12 - drop) / 6
 		}
-		if drop >= energyDropThreshold && state.stepsSinceEnergyDrop >= varietyEnergyThreshold {
-			bonus := float64(state.stepsSinceEnergyDrop-varietyEnergyThreshold+1) * varietyEnergyReward
+		if drop >= state.tuning.EnergyDropThreshold && state.stepsSinceEnergyDrop >= state.tuning.VarietyEnergyThreshold {
+			bonus := float64(state.stepsSinceEnergyDrop-state.tuning.VarietyEnergyThreshold+1) * state.tuning.VarietyEnergyReward
 			cost -= bonus
-		} else if drop < energyDropThreshold && state.stepsSinceEnergyDrop >= varietyEnergyThreshold+2 {
-			penalty := float64(state.stepsSinceEnergyDrop-(varietyEnergyThreshold+1)) * varietyEnergyPenalty
+		} else if drop < state.tuning.EnergyDropThreshold && state.stepsSinceEnergyDrop >= state.tuning.VarietyEnergyThreshold+2 {
+			penalty := float64(state.stepsSinceEnergyDrop-(state.tuning.VarietyEnergyThreshold+1)) * state.tuning.VarietyEnergyPenalty
 			cost += penalty
 		}
 		if cost < -5 {
@@ -847,11 +1281,11 @@ func energyTransitionCost(state *mixState, candidate track.Track, trans transiti
 		cost += (delta - 12) / 8
 	}
 
-	if drop >= energyDropThreshold && state.stepsSinceEnergyDrop >= varietyEnergyThreshold {
-		bonus := float64(state.stepsSinceEnergyDrop-varietyEnergyThreshold+1) * varietyEnergyReward
+	if drop >= state.tuning.EnergyDropThreshold && state.stepsSinceEnergyDrop >= state.tuning.VarietyEnergyThreshold {
+		bonus := float64(state.stepsSinceEnergyDrop-state.tuning.VarietyEnergyThreshold+1) * state.tuning.VarietyEnergyReward
 		cost -= bonus
-	} else if drop < energyDropThreshold && state.stepsSinceEnergyDrop >= varietyEnergyThreshold+2 {
-		penalty := float64(state.stepsSinceEnergyDrop-(varietyEnergyThreshold+1)) * varietyEnergyPenalty
+	} else if drop < state.tuning.EnergyDropThreshold && state.stepsSinceEnergyDrop >= state.tuning.VarietyEnergyThreshold+2 {
+		penalty := float64(state.stepsSinceEnergyDrop-(state.tuning.VarietyEnergyThreshold+1)) * state.tuning.VarietyEnergyPenalty
 		cost += penalty
 	}
 
@@ -862,6 +1296,29 @@ func energyTransitionCost(state *mixState, candidate track.Track, trans transiti
 	return cost
 }
 
+// arcEnergyCost stands in for the energy cycles when WithArc shapes the set: the cost
+// of straying from the arc's target at the next position, plus a jump too big to
+// blend whatever the arc asks for.
+func arcEnergyCost(state *mixState, candidate track.Track) float64 {
+	energy := float64(candidate.Energy)
+	cost := math.Abs(energy-state.arcTarget()) / 6
+	if state.prevSet {
+		if jump := math.Abs(energy - float64(state.prev.Energy)); jump > 15 {
+			cost += (jump - 15) / 8
+		}
+	}
+	return cost
+}
+
+// arcTarget is the arc's target energy for the track after prev.
+func (state *mixState) arcTarget() float64 {
+	next := state.position
+	if state.prevSet {
+		next++
+	}
+	return state.arc.energy(float64(next) / float64(max(state.lastPosition, 1)))
+}
+
 func nextEnergyTarget(state *mixState, stats mixStats, desiredCycleLen int) float64 {
 	base := state.cycleStartEnergy
 	high := stats.energyHigh
@@ -877,13 +1334,43 @@ func nextEnergyTarget(state *mixState, stats mixStats, desiredCycleLen int) floa
 	return base + (high-base)*progress
 }
 
+// coverage is the share of the library the set will hold, between 0.1 and 1.
+func (p *mixPlanner) coverage() float64 {
+	if p.totalTracks <= 0 {
+		return 1
+	}
+	return math.Max(0.1, math.Min(1, float64(p.targetCount)/float64(p.totalTracks)))
+}
+
+// priorityCost rewards a favorite (Priority above 3) and charges filler (below 3) in
+// two ways: by how much of the library the set leaves out, so favorites survive a
+// limit and filler goes first, and by where the current energy cycle stands, so
+// favorites land near each peak and filler near each reset. A track without a
+// priority costs nothing.
+func priorityCost(state *mixState, candidate track.Track, coverage float64) float64 {
+	if candidate.Priority == nil || state.tuning == nil {
+		return 0
+	}
+	progress := 0.0
+	if state.prevSet && state.desiredCycleLen > 0 {
+		progress = math.Min(1, float64(state.tracksInCycle)/float64(state.desiredCycleLen))
+	}
+	lift := float64(*candidate.Priority - 3)
+	return -lift * state.tuning.PriorityWeight * ((1 - coverage) + (progress - 0.5))
+}
+
 func bpmTransitionCost(state *mixState, candidate track.Track, stats mixStats) float64 {
 	if !state.prevSet {
 		return math.Abs(candidate.BPM-stats.bpmMedian) / 6
 	}
 
-	diff := math.Abs(candidate.BPM - state.prev.BPM)
+	diff := track.TempoGap(state.prev.ExitBPM(), candidate.EntryBPM(), state.tempoMatch)
+	diff *= defaultBPMTolerance / state.transitionBPMTolerance(candidate)
+	return bpmGapCost(diff)
+}
 
+// bpmGapCost is the cost of a tempo gap of diff BPM, scaled to defaultBPMTolerance.
+func bpmGapCost(diff float64) float64 {
 	if diff <= 1 {
 		return diff * 0.2
 	}
@@ -897,6 +1384,18 @@ func bpmTransitionCost(state *mixState, candidate track.Track, stats mixStats) f
 	return 2 + (diff-5)*0.7
 }
 
+// bpmGapFloor is the least bpmGapCost of any gap of diff or more. The cost dips just
+// past 2.5 and 5, where each stretch of it starts, so it is not the cost of diff.
+func bpmGapFloor(diff float64) float64 {
+	floor := bpmGapCost(diff)
+	for _, start := range []float64{2.5, 5} {
+		if diff <= start {
+			floor = math.Min(floor, bpmGapCost(math.Nextafter(start, math.Inf(1))))
+		}
+	}
+	return floor
+}
+
 func (p *mixPlanner) take(idx int) track.Track {
 	selected := p.remaining[idx]
 
@@ -910,13 +1409,89 @@ func (p *mixPlanner) take(idx int) track.Track {
 		delete(p.countsByNumber, selected.Key.Number)
 	}
 
+	switch {
+	case p.slot[idx] >= 0:
+		p.pinned--
+	case p.required[idx]:
+		p.waiting--
+	}
+	if selected.AnyKey() {
+		p.anyKey--
+	}
+
 	last := len(p.remaining) - 1
+	p.unindex(selected.Key, idx)
+	if idx != last {
+		p.reindex(p.remaining[last].Key, last, idx)
+	}
 	p.remaining[idx] = p.remaining[last]
 	p.remaining = p.remaining[:last]
+	p.slot[idx] = p.slot[last]
+	p.slot = p.slot[:last]
+	p.required[idx] = p.required[last]
+	p.required = p.required[:last]
 
 	return selected
 }
 
+// unindex drops position idx from key's bucket in byKey.
+func (p *mixPlanner) unindex(key track.Key, idx int) {
+	bucket := p.byKey[key]
+	at := p.locate(bucket, idx)
+	bucket = slices.Delete(bucket, at, at+1)
+	if len(bucket) == 0 {
+		delete(p.byKey, key)
+		return
+	}
+	p.byKey[key] = bucket
+}
+
+// reindex records that the track of key at position from has moved to position to.
+func (p *mixPlanner) reindex(key track.Key, from, to int) {
+	bucket := p.byKey[key]
+	bucket[p.locate(bucket, from)] = to
+}
+
+// locate finds position idx in bucket, which is in EntryBPM order.
+func (p *mixPlanner) locate(bucket []int, idx int) int {
+	bpm := p.remaining[idx].EntryBPM()
+	at := sort.Search(len(bucket), func(i int) bool { return p.remaining[bucket[i]].EntryBPM() >= bpm })
+	for bucket[at] != idx {
+		at++
+	}
+	return at
+}
+
+// anchorAt returns the index in remaining of the track pinned to position pos, or -1.
+func (p *mixPlanner) anchorAt(pos int) int {
+	if p.pinned == 0 {
+		return -1
+	}
+	for idx, at := range p.slot {
+		if at == pos {
+			return idx
+		}
+	}
+	return -1
+}
+
+// firstFree returns the first remaining track not held for a pin, or 0 when every
+// remaining track is pinned.
+func (p *mixPlanner) firstFree() int {
+	for idx, at := range p.slot {
+		if at < 0 {
+			return idx
+		}
+	}
+	return 0
+}
+
+// mustFill reports whether, with placed tracks already in the set, the positions left
+// over after the pins are only enough for the must-include tracks still waiting.
+func (p *mixPlanner) mustFill(placed int) bool {
+	return p.waiting > 0 && p.targetCount-placed-p.pinned <= p.waiting
+}
+
 func (p *mixPlanner) remainingCount() int {
 	return len(p.remaining)
 }
@@ -939,9 +1514,11 @@ func (state *mixState) advance(next track.Track) {
 
 	previous := state.prev
 	trans := computeTransition(state, next)
+	state.advanceExcursion(categorizeTransition(state, trans))
 
 	state.prev = next
-	if trans.wrap {
+	state.position++
+	if trans.Wrap {
 		state.cycleIndex++
 		state.tracksInCycle = 1
 		state.cycleStartEnergy = float64(next.Energy)
@@ -949,14 +1526,14 @@ func (state *mixState) advance(next track.Track) {
 		state.tracksInCycle++
 	}
 
-	if trans.diff == 0 {
+	if trans.Steps == 0 {
 		state.sameNumberStreak++
 	} else {
 		state.sameNumberStreak = 0
 	}
 
 	// Track key number runs (7A->7B->7A = 3 in a row)
-	if next.Key.Number == previous.Key.Number {
+	if next.Key.Number == previous.ExitKey().Number {
 		state.keyNumberRunLength++
 	} else {
 		state.keyNumberRunLength = 1
@@ -966,8 +1543,10 @@ func (state *mixState) advance(next track.Track) {
 	for keyNum := range state.stepsSinceKeyNumber {
 		state.stepsSinceKeyNumber[keyNum]++
 	}
-	// Reset counter for the key we just used
-	state.stepsSinceKeyNumber[next.Key.Number] = 0
+	// Reset counters for the keys we just used (all of them, for a track that modulates)
+	for _, k := range next.Keys() {
+		state.stepsSinceKeyNumber[k.Number] = 0
+	}
 
 	cappedIncrement := func(v int) int {
 		if v < 100 {
@@ -981,40 +1560,28 @@ func (state *mixState) advance(next track.Track) {
 	state.stepsSinceLetterFlip = cappedIncrement(state.stepsSinceLetterFlip)
 	state.stepsSinceEnergyDrop = cappedIncrement(state.stepsSinceEnergyDrop)
 
-	if trans.diff == 1 {
+	if trans.Steps == 1 {
 		state.stepsSinceStep1 = 0
 	}
-	if trans.diff == 2 {
+	if trans.Steps == 2 {
 		state.stepsSinceStep2 = 0
 	}
-	if previous.Key.Mode != next.Key.Mode {
+	if previous.ExitKey().Mode != next.Key.Mode {
 		state.stepsSinceLetterFlip = 0
 	}
 
 	energyDelta := float64(next.Energy - previous.Energy)
-	if -energyDelta >= energyDropThreshold {
+	if -energyDelta >= state.tuning.EnergyDropThreshold {
 		state.stepsSinceEnergyDrop = 0
 	}
 }
 
-func computeTransition(state *mixState, candidate track.Track) transition {
+func computeTransition(state *mixState, candidate track.Track) Transition {
 	if !state.prevSet {
-		return transition{}
+		return Transition{}
 	}
 
-	prevNumber := state.prev.Key.Number
-	nextNumber := candidate.Key.Number
-
-	diff := nextNumber - prevNumber
-	wrap := false
-	if diff < 0 {
-		diff += 12
-		wrap = true
-	}
-
-	modeChange := candidate.Key.Mode != state.prev.Key.Mode
-
-	return transition{diff: diff, wrap: wrap, modeChange: modeChange}
+	return NewTransition(state.prev, candidate)
 }
 
 func quantileInts(values []int, q float64) float64 {
@@ -2,6 +2,8 @@ package strategy_test
 
 import (
 	"context"
+	"math"
+	"strings"
 	"testing"
 
 	"github.com/YakDriver/magicmix/internal/strategy"
@@ -94,6 +96,160 @@ func TestDefaultSorterCamelotProgression(t *testing.T) {
 	}
 }
 
+func TestDefaultSorterRecordsDecisions(t *testing.T) {
+	tracks := sampleTracks(t)
+	ctx := strategy.WithSeed(context.Background(), 12345)
+
+	plain, err := strategy.NewDefaultSorter().Sort(ctx, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+
+	var decisions []strategy.Decision
+	logged := strategy.WithDecisionRecorder(ctx, func(d strategy.Decision) { decisions = append(decisions, d) })
+	recorded, err := strategy.NewDefaultSorter().Sort(logged, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort with recorder returned error: %v", err)
+	}
+
+	if len(decisions) != len(recorded) {
+		t.Fatalf("got %d decisions for %d placements", len(decisions), len(recorded))
+	}
+	for i, d := range decisions {
+		if plain[i].Title != recorded[i].Title {
+			t.Fatalf("recording changed the order at %d: %q vs %q", i, plain[i].Title, recorded[i].Title)
+		}
+		if d.Position != i || d.Chosen < 0 || d.Candidates[d.Chosen].Title != recorded[i].Title {
+			t.Fatalf("decision %d does not describe placement %q: %+v", i, recorded[i].Title, d)
+		}
+		if i > 0 && len(d.CategoryOrder) == 0 {
+			t.Fatalf("decision %d has no category order", i)
+		}
+	}
+}
+
+func TestSortExplainsDefaultPlacements(t *testing.T) {
+	tracks := sampleTracks(t)
+	ctx := strategy.WithSeed(context.Background(), 12345)
+
+	plain, err := strategy.Sort(ctx, strategy.NewDefaultSorter(), cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	explained, err := strategy.Sort(strategy.WithExplain(ctx), strategy.NewDefaultSorter(), cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort with explain returned error: %v", err)
+	}
+	if plain.Explanations != nil {
+		t.Fatalf("explanations without asking: %v", plain.Explanations)
+	}
+	if len(explained.Explanations) != len(explained.Ordered) {
+		t.Fatalf("got %d explanations for %d tracks", len(explained.Explanations), len(explained.Ordered))
+	}
+	for i, why := range explained.Explanations {
+		if plain.Ordered[i].Title != explained.Ordered[i].Title {
+			t.Fatalf("explaining changed the order at %d", i)
+		}
+		want := "key " + explained.Ordered[i-min(i, 1)].ExitKey().String() + " → " + explained.Ordered[i].Key.String()
+		if i == 0 {
+			want = "opener"
+		}
+		if !strings.HasPrefix(why, want) {
+			t.Errorf("explanation %d = %q, want it to start %q", i, why, want)
+		}
+	}
+	if len(explained.Notes) != len(explained.Ordered)+1 {
+		t.Errorf("got %d notes, want a heading and one per track", len(explained.Notes))
+	}
+
+	other, err := strategy.Sort(strategy.WithExplain(ctx), strategy.NewFlowSorter(), cloneTracks(tracks))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if other.Explanations != nil {
+		t.Errorf("flow explained its placements: %v", other.Explanations)
+	}
+}
+
+func TestDefaultSorterTuning(t *testing.T) {
+	tracks := loadRealData(t)[:60]
+	ctx := strategy.WithSeed(context.Background(), 12345)
+	bpmTravel := func(ordered []track.Track) float64 {
+		total := 0.0
+		for i := 1; i < len(ordered); i++ {
+			total += math.Abs(ordered[i].BPM - ordered[i-1].BPM)
+		}
+		return total
+	}
+
+	plain, err := strategy.NewDefaultSorter().Sort(ctx, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	viaContext, err := strategy.NewDefaultSorter().Sort(strategy.WithTuning(ctx, strategy.DefaultTuning), cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	for i := range plain {
+		if plain[i].Title != viaContext[i].Title {
+			t.Fatalf("DefaultTuning should plan as the stock sorter does; differs at %d", i)
+		}
+	}
+
+	tight := strategy.DefaultTuning
+	tight.BPMWeight = 20
+	tighter, err := strategy.NewDefaultSorterWith(tight).Sort(ctx, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	if bpmTravel(tighter) >= bpmTravel(plain) {
+		t.Fatalf("a heavier BPM weight should cut tempo travel: %.1f vs %.1f", bpmTravel(tighter), bpmTravel(plain))
+	}
+
+	bad := strategy.DefaultTuning
+	bad.CycleMaxTracks = 2
+	if bad.Validate() == nil {
+		t.Fatal("a max cycle shorter than the ideal should not validate")
+	}
+}
+
+func TestDefaultSorterFavorsPriority(t *testing.T) {
+	tracks := cloneTracks(loadRealData(t)[:60])
+	favorite, filler := 5, 1
+	for i := range tracks {
+		if i%2 == 0 {
+			tracks[i].Priority = &favorite
+		} else {
+			tracks[i].Priority = &filler
+		}
+	}
+	count := func(ordered []track.Track) int {
+		n := 0
+		for _, tr := range ordered {
+			if *tr.Priority == favorite {
+				n++
+			}
+		}
+		return n
+	}
+	ctx := strategy.WithLimit(strategy.WithSeed(context.Background(), 12345), 20)
+
+	ranked, err := strategy.NewDefaultSorter().Sort(ctx, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	flat := strategy.DefaultTuning
+	flat.PriorityWeight = 0
+	ignored, err := strategy.NewDefaultSorterWith(flat).Sort(ctx, cloneTracks(tracks))
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	if count(ranked) <= count(ignored) || count(ranked) <= len(ranked)/2 {
+		t.Fatalf("favorites should outnumber filler in a 20-track cut: %d of %d (%d without priority)",
+			count(ranked), len(ranked), count(ignored))
+	}
+}
+
 func sampleTracks(t *testing.T) []track.Track {
 	t.Helper()
 	rows := []struct {
@@ -0,0 +1,131 @@
+package strategy
+
+import (
+	"cmp"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Difficulty ratings, from a transition that all but mixes itself to one worth
+// rehearsing before the gig.
+const (
+	DifficultyEasy    = 1
+	DifficultyHardest = 5
+)
+
+// Difficulty thresholds. A pitch fader's usual ±8% range covers most stretches, but
+// past a few percent the mix has to be ridden by ear; eight bars is the shortest
+// blend that doesn't feel rushed.
+const (
+	stretchNoticeable = 2.0 // percent
+	stretchHard       = 4.0
+	stretchExtreme    = 8.0
+	roomBars          = 8
+)
+
+// Difficulty rates how hard a transition is to play, as opposed to how well it fits:
+// Rating runs from DifficultyEasy to DifficultyHardest, and Reasons names what makes
+// it harder, e.g. "tempo stretch 5.1%" or "short outro (6s)".
+type Difficulty struct {
+	Rating  int
+	Reasons []string
+
+	points int // uncapped, to rank ratings that hit the ceiling
+}
+
+// TransitionDifficulty rates playing b after a. Each of these adds to the rating: the
+// tempo stretch needed to beatmatch (more for a bigger one, and for a half- or
+// double-time mix), too little room to blend in a's outro or b's intro when the data
+// has them, keys that are borderline (two steps apart) or clash outright, and phrase
+// structures that don't nest. A transition into or out of a key-agnostic track has no
+// key to get wrong.
+func TransitionDifficulty(a, b track.Track) Difficulty {
+	var d Difficulty
+	add := func(points int, reason string, args ...any) {
+		d.points += points
+		d.Reasons = append(d.Reasons, fmt.Sprintf(reason, args...))
+	}
+
+	from, to := a.ExitBPM(), b.EntryBPM()
+	switch stretch := tempoStretch(from, to); {
+	case stretch >= stretchExtreme:
+		add(3, "tempo stretch %.1f%%", stretch)
+	case stretch >= stretchHard:
+		add(2, "tempo stretch %.1f%%", stretch)
+	case stretch >= stretchNoticeable:
+		add(1, "tempo stretch %.1f%%", stretch)
+	}
+	if from > 0 && to > 0 && math.Round(math.Log2(to/from)) != 0 {
+		add(1, "half/double time")
+	}
+
+	bpm := from
+	if bpm <= 0 {
+		bpm = fadeDefaultBPM
+	}
+	room := roomBars * fadeBeatsPerBar * 60 / bpm
+	for _, side := range []struct {
+		name    string
+		seconds *int
+	}{{"outro", a.Outro}, {"intro", b.Intro}} {
+		if side.seconds == nil || *side.seconds <= 0 {
+			continue
+		}
+		switch secs := float64(*side.seconds); {
+		case secs < room/2:
+			add(2, "short %s (%ds)", side.name, *side.seconds)
+		case secs < room:
+			add(1, "short %s (%ds)", side.name, *side.seconds)
+		}
+	}
+
+	if trans := NewTransition(a, b); !trans.AnyKey {
+		switch dist := trans.FromKey.Distance(trans.ToKey); {
+		case trans.FromKey.Compatible(trans.ToKey):
+		case dist <= 2:
+			add(1, "borderline key %s→%s", trans.FromKey, trans.ToKey)
+		default:
+			add(2, "key clash %s→%s", trans.FromKey, trans.ToKey)
+		}
+	}
+
+	if a.Phrase != nil && b.Phrase != nil && *a.Phrase > 0 && *b.Phrase > 0 &&
+		*a.Phrase%*b.Phrase != 0 && *b.Phrase%*a.Phrase != 0 {
+		add(1, "phrase %d→%d bars", *a.Phrase, *b.Phrase)
+	}
+
+	d.Rating = min(DifficultyEasy+d.points, DifficultyHardest)
+	return d
+}
+
+// Difficulties rates each transition of an ordering: element i is playing tracks[i]
+// then tracks[i+1].
+func Difficulties(tracks []track.Track) []Difficulty {
+	if len(tracks) < 2 {
+		return nil
+	}
+	out := make([]Difficulty, len(tracks)-1)
+	for i := range out {
+		out[i] = TransitionDifficulty(tracks[i], tracks[i+1])
+	}
+	return out
+}
+
+// HardestTransitions returns the indices into d of up to n transitions above
+// DifficultyEasy, hardest first; ties keep set order. These are the ones to rehearse.
+func HardestTransitions(d []Difficulty, n int) []int {
+	var idx []int
+	for i, x := range d {
+		if x.Rating > DifficultyEasy {
+			idx = append(idx, i)
+		}
+	}
+	slices.SortStableFunc(idx, func(i, j int) int { return cmp.Compare(d[j].points, d[i].points) })
+	if len(idx) > n {
+		idx = idx[:n]
+	}
+	return idx
+}
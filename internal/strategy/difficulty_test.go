@@ -0,0 +1,41 @@
+package strategy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestTransitionDifficulty(t *testing.T) {
+	a := track.Track{Title: "a", BPM: 124, Energy: 60, Key: mustKey("8A")}
+
+	cases := []struct {
+		name    string
+		from    track.Track
+		to      track.Track
+		rating  int
+		reasons []string
+	}{
+		{"easy", a, track.Track{BPM: 125, Energy: 62, Key: mustKey("9A")}, 1, nil},
+		{"tempo stretch", a, track.Track{BPM: 130, Energy: 62, Key: mustKey("8A")}, 3, []string{"tempo stretch 4.8%"}},
+		{"half time", a, track.Track{BPM: 62, Energy: 62, Key: mustKey("8A")}, 2, []string{"half/double time"}},
+		{"borderline key", a, track.Track{BPM: 124, Energy: 62, Key: mustKey("10A")}, 2, []string{"borderline key 8A→10A"}},
+		{"short outro and clash", track.Track{BPM: 124, Energy: 60, Key: mustKey("8A"), Outro: intPtr(6)},
+			track.Track{BPM: 124, Energy: 62, Key: mustKey("3B")}, 5, []string{"short outro (6s)", "key clash 8A→3B"}},
+		{"phrase mismatch", track.Track{BPM: 124, Energy: 60, Key: mustKey("8A"), Phrase: intPtr(16)},
+			track.Track{BPM: 124, Energy: 62, Key: mustKey("8A"), Phrase: intPtr(24)}, 2, []string{"phrase 16→24 bars"}},
+		{"any key", a, track.Track{BPM: 124, Energy: 62, Key: mustKey("3B"), Tags: []string{track.AnyKeyTag}}, 1, nil},
+	}
+	for _, c := range cases {
+		got := TransitionDifficulty(c.from, c.to)
+		if got.Rating != c.rating || !slices.Equal(got.Reasons, c.reasons) {
+			t.Errorf("%s: got %d %q, want %d %q", c.name, got.Rating, got.Reasons, c.rating, c.reasons)
+		}
+	}
+
+	set := []track.Track{a, {BPM: 124, Key: mustKey("8A")}, {BPM: 124, Key: mustKey("10A")}, {BPM: 135, Key: mustKey("3B")}, {BPM: 135, Key: mustKey("3B")}}
+	if got := HardestTransitions(Difficulties(set), 3); !slices.Equal(got, []int{2, 1}) {
+		t.Errorf("HardestTransitions = %v, want [2 1]", got)
+	}
+}
@@ -172,11 +172,11 @@ func scoreCandidate(candidate track.Track, prev track.Track, dist KeyDistributio
 	total := 0.0
 
 	// Factor 1: Harmonic compatibility (DJ mixing principles) - OPTIMAL BALANCE
-	harmonicScore := scoreHarmonicCompatibility(prev.Key, candidate.Key)
+	harmonicScore := scoreHarmonicCompatibility(prev.ExitKey(), candidate.Key)
 	total += harmonicScore * 150.0 // Optimal weight for harmonic excellence
 
 	// Factor 2: BPM compatibility (tempo transition smoothness) - OPTIMIZED
-	bpmScore := scoreBPMCompatibility(prev.BPM, candidate.BPM)
+	bpmScore := scoreBPMCompatibility(prev.ExitBPM(), candidate.EntryBPM())
 	total += bpmScore * 70.0 // Optimal weight for BPM flow
 
 	// Factor 3: Energy progression (flow and key-based energy) - OPTIMIZED
@@ -188,7 +188,7 @@ func scoreCandidate(candidate track.Track, prev track.Track, dist KeyDistributio
 	total += distributionScore * 15.0 // Increased weight for better key variety
 
 	// Factor 5: Same key penalty (avoid consecutive identical keys)
-	if prev.Key == candidate.Key {
+	if prev.ExitKey() == candidate.Key {
 		total -= 200.0 // Strong penalty for same key
 	}
 
@@ -319,7 +319,7 @@ func scoreEnergyProgression(prev, candidate track.Track) float64 {
 	energyChange := candidate.Energy - prev.Energy
 
 	// Calculate key-based energy change using the wheel theory
-	fromKeyEnergy := eloiseCalculateKeyEnergy(prev.Key)
+	fromKeyEnergy := eloiseCalculateKeyEnergy(prev.ExitKey())
 	toKeyEnergy := eloiseCalculateKeyEnergy(candidate.Key)
 	keyEnergyChange := toKeyEnergy - fromKeyEnergy
 
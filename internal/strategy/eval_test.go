@@ -14,6 +14,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/YakDriver/magicmix/internal/eval"
 	"github.com/YakDriver/magicmix/internal/strategy"
 	"github.com/YakDriver/magicmix/internal/track"
 )
@@ -47,12 +48,12 @@ func TestDefaultSorterRealDataEvaluation(t *testing.T) {
 			t.Fatalf("sort failure round %d: %v", round, err)
 		}
 
-		score := evaluateSequence(ordered)
+		score := eval.Evaluate(ordered)
 		agg.add(score)
 
 		t.Logf("round %02d size=%2d score=%.2f key=%.2f bpm=%.2f energy=%.2f wraps=%d jumps=%d invalid=%d",
-			round+1, sampleSize, score.Total, score.KeyPenalty, score.BpmPenalty, score.EnergyPenalty,
-			score.Wraps, score.BigJumpCount, score.InvalidTransitions)
+			round+1, sampleSize, score.Total, score.KeyPenalty, score.BPMPenalty, score.EnergyPenalty,
+			score.Wraps, score.BigJumps, score.InvalidTransitions)
 
 		if score.InvalidTransitions > 0 {
 			t.Logf("round %02d noted %d invalid/fallback transitions", round+1, score.InvalidTransitions)
@@ -60,7 +61,7 @@ func TestDefaultSorterRealDataEvaluation(t *testing.T) {
 	}
 
 	avg := agg.average()
-	t.Logf("average score=%.2f key=%.2f bpm=%.2f energy=%.2f wraps=%d bigJumps=%d invalid=%d", avg.Total, avg.KeyPenalty, avg.BpmPenalty, avg.EnergyPenalty, avg.Wraps, avg.BigJumpCount, avg.InvalidTransitions)
+	t.Logf("average score=%.2f key=%.2f bpm=%.2f energy=%.2f wraps=%d bigJumps=%d invalid=%d", avg.Total, avg.KeyPenalty, avg.BPMPenalty, avg.EnergyPenalty, avg.Wraps, avg.BigJumps, avg.InvalidTransitions)
 }
 
 func BenchmarkDefaultSorterRealData(b *testing.B) {
@@ -78,7 +79,7 @@ func BenchmarkDefaultSorterRealData(b *testing.B) {
 		if err != nil {
 			b.Fatalf("sort failure: %v", err)
 		}
-		result := evaluateSequence(ordered)
+		result := eval.Evaluate(ordered)
 		if result.InvalidTransitions > 0 {
 			b.Fatalf("invalid transition detected in benchmark run")
 		}
@@ -205,140 +206,38 @@ func parseTrackRecord(record []string) (track.Track, error) {
 	}, nil
 }
 
-type evaluationScore struct {
-	Total              float64
-	KeyPenalty         float64
-	BpmPenalty         float64
-	EnergyPenalty      float64
-	Wraps              int
-	BigJumpCount       int
-	InvalidTransitions int
-}
-
-func (e *evaluationScore) accumulate(other evaluationScore) {
-	e.Total += other.Total
-	e.KeyPenalty += other.KeyPenalty
-	e.BpmPenalty += other.BpmPenalty
-	e.EnergyPenalty += other.EnergyPenalty
-	e.Wraps += other.Wraps
-	e.BigJumpCount += other.BigJumpCount
-	e.InvalidTransitions += other.InvalidTransitions
-}
-
 type evaluationSummary struct {
 	totalRounds int
-	aggregate   evaluationScore
+	aggregate   eval.Score
 }
 
-func (s *evaluationSummary) add(score evaluationScore) {
+func (s *evaluationSummary) add(score eval.Score) {
 	s.totalRounds++
-	s.aggregate.accumulate(score)
+	s.aggregate.Total += score.Total
+	s.aggregate.KeyPenalty += score.KeyPenalty
+	s.aggregate.BPMPenalty += score.BPMPenalty
+	s.aggregate.EnergyPenalty += score.EnergyPenalty
+	s.aggregate.Wraps += score.Wraps
+	s.aggregate.BigJumps += score.BigJumps
+	s.aggregate.InvalidTransitions += score.InvalidTransitions
 }
 
-func (s *evaluationSummary) average() evaluationScore {
+func (s *evaluationSummary) average() eval.Score {
 	if s.totalRounds == 0 {
-		return evaluationScore{}
+		return eval.Score{}
 	}
 	n := float64(s.totalRounds)
-	return evaluationScore{
+	return eval.Score{
 		Total:              s.aggregate.Total / n,
 		KeyPenalty:         s.aggregate.KeyPenalty / n,
-		BpmPenalty:         s.aggregate.BpmPenalty / n,
+		BPMPenalty:         s.aggregate.BPMPenalty / n,
 		EnergyPenalty:      s.aggregate.EnergyPenalty / n,
 		Wraps:              int(math.Round(float64(s.aggregate.Wraps) / n)),
-		BigJumpCount:       int(math.Round(float64(s.aggregate.BigJumpCount) / n)),
+		BigJumps:           int(math.Round(float64(s.aggregate.BigJumps) / n)),
 		InvalidTransitions: int(math.Round(float64(s.aggregate.InvalidTransitions) / n)),
 	}
 }
 
-func evaluateSequence(tracks []track.Track) evaluationScore {
-	if len(tracks) <= 1 {
-		return evaluationScore{}
-	}
-
-	score := evaluationScore{}
-	sinceReset := 0
-
-	for i := 1; i < len(tracks); i++ {
-		prev := tracks[i-1]
-		next := tracks[i]
-
-		diff, wrapped := camelotDiff(prev.Key.Number, next.Key.Number)
-		if wrapped {
-			score.Wraps++
-			sinceReset = 0
-		}
-
-		modeChange := prev.Key.Mode != next.Key.Mode
-
-		// Key penalties
-		switch diff {
-		case 0:
-			score.KeyPenalty += 3
-		case 1:
-			if modeChange {
-				score.KeyPenalty += 4
-				score.InvalidTransitions++
-			}
-		case 2:
-			if modeChange {
-				score.KeyPenalty += 6
-				score.InvalidTransitions++
-			}
-		case 3:
-			score.KeyPenalty += 4
-			score.BigJumpCount++
-			if modeChange {
-				score.KeyPenalty += 6
-				score.InvalidTransitions++
-			}
-		default:
-			score.KeyPenalty += float64(diff * diff) // exponential penalty
-			score.BigJumpCount++
-			score.InvalidTransitions++
-		}
-
-		if wrapped && diff > 2 {
-			score.KeyPenalty += 3
-		}
-
-		// BPM penalties
-		bpmDelta := math.Abs(next.BPM - prev.BPM)
-		if bpmDelta > 3 {
-			score.BpmPenalty += (bpmDelta - 3) * 0.4
-		}
-
-		// Energy penalties/rewards
-		energyDelta := float64(next.Energy - prev.Energy)
-		if energyDelta > 14 {
-			score.EnergyPenalty += (energyDelta - 14) * 0.3
-		} else if energyDelta < -12 {
-			// Reward strong resets after climbs.
-			score.EnergyPenalty -= math.Min(4, (-energyDelta-12)*0.25)
-			sinceReset = 0
-		}
-
-		sinceReset++
-		if sinceReset > 12 && energyDelta >= 0 {
-			score.EnergyPenalty += 0.5
-		}
-	}
-
-	// Normalise to total score (lower is better).
-	score.Total = score.KeyPenalty*0.6 + score.BpmPenalty*0.2 + score.EnergyPenalty*0.2
-	return score
-}
-
-func camelotDiff(prev, next int) (int, bool) {
-	diff := next - prev
-	wrapped := false
-	if diff < 0 {
-		diff += 12
-		wrapped = true
-	}
-	return diff, wrapped
-}
-
 func evaluationRNG(tb testing.TB) *rand.Rand {
 	tb.Helper()
 	seed := time.Now().UnixNano()
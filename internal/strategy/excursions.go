@@ -0,0 +1,80 @@
+package strategy
+
+import "context"
+
+// Excursion stages: a relative-mode excursion is a flip to the relative mode (8A to
+// 8B), a +1 step within it (8B to 9B), and a flip back home (9B to 9A).
+const (
+	excursionNone    = iota // not under way
+	excursionFlipped        // flipped to the relative mode; the step comes next
+	excursionStepped        // stepped within it; the flip home comes next
+)
+
+const excursionContextKey contextKey = "strategy.excursions"
+
+// WithExcursions has the default strategy play a relative-mode excursion — 8A→8B→9B→9A —
+// about every n tracks, as a planned motif rather than a mode flip only when one is
+// overdue. n <= 0 leaves them off.
+func WithExcursions(ctx context.Context, n int) context.Context {
+	return context.WithValue(ctx, excursionContextKey, n)
+}
+
+func excursionsFromContext(ctx context.Context) int {
+	if ctx == nil {
+		return 0
+	}
+	n, _ := ctx.Value(excursionContextKey).(int)
+	return max(n, 0)
+}
+
+// excursionDue reports whether the planner should open an excursion at the next
+// position.
+func (state *mixState) excursionDue() bool {
+	return state.excursionEvery > 0 && state.excursionStage == excursionNone &&
+		state.sinceExcursion >= state.excursionEvery
+}
+
+// excursionNext is the key-move category the excursion under way (or due) wants next,
+// or -1 when there is none.
+func (state *mixState) excursionNext() int {
+	switch {
+	case state.excursionStage == excursionFlipped:
+		return 0
+	case state.excursionStage == excursionStepped, state.excursionDue():
+		return 3
+	}
+	return -1
+}
+
+// advanceExcursion moves the excursion on after a move of the given category. A move
+// the motif doesn't want abandons it, and the next mode flip starts a fresh one.
+func (state *mixState) advanceExcursion(category int) {
+	if state.excursionEvery <= 0 {
+		return
+	}
+	want := state.excursionNext()
+	state.sinceExcursion++
+	switch {
+	case want < 0 || category != want:
+		state.excursionStage = excursionNone
+	case state.excursionStage == excursionStepped:
+		state.excursionStage, state.sinceExcursion = excursionNone, 0
+	default:
+		state.excursionStage++
+	}
+}
+
+// excursionNote says which part of an excursion a move of the given category plays,
+// or "" when it plays none.
+func (state *mixState) excursionNote(category int) string {
+	if want := state.excursionNext(); want < 0 || category != want {
+		return ""
+	}
+	switch state.excursionStage {
+	case excursionFlipped:
+		return "excursion: a step within the relative mode"
+	case excursionStepped:
+		return "excursion: back to the home mode, closing the motif"
+	}
+	return "excursion: a flip to the relative mode opens the motif"
+}
@@ -0,0 +1,45 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestExcursionsPlayRelativeModeMotif(t *testing.T) {
+	var tracks []track.Track
+	for dup := range 2 {
+		for n := 1; n <= 12; n++ {
+			for _, mode := range []track.Mode{track.ModeA, track.ModeB} {
+				tracks = append(tracks, track.Track{Title: fmt.Sprintf("%d%s-%d", n, mode, dup), BPM: 124,
+					Energy: 40 + n*3, Key: track.Key{Number: n, Mode: mode}})
+			}
+		}
+	}
+
+	motifs := func(ordered []track.Track) int {
+		count := 0
+		for i := 0; i+3 < len(ordered); i++ {
+			a, b, c, d := ordered[i].Key, ordered[i+1].Key, ordered[i+2].Key, ordered[i+3].Key
+			if b == a.Relative() && c == b.Transpose(1) && d == c.Relative() {
+				count++
+			}
+		}
+		return count
+	}
+	ctx := WithSeed(context.Background(), 7)
+	plain, err := NewDefaultSorter().Sort(ctx, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	planned, err := NewDefaultSorter().Sort(WithExcursions(ctx, 4), tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, without := motifs(planned), motifs(plain); got < 2 || got <= without {
+		t.Errorf("with excursions every 4 tracks the set plays %d motif(s) (%d without); want at least 2 and more than without",
+			got, without)
+	}
+}
@@ -0,0 +1,155 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const (
+	explainContextKey    contextKey = "strategy.explain"
+	explainLogContextKey contextKey = "strategy.explainLog"
+)
+
+// WithExplain asks Sort for the reason behind each placement the default strategy
+// makes: the key move and its category (and any preferred category it fell back
+// past), the energy move against the cycle's target, and where the energy cycle
+// stands. Sort returns them in Result.Explanations and lists them in Result.Notes.
+// Other strategies give none. Explaining does not change the ordering.
+func WithExplain(ctx context.Context) context.Context {
+	return context.WithValue(ctx, explainContextKey, true)
+}
+
+func explainFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	on, _ := ctx.Value(explainContextKey).(bool)
+	return on
+}
+
+func explainLogFromContext(ctx context.Context) *explainLog {
+	if ctx == nil {
+		return nil
+	}
+	log, _ := ctx.Value(explainLogContextKey).(*explainLog)
+	return log
+}
+
+// explainLog collects the default planner's reasons during one Sort, by track ID. A
+// Sort may run the planner more than once (see includeRequired); each run starts the
+// log afresh, so the last one's reasons are kept.
+type explainLog struct {
+	reasons map[string]placementReason
+}
+
+// placementReason is why a track was placed after prev, the ID of the track before it
+// ("" for the opener).
+type placementReason struct {
+	prev   string
+	reason string
+}
+
+func (l *explainLog) reset() {
+	l.reasons = map[string]placementReason{}
+}
+
+func (l *explainLog) record(prev string, t track.Track, reason string) {
+	l.reasons[t.ID()] = placementReason{prev: prev, reason: reason}
+}
+
+// explain gives the reason for each track of ordered ("" when the planner gave none)
+// and the notes listing them. A track that no longer follows the track it was planned
+// after — moved by an ordering rule or human feel — is reported as moved, since its
+// reason no longer holds.
+func (l *explainLog) explain(ordered []track.Track) ([]string, []string) {
+	if len(l.reasons) == 0 {
+		return nil, nil
+	}
+	reasons := make([]string, len(ordered))
+	notes := []string{"Placement reasons:"}
+	prev := ""
+	for i, t := range ordered {
+		id := t.ID()
+		if r, ok := l.reasons[id]; ok {
+			reasons[i] = r.reason
+			if r.prev != prev {
+				reasons[i] = "moved here after planning by an ordering rule or human feel"
+			}
+			notes = append(notes, fmt.Sprintf("  %d. %q: %s", i+1, t.Title, reasons[i]))
+		}
+		prev = id
+	}
+	return reasons, notes
+}
+
+// explainOpener says why the planner opened with start.
+func explainOpener(start track.Track, pinned bool, arc *arcScale) string {
+	if pinned {
+		return "pinned as the opener"
+	}
+	if arc != nil {
+		return fmt.Sprintf("opener: the fit nearest the %s arc's opening energy (%.0f) near the median tempo in key number %d (energy %d)",
+			arc.arc.Name, arc.energy(0), start.Key.Number, start.Energy)
+	}
+	return fmt.Sprintf("opener: the lowest-energy fit near the median tempo in key number %d (energy %d)",
+		start.Key.Number, start.Energy)
+}
+
+// explainPlacement says why the planner is placing candidate after state.prev: the key
+// move, the energy move, and the cycle position, noting when it was pinned there.
+func (p *mixPlanner) explainPlacement(state *mixState, candidate track.Track, pinned bool) string {
+	trans := computeTransition(state, candidate)
+	category := categorizeTransition(state, trans)
+	parts := []string{fmt.Sprintf("key %s → %s (%s)", state.prev.ExitKey(), candidate.Key, categoryNames[category])}
+	if pinned {
+		parts[0] = "pinned here; " + parts[0]
+	} else {
+		order := categoryOrder(state)
+		switch rank := slices.Index(order, category); {
+		case state.excursionNote(category) != "":
+			parts = append(parts, state.excursionNote(category))
+		case rank > 0:
+			skipped := make([]string, rank)
+			for i, c := range order[:rank] {
+				skipped[i] = categoryNames[c]
+			}
+			parts = append(parts, "fallback: no eligible "+strings.Join(skipped, " or ")+" track")
+		case order[0] == 3 && category == 3:
+			parts = append(parts, fmt.Sprintf("variety: a mode flip was due after %d tracks without one", state.stepsSinceLetterFlip))
+		case order[0] == 1 && category == 1:
+			parts = append(parts, fmt.Sprintf("variety: a step+2 was due after %d tracks without one", state.stepsSinceStep2))
+		}
+	}
+
+	if state.arc != nil {
+		parts = append(parts, fmt.Sprintf("energy %s %d → %d (%s arc target %.0f)", energyDirection(state.prev, candidate),
+			state.prev.Energy, candidate.Energy, state.arc.arc.Name, state.arcTarget()))
+		return strings.Join(parts, "; ")
+	}
+	if trans.Wrap {
+		parts = append(parts, fmt.Sprintf("the key wheel wraps past 12, which starts energy cycle %d (energy %d → %d)",
+			state.cycleIndex+2, state.prev.Energy, candidate.Energy))
+		return strings.Join(parts, "; ")
+	}
+	target := nextEnergyTarget(state, p.stats, p.desiredCycleLength)
+	parts = append(parts,
+		fmt.Sprintf("energy %s %d → %d (cycle target %.0f)", energyDirection(state.prev, candidate), state.prev.Energy,
+			candidate.Energy, target),
+		fmt.Sprintf("cycle %d, track %d of ~%d", state.cycleIndex+1, state.tracksInCycle+1, p.desiredCycleLength))
+	return strings.Join(parts, "; ")
+}
+
+// energyDirection says whether energy rises, falls, or holds from prev to next.
+func energyDirection(prev, next track.Track) string {
+	switch delta := next.Energy - prev.Energy; {
+	case delta > 0:
+		return "rises"
+	case delta < 0:
+		return "falls"
+	}
+	return "holds"
+}
@@ -0,0 +1,69 @@
+package strategy
+
+import (
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// KeyUse is how often the sets a strategy makes from a library play one key (or one
+// mode), against how common it is in the library. Utilization is the key's share of
+// the sets' tracks over its share of the library's: 1 is its fair share, below 1 the
+// strategy leaves its tracks out more often than the rest, and 0 when the library has
+// none.
+type KeyUse struct {
+	Name        string // "8A", or "A" and "B" for a whole mode
+	Library     int    // the library's tracks that start in it
+	Played      int    // their placements across the sets
+	Utilization float64
+}
+
+// KeyUtilization counts each key's and each mode's use across sets, each an ordering
+// of some of library's tracks, by the key each track starts in. Keys run round the
+// wheel, 1A, 1B, 2A, …, and only those the library has are listed; tracks without a
+// key are left out of both counts.
+func KeyUtilization(library []track.Track, sets [][]track.Track) (keys, modes []KeyUse) {
+	var inLib, inSets [13][2]int
+	count := func(tracks []track.Track, into *[13][2]int) int {
+		total := 0
+		for _, t := range tracks {
+			if k := t.EntryKey(); k.Number > 0 {
+				into[k.Number][modeIndex(k.Mode)]++
+				total++
+			}
+		}
+		return total
+	}
+	libTotal, setTotal := count(library, &inLib), 0
+	for _, set := range sets {
+		setTotal += count(set, &inSets)
+	}
+	use := func(name string, lib, played int) KeyUse {
+		u := KeyUse{Name: name, Library: lib, Played: played}
+		if lib > 0 && setTotal > 0 {
+			u.Utilization = (float64(played) / float64(setTotal)) / (float64(lib) / float64(libTotal))
+		}
+		return u
+	}
+	var modeLib, modePlayed [2]int
+	for n := 1; n <= 12; n++ {
+		for m, mode := range []track.Mode{track.ModeA, track.ModeB} {
+			modeLib[m] += inLib[n][m]
+			modePlayed[m] += inSets[n][m]
+			if inLib[n][m] > 0 {
+				keys = append(keys, use(track.Key{Number: n, Mode: mode}.String(), inLib[n][m], inSets[n][m]))
+			}
+		}
+	}
+	for m, mode := range []track.Mode{track.ModeA, track.ModeB} {
+		if modeLib[m] > 0 {
+			modes = append(modes, use(string(mode), modeLib[m], modePlayed[m]))
+		}
+	}
+	return keys, modes
+}
+
+func modeIndex(m track.Mode) int {
+	if m == track.ModeB {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,29 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestKeyUtilizationComparesSetsWithTheLibrary(t *testing.T) {
+	mk := func(n int, m track.Mode) track.Track { return track.Track{Key: track.Key{Number: n, Mode: m}} }
+	a8, b8, a9 := mk(8, track.ModeA), mk(8, track.ModeB), mk(9, track.ModeA)
+	library := []track.Track{a8, a8, b8, b8, a9, a9, {Title: "no key"}}
+	// Two sets that never play 8B: the A keys take its share.
+	sets := [][]track.Track{{a8, a9}, {a8, a9}}
+
+	keys, modes := KeyUtilization(library, sets)
+	want := map[string]float64{"8A": 1.5, "8B": 0, "9A": 1.5}
+	if len(keys) != len(want) {
+		t.Fatalf("got %d keys, want %d: %+v", len(keys), len(want), keys)
+	}
+	for i, name := range []string{"8A", "8B", "9A"} {
+		if keys[i].Name != name || keys[i].Library != 2 || keys[i].Utilization != want[name] {
+			t.Errorf("keys[%d] = %+v, want %s at %.2f", i, keys[i], name, want[name])
+		}
+	}
+	if len(modes) != 2 || modes[0].Name != "A" || modes[0].Played != 4 || modes[1].Utilization != 0 {
+		t.Errorf("modes = %+v, want A played 4 times and B never", modes)
+	}
+}
@@ -0,0 +1,103 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// flatLibrary describes tracks that give a strategy nothing to order by but tempo —
+// every one in the same key (or key-agnostic) and at the same energy — such as "all
+// 12 tracks are in 8A at energy 50"; "" when they differ in key or energy. A strategy
+// handed such a list breaks every tie its own way, and the order looks arbitrary.
+func flatLibrary(tracks []track.Track) string {
+	if len(tracks) < 2 {
+		return ""
+	}
+	var key track.Key
+	keyed := false
+	for _, t := range tracks {
+		if t.Energy != tracks[0].Energy {
+			return ""
+		}
+		if t.AnyKey() {
+			continue
+		}
+		if t.EntryKey() != t.ExitKey() || keyed && t.EntryKey() != key {
+			return ""
+		}
+		key, keyed = t.EntryKey(), true
+	}
+	where := "with no key between them"
+	if keyed && key.Number > 0 {
+		where = "in " + key.String()
+	}
+	return fmt.Sprintf("all %d tracks are %s at energy %d", len(tracks), where, tracks[0].Energy)
+}
+
+// fallbackSort is how Sort orders a flat library (see flatLibrary) in place of the
+// strategy: by BPM, then title, then artist, so the same tracks always come out the
+// same way and each tempo change is the smallest the tracks allow. Under a limit it
+// keeps the run of that many tracks closest in tempo. It returns notes saying so,
+// and whether every transition is a tempo jump anyway.
+func fallbackSort(ctx context.Context, tracks []track.Track, flat string) ([]track.Track, []string) {
+	ordered := slices.Clone(tracks)
+	slices.SortStableFunc(ordered, func(a, b track.Track) int {
+		return cmp.Or(cmp.Compare(a.BPM, b.BPM), strings.Compare(strings.ToLower(a.Title), strings.ToLower(b.Title)),
+			strings.Compare(strings.ToLower(a.Artist), strings.ToLower(b.Artist)))
+	})
+	notes := []string{fmt.Sprintf("Only tempo tells these tracks apart (%s): ordered by BPM, then title", flat)}
+	if limit := limitFromContext(ctx); limit > 0 && limit < len(ordered) {
+		start := 0
+		for i := 1; i+limit <= len(ordered); i++ {
+			if tempoSpan(ordered[i:i+limit]) < tempoSpan(ordered[start:start+limit]) {
+				start = i
+			}
+		}
+		ordered = ordered[start : start+limit]
+		notes = append(notes, fmt.Sprintf("Kept the %d tracks closest in tempo (%s-%s BPM)", limit,
+			ordered[0].TempoString(), ordered[len(ordered)-1].TempoString()))
+	}
+	if scattered(ordered) {
+		notes = append(notes, fmt.Sprintf(
+			"No two of them are within %.0f%% in tempo, so every transition is a tempo jump whatever the order",
+			DefaultPartnerWindow))
+	}
+	return ordered, notes
+}
+
+// tempoSpan is how far apart the slowest and fastest of tracks sorted by BPM are, as
+// a ratio; tracks without a tempo don't count.
+func tempoSpan(sorted []track.Track) float64 {
+	lo, hi := 0.0, 0.0
+	for _, t := range sorted {
+		if t.BPM <= 0 {
+			continue
+		}
+		if lo == 0 {
+			lo = t.BPM
+		}
+		hi = t.BPM
+	}
+	if lo == 0 {
+		return 1
+	}
+	return hi / lo
+}
+
+// scattered reports whether no two of tracks are within DefaultPartnerWindow of each
+// other in tempo (half and double time included), so no ordering avoids a tempo jump.
+func scattered(tracks []track.Track) bool {
+	for i, a := range tracks {
+		for _, b := range tracks[i+1:] {
+			if a.BPM > 0 && b.BPM > 0 && tempoStretch(a.BPM, b.BPM) <= DefaultPartnerWindow {
+				return false
+			}
+		}
+	}
+	return len(tracks) > 1
+}
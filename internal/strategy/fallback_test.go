@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestFlatLibrary(t *testing.T) {
+	flat := []track.Track{
+		{Title: "a", BPM: 124, Energy: 50, Key: mustKey("8A")},
+		{Title: "b", BPM: 120, Energy: 50, Key: mustKey("8A")},
+		{Title: "c", BPM: 122, Energy: 50, Key: mustKey("3B"), Tags: []string{track.AnyKeyTag}},
+	}
+	if got := flatLibrary(flat); got != "all 3 tracks are in 8A at energy 50" {
+		t.Errorf("flatLibrary = %q", got)
+	}
+	keys := append(flat[:2:2], track.Track{Title: "c", BPM: 122, Energy: 50, Key: mustKey("9A")})
+	energies := append(flat[:2:2], track.Track{Title: "c", BPM: 122, Energy: 60, Key: mustKey("8A")})
+	for name, tracks := range map[string][]track.Track{"keys": keys, "energies": energies} {
+		if got := flatLibrary(tracks); got != "" {
+			t.Errorf("%s differ, but flatLibrary = %q", name, got)
+		}
+	}
+}
+
+func TestSortFallsBackOnFlatLibraries(t *testing.T) {
+	var tracks []track.Track
+	for _, tr := range []struct {
+		title string
+		bpm   float64
+	}{{"delta", 128}, {"bravo", 124}, {"alpha", 124}, {"echo", 140}, {"charlie", 126}, {"foxtrot", 100}} {
+		tracks = append(tracks, track.Track{Title: tr.title, BPM: tr.bpm, Energy: 50, Key: mustKey("8A")})
+	}
+	for _, name := range []string{"flow", "default", "rotation"} {
+		s, err := Get(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		res, err := Sort(WithSeed(context.Background(), 1), s, tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := titles(res.Ordered); got != "foxtrot alpha bravo charlie delta echo " {
+			t.Errorf("%s: ordered %s, want by BPM then title", name, got)
+		}
+		if len(res.Notes) == 0 || !strings.Contains(res.Notes[0], "ordered by BPM, then title") {
+			t.Errorf("%s: notes = %q", name, res.Notes)
+		}
+	}
+
+	res, err := Sort(WithLimit(context.Background(), 3), NewFlowSorter(), tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := titles(res.Ordered); got != "alpha bravo charlie " {
+		t.Errorf("limited to 3: ordered %s, want the three closest in tempo", got)
+	}
+
+	scatter := []track.Track{
+		{Title: "x", BPM: 90, Energy: 50, Key: mustKey("8A")},
+		{Title: "y", BPM: 110, Energy: 50, Key: mustKey("8A")},
+		{Title: "z", BPM: 140, Energy: 50, Key: mustKey("8A")},
+		{Title: "w", BPM: 160, Energy: 50, Key: mustKey("8A")},
+		{Title: "v", BPM: 125, Energy: 50, Key: mustKey("8A")},
+		{Title: "u", BPM: 100, Energy: 50, Key: mustKey("8A")},
+	}
+	if _, notes := fallbackSort(context.Background(), scatter, flatLibrary(scatter)); len(notes) != 2 ||
+		!strings.Contains(notes[1], "every transition is a tempo jump") {
+		t.Errorf("scattered tempos: notes = %q", notes)
+	}
+}
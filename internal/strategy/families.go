@@ -0,0 +1,223 @@
+package strategy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultFamilyGap is how far apart versions of one song are kept when a set
+// deliberately includes more than one of them.
+const DefaultFamilyGap = 10
+
+// FamilyKey is the base title of the song a track is a version of (version
+// descriptors such as "Extended Mix" or "Radio Edit" removed). Tracks that share it
+// are versions of one song when they also share an artist (see SameFamily).
+func FamilyKey(t track.Track) string {
+	return track.BaseTitle(t.Title)
+}
+
+// SameFamily reports whether a and b are versions of one song: they share a
+// FamilyKey and an artist, normalized (see track.Artists). A track's artists include
+// those its title features or credits with a version, so "A – Song (B Remix)" matches
+// B's "Song" too. A remix credited to its remixer ("B – Song (B Remix)") names no
+// other artist to match, so it matches every track of its FamilyKey.
+func SameFamily(a, b track.Track) bool {
+	if FamilyKey(a) != FamilyKey(b) {
+		return false
+	}
+	return creditedToRemixer(a) || creditedToRemixer(b) || sharesFamilyArtist(a, b)
+}
+
+func sharesFamilyArtist(a, b track.Track) bool {
+	other := familyArtists(b)
+	return slices.ContainsFunc(familyArtists(a), func(x string) bool { return slices.Contains(other, x) })
+}
+
+// familyArtists are the artists a track's credit and title name.
+func familyArtists(t track.Track) []string {
+	return append(track.Artists(t.Artist), track.VersionArtists(t.Title)...)
+}
+
+// creditedToRemixer reports whether t is a version credited to the artist who made it
+// rather than to the song's artist.
+func creditedToRemixer(t track.Track) bool {
+	remixers := track.VersionArtists(t.Title)
+	return slices.ContainsFunc(track.Artists(t.Artist), func(a string) bool { return slices.Contains(remixers, a) })
+}
+
+// Families groups tracks that are versions of the same song: those sharing a
+// FamilyKey and an artist, as SameFamily matches them. A remix credited to its remixer
+// joins the family of its FamilyKey only when there is just one, so it can't join two
+// songs of one title into a family. Only groups with two or more members are
+// returned, each as track indices in input order, in the order of their first members.
+func Families(tracks []track.Track) [][]int {
+	byKey := map[string][]int{}
+	for i, t := range tracks {
+		k := FamilyKey(t)
+		byKey[k] = append(byKey[k], i)
+	}
+	family := make([]int, len(tracks)) // each track's family, by its first member
+	for i := range family {
+		family[i] = i
+	}
+	var find func(int) int
+	find = func(i int) int {
+		if family[i] != i {
+			family[i] = find(family[i])
+		}
+		return family[i]
+	}
+	join := func(i, j int) {
+		ri, rj := find(i), find(j)
+		family[max(ri, rj)] = min(ri, rj)
+	}
+	for _, idx := range byKey {
+		var byRemixer []int
+		for a, i := range idx {
+			if creditedToRemixer(tracks[i]) {
+				byRemixer = append(byRemixer, i)
+			}
+			for _, j := range idx[a+1:] {
+				if sharesFamilyArtist(tracks[i], tracks[j]) {
+					join(i, j)
+				}
+			}
+		}
+		for _, i := range byRemixer {
+			others := map[int]bool{}
+			for _, j := range idx {
+				if find(j) != find(i) {
+					others[find(j)] = true
+				}
+			}
+			if len(others) == 1 {
+				for root := range others {
+					join(i, root)
+				}
+			}
+		}
+	}
+	members := map[int][]int{}
+	var order []int
+	for i := range tracks {
+		root := find(i)
+		if _, ok := members[root]; !ok {
+			order = append(order, root)
+		}
+		members[root] = append(members[root], i)
+	}
+	var families [][]int
+	for _, root := range order {
+		if len(members[root]) > 1 {
+			families = append(families, members[root])
+		}
+	}
+	return families
+}
+
+// OnePerFamily keeps a single version of each song — the original (the version whose
+// title carries no descriptor) when present, otherwise the first in input order — and
+// returns the kept tracks in input order plus the alternate versions it set aside.
+func OnePerFamily(tracks []track.Track) (kept, alternates []track.Track) {
+	keptIdx, altIdx := OnePerFamilyIndexes(tracks)
+	for _, i := range keptIdx {
+		kept = append(kept, tracks[i])
+	}
+	for _, i := range altIdx {
+		alternates = append(alternates, tracks[i])
+	}
+	return kept, alternates
+}
+
+// OnePerFamilyIndexes is OnePerFamily returning indices into tracks, for callers that
+// keep data alongside each track.
+func OnePerFamilyIndexes(tracks []track.Track) (kept, alternates []int) {
+	drop := map[int]bool{}
+	for _, fam := range Families(tracks) {
+		keep := fam[0]
+		for _, i := range fam {
+			if !track.IsAlternateVersion(tracks[i].Title) {
+				keep = i
+				break
+			}
+		}
+		for _, i := range fam {
+			if i != keep {
+				drop[i] = true
+			}
+		}
+	}
+	for i := range tracks {
+		if drop[i] {
+			alternates = append(alternates, i)
+		} else {
+			kept = append(kept, i)
+		}
+	}
+	return kept, alternates
+}
+
+// FamilySeparation is the Separation rule that keeps versions of one song at least
+// minGap positions apart.
+func FamilySeparation(minGap int) Separation {
+	return Separation{Name: "family", Groups: func(tracks []track.Track) [][]string {
+		groups := make([][]string, len(tracks))
+		for _, fam := range Families(tracks) {
+			first := tracks[fam[0]]
+			name := fmt.Sprintf("%s by %s", first.Title, first.Artist)
+			for _, i := range fam {
+				groups[i] = []string{name}
+			}
+		}
+		return groups
+	}, MinGap: minGap}
+}
+
+// Duplicate is a track that is another copy of a recording already in the library.
+type Duplicate struct {
+	Index int // the copy set aside
+	Of    int // the copy kept
+}
+
+// DuplicateIndexes keeps the first copy of each recording — tracks sharing a
+// track.DuplicateKey, such as an original and its remaster — and returns the kept
+// indices in input order plus the copies it set aside. Unlike OnePerFamilyIndexes it
+// leaves other versions of a song, such as remixes, alone.
+func DuplicateIndexes(tracks []track.Track) (kept []int, dups []Duplicate) {
+	first := map[string]int{}
+	for i, t := range tracks {
+		k := track.DuplicateKey(t.Title, t.Artist)
+		if of, ok := first[k]; ok {
+			dups = append(dups, Duplicate{Index: i, Of: of})
+			continue
+		}
+		first[k] = i
+		kept = append(kept, i)
+	}
+	return kept, dups
+}
+
+// AlbumSeparation is the Soft Separation rule that keeps tracks from one album or EP
+// at least minGap positions apart, so a compilation's tracks don't run back to back
+// just because they share a key and tempo. Tracks with no album are left alone.
+func AlbumSeparation(minGap int) Separation {
+	return Separation{Name: "album", Group: func(t track.Track) string {
+		return strings.ToLower(strings.TrimSpace(t.Album))
+	}, MinGap: minGap, Soft: true}
+}
+
+// ArtistSeparation is the Separation rule that keeps tracks by one artist at least
+// minGap positions apart. A collaboration counts for each of its artists, so "A feat.
+// B" is kept apart from both A and B.
+func ArtistSeparation(minGap int) Separation {
+	return Separation{Name: "artist", Groups: func(tracks []track.Track) [][]string {
+		groups := make([][]string, len(tracks))
+		for i, t := range tracks {
+			groups[i] = track.Artists(t.Artist)
+		}
+		return groups
+	}, MinGap: minGap}
+}
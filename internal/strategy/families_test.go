@@ -0,0 +1,167 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func versionTracks() []track.Track {
+	tracks := []track.Track{
+		mkTrack("Strobe (Extended Mix)", 128, 50, "8A"),
+		mkTrack("Other", 128, 55, "8A"),
+		mkTrack("Strobe", 128, 60, "9A"),
+		mkTrack("Strobe - Radio Edit", 128, 65, "9A"),
+		mkTrack("Another", 128, 70, "10A"),
+	}
+	for i := range tracks {
+		tracks[i].Artist = "deadmau5"
+	}
+	return tracks
+}
+
+func TestFamiliesGroupVersions(t *testing.T) {
+	fams := Families(versionTracks())
+	if len(fams) != 1 || len(fams[0]) != 3 {
+		t.Fatalf("Families = %v, want one family of 3", fams)
+	}
+}
+
+func TestFamiliesMatchAnySharedArtist(t *testing.T) {
+	tracks := []track.Track{
+		{Title: "Strobe (Original Mix)", Artist: "deadmau5"},
+		{Title: "Strobe (Eric Prydz Remix)", Artist: "Eric Prydz"},               // credited to the remixer
+		{Title: "Strobe (Dubfire Edit)", Artist: "Dubfire, deadmau5"},            // the song's artist second
+		{Title: "Strobe (Live)", Artist: "Someone feat. deadmau5"},               // a featured artist
+		{Title: "Strobe", Artist: "The Strobes"},                                 // another song, same title
+		{Title: "Ghosts 'n' Stuff (Nero Remix)", Artist: "deadmau5 & Rob Swire"}, // alone
+	}
+	if fams := Families(tracks[:4]); len(fams) != 1 || !slices.Equal(fams[0], []int{0, 1, 2, 3}) {
+		t.Fatalf("Families = %v, want the four versions together", fams)
+	}
+	// With two songs called Strobe, the remixer's version can't say whose it is.
+	if fams := Families(tracks[:5]); len(fams) != 1 || !slices.Equal(fams[0], []int{0, 2, 3}) {
+		t.Fatalf("Families = %v, want deadmau5's versions together, the rest apart", fams)
+	}
+	kept, alternates := OnePerFamily(append(tracks[:4:4], tracks[5]))
+	if len(kept) != 2 || kept[0].Title != "Strobe (Original Mix)" || len(alternates) != 3 {
+		t.Fatalf("kept %v; want the original mix as the song, with 3 alternates", titlesOf(kept))
+	}
+}
+
+func TestOnePerFamilyKeepsOriginal(t *testing.T) {
+	kept, alternates := OnePerFamily(versionTracks())
+	if len(kept) != 3 || len(alternates) != 2 {
+		t.Fatalf("kept %d, alternates %d; want 3 and 2", len(kept), len(alternates))
+	}
+	for _, tr := range kept {
+		if FamilyKey(tr) == FamilyKey(versionTracks()[0]) && tr.Title != "Strobe" {
+			t.Fatalf("kept %q, want the original", tr.Title)
+		}
+	}
+}
+
+func TestSortEnforcesFamilySeparation(t *testing.T) {
+	tracks := append(versionTracks(), flowTestTracks()...)
+	ctx := WithSeparation(WithSeed(context.Background(), 3), FamilySeparation(4))
+	for _, name := range []string{flowStrategyName, defaultStrategyName, chaveStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(res.Ordered) != len(tracks) {
+			t.Fatalf("%s: placed %d of %d tracks", name, len(res.Ordered), len(tracks))
+		}
+		last := -100
+		for i, tr := range res.Ordered {
+			if FamilyKey(tr) != FamilyKey(versionTracks()[0]) {
+				continue
+			}
+			if i-last < 4 {
+				t.Fatalf("%s: versions %d apart, want >= 4\n%s", name, i-last, titlesOf(res.Ordered))
+			}
+			last = i
+		}
+	}
+}
+
+// When a rule cannot be met — every track is a version of one song — Sort still
+// returns every track and says so in Result.Warnings.
+func TestSortWarnsWhenRulesCannotBeMet(t *testing.T) {
+	key := track.Key{Number: 8, Mode: track.ModeA}
+	tracks := []track.Track{
+		{Title: "Song", Artist: "A", BPM: 120, Energy: 50, Key: key},
+		{Title: "Song (Extended Mix)", Artist: "A", BPM: 120, Energy: 55, Key: key},
+		{Title: "Song (Radio Edit)", Artist: "A", BPM: 121, Energy: 60, Key: key},
+	}
+	ctx := WithSeparation(context.Background(), FamilySeparation(4))
+	res, err := Sort(ctx, NewFlowSorter(), tracks)
+	if err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+	if len(res.Ordered) != 3 || len(res.Warnings) != 1 {
+		t.Fatalf("got %d tracks and warnings %q; want 3 tracks and one warning", len(res.Ordered), res.Warnings)
+	}
+}
+
+func TestDuplicateIndexesKeepsVersions(t *testing.T) {
+	tracks := append(versionTracks(), mkTrack("Strobe - Remastered 2020", 128, 60, "9A"))
+	tracks[len(tracks)-1].Artist = "Deadmau5"
+	kept, dups := DuplicateIndexes(tracks)
+	want := []Duplicate{{Index: 3, Of: 2}, {Index: 5, Of: 2}}
+	if len(kept) != 4 || !slices.Equal(dups, want) {
+		t.Fatalf("kept %v, duplicates %v; want the radio edit and remaster set aside as copies of Strobe", kept, dups)
+	}
+
+	halo := []track.Track{mkTrack("Halo", 80, 40, "4A"), mkTrack("Halo - Remastered", 80, 40, "4A")}
+	halo[0].Artist, halo[1].Artist = "Beyoncé & Jay-Z", "JAY-Z feat. Beyonce"
+	if _, dups := DuplicateIndexes(halo); !slices.Equal(dups, []Duplicate{{Index: 1, Of: 0}}) {
+		t.Fatalf("duplicates %v; want one Halo, however its credit is spelled", dups)
+	}
+}
+
+func TestSortEnforcesArtistSeparation(t *testing.T) {
+	tracks := flowTestTracks()
+	for i := range tracks {
+		tracks[i].Artist = []string{"Beyoncé", "B", "C & D", "BEYONCE", "E", "The C"}[i%6]
+	}
+	ctx := WithSeparation(WithSeed(context.Background(), 5), ArtistSeparation(2))
+	for _, name := range []string{flowStrategyName, defaultStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i := 1; i < len(res.Ordered); i++ {
+			if track.SharesArtist(res.Ordered[i].Artist, res.Ordered[i-1].Artist) {
+				t.Fatalf("%s: %s back to back at %d\n%s", name, res.Ordered[i].Artist, i, titlesOf(res.Ordered))
+			}
+		}
+	}
+}
+
+func TestArtistSeparationCountsFeaturedArtists(t *testing.T) {
+	tracks := []track.Track{
+		mkTrack("Solo", 124, 60, "8A"), mkTrack("Guest Spot", 124, 61, "8A"),
+		mkTrack("Filler 1", 124, 40, "3B"), mkTrack("Filler 2", 124, 80, "3B"),
+	}
+	for i, a := range []string{"Drake", "Kaskade feat. Drake", "A", "B"} {
+		tracks[i].Artist = a
+	}
+	ctx := WithSeparation(WithSeed(context.Background(), 5), ArtistSeparation(2))
+	for _, name := range []string{flowStrategyName, defaultStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		for i := 1; i < len(res.Ordered); i++ {
+			if track.SharesArtist(res.Ordered[i].Artist, res.Ordered[i-1].Artist) {
+				t.Fatalf("%s: the featured artist plays back to back\n%s", name, titlesOf(res.Ordered))
+			}
+		}
+	}
+}
@@ -50,17 +50,20 @@ func (s *FlowSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.Tr
 	rng := rand.New(rand.NewSource(seed))
 
 	matrix := buildCostMatrix(seq, s.weights)
+	matrix.rules = bindRules(ctx, seq)
+	matrix.progress = progressFromContext(ctx)
 
 	bestPerm := matrix.bestGreedy(chooseStarts(seq, rng))
 	bestPerm, err := matrix.localSearch(ctx, bestPerm)
-	if err != nil {
-		return nil, err
-	}
 
 	out := make([]track.Track, n)
 	for i, idx := range bestPerm {
 		out[i] = seq[idx]
 	}
+	if err != nil {
+		// Every track is placed; the ordering just hasn't been fully improved.
+		return nil, partial(out, err)
+	}
 	return out, nil
 }
 
@@ -74,6 +77,10 @@ type costMatrix struct {
 	minResets int // target wave cadence, invariant to ordering
 	maxResets int
 	buf       []float64 // reusable scratch for gathering intensities along a permutation
+
+	rules []orderRule // optional ordering rules (separation, placement) scored alongside the mix
+
+	progress ProgressFunc // optional; reports seeding and improvement
 }
 
 func buildCostMatrix(seq []track.Track, w Weights) *costMatrix {
@@ -101,7 +108,8 @@ func buildCostMatrix(seq []track.Track, w Weights) *costMatrix {
 func (cm *costMatrix) cost(i, j int) float64 { return cm.m[i*cm.n+j] }
 
 // pathCost returns the full score of a permutation: pairwise coherence plus the
-// global contour term. It equals ScoreMixWith(seq, w).Total for the same ordering.
+// global contour term. It equals ScoreMixWith(seq, w).Total for the same ordering,
+// plus the ordering-rule penalty when rules are set.
 func (cm *costMatrix) pathCost(perm []int) float64 {
 	total := 0.0
 	for k := 0; k+1 < len(perm); k++ {
@@ -111,6 +119,9 @@ func (cm *costMatrix) pathCost(perm []int) float64 {
 		cm.buf[i] = cm.intens[idx]
 	}
 	total += cm.contourW * contourPenalty(cm.buf, cm.minResets, cm.maxResets).RawPenalty
+	if cm.rules != nil {
+		total += rulesCost(cm.rules, perm)
+	}
 	return total
 }
 
@@ -119,12 +130,13 @@ func (cm *costMatrix) pathCost(perm []int) float64 {
 func (cm *costMatrix) bestGreedy(starts []int) []int {
 	var best []int
 	bestCost := math.Inf(1)
-	for _, start := range starts {
+	for i, start := range starts {
 		perm := cm.greedy(start)
 		if c := cm.pathCost(perm); c < bestCost {
 			bestCost = c
 			best = perm
 		}
+		cm.progress.report(Progress{Stage: StageSeeding, Placed: cm.n, Total: cm.n, Step: i + 1, Steps: len(starts), Score: bestCost})
 	}
 	return best
 }
@@ -167,15 +179,19 @@ const maxLocalSearchPasses = 60
 // localSearch improves perm with 2-opt (segment reversal) and or-opt (segment
 // relocation, lengths 1-3) until a full pass yields no improvement or the pass cap is
 // reached. Every accepted move lowers total cost by at least improvementEps, so this
-// terminates.
+// terminates. When ctx is done it returns the best ordering so far with ctx's error.
 func (cm *costMatrix) localSearch(ctx context.Context, perm []int) ([]int, error) {
 	cost := cm.pathCost(perm)
 	scratch := make([]int, len(perm))
 
-	for range maxLocalSearchPasses {
+	for pass := range maxLocalSearchPasses {
 		if err := ctx.Err(); err != nil {
-			return nil, err
+			return perm, err
 		}
+		report := func() {
+			cm.progress.report(Progress{Stage: StageImproving, Placed: cm.n, Total: cm.n, Step: pass + 1, Steps: maxLocalSearchPasses, Score: cost})
+		}
+		report()
 		improved := false
 
 		// 2-opt: reverse perm[i..j].
@@ -190,7 +206,10 @@ func (cm *costMatrix) localSearch(ctx context.Context, perm []int) ([]int, error
 				}
 			}
 			if err := ctx.Err(); err != nil {
-				return nil, err
+				return perm, err
+			}
+			if cm.progress != nil {
+				report()
 			}
 		}
 
@@ -208,9 +227,12 @@ func (cm *costMatrix) localSearch(ctx context.Context, perm []int) ([]int, error
 						improved = true
 					}
 				}
-			}
-			if err := ctx.Err(); err != nil {
-				return nil, err
+				if err := ctx.Err(); err != nil {
+					return perm, err
+				}
+				if cm.progress != nil {
+					report()
+				}
 			}
 		}
 
@@ -233,10 +255,10 @@ func reverseSegment(dst []int, i, j int) {
 // relocateSegment writes into dst the result of removing the length-l segment that
 // starts at index i in src and reinserting it so it begins at index p of the
 // remaining sequence. dst and src must be distinct slices of equal length. It does no
-// heap allocation (l is small: the caller uses 1-3), so it is cheap in the local
+// heap allocation (l is small: the callers use 1-3), so it is cheap in the local
 // search inner loop.
-func relocateSegment(dst, src []int, i, l, p int) {
-	var segbuf [8]int
+func relocateSegment[T any](dst, src []T, i, l, p int) {
+	var segbuf [8]T
 	seg := segbuf[:l]
 	copy(seg, src[i:i+l])
 
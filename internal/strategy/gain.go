@@ -0,0 +1,30 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// GainTarget is the integrated loudness, in LUFS, SuggestGain brings tracks to: the
+// level most streaming and auto-mix players normalize to.
+const GainTarget = -14.0
+
+// Gain limits, in dB. Boosts stop lower than cuts: loudness says nothing of a track's
+// peaks, and a quiet track turned up far enough will clip.
+const (
+	gainMaxBoost = 6.0
+	gainMaxCut   = 12.0
+)
+
+// SuggestGain suggests the gain, in dB, that brings t to GainTarget from its loudness,
+// rounded to a tenth and clamped to a 12 dB cut or a 6 dB boost; false when t has no
+// loudness. Playing a set with each track's gain applied keeps its perceived volume
+// steady from track to track.
+func SuggestGain(t track.Track) (float64, bool) {
+	if t.Loudness == nil {
+		return 0, false
+	}
+	gain := math.Max(-gainMaxCut, math.Min(gainMaxBoost, GainTarget-*t.Loudness))
+	return math.Round(gain*10) / 10, true
+}
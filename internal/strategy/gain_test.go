@@ -0,0 +1,30 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSuggestGain(t *testing.T) {
+	lufs := func(v float64) *float64 { return &v }
+	cases := []struct {
+		name     string
+		loudness *float64
+		gain     float64
+		ok       bool
+	}{
+		{"unknown loudness", nil, 0, false},
+		{"loud master is cut", lufs(-8.46), -5.5, true},
+		{"quiet track is boosted", lufs(-17), 3, true},
+		{"on target", lufs(-14), 0, true},
+		{"boost stops at 6 dB", lufs(-30), 6, true},
+		{"cut stops at 12 dB", lufs(0), -12, true},
+	}
+	for _, tc := range cases {
+		gain, ok := SuggestGain(track.Track{Loudness: tc.loudness})
+		if gain != tc.gain || ok != tc.ok {
+			t.Errorf("%s: SuggestGain = %v, %v; want %v, %v", tc.name, gain, ok, tc.gain, tc.ok)
+		}
+	}
+}
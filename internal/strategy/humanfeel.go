@@ -0,0 +1,178 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Human-feel tuning: a spike has to stand this far (intensity, 0-100) above both its
+// neighbors; moves stay this many positions apart so the imperfections are spread out;
+// humanFeelSalt keeps the noise's random stream apart from the sorter's for one seed.
+const (
+	spikeLift        = 15.0
+	humanFeelSpacing = 4
+	humanFeelSalt    = 0x68756d616e
+)
+
+// HumanFeel is a named amount of deliberate imperfection applied to a finished
+// ordering: a mathematically perfect set can feel sterile, so a profile allows a few
+// +2 key moves that also switch mode (8A into 10B — a lift the scoring avoids) and a
+// few surprise energy spikes. Each is made by moving one track, only where it costs at
+// most MaxMoveCost of mix score, and the choices come from the run's seed, so the same
+// seed always gives the same set.
+type HumanFeel struct {
+	Name         string
+	KeyDetours   int
+	EnergySpikes int
+	MaxMoveCost  float64
+}
+
+// HumanFeelProfiles are the named profiles, from the lightest touch to the loosest.
+var HumanFeelProfiles = []HumanFeel{
+	{Name: "subtle", KeyDetours: 1, MaxMoveCost: 1.0},
+	{Name: "natural", KeyDetours: 2, EnergySpikes: 1, MaxMoveCost: 1.5},
+	{Name: "loose", KeyDetours: 4, EnergySpikes: 2, MaxMoveCost: 2.5},
+}
+
+// ParseHumanFeel looks up a profile by name (case-insensitive); "off" or "" is the
+// zero profile, which changes nothing.
+func ParseHumanFeel(name string) (HumanFeel, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "" || name == "off" {
+		return HumanFeel{}, nil
+	}
+	names := make([]string, len(HumanFeelProfiles))
+	for i, p := range HumanFeelProfiles {
+		if p.Name == name {
+			return p, nil
+		}
+		names[i] = p.Name
+	}
+	return HumanFeel{}, fmt.Errorf("unknown human-feel profile %q (want off, %s)", name, strings.Join(names, ", "))
+}
+
+func (h HumanFeel) empty() bool {
+	return h.KeyDetours == 0 && h.EnergySpikes == 0
+}
+
+const humanFeelContextKey contextKey = "strategy.humanFeel"
+
+// WithHumanFeel asks Sort to roughen every strategy's ordering with a profile. Ordering
+// rules are enforced afterwards, so pins and placements still hold.
+func WithHumanFeel(ctx context.Context, h HumanFeel) context.Context {
+	return context.WithValue(ctx, humanFeelContextKey, h)
+}
+
+func humanFeelFromContext(ctx context.Context) HumanFeel {
+	if ctx == nil {
+		return HumanFeel{}
+	}
+	h, _ := ctx.Value(humanFeelContextKey).(HumanFeel)
+	return h
+}
+
+// humanize applies h to ordered and returns the new ordering with a note per change.
+func humanize(ctx context.Context, ordered []track.Track, h HumanFeel) ([]track.Track, []string) {
+	if h.empty() || len(ordered) < 4 {
+		return ordered, nil
+	}
+	seed, ok := seedFromContext(ctx)
+	if !ok || seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed ^ humanFeelSalt))
+
+	cur := slices.Clone(ordered)
+	var touched []int
+	var notes []string
+	for range h.KeyDetours {
+		next, at, ok := roughen(cur, h.MaxMoveCost, touched, rng, isKeyDetour)
+		if !ok {
+			break
+		}
+		cur, touched = next, append(touched, at)
+		notes = append(notes, fmt.Sprintf("Human feel (%s): %s → %s at %d is a +2 key lift across modes",
+			h.Name, cur[at-1].KeyString(), cur[at].KeyString(), at+1))
+	}
+	for range h.EnergySpikes {
+		next, at, ok := roughen(cur, h.MaxMoveCost, touched, rng, isEnergySpike)
+		if !ok {
+			break
+		}
+		cur, touched = next, append(touched, at)
+		notes = append(notes, fmt.Sprintf("Human feel (%s): %q at %d is a surprise energy spike (%d)",
+			h.Name, cur[at].Title, at+1, cur[at].Energy))
+	}
+	return cur, notes
+}
+
+// isKeyDetour reports whether playing t between prev and next makes the lift into it a
+// +2 step with a mode change.
+func isKeyDetour(prev, t, _ track.Track) bool {
+	trans := NewTransition(prev, t)
+	return !trans.AnyKey && trans.Offset() == 2 && trans.ModeChange
+}
+
+// isEnergySpike reports whether t stands well above both prev and next.
+func isEnergySpike(prev, t, next track.Track) bool {
+	return intensity(t)-max(intensity(prev), intensity(next)) >= spikeLift
+}
+
+// roughen moves one track of ordered into a gap where fits(prev, moved, next) holds,
+// picking at random among the moves that cost at most maxCost of mix score and land
+// away from the touched positions. It returns the new ordering and where the moved
+// track ended up, or false when no move qualifies. The first and last positions are
+// left alone.
+func roughen(ordered []track.Track, maxCost float64, touched []int, rng *rand.Rand,
+	fits func(prev, t, next track.Track) bool) ([]track.Track, int, bool) {
+	n := len(ordered)
+	base := mixTotal(ordered, DefaultWeights)
+	type move struct{ from, gap int }
+	var moves []move
+	scratch := make([]track.Track, n)
+	for g := 1; g < n-1; g++ {
+		// The moved track lands between ordered[g-1] and ordered[g].
+		if slices.ContainsFunc(touched, func(at int) bool { return abs(at-g) < humanFeelSpacing }) {
+			continue
+		}
+		for j := 1; j < n-1; j++ {
+			if j == g-1 || j == g || !fits(ordered[g-1], ordered[j], ordered[g]) {
+				continue
+			}
+			relocateSegment(scratch, ordered, j, 1, landing(j, g))
+			if mixTotal(scratch, DefaultWeights)-base <= maxCost {
+				moves = append(moves, move{j, g})
+			}
+		}
+	}
+	if len(moves) == 0 {
+		return ordered, 0, false
+	}
+	m := moves[rng.Intn(len(moves))]
+	out := make([]track.Track, n)
+	at := landing(m.from, m.gap)
+	relocateSegment(out, ordered, m.from, 1, at)
+	return out, at, true
+}
+
+// landing is where a track moved from index from into the gap before index gap ends
+// up once it has been taken out of the sequence.
+func landing(from, gap int) int {
+	if from < gap {
+		return gap - 1
+	}
+	return gap
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestHumanFeelIsSeededAndBounded(t *testing.T) {
+	flow, _ := Get(flowStrategyName)
+	tracks := chaveTracks(40)
+	loose, err := ParseHumanFeel("Loose")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := WithSeed(context.Background(), 11)
+
+	plain, err := Sort(ctx, flow, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rough, err := Sort(WithHumanFeel(ctx, loose), flow, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	again, _ := Sort(WithHumanFeel(ctx, loose), flow, tracks)
+	if titles(rough.Ordered) != titles(again.Ordered) {
+		t.Fatal("the same seed and profile should give the same set")
+	}
+	if len(rough.Notes) == 0 || titles(rough.Ordered) == titles(plain.Ordered) {
+		t.Fatalf("loose should roughen the set, notes %v", rough.Notes)
+	}
+
+	detours, spikes := 0, 0
+	for k := 1; k+1 < len(rough.Ordered); k++ {
+		o := rough.Ordered
+		if isKeyDetour(o[k-1], o[k], o[k+1]) {
+			detours++
+		}
+		if isEnergySpike(o[k-1], o[k], o[k+1]) {
+			spikes++
+		}
+	}
+	if detours == 0 && spikes == 0 {
+		t.Error("no detour or spike in the roughened set")
+	}
+	moves := float64(loose.KeyDetours + loose.EnergySpikes)
+	if extra := mixTotal(rough.Ordered, DefaultWeights) - mixTotal(plain.Ordered, DefaultWeights); extra > moves*loose.MaxMoveCost+1e-9 {
+		t.Errorf("roughening cost %.2f, more than %g moves of %.1f", extra, moves, loose.MaxMoveCost)
+	}
+
+	off, err := ParseHumanFeel("off")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if same, _ := Sort(WithHumanFeel(ctx, off), flow, tracks); titles(same.Ordered) != titles(plain.Ordered) {
+		t.Error("the off profile should change nothing")
+	}
+	if _, err := ParseHumanFeel("sloppy"); err == nil {
+		t.Error("an unknown profile should be an error")
+	}
+}
@@ -0,0 +1,266 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// inventoryEdge is the share of a set, at least one track, that Inventory takes as
+// its opening and its closing stretch: the tracks an arc's start and end ask for.
+const inventoryEdge = 0.1
+
+// Inventory checks, before sorting, whether tracks can plausibly satisfy the
+// constraints in ctx at the set length it asks for, and returns a warning for each
+// shortfall it finds: a limit beyond the library; too few tracks near an arc's
+// opening or closing energy, or an energy target's; a placement rule that matches
+// nothing; pins whose positions can't all be met. When the whole list is sorted, so no limit can leave the trouble out, it
+// also finds more matches for a placement rule than its window holds, an artist with
+// more tracks than a separation rule leaves room for, and keys in groups no
+// compatible move joins, with too few anykey tracks to bridge them. Under strict
+// Camelot it finds both modes with no relative pair to change mode on. It only counts
+// tracks, so it takes moments; a clean inventory doesn't promise a sort satisfies
+// everything, but each warning names something it can't.
+func Inventory(ctx context.Context, tracks []track.Track) []string {
+	if len(tracks) == 0 {
+		return nil
+	}
+	n := len(tracks)
+	var warnings []string
+	if limit := limitFromContext(ctx); limit > n {
+		warnings = append(warnings, fmt.Sprintf("a %d-track set was asked for, but only %d track(s) are left to sort", limit, n))
+	} else if limit > 0 {
+		n = limit
+	}
+	edge := max(1, int(math.Round(inventoryEdge*float64(n))))
+
+	if a := arcFromContext(ctx); a != nil {
+		scale := newArcScale(*a, tracks)
+		for _, end := range []struct {
+			name     string
+			progress float64
+		}{{"opens", 0}, {"closes", 1}} {
+			target := scale.energy(end.progress)
+			if have := countNearEnergy(tracks, target, arcTolerance); have < edge {
+				warnings = append(warnings, fmt.Sprintf(
+					"the %s arc %s near energy %.0f, but only %d track(s) are within %.0f of it for the %d it wants there; add some, or choose another arc",
+					a.Name, end.name, target, have, arcTolerance, edge))
+			}
+		}
+	}
+
+	timing := bindPlacement(tracks, nil)
+	for _, target := range energyTargetsFromContext(ctx) {
+		lo, hi := target.Window.resolve(timing.total / 60)
+		want := max(1, int(math.Round((hi-lo)*float64(n))))
+		if have := countNearEnergy(tracks, float64(target.Energy), targetTolerance); have < want {
+			warnings = append(warnings, fmt.Sprintf(
+				"energy target %s wants about %d track(s) near energy %d, but only %d are within %.0f of it",
+				target.Name, want, target.Energy, have, targetTolerance))
+		}
+	}
+
+	if rules := placementFromContext(ctx); len(rules) > 0 {
+		b := bindPlacement(tracks, rules)
+		for r, rule := range rules {
+			matched := 0
+			for _, m := range b.match[r] {
+				if m {
+					matched++
+				}
+			}
+			room := int(math.Ceil((b.hi[r] - b.lo[r]) * float64(n)))
+			switch {
+			case matched == 0:
+				warnings = append(warnings, fmt.Sprintf("placement rule %s matches no tracks", rule.Name))
+			case n == len(tracks) && matched > room:
+				warnings = append(warnings, fmt.Sprintf(
+					"placement rule %s matches %d track(s), but its window holds about %d; widen it, or expect %d outside",
+					rule.Name, matched, room, matched-room))
+			}
+		}
+	}
+
+	warnings = append(warnings, pinConflicts(tracks, pinsFromContext(ctx), n)...)
+
+	if n == len(tracks) {
+		for _, rule := range separationFromContext(ctx) {
+			if rule.Soft || rule.MinGap < 2 {
+				continue
+			}
+			fits := (n-1)/rule.MinGap + 1
+			counts := map[string]int{}
+			for _, groups := range rule.groupsOf(tracks) {
+				for _, g := range groups {
+					counts[g]++
+				}
+			}
+			var over []string
+			for g, c := range counts {
+				if c > fits {
+					over = append(over, fmt.Sprintf("%s (%d)", g, c))
+				}
+			}
+			if len(over) > 0 {
+				slices.Sort(over)
+				warnings = append(warnings, fmt.Sprintf(
+					"separating tracks by %s at least %d apart leaves room for %d of each in a %d-track set, fewer than these have: %s",
+					rule.Name, rule.MinGap, fits, n, strings.Join(over, ", ")))
+			}
+		}
+
+		groups, bridges := keyGroups(tracks)
+		if jumps := len(groups) - 1 - bridges; jumps > 0 {
+			warnings = append(warnings, fmt.Sprintf(
+				"the keys fall into %d groups no compatible move joins (%s), with %d anykey track(s) to bridge them: expect at least %d key jump(s)",
+				len(groups), strings.Join(groups, "; "), bridges, jumps))
+		}
+	}
+	if strictCamelotFromContext(ctx) {
+		if modes, relative := modeInventory(tracks); modes == 2 && !relative {
+			warnings = append(warnings,
+				"strict Camelot: the keys include both A and B but no relative pair, such as 8A and 8B, to change mode on; expect a diagonal move")
+		}
+	}
+	return warnings
+}
+
+// pinConflicts finds the pins an n-track set can't all honor, because more of them
+// want a stretch of positions than it has. Taking the pins whose windows end first
+// first, it gives each the earliest position in its window no other pin holds, and
+// warns of each left with none. Pins that match no track are left out.
+func pinConflicts(tracks []track.Track, pins []Pin, n int) []string {
+	type window struct{ pin, lo, hi int }
+	var windows []window
+	for p, pin := range pins {
+		if pin.Match != nil && slices.ContainsFunc(tracks, pin.Match) {
+			lo, hi := pin.span(n)
+			windows = append(windows, window{p, lo, hi})
+		}
+	}
+	slices.SortStableFunc(windows, func(a, b window) int { return cmp.Compare(a.hi, b.hi) })
+	holder := map[int]int{} // the pin holding each position
+	var warnings []string
+	for _, w := range windows {
+		at := w.lo
+		for ; at <= w.hi; at++ {
+			if _, held := holder[at]; !held {
+				break
+			}
+		}
+		if at <= w.hi {
+			holder[at] = w.pin
+			continue
+		}
+		var rivals []string
+		for pos := w.lo; pos <= w.hi; pos++ {
+			rivals = append(rivals, fmt.Sprintf("%q", pins[holder[pos]].Name))
+		}
+		warnings = append(warnings, fmt.Sprintf(
+			"pin %q can't be met in a %d-track set: %s hold every position it allows",
+			pins[w.pin].Name, n, strings.Join(rivals, ", ")))
+	}
+	return warnings
+}
+
+// countNearEnergy counts the tracks within tolerance of energy.
+func countNearEnergy(tracks []track.Track, energy, tolerance float64) int {
+	n := 0
+	for _, t := range tracks {
+		if math.Abs(float64(t.Energy)-energy) <= tolerance {
+			n++
+		}
+	}
+	return n
+}
+
+// keyGroups splits the keys of tracks into the groups Camelot-compatible moves join,
+// each written as its keys in wheel order ("8A 9A 9B"), and counts the anykey tracks
+// that can bridge between them. A track whose key changes joins its entry and exit
+// key; tracks with no key are left out.
+func keyGroups(tracks []track.Track) (groups []string, bridges int) {
+	parent := map[track.Key]track.Key{}
+	var find func(k track.Key) track.Key
+	find = func(k track.Key) track.Key {
+		if p := parent[k]; p != k {
+			parent[k] = find(p)
+		}
+		return parent[k]
+	}
+	join := func(a, b track.Key) { parent[find(a)] = find(b) }
+	for _, t := range tracks {
+		if t.AnyKey() {
+			bridges++
+			continue
+		}
+		for _, k := range []track.Key{t.EntryKey(), t.ExitKey()} {
+			if _, ok := parent[k]; !ok && k.Number != 0 {
+				parent[k] = k
+			}
+		}
+		if t.EntryKey().Number != 0 && t.ExitKey().Number != 0 {
+			join(t.EntryKey(), t.ExitKey())
+		}
+	}
+	keys := make([]track.Key, 0, len(parent))
+	for k := range parent {
+		keys = append(keys, k)
+	}
+	slices.SortFunc(keys, func(a, b track.Key) int {
+		return cmp.Or(cmp.Compare(a.Number, b.Number), cmp.Compare(a.Mode, b.Mode))
+	})
+	for i, a := range keys {
+		for _, b := range keys[i+1:] {
+			if a.Compatible(b) {
+				join(a, b)
+			}
+		}
+	}
+	byRoot := map[track.Key]string{}
+	var roots []track.Key
+	for _, k := range keys {
+		r := find(k)
+		if _, ok := byRoot[r]; !ok {
+			roots = append(roots, r)
+			byRoot[r] = k.String()
+		} else {
+			byRoot[r] += " " + k.String()
+		}
+	}
+	for _, r := range roots {
+		groups = append(groups, byRoot[r])
+	}
+	return groups, bridges
+}
+
+// modeInventory counts the modes among the keys of tracks, and reports whether some
+// wheel number has both.
+func modeInventory(tracks []track.Track) (modes int, relative bool) {
+	seen := map[track.Key]bool{}
+	var a, b bool
+	for _, t := range tracks {
+		if t.AnyKey() || t.Key.Number == 0 {
+			continue
+		}
+		seen[t.Key] = true
+		a = a || t.Key.Mode == track.ModeA
+		b = b || t.Key.Mode == track.ModeB
+	}
+	for k := range seen {
+		if k.Mode == track.ModeA && seen[k.Relative()] {
+			relative = true
+		}
+	}
+	if a {
+		modes++
+	}
+	if b {
+		modes++
+	}
+	return modes, relative
+}
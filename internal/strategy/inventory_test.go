@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestInventory(t *testing.T) {
+	var tracks []track.Track
+	for i := range 20 {
+		artist := fmt.Sprint("Artist", i)
+		if i%2 == 0 {
+			artist = "Regular"
+		}
+		key := "8A"
+		if i >= 17 {
+			key = "3B"
+		}
+		tracks = append(tracks, track.Track{Title: fmt.Sprint("t", i), Artist: artist, BPM: 124, Energy: 60 + i, Key: mustKey(key)})
+	}
+	if got := Inventory(context.Background(), tracks); len(got) != 1 || !strings.Contains(got[0], "2 groups no compatible move joins (3B; 8A)") {
+		t.Errorf("unconstrained inventory = %q; want only the key groups", got)
+	}
+
+	// Ten tracks by one artist can't sit four apart in twenty, and a set of 30 can't
+	// come from 20. A closing arc winds down to the library's calmest, which it has.
+	ctx := WithSeparation(WithStrictCamelot(context.Background()), ArtistSeparation(4))
+	got := strings.Join(Inventory(ctx, tracks), "\n")
+	for _, want := range []string{"room for 5 of each in a 20-track set, fewer than these have: regular (10)",
+		"no relative pair"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("inventory %q lacks %q", got, want)
+		}
+	}
+	got = strings.Join(Inventory(WithLimit(ctx, 30), tracks), "\n")
+	if !strings.Contains(got, "a 30-track set was asked for, but only 20") {
+		t.Errorf("inventory with a limit past the library = %q", got)
+	}
+
+	// Three calm outliers spread the calm end of the build arc's scale so thin that
+	// only one track is near where it opens, and only one near energy 20.
+	tracks[0].Energy, tracks[2].Energy, tracks[4].Energy = 0, 20, 40
+	build, _ := ParseArc("build")
+	ctx = WithEnergyTargets(WithArc(context.Background(), build),
+		EnergyTarget{Name: "first:50%=20", Window: Window{Lo: 0, Hi: 0.5}, Energy: 20})
+	got = strings.Join(Inventory(ctx, tracks), "\n")
+	for _, want := range []string{"the build arc opens near energy", "energy target first:50%=20 wants about 10 track(s) near energy 20, but only 1"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("inventory %q lacks %q", got, want)
+		}
+	}
+
+	// Two tracks can't both be among the last two when a third closes the set, though
+	// each could be alone.
+	ctx = WithPins(context.Background(),
+		Pin{Name: "t1@-2..", Match: titled("t1"), Earliest: -2},
+		Pin{Name: "--close t2", Match: titled("t2"), Position: -1},
+		Pin{Name: "t3@-2..", Match: titled("t3"), Earliest: -2},
+		Pin{Name: "t4@..5", Match: titled("t4"), Latest: 5},
+		Pin{Name: "nothing@1", Match: titled("nothing"), Position: 1})
+	got = strings.Join(Inventory(ctx, tracks), "\n")
+	if want := `pin "t3@-2.." can't be met in a 20-track set: "t1@-2..", "--close t2" hold every position it allows`; !strings.Contains(got, want) {
+		t.Errorf("inventory %q lacks %q", got, want)
+	}
+	if strings.Count(got, "can't be met") != 1 {
+		t.Errorf("inventory %q; want only t3's pin reported", got)
+	}
+}
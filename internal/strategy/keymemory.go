@@ -0,0 +1,114 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Key memory tuning. Nothing is weighed over the first keyMemoryWarmup tracks, which
+// are too few to call a set monotonous; after that each track costs up to
+// keyMemoryUnit, in proportion to how far its wheel position's share of the set so far
+// runs over the list's own share, and to how late in the set it plays.
+const (
+	keyMemoryWarmup = 8
+	keyMemoryUnit   = 2.0
+)
+
+const keyMemoryContextKey contextKey = "strategy.key-memory"
+
+// WithKeyMemory steers a long set away from the wheel positions (Camelot numbers, 8A
+// and 8B alike) it has already played most, toward the ones it has neglected. It
+// remembers every track so far, not just the last few steps: a track costs more the
+// more its position's share of the set before it runs over that position's share of
+// the tracks being sorted, so a library that lives in a few keys may still play them
+// and a set that has drifted into four keys for an hour is drawn back out. The cost
+// grows toward the end of the set, where monotony sets in. Weight scales it against
+// the mix score, as a soft rule's penalty is (see weighSoftRules); 0 or less leaves
+// ctx unchanged. Under a limit only the set itself is counted.
+func WithKeyMemory(ctx context.Context, weight float64) context.Context {
+	if weight <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, keyMemoryContextKey, weight)
+}
+
+func keyMemoryFromContext(ctx context.Context) float64 {
+	if ctx == nil {
+		return 0
+	}
+	w, _ := ctx.Value(keyMemoryContextKey).(float64)
+	return w
+}
+
+// boundKeyMemory is WithKeyMemory resolved against one track list: each track's
+// wheel position (0 for none, or a key-agnostic track), and each position's share of
+// the list.
+type boundKeyMemory struct {
+	number []int
+	share  [13]float64
+	span   int
+	weight float64
+}
+
+func bindKeyMemory(tracks []track.Track, weight float64, limit int) *boundKeyMemory {
+	b := &boundKeyMemory{number: make([]int, len(tracks)), span: len(tracks), weight: weight}
+	keyed := 0
+	for i, t := range tracks {
+		if t.AnyKey() {
+			continue
+		}
+		if n := t.EntryKey().Number; n > 0 {
+			b.number[i] = n
+			b.share[n]++
+			keyed++
+		}
+	}
+	for n := range b.share {
+		if keyed > 0 {
+			b.share[n] /= float64(keyed)
+		}
+	}
+	if limit > 0 && limit < b.span {
+		b.span = limit
+	}
+	return b
+}
+
+// overuse calls visit for each position of perm, past the warm-up, whose track's
+// wheel position the set has played more than its share, with how much more (0 to 1)
+// and how late the track plays (0 to 1).
+func (b *boundKeyMemory) overuse(perm []int, visit func(pos int, excess, late float64)) {
+	n := min(b.span, len(perm))
+	if n <= keyMemoryWarmup {
+		return
+	}
+	var played [13]int
+	keyed := 0
+	for pos, idx := range perm[:n] {
+		num := b.number[idx]
+		if num == 0 {
+			continue
+		}
+		if keyed >= keyMemoryWarmup {
+			if excess := float64(played[num])/float64(keyed) - b.share[num]; excess > 0 {
+				visit(pos, excess, float64(pos+1)/float64(n))
+			}
+		}
+		played[num]++
+		keyed++
+	}
+}
+
+func (b *boundKeyMemory) cost(perm []int) float64 {
+	total := 0.0
+	b.overuse(perm, func(_ int, excess, late float64) { total += excess * late })
+	return keyMemoryUnit * b.weight * total
+}
+
+func (*boundKeyMemory) soft() {}
+
+// conflicts marks the tracks that return to an overplayed wheel position.
+func (b *boundKeyMemory) conflicts(perm []int, out []bool) {
+	b.overuse(perm, func(pos int, _, _ float64) { out[pos] = true })
+}
@@ -0,0 +1,42 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestKeyMemoryPenalizesOverplayedKeys(t *testing.T) {
+	// Sixteen tracks: even indexes in 8A, odd ones each in a key of their own.
+	var tracks []track.Track
+	for i := range 8 {
+		tracks = append(tracks, track.Track{Title: "home", BPM: 124, Energy: 50, Key: mustKey("8A")})
+		tracks = append(tracks, track.Track{Title: "away", BPM: 124, Energy: 50, Key: track.Key{Number: []int{1, 2, 3, 4, 5, 6, 7, 9}[i], Mode: track.ModeB}})
+	}
+	b := bindKeyMemory(tracks, 1, 0)
+
+	// Alternating, 8A never runs over its half of the set.
+	spread := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10, 11, 12, 13, 14, 15}
+	// Six 8A tracks open the set; returning to 8A straight after the warm-up costs...
+	dwell := []int{0, 2, 4, 6, 8, 10, 1, 3, 12, 14, 5, 7, 9, 11, 13, 15}
+	// ...while the other keys catch up first, and 8A comes back once they have, is free.
+	catchUp := []int{0, 2, 4, 6, 8, 10, 1, 3, 5, 7, 9, 11, 13, 15, 12, 14}
+	if c := b.cost(spread); c != 0 {
+		t.Errorf("spread cost = %v, want 0", c)
+	}
+	if c := b.cost(catchUp); c != 0 {
+		t.Errorf("catch-up cost = %v, want 0", c)
+	}
+	if c := b.cost(dwell); c <= 0 {
+		t.Errorf("dwell cost = %v, want a penalty", c)
+	}
+	conflicts := make([]bool, len(dwell))
+	b.conflicts(dwell, conflicts)
+	if !conflicts[keyMemoryWarmup] || !conflicts[keyMemoryWarmup+1] || conflicts[keyMemoryWarmup+2] {
+		t.Errorf("conflicts = %v, want just the two 8A tracks after the warm-up", conflicts)
+	}
+	if got := len(bindRules(WithKeyMemory(context.Background(), 0), tracks)); got != 0 {
+		t.Errorf("weight 0 bound %d rules, want none", got)
+	}
+}
@@ -0,0 +1,153 @@
+package strategy
+
+import (
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// LibraryDiff is what changed between two snapshots of a library, track by track, and
+// what that did to its harmonic shape: how well it covers the wheel, where its tempos
+// leave gaps, and how many partners its tracks have.
+type LibraryDiff struct {
+	Added, Removed []track.Track
+	Changed        []TrackChange
+	Before, After  LibraryShape
+}
+
+// TrackChange is a track in both snapshots whose tags differ.
+type TrackChange struct {
+	Before, After track.Track
+	Fields        []FieldChange
+}
+
+// FieldChange is one tag of a changed track, as each snapshot has it ("" for none).
+type FieldChange struct {
+	Field  string `json:"field"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// LibraryShape is how sortable a library is: its tracks per key (round the wheel,
+// 1A, 1B, 2A, …, all 24), the tempo gaps no pitch fader bridges, and how many tracks
+// have no partner at all (see CountPartners), which force a jump wherever they play.
+type LibraryShape struct {
+	Tracks       int      `json:"tracks"`
+	Keys         []int    `json:"-"`
+	KeysCovered  int      `json:"keys_covered"`
+	BPMGaps      []BPMGap `json:"bpm_gaps"`
+	Partnerless  int      `json:"partnerless"`
+	MeanPartners float64  `json:"mean_partners"`
+}
+
+// BPMGap is a stretch of tempo the library has no tracks in: From and To are the
+// neighboring tempos on either side, more than DefaultPartnerWindow apart.
+type BPMGap struct {
+	From float64 `json:"from"`
+	To   float64 `json:"to"`
+}
+
+// WheelKeys are the 24 keys in the order LibraryShape.Keys counts them.
+func WheelKeys() []track.Key {
+	keys := make([]track.Key, 0, 24)
+	for n := 1; n <= 12; n++ {
+		keys = append(keys, track.Key{Number: n, Mode: track.ModeA}, track.Key{Number: n, Mode: track.ModeB})
+	}
+	return keys
+}
+
+// DiffLibraries compares two snapshots of a library. Tracks are matched by title and
+// artist (track.RecordingKey), so a retagged track shows as changed rather than as
+// removed and added; among tracks sharing a title and artist, they pair in order.
+func DiffLibraries(before, after []track.Track) LibraryDiff {
+	d := LibraryDiff{Before: Shape(before), After: Shape(after)}
+	pending := map[string][]int{}
+	for i, t := range before {
+		k := track.RecordingKey(t.Title, t.Artist)
+		pending[k] = append(pending[k], i)
+	}
+	matched := make([]bool, len(before))
+	for _, t := range after {
+		k := track.RecordingKey(t.Title, t.Artist)
+		if len(pending[k]) == 0 {
+			d.Added = append(d.Added, t)
+			continue
+		}
+		i := pending[k][0]
+		pending[k] = pending[k][1:]
+		matched[i] = true
+		if fields := changedFields(before[i], t); len(fields) > 0 {
+			d.Changed = append(d.Changed, TrackChange{Before: before[i], After: t, Fields: fields})
+		}
+	}
+	for i, t := range before {
+		if !matched[i] {
+			d.Removed = append(d.Removed, t)
+		}
+	}
+	return d
+}
+
+// changedFields lists the tags that differ between two snapshots of one track.
+func changedFields(a, b track.Track) []FieldChange {
+	optInt := func(p *int) string {
+		if p == nil {
+			return ""
+		}
+		return strconv.Itoa(*p)
+	}
+	pairs := []FieldChange{
+		{Field: "bpm", Before: a.TempoString(), After: b.TempoString()},
+		{Field: "key", Before: a.KeyString(), After: b.KeyString()},
+		{Field: "energy", Before: strconv.Itoa(a.Energy), After: strconv.Itoa(b.Energy)},
+		{Field: "genre", Before: a.Genre, After: b.Genre},
+		{Field: "length", Before: optInt(a.Duration), After: optInt(b.Duration)},
+		{Field: "year", Before: optInt(a.Year), After: optInt(b.Year)},
+		{Field: "priority", Before: optInt(a.Priority), After: optInt(b.Priority)},
+		{Field: "color", Before: a.Color, After: b.Color},
+		{Field: "tags", Before: strings.Join(a.Tags, "; "), After: strings.Join(b.Tags, "; ")},
+	}
+	return slices.DeleteFunc(pairs, func(f FieldChange) bool { return f.Before == f.After })
+}
+
+// Shape measures how sortable tracks are as a library.
+func Shape(tracks []track.Track) LibraryShape {
+	s := LibraryShape{Tracks: len(tracks), Keys: make([]int, 24)}
+	for _, t := range tracks {
+		if k := t.EntryKey(); k.Number > 0 {
+			s.Keys[2*(k.Number-1)+modeIndex(k.Mode)]++
+		}
+	}
+	for _, n := range s.Keys {
+		if n > 0 {
+			s.KeysCovered++
+		}
+	}
+
+	var tempos []float64
+	for _, t := range tracks {
+		if t.BPM > 0 {
+			tempos = append(tempos, t.BPM)
+		}
+	}
+	slices.Sort(tempos)
+	for i := 1; i < len(tempos); i++ {
+		if (tempos[i]/tempos[i-1]-1)*100 > DefaultPartnerWindow {
+			s.BPMGaps = append(s.BPMGaps, BPMGap{From: tempos[i-1], To: tempos[i]})
+		}
+	}
+
+	if len(tracks) > 0 {
+		total := 0
+		for _, p := range CountPartners(tracks, DefaultPartnerWindow) {
+			total += p.Either
+			if p.Either == 0 {
+				s.Partnerless++
+			}
+		}
+		s.MeanPartners = float64(total) / float64(len(tracks))
+	}
+	return s
+}
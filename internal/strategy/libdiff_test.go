@@ -0,0 +1,38 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestDiffLibrariesMatchesByRecording(t *testing.T) {
+	before := []track.Track{
+		{Title: "a", Artist: "x", BPM: 124, Energy: 50, Key: mustKey("8A")},
+		{Title: "b", Artist: "x", BPM: 125, Energy: 60, Key: mustKey("9A")},
+		{Title: "c", Artist: "x", BPM: 123, Energy: 55, Key: mustKey("8B")},
+	}
+	after := []track.Track{
+		{Title: "A", Artist: "X", BPM: 124, Energy: 50, Key: mustKey("8A")},
+		{Title: "b", Artist: "x", BPM: 125, Energy: 70, Key: mustKey("7A")},
+		{Title: "d", Artist: "y", BPM: 150, Energy: 80, Key: mustKey("3B")},
+	}
+	d := DiffLibraries(before, after)
+	if len(d.Added) != 1 || d.Added[0].Title != "d" || len(d.Removed) != 1 || d.Removed[0].Title != "c" {
+		t.Fatalf("added %v, removed %v; want d added and c removed", d.Added, d.Removed)
+	}
+	if len(d.Changed) != 1 || len(d.Changed[0].Fields) != 2 ||
+		d.Changed[0].Fields[0] != (FieldChange{Field: "key", Before: "9A", After: "7A"}) ||
+		d.Changed[0].Fields[1] != (FieldChange{Field: "energy", Before: "60", After: "70"}) {
+		t.Fatalf("changed = %+v, want b's key and energy", d.Changed)
+	}
+	if d.Before.KeysCovered != 3 || d.After.KeysCovered != 3 || d.After.Keys[2*(3-1)+1] != 1 {
+		t.Errorf("keys covered %d -> %d, 3B count %d", d.Before.KeysCovered, d.After.KeysCovered, d.After.Keys[5])
+	}
+	if len(d.Before.BPMGaps) != 0 || len(d.After.BPMGaps) != 1 || d.After.BPMGaps[0] != (BPMGap{From: 125, To: 150}) {
+		t.Errorf("BPM gaps %v -> %v, want none -> 125-150", d.Before.BPMGaps, d.After.BPMGaps)
+	}
+	if d.Before.Partnerless != 0 || d.After.Partnerless != 1 {
+		t.Errorf("partnerless %d -> %d, want 0 -> 1", d.Before.Partnerless, d.After.Partnerless)
+	}
+}
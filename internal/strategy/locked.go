@@ -0,0 +1,88 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// lockUnit is the penalty for each pair of locked tracks played out of their order,
+// as heavy as a pin's so the backbone wins any tug of war with a softer rule.
+const lockUnit = 10.0
+
+const lockedContextKey contextKey = "strategy.locked"
+
+// WithLockedOrder keeps the backbone tracks in the order given wherever they land in
+// the set, however many other tracks are played between them: a hand-built run of
+// tracks the strategy fills in around rather than reorders. Tracks are matched to
+// the backbone by track.ID. Like a pin it is enforced: Sort repairs any strategy's
+// output that breaks it, and flow and anneal optimize it.
+func WithLockedOrder(ctx context.Context, backbone []track.Track) context.Context {
+	if len(backbone) < 2 {
+		return ctx
+	}
+	return context.WithValue(ctx, lockedContextKey, backbone)
+}
+
+func lockedOrderFromContext(ctx context.Context) []track.Track {
+	if ctx == nil {
+		return nil
+	}
+	backbone, _ := ctx.Value(lockedContextKey).([]track.Track)
+	return backbone
+}
+
+// boundLock is a locked order resolved against one track list: each track's place in
+// the backbone, or -1 for a track that isn't locked.
+type boundLock struct {
+	rank   []int
+	locked int
+}
+
+func bindLock(tracks []track.Track, backbone []track.Track) *boundLock {
+	ranks := make(map[string]int, len(backbone))
+	for i, t := range backbone {
+		if _, ok := ranks[t.ID()]; !ok {
+			ranks[t.ID()] = i
+		}
+	}
+	b := &boundLock{rank: make([]int, len(tracks))}
+	for i, t := range tracks {
+		r, ok := ranks[t.ID()]
+		if !ok {
+			r = -1
+		} else {
+			b.locked++
+		}
+		b.rank[i] = r
+	}
+	return b
+}
+
+// inversions calls visit for each pair of positions of perm holding locked tracks in
+// the wrong order.
+func (b *boundLock) inversions(perm []int, visit func(i, j int)) {
+	if b.locked < 2 {
+		return
+	}
+	for i, a := range perm {
+		if b.rank[a] < 0 {
+			continue
+		}
+		for j := i + 1; j < len(perm); j++ {
+			if r := b.rank[perm[j]]; r >= 0 && r < b.rank[a] {
+				visit(i, j)
+			}
+		}
+	}
+}
+
+func (b *boundLock) cost(perm []int) float64 {
+	pairs := 0
+	b.inversions(perm, func(int, int) { pairs++ })
+	return lockUnit * float64(pairs)
+}
+
+func (b *boundLock) conflicts(perm []int, out []bool) {
+	b.inversions(perm, func(i, j int) { out[i], out[j] = true, true })
+}
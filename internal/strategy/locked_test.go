@@ -0,0 +1,46 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestLockedOrderKeepsTheBackbone(t *testing.T) {
+	tracks := chaveTracks(24)
+	// A backbone that runs against the grain of the mix: every sixth track, backwards.
+	var backbone []track.Track
+	for i := len(tracks) - 1; i >= 0; i -= 6 {
+		backbone = append(backbone, tracks[i])
+	}
+	for _, name := range []string{flowStrategyName, defaultStrategyName, rotationStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(WithLockedOrder(WithSeed(context.Background(), 2), backbone), sorter, tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Ordered) != len(tracks) {
+			t.Fatalf("%s: got %d tracks, want %d", name, len(res.Ordered), len(tracks))
+		}
+		var got []string
+		for _, tr := range res.Ordered {
+			for _, b := range backbone {
+				if tr.ID() == b.ID() {
+					got = append(got, tr.Title)
+				}
+			}
+		}
+		for i, b := range backbone {
+			if got[i] != b.Title {
+				t.Errorf("%s: locked tracks play as %v, want the backbone's order", name, got)
+				break
+			}
+		}
+	}
+
+	b := bindLock(tracks, backbone)
+	if c := b.cost(identity(len(tracks))); c != lockUnit*6 {
+		t.Errorf("four locked tracks fully reversed cost %.0f, want six inversions' worth", c)
+	}
+}
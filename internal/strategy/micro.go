@@ -0,0 +1,118 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// MicroLimit is the most tracks Sort orders by trying every order instead of running
+// the strategy: at 5 that is 120 orders, fewer than a planner spends getting started,
+// and the exhaustive answer is the best one the score allows.
+const MicroLimit = 5
+
+// micro reports whether Sort should order n tracks exhaustively: a handful, none left
+// out by a limit, and no decision log or explanations asked of the strategy itself.
+func micro(ctx context.Context, n int) bool {
+	if n < 2 || n > MicroLimit {
+		return false
+	}
+	if limit := limitFromContext(ctx); limit > 0 && limit < n {
+		return false
+	}
+	return decisionRecorderFromContext(ctx) == nil && !explainFromContext(ctx)
+}
+
+// microSort tries every order of a handful of tracks and keeps the one that breaks the
+// enforced rules least, then scores best on the mix score plus the soft rules'
+// penalties; ties go to the order found first, so the result is deterministic. It
+// returns advisory notes saying what the tracks allow: whether any order avoids a key
+// clash, and whether any keeps every tempo change within a pitch fader's reach.
+func microSort(tracks []track.Track, rules []orderRule) ([]track.Track, []string) {
+	hard, soft := splitRules(rules)
+	n := len(tracks)
+	seq := make([]track.Track, n)
+	var best []int
+	bestPen, bestObj := math.Inf(1), math.Inf(1)
+	fewestClashes, leastStretch := n, math.Inf(1)
+	orders := 0
+	permute(n, func(perm []int) {
+		orders++
+		for k, idx := range perm {
+			seq[k] = tracks[idx]
+		}
+		clashes, stretch, _ := roughestSteps(seq)
+		fewestClashes = min(fewestClashes, clashes)
+		leastStretch = min(leastStretch, stretch)
+		pen := rulesCost(hard, perm)
+		obj := mixTotal(seq, DefaultWeights) + rulesCost(soft, perm)
+		if pen < bestPen-improvementEps || (pen <= bestPen+improvementEps && obj < bestObj-improvementEps) {
+			bestPen, bestObj = pen, obj
+			best = append(best[:0], perm...)
+		}
+	})
+
+	out := make([]track.Track, n)
+	for k, idx := range best {
+		out[k] = tracks[idx]
+	}
+	notes := []string{fmt.Sprintf("Only %d tracks: tried all %d orders and kept the smoothest", n, orders)}
+	clashes, stretch, widest := roughestSteps(out)
+	switch {
+	case fewestClashes > 0:
+		notes = append(notes, fmt.Sprintf(
+			"No harmonically valid order exists for these %d tracks; best available shown (%d key clash(es))", n, clashes))
+	case clashes > 0:
+		notes = append(notes, fmt.Sprintf(
+			"An order without key clashes exists, but it mixes worse on tempo or mood; the one shown has %d key clash(es)", clashes))
+	}
+	if leastStretch > DefaultPartnerWindow {
+		notes = append(notes, fmt.Sprintf(
+			"No order keeps every tempo change within %.0f%%; the one shown needs a %.1f%% stretch from %q into %q",
+			DefaultPartnerWindow, stretch, out[widest].Title, out[widest+1].Title))
+	}
+	return out, notes
+}
+
+// roughestSteps counts the key clashes in an ordering, transitions outside the Camelot
+// rules (track.Key.Compatible), and finds its widest tempo stretch in percent and the
+// position it leaves from.
+func roughestSteps(tracks []track.Track) (clashes int, stretch float64, at int) {
+	for i := 0; i+1 < len(tracks); i++ {
+		trans := NewTransition(tracks[i], tracks[i+1])
+		if !trans.AnyKey && !trans.FromKey.Compatible(trans.ToKey) {
+			clashes++
+		}
+		if s := tempoStretch(tracks[i].ExitBPM(), tracks[i+1].EntryBPM()); s > stretch {
+			stretch, at = s, i
+		}
+	}
+	return clashes, stretch, at
+}
+
+// permute calls visit with every permutation of 0..n-1, in lexicographic order. visit
+// must not keep perm.
+func permute(n int, visit func(perm []int)) {
+	perm := make([]int, 0, n)
+	used := make([]bool, n)
+	var walk func()
+	walk = func() {
+		if len(perm) == n {
+			visit(perm)
+			return
+		}
+		for i := range n {
+			if used[i] {
+				continue
+			}
+			used[i] = true
+			perm = append(perm, i)
+			walk()
+			perm = perm[:len(perm)-1]
+			used[i] = false
+		}
+	}
+	walk()
+}
@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSortOrdersTinyListsExhaustively(t *testing.T) {
+	tracks := []track.Track{
+		{Title: "c", BPM: 124, Energy: 70, Key: mustKey("10A")},
+		{Title: "a", BPM: 122, Energy: 50, Key: mustKey("8A")},
+		{Title: "b", BPM: 123, Energy: 60, Key: mustKey("9A")},
+		{Title: "d", BPM: 124, Energy: 75, Key: mustKey("10B")},
+	}
+	sorter, _ := Get(defaultStrategyName)
+	res, err := Sort(WithSeed(context.Background(), 1), sorter, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	best := mixTotal(res.Ordered, DefaultWeights)
+	permute(len(tracks), func(perm []int) {
+		seq := make([]track.Track, len(perm))
+		for k, idx := range perm {
+			seq[k] = tracks[idx]
+		}
+		if mixTotal(seq, DefaultWeights) < best-improvementEps {
+			t.Errorf("order %v scores %.3f, better than the %.3f kept", perm, mixTotal(seq, DefaultWeights), best)
+		}
+	})
+	notes := strings.Join(res.Notes, "\n")
+	if !strings.Contains(notes, "tried all 24 orders") || strings.Contains(notes, "No harmonically valid") {
+		t.Errorf("notes = %q", res.Notes)
+	}
+
+	clashing := []track.Track{
+		{Title: "x", BPM: 90, Energy: 50, Key: mustKey("1A")},
+		{Title: "y", BPM: 128, Energy: 60, Key: mustKey("5B")},
+		{Title: "z", BPM: 128, Energy: 70, Key: mustKey("9A")},
+	}
+	res, err = Sort(context.Background(), sorter, clashing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	notes = strings.Join(res.Notes, "\n")
+	if !strings.Contains(notes, "No harmonically valid order exists for these 3 tracks") ||
+		!strings.Contains(notes, "No order keeps every tempo change within 6%") {
+		t.Errorf("notes = %q", res.Notes)
+	}
+
+	// A limit that leaves tracks out is the strategy's call.
+	res, err = Sort(WithLimit(context.Background(), 2), sorter, clashing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(strings.Join(res.Notes, "\n"), "tried all") {
+		t.Errorf("a limited sort should run the strategy, notes %q", res.Notes)
+	}
+}
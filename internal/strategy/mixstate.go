@@ -0,0 +1,139 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// MixState is the default planner's state machine — where the set is in its energy
+// cycle, how long since each kind of key move, and the pool still to play — for a
+// scheduler that keeps the order itself, such as a venue's automation system, and
+// asks only for scoring and suggestions. Tell it each track that plays with Advance;
+// Score, Category, and Suggest then answer as the default strategy would at that
+// point of a set. A MixState is not safe for concurrent use.
+type MixState struct {
+	planner *mixPlanner
+	state   mixState
+	played  int
+}
+
+// NewMixState starts a set drawn from pool, planning as the default strategy would
+// with the tuning, tempo match, arc, excursions, tie-break, and strict Camelot setting
+// in ctx; a limit in ctx sets the length of the set, or else it plays the whole pool.
+// Pins and must-include tracks are the scheduler's own business and are ignored.
+// pool is not modified.
+func NewMixState(ctx context.Context, pool []track.Track) *MixState {
+	n := len(pool)
+	if limit := limitFromContext(ctx); limit > 0 && limit < n {
+		n = limit
+	}
+	ctx = context.WithValue(ctx, pinContextKey, []Pin(nil))
+	ctx = context.WithValue(ctx, mustIncludeContextKey, []MustInclude(nil))
+	p := newMixPlanner(ctx, pool, n)
+	p.recorder = nil
+	m := &MixState{planner: p, state: p.initialState(track.Track{})}
+	m.state.prevSet = false
+	return m
+}
+
+// Advance records that t played next. t leaves the pool if it is in it; a track from
+// outside the pool, such as one the DJ chose, moves the state all the same.
+func (m *MixState) Advance(t track.Track) {
+	if i := findTrack(m.planner.remaining, t); i >= 0 {
+		m.planner.take(i)
+	}
+	if m.played == 0 {
+		m.state = m.planner.initialState(t)
+	} else {
+		m.state.advance(t)
+	}
+	m.played++
+}
+
+// Played counts the tracks Advance has recorded.
+func (m *MixState) Played() int { return m.played }
+
+// Pool returns the tracks still to play, in no particular order.
+func (m *MixState) Pool() []track.Track {
+	return slices.Clone(m.planner.remaining)
+}
+
+// Transition is the move from the last track played into candidate; zero before the
+// first track.
+func (m *MixState) Transition(candidate track.Track) Transition {
+	return computeTransition(&m.state, candidate)
+}
+
+// Category names the planner's category for playing candidate next: "step+1",
+// "step+2", "same-number", "mode-flip", or "other". The planner takes the best track
+// of the first category in CategoryOrder that has one, whatever the scores of the
+// rest.
+func (m *MixState) Category(candidate track.Track) string {
+	return categoryNames[categorizeTransition(&m.state, m.Transition(candidate))]
+}
+
+// CategoryOrder is the order the planner tries the categories in for the next track,
+// which shifts as the set goes without one kind of move for long.
+func (m *MixState) CategoryOrder() []string {
+	var out []string
+	for _, c := range categoryOrder(&m.state) {
+		out = append(out, categoryNames[c])
+	}
+	return out
+}
+
+// Score is the planner's score, broken down, for playing candidate next; lower is
+// better. candidate need not be in the pool.
+func (m *MixState) Score(candidate track.Track) DecisionCandidate {
+	trans := m.Transition(candidate)
+	c := scoredCandidate{
+		category: categorizeTransition(&m.state, trans),
+		trans:    trans,
+		score:    m.planner.candidateScore(&m.state, candidate, trans, -1),
+	}
+	return m.planner.describe(&m.state, candidate, c)
+}
+
+// Suggest returns up to n tracks of the pool (all of them when n <= 0), most
+// preferred first, as the planner ranks them: by category in CategoryOrder, then by
+// score, with ties settled by the tie-break policy. Its first pick is the planner's
+// unless a coin flip would have settled a tie. Before the first track it ranks the
+// pool as openers: near the library's calmer energies and middle tempo.
+func (m *MixState) Suggest(n int) []DecisionCandidate {
+	p := m.planner
+	rank := make(map[int]int, len(categoryNames))
+	for i, c := range categoryOrder(&m.state) {
+		rank[c] = i
+	}
+	scored := make([]scoredCandidate, len(p.remaining))
+	for i, t := range p.remaining {
+		trans := m.Transition(t)
+		scored[i] = scoredCandidate{idx: i, category: categorizeTransition(&m.state, trans), trans: trans,
+			score: p.candidateScore(&m.state, t, trans, -1)}
+	}
+	slices.SortStableFunc(scored, func(a, b scoredCandidate) int {
+		if c := cmp.Compare(rank[a.category], rank[b.category]); c != 0 {
+			return c
+		}
+		if !closeFloat(a.score, b.score) {
+			return cmp.Compare(a.score, b.score)
+		}
+		return p.tieBreak.prefer(p.remaining[a.idx], p.remaining[b.idx])
+	})
+	if n > 0 && n < len(scored) {
+		scored = scored[:n]
+	}
+	out := make([]DecisionCandidate, len(scored))
+	for i, c := range scored {
+		out[i] = p.describe(&m.state, p.remaining[c.idx], c)
+	}
+	return out
+}
+
+// Snapshot is the state the next track is chosen from, as a decision log records it.
+func (m *MixState) Snapshot() DecisionState {
+	return m.planner.snapshot(&m.state)
+}
@@ -0,0 +1,54 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"testing"
+)
+
+func TestMixStateFollowsThePlanner(t *testing.T) {
+	tracks := chaveTracks(30)
+	ctx := WithSeed(context.Background(), 7)
+	ordered, err := NewDefaultSorter().Sort(ctx, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A scheduler replaying the planner's own set gets the planner's next pick as its
+	// top suggestion at every step.
+	m := NewMixState(ctx, tracks)
+	m.Advance(ordered[0])
+	for k := 1; k < len(ordered); k++ {
+		top := m.Suggest(1)
+		if len(top) != 1 || top[0].Title != ordered[k].Title {
+			t.Fatalf("#%d: suggested %+v, the planner played %q", k+1, top, ordered[k].Title)
+		}
+		if got := m.Score(ordered[k]); got.Total != top[0].Total || got.Category != m.Category(ordered[k]) {
+			t.Errorf("#%d: Score = %+v, Suggest = %+v", k+1, got, top[0])
+		}
+		m.Advance(ordered[k])
+	}
+	if m.Played() != len(ordered) || len(m.Pool()) != 0 || m.Snapshot().Prev != ordered[len(ordered)-1].Title {
+		t.Errorf("after the set: played %d, pool %d, state %+v", m.Played(), len(m.Pool()), m.Snapshot())
+	}
+}
+
+func TestMixStateOffPool(t *testing.T) {
+	tracks := chaveTracks(12)
+	m := NewMixState(context.Background(), tracks[:10])
+	if got := m.Suggest(0); len(got) != 10 {
+		t.Fatalf("Suggest(0) gave %d openers, want the whole pool", len(got))
+	}
+
+	// A track from outside the pool moves the state but leaves the pool whole.
+	m.Advance(tracks[11])
+	if m.Played() != 1 || len(m.Pool()) != 10 || m.Snapshot().Prev != tracks[11].Title {
+		t.Errorf("after an off-pool track: played %d, pool %d, state %+v", m.Played(), len(m.Pool()), m.Snapshot())
+	}
+	if !slices.Contains(m.CategoryOrder(), m.Category(tracks[0])) {
+		t.Errorf("category %q is not in order %v", m.Category(tracks[0]), m.CategoryOrder())
+	}
+	if tr := m.Transition(tracks[0]); tr.FromKey != tracks[11].Key || tr.ToKey != tracks[0].Key {
+		t.Errorf("Transition = %+v, want %s to %s", tr, tracks[11].Key, tracks[0].Key)
+	}
+}
@@ -23,7 +23,10 @@ const outlierMinGain = 1.0
 // stand out as statistical outliers (Tukey upper fence) above an absolute floor. If
 // the collection is coherent, nothing is dropped. Kept tracks are returned in their
 // original order; callers typically re-optimize them for a clean final sequence.
-func TrimOutliers(ordered []track.Track, maxFraction float64) ([]track.Track, []DroppedTrack) {
+// Tracks keep matches (such as Required's) are never dropped; keep may be nil. A
+// track's Priority scales its roughness for this purpose, so filler goes before a
+// favorite that fits as badly (see priorityScale).
+func TrimOutliers(ordered []track.Track, maxFraction float64, keep func(track.Track) bool) ([]track.Track, []DroppedTrack) {
 	n := len(ordered)
 	maxDrop := int(float64(n) * maxFraction)
 	if n < 5 || maxDrop < 1 {
@@ -49,8 +52,9 @@ func TrimOutliers(ordered []track.Track, maxFraction float64) ([]track.Track, []
 		gain float64
 	}
 	var candidates []candidate
-	for i, g := range gains {
-		if g > fence && g > outlierMinGain {
+	for i := range gains {
+		g := gains[i] * priorityScale(ordered[i])
+		if g > fence && g > outlierMinGain && (keep == nil || !keep(ordered[i])) {
 			candidates = append(candidates, candidate{i, g})
 		}
 	}
@@ -66,19 +70,19 @@ func TrimOutliers(ordered []track.Track, maxFraction float64) ([]track.Track, []
 
 	drop := make(map[int]float64, len(candidates))
 	for _, c := range candidates {
-		drop[c.idx] = c.gain
+		drop[c.idx] = gains[c.idx]
 	}
 
-	keep := make([]track.Track, 0, n-len(drop))
+	kept := make([]track.Track, 0, n-len(drop))
 	dropped := make([]DroppedTrack, 0, len(drop))
 	for i, t := range ordered {
 		if g, ok := drop[i]; ok {
 			dropped = append(dropped, DroppedTrack{Track: t, MarginalCost: g})
 			continue
 		}
-		keep = append(keep, t)
+		kept = append(kept, t)
 	}
-	return keep, dropped
+	return kept, dropped
 }
 
 // tukeyUpperFence returns Q3 + 1.5*IQR, the classic threshold above which a value is
@@ -91,3 +95,12 @@ func tukeyUpperFence(vals []float64) float64 {
 	q3 := quantileFloats(sorted, 0.75)
 	return q3 + 1.5*(q3-q1)
 }
+
+// priorityScale weighs a track's roughness by its Priority when trimming: 1.5 for
+// filler (1), down to 0.5 for a favorite (5); 1 when it has none.
+func priorityScale(t track.Track) float64 {
+	if t.Priority == nil {
+		return 1
+	}
+	return 1 + float64(3-*t.Priority)*0.25
+}
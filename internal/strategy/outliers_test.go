@@ -33,7 +33,7 @@ func coherentSequence() []track.Track {
 
 func TestTrimOutliersKeepsCoherentSet(t *testing.T) {
 	seq := coherentSequence()
-	keep, dropped := TrimOutliers(seq, 0.10)
+	keep, dropped := TrimOutliers(seq, 0.10, nil)
 	if len(dropped) != 0 {
 		t.Fatalf("expected no drops from a coherent set, dropped %d", len(dropped))
 	}
@@ -51,7 +51,7 @@ func TestTrimOutliersDropsMisfit(t *testing.T) {
 	withMisfit = append(withMisfit, misfit)
 	withMisfit = append(withMisfit, seq[7:]...)
 
-	keep, dropped := TrimOutliers(withMisfit, 0.10)
+	keep, dropped := TrimOutliers(withMisfit, 0.10, nil)
 	if len(dropped) == 0 {
 		t.Fatal("expected the misfit to be dropped")
 	}
@@ -65,6 +65,11 @@ func TestTrimOutliersDropsMisfit(t *testing.T) {
 			t.Fatal("misfit should not remain in kept set")
 		}
 	}
+
+	isMisfit := func(tr track.Track) bool { return tr.Title == "MISFIT" }
+	if _, dropped := TrimOutliers(withMisfit, 0.10, isMisfit); len(dropped) != 0 {
+		t.Fatalf("a kept track must never be dropped, dropped %v", dropped)
+	}
 }
 
 func TestTrimOutliersRespectsFractionCap(t *testing.T) {
@@ -72,7 +77,7 @@ func TestTrimOutliersRespectsFractionCap(t *testing.T) {
 	seq := coherentSequence()
 	seq[3] = mkTrack("BAD1", 70, 10, "6B")
 	seq[9] = mkTrack("BAD2", 200, 95, "7B")
-	_, dropped := TrimOutliers(seq, 0.10)
+	_, dropped := TrimOutliers(seq, 0.10, nil)
 	if len(dropped) > 1 {
 		t.Fatalf("10%% of 15 tracks caps drops at 1, got %d", len(dropped))
 	}
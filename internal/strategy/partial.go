@@ -0,0 +1,53 @@
+package strategy
+
+import (
+	"fmt"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// PartialError is returned by a sorter that was stopped (usually by the context's
+// deadline) after placing some tracks. Placed is the ordering so far; Sort turns it into
+// a partial Result rather than failing.
+type PartialError struct {
+	Placed []track.Track
+	Err    error
+}
+
+func (e *PartialError) Error() string {
+	return fmt.Sprintf("stopped after placing %d track(s): %v", len(e.Placed), e.Err)
+}
+
+func (e *PartialError) Unwrap() error { return e.Err }
+
+// partial wraps a cancellation with the tracks placed before it.
+func partial(placed []track.Track, err error) error {
+	return &PartialError{Placed: placed, Err: err}
+}
+
+// unplaced returns the tracks not in placed, in their original order. Tracks are
+// matched by title, artist, tempo, key, and energy, counting duplicates.
+func unplaced(tracks, placed []track.Track) []track.Track {
+	type id struct {
+		title, artist string
+		bpm           float64
+		key           string
+		energy        int
+	}
+	idOf := func(t track.Track) id {
+		return id{t.Title, t.Artist, t.BPM, t.KeyString(), t.Energy}
+	}
+	seen := make(map[id]int, len(placed))
+	for _, t := range placed {
+		seen[idOf(t)]++
+	}
+	var rest []track.Track
+	for _, t := range tracks {
+		if k := idOf(t); seen[k] > 0 {
+			seen[k]--
+			continue
+		}
+		rest = append(rest, t)
+	}
+	return rest
+}
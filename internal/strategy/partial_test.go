@@ -0,0 +1,50 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSortReturnsPartialResultWhenStopped(t *testing.T) {
+	var tracks []track.Track
+	for i := range 6 {
+		tracks = append(tracks, track.Track{
+			Title:  string(rune('a' + i)),
+			BPM:    120 + float64(i),
+			Energy: 50 + i,
+			Key:    track.Key{Number: i + 1, Mode: track.ModeA},
+		})
+	}
+	ctx, cancel := context.WithCancel(WithSeed(context.Background(), 1))
+	cancel()
+
+	for _, name := range []string{defaultStrategyName, flowStrategyName, chaveStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: Sort returned error: %v", name, err)
+		}
+		if !res.Partial || len(res.Warnings) == 0 {
+			t.Fatalf("%s: stopped sort not reported as partial: %+v", name, res)
+		}
+		if got := len(res.Ordered) + len(res.Unplaced); got != len(tracks) {
+			t.Fatalf("%s: %d placed + %d unplaced, want %d", name, len(res.Ordered), len(res.Unplaced), len(tracks))
+		}
+		for i := 1; i < len(res.Unplaced); i++ {
+			if res.Unplaced[i-1].Title > res.Unplaced[i].Title {
+				t.Fatalf("%s: unplaced tracks not in input order: %v", name, res.Unplaced)
+			}
+		}
+	}
+}
+
+func TestUnplacedCountsDuplicates(t *testing.T) {
+	a := track.Track{Title: "a", BPM: 120}
+	b := track.Track{Title: "b", BPM: 120}
+	rest := unplaced([]track.Track{a, b, a}, []track.Track{a})
+	if len(rest) != 2 || rest[0].Title != "b" || rest[1].Title != "a" {
+		t.Fatalf("unplaced = %v, want [b a]", rest)
+	}
+}
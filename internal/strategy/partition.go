@@ -0,0 +1,185 @@
+package strategy
+
+import (
+	"cmp"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// partitionRounds caps how many times Partition reassigns tracks and re-picks each
+// group's center; it usually settles in a few.
+const partitionRounds = 12
+
+// Partition splits a library into n groups of about equal size that each hang together:
+// tracks go with the ones they mix into most cheaply (the coherence cost the sorters
+// minimize, taken both ways) and sit nearest in energy, so each group can be ordered
+// into a set of its own. Groups are balanced by track count, or by playtime when
+// byLength is set (tracks with no length count as the average). Every track lands in
+// exactly one group, in input order within it; the groups come back from the calmest
+// to the most energetic. Partition is deterministic: the same library always splits
+// the same way.
+func Partition(tracks []track.Track, n int, byLength bool) [][]track.Track {
+	if n <= 1 || len(tracks) <= 1 {
+		return [][]track.Track{slices.Clone(tracks)}
+	}
+	n = min(n, len(tracks))
+
+	dist := partitionDistances(tracks)
+	weight := make([]float64, len(tracks))
+	for i := range weight {
+		weight[i] = 1
+	}
+	if byLength {
+		weight = trackSeconds(tracks)
+	}
+	total := 0.0
+	for _, w := range weight {
+		total += w
+	}
+	// A group may run over its share by up to half a track, so shares that don't
+	// divide evenly still fill.
+	capacity := total/float64(n) + slices.Max(weight)/2
+
+	centers := spreadCenters(dist, n)
+	var group []int
+	for range partitionRounds {
+		group = assignGroups(dist, weight, centers, capacity)
+		next := groupCenters(dist, group, n)
+		if slices.Equal(next, centers) {
+			break
+		}
+		centers = next
+	}
+
+	groups := make([][]track.Track, n)
+	for i, g := range group {
+		groups[g] = append(groups[g], tracks[i])
+	}
+	groups = slices.DeleteFunc(groups, func(g []track.Track) bool { return len(g) == 0 })
+	slices.SortStableFunc(groups, func(a, b []track.Track) int { return cmp.Compare(meanEnergy(a), meanEnergy(b)) })
+	return groups
+}
+
+// partitionDistances is how poorly each pair of tracks belongs together: the mean of
+// their coherence costs in each direction plus their energy gap, on the scale of the
+// other mood terms.
+func partitionDistances(tracks []track.Track) [][]float64 {
+	costs := PairwiseCosts(tracks, DefaultWeights)
+	dist := make([][]float64, len(tracks))
+	for i := range tracks {
+		dist[i] = make([]float64, len(tracks))
+		for j := range tracks {
+			if i != j {
+				gap := math.Abs(float64(tracks[i].Energy - tracks[j].Energy))
+				dist[i][j] = (costs[i][j]+costs[j][i])/2 + math.Min(1, gap/40)
+			}
+		}
+	}
+	return dist
+}
+
+// spreadCenters picks n tracks far apart to start the groups from: the most outlying
+// track, then each time the one farthest from every center so far.
+func spreadCenters(dist [][]float64, n int) []int {
+	first, farthest := 0, -1.0
+	for i, row := range dist {
+		if sum := floatSum(row); sum > farthest {
+			first, farthest = i, sum
+		}
+	}
+	centers := []int{first}
+	for len(centers) < n {
+		next, best := -1, -1.0
+		for i := range dist {
+			if slices.Contains(centers, i) {
+				continue
+			}
+			nearest := math.Inf(1)
+			for _, c := range centers {
+				nearest = math.Min(nearest, dist[i][c])
+			}
+			if nearest > best {
+				next, best = i, nearest
+			}
+		}
+		centers = append(centers, next)
+	}
+	return centers
+}
+
+// assignGroups puts each track in the group of its nearest center that has room. The
+// tracks with the most to lose from a second choice go first; a track no group has
+// room for joins the emptiest.
+func assignGroups(dist [][]float64, weight []float64, centers []int, capacity float64) []int {
+	regret := make([]float64, len(dist))
+	for i := range dist {
+		near := make([]float64, len(centers))
+		for g, c := range centers {
+			near[g] = dist[i][c]
+		}
+		slices.Sort(near)
+		regret[i] = near[1] - near[0]
+	}
+	order := identity(len(dist))
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(regret[b], regret[a]) })
+
+	group := make([]int, len(dist))
+	load := make([]float64, len(centers))
+	for _, i := range order {
+		best, emptiest := -1, 0
+		for g, c := range centers {
+			if load[g] < load[emptiest] {
+				emptiest = g
+			}
+			if load[g]+weight[i] <= capacity && (best < 0 || dist[i][c] < dist[i][centers[best]]) {
+				best = g
+			}
+		}
+		if best < 0 {
+			best = emptiest
+		}
+		group[i] = best
+		load[best] += weight[i]
+	}
+	return group
+}
+
+// groupCenters picks each group's most central member: the one nearest, in total, to
+// the rest of its group.
+func groupCenters(dist [][]float64, group []int, n int) []int {
+	centers := make([]int, n)
+	best := make([]float64, n)
+	for g := range best {
+		best[g] = math.Inf(1)
+	}
+	for i, g := range group {
+		sum := 0.0
+		for j, h := range group {
+			if h == g {
+				sum += dist[i][j]
+			}
+		}
+		if sum < best[g] {
+			centers[g], best[g] = i, sum
+		}
+	}
+	return centers
+}
+
+func floatSum(values []float64) float64 {
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	return sum
+}
+
+func meanEnergy(tracks []track.Track) float64 {
+	sum := 0
+	for _, t := range tracks {
+		sum += t.Energy
+	}
+	return float64(sum) / float64(len(tracks))
+}
@@ -0,0 +1,76 @@
+package strategy
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestPartitionPlacesEveryTrackOnceInBalancedGroups(t *testing.T) {
+	tracks := chaveTracks(60)
+	groups := Partition(tracks, 3, false)
+	if len(groups) != 3 {
+		t.Fatalf("got %d groups, want 3", len(groups))
+	}
+	seen := map[string]int{}
+	for _, g := range groups {
+		if len(g) < 18 || len(g) > 22 {
+			t.Errorf("group of %d tracks, want about 20", len(g))
+		}
+		for _, tr := range g {
+			seen[tr.Title]++
+		}
+	}
+	if len(seen) != len(tracks) {
+		t.Fatalf("groups cover %d distinct tracks, want %d", len(seen), len(tracks))
+	}
+	for title, n := range seen {
+		if n != 1 {
+			t.Fatalf("%s placed in %d groups, want 1", title, n)
+		}
+	}
+	for i := 1; i < len(groups); i++ {
+		if meanEnergy(groups[i]) < meanEnergy(groups[i-1]) {
+			t.Errorf("group %d is calmer than group %d", i+1, i)
+		}
+	}
+}
+
+func TestPartitionKeepsLikeTracksTogether(t *testing.T) {
+	// Two clear clusters: slow, calm tracks around 2A and fast, driving ones around 8B.
+	var tracks []track.Track
+	for i := range 8 {
+		tracks = append(tracks,
+			track.Track{Title: fmt.Sprintf("calm%d", i), BPM: 90 + float64(i), Energy: 30 + i, Key: track.Key{Number: 2 + i%2, Mode: track.ModeA}},
+			track.Track{Title: fmt.Sprintf("drive%d", i), BPM: 128 + float64(i), Energy: 80 + i, Key: track.Key{Number: 8 + i%2, Mode: track.ModeB}})
+	}
+	groups := Partition(tracks, 2, false)
+	if len(groups) != 2 {
+		t.Fatalf("got %d groups, want 2", len(groups))
+	}
+	for g, prefix := range []string{"calm", "drive"} {
+		for _, tr := range groups[g] {
+			if tr.Title[:len(prefix)] != prefix {
+				t.Errorf("group %d (%s) holds %s", g+1, prefix, tr.Title)
+			}
+		}
+	}
+}
+
+func TestPartitionBalancesPlaytime(t *testing.T) {
+	tracks := chaveTracks(40)
+	for i := range tracks {
+		length := 180 + 240*(i%2) // alternating 3- and 7-minute tracks
+		tracks[i].Duration = &length
+	}
+	for _, g := range Partition(tracks, 2, true) {
+		seconds := 0
+		for _, tr := range g {
+			seconds += *tr.Duration
+		}
+		if seconds < 5400 || seconds > 6600 { // 6000 s each, give or take a long track
+			t.Errorf("group of %d tracks plays %d s, want about 6000", len(g), seconds)
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package strategy
+
+import (
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultPartnerWindow is the tempo gap, in percent, within which two tracks count as
+// partners: about what a pitch fader covers without the mix sounding stretched.
+const DefaultPartnerWindow = 6.0
+
+// Partners is how well connected one track is in its library: In counts the tracks
+// that mix into it (their exit key fits its entry key, their tempo within the
+// window), Out the tracks it mixes into, and Either the tracks that do at least one.
+// A track short of partners forces a key or tempo jump on whichever side it lacks
+// them, unless it opens or closes the set.
+type Partners struct {
+	In, Out, Either int
+}
+
+// Fewest is the smaller of In and Out: the side of the track that is harder to mix.
+func (p Partners) Fewest() int { return min(p.In, p.Out) }
+
+// CountPartners counts each track's partners among tracks, index-aligned with them.
+// Keys fit by the Camelot rules (track.Key.Compatible), and a key-agnostic track fits
+// any key; tempos fit when one is within window percent of the other, half and double
+// time included, as the score folds them.
+func CountPartners(tracks []track.Track, window float64) []Partners {
+	out := make([]Partners, len(tracks))
+	for i, a := range tracks {
+		for j, b := range tracks {
+			if i == j {
+				continue
+			}
+			into, from := mixes(b, a, window), mixes(a, b, window)
+			if into {
+				out[i].In++
+			}
+			if from {
+				out[i].Out++
+			}
+			if into || from {
+				out[i].Either++
+			}
+		}
+	}
+	return out
+}
+
+// mixes reports whether b can follow a within the key rules and the tempo window.
+func mixes(a, b track.Track, window float64) bool {
+	trans := NewTransition(a, b)
+	if !trans.AnyKey && !trans.FromKey.Compatible(trans.ToKey) {
+		return false
+	}
+	return tempoStretch(a.ExitBPM(), b.EntryBPM()) <= window
+}
@@ -0,0 +1,34 @@
+package strategy
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestCountPartners(t *testing.T) {
+	tracks := []track.Track{
+		{Title: "a", BPM: 124, Key: mustKey("8A")},
+		{Title: "b", BPM: 126, Key: mustKey("9A")},
+		{Title: "c", BPM: 62, Key: mustKey("8B")},  // half time, relative key
+		{Title: "d", BPM: 140, Key: mustKey("9A")}, // tempo too far
+		{Title: "e", BPM: 124, Key: mustKey("2B")}, // clashes with every other key
+		{Title: "sweep", BPM: 124, Key: mustKey("3A"), Tags: []string{track.AnyKeyTag}},
+		{Title: "mod", BPM: 124, Key: mustKey("2A"), Modulations: []track.Key{mustKey("8A")}},
+	}
+	got := CountPartners(tracks, DefaultPartnerWindow)
+	want := []Partners{
+		{In: 4, Out: 3, Either: 4}, // a: b, c, and sweep either way; mod only into it
+		{In: 3, Out: 2, Either: 3},
+		{In: 3, Out: 2, Either: 3},
+		{In: 0, Out: 0, Either: 0},
+		{In: 1, Out: 2, Either: 2}, // e: only sweep, and the 2A that mod opens in
+		{In: 5, Out: 5, Either: 5},
+		{In: 2, Out: 4, Either: 5}, // mod: into its 2A, out of its 8A
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("%s: got %+v, want %+v", tracks[i].Title, got[i], want[i])
+		}
+	}
+}
@@ -0,0 +1,237 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Pin tuning: a pinned track away from its position costs pinUnit plus pinSlope per
+// set-fraction of distance, well above placement so a pin wins any tug of war.
+const (
+	pinUnit  = 10.0
+	pinSlope = 20.0
+)
+
+// Pin fixes a track at a position of the set — the opener, the closer, or a track
+// that has to land at a given spot — or, with Earliest and Latest, within a range of
+// positions, such as no earlier than 20th or within the first 10. A pinned track is
+// also a must-include track.
+type Pin struct {
+	Name     string // the pin as written, for reporting
+	Match    func(track.Track) bool
+	Position int // 1-based from the start; negative counts from the end (-1 is last)
+	// Earliest and Latest, when Position is 0, are the first and last positions the
+	// track may take, counted as Position is; 0 leaves that end open. A ranged pin
+	// holds no position, so other pins and tracks may share its range.
+	Earliest, Latest int
+}
+
+// ranged reports whether the pin is a range of positions rather than one.
+func (p Pin) ranged() bool {
+	return p.Position == 0 && (p.Earliest != 0 || p.Latest != 0)
+}
+
+// span resolves the positions the pin allows into 0-based indexes of an n-track set.
+func (p Pin) span(n int) (lo, hi int) {
+	if !p.ranged() {
+		at := resolvePosition(p.Position, n)
+		return at, at
+	}
+	lo, hi = 0, n-1
+	if p.Earliest != 0 {
+		lo = resolvePosition(p.Earliest, n)
+	}
+	if p.Latest != 0 {
+		hi = resolvePosition(p.Latest, n)
+	}
+	return lo, max(lo, hi)
+}
+
+// MustInclude names tracks a limit may not drop.
+type MustInclude struct {
+	Name  string
+	Match func(track.Track) bool
+}
+
+const (
+	pinContextKey         contextKey = "strategy.pins"
+	mustIncludeContextKey contextKey = "strategy.mustInclude"
+)
+
+// WithPins adds pins to the context. Sort moves pinned tracks into place on any
+// strategy's output; the default strategy plans around them, and flow optimizes them.
+// When two pins claim the same position, the first wins.
+func WithPins(ctx context.Context, pins ...Pin) context.Context {
+	if len(pins) == 0 {
+		return ctx
+	}
+	all := append(append([]Pin(nil), pinsFromContext(ctx)...), pins...)
+	return context.WithValue(ctx, pinContextKey, all)
+}
+
+func pinsFromContext(ctx context.Context) []Pin {
+	if ctx == nil {
+		return nil
+	}
+	pins, _ := ctx.Value(pinContextKey).([]Pin)
+	return pins
+}
+
+// WithMustInclude adds tracks that must make the set even when a limit drops songs.
+func WithMustInclude(ctx context.Context, must ...MustInclude) context.Context {
+	if len(must) == 0 {
+		return ctx
+	}
+	all := append(append([]MustInclude(nil), mustIncludeFromContext(ctx)...), must...)
+	return context.WithValue(ctx, mustIncludeContextKey, all)
+}
+
+func mustIncludeFromContext(ctx context.Context) []MustInclude {
+	if ctx == nil {
+		return nil
+	}
+	must, _ := ctx.Value(mustIncludeContextKey).([]MustInclude)
+	return must
+}
+
+// Required returns a matcher for the tracks the context's pins and must-include
+// rules keep in the set, or nil when there are none.
+func Required(ctx context.Context) func(track.Track) bool {
+	pins, must := pinsFromContext(ctx), mustIncludeFromContext(ctx)
+	if len(pins) == 0 && len(must) == 0 {
+		return nil
+	}
+	return func(t track.Track) bool {
+		for _, p := range pins {
+			if p.Match(t) {
+				return true
+			}
+		}
+		for _, m := range must {
+			if m.Match(t) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// Truncate shortens ordered to n tracks, dropping the latest tracks that aren't
+// required (see Required) so must-include tracks and the closer survive, then moves
+// pinned tracks back into place for the shorter set.
+func Truncate(ctx context.Context, ordered []track.Track, n int) []track.Track {
+	if n >= len(ordered) {
+		return ordered
+	}
+	required := Required(ctx)
+	if required == nil {
+		return ordered[:n]
+	}
+	keep := make([]bool, len(ordered))
+	excess := len(ordered) - n
+	for i := len(ordered) - 1; i >= 0; i-- {
+		if excess > 0 && !required(ordered[i]) {
+			excess--
+			continue
+		}
+		keep[i] = true
+	}
+	out := make([]track.Track, 0, n)
+	for i, t := range ordered {
+		if keep[i] && len(out) < n {
+			out = append(out, t)
+		}
+	}
+	if rules := bindRules(ctx, out); len(rules) > 0 {
+		out = enforceRules(out, rules)
+	}
+	return out
+}
+
+// resolvePosition turns a pin's position into a 0-based index of an n-track set,
+// clamped into the set.
+func resolvePosition(pos, n int) int {
+	at := pos - 1
+	if pos < 0 {
+		at = n + pos
+	}
+	return max(0, min(n-1, at))
+}
+
+// boundPins is a set of pins resolved against one track list: which track each pin
+// holds and the positions it may take.
+type boundPins struct {
+	pinned []int // bound track index per pin; -1 when nothing matched
+	lo, hi []int // 0-based positions per pin; equal for a pin to one position
+	n      int
+}
+
+func bindPins(tracks []track.Track, pins []Pin) *boundPins {
+	n := len(tracks)
+	pinned, _ := claimPins(tracks, pins, n)
+	b := &boundPins{pinned: pinned, lo: make([]int, len(pins)), hi: make([]int, len(pins)), n: n}
+	for p, pin := range pins {
+		b.lo[p], b.hi[p] = pin.span(n)
+	}
+	return b
+}
+
+// claimPins gives each pin the first matching track no earlier pin took, and its
+// position in a set of n: -1 for a ranged pin, which holds no position. A pin whose
+// position is taken, or that matches nothing, gets track -1.
+func claimPins(tracks []track.Track, pins []Pin, n int) (pinned, target []int) {
+	pinned, target = make([]int, len(pins)), make([]int, len(pins))
+	claimedTrack := make([]bool, len(tracks))
+	claimedPos := make(map[int]bool, len(pins))
+	for p, pin := range pins {
+		pinned[p] = -1
+		at := -1
+		if !pin.ranged() {
+			at = resolvePosition(pin.Position, n)
+		}
+		if claimedPos[at] {
+			continue
+		}
+		for i, t := range tracks {
+			if !claimedTrack[i] && pin.Match(t) {
+				pinned[p], target[p] = i, at
+				claimedTrack[i] = true
+				if at >= 0 {
+					claimedPos[at] = true
+				}
+				break
+			}
+		}
+	}
+	return pinned, target
+}
+
+// visit calls fn with the position of every pinned track that is out of place and the
+// cost of its distance from the nearest position it may take.
+func (b *boundPins) visit(perm []int, fn func(k int, cost float64)) {
+	for p, idx := range b.pinned {
+		if idx < 0 {
+			continue
+		}
+		for k, v := range perm {
+			if v != idx {
+				continue
+			}
+			if off := max(b.lo[p]-k, k-b.hi[p]); off > 0 {
+				fn(k, pinUnit+pinSlope*float64(off)/float64(b.n))
+			}
+			break
+		}
+	}
+}
+
+func (b *boundPins) cost(perm []int) float64 {
+	total := 0.0
+	b.visit(perm, func(_ int, cost float64) { total += cost })
+	return total
+}
+
+func (b *boundPins) conflicts(perm []int, out []bool) {
+	b.visit(perm, func(k int, _ float64) { out[k] = true })
+}
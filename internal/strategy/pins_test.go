@@ -0,0 +1,124 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func titled(title string) func(track.Track) bool {
+	return func(t track.Track) bool { return t.Title == title }
+}
+
+func TestSortHonorsPins(t *testing.T) {
+	tracks := chaveTracks(30)
+	opener, closer, middle := tracks[17].Title, tracks[4].Title, tracks[22].Title
+	ctx := WithPins(WithSeed(context.Background(), 3),
+		Pin{Name: "open", Match: titled(opener), Position: 1},
+		Pin{Name: "close", Match: titled(closer), Position: -1},
+		Pin{Name: "tenth", Match: titled(middle), Position: 10})
+
+	for _, name := range []string{defaultStrategyName, flowStrategyName, chaveStrategyName, annealStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := res.Ordered
+		if got[0].Title != opener || got[len(got)-1].Title != closer || got[9].Title != middle {
+			t.Errorf("%s: got %s first, %s tenth, %s last; want %s, %s, %s", name,
+				got[0].Title, got[9].Title, got[len(got)-1].Title, opener, middle, closer)
+		}
+	}
+}
+
+func TestSortHonorsPositionRanges(t *testing.T) {
+	tracks := chaveTracks(30)
+	early, late, between := tracks[25].Title, tracks[1].Title, tracks[12].Title
+	ctx := WithPins(WithSeed(context.Background(), 5),
+		Pin{Name: "within the first 5", Match: titled(early), Latest: 5},
+		Pin{Name: "no earlier than 20th", Match: titled(late), Earliest: 20},
+		Pin{Name: "8th to 12th", Match: titled(between), Earliest: 8, Latest: 12},
+		Pin{Name: "open", Match: titled(tracks[3].Title), Position: 1})
+
+	for _, name := range []string{defaultStrategyName, flowStrategyName, chaveStrategyName, annealStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		at := func(title string) int { return slices.IndexFunc(res.Ordered, titled(title)) + 1 }
+		if e, l, b := at(early), at(late), at(between); e < 1 || e > 5 || l < 20 || b < 8 || b > 12 {
+			t.Errorf("%s: got positions %d, %d, %d; want within 1-5, 20 or later, and 8-12", name, e, l, b)
+		}
+		if res.Ordered[0].Title != tracks[3].Title {
+			t.Errorf("%s: opened on %s, want the pinned opener", name, res.Ordered[0].Title)
+		}
+	}
+}
+
+func TestPinSpan(t *testing.T) {
+	for _, c := range []struct {
+		pin    Pin
+		lo, hi int
+	}{
+		{Pin{Position: 3}, 2, 2},
+		{Pin{Position: -1}, 9, 9},
+		{Pin{Earliest: 4}, 3, 9},
+		{Pin{Latest: 5}, 0, 4},
+		{Pin{Earliest: -3}, 7, 9},
+		{Pin{Earliest: 6, Latest: -6}, 5, 5}, // an empty range narrows to its start
+		{Pin{Earliest: 15}, 9, 9},
+	} {
+		if lo, hi := c.pin.span(10); lo != c.lo || hi != c.hi {
+			t.Errorf("%+v.span(10) = %d, %d; want %d, %d", c.pin, lo, hi, c.lo, c.hi)
+		}
+	}
+}
+
+func TestDefaultSorterKeepsRequiredUnderLimit(t *testing.T) {
+	tracks := chaveTracks(30)
+	closer := tracks[0].Title
+	var must []MustInclude
+	for _, i := range []int{5, 11, 29} {
+		must = append(must, MustInclude{Name: tracks[i].Title, Match: titled(tracks[i].Title)})
+	}
+	ctx := WithLimit(WithSeed(context.Background(), 9), 8)
+	ctx = WithMustInclude(WithPins(ctx, Pin{Name: "close", Match: titled(closer), Position: -1}), must...)
+
+	for _, name := range []string{defaultStrategyName, "eloise"} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(res.Ordered) != 8 {
+			t.Fatalf("%s: got %d tracks, want the limit of 8", name, len(res.Ordered))
+		}
+		if last := res.Ordered[len(res.Ordered)-1].Title; last != closer {
+			t.Errorf("%s: closed on %s, want %s", name, last, closer)
+		}
+		for _, m := range must {
+			if !slices.ContainsFunc(res.Ordered, m.Match) {
+				t.Errorf("%s: must-include %s was dropped", name, m.Name)
+			}
+		}
+	}
+}
+
+func TestTruncateKeepsRequired(t *testing.T) {
+	tracks := chaveTracks(12)
+	closer, kept := tracks[11].Title, tracks[9].Title
+	ctx := WithMustInclude(WithPins(context.Background(), Pin{Name: "close", Match: titled(closer), Position: -1}),
+		MustInclude{Name: kept, Match: titled(kept)})
+
+	out := Truncate(ctx, tracks, 5)
+	if len(out) != 5 || out[4].Title != closer || !slices.ContainsFunc(out, titled(kept)) {
+		t.Errorf("Truncate should keep the closer last and the must-include track: %v", titles(out))
+	}
+	if got := Truncate(context.Background(), tracks, 5); titles(got) != titles(tracks[:5]) {
+		t.Errorf("without rules Truncate is a plain cut, got %v", titles(got))
+	}
+}
@@ -0,0 +1,239 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Placement tuning. A matching track outside its window costs placementUnit plus
+// placementSlope per set-fraction of distance to the window, so the optimizer both
+// honors the rule and, when it cannot, lands the track as near the window as it can.
+const (
+	placementUnit  = 3.0
+	placementSlope = 6.0
+
+	avgTrackSeconds = 210.0 // assumed length of a track with no duration
+)
+
+// Placement keeps the tracks a filter matches inside a window of the set — e.g.
+// singalongs only in the last hour, or the heaviest tracks at peak time.
+type Placement struct {
+	Name   string // the rule as written, for reporting
+	Match  func(track.Track) bool
+	Window Window
+}
+
+// Window is a span of a set. Lo and Hi are fractions of the set (0-1) or, when Minutes
+// is set, minutes of playtime; FromEnd measures them back from the end of the set.
+type Window struct {
+	Lo, Hi  float64
+	Minutes bool
+	FromEnd bool
+}
+
+// Named windows accepted by ParseWindow.
+var namedWindows = map[string]Window{
+	"opening": {Lo: 0, Hi: 1.0 / 3},
+	"middle":  {Lo: 1.0 / 3, Hi: 2.0 / 3},
+	"closing": {Lo: 2.0 / 3, Hi: 1},
+	"peak":    {Lo: 0.6, Hi: 0.9},
+}
+
+// ParseWindow reads a window such as "first:20%", "last:60m", "40%-70%", or one of
+// the named windows "opening", "middle", "closing" (thirds of the set) and "peak"
+// (60-90% through the set).
+func ParseWindow(s string) (Window, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if w, ok := namedWindows[s]; ok {
+		return w, nil
+	}
+	if anchor, span, ok := strings.Cut(s, ":"); ok {
+		v, minutes, err := parseSpan(span)
+		if err != nil {
+			return Window{}, fmt.Errorf("window %q: %w", s, err)
+		}
+		switch anchor {
+		case "first":
+			return Window{Lo: 0, Hi: v, Minutes: minutes}, nil
+		case "last":
+			return Window{Lo: 0, Hi: v, Minutes: minutes, FromEnd: true}, nil
+		}
+		return Window{}, fmt.Errorf("window %q: anchor must be first or last", s)
+	}
+	if lo, hi, ok := strings.Cut(s, "-"); ok {
+		l, lm, err := parseSpan(lo)
+		if err != nil {
+			return Window{}, fmt.Errorf("window %q: %w", s, err)
+		}
+		h, hm, err := parseSpan(hi)
+		if err != nil {
+			return Window{}, fmt.Errorf("window %q: %w", s, err)
+		}
+		if lm != hm {
+			return Window{}, fmt.Errorf("window %q: mixes minutes and percent", s)
+		}
+		if h < l {
+			return Window{}, fmt.Errorf("window %q: end before start", s)
+		}
+		return Window{Lo: l, Hi: h, Minutes: lm}, nil
+	}
+	return Window{}, fmt.Errorf("unknown window %q", s)
+}
+
+// parseSpan reads "20%" as 0.2 or "60m" as 60 minutes.
+func parseSpan(s string) (float64, bool, error) {
+	s = strings.TrimSpace(s)
+	switch {
+	case strings.HasSuffix(s, "%"):
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "%"), 64)
+		if err != nil || v < 0 || v > 100 {
+			return 0, false, fmt.Errorf("bad percentage %q", s)
+		}
+		return v / 100, false, nil
+	case strings.HasSuffix(s, "m"):
+		v, err := strconv.ParseFloat(strings.TrimSuffix(s, "m"), 64)
+		if err != nil || v < 0 {
+			return 0, false, fmt.Errorf("bad minutes %q", s)
+		}
+		return v, true, nil
+	}
+	return 0, false, fmt.Errorf("span %q needs a %% or m suffix", s)
+}
+
+// resolve converts the window to set fractions for a set of totalMin minutes.
+func (w Window) resolve(totalMin float64) (lo, hi float64) {
+	lo, hi = w.Lo, w.Hi
+	if w.Minutes {
+		if totalMin <= 0 {
+			return 0, 1
+		}
+		lo, hi = lo/totalMin, hi/totalMin
+	}
+	if w.FromEnd {
+		lo, hi = 1-hi, 1-lo
+	}
+	return math.Max(0, lo), math.Min(1, hi)
+}
+
+const placementContextKey contextKey = "strategy.placement"
+
+// WithPlacement adds placement rules to the context. Sort repairs any ordering that
+// breaks them, and the flow strategy optimizes them directly.
+func WithPlacement(ctx context.Context, rules ...Placement) context.Context {
+	if len(rules) == 0 {
+		return ctx
+	}
+	all := append(append([]Placement(nil), placementFromContext(ctx)...), rules...)
+	return context.WithValue(ctx, placementContextKey, all)
+}
+
+func placementFromContext(ctx context.Context) []Placement {
+	if ctx == nil {
+		return nil
+	}
+	if rules, ok := ctx.Value(placementContextKey).([]Placement); ok {
+		return rules
+	}
+	return nil
+}
+
+// boundPlacement is a set of placement rules resolved against one track list.
+type boundPlacement struct {
+	dur   []float64 // per-track seconds (estimated when unknown)
+	total float64   // set length in seconds
+	lo    []float64 // per rule: window as set fractions
+	hi    []float64
+	match [][]bool // per rule, per track
+	buf   []float64
+}
+
+func bindPlacement(tracks []track.Track, rules []Placement) *boundPlacement {
+	b := &boundPlacement{
+		dur:   trackSeconds(tracks),
+		match: make([][]bool, len(rules)),
+		lo:    make([]float64, len(rules)),
+		hi:    make([]float64, len(rules)),
+		buf:   make([]float64, len(tracks)),
+	}
+	for _, d := range b.dur {
+		b.total += d
+	}
+	for r, rule := range rules {
+		b.lo[r], b.hi[r] = rule.Window.resolve(b.total / 60)
+		b.match[r] = make([]bool, len(tracks))
+		for i, t := range tracks {
+			b.match[r][i] = rule.Match(t)
+		}
+	}
+	return b
+}
+
+// starts fills buf with each position's start as a fraction of the set.
+func (b *boundPlacement) starts(perm []int) []float64 {
+	elapsed := 0.0
+	for k, idx := range perm {
+		b.buf[k] = elapsed / math.Max(b.total, 1)
+		elapsed += b.dur[idx]
+	}
+	return b.buf
+}
+
+func (b *boundPlacement) cost(perm []int) float64 {
+	total := 0.0
+	at := b.starts(perm)
+	for r := range b.match {
+		lo, hi := b.lo[r], b.hi[r]
+		for k, idx := range perm {
+			if !b.match[r][idx] {
+				continue
+			}
+			if f := at[k]; f < lo {
+				total += placementUnit + placementSlope*(lo-f)
+			} else if f > hi {
+				total += placementUnit + placementSlope*(f-hi)
+			}
+		}
+	}
+	return total
+}
+
+func (b *boundPlacement) conflicts(perm []int, out []bool) {
+	at := b.starts(perm)
+	for r := range b.match {
+		for k, idx := range perm {
+			if b.match[r][idx] && (at[k] < b.lo[r] || at[k] > b.hi[r]) {
+				out[k] = true
+			}
+		}
+	}
+}
+
+// trackSeconds returns each track's duration, filling unknown ones with the average
+// of the known durations (or avgTrackSeconds when none are known).
+func trackSeconds(tracks []track.Track) []float64 {
+	out := make([]float64, len(tracks))
+	known, sum := 0, 0.0
+	for _, t := range tracks {
+		if t.Duration != nil && *t.Duration > 0 {
+			known++
+			sum += float64(*t.Duration)
+		}
+	}
+	fill := avgTrackSeconds
+	if known > 0 {
+		fill = sum / float64(known)
+	}
+	for i, t := range tracks {
+		if t.Duration != nil && *t.Duration > 0 {
+			out[i] = float64(*t.Duration)
+		} else {
+			out[i] = fill
+		}
+	}
+	return out
+}
@@ -0,0 +1,62 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestParseWindow(t *testing.T) {
+	tests := []struct {
+		spec     string
+		totalMin float64
+		lo, hi   float64
+	}{
+		{"first:20%", 100, 0, 0.2},
+		{"last:25%", 100, 0.75, 1},
+		{"last:60m", 120, 0.5, 1},
+		{"first:30m", 60, 0, 0.5},
+		{"40%-70%", 100, 0.4, 0.7},
+		{"closing", 100, 2.0 / 3, 1},
+	}
+	for _, tc := range tests {
+		w, err := ParseWindow(tc.spec)
+		if err != nil {
+			t.Fatalf("ParseWindow(%q): %v", tc.spec, err)
+		}
+		lo, hi := w.resolve(tc.totalMin)
+		if !closeFloat(lo, tc.lo) || !closeFloat(hi, tc.hi) {
+			t.Errorf("%q resolved to [%.3f, %.3f], want [%.3f, %.3f]", tc.spec, lo, hi, tc.lo, tc.hi)
+		}
+	}
+	for _, bad := range []string{"soon", "first:20", "middle:10%", "70%-40%", "10%-20m"} {
+		if _, err := ParseWindow(bad); err == nil {
+			t.Errorf("ParseWindow(%q) expected error", bad)
+		}
+	}
+}
+
+func TestSortHonorsPlacement(t *testing.T) {
+	tracks := chaveTracks(30)
+	for i := range 3 {
+		tracks[i*7].Tags = []string{"singalong"}
+	}
+	isSingalong := func(t track.Track) bool { return len(t.Tags) > 0 }
+	ctx := WithPlacement(WithSeed(context.Background(), 5),
+		Placement{Name: "singalong@closing", Match: isSingalong, Window: namedWindows["closing"]})
+
+	for _, name := range []string{flowStrategyName, defaultStrategyName, chaveStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		at := bindPlacement(res.Ordered, nil).starts(identity(len(res.Ordered)))
+		for k, tr := range res.Ordered {
+			if isSingalong(tr) && at[k] < 2.0/3 {
+				t.Fatalf("%s: singalong %s starts %.0f%% into the set", name, tr.Title, at[k]*100)
+			}
+		}
+	}
+}
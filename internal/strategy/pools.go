@@ -0,0 +1,184 @@
+package strategy
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Pool schedule tuning. The openers and the closers each take poolEdge of the set at
+// its ends. The peak is where the arc is within poolPeakBand levels of its top, away
+// from the ends, widened to at least poolPeakSpan of the set.
+const (
+	poolEdge     = 0.1
+	poolPeakSpan = 0.2
+	poolPeakBand = 0.1
+)
+
+// Pool roles.
+const (
+	PoolOpen  = "open"
+	PoolPeak  = "peak"
+	PoolClose = "close"
+)
+
+// PoolRoles lists the pool roles, in set order.
+var PoolRoles = []string{PoolOpen, PoolPeak, PoolClose}
+
+// ParsePoolRole reads a pool role: open (or opener, openers), peak, or close (or
+// closer, closers).
+func ParsePoolRole(s string) (string, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "open", "opener", "openers":
+		return PoolOpen, nil
+	case "peak", "peaks":
+		return PoolPeak, nil
+	case "close", "closer", "closers":
+		return PoolClose, nil
+	}
+	return "", fmt.Errorf("unknown pool %q (want %s)", s, strings.Join(PoolRoles, ", "))
+}
+
+// Pool declares the tracks for one part of the set: the openers, the peak, or the
+// closers, as the tracks a filter matches.
+type Pool struct {
+	Name  string // the filter as written, for reporting
+	Role  string // PoolOpen, PoolPeak, or PoolClose
+	Match func(track.Track) bool
+}
+
+// PoolPlan is a pool sized for one set: the window it fills, how many tracks that
+// takes (Need), how many the library offers (Have), and the ones chosen to play
+// there, as indexes into the tracks PlanPools was given.
+type PoolPlan struct {
+	Pool
+	Window Window
+	Need   int
+	Have   int
+	Chosen []int
+}
+
+// PoolWindow is the span of the set a pool role fills under arc (nil for no arc,
+// whose peak is the named "peak" window, 60-90% through the set). The openers and the
+// closers take the first and the last 10%.
+func PoolWindow(role string, arc *Arc) Window {
+	switch role {
+	case PoolOpen:
+		return Window{Lo: 0, Hi: poolEdge}
+	case PoolClose:
+		return Window{Lo: 1 - poolEdge, Hi: 1}
+	}
+	if arc == nil {
+		w := namedWindows["peak"]
+		w.Hi = math.Min(w.Hi, 1-poolEdge)
+		return w
+	}
+	const steps = 100
+	lo, hi := int(poolEdge*steps), int((1-poolEdge)*steps)
+	top := lo
+	for p := lo; p <= hi; p++ {
+		if arc.Level(float64(p)/steps) >= arc.Level(float64(top)/steps) {
+			top = p
+		}
+	}
+	band := arc.Level(float64(top)/steps) - poolPeakBand
+	from, to := top, top
+	for from > lo && arc.Level(float64(from-1)/steps) >= band {
+		from--
+	}
+	for to < hi && arc.Level(float64(to+1)/steps) >= band {
+		to++
+	}
+	for to-from < int(poolPeakSpan*steps) {
+		if from > lo {
+			from--
+		}
+		if to < hi {
+			to++
+		}
+	}
+	return Window{Lo: float64(from) / steps, Hi: float64(to) / steps}
+}
+
+// PlanPools sizes pools for a set of setTracks tracks drawn from tracks, under arc
+// (nil for none): each pool needs as many tracks as its window holds, at least one.
+// It chooses that many of each pool's matches (the calmest openers, the most
+// energetic peak tracks, the closers as listed), each track for one pool only. The
+// error lists every pool with too few matches to fill its window.
+func PlanPools(pools []Pool, tracks []track.Track, setTracks int, arc *Arc) ([]PoolPlan, error) {
+	plans := make([]PoolPlan, len(pools))
+	spare := make([]int, len(pools))
+	for p, pool := range pools {
+		w := PoolWindow(pool.Role, arc)
+		plans[p] = PoolPlan{Pool: pool, Window: w, Need: max(1, int(math.Round((w.Hi-w.Lo)*float64(setTracks))))}
+		for _, t := range tracks {
+			if pool.Match(t) {
+				spare[p]++
+			}
+		}
+		spare[p] -= plans[p].Need
+	}
+	// The tightest pools choose first, the ends before the peak on a tie, so a track
+	// two pools match goes where it is missed most.
+	order := make([]int, len(pools))
+	for p := range order {
+		order[p] = p
+	}
+	rank := func(role string) int { return slices.Index([]string{PoolOpen, PoolClose, PoolPeak}, role) }
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Or(cmp.Compare(spare[a], spare[b]), cmp.Compare(rank(pools[a].Role), rank(pools[b].Role)))
+	})
+
+	taken := make([]bool, len(tracks))
+	for _, p := range order {
+		pool, plan := pools[p], plans[p]
+		var matches []int
+		for i, t := range tracks {
+			if !taken[i] && pool.Match(t) {
+				matches = append(matches, i)
+			}
+		}
+		plan.Have = len(matches)
+		switch pool.Role {
+		case PoolOpen:
+			slices.SortStableFunc(matches, func(a, b int) int { return cmp.Compare(tracks[a].Energy, tracks[b].Energy) })
+		case PoolPeak:
+			slices.SortStableFunc(matches, func(a, b int) int { return cmp.Compare(tracks[b].Energy, tracks[a].Energy) })
+		}
+		plan.Chosen = matches[:min(plan.Need, len(matches))]
+		for _, i := range plan.Chosen {
+			taken[i] = true
+		}
+		plans[p] = plan
+	}
+	var errs []error
+	for _, plan := range plans {
+		if plan.Have < plan.Need {
+			errs = append(errs, fmt.Errorf("%s pool %q: %d track(s) match, the set needs %d for %.0f-%.0f%% of its %d tracks",
+				plan.Role, plan.Name, plan.Have, plan.Need, 100*plan.Window.Lo, 100*plan.Window.Hi, setTracks))
+		}
+	}
+	return plans, errors.Join(errs...)
+}
+
+// Rules returns the plan as ordering rules: each chosen track must make the set and
+// play inside the pool's window.
+func (p PoolPlan) Rules(tracks []track.Track) ([]MustInclude, Placement) {
+	ids := make(map[string]bool, len(p.Chosen))
+	for _, i := range p.Chosen {
+		ids[tracks[i].ID()] = true
+	}
+	match := func(t track.Track) bool { return ids[t.ID()] }
+	name := fmt.Sprintf("%s pool %s", p.Role, p.Name)
+	var must []MustInclude
+	for _, i := range p.Chosen {
+		id := tracks[i].ID()
+		must = append(must, MustInclude{Name: name, Match: func(t track.Track) bool { return t.ID() == id }})
+	}
+	return must, Placement{Name: name, Match: match, Window: p.Window}
+}
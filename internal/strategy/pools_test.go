@@ -0,0 +1,70 @@
+package strategy
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestPoolWindow(t *testing.T) {
+	if w := PoolWindow(PoolOpen, nil); w.Lo != 0 || w.Hi != poolEdge {
+		t.Errorf("open window = %+v", w)
+	}
+	if w := PoolWindow(PoolPeak, nil); w.Lo != 0.6 || w.Hi != 0.9 {
+		t.Errorf("peak window without an arc = %+v, want 60-90%%", w)
+	}
+	build, _ := ParseArc("build")
+	if w := PoolWindow(PoolPeak, &build); w.Hi != 1-poolEdge || w.Hi-w.Lo < poolPeakSpan-1e-9 {
+		t.Errorf("peak window of a build = %+v, want the late set before the closers", w)
+	}
+	closing, _ := ParseArc("closing")
+	if w := PoolWindow(PoolPeak, &closing); w.Lo != poolEdge || w.Hi-w.Lo < poolPeakSpan-1e-9 {
+		t.Errorf("peak window of a closing arc = %+v, want the early set after the openers", w)
+	}
+}
+
+func TestPlanPools(t *testing.T) {
+	var tracks []track.Track
+	for i := range 30 {
+		tag := "filler"
+		switch {
+		case i < 4:
+			tag = "opener"
+		case i >= 26:
+			tag = "closer"
+		}
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("t%d", i), Artist: "A", Energy: i * 3, Genre: tag})
+	}
+	is := func(genre string) func(track.Track) bool { return func(t track.Track) bool { return t.Genre == genre } }
+	pools := []Pool{
+		{Name: "opener", Role: PoolOpen, Match: is("opener")},
+		{Name: "energy>=45", Role: PoolPeak, Match: func(t track.Track) bool { return t.Energy >= 45 }},
+		{Name: "closer", Role: PoolClose, Match: is("closer")},
+	}
+	plans, err := PlanPools(pools, tracks, 30, nil)
+	if err != nil {
+		t.Fatalf("PlanPools: %v", err)
+	}
+	if p := plans[0]; p.Need != 3 || p.Have != 4 || p.Chosen[0] != 0 {
+		t.Errorf("open plan = %+v; want the 3 calmest of 4 openers", p)
+	}
+	// The closers are the tighter pool, so they keep theirs though the peak matches them too.
+	if p := plans[2]; len(p.Chosen) != 3 || p.Chosen[0] != 26 {
+		t.Errorf("close plan = %+v; want the first 3 closers", p)
+	}
+	if p := plans[1]; p.Need != 9 || p.Chosen[0] != 29 || p.Chosen[1] != 25 {
+		t.Errorf("peak plan = %+v; want 9 tracks, the most energetic left first", p)
+	}
+	short := append(pools, Pool{Name: "encore", Role: PoolClose, Match: is("encore")})
+	if _, err := PlanPools(short, tracks, 30, nil); err == nil ||
+		!strings.Contains(err.Error(), `close pool "encore": 0 track(s) match, the set needs 3`) {
+		t.Errorf("PlanPools error = %v; want the encore pool short", err)
+	}
+
+	must, rule := plans[0].Rules(tracks)
+	if len(must) != 3 || !rule.Match(tracks[0]) || rule.Match(tracks[3]) {
+		t.Errorf("Rules = %d must-includes, %v; want the 3 chosen openers only", len(must), rule.Match(tracks[3]))
+	}
+}
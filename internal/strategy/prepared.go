@@ -0,0 +1,115 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Preparedness tuning. A track with preparedCues or more cues counts as fully
+// prepared; one with none at the opener, the closer, or across the peak costs
+// preparedUnit, about a rough transition, so the search swaps in a better-known track
+// there when the mix allows it but doesn't wreck a transition for it.
+const (
+	preparedCues   = 4
+	preparedUnit   = 3.0
+	preparedWindow = 3
+)
+
+// Readiness is how well prepared t looks, 0 to 1, going by the cues the DJ has set on
+// it: none is 0 and preparedCues or more is 1. A track whose cues are unknown counts
+// as ready, so a library without cue data is left alone.
+func Readiness(t track.Track) float64 {
+	if t.Cues == nil {
+		return 1
+	}
+	return min(1, float64(*t.Cues)/preparedCues)
+}
+
+const preparedContextKey contextKey = "strategy.prepared"
+
+// WithPrepared prefers well-prepared tracks (see Readiness) where the set leans on
+// them most: the opener, the closer, and the peak, the preparedWindow tracks of the
+// set's highest running energy. Weight scales the preference against the mix score;
+// 0 or less leaves ctx unchanged. An unprepared track in those spots is a soft rule's
+// cost (see weighSoftRules), paid where the mix is better for it. Under a limit the
+// set's own closer and peak count, not the ordering's.
+func WithPrepared(ctx context.Context, weight float64) context.Context {
+	if weight <= 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, preparedContextKey, weight)
+}
+
+func preparedFromContext(ctx context.Context) float64 {
+	if ctx == nil {
+		return 0
+	}
+	w, _ := ctx.Value(preparedContextKey).(float64)
+	return w
+}
+
+// boundPrepared is WithPrepared resolved against one track list: how far each track
+// falls short of ready, and the span of the ordering that makes the set.
+type boundPrepared struct {
+	unready []float64
+	vals    []float64
+	span    int
+	weight  float64
+}
+
+func bindPrepared(tracks []track.Track, weight float64, limit int) *boundPrepared {
+	b := &boundPrepared{unready: make([]float64, len(tracks)), vals: intensities(tracks), span: len(tracks), weight: weight}
+	for i, t := range tracks {
+		b.unready[i] = 1 - Readiness(t)
+	}
+	if limit > 0 && limit < b.span {
+		b.span = limit
+	}
+	return b
+}
+
+// keyPositions are the positions of perm the rule weighs, each with its share of a
+// full penalty: the opener and closer whole, the peak's tracks a share each.
+func (b *boundPrepared) keyPositions(perm []int, visit func(pos int, share float64)) {
+	n := min(b.span, len(perm))
+	if n == 0 {
+		return
+	}
+	visit(0, 1)
+	if n > 1 {
+		visit(n-1, 1)
+	}
+	levels := runningLevels(b.vals, perm[:n], preparedWindow)
+	if len(levels) == 0 {
+		return
+	}
+	peak := 0
+	for i, l := range levels {
+		if l > levels[peak] {
+			peak = i
+		}
+	}
+	for k := range preparedWindow {
+		visit(peak+k, 1.0/preparedWindow)
+	}
+}
+
+func (b *boundPrepared) cost(perm []int) float64 {
+	total := 0.0
+	b.keyPositions(perm, func(pos int, share float64) {
+		total += share * b.unready[perm[pos]]
+	})
+	return preparedUnit * b.weight * total
+}
+
+func (*boundPrepared) soft() {}
+
+// conflicts marks the key positions held by a track that isn't fully prepared.
+func (b *boundPrepared) conflicts(perm []int, out []bool) {
+	b.keyPositions(perm, func(pos int, _ float64) {
+		if b.unready[perm[pos]] > 0 {
+			out[pos] = true
+		}
+	})
+}
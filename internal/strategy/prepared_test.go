@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestPreparedTracksHoldKeyPositions(t *testing.T) {
+	none, some, many := 0, 2, 8
+	for _, c := range []struct {
+		cues *int
+		want float64
+	}{{nil, 1}, {&none, 0}, {&some, 0.5}, {&many, 1}} {
+		if got := Readiness(track.Track{Cues: c.cues}); got != c.want {
+			t.Errorf("Readiness(%v) = %v, want %v", c.cues, got, c.want)
+		}
+	}
+
+	// Every other track has no cues; the rest are well prepared.
+	tracks := chaveTracks(30)
+	for i := range tracks {
+		cues := 6 * (i % 2)
+		tracks[i].Cues = &cues
+	}
+	penalty := func(ordered []track.Track) float64 {
+		return bindPrepared(ordered, 1, 0).cost(identity(len(ordered)))
+	}
+	sorter, _ := Get(flowStrategyName)
+	base, err := Sort(WithSeed(context.Background(), 3), sorter, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Sort(WithPrepared(WithSeed(context.Background(), 3), 2), sorter, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Ordered) != len(tracks) {
+		t.Fatalf("got %d tracks, want %d", len(res.Ordered), len(tracks))
+	}
+	if penalty(res.Ordered) >= penalty(base.Ordered) {
+		t.Errorf("unprepared key positions cost %.2f with the rule, %.2f without; want fewer", penalty(res.Ordered), penalty(base.Ordered))
+	}
+	for _, pos := range []int{0, len(res.Ordered) - 1} {
+		if Readiness(res.Ordered[pos]) < 1 {
+			t.Errorf("position %d holds %s with %d cues, want a prepared track", pos+1, res.Ordered[pos].Title, *res.Ordered[pos].Cues)
+		}
+	}
+
+	// Under a limit the set's own closer counts.
+	b := bindPrepared(tracks, 1, 5)
+	perm := identity(len(tracks))
+	marked := make([]bool, len(tracks))
+	b.conflicts(perm, marked)
+	if !marked[4] || marked[len(tracks)-1] {
+		t.Errorf("with a limit of 5, want the fifth position marked (a track without cues) and not the last")
+	}
+}
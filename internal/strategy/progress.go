@@ -0,0 +1,74 @@
+package strategy
+
+import (
+	"context"
+	"time"
+)
+
+const progressContextKey contextKey = "strategy.progress"
+
+// Progress stages.
+const (
+	StagePlacing   = "placing"   // building the set one track at a time
+	StageSeeding   = "seeding"   // trying greedy orderings from several opening tracks
+	StageImproving = "improving" // local search over a complete ordering
+	StageAnnealing = "annealing" // simulated annealing over a complete ordering
+)
+
+// Progress is a snapshot of a running sort. Placed of Total tracks are in the set so
+// far. Step of Steps counts the stage's own work, such as local-search passes or
+// annealing iterations; Steps is 0 when the stage reports no steps. Score is the best
+// complete ordering's score so far (lower is better), or 0 before there is one.
+// Strategy and Elapsed are filled in by Sort.
+type Progress struct {
+	Strategy      string
+	Stage         string
+	Placed, Total int
+	Step, Steps   int
+	Score         float64
+	Elapsed       time.Duration
+}
+
+// ProgressFunc receives progress reports. It runs on the sorting goroutine, so it
+// should return quickly; to stop a sort early, cancel its context, and the strategy
+// hands back its best ordering so far as a partial result.
+type ProgressFunc func(Progress)
+
+// WithProgress asks strategies to report their progress to fn as they go: the default
+// strategy after every placement, flow and anneal as they seed and improve. The other
+// strategies finish quickly and report nothing.
+func WithProgress(ctx context.Context, fn ProgressFunc) context.Context {
+	if fn == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, progressContextKey, fn)
+}
+
+func progressFromContext(ctx context.Context) ProgressFunc {
+	if ctx == nil {
+		return nil
+	}
+	fn, _ := ctx.Value(progressContextKey).(ProgressFunc)
+	return fn
+}
+
+// report sends p to fn, if there is one.
+func (fn ProgressFunc) report(p Progress) {
+	if fn != nil {
+		fn(p)
+	}
+}
+
+// withSortProgress has the strategy s's reports carry its name and the time since the
+// sort began.
+func withSortProgress(ctx context.Context, s Sorter) context.Context {
+	fn := progressFromContext(ctx)
+	if fn == nil {
+		return ctx
+	}
+	start, name := time.Now(), s.Name()
+	return WithProgress(ctx, func(p Progress) {
+		p.Strategy, p.Elapsed = name, time.Since(start)
+		fn(p)
+	})
+}
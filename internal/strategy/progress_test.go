@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSortReportsProgress(t *testing.T) {
+	tracks := annealTestTracks(30)
+
+	var reports []Progress
+	ctx := WithProgress(WithSeed(context.Background(), 4), func(p Progress) { reports = append(reports, p) })
+	if _, err := Sort(ctx, NewDefaultSorter(), tracks); err != nil {
+		t.Fatal(err)
+	}
+	if len(reports) != len(tracks)-1 {
+		t.Fatalf("default strategy sent %d reports, want one per placement after the opener (%d)", len(reports), len(tracks)-1)
+	}
+	last := reports[len(reports)-1]
+	if last.Strategy != "default" || last.Stage != StagePlacing || last.Placed != len(tracks) || last.Total != len(tracks) {
+		t.Errorf("last report = %+v", last)
+	}
+
+	reports = nil
+	if _, err := Sort(ctx, NewFlowSorter(), tracks); err != nil {
+		t.Fatal(err)
+	}
+	var seeded, improved bool
+	for _, p := range reports {
+		seeded = seeded || p.Stage == StageSeeding
+		improved = improved || (p.Stage == StageImproving && p.Score > 0 && p.Placed == len(tracks))
+	}
+	if !seeded || !improved {
+		t.Errorf("flow should report seeding and scored improvement, got %+v", reports)
+	}
+
+	// Cancelling from the callback stops the sort with what it had.
+	cctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	cctx = WithProgress(WithSeed(cctx, 4), func(p Progress) {
+		if p.Placed >= 10 {
+			cancel()
+		}
+	})
+	res, err := Sort(cctx, NewDefaultSorter(), tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !res.Partial || len(res.Ordered) != 10 {
+		t.Errorf("cancelled sort: partial %v with %d tracks, want partial with 10", res.Partial, len(res.Ordered))
+	}
+}
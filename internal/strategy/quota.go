@@ -0,0 +1,101 @@
+package strategy
+
+import (
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// MeetQuota makes sure at least min tracks of ordered match, swapping in matching
+// tracks from pool that did not make the set. Each swap replaces the non-matching track
+// whose removal most improves the set with the spare match that fits the set best, so
+// the set keeps its size. The swapped-in tracks are appended; callers re-sort the
+// result. It returns the new set and how many tracks were swapped in, which is fewer
+// than needed when pool runs out of matches.
+func MeetQuota(ordered, pool []track.Track, match func(track.Track) bool, min int) ([]track.Track, int) {
+	have := 0
+	for _, t := range ordered {
+		if match(t) {
+			have++
+		}
+	}
+	var spare []track.Track
+	for _, t := range unplaced(pool, ordered) {
+		if match(t) {
+			spare = append(spare, t)
+		}
+	}
+
+	out := append([]track.Track(nil), ordered...)
+	swapped := 0
+	for ; have+swapped < min && len(spare) > 0; swapped++ {
+		drop := worstNonMatch(out, match)
+		if drop < 0 {
+			break
+		}
+		out = append(out[:drop], out[drop+1:]...)
+
+		best, bestCost := 0, math.Inf(1)
+		for i, cand := range spare {
+			if c := insertionCost(out, cand); c < bestCost {
+				best, bestCost = i, c
+			}
+		}
+		out = append(out, spare[best])
+		spare = append(spare[:best], spare[best+1:]...)
+	}
+	return out, swapped
+}
+
+// worstNonMatch returns the index of the non-matching track whose removal lowers the
+// mix score most, or -1 when every track matches.
+func worstNonMatch(ordered []track.Track, match func(track.Track) bool) int {
+	base := mixTotal(ordered, DefaultWeights)
+	worst, bestGain := -1, math.Inf(-1)
+	scratch := make([]track.Track, 0, len(ordered))
+	for i, t := range ordered {
+		if match(t) {
+			continue
+		}
+		scratch = append(append(scratch[:0], ordered[:i]...), ordered[i+1:]...)
+		if gain := base - mixTotal(scratch, DefaultWeights); gain > bestGain {
+			worst, bestGain = i, gain
+		}
+	}
+	return worst
+}
+
+// insertionCost is the cheapest coherence cost of slotting t into ordered: between two
+// neighbors, or at either end.
+func insertionCost(ordered []track.Track, t track.Track) float64 {
+	if len(ordered) == 0 {
+		return 0
+	}
+	w := DefaultWeights
+	best := math.Min(coherenceCost(t, ordered[0], w), coherenceCost(ordered[len(ordered)-1], t, w))
+	for i := 0; i+1 < len(ordered); i++ {
+		c := coherenceCost(ordered[i], t, w) + coherenceCost(t, ordered[i+1], w) - coherenceCost(ordered[i], ordered[i+1], w)
+		best = math.Min(best, c)
+	}
+	return best
+}
+
+// SpreadSeparation is the Separation rule that spaces count matching tracks evenly
+// through a set of n, so they are not bunched together. It asks for at least n/count
+// positions between matches and reports false when that gap is too small to matter.
+func SpreadSeparation(name string, match func(track.Track) bool, count, n int) (Separation, bool) {
+	if count < 2 {
+		return Separation{}, false
+	}
+	gap := n / count
+	if gap < 2 {
+		return Separation{}, false
+	}
+	group := func(t track.Track) string {
+		if match(t) {
+			return name
+		}
+		return ""
+	}
+	return Separation{Name: name, Group: group, MinGap: gap}, true
+}
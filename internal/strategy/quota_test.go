@@ -0,0 +1,46 @@
+package strategy
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestMeetQuotaSwapsInSpareMatches(t *testing.T) {
+	mk := func(title string, num int, tags ...string) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}, Tags: tags}
+	}
+	isNew := func(t track.Track) bool { return slices.Contains(t.Tags, "new") }
+	pool := []track.Track{mk("a", 1), mk("b", 2), mk("odd", 9), mk("c", 3), mk("n1", 4, "new"), mk("n2", 10, "new")}
+	ordered := pool[:4]
+
+	set, swapped := MeetQuota(ordered, pool, isNew, 1)
+	if swapped != 1 || len(set) != len(ordered) {
+		t.Fatalf("MeetQuota swapped %d into %d tracks, want 1 into %d", swapped, len(set), len(ordered))
+	}
+	names := titles(set)
+	if slices.ContainsFunc(set, func(t track.Track) bool { return t.Title == "odd" }) {
+		t.Fatalf("the worst-fitting track should be swapped out: %s", names)
+	}
+	if !slices.ContainsFunc(set, func(t track.Track) bool { return t.Title == "n1" }) {
+		t.Fatalf("the better-fitting new track should be swapped in: %s", names)
+	}
+
+	if _, swapped := MeetQuota(ordered, pool, isNew, 5); swapped != 2 {
+		t.Fatalf("with too few new tracks, swapped %d, want all 2", swapped)
+	}
+	if _, swapped := MeetQuota(set, pool, isNew, 1); swapped != 0 {
+		t.Fatalf("a set meeting its quota was changed")
+	}
+}
+
+func TestSpreadSeparation(t *testing.T) {
+	isNew := func(track.Track) bool { return true }
+	if rule, ok := SpreadSeparation("new", isNew, 4, 20); !ok || rule.MinGap != 5 {
+		t.Fatalf("SpreadSeparation(4 in 20) = %+v, %v; want gap 5", rule, ok)
+	}
+	if _, ok := SpreadSeparation("new", isNew, 12, 20); ok {
+		t.Fatal("a gap under 2 should not produce a rule")
+	}
+}
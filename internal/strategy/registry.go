@@ -3,41 +3,175 @@ package strategy
 import (
 	"fmt"
 	"sort"
+	"sync"
 )
 
 // Factory constructs a new sorter instance.
 type Factory func() Sorter
 
-var (
-	factories = map[string]Factory{
-		defaultStrategyName:   func() Sorter { return NewDefaultSorter() },
-		eloiseStrategyName:    func() Sorter { return NewEloiseSorter() },
-		constanceStrategyName: func() Sorter { return NewConstanceSorter() },
-		flowStrategyName:      func() Sorter { return NewFlowSorter() },
-		chaveStrategyName:     func() Sorter { return NewChaveSorter() },
-	}
-)
+// Info describes a registered strategy for help output and tooling.
+type Info struct {
+	Name        string
+	Description string
+	// Quality and Speed are rough hints for choosing a strategy, e.g. "best"/"fair" and
+	// "fast"/"slow".
+	Quality string
+	Speed   string
+}
+
+type registration struct {
+	info    Info
+	factory Factory
+}
+
+// Registry maps strategy names to their factories. The package-level functions
+// (Register, Get, Names, …) use one shared registry; an embedder that wants its own,
+// so that two parts of a program can register the same name differently, or give a
+// strategy a different configuration through its factory, makes one with NewRegistry.
+// A Registry is safe for concurrent use.
+type Registry struct {
+	mu  sync.RWMutex
+	reg map[string]registration
+}
+
+// NewRegistry returns a registry holding the built-in strategies and nothing else:
+// strategies registered with the package-level Register are not in it, and ones
+// registered in it are not visible to Get.
+func NewRegistry() *Registry {
+	r := &Registry{reg: map[string]registration{}}
+	registerBuiltins(r)
+	return r
+}
 
-// Register adds or replaces a sorter factory in the registry.
+var registry = NewRegistry()
+
+// registerBuiltins adds magicmix's own strategies to r.
+func registerBuiltins(r *Registry) {
+	r.RegisterInfo(Info{
+		Name:        flowStrategyName,
+		Description: "path optimization that minimizes the exact score --score reports",
+		Quality:     "best",
+		Speed:       "moderate",
+	}, func() Sorter { return NewFlowSorter() })
+	r.RegisterInfo(Info{
+		Name:        annealStrategyName,
+		Description: "flow's objective searched by simulated annealing; slower, escapes greedy corners on long sets",
+		Quality:     "best",
+		Speed:       "slow",
+	}, func() Sorter { return NewAnnealSorter() })
+	r.RegisterInfo(Info{
+		Name:        chaveStrategyName,
+		Description: "themed ~20-30 minute chapters that each build in intensity",
+		Quality:     "good",
+		Speed:       "moderate",
+	}, func() Sorter { return NewChaveSorter() })
+	r.RegisterInfo(Info{
+		Name:        defaultStrategyName,
+		Description: "greedy planner balancing key steps, BPM, and energy cycles (earlier heuristic)",
+		Quality:     "fair",
+		Speed:       "fast",
+	}, func() Sorter { return NewDefaultSorter() })
+	r.RegisterInfo(Info{
+		Name:        rotationStrategyName,
+		Description: "steady +1 laps around the Camelot wheel, each key's share in proportion to the library",
+		Quality:     "fair",
+		Speed:       "fast",
+	}, func() Sorter { return NewRotationSorter() })
+	r.RegisterInfo(Info{
+		Name:        eloiseStrategyName,
+		Description: "distribution-aware key burn-rate heuristic (earlier heuristic)",
+		Quality:     "fair",
+		Speed:       "fast",
+	}, func() Sorter { return NewEloiseSorter() })
+	r.RegisterInfo(Info{
+		Name:        constanceStrategyName,
+		Description: "pattern walk: mostly +1 steps, some same-key and mode-switch moves (earlier heuristic)",
+		Quality:     "fair",
+		Speed:       "fast",
+	}, func() Sorter { return NewConstanceSorter() })
+}
+
+// Register adds or replaces a sorter factory in the registry with no description.
 func Register(name string, factory Factory) {
-	factories[name] = factory
+	registry.Register(name, factory)
+}
+
+// RegisterInfo adds or replaces a sorter factory along with its description.
+func RegisterInfo(info Info, factory Factory) {
+	registry.RegisterInfo(info, factory)
 }
 
 // Get returns a sorter by name.
 func Get(name string) (Sorter, error) {
-	factory, ok := factories[name]
+	return registry.Get(name)
+}
+
+// Describe returns the registered description of a strategy.
+func Describe(name string) (Info, bool) {
+	return registry.Describe(name)
+}
+
+// Names returns a sorted list of registered strategy names for help output.
+func Names() []string {
+	return registry.Names()
+}
+
+// Infos returns the description of every registered strategy, sorted by name.
+func Infos() []Info {
+	return registry.Infos()
+}
+
+// Register is the package-level Register for r.
+func (r *Registry) Register(name string, factory Factory) {
+	r.RegisterInfo(Info{Name: name}, factory)
+}
+
+// RegisterInfo is the package-level RegisterInfo for r.
+func (r *Registry) RegisterInfo(info Info, factory Factory) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reg[info.Name] = registration{info: info, factory: factory}
+}
+
+// Get is the package-level Get for r.
+func (r *Registry) Get(name string) (Sorter, error) {
+	r.mu.RLock()
+	reg, ok := r.reg[name]
+	r.mu.RUnlock()
 	if !ok {
 		return nil, fmt.Errorf("unknown strategy: %s", name)
 	}
-	return factory(), nil
+	return reg.factory(), nil
 }
 
-// Names returns a sorted list of registered strategy names for help output.
-func Names() []string {
-	names := make([]string, 0, len(factories))
-	for name := range factories {
+// Describe is the package-level Describe for r.
+func (r *Registry) Describe(name string) (Info, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	reg, ok := r.reg[name]
+	return reg.info, ok
+}
+
+// Names is the package-level Names for r.
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	names := make([]string, 0, len(r.reg))
+	for name := range r.reg {
 		names = append(names, name)
 	}
 	sort.Strings(names)
 	return names
 }
+
+// Infos is the package-level Infos for r.
+func (r *Registry) Infos() []Info {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	infos := make([]Info, 0, len(r.reg))
+	for _, reg := range r.reg {
+		infos = append(infos, reg.info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].Name < infos[j].Name })
+	return infos
+}
@@ -0,0 +1,72 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestEveryBuiltinStrategyIsDescribed(t *testing.T) {
+	for _, info := range Infos() {
+		if info.Description == "" || info.Quality == "" || info.Speed == "" {
+			t.Errorf("strategy %q is missing metadata: %+v", info.Name, info)
+		}
+		if s, err := Get(info.Name); err != nil || s.Name() != info.Name {
+			t.Errorf("Get(%q) = %v, %v", info.Name, s, err)
+		}
+	}
+}
+
+type stubSorter struct{}
+
+func (stubSorter) Name() string { return "stub" }
+func (stubSorter) Sort(_ context.Context, tracks []track.Track) ([]track.Track, error) {
+	return tracks, nil
+}
+
+func TestRegisterInfo(t *testing.T) {
+	t.Cleanup(func() { delete(registry.reg, "stub") })
+
+	Register("stub", func() Sorter { return stubSorter{} })
+	if info, ok := Describe("stub"); !ok || info.Description != "" {
+		t.Fatalf("Describe after Register = %+v, %v", info, ok)
+	}
+	RegisterInfo(Info{Name: "stub", Description: "returns its input"}, func() Sorter { return stubSorter{} })
+	if info, _ := Describe("stub"); info.Description != "returns its input" {
+		t.Fatalf("Describe after RegisterInfo = %+v", info)
+	}
+	if _, ok := Describe("missing"); ok {
+		t.Fatal("Describe reported an unregistered strategy")
+	}
+}
+
+func TestRegistriesAreIsolated(t *testing.T) {
+	t.Cleanup(func() { delete(registry.reg, "stub") })
+
+	a, b := NewRegistry(), NewRegistry()
+	a.RegisterInfo(Info{Name: "stub", Description: "a's"}, func() Sorter { return stubSorter{} })
+	b.RegisterInfo(Info{Name: "stub", Description: "b's"}, func() Sorter { return stubSorter{} })
+	b.RegisterInfo(Info{Name: defaultStrategyName, Description: "tuned"},
+		func() Sorter { return NewDefaultSorterWith(DefaultTuning) })
+	if info, _ := a.Describe("stub"); info.Description != "a's" {
+		t.Errorf("a's stub = %+v", info)
+	}
+	if info, _ := b.Describe("stub"); info.Description != "b's" {
+		t.Errorf("b's stub = %+v", info)
+	}
+	if _, ok := Describe("stub"); ok {
+		t.Error("a registry's strategy leaked into the shared registry")
+	}
+	if info, _ := Describe(defaultStrategyName); info.Description == "tuned" {
+		t.Error("a registry's replacement leaked into the shared registry")
+	}
+
+	Register("stub", func() Sorter { return stubSorter{} })
+	if _, err := NewRegistry().Get("stub"); err == nil {
+		t.Error("a new registry holds a strategy registered after it in the shared one")
+	}
+	if got, want := len(a.Names()), len(Names()); got != want {
+		t.Errorf("a has %d strategies, the shared registry %d (built-ins plus one stub each)", got, want)
+	}
+}
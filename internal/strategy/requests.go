@@ -0,0 +1,83 @@
+package strategy
+
+import (
+	"fmt"
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultRequestWindow is how many upcoming tracks PlaceRequest looks across: a request
+// played much later than that hasn't really been played for the person who asked.
+const DefaultRequestWindow = 8
+
+// RequestSpot is where a request can play among the upcoming tracks: before
+// upcoming[Position] (len(upcoming) for after the last), and how much it adds to the
+// mix score there. Reason says why no spot was found; it is "" for a sensible one.
+type RequestSpot struct {
+	Position int
+	Cost     float64
+	Reason   string
+}
+
+// PlaceRequest finds the spot for req in the first window tracks of upcoming, which
+// follow now: the one that adds least to the mix score among the spots where req mixes
+// out of the track before it and into the one after it (keys by the Camelot rules,
+// tempos within DefaultPartnerWindow), the soonest when several tie. ok is false when
+// no spot in the window is harmonically sensible; the spot returned is then the
+// cheapest one, and its Reason says what stands in the way there.
+func PlaceRequest(now track.Track, upcoming []track.Track, req track.Track, window int) (spot RequestSpot, ok bool) {
+	if window <= 0 {
+		window = DefaultRequestWindow
+	}
+	last := min(window, len(upcoming))
+	best := RequestSpot{Position: -1, Cost: math.Inf(1)}
+	fallback := best
+	for pos := 0; pos <= last; pos++ {
+		prev := now
+		if pos > 0 {
+			prev = upcoming[pos-1]
+		}
+		cost := TransitionCost(prev, req, DefaultWeights)
+		var reason string
+		if !mixes(prev, req, DefaultPartnerWindow) {
+			reason = mismatch(prev, req)
+		}
+		if pos < len(upcoming) {
+			next := upcoming[pos]
+			cost += TransitionCost(req, next, DefaultWeights) - TransitionCost(prev, next, DefaultWeights)
+			if reason == "" && !mixes(req, next, DefaultPartnerWindow) {
+				reason = mismatch(req, next)
+			}
+		}
+		if reason == "" && cost < best.Cost {
+			best = RequestSpot{Position: pos, Cost: cost}
+		}
+		if cost < fallback.Cost {
+			fallback = RequestSpot{Position: pos, Cost: cost, Reason: reason}
+		}
+	}
+	if best.Position >= 0 {
+		return best, true
+	}
+	return fallback, false
+}
+
+// mismatch says why b can't follow a: a key clash, a tempo jump, or both.
+func mismatch(a, b track.Track) string {
+	trans := NewTransition(a, b)
+	var parts []string
+	if !trans.AnyKey && !trans.FromKey.Compatible(trans.ToKey) {
+		parts = append(parts, fmt.Sprintf("key %s clashes with %s", trans.ToKey, trans.FromKey))
+	}
+	if stretch := tempoStretch(a.ExitBPM(), b.EntryBPM()); stretch > DefaultPartnerWindow {
+		parts = append(parts, fmt.Sprintf("%s BPM is %.0f%% off %s", b.TempoString(), stretch, a.TempoString()))
+	}
+	if len(parts) == 2 {
+		return parts[0] + ", and " + parts[1]
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return ""
+}
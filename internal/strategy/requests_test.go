@@ -0,0 +1,39 @@
+package strategy
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestPlaceRequest(t *testing.T) {
+	now := track.Track{Title: "now", BPM: 124, Energy: 50, Key: mustKey("8A")}
+	upcoming := []track.Track{
+		{Title: "u1", BPM: 124, Energy: 55, Key: mustKey("3A")},
+		{Title: "u2", BPM: 125, Energy: 60, Key: mustKey("3A")},
+		{Title: "u3", BPM: 125, Energy: 60, Key: mustKey("4A")},
+	}
+
+	// 3A mixes out of u1 and u2, and into u2 and u3; it clashes with "now" (8A).
+	req := track.Track{Title: "req", BPM: 124, Energy: 58, Key: mustKey("3A")}
+	spot, ok := PlaceRequest(now, upcoming, req, DefaultRequestWindow)
+	if !ok || spot.Position == 0 || spot.Reason != "" {
+		t.Errorf("PlaceRequest = %+v, %v; want a spot after u1", spot, ok)
+	}
+
+	far := track.Track{Title: "far", BPM: 150, Energy: 58, Key: mustKey("11B")}
+	spot, ok = PlaceRequest(now, upcoming, far, DefaultRequestWindow)
+	if ok || !strings.Contains(spot.Reason, "clashes") || !strings.Contains(spot.Reason, "BPM") {
+		t.Errorf("PlaceRequest of a clashing track = %+v, %v; want a key and tempo reason", spot, ok)
+	}
+
+	// Only the window counts: a spot after u3 is past a window of 1.
+	late := track.Track{Title: "late", BPM: 125, Energy: 60, Key: mustKey("5A")}
+	if _, ok := PlaceRequest(now, upcoming, late, 1); ok {
+		t.Error("PlaceRequest placed a request outside its window")
+	}
+	if spot, ok := PlaceRequest(now, upcoming, late, 3); !ok || spot.Position != 3 {
+		t.Errorf("PlaceRequest = %+v, %v; want it after u3", spot, ok)
+	}
+}
@@ -0,0 +1,150 @@
+package strategy
+
+import (
+	"context"
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Reset-rule tuning, on the same scale as placement: each misplaced reset costs
+// resetUnit, and one inside a forbidden window costs resetSlope more per set-fraction of
+// distance to the window's edge, so the optimizer nudges it out.
+const (
+	resetUnit  = 3.0
+	resetSlope = 6.0
+)
+
+// ResetRule steers where energy resets — intensity drops big enough to start a new
+// build — may fall. The contour score decides how many resets a set wants; these rules
+// decide where they are allowed.
+type ResetRule struct {
+	// Forbid lists windows where no reset may start, e.g. the first 20% of the set.
+	Forbid []Window
+	// MinGap is the fewest tracks from one reset to the next (0 = no limit).
+	MinGap int
+	// OnWrap allows resets only where the key wheel wraps back past 12 (11A into 2A),
+	// so a new energy build starts with a new lap of the wheel.
+	OnWrap bool
+	// At lists minutes into the set where a reset must start, such as the top of each
+	// hour of a radio show: the track that starts nearest each mark should follow a drop.
+	At []float64
+}
+
+func (r ResetRule) empty() bool {
+	return len(r.Forbid) == 0 && r.MinGap <= 0 && !r.OnWrap && len(r.At) == 0
+}
+
+const resetContextKey contextKey = "strategy.resets"
+
+// WithResets sets the reset rule for a sort, replacing any earlier one. Sort repairs any
+// ordering that breaks it, and the flow strategy optimizes it directly.
+func WithResets(ctx context.Context, rule ResetRule) context.Context {
+	return context.WithValue(ctx, resetContextKey, rule)
+}
+
+func resetsFromContext(ctx context.Context) ResetRule {
+	if ctx == nil {
+		return ResetRule{}
+	}
+	rule, _ := ctx.Value(resetContextKey).(ResetRule)
+	return rule
+}
+
+// boundResets is a reset rule resolved against one track list.
+type boundResets struct {
+	rule   ResetRule
+	intens []float64
+	entry  []track.Key
+	exit   []track.Key
+	timing *boundPlacement // reuses placement's playtime positions
+	lo, hi []float64       // per forbidden window, as set fractions
+	marks  []float64       // per required reset, as a set fraction
+}
+
+func bindResets(tracks []track.Track, rule ResetRule) *boundResets {
+	b := &boundResets{
+		rule:   rule,
+		intens: intensities(tracks),
+		entry:  make([]track.Key, len(tracks)),
+		exit:   make([]track.Key, len(tracks)),
+		timing: bindPlacement(tracks, nil),
+		lo:     make([]float64, len(rule.Forbid)),
+		hi:     make([]float64, len(rule.Forbid)),
+	}
+	for i, t := range tracks {
+		b.entry[i], b.exit[i] = t.EntryKey(), t.ExitKey()
+	}
+	for w, win := range rule.Forbid {
+		b.lo[w], b.hi[w] = win.resolve(b.timing.total / 60)
+	}
+	for _, m := range rule.At {
+		if f := m * 60 / max(b.timing.total, 1); f > 0 && f < 1 {
+			b.marks = append(b.marks, f)
+		}
+	}
+	return b
+}
+
+// visit calls fn with the position k of every reset in perm (the track after the drop)
+// and its cost under the rule.
+func (b *boundResets) visit(perm []int, fn func(k int, cost float64)) {
+	at := b.timing.starts(perm)
+	last := -1
+	for k := 1; k < len(perm); k++ {
+		from, to := perm[k-1], perm[k]
+		if b.intens[to]-b.intens[from] > -contourResetDrop {
+			continue
+		}
+		cost := 0.0
+		for w := range b.lo {
+			if f := at[k]; f >= b.lo[w] && f <= b.hi[w] {
+				cost += resetUnit + resetSlope*min(f-b.lo[w], b.hi[w]-f)
+			}
+		}
+		if b.rule.OnWrap && !KeyTransition(b.exit[from], b.entry[to]).Wrap {
+			cost += resetUnit
+		}
+		if b.rule.MinGap > 0 && last >= 0 && k-last < b.rule.MinGap {
+			cost += resetUnit
+		}
+		last = k
+		fn(k, cost)
+	}
+}
+
+// missed calls fn with the position k nearest each required reset mark where perm has
+// no reset (the track at k doesn't follow a drop).
+func (b *boundResets) missed(perm []int, fn func(k int)) {
+	if len(b.marks) == 0 || len(perm) < 2 {
+		return
+	}
+	at := b.timing.starts(perm)
+	for _, m := range b.marks {
+		k := 1
+		for j := 2; j < len(perm); j++ {
+			if math.Abs(at[j]-m) < math.Abs(at[k]-m) {
+				k = j
+			}
+		}
+		if b.intens[perm[k]]-b.intens[perm[k-1]] > -contourResetDrop {
+			fn(k)
+		}
+	}
+}
+
+func (b *boundResets) cost(perm []int) float64 {
+	total := 0.0
+	b.visit(perm, func(_ int, cost float64) { total += cost })
+	b.missed(perm, func(int) { total += resetUnit })
+	return total
+}
+
+func (b *boundResets) conflicts(perm []int, out []bool) {
+	b.visit(perm, func(k int, cost float64) {
+		if cost > 0 {
+			out[k-1], out[k] = true, true
+		}
+	})
+	b.missed(perm, func(k int) { out[k-1], out[k] = true, true })
+}
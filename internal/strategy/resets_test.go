@@ -0,0 +1,73 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func resetTracks() []track.Track {
+	mk := func(title string, energy, num int) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: energy, Key: track.Key{Number: num, Mode: track.ModeA}}
+	}
+	// Two builds: 40→80 on keys 9-12, then a drop back to 40 on keys 1-4.
+	return []track.Track{
+		mk("a1", 40, 9), mk("a2", 55, 10), mk("a3", 70, 11), mk("a4", 80, 12),
+		mk("b1", 40, 1), mk("b2", 55, 2), mk("b3", 70, 3), mk("b4", 80, 4),
+	}
+}
+
+func TestResetRuleCosts(t *testing.T) {
+	tracks := resetTracks()
+	perm := identity(len(tracks)) // one reset, at position 4 (50% through), on a wrap
+
+	tests := []struct {
+		name string
+		rule ResetRule
+		ok   bool
+	}{
+		{"wrap", ResetRule{OnWrap: true}, true},
+		{"outside forbidden window", ResetRule{Forbid: []Window{{Lo: 0, Hi: 0.25}}}, true},
+		{"inside forbidden window", ResetRule{Forbid: []Window{{Lo: 0, Hi: 0.6}}}, false},
+		// 8 tracks of the default 3.5 minutes: the reset starts at minute 14.
+		{"required at the reset", ResetRule{At: []float64{14}}, true},
+		{"required elsewhere", ResetRule{At: []float64{7}}, false},
+	}
+	for _, tc := range tests {
+		if got := bindResets(tracks, tc.rule).cost(perm); (got == 0) != tc.ok {
+			t.Errorf("%s: cost = %v, want ok=%v", tc.name, got, tc.ok)
+		}
+	}
+
+	// Playing the second build first puts the drop at 4A into 9A, which is not a wrap.
+	swapped := []int{4, 5, 6, 7, 0, 1, 2, 3}
+	if bindResets(tracks, ResetRule{OnWrap: true}).cost(swapped) == 0 {
+		t.Error("a reset off the wrap was not penalized")
+	}
+
+	// Three builds of two tracks: resets two tracks apart break a gap of 3.
+	short := []int{0, 2, 4, 6, 5, 7}
+	b := bindResets(tracks, ResetRule{MinGap: 3})
+	if b.cost(short) == 0 {
+		t.Error("resets closer than MinGap were not penalized")
+	}
+	conflicts := make([]bool, len(short))
+	b.conflicts(short, conflicts)
+	if !conflicts[3] || !conflicts[4] {
+		t.Errorf("conflicts = %v, want the second reset marked", conflicts)
+	}
+}
+
+func TestSortHonorsResetRule(t *testing.T) {
+	tracks := resetTracks()
+	ctx := WithResets(WithSeed(context.Background(), 1), ResetRule{Forbid: []Window{{Lo: 0, Hi: 0.6}}})
+	res, err := Sort(ctx, NewFlowSorter(), tracks)
+	if err != nil {
+		t.Fatalf("Sort returned error: %v", err)
+	}
+	rule := bindResets(res.Ordered, ResetRule{Forbid: []Window{{Lo: 0, Hi: 0.6}}})
+	if cost := rule.cost(identity(len(res.Ordered))); cost > 0 {
+		t.Fatalf("ordering %v still resets in the first 60%% (cost %v)", titles(res.Ordered), cost)
+	}
+}
@@ -0,0 +1,135 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Residency describes a run of gigs drawn from one library: how many nights, how big
+// each night's set is (Tracks, or Seconds of playtime), and RepeatTolerance, the
+// largest share of a night's tracks (0 to 1) that may have played on an earlier night.
+type Residency struct {
+	Nights          int
+	Tracks          int
+	Seconds         float64
+	RepeatTolerance float64
+}
+
+// Night is one night of a residency plan: its ordered set, and how many of its
+// tracks played on an earlier night.
+type Night struct {
+	Result
+	Repeats int
+}
+
+// PlanResidency orders r.Nights sets from tracks, one after another, so that each
+// night is a sound set in its own right while repeating as little as the tolerance
+// asks. Each night starts as the head of a sort of the library, cut to its size; then
+// tracks not yet played are swapped in, as MeetQuota does, until no more than the
+// tolerated share are repeats, and the set is sorted on its own. A tolerance of 0
+// sorts only the tracks not yet played for as long as they fill a night; a higher one
+// lets the best-fitting tracks come back when they make a better set. When fresh
+// tracks run out, the night repeats more than the tolerance allows, favoring tracks
+// that didn't play the night before, and says so in its warnings. The quota counts tracks, so a night sized by length can end up a little
+// shorter or longer than r.Seconds after swaps.
+func PlanResidency(ctx context.Context, s Sorter, tracks []track.Track, r Residency) ([]Night, error) {
+	switch {
+	case r.Nights < 1:
+		return nil, errors.New("residency: want at least 1 night")
+	case r.Tracks <= 0 && r.Seconds <= 0:
+		return nil, errors.New("residency: want a night size in tracks or seconds")
+	case r.RepeatTolerance < 0 || r.RepeatTolerance > 1:
+		return nil, fmt.Errorf("residency: repeat tolerance %g is outside 0-1", r.RepeatTolerance)
+	}
+
+	played := make(map[string]int, len(tracks)) // track ID to the last night it played
+	fresh := func(t track.Track) bool { return played[t.ID()] == 0 }
+	nights := make([]Night, 0, r.Nights)
+	for n := 1; n <= r.Nights; n++ {
+		pool := tracks
+		if r.RepeatTolerance == 0 {
+			if unplayed := slices.DeleteFunc(slices.Clone(tracks), func(t track.Track) bool { return !fresh(t) }); r.fills(unplayed) {
+				pool = unplayed
+			}
+		}
+		set, err := pickNight(ctx, s, pool, r)
+		if err != nil {
+			return nights, fmt.Errorf("night %d: %w", n, err)
+		}
+		allowed := int(math.Floor(r.RepeatTolerance * float64(len(set))))
+		set, _ = MeetQuota(set, tracks, fresh, len(set)-allowed)
+		if n > 1 {
+			// Short of fresh tracks, at least skip the ones played the night before.
+			rested := func(t track.Track) bool { return played[t.ID()] < n-1 }
+			set, _ = MeetQuota(set, tracks, rested, len(set)-allowed)
+		}
+
+		res, err := Sort(ctx, s, set)
+		if err != nil {
+			return nights, fmt.Errorf("night %d: %w", n, err)
+		}
+		if res.Partial {
+			return nights, fmt.Errorf("night %d: sorting stopped before finishing", n)
+		}
+		night := Night{Result: res}
+		for _, t := range res.Ordered {
+			if !fresh(t) {
+				night.Repeats++
+			}
+		}
+		if night.Repeats > allowed {
+			night.Warnings = append(night.Warnings, fmt.Sprintf("the library ran out of tracks not yet played; %d repeat(s) where the tolerance allows %d",
+				night.Repeats, allowed))
+		}
+		for _, t := range res.Ordered {
+			played[t.ID()] = n
+		}
+		nights = append(nights, night)
+	}
+	return nights, nil
+}
+
+// fills reports whether tracks are enough for a whole night.
+func (r Residency) fills(tracks []track.Track) bool {
+	if r.Seconds > 0 {
+		total := 0.0
+		for _, d := range trackSeconds(tracks) {
+			total += d
+		}
+		return total >= r.Seconds
+	}
+	return len(tracks) >= r.Tracks
+}
+
+// pickNight picks a night's worth of tracks from pool: the head of its sort, up to
+// r.Tracks tracks or r.Seconds of playtime.
+func pickNight(ctx context.Context, s Sorter, pool []track.Track, r Residency) ([]track.Track, error) {
+	sortCtx := ctx
+	if r.Tracks > 0 {
+		sortCtx = WithLimit(ctx, r.Tracks)
+	}
+	res, err := Sort(sortCtx, s, pool)
+	if err != nil {
+		return nil, err
+	}
+	if res.Partial {
+		return nil, errors.New("sorting stopped before finishing")
+	}
+	if r.Tracks > 0 {
+		return Truncate(ctx, res.Ordered, r.Tracks), nil
+	}
+	n, total := 0, 0.0
+	for _, d := range trackSeconds(res.Ordered) {
+		if n > 0 && total+d > r.Seconds {
+			break
+		}
+		n++
+		total += d
+	}
+	return res.Ordered[:n], nil
+}
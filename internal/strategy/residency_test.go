@@ -0,0 +1,59 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestPlanResidencyAvoidsRepeats(t *testing.T) {
+	tracks := chaveTracks(40)
+	ctx := WithSeed(context.Background(), 7)
+	sorter, err := Get("flow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	nights, err := PlanResidency(ctx, sorter, tracks, Residency{Nights: 3, Tracks: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nights) != 3 {
+		t.Fatalf("got %d nights, want 3", len(nights))
+	}
+	seen := map[string]bool{}
+	for i, n := range nights {
+		if len(n.Ordered) != 12 || n.Repeats != 0 {
+			t.Fatalf("night %d: %d tracks with %d repeats, want 12 fresh", i+1, len(n.Ordered), n.Repeats)
+		}
+		for _, tr := range n.Ordered {
+			if seen[tr.ID()] {
+				t.Fatalf("night %d repeats %s with a zero tolerance", i+1, tr.Title)
+			}
+			seen[tr.ID()] = true
+		}
+	}
+
+	// Four nights of 12 from 40 tracks: the last has to repeat and says so.
+	nights, err = PlanResidency(ctx, sorter, tracks, Residency{Nights: 4, Tracks: 12})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := nights[3]; last.Repeats != 8 || len(last.Warnings) == 0 {
+		t.Fatalf("last night: %d repeats, warnings %q; want 8 and a warning", last.Repeats, last.Warnings)
+	}
+
+	// A tolerance caps the repeats without forcing them.
+	nights, err = PlanResidency(ctx, sorter, tracks, Residency{Nights: 3, Tracks: 12, RepeatTolerance: 0.25})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, n := range nights {
+		if n.Repeats > 3 {
+			t.Fatalf("night %d: %d repeats, tolerance allows 3", i+1, n.Repeats)
+		}
+	}
+
+	if _, err := PlanResidency(ctx, sorter, tracks, Residency{Nights: 2}); err == nil {
+		t.Fatal("a residency with no night size should fail")
+	}
+}
@@ -0,0 +1,128 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const rotationStrategyName = "rotation"
+
+// rotationLapSize is about how many tracks one lap of the wheel plays: two a stop.
+const rotationLapSize = 24
+
+// RotationSorter walks the Camelot wheel in a steady +1 rotation, 1 → 2 → … → 12 → 1,
+// lap after lap. Each stop is a key number (both modes) and plays the tracks that start
+// in it, spread over the laps in proportion to how many it has: a stop with twice the
+// tracks plays twice as many each time round, and a stop too small for every lap is
+// skipped on the last ones (a +2 step). Within a stop the tracks are taken quietest
+// first, so each lap is a little more energetic than the one before, and the ones in
+// the mode the set is in play before it switches to the relative mode. The set
+// starts at the stop of its quietest track. There is no randomness: the same library
+// always gives the same set.
+type RotationSorter struct{}
+
+func NewRotationSorter() *RotationSorter {
+	return &RotationSorter{}
+}
+
+func (s *RotationSorter) Name() string {
+	return rotationStrategyName
+}
+
+func (s *RotationSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.Track, error) {
+	if len(tracks) <= 1 {
+		copied := make([]track.Track, len(tracks))
+		for i, t := range tracks {
+			copied[i] = t.Clone()
+		}
+		return copied, nil
+	}
+
+	// stops[n] holds the tracks starting in key number n, quietest first; stops[0]
+	// holds any without a key, played last.
+	var stops [13][]track.Track
+	for _, t := range tracks {
+		stops[t.EntryKey().Number] = append(stops[t.EntryKey().Number], t.Clone())
+	}
+	for _, stop := range stops {
+		slices.SortStableFunc(stop, func(a, b track.Track) int {
+			return cmp.Or(cmp.Compare(a.Energy, b.Energy), cmp.Compare(a.BPM, b.BPM))
+		})
+	}
+	if limit := limitFromContext(ctx); limit > 0 && limit < len(tracks) {
+		stops = rotationPick(stops, len(tracks), limit)
+	}
+
+	total, start, quietest := 0, 0, 0
+	for n := 1; n <= 12; n++ {
+		total += len(stops[n])
+		if len(stops[n]) > 0 && (start == 0 || stops[n][0].Energy < quietest) {
+			start, quietest = n, stops[n][0].Energy
+		}
+	}
+	ordered := make([]track.Track, 0, total+len(stops[0]))
+	if start > 0 {
+		laps := max(1, (total+rotationLapSize-1)/rotationLapSize)
+		mode := stops[start][0].EntryKey().Mode
+		for lap := range laps {
+			for step := range 12 {
+				stop := stops[track.Key{Number: start}.Transpose(step).Number]
+				// Ceiling division hands the earlier laps any extra, so a small stop
+				// plays on the first laps rather than the last.
+				lo, hi := (lap*len(stop)+laps-1)/laps, ((lap+1)*len(stop)+laps-1)/laps
+				ordered = append(ordered, rotationVisit(stop[lo:hi], &mode)...)
+			}
+		}
+	}
+	return append(ordered, stops[0]...), nil
+}
+
+// rotationVisit orders one visit to a stop: the tracks in mode first, then those in
+// the relative mode, each quietest first. It leaves mode at the visit's last key.
+func rotationVisit(visit []track.Track, mode *track.Mode) []track.Track {
+	out := make([]track.Track, 0, len(visit))
+	for _, t := range visit {
+		if t.EntryKey().Mode == *mode {
+			out = append(out, t)
+		}
+	}
+	for _, t := range visit {
+		if t.EntryKey().Mode != *mode {
+			out = append(out, t)
+		}
+	}
+	if len(out) > 0 {
+		*mode = out[len(out)-1].ExitKey().Mode
+	}
+	return out
+}
+
+// rotationPick cuts the stops down to want of their n tracks, each stop keeping its
+// share (largest remainder first, then the lower stop) spread evenly over its energy
+// range.
+func rotationPick(stops [13][]track.Track, n, want int) [13][]track.Track {
+	var quota [13]int
+	type remainder struct{ stop, frac int }
+	var rems []remainder
+	given := 0
+	for s, stop := range stops {
+		quota[s] = len(stop) * want / n
+		given += quota[s]
+		rems = append(rems, remainder{s, len(stop) * want % n})
+	}
+	slices.SortStableFunc(rems, func(a, b remainder) int { return cmp.Compare(b.frac, a.frac) })
+	for _, r := range rems[:want-given] {
+		quota[r.stop]++
+	}
+
+	var picked [13][]track.Track
+	for s, stop := range stops {
+		for i := range quota[s] {
+			picked[s] = append(picked[s], stop[(2*i+1)*len(stop)/(2*quota[s])])
+		}
+	}
+	return picked
+}
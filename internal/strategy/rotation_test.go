@@ -0,0 +1,65 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestRotationWalksTheWheel(t *testing.T) {
+	// Two tracks at every stop, and six at 5A: two laps, with 5 playing three a lap.
+	var tracks []track.Track
+	for n := 1; n <= 12; n++ {
+		count := 2
+		if n == 5 {
+			count = 6
+		}
+		for i := range count {
+			tracks = append(tracks, track.Track{Title: fmt.Sprintf("%dA-%d", n, i), BPM: 124,
+				Energy: 30 + 10*i + n, Key: track.Key{Number: n, Mode: track.ModeA}})
+		}
+	}
+
+	got, err := NewRotationSorter().Sort(context.Background(), tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(tracks) {
+		t.Fatalf("got %d tracks, want %d", len(got), len(tracks))
+	}
+	if got[0].Title != "1A-0" {
+		t.Errorf("opened on %s, want the quietest track, 1A-0", got[0].Title)
+	}
+	wraps, atFive := 0, 0
+	for i := 1; i < len(got); i++ {
+		steps := got[i-1].Key.Steps(got[i].Key)
+		if steps > 1 {
+			t.Fatalf("%s -> %s is %d steps, want a steady +1 rotation", got[i-1].Title, got[i].Title, steps)
+		}
+		if got[i].Key.Number < got[i-1].Key.Number {
+			wraps++
+		}
+		if got[i].Key.Number == 5 && wraps == 0 {
+			atFive++
+		}
+	}
+	if wraps != 1 || atFive != 3 {
+		t.Errorf("%d laps with %d tracks at 5A on the first, want 2 laps and 3", wraps+1, atFive)
+	}
+
+	limited, err := NewRotationSorter().Sort(WithLimit(context.Background(), 14), tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	five := 0
+	for _, tr := range limited {
+		if tr.Key.Number == 5 {
+			five++
+		}
+	}
+	if len(limited) != 14 || five != 3 {
+		t.Errorf("limited to 14: got %d tracks, %d at 5A; want 14 and 3", len(limited), five)
+	}
+}
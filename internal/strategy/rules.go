@@ -0,0 +1,324 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// orderRule is a whole-ordering preference — separation, placement, resets, energy
+// targets and arcs, pins, locked orders, variety from a previous set, the crowd's
+// lean, a DJ's style, the energy swing, prepared tracks at key positions, strict
+// Camelot — resolved against one track list so it can be scored cheaply over
+// permutations of that list. Rules sit on top of the mix score: flow adds them to its
+// objective, and Sort repairs any other strategy's output that breaks them.
+type orderRule interface {
+	// cost is the penalty for the ordering perm (indices into the bound tracks); 0
+	// means the rule is satisfied.
+	cost(perm []int) float64
+	// conflicts marks, in out, the positions of perm that contribute to the penalty.
+	conflicts(perm []int, out []bool)
+}
+
+// bindRules resolves every ordering rule carried by ctx against tracks.
+func bindRules(ctx context.Context, tracks []track.Track) []orderRule {
+	var rules []orderRule
+	if sep := separationFromContext(ctx); len(sep) > 0 {
+		hard := slices.DeleteFunc(slices.Clone(sep), func(s Separation) bool { return s.Soft })
+		if len(hard) > 0 {
+			rules = append(rules, bindSeparation(tracks, hard))
+		}
+		if soft := slices.DeleteFunc(slices.Clone(sep), func(s Separation) bool { return !s.Soft }); len(soft) > 0 {
+			b := bindSeparation(tracks, soft)
+			b.unit = softSeparationUnit
+			rules = append(rules, softSeparation{b})
+		}
+	}
+	if pl := placementFromContext(ctx); len(pl) > 0 {
+		rules = append(rules, bindPlacement(tracks, pl))
+	}
+	if rr := resetsFromContext(ctx); !rr.empty() {
+		rules = append(rules, bindResets(tracks, rr))
+	}
+	if et := energyTargetsFromContext(ctx); len(et) > 0 {
+		rules = append(rules, bindTargets(tracks, et))
+	}
+	if arc := arcFromContext(ctx); arc != nil {
+		rules = append(rules, bindArc(tracks, *arc))
+	}
+	if pins := pinsFromContext(ctx); len(pins) > 0 {
+		rules = append(rules, bindPins(tracks, pins))
+	}
+	if backbone := lockedOrderFromContext(ctx); len(backbone) > 0 {
+		rules = append(rules, bindLock(tracks, backbone))
+	}
+	if v := varietyFromContext(ctx); v != nil {
+		rules = append(rules, bindVariety(tracks, *v))
+	}
+	if c := crowdFromContext(ctx); c != nil {
+		rules = append(rules, bindCrowd(tracks, *c, genresFromContext(ctx)))
+	}
+	if s := styleFromContext(ctx); s != nil {
+		rules = append(rules, bindStyle(tracks, *s))
+	}
+	if s := energySwingFromContext(ctx); s != nil {
+		rules = append(rules, bindSwing(tracks, *s))
+	}
+	if w := preparedFromContext(ctx); w > 0 {
+		rules = append(rules, bindPrepared(tracks, w, limitFromContext(ctx)))
+	}
+	if w := keyMemoryFromContext(ctx); w > 0 {
+		rules = append(rules, bindKeyMemory(tracks, w, limitFromContext(ctx)))
+	}
+	if strictCamelotFromContext(ctx) {
+		rules = append(rules, bindStrict(tracks))
+	}
+	return rules
+}
+
+// withoutRules hides ordering rules from a nested sort over part of a set (such as one
+// chave), where positions and windows would be measured against the wrong span. The
+// enclosing Sort still enforces them on the whole ordering.
+func withoutRules(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, separationContextKey, []Separation(nil))
+	ctx = context.WithValue(ctx, resetContextKey, ResetRule{})
+	ctx = context.WithValue(ctx, energyTargetContextKey, []EnergyTarget(nil))
+	ctx = context.WithValue(ctx, arcContextKey, (*Arc)(nil))
+	ctx = context.WithValue(ctx, pinContextKey, []Pin(nil))
+	ctx = context.WithValue(ctx, lockedContextKey, []track.Track(nil))
+	ctx = context.WithValue(ctx, varietyContextKey, (*Variety)(nil))
+	ctx = context.WithValue(ctx, crowdContextKey, (*Crowd)(nil))
+	ctx = context.WithValue(ctx, styleContextKey, (*Style)(nil))
+	ctx = context.WithValue(ctx, swingContextKey, (*EnergySwing)(nil))
+	ctx = context.WithValue(ctx, preparedContextKey, 0.0)
+	ctx = context.WithValue(ctx, keyMemoryContextKey, 0.0)
+	ctx = context.WithValue(ctx, strictCamelotContextKey, false)
+	return context.WithValue(ctx, placementContextKey, []Placement(nil))
+}
+
+func rulesCost(rules []orderRule, perm []int) float64 {
+	total := 0.0
+	for _, r := range rules {
+		total += r.cost(perm)
+	}
+	return total
+}
+
+// softRule is an orderRule weighed against the mix score rather than enforced, such
+// as variety from a previous set or the crowd's lean: Sort's repair moves a track for it only when that
+// lowers the mix score and the penalty together (see weighSoftRules).
+type softRule interface {
+	orderRule
+	soft()
+}
+
+// splitRules separates the rules to enforce from the soft ones.
+func splitRules(rules []orderRule) (hard, soft []orderRule) {
+	for _, r := range rules {
+		if _, ok := r.(softRule); ok {
+			soft = append(soft, r)
+		} else {
+			hard = append(hard, r)
+		}
+	}
+	return hard, soft
+}
+
+// repairSegmentMax is the longest run of tracks enforceRules moves at once.
+const repairSegmentMax = 3
+
+// enforceRules repairs an ordering that breaks its rules by relocating single
+// conflicting tracks, or short runs starting at one, or else another track into a
+// conflict, or swapping a conflicting track with another, when nothing simpler helps.
+// Each accepted move strictly lowers the rule penalty, preferring among equally good
+// moves the one that keeps the mix score lowest, so a compliant ordering is returned
+// untouched. Soft rules are left to weighSoftRules.
+func enforceRules(ordered []track.Track, rules []orderRule) []track.Track {
+	rules, _ = splitRules(rules)
+	n := len(ordered)
+	perm := identity(n)
+	pen := rulesCost(rules, perm)
+	if pen == 0 {
+		return ordered
+	}
+
+	scratch := make([]int, n)
+	seq := make([]track.Track, n)
+	conflicts := make([]bool, n)
+	for pen > 0 {
+		clear(conflicts)
+		for _, r := range rules {
+			r.conflicts(perm, conflicts)
+		}
+		bestPen, bestMix := pen, math.Inf(1)
+		var best []int
+		weigh := func() {
+			np := rulesCost(rules, scratch)
+			if np >= pen-improvementEps {
+				return
+			}
+			for k, idx := range scratch {
+				seq[k] = ordered[idx]
+			}
+			mix := mixTotal(seq, DefaultWeights)
+			if best == nil || np < bestPen-improvementEps ||
+				(np <= bestPen+improvementEps && mix < bestMix) {
+				bestPen, bestMix = np, mix
+				best = append(best[:0], scratch...)
+			}
+		}
+		try := func(i, l, p int) {
+			relocateSegment(scratch, perm, i, l, p)
+			weigh()
+		}
+		// Single tracks first; a run of up to repairSegmentMax only when no single
+		// move helps, as when both neighbors of a track conflict with it.
+		for l := 1; l <= min(repairSegmentMax, n-1) && best == nil; l++ {
+			for i := 0; i+l <= n; i++ {
+				if !conflicts[i] {
+					continue
+				}
+				for p := 0; p+l <= n; p++ {
+					if p != i {
+						try(i, l, p)
+					}
+				}
+			}
+		}
+		// Failing that, a track that breaks nothing itself may go between two that
+		// clash, as 8A between 8B and 7A under strict Camelot.
+		for i := 0; i < n && best == nil; i++ {
+			for c := range n {
+				if !conflicts[c] {
+					continue
+				}
+				for _, p := range []int{c - 1, c, c + 1} {
+					if p >= 0 && p < n && p != i && !conflicts[i] {
+						try(i, 1, p)
+					}
+				}
+			}
+		}
+		// Last, swap a conflicting track with another, leaving every track between
+		// them in place, as when a pin to one position and a pin to a range crowd
+		// each other.
+		for i := 0; i < n && best == nil; i++ {
+			if !conflicts[i] {
+				continue
+			}
+			for j := range n {
+				if j != i {
+					copy(scratch, perm)
+					scratch[i], scratch[j] = scratch[j], scratch[i]
+					weigh()
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		copy(perm, best)
+		pen = bestPen
+	}
+
+	out := make([]track.Track, n)
+	for k, idx := range perm {
+		out[k] = ordered[idx]
+	}
+	return out
+}
+
+// weighSoftRules relocates single tracks that a soft rule penalizes while that
+// strictly lowers the mix score plus the soft penalty, without breaking the other rules
+// any further. The penalty is the rule's price: a reused transition that fits far
+// better than any alternative stays.
+//
+// This is the contract every soft rule (WithVariety, WithStyle, WithCrowd,
+// WithPrepared, WithKeyMemory, WithEnergySwing, a Soft Separation) shares: flow and
+// anneal add its penalty to the mix score as they search, and Sort runs this over
+// every strategy's output, so strategies that don't weigh the rule still honor it.
+func weighSoftRules(ordered []track.Track, rules []orderRule) []track.Track {
+	hard, soft := splitRules(rules)
+	if len(soft) == 0 {
+		return ordered
+	}
+	n := len(ordered)
+	perm := identity(n)
+	seq := make([]track.Track, n)
+	objective := func(perm []int) float64 {
+		for k, idx := range perm {
+			seq[k] = ordered[idx]
+		}
+		return mixTotal(seq, DefaultWeights) + rulesCost(soft, perm)
+	}
+	cur, hardPen := objective(perm), rulesCost(hard, perm)
+
+	scratch := make([]int, n)
+	conflicts := make([]bool, n)
+	for rulesCost(soft, perm) > 0 {
+		clear(conflicts)
+		for _, r := range soft {
+			r.conflicts(perm, conflicts)
+		}
+		bestObj := cur - improvementEps
+		var best []int
+		for i := range n {
+			if !conflicts[i] {
+				continue
+			}
+			for p := range n {
+				if p == i {
+					continue
+				}
+				relocateSegment(scratch, perm, i, 1, p)
+				if len(hard) > 0 && rulesCost(hard, scratch) > hardPen+improvementEps {
+					continue
+				}
+				if obj := objective(scratch); obj < bestObj {
+					bestObj = obj
+					best = append(best[:0], scratch...)
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		copy(perm, best)
+		cur = bestObj
+	}
+
+	out := make([]track.Track, n)
+	for k, idx := range perm {
+		out[k] = ordered[idx]
+	}
+	return out
+}
+
+// ruleConflicts counts the positions of an n-track ordering that break a rule to be
+// enforced; soft rules are traded, not broken.
+func ruleConflicts(rules []orderRule, n int) int {
+	rules, _ = splitRules(rules)
+	conflicts := make([]bool, n)
+	perm := identity(n)
+	for _, r := range rules {
+		r.conflicts(perm, conflicts)
+	}
+	count := 0
+	for _, c := range conflicts {
+		if c {
+			count++
+		}
+	}
+	return count
+}
+
+// identity returns the permutation 0..n-1.
+func identity(n int) []int {
+	perm := make([]int, n)
+	for i := range perm {
+		perm[i] = i
+	}
+	return perm
+}
@@ -0,0 +1,30 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// FitRuntime picks tracks whose lengths add up to as much of seconds as they can
+// without going over — a radio show's music time or a set's length. Like Blend it
+// favors the tracks that fit the whole pool best, taking them in that order and
+// skipping any that would overrun; tracks with no length count as the average of the
+// known ones. Tracks required reports true for (nil for none) are taken first, even
+// past seconds. It returns the indices of the picked tracks, in input order.
+func FitRuntime(ctx context.Context, tracks []track.Track, seconds float64, required func(track.Track) bool) ([]int, error) {
+	dur := trackSeconds(tracks)
+	total := 0.0
+	for _, d := range dur {
+		total += d
+	}
+	if total <= seconds {
+		return identity(len(tracks)), nil
+	}
+
+	order, err := rankByMix(ctx, tracks, 0)
+	if err != nil {
+		return nil, err
+	}
+	return pickInOrder(tracks, order, dur, 0, seconds, required), nil
+}
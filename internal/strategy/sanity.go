@@ -0,0 +1,134 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Genre-fit tuning. Energy misses its genre's range by genreEnergyFar or more, or a
+// tempo that fits the range at neither its own, half, nor double time, is far enough
+// off to call the track implausible on that alone.
+const genreEnergyFar = 25
+
+// GenreProfile is the tempo and energy a genre plausibly has: BPMs from BPMLo to
+// BPMHi and energies (on the 0-100 scale) from EnergyLo to EnergyHi.
+type GenreProfile struct {
+	BPMLo, BPMHi       float64
+	EnergyLo, EnergyHi int
+}
+
+// genreProfiles are the built-in profiles, keyed by canonical genre name. A genre
+// with no entry of its own uses its family's; they are deliberately broad, so only a
+// track well outside its genre's usual range is listed.
+var genreProfiles = map[string]GenreProfile{
+	"House":                {BPMLo: 112, BPMHi: 132, EnergyLo: 30, EnergyHi: 95},
+	"Deep House":           {BPMLo: 110, BPMHi: 126, EnergyLo: 20, EnergyHi: 75},
+	"Techno":               {BPMLo: 118, BPMHi: 150, EnergyLo: 40, EnergyHi: 100},
+	"Hard Techno":          {BPMLo: 135, BPMHi: 165, EnergyLo: 55, EnergyHi: 100},
+	"Trance":               {BPMLo: 125, BPMHi: 150, EnergyLo: 40, EnergyHi: 100},
+	"Drum and Bass":        {BPMLo: 160, BPMHi: 182, EnergyLo: 40, EnergyHi: 100},
+	"Liquid Drum and Bass": {BPMLo: 160, BPMHi: 180, EnergyLo: 25, EnergyHi: 85},
+	"Jungle":               {BPMLo: 155, BPMHi: 178, EnergyLo: 40, EnergyHi: 100},
+	"Dubstep":              {BPMLo: 135, BPMHi: 152, EnergyLo: 40, EnergyHi: 100},
+	"Breaks":               {BPMLo: 120, BPMHi: 142, EnergyLo: 30, EnergyHi: 95},
+	"Hard Dance":           {BPMLo: 140, BPMHi: 200, EnergyLo: 60, EnergyHi: 100},
+	"Disco":                {BPMLo: 100, BPMHi: 130, EnergyLo: 25, EnergyHi: 90},
+	"Downtempo":            {BPMLo: 60, BPMHi: 115, EnergyLo: 0, EnergyHi: 60},
+	"Ambient":              {BPMLo: 50, BPMHi: 120, EnergyLo: 0, EnergyHi: 40},
+	"Hip Hop":              {BPMLo: 60, BPMHi: 160, EnergyLo: 10, EnergyHi: 90},
+	"R&B":                  {BPMLo: 60, BPMHi: 120, EnergyLo: 5, EnergyHi: 80},
+	"Pop":                  {BPMLo: 70, BPMHi: 135, EnergyLo: 15, EnergyHi: 95},
+	"Rock":                 {BPMLo: 70, BPMHi: 190, EnergyLo: 15, EnergyHi: 100},
+	"Soul":                 {BPMLo: 65, BPMHi: 135, EnergyLo: 10, EnergyHi: 90},
+	"Latin":                {BPMLo: 80, BPMHi: 130, EnergyLo: 20, EnergyHi: 95},
+	"Salsa":                {BPMLo: 150, BPMHi: 220, EnergyLo: 40, EnergyHi: 95},
+	"Reggae":               {BPMLo: 60, BPMHi: 110, EnergyLo: 10, EnergyHi: 85},
+	"Afrobeats":            {BPMLo: 95, BPMHi: 125, EnergyLo: 20, EnergyHi: 90},
+	"Jazz":                 {BPMLo: 50, BPMHi: 260, EnergyLo: 0, EnergyHi: 80},
+	"Country":              {BPMLo: 60, BPMHi: 180, EnergyLo: 5, EnergyHi: 85},
+	"Folk":                 {BPMLo: 50, BPMHi: 160, EnergyLo: 0, EnergyHi: 60},
+	"Classical":            {BPMLo: 30, BPMHi: 200, EnergyLo: 0, EnergyHi: 70},
+	"Electronic":           {BPMLo: 90, BPMHi: 160, EnergyLo: 20, EnergyHi: 100},
+}
+
+// Misfit is a track whose tempo or energy looks implausible for its genre. Severity is
+// "implausible" when both miss, or one misses far, and "doubtful" otherwise; Reasons
+// says what misses, in the order tempo, energy.
+type Misfit struct {
+	Index    int    // into the tracks given to GenreMisfits
+	Genre    string // the genre whose profile the track was judged against
+	Profile  GenreProfile
+	Severity string
+	Reasons  []string
+}
+
+// GenreMisfits judges each track against the profile of its genre (resolved through
+// WithGenres's taxonomy) and lists, in track order, the ones that miss it: a tempo
+// outside the genre's range, an energy outside its range, or both, as a 78 BPM techno
+// track tagged energy 12 would. A tempo that fits at half or double time still
+// misses, as a tag read at the wrong time, but not far. Tracks with no genre, or one
+// without a profile, aren't judged, nor are an unknown BPM and an inferred energy. A
+// library tagged on a 1-10 energy scale is read as tenths of 100.
+func GenreMisfits(ctx context.Context, tracks []track.Track) []Misfit {
+	genres := genresFromContext(ctx)
+	scale := 10
+	for _, t := range tracks {
+		if !t.EnergyInferred && t.Energy > 10 {
+			scale = 1
+			break
+		}
+	}
+	var out []Misfit
+	for i, t := range tracks {
+		name, p, ok := genreProfile(genres.Normalize(t.Genre), genres.Family(t.Genre))
+		if !ok {
+			continue
+		}
+		m := Misfit{Index: i, Genre: name, Profile: p}
+		far := false
+		if t.BPM > 0 && (t.BPM < p.BPMLo || t.BPM > p.BPMHi) {
+			switch {
+			case 2*t.BPM >= p.BPMLo && 2*t.BPM <= p.BPMHi:
+				m.Reasons = append(m.Reasons, fmt.Sprintf("%g BPM is slow for %s (%g-%g), perhaps read at half time", t.BPM, name, p.BPMLo, p.BPMHi))
+			case t.BPM/2 >= p.BPMLo && t.BPM/2 <= p.BPMHi:
+				m.Reasons = append(m.Reasons, fmt.Sprintf("%g BPM is fast for %s (%g-%g), perhaps read at double time", t.BPM, name, p.BPMLo, p.BPMHi))
+			default:
+				far = true
+				m.Reasons = append(m.Reasons, fmt.Sprintf("%g BPM is outside %s's %g-%g", t.BPM, name, p.BPMLo, p.BPMHi))
+			}
+		}
+		if energy := t.Energy * scale; !t.EnergyInferred && (energy < p.EnergyLo || energy > p.EnergyHi) {
+			miss := max(p.EnergyLo-energy, energy-p.EnergyHi)
+			far = far || miss >= genreEnergyFar
+			word := "low"
+			if energy > p.EnergyHi {
+				word = "high"
+			}
+			m.Reasons = append(m.Reasons, fmt.Sprintf("energy %d is %s for %s (%d-%d)", t.Energy, word, name, p.EnergyLo/scale, p.EnergyHi/scale))
+		}
+		if len(m.Reasons) == 0 {
+			continue
+		}
+		m.Severity = "doubtful"
+		if far || len(m.Reasons) > 1 {
+			m.Severity = "implausible"
+		}
+		out = append(out, m)
+	}
+	return out
+}
+
+// genreProfile looks up the profile for a canonical genre name, falling back to its
+// family's, and says which it used.
+func genreProfile(name, family string) (string, GenreProfile, bool) {
+	if name == "" {
+		return "", GenreProfile{}, false
+	}
+	if p, ok := genreProfiles[name]; ok {
+		return name, p, true
+	}
+	p, ok := genreProfiles[family]
+	return family, p, ok
+}
@@ -0,0 +1,50 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestGenreMisfits(t *testing.T) {
+	tracks := []track.Track{
+		{Title: "fine", Genre: "Techno", BPM: 130, Energy: 70},
+		{Title: "slow and quiet", Genre: "Techno", BPM: 78, Energy: 12},
+		{Title: "half time", Genre: "Drum and Bass", BPM: 87, Energy: 70},
+		{Title: "calm", Genre: "Tech House", BPM: 124, Energy: 25},
+		{Title: "untagged", BPM: 40, Energy: 99},
+		{Title: "inferred", Genre: "Ambient", BPM: 80, Energy: 90, EnergyInferred: true},
+	}
+	got := GenreMisfits(context.Background(), tracks)
+	want := []struct {
+		index    int
+		genre    string
+		severity string
+		reason   string
+	}{
+		{1, "Techno", "implausible", "78 BPM is outside Techno's 118-150"},
+		{2, "Drum and Bass", "doubtful", "perhaps read at half time"},
+		{3, "House", "doubtful", "energy 25 is low for House (30-95)"},
+	}
+	if len(got) != len(want) {
+		t.Fatalf("GenreMisfits = %+v; want %d misfits", got, len(want))
+	}
+	for k, w := range want {
+		m := got[k]
+		if m.Index != w.index || m.Genre != w.genre || m.Severity != w.severity || !strings.Contains(strings.Join(m.Reasons, "; "), w.reason) {
+			t.Errorf("misfit %d = %+v; want track %d, %s, %s, %q", k, m, w.index, w.genre, w.severity, w.reason)
+		}
+	}
+	if len(got[0].Reasons) != 2 {
+		t.Errorf("reasons = %q; want both the tempo and the energy", got[0].Reasons)
+	}
+
+	// A library on a 1-10 scale is judged in tenths of 100.
+	tenths := []track.Track{{Genre: "Techno", BPM: 130, Energy: 7}, {Genre: "Techno", BPM: 130, Energy: 1}}
+	if got := GenreMisfits(context.Background(), tenths); len(got) != 1 || got[0].Index != 1 ||
+		!strings.Contains(got[0].Reasons[0], "(4-10)") {
+		t.Errorf("GenreMisfits on a 1-10 scale = %+v; want only the energy-1 track, against 4-10", got)
+	}
+}
@@ -0,0 +1,106 @@
+package strategy_test
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// syntheticLibrary makes n tracks spread over every key, a 100-140 BPM range, and
+// the full energy range, the same for a given n.
+func syntheticLibrary(n int) []track.Track {
+	r := rand.New(rand.NewSource(int64(n)))
+	tracks := make([]track.Track, n)
+	for i := range tracks {
+		mode := track.ModeA
+		if r.Intn(2) == 0 {
+			mode = track.ModeB
+		}
+		tracks[i] = track.Track{
+			Title:  fmt.Sprintf("Track %d", i),
+			Artist: fmt.Sprintf("Artist %d", r.Intn(n/4+1)),
+			BPM:    100 + float64(r.Intn(400))/10,
+			Energy: 10 + r.Intn(90),
+			Key:    track.Key{Number: 1 + r.Intn(12), Mode: mode},
+		}
+	}
+	return tracks
+}
+
+// BenchmarkDefaultSorterScaling sorts ever larger libraries. Each placement fully
+// scores only the few tracks of the keys its category needs that sit near the tempo
+// and energy it wants; the walk past the rest of a key's tempo neighbours is cheap but
+// still grows with the library, so ns/track rises slowly rather than staying flat.
+func BenchmarkDefaultSorterScaling(b *testing.B) {
+	for _, n := range []int{1000, 4000, 16000} {
+		tracks := syntheticLibrary(n)
+		b.Run(fmt.Sprintf("tracks=%d", n), func(b *testing.B) {
+			ctx := strategy.WithSeed(context.Background(), 1)
+			for b.Loop() {
+				if _, err := strategy.NewDefaultSorter().Sort(ctx, cloneTracks(tracks)); err != nil {
+					b.Fatal(err)
+				}
+			}
+			b.ReportMetric(float64(b.Elapsed().Nanoseconds())/float64(b.N*n), "ns/track")
+		})
+	}
+}
+
+// TestDefaultSorterIndexedMatchesFullScan checks that the key and tempo index finds
+// the same order as scoring every track, whatever bounds the tracks it skips:
+// recording decisions forces the full scan.
+func TestDefaultSorterIndexedMatchesFullScan(t *testing.T) {
+	tracks := syntheticLibrary(600)
+	for i := range tracks {
+		switch i % 7 {
+		case 0:
+			tracks[i].Genre = "Trance"
+		case 1:
+			tracks[i].Genre = "Hip Hop"
+			tracks[i].BPM /= 2
+		case 2:
+			priority := 1 + i%5
+			tracks[i].Priority = &priority
+		case 3:
+			if i%3 == 0 {
+				tracks[i].Tags = []string{track.AnyKeyTag}
+			}
+		}
+	}
+	build, err := strategy.ParseArc("build")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tuning := strategy.DefaultTuning
+	tuning.GenreBPMTolerance = map[string]float64{"Trance": 3, "Hip Hop": 12}
+	base := strategy.WithSeed(context.Background(), 7)
+
+	for name, ctx := range map[string]context.Context{
+		"default":     base,
+		"half-double": strategy.WithTempoMatch(strategy.WithTuning(base, tuning), track.TempoHalfDouble),
+		"arc":         strategy.WithArc(base, build),
+		"pins": strategy.WithPins(base, strategy.Pin{
+			Match: func(t track.Track) bool { return t.Title == "Track 42" }, Position: 30,
+		}),
+	} {
+		t.Run(name, func(t *testing.T) {
+			indexed, err := strategy.NewDefaultSorter().Sort(ctx, cloneTracks(tracks))
+			if err != nil {
+				t.Fatalf("Sort returned error: %v", err)
+			}
+			scanned, err := strategy.NewDefaultSorter().Sort(strategy.WithDecisionRecorder(ctx, func(strategy.Decision) {}), cloneTracks(tracks))
+			if err != nil {
+				t.Fatalf("Sort with recorder returned error: %v", err)
+			}
+			for i := range indexed {
+				if indexed[i].Title != scanned[i].Title {
+					t.Fatalf("orders diverge at %d: %q vs %q", i, indexed[i].Title, scanned[i].Title)
+				}
+			}
+		})
+	}
+}
@@ -13,8 +13,9 @@ import (
 // The score has two families:
 //
 //   - Coherence (pairwise): does each song feel related to its neighbor? Harmonic
-//     Camelot compatibility and octave-folded tempo are always active; valence (mood)
-//     and acousticness continuity are added when the data provides them.
+//     Camelot compatibility and octave-folded tempo are always active; valence (mood),
+//     acousticness continuity, and phrase-structure agreement are added when the data
+//     provides them.
 //
 //   - Contour (global): does the whole set have a satisfying energy shape? Intensity
 //     (energy, blended with danceability when present) should move in waves —
@@ -34,6 +35,7 @@ type Weights struct {
 	Tempo    float64
 	Valence  float64
 	Acoustic float64
+	Phrase   float64
 	Contour  float64
 }
 
@@ -43,6 +45,7 @@ var DefaultWeights = Weights{
 	Tempo:    1.0,
 	Valence:  0.5,
 	Acoustic: 0.25,
+	Phrase:   1.0,
 	Contour:  1.0,
 }
 
@@ -78,6 +81,7 @@ type MixScore struct {
 	TempoTotal    float64
 	ValenceTotal  float64
 	AcousticTotal float64
+	PhraseTotal   float64
 	ContourTotal  float64
 
 	Contour ContourStats
@@ -101,13 +105,21 @@ type TransitionDetail struct {
 	Index     int
 	FromTitle string
 	ToTitle   string
-	FromKey   track.Key
-	ToKey     track.Key
-	Harmonic  float64
-	Tempo     float64
-	Valence   float64
-	Acoustic  float64
-	Pairwise  float64
+	// Transition is the key move: the exit key of one track into the entry key of the
+	// next.
+	Transition
+
+	Harmonic float64
+	Tempo    float64
+	Valence  float64
+	Acoustic float64
+	Phrase   float64
+	Pairwise float64
+
+	// FromPhrase and ToPhrase are the bars per phrase on each side (0 when unknown),
+	// so reports can say how to line the transition up.
+	FromPhrase int
+	ToPhrase   int
 }
 
 // ScoreMix scores an ordering with the default weights.
@@ -124,24 +136,14 @@ func ScoreMixWith(tracks []track.Track, w Weights) MixScore {
 
 	details := make([]TransitionDetail, 0, len(tracks)-1)
 	for i := 0; i+1 < len(tracks); i++ {
-		a, b := tracks[i], tracks[i+1]
-		d := TransitionDetail{
-			Index:     i,
-			FromTitle: a.Title,
-			ToTitle:   b.Title,
-			FromKey:   a.Key,
-			ToKey:     b.Key,
-			Harmonic:  w.Harmonic * harmonicCost(a.Key, b.Key),
-			Tempo:     w.Tempo * tempoCost(a.BPM, b.BPM),
-			Valence:   w.Valence * valenceCost(a, b),
-			Acoustic:  w.Acoustic * acousticCost(a, b),
-		}
-		d.Pairwise = d.Harmonic + d.Tempo + d.Valence + d.Acoustic
+		d := transitionDetail(tracks[i], tracks[i+1], w)
+		d.Index = i
 
 		score.HarmonicTotal += d.Harmonic
 		score.TempoTotal += d.Tempo
 		score.ValenceTotal += d.Valence
 		score.AcousticTotal += d.Acoustic
+		score.PhraseTotal += d.Phrase
 		details = append(details, d)
 	}
 
@@ -151,7 +153,7 @@ func ScoreMixWith(tracks []track.Track, w Weights) MixScore {
 
 	score.Transitions = len(details)
 	score.Total = score.HarmonicTotal + score.TempoTotal + score.ValenceTotal +
-		score.AcousticTotal + score.ContourTotal
+		score.AcousticTotal + score.PhraseTotal + score.ContourTotal
 	if score.Transitions > 0 {
 		score.PerTrack = score.Total / float64(len(tracks))
 	}
@@ -159,12 +161,59 @@ func ScoreMixWith(tracks []track.Track, w Weights) MixScore {
 	return score
 }
 
+// transitionDetail breaks down the coherence cost of playing b after a.
+func transitionDetail(a, b track.Track, w Weights) TransitionDetail {
+	trans := NewTransition(a, b)
+	d := TransitionDetail{
+		FromTitle:  a.Title,
+		ToTitle:    b.Title,
+		Transition: trans,
+		Harmonic:   w.Harmonic * harmonicCost(trans),
+		Tempo:      w.Tempo * tempoCost(a.ExitBPM(), b.EntryBPM()),
+		Valence:    w.Valence * valenceCost(a, b),
+		Acoustic:   w.Acoustic * acousticCost(a, b),
+		Phrase:     w.Phrase * phraseCost(a, b),
+	}
+	if a.Phrase != nil {
+		d.FromPhrase = *a.Phrase
+	}
+	if b.Phrase != nil {
+		d.ToPhrase = *b.Phrase
+	}
+	d.Pairwise = d.Harmonic + d.Tempo + d.Valence + d.Acoustic + d.Phrase
+	return d
+}
+
+// PairwiseCosts returns the coherence cost of every ordered pair of tracks: the cost of
+// playing tracks[j] right after tracks[i] is at [i][j], and the diagonal is 0. These are
+// the pairwise terms of the mix score; the contour term depends on a whole ordering and
+// is not included.
+func PairwiseCosts(tracks []track.Track, w Weights) [][]float64 {
+	costs := make([][]float64, len(tracks))
+	for i, a := range tracks {
+		costs[i] = make([]float64, len(tracks))
+		for j, b := range tracks {
+			if i != j {
+				costs[i][j] = coherenceCost(a, b, w)
+			}
+		}
+	}
+	return costs
+}
+
+// TransitionCost is the pairwise cost of playing b right after a: one transition's
+// share of the mix score, before the contour term.
+func TransitionCost(a, b track.Track, w Weights) float64 {
+	return coherenceCost(a, b, w)
+}
+
 // coherenceCost is the pairwise cost of playing b after a.
 func coherenceCost(a, b track.Track, w Weights) float64 {
-	return w.Harmonic*harmonicCost(a.Key, b.Key) +
-		w.Tempo*tempoCost(a.BPM, b.BPM) +
+	return w.Harmonic*harmonicCost(NewTransition(a, b)) +
+		w.Tempo*tempoCost(a.ExitBPM(), b.EntryBPM()) +
 		w.Valence*valenceCost(a, b) +
-		w.Acoustic*acousticCost(a, b)
+		w.Acoustic*acousticCost(a, b) +
+		w.Phrase*phraseCost(a, b)
 }
 
 // mixTotal computes just the total score of an ordering (no reporting breakdown). It
@@ -184,16 +233,14 @@ func mixTotal(tracks []track.Track, w Weights) float64 {
 }
 
 // harmonicCost scores Camelot compatibility. Clockwise steps (+1, +2) are favored
-// over the counter-clockwise (-1) move, matching standard harmonic-mixing practice.
-func harmonicCost(a, b track.Key) float64 {
-	d := b.Number - a.Number
-	for d > 6 {
-		d -= 12
-	}
-	for d < -6 {
-		d += 12
+// over the counter-clockwise (-1) move, matching standard harmonic-mixing practice. A
+// move into or out of a key-agnostic track costs nothing.
+func harmonicCost(t Transition) float64 {
+	if t.AnyKey {
+		return 0
 	}
-	modeChange := a.Mode != b.Mode
+	d := t.Offset()
+	modeChange := t.ModeChange
 
 	switch {
 	case d == 0 && !modeChange:
@@ -228,13 +275,18 @@ func harmonicCost(a, b track.Key) float64 {
 // tempoCost folds tempo onto the octave circle so half/double-time pairs are treated
 // as close, then costs the residual percentage difference.
 func tempoCost(a, b float64) float64 {
+	return math.Min(1.5, tempoStretch(a, b)/10.0)
+}
+
+// tempoStretch is how far, in percent, one tempo has to be pitched to meet the other
+// once folded onto the octave circle; 0 when either is unknown.
+func tempoStretch(a, b float64) float64 {
 	if a <= 0 || b <= 0 {
 		return 0
 	}
 	octaves := math.Log2(b / a)
 	octaves -= math.Round(octaves) // fold to [-0.5, 0.5]
-	pctDiff := math.Abs(math.Exp2(octaves)-1) * 100
-	return math.Min(1.5, pctDiff/10.0)
+	return math.Abs(math.Exp2(octaves)-1) * 100
 }
 
 // valenceCost penalizes large mood swings when both tracks report valence.
@@ -253,11 +305,36 @@ func acousticCost(a, b track.Track) float64 {
 	return math.Min(0.8, math.Abs(float64(*a.Acousticness-*b.Acousticness))/70.0)
 }
 
+// phraseCost slightly penalizes mixing between mismatched phrase structures when both
+// tracks report them: nested lengths (16 into 32 bars) still line up at every longer
+// phrase, so they cost less than lengths that drift (16 into 24).
+func phraseCost(a, b track.Track) float64 {
+	if a.Phrase == nil || b.Phrase == nil || *a.Phrase <= 0 || *b.Phrase <= 0 {
+		return 0
+	}
+	pa, pb := *a.Phrase, *b.Phrase
+	switch {
+	case pa == pb:
+		return 0
+	case pa%pb == 0 || pb%pa == 0:
+		return 0.05
+	default:
+		return 0.15
+	}
+}
+
 // contourPenalty scores the global intensity shape. Builds (rising runs) should be
 // gradual; resets (drops beyond contourResetDrop) that follow a qualifying build are
 // free; jittery dips, over-large leaps, and a reset count outside [minResets,
 // maxResets] (the target wave cadence) are penalized. The ending is not scored.
 func contourPenalty(vals []float64, minResets, maxResets int) ContourStats {
+	return contourWalk(vals, minResets, maxResets, nil)
+}
+
+// contourWalk is contourPenalty, also calling onStep (when non-nil) with the
+// smoothness or jitter-reset cost of each step i → i+1 that has one. The wave-count
+// penalty belongs to the whole set and is not passed to onStep.
+func contourWalk(vals []float64, minResets, maxResets int, onStep func(i int, cost float64)) ContourStats {
 	stats := ContourStats{TargetResetLo: minResets, TargetResetHi: maxResets}
 	n := len(vals)
 	if n < 3 {
@@ -278,21 +355,29 @@ func contourPenalty(vals []float64, minResets, maxResets int) ContourStats {
 			rise := vals[i] - vals[buildStart]
 			if runLen < contourMinRunLen || rise < contourMinRunRise {
 				resetPenalty += contourJitterReset
+				if onStep != nil {
+					onStep(i, contourJitterReset)
+				}
 			}
 			resets++
 			buildStart = i + 1
 			continue
 		}
 
+		step := 0.0
 		switch {
 		case d >= contourStepLo && d <= contourStepHi:
 			// ideal gentle build step
 		case d > contourStepHi:
-			smoothness += (d - contourStepHi) / contourBigJumpDiv
+			step = (d - contourStepHi) / contourBigJumpDiv
 		case d >= -contourDipTol && d < contourStepLo:
-			smoothness += contourFlatPenalty
+			step = contourFlatPenalty
 		default: // dip within a build (jitter)
-			smoothness += (-d - contourDipTol) / contourDipDiv
+			step = (-d - contourDipTol) / contourDipDiv
+		}
+		smoothness += step
+		if onStep != nil && step > 0 {
+			onStep(i, step)
 		}
 	}
 
@@ -358,6 +443,12 @@ func intensity(t track.Track) float64 {
 	return e
 }
 
+// IsReset reports whether playing b after a is an energy reset: an intensity drop big
+// enough to start a new build.
+func IsReset(a, b track.Track) bool {
+	return intensity(a)-intensity(b) >= contourResetDrop
+}
+
 func intensities(tracks []track.Track) []float64 {
 	vals := make([]float64, len(tracks))
 	for i, t := range tracks {
@@ -373,7 +464,7 @@ func activeSignals(tracks []track.Track) []string {
 	if len(tracks) == 0 {
 		return signals
 	}
-	var dance, valence, acoustic int
+	var dance, valence, acoustic, phrase int
 	for _, t := range tracks {
 		if t.Danceability != nil {
 			dance++
@@ -384,6 +475,9 @@ func activeSignals(tracks []track.Track) []string {
 		if t.Acousticness != nil {
 			acoustic++
 		}
+		if t.Phrase != nil {
+			phrase++
+		}
 	}
 	half := len(tracks) / 2
 	if dance > half {
@@ -395,6 +489,9 @@ func activeSignals(tracks []track.Track) []string {
 	if acoustic > half {
 		signals = append(signals, "acousticness")
 	}
+	if phrase > half {
+		signals = append(signals, "phrase")
+	}
 	return signals
 }
 
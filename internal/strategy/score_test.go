@@ -1,10 +1,12 @@
 package strategy
 
 import (
+	"context"
 	"math"
 	"slices"
 	"testing"
 
+	"github.com/YakDriver/magicmix/internal/genre"
 	"github.com/YakDriver/magicmix/internal/track"
 )
 
@@ -121,3 +123,106 @@ func TestScoreAdaptsToAvailableSignals(t *testing.T) {
 func containsSignal(signals []string, want string) bool {
 	return slices.Contains(signals, want)
 }
+
+// A variable-tempo track is entered at its start tempo and left at its end tempo, so
+// the build 100→128 belongs between a 100 BPM track and a 128 BPM one — not reversed.
+func TestTempoRangeMatchesEntryAndExit(t *testing.T) {
+	key := track.Key{Number: 8, Mode: track.ModeA}
+	slow := track.Track{Title: "slow", BPM: 100, Energy: 50, Key: key}
+	build := track.Track{Title: "build", BPM: 100, BPMEnd: 128, Energy: 60, Key: key}
+	fast := track.Track{Title: "fast", BPM: 128, Energy: 70, Key: key}
+
+	forward := ScoreMix([]track.Track{slow, build, fast}).TempoTotal
+	backward := ScoreMix([]track.Track{fast, build, slow}).TempoTotal
+	if forward != 0 {
+		t.Fatalf("slow -> build -> fast tempo cost = %.2f, want 0", forward)
+	}
+	if backward <= forward {
+		t.Fatalf("reversed order should cost more: %.2f <= %.2f", backward, forward)
+	}
+}
+
+// A track that modulates is left from its last key: after 8A/3A, a 4A is one step up,
+// not the four steps it would be from 8A.
+func TestComputeTransitionUsesExitKey(t *testing.T) {
+	modulating := track.Track{
+		Key:         track.Key{Number: 8, Mode: track.ModeA},
+		Modulations: []track.Key{{Number: 3, Mode: track.ModeA}},
+	}
+	state := &mixState{prev: modulating, prevSet: true}
+	trans := computeTransition(state, track.Track{Key: track.Key{Number: 4, Mode: track.ModeA}})
+	if trans.Steps != 1 || trans.Wrap || trans.ModeChange {
+		t.Fatalf("transition from 8A/3A to 4A = %+v, want one step up", trans)
+	}
+}
+
+// With half/double time on, 87 into 174 is as smooth as 87 into 87.
+func TestBPMTransitionCostTempoMatch(t *testing.T) {
+	from, to := track.Track{BPM: 87}, track.Track{BPM: 174}
+	direct := bpmTransitionCost(&mixState{prev: from, prevSet: true}, to, mixStats{})
+	folded := bpmTransitionCost(&mixState{prev: from, prevSet: true, tempoMatch: track.TempoHalfDouble}, to, mixStats{})
+	if folded != 0 || direct <= folded {
+		t.Fatalf("87 -> 174 costs %.2f direct, %.2f at half/double time; want 0 folded", direct, folded)
+	}
+	if got := tempoMatchFromContext(WithTempoMatch(context.Background(), track.TempoThreeFour)); got != track.TempoThreeFour {
+		t.Fatalf("tempo match from context = %q", got)
+	}
+}
+
+func TestGenreBPMTolerance(t *testing.T) {
+	rap := track.Track{Genre: "Rap", BPM: 90}
+	trance := track.Track{Genre: "uplifting trance", BPM: 138}
+	house := track.Track{Genre: "House", BPM: 124}
+	tol := genreBPMTolerance([]track.Track{rap, trance, house},
+		map[string]float64{"hip-hop": 12, "Uplifting Trance": 3}, genre.Default())
+	if tol["Rap"] != 12 || tol["uplifting trance"] != 3 {
+		t.Fatalf("tolerances = %v; want Rap from its family and trance by name", tol)
+	}
+	if _, ok := tol["House"]; ok {
+		t.Fatalf("House has no tolerance, got %v", tol)
+	}
+
+	cost := func(from, to track.Track) float64 {
+		return bpmTransitionCost(&mixState{prev: from, prevSet: true, bpmTolerance: tol}, to, mixStats{})
+	}
+	plain := cost(track.Track{BPM: 90}, track.Track{BPM: 100})
+	loose := cost(rap, track.Track{Genre: "Rap", BPM: 100})
+	tight := cost(trance, track.Track{Genre: "uplifting trance", BPM: 148})
+	if !(loose < plain && plain < tight) {
+		t.Fatalf("a 10 BPM jump costs %.2f in hip hop, %.2f untagged, %.2f in trance", loose, plain, tight)
+	}
+	if mixed := cost(rap, track.Track{Genre: "uplifting trance", BPM: 100}); mixed != tight {
+		t.Fatalf("hip hop into trance should use trance's strictness: %.2f vs %.2f", mixed, tight)
+	}
+}
+
+func TestPhraseCostPrefersMatchingStructures(t *testing.T) {
+	p := func(bars int) track.Track { return track.Track{Phrase: &bars} }
+	same, nested, drift := phraseCost(p(16), p(16)), phraseCost(p(16), p(32)), phraseCost(p(16), p(24))
+	if !(same == 0 && same < nested && nested < drift) {
+		t.Fatalf("phrase costs same %.2f nested %.2f drift %.2f; want 0 < nested < drift", same, nested, drift)
+	}
+	if c := phraseCost(track.Track{}, p(32)); c != 0 {
+		t.Fatalf("unknown phrase should cost nothing, got %.2f", c)
+	}
+}
+
+// The planner and the score must read a transition the same way.
+func TestTransitionSharedByPlannerAndScore(t *testing.T) {
+	from := track.Track{Title: "a", Key: track.Key{Number: 11, Mode: track.ModeA}}
+	to := track.Track{Title: "b", Key: track.Key{Number: 1, Mode: track.ModeB}}
+
+	want := Transition{FromKey: from.Key, ToKey: to.Key, Steps: 2, Wrap: true, ModeChange: true}
+	if got := NewTransition(from, to); got != want {
+		t.Fatalf("NewTransition = %+v, want %+v", got, want)
+	}
+	if got := computeTransition(&mixState{prev: from, prevSet: true}, to); got != want {
+		t.Fatalf("planner transition = %+v, want %+v", got, want)
+	}
+	if got := transitionDetail(from, to, DefaultWeights).Transition; got != want {
+		t.Fatalf("scored transition = %+v, want %+v", got, want)
+	}
+	if off := want.Offset(); off != 2 {
+		t.Fatalf("Offset = %d, want 2", off)
+	}
+}
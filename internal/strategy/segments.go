@@ -0,0 +1,96 @@
+package strategy
+
+import (
+	"fmt"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// DefaultSegments splits a set into opening, middle, and close.
+const DefaultSegments = 3
+
+// SegmentScore is the share of a mix score that falls in one stretch of the set, so a
+// report can show where the penalties pile up. Each transition belongs to the segment
+// its outgoing track is in, and so does the contour cost of that step. The contour's
+// wave-count term judges the whole set and is left out, so segment totals add up to
+// the mix total less MixScore.Contour.WaveCountPenalty (weighted).
+type SegmentScore struct {
+	Name        string // "opening", "middle", "close" for thirds; "1/4" … otherwise
+	First, Last int    // the segment's tracks, 0-based and inclusive
+	Transitions int
+
+	Harmonic float64
+	Tempo    float64
+	Valence  float64
+	Acoustic float64
+	Phrase   float64
+	Contour  float64
+	Total    float64
+
+	// PerTransition is Total spread over the segment's transitions, for comparing
+	// segments of different lengths.
+	PerTransition float64
+
+	// Roughest is the segment's costliest transition; nil when it has none.
+	Roughest *TransitionDetail
+}
+
+// ScoreSegments splits an ordering into n segments of as equal a track count as
+// possible and scores each with ScoreMixWith's terms. n is capped at the number of
+// tracks; it returns nil for fewer than two tracks or n < 1.
+func ScoreSegments(tracks []track.Track, w Weights, n int) []SegmentScore {
+	if len(tracks) <= 1 || n < 1 {
+		return nil
+	}
+	n = min(n, len(tracks))
+	segs := make([]SegmentScore, n)
+	of := make([]int, len(tracks)) // segment of each track
+	for k := range segs {
+		segs[k].Name = segmentName(k, n)
+		segs[k].First = k * len(tracks) / n
+		segs[k].Last = (k+1)*len(tracks)/n - 1
+		for i := segs[k].First; i <= segs[k].Last; i++ {
+			of[i] = k
+		}
+	}
+
+	details := make([]TransitionDetail, len(tracks)-1)
+	for i := range details {
+		d := transitionDetail(tracks[i], tracks[i+1], w)
+		d.Index = i
+		details[i] = d
+		s := &segs[of[i]]
+		s.Transitions++
+		s.Harmonic += d.Harmonic
+		s.Tempo += d.Tempo
+		s.Valence += d.Valence
+		s.Acoustic += d.Acoustic
+		s.Phrase += d.Phrase
+		if s.Roughest == nil || d.Pairwise > s.Roughest.Pairwise {
+			s.Roughest = &details[i]
+		}
+	}
+	minResets, maxResets := waveResetBand(tracks)
+	contourWalk(intensities(tracks), minResets, maxResets, func(i int, cost float64) {
+		segs[of[i]].Contour += w.Contour * cost
+	})
+
+	for k := range segs {
+		s := &segs[k]
+		s.Total = s.Harmonic + s.Tempo + s.Valence + s.Acoustic + s.Phrase + s.Contour
+		if s.Transitions > 0 {
+			s.PerTransition = s.Total / float64(s.Transitions)
+		}
+		if s.Roughest != nil && s.Roughest.Pairwise <= 0 {
+			s.Roughest = nil
+		}
+	}
+	return segs
+}
+
+func segmentName(k, n int) string {
+	if n == 3 {
+		return [...]string{"opening", "middle", "close"}[k]
+	}
+	return fmt.Sprintf("%d/%d", k+1, n)
+}
@@ -0,0 +1,55 @@
+package strategy
+
+import (
+	"math"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// TestScoreSegmentsLocatesPenalties builds a set that flows in its first two thirds
+// and clashes in the last, and checks the close carries the cost and the segments add
+// up to the whole-set score less the wave-count term.
+func TestScoreSegmentsLocatesPenalties(t *testing.T) {
+	var tracks []track.Track
+	for i := range 6 {
+		tracks = append(tracks, track.Track{Title: "smooth", BPM: 124, Energy: 50 + 4*i,
+			Key: track.Key{Number: 8, Mode: track.ModeA}})
+	}
+	for i := range 3 {
+		tracks = append(tracks, track.Track{Title: "clash", BPM: float64(100 + 30*(i%2)), Energy: 90 - 30*(i%2),
+			Key: track.Key{Number: 1 + 5*i, Mode: track.ModeB}})
+	}
+
+	segs := ScoreSegments(tracks, DefaultWeights, DefaultSegments)
+	if len(segs) != 3 || segs[0].Name != "opening" || segs[2].Name != "close" {
+		t.Fatalf("want opening, middle, close; got %+v", segs)
+	}
+	if segs[0].First != 0 || segs[2].Last != len(tracks)-1 || segs[1].First != segs[0].Last+1 {
+		t.Errorf("segments don't tile the set: %+v", segs)
+	}
+	if segs[2].Total <= segs[0].Total+segs[1].Total {
+		t.Errorf("the close should carry the penalties: opening %.2f middle %.2f close %.2f",
+			segs[0].Total, segs[1].Total, segs[2].Total)
+	}
+	if segs[2].Roughest == nil || segs[2].Roughest.FromTitle != "clash" {
+		t.Errorf("close's roughest transition = %+v", segs[2].Roughest)
+	}
+
+	sum := 0.0
+	for _, s := range segs {
+		sum += s.Total
+	}
+	score := ScoreMix(tracks)
+	want := score.Total - DefaultWeights.Contour*score.Contour.WaveCountPenalty
+	if math.Abs(sum-want) > 1e-9 {
+		t.Errorf("segments sum to %.4f, want %.4f", sum, want)
+	}
+
+	if got := ScoreSegments(tracks, DefaultWeights, 4); got[3].Name != "4/4" {
+		t.Errorf("four segments should be named n/4, got %q", got[3].Name)
+	}
+	if ScoreSegments(tracks[:1], DefaultWeights, 3) != nil {
+		t.Error("a single track has no segments to score")
+	}
+}
@@ -0,0 +1,149 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// separationUnit is the penalty for two same-group tracks placed back to back; it
+// tapers linearly to zero at the rule's MinGap. It is deliberately larger than any
+// single coherence cost so the optimizer treats separation as a near-hard rule.
+const separationUnit = 3.0
+
+// softSeparationUnit is separationUnit for a Soft rule: half a middling transition's
+// coherence cost, so it breaks up a run only where another order mixes about as well.
+const softSeparationUnit = 0.5
+
+// Separation asks that tracks sharing a group — versions of one song, one artist, one
+// album — sit at least MinGap positions apart. Group returns "" for tracks the rule
+// does not apply to. Groups, when set instead, resolves a whole list at once, for
+// groups found only by comparing tracks, such as song families, and for a track in
+// several groups, such as a collaboration under each of its artists; two tracks
+// conflict when they share any group. A Soft rule is a mild preference weighed against
+// the mix score (see softRule) rather than a near-hard rule.
+type Separation struct {
+	Name   string
+	Group  func(track.Track) string
+	Groups func([]track.Track) [][]string
+	MinGap int
+	Soft   bool
+}
+
+// groupsOf resolves the rule against tracks: the groups each track is in.
+func (s Separation) groupsOf(tracks []track.Track) [][]string {
+	if s.Groups != nil {
+		return s.Groups(tracks)
+	}
+	groups := make([][]string, len(tracks))
+	for i, t := range tracks {
+		if g := s.Group(t); g != "" {
+			groups[i] = []string{g}
+		}
+	}
+	return groups
+}
+
+const separationContextKey contextKey = "strategy.separation"
+
+// WithSeparation adds separation rules to the context. Sort repairs any ordering that
+// breaks them, and the flow strategy optimizes them directly.
+func WithSeparation(ctx context.Context, rules ...Separation) context.Context {
+	if len(rules) == 0 {
+		return ctx
+	}
+	all := append(append([]Separation(nil), separationFromContext(ctx)...), rules...)
+	return context.WithValue(ctx, separationContextKey, all)
+}
+
+func separationFromContext(ctx context.Context) []Separation {
+	if ctx == nil {
+		return nil
+	}
+	if rules, ok := ctx.Value(separationContextKey).([]Separation); ok {
+		return rules
+	}
+	return nil
+}
+
+// boundSeparation is a set of separation rules resolved against one track list.
+type boundSeparation struct {
+	rules  []Separation
+	groups [][][]int // per rule, per track: the ids of its groups, none when not grouped
+	unit   float64
+}
+
+// softSeparation is a boundSeparation of Soft rules.
+type softSeparation struct{ *boundSeparation }
+
+func (softSeparation) soft() {}
+
+// bindSeparation resolves each rule to per-track group ids so the penalty can be
+// evaluated over permutations without re-deriving group strings.
+func bindSeparation(tracks []track.Track, rules []Separation) *boundSeparation {
+	b := &boundSeparation{rules: rules, groups: make([][][]int, len(rules)), unit: separationUnit}
+	for r, rule := range rules {
+		ids := map[string]int{}
+		b.groups[r] = make([][]int, len(tracks))
+		for i, groups := range rule.groupsOf(tracks) {
+			for _, g := range groups {
+				id, ok := ids[g]
+				if !ok {
+					id = len(ids)
+					ids[g] = id
+				}
+				b.groups[r][i] = append(b.groups[r][i], id)
+			}
+		}
+	}
+	return b
+}
+
+// cost sums the penalty for every same-group pair closer than its rule's MinGap.
+func (b *boundSeparation) cost(perm []int) float64 {
+	total := 0.0
+	b.each(perm, func(_, _ int, r, d int) {
+		gap := b.rules[r].MinGap
+		total += b.unit * float64(gap-d) / float64(gap-1)
+	})
+	return total
+}
+
+func (b *boundSeparation) conflicts(perm []int, out []bool) {
+	b.each(perm, func(i, j int, _, _ int) {
+		out[i], out[j] = true, true
+	})
+}
+
+// each calls fn for every too-close same-group pair at positions i < j, d = j-i apart,
+// under rule r.
+func (b *boundSeparation) each(perm []int, fn func(i, j, r, d int)) {
+	for r, rule := range b.rules {
+		if rule.MinGap < 2 {
+			continue
+		}
+		ids := b.groups[r]
+		for i := range perm {
+			gi := ids[perm[i]]
+			if len(gi) == 0 {
+				continue
+			}
+			for d := 1; d < rule.MinGap && i+d < len(perm); d++ {
+				if sharesGroup(gi, ids[perm[i+d]]) {
+					fn(i, i+d, r, d)
+				}
+			}
+		}
+	}
+}
+
+// sharesGroup reports whether two tracks' group ids have one in common.
+func sharesGroup(a, b []int) bool {
+	for _, x := range a {
+		if slices.Contains(b, x) {
+			return true
+		}
+	}
+	return false
+}
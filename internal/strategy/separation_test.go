@@ -0,0 +1,47 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestAlbumSeparationSpreadsAnEP(t *testing.T) {
+	// Three tracks from one EP share a key, tempo, and energy, so the mix alone would
+	// play them back to back; the library has plenty of other 8A and 9A tracks.
+	var tracks []track.Track
+	for i := range 3 {
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("EP %d", i), Album: "Summer EP", BPM: 124, Energy: 60,
+			Key: track.Key{Number: 8, Mode: track.ModeA}})
+	}
+	for i := range 9 {
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("Other %d", i), BPM: 123 + float64(i%3), Energy: 40 + 5*i,
+			Key: track.Key{Number: 8 + i%2, Mode: track.ModeA}})
+	}
+	ctx := WithSeparation(WithSeed(context.Background(), 2), AlbumSeparation(3))
+
+	for _, name := range []string{defaultStrategyName, flowStrategyName} {
+		sorter, _ := Get(name)
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(res.Ordered) != len(tracks) {
+			t.Fatalf("%s: got %d tracks, want %d", name, len(res.Ordered), len(tracks))
+		}
+		for i := 1; i < len(res.Ordered); i++ {
+			if a, b := res.Ordered[i-1], res.Ordered[i]; a.Album != "" && a.Album == b.Album {
+				t.Errorf("%s: %s and %s from one EP play back to back", name, a.Title, b.Title)
+			}
+		}
+		if len(res.Warnings) > 0 {
+			t.Errorf("%s: a soft preference warned: %v", name, res.Warnings)
+		}
+	}
+
+	if rules := bindRules(ctx, tracks); len(rules) != 1 || ruleConflicts(rules, len(tracks)) != 0 {
+		t.Errorf("album separation should bind as one soft rule, never counted as a broken rule")
+	}
+}
@@ -0,0 +1,103 @@
+package strategy
+
+import (
+	"context"
+	"errors"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Simulation is the projected outcome of playing a track next: that track followed by
+// the default planner's own picks for the next few slots.
+type Simulation struct {
+	Steps []SimStep
+	State DecisionState // planner state after the last projected pick
+	Total float64       // score of played + Steps under the shared scoring model
+	Delta float64       // Total minus the score of played alone
+}
+
+// SimStep is one projected pick. Transition is the coherence breakdown from the
+// previous track (zero for the opening pick); Delta is how much the pick changes the
+// whole-set score, contour included.
+type SimStep struct {
+	Track      track.Track
+	Transition TransitionDetail
+	Delta      float64
+}
+
+// Simulate answers "what if I play next now?": given the tracks played so far and the
+// pool still available, it plays next, then lets the default planner choose up to
+// horizon further tracks from the pool, reporting the projected state and the score
+// change at each step. next may or may not be in pool; neither input is modified.
+// Comparing Delta across candidates ranks them by their effect on the set.
+func Simulate(ctx context.Context, played, pool []track.Track, next track.Track, horizon int) (Simulation, error) {
+	if horizon < 0 {
+		return Simulation{}, errors.New("horizon must be non-negative")
+	}
+
+	all := make([]track.Track, 0, len(played)+len(pool)+1)
+	all = append(all, played...)
+	all = append(all, pool...)
+	if findTrack(pool, next) < 0 {
+		all = append(all, next)
+	}
+	p := newMixPlanner(ctx, all, len(all))
+	p.recorder = nil // a projection is not part of any logged run
+
+	var state mixState
+	seq := make([]track.Track, 0, len(played)+horizon+1)
+	place := func(t track.Track) {
+		p.take(findTrack(p.remaining, t))
+		if len(seq) == 0 {
+			state = p.initialState(t)
+		} else {
+			state.advance(t)
+		}
+		seq = append(seq, t)
+	}
+	for _, t := range played {
+		place(t)
+	}
+
+	sim := Simulation{}
+	base := mixTotal(seq, DefaultWeights)
+	prevTotal := base
+	step := func(t track.Track) {
+		var detail TransitionDetail
+		if len(seq) > 0 {
+			detail = transitionDetail(seq[len(seq)-1], t, DefaultWeights)
+			detail.Index = len(seq) - 1
+		}
+		place(t)
+		total := mixTotal(seq, DefaultWeights)
+		sim.Steps = append(sim.Steps, SimStep{Track: t, Transition: detail, Delta: total - prevTotal})
+		prevTotal = total
+	}
+
+	step(next)
+	for range horizon {
+		if err := ctx.Err(); err != nil {
+			return Simulation{}, err
+		}
+		if len(p.remaining) == 0 {
+			break
+		}
+		step(p.remaining[p.chooseNextIndex(&state)])
+	}
+
+	sim.State = p.snapshot(&state)
+	sim.Total = prevTotal
+	sim.Delta = prevTotal - base
+	return sim, nil
+}
+
+// findTrack returns the index of the first track in ts matching t on its identifying
+// fields, or -1.
+func findTrack(ts []track.Track, t track.Track) int {
+	for i, c := range ts {
+		if c.Title == t.Title && c.Artist == t.Artist && c.Key == t.Key && c.BPM == t.BPM && c.Energy == t.Energy {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,68 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSimulateProjectsFollowingPicks(t *testing.T) {
+	tracks := flowTestTracks()
+	played, pool := tracks[:2], tracks[2:] // a (8A), b (9A) played
+	ctx := WithSeed(context.Background(), 1)
+
+	sim, err := Simulate(ctx, played, pool, pool[0], 3) // c (9B) next
+	if err != nil {
+		t.Fatalf("Simulate: %v", err)
+	}
+	if len(sim.Steps) != 4 || sim.Steps[0].Track.Title != "c" {
+		t.Fatalf("got %d steps starting %q, want 4 starting c", len(sim.Steps), sim.Steps[0].Track.Title)
+	}
+	if sim.State.Remaining != len(pool)-4 || sim.State.Prev != sim.Steps[3].Track.Title {
+		t.Fatalf("unexpected projected state: %+v", sim.State)
+	}
+
+	seq := append(append([]track.Track(nil), played...), stepTracks(sim)...)
+	if got, want := sim.Total, ScoreMix(seq).Total; math.Abs(got-want) > 1e-9 {
+		t.Fatalf("Total = %.4f, want the shared score %.4f", got, want)
+	}
+	sum := 0.0
+	for _, s := range sim.Steps {
+		sum += s.Delta
+	}
+	if math.Abs(sum-sim.Delta) > 1e-9 {
+		t.Fatalf("step deltas sum to %.4f, want %.4f", sum, sim.Delta)
+	}
+	if len(pool) != 8 || pool[0].Title != "c" {
+		t.Fatal("Simulate modified its input")
+	}
+}
+
+// A harmonically clashing pick should project worse than a compatible one.
+func TestSimulateRanksCandidates(t *testing.T) {
+	tracks := flowTestTracks()
+	played, pool := tracks[:2], tracks[2:] // ... b (9A, 122)
+	ctx := WithSeed(context.Background(), 1)
+
+	good, err := Simulate(ctx, played, pool, tracks[2], 0) // c: 9B, 124
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad, err := Simulate(ctx, played, pool, tracks[6], 0) // g: 3A, 96
+	if err != nil {
+		t.Fatal(err)
+	}
+	if good.Delta >= bad.Delta {
+		t.Fatalf("compatible pick delta %.2f >= clashing pick %.2f", good.Delta, bad.Delta)
+	}
+}
+
+func stepTracks(sim Simulation) []track.Track {
+	out := make([]track.Track, len(sim.Steps))
+	for i, s := range sim.Steps {
+		out[i] = s.Track
+	}
+	return out
+}
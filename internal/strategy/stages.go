@@ -0,0 +1,168 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const stagedStrategyName = "staged"
+
+// Stage is one stretch of a staged set: the strategy that orders it and how long it
+// runs, as a track count or seconds of playtime. A stage with neither is the rest of
+// the set.
+type Stage struct {
+	Sorter  Sorter
+	Tracks  int
+	Seconds float64
+}
+
+// sized reports whether the stage has a length of its own rather than taking the rest.
+func (st Stage) sized() bool { return st.Tracks > 0 || st.Seconds > 0 }
+
+// StagedSorter orders a set in stages, each by its own strategy: a gentle warm-up, a
+// main stretch, a peak hour. The library is split by energy (see Split), so the stages
+// rise through it in order, and each stage is sorted on its own. At each border the
+// framework hands over harmonically: the next stage opens with its track that mixes
+// most cheaply out of the last stage's closer, pinned first. Ordering rules measure the
+// whole set and are left to Sort, as for any strategy.
+type StagedSorter struct {
+	stages []Stage
+}
+
+// NewStagedSorter returns a sorter for stages, in set order. At most one stage may be
+// unsized; it takes the tracks the others leave.
+func NewStagedSorter(stages []Stage) (*StagedSorter, error) {
+	if len(stages) < 2 {
+		return nil, fmt.Errorf("staged: want at least 2 stages, got %d", len(stages))
+	}
+	rest := 0
+	for i, st := range stages {
+		if st.Sorter == nil {
+			return nil, fmt.Errorf("staged: stage %d has no strategy", i+1)
+		}
+		if !st.sized() {
+			rest++
+		}
+	}
+	if rest > 1 {
+		return nil, fmt.Errorf("staged: %d stages take the rest of the set; give all but one a length", rest)
+	}
+	return &StagedSorter{stages: slices.Clone(stages)}, nil
+}
+
+func (s *StagedSorter) Name() string {
+	return stagedStrategyName
+}
+
+// Stages returns the sorter's stages, in set order.
+func (s *StagedSorter) Stages() []Stage {
+	return slices.Clone(s.stages)
+}
+
+// Split assigns tracks to the stages by energy, quietest first (then slowest). The
+// stages before the unsized one fill from the quiet end in order, those after it from
+// the loud end, and the unsized stage takes what is left in between. With every stage
+// sized, the last one fills from the loud end and the tracks in between are left out.
+// Each stage gets whole tracks until it reaches its length; tracks keep their input
+// order within a stage.
+func (s *StagedSorter) Split(tracks []track.Track) [][]track.Track {
+	ranked := make([]int, len(tracks))
+	for i := range ranked {
+		ranked[i] = i
+	}
+	slices.SortStableFunc(ranked, func(a, b int) int {
+		return cmp.Or(cmp.Compare(tracks[a].Energy, tracks[b].Energy), cmp.Compare(tracks[a].BPM, tracks[b].BPM))
+	})
+	secs := trackSeconds(tracks)
+
+	n := len(s.stages)
+	rest := slices.IndexFunc(s.stages, func(st Stage) bool { return !st.sized() })
+	front, back := rest, rest+1
+	if rest < 0 {
+		front, back = n-1, n-1
+	}
+	lo, hi := 0, len(ranked)
+	fits := func(st Stage, count int, played float64) bool {
+		if st.Tracks > 0 {
+			return count < st.Tracks
+		}
+		return played < st.Seconds
+	}
+	groups := make([][]int, n)
+	for k := 0; k < front; k++ {
+		played := 0.0
+		for lo < hi && fits(s.stages[k], len(groups[k]), played) {
+			groups[k] = append(groups[k], ranked[lo])
+			played += secs[ranked[lo]]
+			lo++
+		}
+	}
+	for k := n - 1; k >= back; k-- {
+		played := 0.0
+		for lo < hi && fits(s.stages[k], len(groups[k]), played) {
+			hi--
+			groups[k] = append(groups[k], ranked[hi])
+			played += secs[ranked[hi]]
+		}
+	}
+	if rest >= 0 {
+		groups[rest] = ranked[lo:hi]
+	}
+
+	out := make([][]track.Track, n)
+	for k, g := range groups {
+		g = slices.Clone(g)
+		slices.Sort(g)
+		for _, i := range g {
+			out[k] = append(out[k], tracks[i])
+		}
+	}
+	return out
+}
+
+// Sort orders each stage's tracks with its strategy, without a limit or the context's
+// ordering rules, and plays the stages one after another. A stage stopped part-way
+// ends the set there.
+func (s *StagedSorter) Sort(ctx context.Context, tracks []track.Track) ([]track.Track, error) {
+	base := context.WithValue(withoutCheckpoints(withoutRules(ctx)), limitContextKey, 0)
+	var out []track.Track
+	for k, group := range s.Split(tracks) {
+		if len(group) == 0 {
+			continue
+		}
+		stageCtx := base
+		if len(out) > 0 {
+			id := handoff(out[len(out)-1], group).ID()
+			stageCtx = WithPins(stageCtx, Pin{
+				Name:     fmt.Sprintf("handoff into stage %d", k+1),
+				Match:    func(t track.Track) bool { return t.ID() == id },
+				Position: 1,
+			})
+		}
+		res, err := Sort(stageCtx, s.stages[k].Sorter, group)
+		if err != nil {
+			return out, err
+		}
+		out = append(out, res.Ordered...)
+		if res.Partial {
+			return out, &PartialError{Placed: out, Err: fmt.Errorf("stage %d (%s) stopped early", k+1, s.stages[k].Sorter.Name())}
+		}
+	}
+	return out, nil
+}
+
+// handoff picks the track of next that mixes most cheaply out of last, the first
+// such when several tie.
+func handoff(last track.Track, next []track.Track) track.Track {
+	best, bestCost := next[0], TransitionCost(last, next[0], DefaultWeights)
+	for _, t := range next[1:] {
+		if c := TransitionCost(last, t, DefaultWeights); c < bestCost {
+			best, bestCost = t, c
+		}
+	}
+	return best
+}
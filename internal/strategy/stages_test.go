@@ -0,0 +1,89 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestNewStagedSorterValidates(t *testing.T) {
+	flow := NewFlowSorter()
+	for name, stages := range map[string][]Stage{
+		"one stage":   {{Sorter: flow}},
+		"two rests":   {{Sorter: flow}, {Sorter: flow}, {Sorter: flow, Tracks: 4}},
+		"no strategy": {{Tracks: 4}, {Sorter: flow}},
+	} {
+		if _, err := NewStagedSorter(stages); err == nil {
+			t.Errorf("%s: NewStagedSorter accepted %v", name, stages)
+		}
+	}
+}
+
+func stagedLibrary() []track.Track {
+	keys := []string{"8A", "9A", "3B", "10A", "8B", "4A", "11A", "9B", "5A", "12A"}
+	var tracks []track.Track
+	for i, k := range keys {
+		// Energies run against input order, so Split's ranking shows.
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("e%d", 90-i*8), Artist: "a", BPM: 120 + float64(i),
+			Energy: 90 - i*8, Key: mustKey(k)})
+	}
+	return tracks
+}
+
+func TestStagedSplitByEnergy(t *testing.T) {
+	rotation, flow := NewRotationSorter(), NewFlowSorter()
+	tracks := stagedLibrary()
+
+	s, err := NewStagedSorter([]Stage{{Sorter: rotation, Tracks: 2}, {Sorter: flow}, {Sorter: rotation, Tracks: 3}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups := s.Split(tracks)
+	want := []string{"e26 e18 ", "e66 e58 e50 e42 e34 ", "e90 e82 e74 "}
+	for k, g := range groups {
+		if got := titles(g); got != want[k] {
+			t.Errorf("stage %d = %q, want %q", k+1, got, want[k])
+		}
+	}
+
+	// Every stage sized: the last fills from the loud end and the middle is left out.
+	s, err = NewStagedSorter([]Stage{{Sorter: flow, Tracks: 2}, {Sorter: flow, Tracks: 2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	groups = s.Split(tracks)
+	if got := titles(groups[0]) + "| " + titles(groups[1]); got != "e26 e18 | e90 e82 " {
+		t.Errorf("split = %q", got)
+	}
+}
+
+func TestStagedSortHandsOverAtBorders(t *testing.T) {
+	tracks := stagedLibrary()
+	s, err := NewStagedSorter([]Stage{{Sorter: NewRotationSorter(), Seconds: 3 * avgTrackSeconds}, {Sorter: NewFlowSorter()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	res, err := Sort(WithSeed(context.Background(), 1), s, tracks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(res.Ordered) != len(tracks) {
+		t.Fatalf("ordered %d of %d tracks", len(res.Ordered), len(tracks))
+	}
+	groups := s.Split(tracks)
+	first := map[string]bool{}
+	for _, tr := range groups[0] {
+		first[tr.ID()] = true
+	}
+	for i, tr := range res.Ordered {
+		if first[tr.ID()] != (i < len(groups[0])) {
+			t.Fatalf("track %d %q is out of its stage: %s", i+1, tr.Title, titles(res.Ordered))
+		}
+	}
+	border := len(groups[0])
+	if want := handoff(res.Ordered[border-1], groups[1]); res.Ordered[border].ID() != want.ID() {
+		t.Errorf("stage 2 opens with %q, want the handoff %q", res.Ordered[border].Title, want.Title)
+	}
+}
@@ -2,6 +2,9 @@ package strategy
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
 
 	"github.com/YakDriver/magicmix/internal/track"
 )
@@ -12,19 +15,169 @@ type Sorter interface {
 	Sort(ctx context.Context, tracks []track.Track) ([]track.Track, error)
 }
 
-// Result captures the ordered output and any metadata about the sort run.
+// Result captures the ordered output and any metadata about the sort run. Warnings
+// are non-fatal issues worth surfacing to the user, such as ordering rules that could
+// not be fully satisfied. Confidence rates each placement of Ordered (see
+// PlacementConfidence). Partial is set when the sorter stopped early; Unplaced then
+// holds the tracks it never got to, in input order. Explanations, when the context
+// asks for them (see WithExplain), gives the reason for each track of Ordered.
+// Strategy, Seed, Input, and Elapsed record the run itself, for Summary.
 type Result struct {
-	Ordered []track.Track
-	Notes   []string
+	Ordered      []track.Track
+	Unplaced     []track.Track
+	Partial      bool
+	Notes        []string
+	Warnings     []string
+	Confidence   []Confidence
+	Explanations []string
+
+	Strategy string        // the sorter's name
+	Seed     int64         // from WithSeed; 0 when the run had none
+	Input    int           // tracks offered to the sort
+	Elapsed  time.Duration // how long the sort took
 }
 
 // Sort applies the sorter and wraps the result in a Result struct for future expansion.
+// A list of 2 to MicroLimit tracks, all of them wanted in the set, skips the sorter
+// unless its decisions or explanations were asked for: Sort tries every order instead
+// and notes what the tracks allow (see microSort).
+// So does a longer list that offers nothing but tempo to order by, every track in one
+// key at one energy: Sort orders it by BPM, then title, and notes why (see
+// fallbackSort).
+// Ordering rules in the context (separation, placement, resets, energy targets, pins)
+// are enforced on the sorter's output, so every strategy honors them, and a sorter that
+// shortened the set for a limit gets back any pinned or must-include track it dropped;
+// variety from a previous set and the crowd's lean are weighed against the mix score
+// there. Key-agnostic tracks (see track.AnyKeyTag) are then moved to bridge the worst
+// key jumps.
+// A human-feel profile in the context (see WithHumanFeel) roughens the ordering before
+// the rules are enforced, and a progress function (see WithProgress) hears from the
+// sorter as it works. Under WithDeterminismAudit the sort runs twice and must come out
+// the same both times.
+//
+// A sorter stopped part-way with a PartialError (such as by a timeout) yields a partial
+// Result rather than an error: Ordered holds the tracks it placed, Unplaced the rest in
+// input order, and Partial is set.
 func Sort(ctx context.Context, s Sorter, tracks []track.Track) (Result, error) {
-	ordered, err := s.Sort(ctx, tracks)
+	start := time.Now()
+	run := sortOnce
+	if auditFromContext(ctx) {
+		run = auditedSort
+	}
+	res, err := run(ctx, s, tracks)
 	if err != nil {
+		return res, err
+	}
+	res.Strategy, res.Input, res.Elapsed = s.Name(), len(tracks), time.Since(start)
+	res.Seed, _ = seedFromContext(ctx)
+	return res, nil
+}
+
+// sortOnce is Sort without a determinism audit.
+func sortOnce(ctx context.Context, s Sorter, tracks []track.Track) (Result, error) {
+	res := Result{}
+	var explained *explainLog
+	if explainFromContext(ctx) {
+		explained = &explainLog{}
+		ctx = context.WithValue(ctx, explainLogContextKey, explained)
+	}
+	ctx = withSortProgress(ctx, s)
+	var ordered []track.Track
+	var err error
+	flat := flatLibrary(tracks)
+	switch {
+	case micro(ctx, len(tracks)):
+		ordered, res.Notes = microSort(tracks, bindRules(ctx, tracks))
+	case flat != "" && decisionRecorderFromContext(ctx) == nil && !explainFromContext(ctx):
+		ordered, res.Notes = fallbackSort(ctx, tracks, flat)
+	default:
+		ordered, err = s.Sort(ctx, tracks)
+	}
+	ctx = withoutCheckpoints(ctx)
+	var stopped *PartialError
+	switch {
+	case errors.As(err, &stopped):
+		ordered = stopped.Placed
+		res.Partial = true
+		res.Unplaced = unplaced(tracks, ordered)
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s strategy stopped early (%v): placed %d of %d tracks",
+			s.Name(), stopped.Err, len(ordered), len(tracks)))
+	case err != nil:
 		return Result{}, err
 	}
-	return Result{Ordered: ordered}, nil
+	if want := limitFromContext(ctx); !res.Partial && len(ordered) < len(tracks) && (want == 0 || len(ordered) < want) {
+		res.Warnings = append(res.Warnings, fmt.Sprintf("%s strategy returned %d of %d tracks",
+			s.Name(), len(ordered), len(tracks)))
+	}
+	if required := Required(ctx); required != nil && !res.Partial && len(ordered) < len(tracks) {
+		var swapped int
+		if ordered, swapped = includeRequired(ctx, s, tracks, ordered, required); swapped > 0 {
+			res.Notes = append(res.Notes, fmt.Sprintf("Swapped %d pinned or must-include track(s) back into the %d-track set",
+				swapped, len(ordered)))
+		}
+	}
+	if h := humanFeelFromContext(ctx); !h.empty() && !res.Partial {
+		var notes []string
+		ordered, notes = humanize(ctx, ordered, h)
+		res.Notes = append(res.Notes, notes...)
+	}
+	if rules := bindRules(ctx, ordered); len(rules) > 0 {
+		ordered = enforceRules(ordered, rules)
+		if strictCamelotFromContext(ctx) {
+			ordered = planAroundDiagonals(ordered, bindRules(ctx, ordered))
+		}
+		ordered = weighSoftRules(ordered, bindRules(ctx, ordered))
+	}
+	if !res.Partial {
+		var notes []string
+		ordered, notes = bridgeKeyJumps(ordered, bindRules(ctx, ordered))
+		res.Notes = append(res.Notes, notes...)
+	}
+	if rules := bindRules(ctx, ordered); len(rules) > 0 {
+		if n := ruleConflicts(bindRules(ctx, ordered), len(ordered)); n > 0 {
+			res.Warnings = append(res.Warnings, fmt.Sprintf(
+				"ordering rules relaxed: %d track(s) still break a separation, placement, reset, energy-target, arc, pin, locked-order, or strict-Camelot rule", n))
+		}
+		if strictCamelotFromContext(ctx) {
+			set := ordered
+			if limit := limitFromContext(ctx); limit > 0 {
+				set = Truncate(ctx, ordered, limit)
+			}
+			for _, k := range Diagonals(set) {
+				a, b := set[k-1], set[k]
+				res.Warnings = append(res.Warnings, fmt.Sprintf("strict Camelot: couldn't plan around the diagonal move at #%d, %q (%s) into %q (%s)",
+					k+1, a.Title, a.ExitKey(), b.Title, b.EntryKey()))
+			}
+		}
+	}
+	res.Ordered = ordered
+	res.Confidence = PlacementConfidence(ordered)
+	if explained != nil {
+		var notes []string
+		res.Explanations, notes = explained.explain(ordered)
+		res.Notes = append(res.Notes, notes...)
+	}
+	return res, nil
+}
+
+// includeRequired swaps the required tracks a limited sort left out back into ordered,
+// in place of the tracks that fit worst, and re-sorts the set at its new size.
+func includeRequired(ctx context.Context, s Sorter, tracks, ordered []track.Track, required func(track.Track) bool) ([]track.Track, int) {
+	want := 0
+	for _, t := range tracks {
+		if required(t) {
+			want++
+		}
+	}
+	set, swapped := MeetQuota(ordered, tracks, required, want)
+	if swapped == 0 {
+		return ordered, 0
+	}
+	again, err := s.Sort(context.WithValue(ctx, limitContextKey, 0), set)
+	if err != nil || len(again) != len(set) {
+		return set, swapped
+	}
+	return again, swapped
 }
 
 type contextKey string
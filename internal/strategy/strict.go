@@ -0,0 +1,213 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// strictUnit is the rule penalty for each diagonal move under WithStrictCamelot:
+// far above any transition's mix cost, so flow and anneal never trade one for a
+// smoother set, and Sort's repair removes every one an ordering allows.
+const strictUnit = 10.0
+
+// strictPlannerCost is added to a diagonal candidate's score in the default planner
+// under WithStrictCamelot, so it is only chosen when every other track is one too.
+const strictPlannerCost = 1000.0
+
+const strictCamelotContextKey contextKey = "strategy.strict-camelot"
+
+// Diagonal reports whether the move changes both the wheel number and the mode, such
+// as 8A to 9B: the combination strict Camelot mixing forbids. Moves into or out of
+// a track with no key or a key-agnostic one are never diagonal.
+func (t Transition) Diagonal() bool {
+	return !t.AnyKey && t.ModeChange && t.FromKey.Number != t.ToKey.Number &&
+		t.FromKey.Number != 0 && t.ToKey.Number != 0
+}
+
+// WithStrictCamelot forbids diagonal moves (see Transition.Diagonal) outright instead
+// of only discouraging them: the mode changes only to the relative key. The default
+// planner chooses around them, flow and anneal optimize the rule, and Sort repairs any
+// strategy's output that breaks it. A diagonal is left only where no ordering of the
+// tracks avoids it, and Sort warns of each.
+func WithStrictCamelot(ctx context.Context) context.Context {
+	return context.WithValue(ctx, strictCamelotContextKey, true)
+}
+
+func strictCamelotFromContext(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	on, _ := ctx.Value(strictCamelotContextKey).(bool)
+	return on
+}
+
+// boundStrict is WithStrictCamelot resolved against one track list: which pairs of
+// tracks make a diagonal move back to back.
+type boundStrict struct {
+	diagonal [][]bool
+}
+
+func bindStrict(tracks []track.Track) *boundStrict {
+	b := &boundStrict{diagonal: make([][]bool, len(tracks))}
+	for i, a := range tracks {
+		b.diagonal[i] = make([]bool, len(tracks))
+		for j, c := range tracks {
+			b.diagonal[i][j] = i != j && NewTransition(a, c).Diagonal()
+		}
+	}
+	return b
+}
+
+func (b *boundStrict) cost(perm []int) float64 {
+	total := 0.0
+	for k := 1; k < len(perm); k++ {
+		if b.diagonal[perm[k-1]][perm[k]] {
+			total += strictUnit
+		}
+	}
+	return total
+}
+
+// conflicts marks both tracks of every diagonal move.
+func (b *boundStrict) conflicts(perm []int, out []bool) {
+	for k := 1; k < len(perm); k++ {
+		if b.diagonal[perm[k-1]][perm[k]] {
+			out[k-1], out[k] = true, true
+		}
+	}
+}
+
+// Diagonals lists the positions k of ordered where playing ordered[k] after
+// ordered[k-1] is a diagonal move.
+func Diagonals(ordered []track.Track) []int {
+	var out []int
+	for k := 1; k < len(ordered); k++ {
+		if NewTransition(ordered[k-1], ordered[k]).Diagonal() {
+			out = append(out, k)
+		}
+	}
+	return out
+}
+
+// strictSpliceTries bounds the relative pairs planAroundDiagonals weighs for each
+// diagonal move.
+const strictSpliceTries = 64
+
+// planAroundDiagonals repairs the diagonal moves enforceRules can't, where the set
+// has to change mode somewhere and no single track moved fixes it. For each diagonal
+// it tries moving the whole run on either side of it, up to the next diagonal, to
+// another place in the set, and splicing a relative pair from elsewhere (such as 9A
+// then 9B) into it, so the mode changes there instead. A move is kept only when it
+// lowers the penalty of the rules to be enforced, choosing the one that keeps the mix
+// score lowest.
+func planAroundDiagonals(ordered []track.Track, rules []orderRule) []track.Track {
+	hard, _ := splitRules(rules)
+	n := len(ordered)
+	b := bindStrict(ordered)
+	perm := identity(n)
+	pen := rulesCost(hard, perm)
+	seq := make([]track.Track, n)
+	for pen > 0 {
+		var best []int
+		bestPen, bestMix := pen, math.Inf(1)
+		diagonals := b.cost(perm)
+		try := func(next []int) bool {
+			if b.cost(next) >= diagonals {
+				return false
+			}
+			np := rulesCost(hard, next)
+			if np >= pen-improvementEps {
+				return true
+			}
+			for i, idx := range next {
+				seq[i] = ordered[idx]
+			}
+			if mix := mixTotal(seq, DefaultWeights); best == nil || np < bestPen-improvementEps ||
+				(np <= bestPen+improvementEps && mix < bestMix) {
+				best, bestPen, bestMix = next, np, mix
+			}
+			return true
+		}
+		var at []int
+		for k := 1; k < n; k++ {
+			if b.diagonal[perm[k-1]][perm[k]] {
+				at = append(at, k)
+			}
+		}
+		for j, k := range at {
+			prev, following := 0, n
+			if j > 0 {
+				prev = at[j-1]
+			}
+			if j+1 < len(at) {
+				following = at[j+1]
+			}
+			for _, run := range [][2]int{{prev, k}, {k, following}} {
+				i, l := run[0], run[1]-run[0]
+				for p := 0; p+l <= n; p++ {
+					if p != i {
+						try(moveRun(perm, i, l, p))
+					}
+				}
+			}
+		}
+		for _, k := range at {
+			if best != nil {
+				break
+			}
+			left, right := perm[k-1], perm[k]
+			tries := 0
+			for _, u := range perm {
+				if u == left || u == right || b.diagonal[left][u] {
+					continue
+				}
+				for _, v := range perm {
+					if v == left || v == right || v == u || b.diagonal[u][v] || b.diagonal[v][right] {
+						continue
+					}
+					if try(spliceAfter(perm, left, u, v)) {
+						tries++
+					}
+					if tries >= strictSpliceTries {
+						break
+					}
+				}
+			}
+		}
+		if best == nil {
+			break
+		}
+		perm, pen = best, bestPen
+	}
+	out := make([]track.Track, n)
+	for k, idx := range perm {
+		out[k] = ordered[idx]
+	}
+	return out
+}
+
+// spliceAfter returns perm with u then v taken from where they are and played right
+// after at.
+func spliceAfter(perm []int, at, u, v int) []int {
+	out := make([]int, 0, len(perm))
+	for _, idx := range perm {
+		if idx == u || idx == v {
+			continue
+		}
+		out = append(out, idx)
+		if idx == at {
+			out = append(out, u, v)
+		}
+	}
+	return out
+}
+
+// moveRun is relocateSegment for a run of any length: perm with perm[i:i+l] moved to
+// begin at index p of what remains.
+func moveRun(perm []int, i, l, p int) []int {
+	rest := slices.Concat(perm[:i], perm[i+l:])
+	return slices.Concat(rest[:p], perm[i:i+l], rest[p:])
+}
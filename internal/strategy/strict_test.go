@@ -0,0 +1,90 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestTransitionDiagonal(t *testing.T) {
+	for _, tc := range []struct {
+		a, b string
+		want bool
+	}{
+		{"8A", "9B", true},
+		{"8B", "7A", true},
+		{"8A", "3B", true},
+		{"8A", "8B", false},
+		{"8A", "9A", false},
+		{"8A", "8A", false},
+	} {
+		if got := KeyTransition(mustKey(tc.a), mustKey(tc.b)).Diagonal(); got != tc.want {
+			t.Errorf("%s to %s diagonal = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+	if KeyTransition(track.Key{}, mustKey("9B")).Diagonal() {
+		t.Error("a move from no key is diagonal")
+	}
+}
+
+func TestSortStrictCamelot(t *testing.T) {
+	keys := []string{"8A", "9B", "10A", "9A", "8B", "10B", "11A", "11B"}
+	var tracks []track.Track
+	for i := range 24 {
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("s%02d", i), Artist: fmt.Sprintf("A%d", i),
+			BPM: 120 + float64(i%5), Energy: 30 + (i*17)%60, Key: mustKey(keys[i%len(keys)])})
+	}
+	ctx := WithStrictCamelot(WithSeed(context.Background(), 3))
+	for _, name := range Names() {
+		s, _ := Get(name)
+		res, err := Sort(ctx, s, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if d := Diagonals(res.Ordered); len(d) > 0 {
+			t.Errorf("%s left diagonal moves at %v under strict Camelot", name, d)
+		}
+	}
+
+	// Two keys a diagonal apart can't avoid it, and Sort says so.
+	pair := []track.Track{{Title: "x", BPM: 120, Energy: 50, Key: mustKey("8A")}, {Title: "y", BPM: 120, Energy: 60, Key: mustKey("9B")}}
+	s, _ := Get(flowStrategyName)
+	res, err := Sort(ctx, s, pair)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(strings.Join(res.Warnings, "\n"), "strict Camelot: couldn't plan around the diagonal move at #2") {
+		t.Errorf("warnings = %q; want the unavoidable diagonal named", res.Warnings)
+	}
+}
+
+func TestPlanAroundDiagonals(t *testing.T) {
+	set := func(keys ...string) []track.Track {
+		var out []track.Track
+		for i, k := range keys {
+			out = append(out, track.Track{Title: fmt.Sprint(i), BPM: 120, Energy: 50, Key: mustKey(k)})
+		}
+		return out
+	}
+	ctx := WithStrictCamelot(context.Background())
+	for _, keys := range [][]string{
+		// The B run sits between A runs, but its one relative pair can cover only
+		// one of the two mode changes: the run has to move to an end.
+		{"7A", "9A", "9B", "11B", "10B", "3A", "3A", "7A"},
+		// The only relative pair is elsewhere in the set, and both go in together.
+		{"8A", "9B", "10B", "3A", "3B", "2A"},
+	} {
+		ordered := set(keys...)
+		out := planAroundDiagonals(ordered, bindRules(ctx, ordered))
+		if d := Diagonals(out); len(d) > 0 {
+			var got []string
+			for _, tr := range out {
+				got = append(got, tr.Key.String())
+			}
+			t.Errorf("%v planned as %v, with diagonal moves at %v", keys, got, d)
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Style tuning. A transition by a key move the style never uses costs styleUnit, one
+// by its most used move nothing; a tempo change or energy jump past the style's costs
+// styleUnit per styleBPMStep BPM or styleEnergyStep points over, up to styleCap units
+// each; and a disliked pair back to back costs styleDislikedUnits units. Learned
+// tempo changes and energy jumps are the styleQuantile of a set's transitions.
+const (
+	styleUnit          = 0.5
+	styleBPMStep       = 3.0
+	styleEnergyStep    = 10.0
+	styleCap           = 2.0
+	styleDislikedUnits = 6.0
+	styleQuantile      = 0.8
+)
+
+// Key moves, as KeyMove names them.
+var keyMoves = []string{"same", "+1", "-1", "+2", "-2", "relative", "other"}
+
+// KeyMoves lists the key move names KeyMove returns.
+func KeyMoves() []string {
+	return slices.Clone(keyMoves)
+}
+
+// KeyMove names the move on the Camelot wheel from a to b: "same", "+1" or "-1" (one
+// step around the ring), "+2" or "-2", "relative" (the same number in the other
+// ring), or "other". It is "" when either key is unknown.
+func KeyMove(a, b track.Key) string {
+	if a.Number == 0 || b.Number == 0 {
+		return ""
+	}
+	off := a.Offset(b)
+	switch {
+	case a.Mode != b.Mode && off == 0:
+		return "relative"
+	case a.Mode != b.Mode:
+		return "other"
+	case off == 0:
+		return "same"
+	case off >= -2 && off <= 2:
+		return fmt.Sprintf("%+d", off)
+	}
+	return "other"
+}
+
+// Adjacency is a pair of tracks, each written "Title|Artist", played back to back.
+type Adjacency struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// matches reports whether a, then b, is the pair: titles compare case-insensitively,
+// and an artist, when given, must be credited.
+func (p Adjacency) matches(a, b track.Track) bool {
+	return refMatches(p.From, a) && refMatches(p.To, b)
+}
+
+func refMatches(ref string, t track.Track) bool {
+	title, artist, _ := strings.Cut(ref, "|")
+	title, artist = strings.TrimSpace(title), strings.TrimSpace(artist)
+	return title != "" && strings.EqualFold(strings.TrimSpace(t.Title), title) &&
+		(artist == "" || track.SharesArtist(t.Artist, artist))
+}
+
+// Style is a DJ's own way of mixing, kept across runs: learned from the sets they
+// played (Learn) and declared by hand. KeyMoves is the share of learned transitions
+// each key move took; Favorites are moves declared always welcome. BPMDelta is the
+// tempo change they usually make and EnergyJump the largest energy jump they make as
+// a rule; Disliked are pairs they don't want back to back. Sets and Transitions count
+// what has been learned, and Weight scales the style against the mix score (0 counts
+// as 1).
+type Style struct {
+	KeyMoves    map[string]float64 `json:"keyMoves,omitempty"`
+	Favorites   []string           `json:"favorites,omitempty"`
+	BPMDelta    float64            `json:"bpmDelta,omitempty"`
+	EnergyJump  float64            `json:"energyJump,omitempty"`
+	Disliked    []Adjacency        `json:"disliked,omitempty"`
+	Sets        int                `json:"sets,omitempty"`
+	Transitions int                `json:"transitions,omitempty"`
+	Weight      float64            `json:"weight,omitempty"`
+}
+
+// Empty reports whether the style has nothing to apply.
+func (s Style) Empty() bool {
+	return len(s.KeyMoves) == 0 && len(s.Favorites) == 0 && s.BPMDelta == 0 && s.EnergyJump == 0 && len(s.Disliked) == 0
+}
+
+// Learn merges a played set into the style, weighing it against what was learned
+// before by transitions: the share of each key move, the styleQuantile tempo change,
+// and the styleQuantile energy jump. A set of fewer than two tracks teaches nothing.
+func (s *Style) Learn(set []track.Track) {
+	counts := map[string]int{}
+	var bpm, energy []float64
+	keyed := 0
+	for k := 1; k < len(set); k++ {
+		a, b := set[k-1], set[k]
+		if move := KeyMove(a.Key, b.Key); move != "" {
+			counts[move]++
+			keyed++
+		}
+		if a.BPM > 0 && b.BPM > 0 {
+			bpm = append(bpm, math.Abs(b.EntryBPM()-a.ExitBPM()))
+		}
+		energy = append(energy, math.Abs(float64(b.Energy-a.Energy)))
+	}
+	n := len(set) - 1
+	if n < 1 {
+		return
+	}
+	old := float64(s.Transitions)
+	merge := func(prev, learned float64) float64 {
+		if old == 0 {
+			return learned
+		}
+		return (prev*old + learned*float64(n)) / (old + float64(n))
+	}
+	if keyed > 0 {
+		if s.KeyMoves == nil {
+			s.KeyMoves = map[string]float64{}
+		}
+		for _, move := range keyMoves {
+			share := float64(counts[move]) / float64(keyed)
+			s.KeyMoves[move] = merge(s.KeyMoves[move], share)
+			if s.KeyMoves[move] == 0 {
+				delete(s.KeyMoves, move)
+			}
+		}
+	}
+	if len(bpm) > 0 {
+		slices.Sort(bpm)
+		learned := quantileFloats(bpm, styleQuantile)
+		if s.BPMDelta > 0 {
+			learned = merge(s.BPMDelta, learned)
+		}
+		s.BPMDelta = math.Round(learned*10) / 10
+	}
+	slices.Sort(energy)
+	s.EnergyJump = math.Round(merge(s.EnergyJump, quantileFloats(energy, styleQuantile)))
+	s.Sets++
+	s.Transitions += n
+}
+
+// Dislike adds a pair not to play back to back, unless it is already there.
+func (s *Style) Dislike(p Adjacency) {
+	for _, d := range s.Disliked {
+		if strings.EqualFold(d.From, p.From) && strings.EqualFold(d.To, p.To) {
+			return
+		}
+	}
+	s.Disliked = append(s.Disliked, p)
+}
+
+// Summary describes the style in a line, for a report.
+func (s Style) Summary() string {
+	var parts []string
+	if len(s.KeyMoves) > 0 {
+		moves := slices.Clone(keyMoves)
+		slices.SortStableFunc(moves, func(a, b string) int { return cmp.Compare(s.KeyMoves[b], s.KeyMoves[a]) })
+		var top []string
+		for _, m := range moves[:3] {
+			if s.KeyMoves[m] > 0 {
+				top = append(top, fmt.Sprintf("%s %.0f%%", m, 100*s.KeyMoves[m]))
+			}
+		}
+		parts = append(parts, "key moves "+strings.Join(top, ", "))
+	}
+	if len(s.Favorites) > 0 {
+		parts = append(parts, "favorite moves "+strings.Join(s.Favorites, ", "))
+	}
+	if s.BPMDelta > 0 {
+		parts = append(parts, fmt.Sprintf("tempo changes up to %g BPM", s.BPMDelta))
+	}
+	if s.EnergyJump > 0 {
+		parts = append(parts, fmt.Sprintf("energy jumps up to %g", s.EnergyJump))
+	}
+	if len(s.Disliked) > 0 {
+		parts = append(parts, fmt.Sprintf("%d disliked pair(s)", len(s.Disliked)))
+	}
+	return strings.Join(parts, "; ")
+}
+
+const styleContextKey contextKey = "strategy.style"
+
+// WithStyle leans the set toward s: transitions by the key moves the style uses, within
+// its usual tempo change and energy jump, cost less than those it doesn't, and its
+// disliked pairs cost more back to back. The lean is a soft rule (see weighSoftRules).
+func WithStyle(ctx context.Context, s Style) context.Context {
+	if s.Empty() {
+		return ctx
+	}
+	return context.WithValue(ctx, styleContextKey, &s)
+}
+
+func styleFromContext(ctx context.Context) *Style {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(styleContextKey).(*Style)
+	return s
+}
+
+// boundStyle is a Style resolved against one track list: the penalty for playing
+// each pair of tracks back to back.
+type boundStyle struct {
+	pair [][]float64
+}
+
+func bindStyle(tracks []track.Track, s Style) *boundStyle {
+	unit := styleUnit
+	if s.Weight > 0 {
+		unit *= s.Weight
+	}
+	top := 0.0
+	for _, share := range s.KeyMoves {
+		top = math.Max(top, share)
+	}
+	b := &boundStyle{pair: make([][]float64, len(tracks))}
+	for i, a := range tracks {
+		b.pair[i] = make([]float64, len(tracks))
+		for j, c := range tracks {
+			if i == j {
+				continue
+			}
+			cost := 0.0
+			if move := KeyMove(a.Key, c.Key); move != "" && top > 0 && !slices.Contains(s.Favorites, move) {
+				cost += unit * (1 - s.KeyMoves[move]/top)
+			}
+			if s.BPMDelta > 0 && a.BPM > 0 && c.BPM > 0 {
+				if over := math.Abs(c.EntryBPM()-a.ExitBPM()) - s.BPMDelta; over > 0 {
+					cost += unit * math.Min(styleCap, over/styleBPMStep)
+				}
+			}
+			if s.EnergyJump > 0 {
+				if over := math.Abs(float64(c.Energy-a.Energy)) - s.EnergyJump; over > 0 {
+					cost += unit * math.Min(styleCap, over/styleEnergyStep)
+				}
+			}
+			for _, d := range s.Disliked {
+				if d.matches(a, c) {
+					cost += unit * styleDislikedUnits
+					break
+				}
+			}
+			b.pair[i][j] = cost
+		}
+	}
+	return b
+}
+
+func (*boundStyle) soft() {}
+
+func (b *boundStyle) cost(perm []int) float64 {
+	total := 0.0
+	for k := 1; k < len(perm); k++ {
+		total += b.pair[perm[k-1]][perm[k]]
+	}
+	return total
+}
+
+// conflicts marks both tracks of every transition the style penalizes.
+func (b *boundStyle) conflicts(perm []int, out []bool) {
+	for k := 1; k < len(perm); k++ {
+		if b.pair[perm[k-1]][perm[k]] > 0 {
+			out[k-1], out[k] = true, true
+		}
+	}
+}
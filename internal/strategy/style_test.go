@@ -0,0 +1,106 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestKeyMove(t *testing.T) {
+	for _, tc := range []struct{ a, b, want string }{
+		{"8A", "8A", "same"},
+		{"8A", "9A", "+1"},
+		{"1A", "12A", "-1"},
+		{"8A", "10A", "+2"},
+		{"8A", "8B", "relative"},
+		{"8A", "3B", "other"},
+		{"8A", "2A", "other"},
+	} {
+		if got := KeyMove(mustKey(tc.a), mustKey(tc.b)); got != tc.want {
+			t.Errorf("KeyMove(%s, %s) = %q, want %q", tc.a, tc.b, got, tc.want)
+		}
+	}
+	if got := KeyMove(track.Key{}, mustKey("8A")); got != "" {
+		t.Errorf("KeyMove with no key = %q, want none", got)
+	}
+}
+
+func TestStyleLearn(t *testing.T) {
+	set := []track.Track{
+		{Title: "a", BPM: 124, Energy: 40, Key: mustKey("8A")},
+		{Title: "b", BPM: 125, Energy: 45, Key: mustKey("9A")},
+		{Title: "c", BPM: 126, Energy: 50, Key: mustKey("10A")},
+		{Title: "d", BPM: 126, Energy: 55, Key: mustKey("11A")},
+		{Title: "e", BPM: 128, Energy: 60, Key: mustKey("11B")},
+	}
+	var s Style
+	s.Learn(set)
+	if s.KeyMoves["+1"] != 0.75 || s.KeyMoves["relative"] != 0.25 || s.Sets != 1 || s.Transitions != 4 {
+		t.Errorf("after one set: %+v; want +1 75%%, relative 25%%", s)
+	}
+	if s.BPMDelta <= 1 || s.BPMDelta > 2 || s.EnergyJump != 5 {
+		t.Errorf("after one set: BPM delta %g, energy jump %g; want between 1 and 2, and 5", s.BPMDelta, s.EnergyJump)
+	}
+	// A second set of the same length weighs as much as the first.
+	same := []track.Track{
+		{Title: "a", BPM: 124, Energy: 40, Key: mustKey("8A")},
+		{Title: "b", BPM: 124, Energy: 45, Key: mustKey("8A")},
+		{Title: "c", BPM: 124, Energy: 50, Key: mustKey("8A")},
+		{Title: "d", BPM: 124, Energy: 55, Key: mustKey("8A")},
+		{Title: "e", BPM: 124, Energy: 60, Key: mustKey("8A")},
+	}
+	s.Learn(same)
+	if s.KeyMoves["same"] != 0.5 || s.KeyMoves["+1"] != 0.375 || s.Sets != 2 {
+		t.Errorf("after two sets: %+v; want same 50%%, +1 37.5%%", s.KeyMoves)
+	}
+	s.Learn(set[:1])
+	if s.Sets != 2 {
+		t.Errorf("a one-track set was learned: %+v", s)
+	}
+}
+
+func TestStyleDislikedAdjacency(t *testing.T) {
+	a := track.Track{Title: "Alpha", Artist: "One"}
+	b := track.Track{Title: "Beta", Artist: "Two"}
+	var s Style
+	s.Dislike(Adjacency{From: "alpha|One", To: "Beta"})
+	s.Dislike(Adjacency{From: "Alpha|one", To: "beta"})
+	if len(s.Disliked) != 1 {
+		t.Fatalf("disliked = %+v; want the repeat ignored", s.Disliked)
+	}
+	b2 := bindStyle([]track.Track{a, b}, s)
+	if c := b2.cost([]int{0, 1}); c != styleUnit*styleDislikedUnits {
+		t.Errorf("Alpha then Beta costs %g, want %g", c, styleUnit*styleDislikedUnits)
+	}
+	if c := b2.cost([]int{1, 0}); c != 0 {
+		t.Errorf("Beta then Alpha costs %g, want 0", c)
+	}
+}
+
+func TestSortLeansTowardStyle(t *testing.T) {
+	tracks := chaveTracks(24)
+	ctx := WithSeed(context.Background(), 4)
+	sorter, _ := Get(flowStrategyName)
+	share := func(ctx context.Context, move string) float64 {
+		res, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		n := 0
+		for k := 1; k < len(res.Ordered); k++ {
+			if KeyMove(res.Ordered[k-1].Key, res.Ordered[k].Key) == move {
+				n++
+			}
+		}
+		return float64(n) / float64(len(res.Ordered)-1)
+	}
+	plain := share(ctx, "same")
+	styled := share(WithStyle(ctx, Style{KeyMoves: map[string]float64{"same": 0.9, "+1": 0.1}, Weight: 3}), "same")
+	if styled <= plain {
+		t.Errorf("%.0f%% of transitions keep the key with a same-key style and %.0f%% without; want more with", 100*styled, 100*plain)
+	}
+	if WithStyle(ctx, Style{}) != ctx {
+		t.Error("an empty style changed the context")
+	}
+}
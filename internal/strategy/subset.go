@@ -0,0 +1,260 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// SubsetObjective decides which tracks make a set that a limit or a target duration
+// cuts short. It ranks the tracks, the most wanted first, and Select takes them in
+// that order; what is left is what the gig can do without.
+type SubsetObjective struct {
+	Name        string
+	Description string
+	rank        func(ctx context.Context, tracks []track.Track, n int) ([]int, error)
+}
+
+// subsetObjectives are the objectives, in the order they are listed.
+var subsetObjectives = []SubsetObjective{
+	{Name: "mix", Description: "the tracks that mix best with the rest of the library", rank: rankByMix},
+	{Name: "cohesion", Description: "a tight cluster of neighboring keys", rank: rankByCohesion},
+	{Name: "arc", Description: "the widest, most even spread of energy, for an arc to draw on", rank: rankByArc},
+	{Name: "popularity", Description: "the most popular tracks (the popularity column)", rank: rankByPopularity},
+	{Name: "balanced", Description: "the best standing across the other four", rank: rankBalanced},
+}
+
+// SubsetObjectives returns the objectives.
+func SubsetObjectives() []SubsetObjective {
+	return slices.Clone(subsetObjectives)
+}
+
+// ParseSubsetObjective looks up an objective by name.
+func ParseSubsetObjective(name string) (SubsetObjective, error) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	names := make([]string, len(subsetObjectives))
+	for i, o := range subsetObjectives {
+		if o.Name == name {
+			return o, nil
+		}
+		names[i] = o.Name
+	}
+	return SubsetObjective{}, fmt.Errorf("unknown subset objective %q (want %s)", name, strings.Join(names, ", "))
+}
+
+// Select picks the tracks a set of n tracks (0 for no count) and at most seconds long
+// (0 for no length) keeps, by the objective's ranking: it takes them in rank order,
+// skipping any that would overrun seconds. Tracks required reports true for (nil for
+// none) are taken first, even past the bounds. It returns the indices of the picked
+// tracks, in input order.
+func (o SubsetObjective) Select(ctx context.Context, tracks []track.Track, n int, seconds float64,
+	required func(track.Track) bool) ([]int, error) {
+	dur := trackSeconds(tracks)
+	total := 0.0
+	for _, d := range dur {
+		total += d
+	}
+	if (n <= 0 || n >= len(tracks)) && (seconds <= 0 || total <= seconds) {
+		return identity(len(tracks)), nil
+	}
+	want := n
+	if want <= 0 && seconds > 0 {
+		want = int(math.Ceil(seconds / avgOf(dur)))
+	}
+	order, err := o.rank(ctx, tracks, min(max(want, 1), len(tracks)))
+	if err != nil {
+		return nil, err
+	}
+	return pickInOrder(tracks, order, dur, n, seconds, required), nil
+}
+
+// pickInOrder takes the required tracks, then tracks in order until n are taken or,
+// skipping any that would overrun, seconds are filled. It returns the taken indices in
+// input order.
+func pickInOrder(tracks []track.Track, order []int, dur []float64, n int, seconds float64,
+	required func(track.Track) bool) []int {
+	keep := make([]bool, len(tracks))
+	count, total := 0, 0.0
+	if required != nil {
+		for i, t := range tracks {
+			if required(t) {
+				keep[i] = true
+				count++
+				total += dur[i]
+			}
+		}
+	}
+	for _, i := range order {
+		if n > 0 && count >= n {
+			break
+		}
+		if keep[i] || (seconds > 0 && total+dur[i] > seconds) {
+			continue
+		}
+		keep[i] = true
+		count++
+		total += dur[i]
+	}
+	var picked []int
+	for i, k := range keep {
+		if k {
+			picked = append(picked, i)
+		}
+	}
+	return picked
+}
+
+func avgOf(xs []float64) float64 {
+	if len(xs) == 0 {
+		return avgTrackSeconds
+	}
+	sum := 0.0
+	for _, x := range xs {
+		sum += x
+	}
+	return sum / float64(len(xs))
+}
+
+// rankByMix ranks tracks by how little they add to the cost of a good ordering of the
+// whole library, as Blend and FitRuntime do.
+func rankByMix(ctx context.Context, tracks []track.Track, _ int) ([]int, error) {
+	gains, err := fitGains(ctx, tracks)
+	if err != nil {
+		return nil, err
+	}
+	order := identity(len(tracks))
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(gains[a], gains[b]) })
+	return order, nil
+}
+
+// rankByCohesion ranks tracks by their distance on the Camelot wheel from the key
+// whose n nearest tracks sit closest to it, so the first n are the tightest cluster
+// of keys the library has. Tracks with no key come last.
+func rankByCohesion(_ context.Context, tracks []track.Track, n int) ([]int, error) {
+	var centers []track.Key
+	for _, t := range tracks {
+		if t.Key.Number > 0 && !slices.Contains(centers, t.Key) {
+			centers = append(centers, t.Key)
+		}
+	}
+	distance := func(center track.Key, t track.Track) int {
+		if t.Key.Number == 0 {
+			return math.MaxInt
+		}
+		return center.Distance(t.Key)
+	}
+	byCenter := func(center track.Key) []int {
+		order := identity(len(tracks))
+		slices.SortStableFunc(order, func(a, b int) int {
+			return cmp.Compare(distance(center, tracks[a]), distance(center, tracks[b]))
+		})
+		return order
+	}
+	if len(centers) == 0 {
+		return identity(len(tracks)), nil
+	}
+	var best []int
+	bestSpread := math.MaxInt
+	for _, center := range centers {
+		order := byCenter(center)
+		spread := 0
+		for _, i := range order[:n] {
+			spread = min(math.MaxInt/2, spread+distance(center, tracks[i]))
+		}
+		if spread < bestSpread {
+			best, bestSpread = order, spread
+		}
+	}
+	return best, nil
+}
+
+// rankByArc ranks tracks so every prefix spreads as widely and evenly over the
+// library's energies as it can: the calmest and the most energetic first, then each
+// time the track furthest in energy from those already ranked.
+func rankByArc(_ context.Context, tracks []track.Track, _ int) ([]int, error) {
+	if len(tracks) == 0 {
+		return nil, nil
+	}
+	ranked := make([]bool, len(tracks))
+	gap := make([]int, len(tracks)) // energy distance to the nearest ranked track
+	for i := range gap {
+		gap[i] = math.MaxInt
+	}
+	var order []int
+	take := func(i int) {
+		ranked[i] = true
+		order = append(order, i)
+		for j, t := range tracks {
+			d := t.Energy - tracks[i].Energy
+			gap[j] = min(gap[j], max(d, -d))
+		}
+	}
+	lo, hi := 0, 0
+	for i, t := range tracks {
+		if t.Energy < tracks[lo].Energy {
+			lo = i
+		}
+		if t.Energy > tracks[hi].Energy {
+			hi = i
+		}
+	}
+	take(lo)
+	if hi != lo {
+		take(hi)
+	}
+	for len(order) < len(tracks) {
+		next := -1
+		for i := range tracks {
+			if !ranked[i] && (next < 0 || gap[i] > gap[next]) {
+				next = i
+			}
+		}
+		take(next)
+	}
+	return order, nil
+}
+
+// rankByPopularity ranks tracks by popularity, the most popular first, then by
+// priority; tracks with neither keep their input order at the end.
+func rankByPopularity(_ context.Context, tracks []track.Track, _ int) ([]int, error) {
+	value := func(p *int) int {
+		if p == nil {
+			return -1
+		}
+		return *p
+	}
+	order := identity(len(tracks))
+	slices.SortStableFunc(order, func(a, b int) int {
+		return cmp.Or(cmp.Compare(value(tracks[b].Popularity), value(tracks[a].Popularity)),
+			cmp.Compare(value(tracks[b].Priority), value(tracks[a].Priority)))
+	})
+	return order, nil
+}
+
+// rankBalanced ranks tracks by their summed position under the other objectives, so
+// a track that does well on all of them beats one that tops only one. Popularity is
+// left out when no track has it.
+func rankBalanced(ctx context.Context, tracks []track.Track, n int) ([]int, error) {
+	rankers := []func(context.Context, []track.Track, int) ([]int, error){rankByMix, rankByCohesion, rankByArc}
+	if slices.ContainsFunc(tracks, func(t track.Track) bool { return t.Popularity != nil }) {
+		rankers = append(rankers, rankByPopularity)
+	}
+	sum := make([]int, len(tracks))
+	for _, rank := range rankers {
+		order, err := rank(ctx, tracks, n)
+		if err != nil {
+			return nil, err
+		}
+		for pos, i := range order {
+			sum[i] += pos
+		}
+	}
+	order := identity(len(tracks))
+	slices.SortStableFunc(order, func(a, b int) int { return cmp.Compare(sum[a], sum[b]) })
+	return order, nil
+}
@@ -0,0 +1,61 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSubsetObjectives(t *testing.T) {
+	pop := func(n int) *int { return &n }
+	keys := []string{"8A", "8A", "9A", "8B", "2B", "3A", "7A", "11B", "8A", "5B"}
+	var tracks []track.Track
+	for i, k := range keys {
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("t%d", i), Artist: "A", BPM: 124, Key: mustKey(k),
+			Energy: 40 + 2*i, Popularity: pop(i * 10)})
+	}
+	tracks[4].Energy, tracks[7].Energy = 10, 95
+	ctx := WithSeed(context.Background(), 1)
+	pick := func(name string, n int) []int {
+		t.Helper()
+		o, err := ParseSubsetObjective(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		picked, err := o.Select(ctx, tracks, n, 0, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(picked) != n {
+			t.Fatalf("%s picked %v, want %d tracks", name, picked, n)
+		}
+		return picked
+	}
+
+	if got := pick("cohesion", 5); !slices.Equal(got, []int{0, 1, 2, 3, 8}) {
+		t.Errorf("cohesion picked %v; want the 8A cluster", got)
+	}
+	if got := pick("arc", 3); !slices.Contains(got, 4) || !slices.Contains(got, 7) {
+		t.Errorf("arc picked %v; want the calmest and the most energetic", got)
+	}
+	if got := pick("popularity", 3); !slices.Equal(got, []int{7, 8, 9}) {
+		t.Errorf("popularity picked %v; want the three most popular", got)
+	}
+	pick("mix", 4)
+	pick("balanced", 4)
+
+	required := func(t track.Track) bool { return t.Title == "t5" }
+	o, _ := ParseSubsetObjective("popularity")
+	if got, _ := o.Select(ctx, tracks, 3, 0, required); !slices.Equal(got, []int{5, 8, 9}) {
+		t.Errorf("popularity with a required track picked %v; want it and the two most popular", got)
+	}
+	if got, _ := o.Select(ctx, tracks, 0, 3*avgTrackSeconds, nil); len(got) != 3 {
+		t.Errorf("popularity to a length picked %v; want 3 average-length tracks", got)
+	}
+	if _, err := ParseSubsetObjective("vibes"); err == nil {
+		t.Error(`ParseSubsetObjective("vibes"): want an error`)
+	}
+}
@@ -0,0 +1,57 @@
+package strategy
+
+import "github.com/YakDriver/magicmix/internal/track"
+
+// Summary is a run's outcome in one object, for wrapper scripts and GUIs that would
+// otherwise read it from the lines printed for a person: how many tracks went in and
+// came out, the set's mix score, what the run warned of, where the set was written,
+// and how long it took. Error is set when the run failed; the command line's
+// --summary-format json prints it then too.
+type Summary struct {
+	Strategy string   `json:"strategy"`
+	Seed     int64    `json:"seed,omitempty"`
+	Input    int      `json:"input_tracks"` // tracks considered: the set and those left out
+	Tracks   int      `json:"tracks"`       // tracks in the set
+	Dropped  int      `json:"dropped"`      // tracks left out of the set
+	Unplaced int      `json:"unplaced"`     // tracks a sort stopped early never placed
+	Partial  bool     `json:"partial"`
+	Score    float64  `json:"score"`                    // the set's mix score; lower is better
+	Length   float64  `json:"length_seconds,omitempty"` // the set's length, when every track has one
+	Warnings []string `json:"warnings"`
+	Notes    []string `json:"notes"`
+	Outputs  []string `json:"outputs"` // files written, the set first
+	Elapsed  float64  `json:"elapsed_seconds"`
+	Error    string   `json:"error,omitempty"`
+}
+
+// Summary summarizes the run r records. It has no outputs; whoever writes the set
+// adds them.
+func (r Result) Summary() Summary {
+	return Summary{
+		Strategy: r.Strategy,
+		Seed:     r.Seed,
+		Input:    r.Input,
+		Tracks:   len(r.Ordered),
+		Dropped:  max(0, r.Input-len(r.Ordered)-len(r.Unplaced)),
+		Unplaced: len(r.Unplaced),
+		Partial:  r.Partial,
+		Score:    mixTotal(r.Ordered, DefaultWeights),
+		Length:   knownLength(r.Ordered),
+		Warnings: append([]string{}, r.Warnings...),
+		Notes:    append([]string{}, r.Notes...),
+		Outputs:  []string{},
+		Elapsed:  r.Elapsed.Seconds(),
+	}
+}
+
+// knownLength is the total length of tracks in seconds, or 0 when any has none.
+func knownLength(tracks []track.Track) float64 {
+	total := 0
+	for _, t := range tracks {
+		if t.Duration == nil {
+			return 0
+		}
+		total += *t.Duration
+	}
+	return float64(total)
+}
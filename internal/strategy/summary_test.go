@@ -0,0 +1,38 @@
+package strategy
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+)
+
+func TestResultSummary(t *testing.T) {
+	tracks := chaveTracks(20)
+	sorter, _ := Get(defaultStrategyName)
+	res, err := Sort(WithLimit(WithSeed(context.Background(), 4), 12), sorter, tracks)
+	if err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+	s := res.Summary()
+	if s.Strategy != defaultStrategyName || s.Seed != 4 || s.Input != 20 || s.Tracks != len(res.Ordered) ||
+		s.Dropped != 20-len(res.Ordered) || s.Partial || s.Unplaced != 0 {
+		t.Errorf("summary %+v doesn't match the result", s)
+	}
+	if math.Abs(s.Score-ScoreMix(res.Ordered).Total) > 1e-9 || s.Length == 0 || s.Elapsed <= 0 {
+		t.Errorf("score %v, length %v, elapsed %v; want the set's mix score, its length, and the time taken",
+			s.Score, s.Length, s.Elapsed)
+	}
+
+	// Lists marshal as [], not null, so a wrapper can range over them unchecked.
+	data, err := json.Marshal(Result{}.Summary())
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, field := range []string{`"warnings":[]`, `"notes":[]`, `"outputs":[]`} {
+		if !strings.Contains(string(data), field) {
+			t.Errorf("empty summary %s: want %s", data, field)
+		}
+	}
+}
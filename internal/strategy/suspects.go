@@ -0,0 +1,213 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Suspect-tag tuning. An energy is suspect when it sits at least minEnergyMisfit points
+// and suspectSpread robust deviations from what its BPM and genre predict; a BPM is
+// suspect when doubling or halving it lands within halfTimeTolerance of its genre's
+// median tempo and it sits well off that median as tagged.
+const (
+	minSuspectLibrary = 8
+	minGenreTempos    = 5
+	minEnergyMisfit   = 25.0
+	suspectSpread     = 3.0
+	halfTimeTolerance = 0.08
+	halfTimeFar       = 0.7
+	minEnergySlope    = 0.25
+)
+
+// Suspect is a tag that looks wrong for its track: an energy far from what the
+// track's tempo and genre suggest against the rest of the library, or a BPM read at
+// half or double time.
+type Suspect struct {
+	Index    int     // into the tracks given to Suspects
+	Field    string  // "energy" or "bpm"
+	Value    float64 // as tagged
+	Expected float64 // what the track's context suggests
+	Reason   string
+}
+
+// Suspects finds tags in tracks that look mis-tagged, in track order. Energy is judged
+// by fitting each track's tagged energy against an estimate from its BPM, genre,
+// loudness, and danceability (track.InferEnergyWith, with WithGenres's taxonomy), so a
+// library tagged on its own scale is read on that scale; a track the fit misses by far
+// more than the rest is suspect. Tracks whose energy was itself inferred are not
+// judged, and a library of fewer than minSuspectLibrary tracks has too little to go on.
+func Suspects(ctx context.Context, tracks []track.Track) []Suspect {
+	if len(tracks) < minSuspectLibrary {
+		return nil
+	}
+	genres := genresFromContext(ctx)
+	var suspects []Suspect
+
+	var idx []int
+	var est, tagged []float64
+	for i, t := range tracks {
+		if !t.EnergyInferred && t.BPM > 0 {
+			idx = append(idx, i)
+			est = append(est, float64(track.InferEnergyWith(t, genres)))
+			tagged = append(tagged, float64(t.Energy))
+		}
+	}
+	if len(idx) >= minSuspectLibrary {
+		slope, intercept := robustFit(est, tagged)
+		residuals := make([]float64, len(idx))
+		for k := range idx {
+			residuals[k] = tagged[k] - (slope*est[k] + intercept)
+		}
+		center := medianOf(residuals)
+		deviations := make([]float64, len(residuals))
+		for k, r := range residuals {
+			deviations[k] = math.Abs(r - center)
+		}
+		limit := math.Max(minEnergyMisfit, suspectSpread*1.4826*medianOf(deviations))
+		// Expected values stay within the energies the rest of the library is tagged
+		// with, so the fit isn't trusted past the data it was drawn from.
+		lo, hi := 100.0, 1.0
+		for k := range idx {
+			if math.Abs(residuals[k]-center) <= limit {
+				lo, hi = math.Min(lo, tagged[k]), math.Max(hi, tagged[k])
+			}
+		}
+		for k, i := range idx {
+			if math.Abs(residuals[k]-center) <= limit {
+				continue
+			}
+			expected := math.Round(math.Max(lo, math.Min(hi, slope*est[k]+intercept+center)))
+			suspects = append(suspects, Suspect{
+				Index: i, Field: "energy", Value: tagged[k], Expected: expected,
+				Reason: energyReason(tracks[i], expected),
+			})
+		}
+	}
+
+	tempos := map[string][]float64{}
+	var all []float64
+	for _, t := range tracks {
+		if t.BPM > 0 {
+			family := genres.Family(t.Genre)
+			tempos[family] = append(tempos[family], t.BPM)
+			all = append(all, t.BPM)
+		}
+	}
+	libraryMedian := medianOf(all)
+	for i, t := range tracks {
+		if t.BPM <= 0 {
+			continue
+		}
+		median, scope := libraryMedian, "the library's"
+		if family := genres.Family(t.Genre); family != "" && len(tempos[family]) >= minGenreTempos {
+			median, scope = medianOf(tempos[family]), family
+		}
+		var expected float64
+		switch {
+		case t.BPM < halfTimeFar*median && math.Abs(2*t.BPM-median) <= halfTimeTolerance*median:
+			expected = 2 * t.BPM
+		case t.BPM*halfTimeFar > median && math.Abs(t.BPM/2-median) <= halfTimeTolerance*median:
+			expected = t.BPM / 2
+		default:
+			continue
+		}
+		kind := "half"
+		if expected < t.BPM {
+			kind = "double"
+		}
+		suspects = append(suspects, Suspect{
+			Index: i, Field: "bpm", Value: t.BPM, Expected: expected,
+			Reason: suspectBPMReason(kind, scope, median),
+		})
+	}
+	slices.SortStableFunc(suspects, func(a, b Suspect) int { return cmp.Compare(a.Index, b.Index) })
+	return suspects
+}
+
+// FixSuspects returns a copy of tracks with each suspect tag set to its expected value.
+func FixSuspects(tracks []track.Track, suspects []Suspect) []track.Track {
+	fixed := make([]track.Track, len(tracks))
+	copy(fixed, tracks)
+	for _, s := range suspects {
+		switch s.Field {
+		case "energy":
+			fixed[s.Index].Energy = int(s.Expected)
+		case "bpm":
+			fixed[s.Index].BPM = s.Expected
+		}
+	}
+	return fixed
+}
+
+func energyReason(t track.Track, expected float64) string {
+	from := "its tempo suggests"
+	if t.Genre != "" {
+		from = "its tempo and genre suggest"
+	}
+	if float64(t.Energy) > expected {
+		return "energy is far higher than " + from
+	}
+	return "energy is far lower than " + from
+}
+
+func suspectBPMReason(kind, scope string, median float64) string {
+	if scope != "the library's" {
+		scope += "'s"
+	}
+	return fmt.Sprintf("BPM looks read at %s time against %s median of %.0f", kind, scope, median)
+}
+
+// robustFit fits y = slope*x + intercept by least squares, then again without the
+// points the first fit misses by more than 2.5 standard deviations, so one bad tag
+// doesn't bend the line toward itself. A fit too flat to mean anything (a library
+// whose estimates barely track its tags) falls back to a slope of 1 through the median
+// offset.
+func robustFit(x, y []float64) (slope, intercept float64) {
+	slope, intercept = leastSquares(x, y, nil)
+	var sq float64
+	for i := range x {
+		r := y[i] - (slope*x[i] + intercept)
+		sq += r * r
+	}
+	sd := math.Sqrt(sq / float64(len(x)))
+	keep := make([]bool, len(x))
+	for i := range x {
+		keep[i] = math.Abs(y[i]-(slope*x[i]+intercept)) <= 2.5*sd
+	}
+	slope, intercept = leastSquares(x, y, keep)
+	if slope < minEnergySlope || math.IsNaN(slope) {
+		offsets := make([]float64, len(x))
+		for i := range x {
+			offsets[i] = y[i] - x[i]
+		}
+		return 1, medianOf(offsets)
+	}
+	return slope, intercept
+}
+
+// leastSquares fits y = slope*x + intercept over the points keep marks (all when keep
+// is nil). Points with no spread in x give a NaN slope.
+func leastSquares(x, y []float64, keep []bool) (slope, intercept float64) {
+	var n, sx, sy, sxx, sxy float64
+	for i := range x {
+		if keep != nil && !keep[i] {
+			continue
+		}
+		n++
+		sx += x[i]
+		sy += y[i]
+		sxx += x[i] * x[i]
+		sxy += x[i] * y[i]
+	}
+	den := n*sxx - sx*sx
+	if n == 0 || den == 0 {
+		return math.NaN(), 0
+	}
+	slope = (n*sxy - sx*sy) / den
+	return slope, (sy - slope*sx) / n
+}
@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSuspectsFindsMisTaggedEnergyAndHalfTime(t *testing.T) {
+	var tracks []track.Track
+	for i := range 12 {
+		bpm := 100 + float64(i)*5 // 100..155
+		tracks = append(tracks, track.Track{Title: "song", BPM: bpm, Energy: 30 + i*5})
+	}
+	tracks = append(tracks,
+		track.Track{Title: "ambient intro", BPM: 72, Energy: 95, Genre: "Ambient"},
+		track.Track{Title: "half-time", BPM: 64, Energy: 60},
+	)
+
+	suspects := Suspects(context.Background(), tracks)
+	got := map[string]Suspect{}
+	for _, s := range suspects {
+		got[tracks[s.Index].Title+"/"+s.Field] = s
+	}
+	if len(suspects) != 2 {
+		t.Fatalf("Suspects = %+v, want the ambient intro's energy and the half-time BPM", suspects)
+	}
+	if s, ok := got["ambient intro/energy"]; !ok || s.Expected > 40 {
+		t.Errorf("ambient intro energy suspect = %+v, want an expected energy well under 95", s)
+	}
+	if s, ok := got["half-time/bpm"]; !ok || s.Expected != 128 {
+		t.Errorf("half-time BPM suspect = %+v, want expected 128", s)
+	}
+
+	fixed := FixSuspects(tracks, suspects)
+	if fixed[12].Energy == 95 || fixed[13].BPM != 128 || tracks[12].Energy != 95 {
+		t.Errorf("FixSuspects = %+v, %+v; want both corrected and the input untouched", fixed[12], fixed[13])
+	}
+
+	if clean := Suspects(context.Background(), tracks[:12]); len(clean) != 0 {
+		t.Errorf("Suspects on a consistent library = %+v, want none", clean)
+	}
+}
@@ -0,0 +1,187 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Energy swing tuning. The running energy level at a point of the set is the mean
+// intensity of the swingWindow tracks starting there; a set's swing is the standard
+// deviation of that level over the set. Missing the target costs swingUnit per
+// intensity point: a set five points off weighs as much as a run of rough
+// transitions, so the search trades some smoothness for the shape but not all of it.
+const (
+	swingWindow = 4
+	swingUnit   = 2.0
+)
+
+// EnergySwing asks the whole set's energy to vary by about a target: how far its
+// running energy level strays from the set's average, in intensity points (0-100),
+// either Target itself or, for a named swing, Share of the library's own spread (the
+// standard deviation of its intensities), so a name means the same on a narrow
+// peak-time crate as on a whole library. A low
+// target holds one level, as a lounge set does, by mixing calmer and busier tracks
+// together; a high one plays dramatic peaks and valleys. The contour's terms look at
+// each step; the swing looks at the set as a whole, so it is what tells the two apart.
+// It is a soft rule: weighed against the mix score, not enforced.
+type EnergySwing struct {
+	Name   string // the named swing it came from, "" for a number
+	Target float64
+	Share  float64 // when > 0, the target is this share of the library's spread
+}
+
+// energySwings are the named swings, in the order they are listed.
+var energySwings = []EnergySwing{
+	{Name: "flat", Share: 0.2},
+	{Name: "moderate", Share: 0.5},
+	{Name: "dramatic", Share: 0.9},
+}
+
+// TargetFor is the swing s asks of a set drawn from tracks.
+func (s EnergySwing) TargetFor(tracks []track.Track) float64 {
+	if s.Share <= 0 {
+		return s.Target
+	}
+	vals := intensities(tracks)
+	return s.Share * swingOf(vals, identity(len(vals)), 1)
+}
+
+// ParseEnergySwing reads a named swing (flat, moderate, or dramatic) or a target in
+// intensity points.
+func ParseEnergySwing(s string) (EnergySwing, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	names := make([]string, len(energySwings))
+	for i, sw := range energySwings {
+		if sw.Name == s {
+			return sw, nil
+		}
+		names[i] = sw.Name
+	}
+	if target, err := strconv.ParseFloat(s, 64); err == nil {
+		if target < 0 || target > 50 {
+			return EnergySwing{}, fmt.Errorf("energy swing %q: want 0 to 50 intensity points", s)
+		}
+		return EnergySwing{Target: target}, nil
+	}
+	return EnergySwing{}, fmt.Errorf("unknown energy swing %q (want %s, or a number of intensity points)", s,
+		strings.Join(names, ", "))
+}
+
+const swingContextKey contextKey = "strategy.swing"
+
+// WithEnergySwing asks for a set whose running energy level strays from its average
+// by about s's target (see TargetFor), charging swingUnit per intensity point the
+// ordering misses it by, too flat or too restless alike (see weighSoftRules).
+func WithEnergySwing(ctx context.Context, s EnergySwing) context.Context {
+	return context.WithValue(ctx, swingContextKey, &s)
+}
+
+func energySwingFromContext(ctx context.Context) *EnergySwing {
+	if ctx == nil {
+		return nil
+	}
+	s, _ := ctx.Value(swingContextKey).(*EnergySwing)
+	return s
+}
+
+// Swing measures an ordering's energy swing, as EnergySwing targets it; 0 for a set
+// too short to have a running level.
+func Swing(tracks []track.Track) float64 {
+	return swingOf(intensities(tracks), identity(len(tracks)), swingWindow)
+}
+
+// swingOf is the standard deviation of the running level of vals played in the order
+// perm, window values at a time; a window of 1 gives their plain spread.
+func swingOf(vals []float64, perm []int, window int) float64 {
+	levels := runningLevels(vals, perm, window)
+	if len(levels) < 2 {
+		return 0
+	}
+	mean := 0.0
+	for _, l := range levels {
+		mean += l
+	}
+	mean /= float64(len(levels))
+	variance := 0.0
+	for _, l := range levels {
+		variance += (l - mean) * (l - mean)
+	}
+	return math.Sqrt(variance / float64(len(levels)))
+}
+
+// runningLevels is the mean of each window consecutive values of perm.
+func runningLevels(vals []float64, perm []int, window int) []float64 {
+	if len(perm) < window {
+		return nil
+	}
+	levels := make([]float64, len(perm)-window+1)
+	sum := 0.0
+	for i, idx := range perm {
+		sum += vals[idx]
+		if i >= window {
+			sum -= vals[perm[i-window]]
+		}
+		if i >= window-1 {
+			levels[i-window+1] = sum / float64(window)
+		}
+	}
+	return levels
+}
+
+// boundSwing is an EnergySwing resolved against one track list.
+type boundSwing struct {
+	target float64
+	vals   []float64
+}
+
+func bindSwing(tracks []track.Track, s EnergySwing) *boundSwing {
+	return &boundSwing{target: s.TargetFor(tracks), vals: intensities(tracks)}
+}
+
+func (b *boundSwing) cost(perm []int) float64 {
+	return swingUnit * math.Abs(swingOf(b.vals, perm, swingWindow)-b.target)
+}
+
+func (*boundSwing) soft() {}
+
+// conflicts marks the tracks whose moves change the swing most: those in the highest
+// and lowest stretches of the set, and the one furthest above and below the level
+// around it. A swing on target marks nothing.
+func (b *boundSwing) conflicts(perm []int, out []bool) {
+	if b.cost(perm) == 0 {
+		return
+	}
+	levels := runningLevels(b.vals, perm, swingWindow)
+	if len(levels) < 2 {
+		return
+	}
+	hi, lo := 0, 0
+	for i, l := range levels {
+		if l > levels[hi] {
+			hi = i
+		}
+		if l < levels[lo] {
+			lo = i
+		}
+	}
+	for k := range swingWindow {
+		out[hi+k], out[lo+k] = true, true
+	}
+	// off is how far the track at position i sits from the running level around it.
+	off := func(i int) float64 { return b.vals[perm[i]] - levels[min(max(i-swingWindow/2, 0), len(levels)-1)] }
+	above, below := 0, 0
+	for i := range perm {
+		if off(i) > off(above) {
+			above = i
+		}
+		if off(i) < off(below) {
+			below = i
+		}
+	}
+	out[above], out[below] = true, true
+}
@@ -0,0 +1,47 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestEnergySwingShapesTheSet(t *testing.T) {
+	if _, err := ParseEnergySwing("wild"); err == nil {
+		t.Error("an unknown swing parsed")
+	}
+	if s, err := ParseEnergySwing("6.5"); err != nil || s.Target != 6.5 {
+		t.Errorf("ParseEnergySwing(6.5) = %+v, %v", s, err)
+	}
+
+	// The same tracks climbing steadily swing far more than interleaved calm and busy.
+	var climb, mixed []track.Track
+	for i := range 12 {
+		climb = append(climb, track.Track{Energy: 30 + 5*i})
+	}
+	for i := range 6 {
+		mixed = append(mixed, climb[i], climb[11-i])
+	}
+	if Swing(climb) <= 2*Swing(mixed) {
+		t.Errorf("swing of a climb %.1f, of interleaved %.1f; want the climb far higher", Swing(climb), Swing(mixed))
+	}
+
+	tracks := chaveTracks(30)
+	sorter, _ := Get(flowStrategyName)
+	swings := map[string]float64{}
+	for _, name := range []string{"flat", "dramatic"} {
+		s, _ := ParseEnergySwing(name)
+		res, err := Sort(WithEnergySwing(WithSeed(context.Background(), 4), s), sorter, tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(res.Ordered) != len(tracks) {
+			t.Fatalf("%s: got %d tracks, want %d", name, len(res.Ordered), len(tracks))
+		}
+		swings[name] = Swing(res.Ordered)
+	}
+	if swings["flat"] >= swings["dramatic"] {
+		t.Errorf("flat set swings %.1f, dramatic %.1f; want flat lower", swings["flat"], swings["dramatic"])
+	}
+}
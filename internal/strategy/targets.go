@@ -0,0 +1,95 @@
+package strategy
+
+import (
+	"context"
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Energy-target tuning: a track within targetTolerance of its segment's target is
+// fine; beyond that each track costs targetUnit per targetTolerance of excess, on the
+// same scale as placement, so the optimizer pulls segments toward their targets.
+const (
+	targetTolerance = 10.0
+	targetUnit      = 1.0
+)
+
+// EnergyTarget asks the tracks starting inside a window of the set for energy near a
+// level — say a calm first segment of a radio show and a busier last one.
+type EnergyTarget struct {
+	Name   string // the target as written, for reporting
+	Window Window
+	Energy int // 0-100
+}
+
+const energyTargetContextKey contextKey = "strategy.energyTargets"
+
+// WithEnergyTargets adds energy targets to the context. Sort repairs any ordering that
+// strays from them, and the flow strategy optimizes them directly.
+func WithEnergyTargets(ctx context.Context, targets ...EnergyTarget) context.Context {
+	if len(targets) == 0 {
+		return ctx
+	}
+	all := append(append([]EnergyTarget(nil), energyTargetsFromContext(ctx)...), targets...)
+	return context.WithValue(ctx, energyTargetContextKey, all)
+}
+
+func energyTargetsFromContext(ctx context.Context) []EnergyTarget {
+	if ctx == nil {
+		return nil
+	}
+	targets, _ := ctx.Value(energyTargetContextKey).([]EnergyTarget)
+	return targets
+}
+
+// boundTargets is a set of energy targets resolved against one track list.
+type boundTargets struct {
+	targets []EnergyTarget
+	energy  []float64
+	timing  *boundPlacement // reuses placement's playtime positions
+	lo, hi  []float64       // per target, as set fractions
+}
+
+func bindTargets(tracks []track.Track, targets []EnergyTarget) *boundTargets {
+	b := &boundTargets{
+		targets: targets,
+		energy:  make([]float64, len(tracks)),
+		timing:  bindPlacement(tracks, nil),
+		lo:      make([]float64, len(targets)),
+		hi:      make([]float64, len(targets)),
+	}
+	for i, t := range tracks {
+		b.energy[i] = float64(t.Energy)
+	}
+	for r, target := range targets {
+		b.lo[r], b.hi[r] = target.Window.resolve(b.timing.total / 60)
+	}
+	return b
+}
+
+// visit calls fn with every position of perm whose track strays from the target of a
+// window it starts in, and the cost of straying.
+func (b *boundTargets) visit(perm []int, fn func(k int, cost float64)) {
+	at := b.timing.starts(perm)
+	for r, target := range b.targets {
+		for k, idx := range perm {
+			if at[k] < b.lo[r] || at[k] >= b.hi[r] {
+				continue
+			}
+			if off := math.Abs(b.energy[idx]-float64(target.Energy)) - targetTolerance; off > 0 {
+				fn(k, targetUnit*off/targetTolerance)
+			}
+		}
+	}
+}
+
+func (b *boundTargets) cost(perm []int) float64 {
+	total := 0.0
+	b.visit(perm, func(_ int, cost float64) { total += cost })
+	return total
+}
+
+func (b *boundTargets) conflicts(perm []int, out []bool) {
+	b.visit(perm, func(k int, _ float64) { out[k] = true })
+}
@@ -0,0 +1,66 @@
+package strategy
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestEnergyTargetsSteerSegments(t *testing.T) {
+	mk := func(title string, energy int) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: energy, Key: track.Key{Number: 8, Mode: track.ModeA}}
+	}
+	tracks := []track.Track{mk("hi1", 80), mk("hi2", 82), mk("lo1", 40), mk("lo2", 42)}
+	targets := []EnergyTarget{{Name: "calm start", Window: Window{Lo: 0, Hi: 0.5}, Energy: 40}}
+
+	b := bindTargets(tracks, targets)
+	if b.cost([]int{2, 3, 0, 1}) != 0 {
+		t.Error("calm tracks in the calm window should cost nothing")
+	}
+	if b.cost(identity(4)) == 0 {
+		t.Error("loud tracks in the calm window were not penalized")
+	}
+
+	ctx := WithEnergyTargets(WithSeed(context.Background(), 1), targets...)
+	res, err := Sort(ctx, NewDefaultSorter(), tracks)
+	if err != nil {
+		t.Fatalf("Sort: %v", err)
+	}
+	for _, tr := range res.Ordered[:2] {
+		if tr.Energy > 50 {
+			t.Fatalf("opening should be calm: %s", titles(res.Ordered))
+		}
+	}
+}
+
+func TestFitRuntimeStaysWithinLength(t *testing.T) {
+	mk := func(title string, secs, num int) track.Track {
+		return track.Track{Title: title, BPM: 124, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}, Duration: &secs}
+	}
+	tracks := []track.Track{mk("a", 200, 1), mk("b", 240, 2), mk("c", 180, 3), mk("d", 300, 4), mk("e", 220, 5)}
+
+	picked, err := FitRuntime(WithSeed(context.Background(), 1), tracks, 700, nil)
+	if err != nil {
+		t.Fatalf("FitRuntime: %v", err)
+	}
+	total := 0
+	for _, i := range picked {
+		total += *tracks[i].Duration
+	}
+	if total > 700 || total < 500 {
+		t.Fatalf("picked %v totalling %ds, want close to but within 700s", picked, total)
+	}
+	if all, _ := FitRuntime(context.Background(), tracks, 5000, nil); len(all) != len(tracks) {
+		t.Fatalf("a long enough runtime should keep every track, got %v", all)
+	}
+	isD := func(tr track.Track) bool { return tr.Title == "d" }
+	picked, err = FitRuntime(WithSeed(context.Background(), 1), tracks, 500, isD)
+	if err != nil {
+		t.Fatalf("FitRuntime: %v", err)
+	}
+	if !slices.Contains(picked, 3) {
+		t.Fatalf("the required track must be picked, got %v", picked)
+	}
+}
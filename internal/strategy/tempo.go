@@ -0,0 +1,104 @@
+package strategy
+
+import (
+	"context"
+	"math"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+const tempoMatchContextKey contextKey = "strategy.tempoMatch"
+
+// WithTempoMatch has the default strategy cost a tempo change as the gap to the
+// nearest related tempo m allows, so with track.TempoHalfDouble an 87 BPM track
+// leads into a 174 as smoothly as into another 87. Without it tempos compare
+// directly.
+func WithTempoMatch(ctx context.Context, m track.TempoMatch) context.Context {
+	return context.WithValue(ctx, tempoMatchContextKey, m)
+}
+
+func tempoMatchFromContext(ctx context.Context) track.TempoMatch {
+	if ctx != nil {
+		if m, ok := ctx.Value(tempoMatchContextKey).(track.TempoMatch); ok {
+			return m
+		}
+	}
+	return track.TempoDirect
+}
+
+// defaultBPMTolerance is the tempo change, in BPM, the stock BPM cost is built around:
+// a Tuning.GenreBPMTolerance of t scales a change by defaultBPMTolerance/t.
+const defaultBPMTolerance = 5.0
+
+const genresContextKey contextKey = "strategy.genres"
+
+// WithGenres has strategies resolve genres, as Tuning.GenreBPMTolerance does, with tx
+// instead of the built-in taxonomy, so user-defined genres and aliases count.
+func WithGenres(ctx context.Context, tx *genre.Taxonomy) context.Context {
+	return context.WithValue(ctx, genresContextKey, tx)
+}
+
+func genresFromContext(ctx context.Context) *genre.Taxonomy {
+	if ctx != nil {
+		if tx, ok := ctx.Value(genresContextKey).(*genre.Taxonomy); ok && tx != nil {
+			return tx
+		}
+	}
+	return genre.Default()
+}
+
+// genreBPMTolerance resolves tolerances (keyed by genre or family name) for each
+// distinct genre among tracks: its own genre's tolerance if listed, else its
+// family's. Genres with neither are left out.
+func genreBPMTolerance(tracks []track.Track, tolerances map[string]float64, tx *genre.Taxonomy) map[string]float64 {
+	if len(tolerances) == 0 {
+		return nil
+	}
+	byName := make(map[string]float64, len(tolerances))
+	for name, tol := range tolerances {
+		byName[strings.ToLower(tx.Normalize(name))] = tol
+	}
+	out := map[string]float64{}
+	for _, t := range tracks {
+		if _, done := out[t.Genre]; done || t.Genre == "" {
+			continue
+		}
+		if tol, ok := byName[strings.ToLower(tx.Normalize(t.Genre))]; ok {
+			out[t.Genre] = tol
+		} else if tol, ok := byName[strings.ToLower(tx.Family(t.Genre))]; ok {
+			out[t.Genre] = tol
+		}
+	}
+	return out
+}
+
+// exitBPMTolerance is the tolerance of the previous track's genre: the most any
+// transition out of it allows.
+func (s *mixState) exitBPMTolerance() float64 {
+	if tol, ok := s.bpmTolerance[s.prev.Genre]; ok {
+		return tol
+	}
+	return defaultBPMTolerance
+}
+
+// transitionBPMTolerance is the stricter of the tolerances of the previous track's
+// genre and candidate's.
+func (s *mixState) transitionBPMTolerance(candidate track.Track) float64 {
+	from := s.exitBPMTolerance()
+	to, ok := s.bpmTolerance[candidate.Genre]
+	if !ok {
+		to = defaultBPMTolerance
+	}
+	return math.Min(from, to)
+}
+
+func positiveValues(m map[string]float64) bool {
+	for _, v := range m {
+		if v <= 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,78 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// TieBreak says how the default strategy chooses between next tracks it scores the
+// same. Ties are where taste should decide, so a policy can favor one kind of track;
+// where it can't tell two tracks apart (a signal missing or equal), a coin flip from
+// the seed decides, as TieRandom always does.
+type TieBreak string
+
+const (
+	// TieRandom flips a coin.
+	TieRandom TieBreak = "random"
+	// TiePopular prefers the more popular track.
+	TiePopular TieBreak = "popular"
+	// TieOutro prefers the track with the longer mixable outro, leaving more room for
+	// the transition after it.
+	TieOutro TieBreak = "outro"
+	// TieOlder prefers the earlier release.
+	TieOlder TieBreak = "older"
+)
+
+// ParseTieBreak reads a TieBreak name; "" is TieRandom.
+func ParseTieBreak(s string) (TieBreak, error) {
+	switch tb := TieBreak(strings.ToLower(strings.TrimSpace(s))); tb {
+	case "":
+		return TieRandom, nil
+	case TieRandom, TiePopular, TieOutro, TieOlder:
+		return tb, nil
+	}
+	return "", fmt.Errorf("unknown tie-break %q (want random, popular, outro, or older)", s)
+}
+
+const tieBreakContextKey contextKey = "strategy.tieBreak"
+
+// WithTieBreak has the default strategy break ties between equally scored next tracks
+// by tb instead of a coin flip.
+func WithTieBreak(ctx context.Context, tb TieBreak) context.Context {
+	return context.WithValue(ctx, tieBreakContextKey, tb)
+}
+
+func tieBreakFromContext(ctx context.Context) TieBreak {
+	if ctx != nil {
+		if tb, ok := ctx.Value(tieBreakContextKey).(TieBreak); ok {
+			return tb
+		}
+	}
+	return TieRandom
+}
+
+// prefer compares a and b under the policy: negative when it favors a, positive when
+// it favors b, and 0 when it can't tell them apart.
+func (tb TieBreak) prefer(a, b track.Track) int {
+	switch tb {
+	case TiePopular:
+		return compareKnown(b.Popularity, a.Popularity)
+	case TieOutro:
+		return compareKnown(b.Outro, a.Outro)
+	case TieOlder:
+		return compareKnown(a.Year, b.Year)
+	}
+	return 0
+}
+
+// compareKnown compares two optional values, 0 when either is missing.
+func compareKnown(x, y *int) int {
+	if x == nil || y == nil {
+		return 0
+	}
+	return cmp.Compare(*x, *y)
+}
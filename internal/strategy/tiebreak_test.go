@@ -0,0 +1,36 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestDefaultTieBreak(t *testing.T) {
+	// Tracks the planner can't tell apart but for popularity and release year; the
+	// most popular is also the oldest.
+	var tracks []track.Track
+	for i := range 8 {
+		pop, year := 10*i, 2020-i
+		tracks = append(tracks, track.Track{Title: fmt.Sprintf("t%d", i), BPM: 124, Energy: 60, Key: mustKey("8A"),
+			Popularity: &pop, Year: &year})
+	}
+	ctx := WithSeed(context.Background(), 9)
+	for _, tb := range []TieBreak{TiePopular, TieOlder} {
+		got, err := NewDefaultSorter().Sort(WithTieBreak(ctx, tb), tracks)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// After the opener, each next track is the one left that the policy prefers.
+		for i := 2; i < len(got); i++ {
+			if *got[i].Popularity > *got[i-1].Popularity {
+				t.Fatalf("%s: %s follows %s, but the policy prefers it", tb, got[i].Title, got[i-1].Title)
+			}
+		}
+	}
+	if _, err := ParseTieBreak("shortest"); err == nil {
+		t.Error("ParseTieBreak should reject an unknown policy")
+	}
+}
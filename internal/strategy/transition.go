@@ -0,0 +1,44 @@
+package strategy
+
+import "github.com/YakDriver/magicmix/internal/track"
+
+// Transition describes the key move from one track into the next: the planner decides
+// with it and the score reports it, so both agree on what counts as a step, a wrap
+// around the wheel, or a mode change.
+type Transition struct {
+	FromKey track.Key
+	ToKey   track.Key
+
+	// Steps is the clockwise distance around the wheel, 0-11, ignoring mode.
+	Steps int
+	// Wrap is set when the move passes 12 back to 1 (11A to 2A, or 9A down to 8A).
+	Wrap bool
+	// ModeChange is set when the move switches between minor (A) and major (B).
+	ModeChange bool
+	// AnyKey is set when either track is key-agnostic (track.Track.AnyKey), so the
+	// move cannot clash whatever the keys.
+	AnyKey bool
+}
+
+// NewTransition describes playing b after a: a's exit key into b's entry key.
+func NewTransition(a, b track.Track) Transition {
+	t := KeyTransition(a.ExitKey(), b.EntryKey())
+	t.AnyKey = a.AnyKey() || b.AnyKey()
+	return t
+}
+
+// KeyTransition describes the move between two keys.
+func KeyTransition(from, to track.Key) Transition {
+	return Transition{
+		FromKey:    from,
+		ToKey:      to,
+		Steps:      from.Steps(to),
+		Wrap:       to.Number < from.Number,
+		ModeChange: from.Mode != to.Mode,
+	}
+}
+
+// Offset is the shortest signed number of wheel steps, -5 to 6 (positive is clockwise).
+func (t Transition) Offset() int {
+	return t.FromKey.Offset(t.ToKey)
+}
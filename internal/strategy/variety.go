@@ -0,0 +1,114 @@
+package strategy
+
+import (
+	"context"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// varietyUnit is the penalty for each thing a set keeps from the previous one: a
+// transition between the same two tracks (either way round), or the same opener or
+// closer. It is about one middling transition's coherence cost, so at weight 1 a
+// strategy that weighs rules against the mix score gives up a reused transition only
+// for a comparable one.
+const varietyUnit = 1.0
+
+// Variety asks a set to differ from Previous, an earlier set played to the same
+// crowd: not to repeat its transitions or open or close with the same track. Tracks
+// are matched by ID. Weight scales the penalty (0 counts as 1): higher trades more of
+// the mix score for difference.
+type Variety struct {
+	Previous []track.Track
+	Weight   float64
+}
+
+const varietyContextKey contextKey = "strategy.variety"
+
+// WithVariety asks for a set that differs from v.Previous, charging each transition,
+// opener, or closer it repeats (see weighSoftRules). An empty Previous leaves ctx
+// unchanged.
+func WithVariety(ctx context.Context, v Variety) context.Context {
+	if len(v.Previous) == 0 {
+		return ctx
+	}
+	return context.WithValue(ctx, varietyContextKey, &v)
+}
+
+func varietyFromContext(ctx context.Context) *Variety {
+	if ctx == nil {
+		return nil
+	}
+	v, _ := ctx.Value(varietyContextKey).(*Variety)
+	return v
+}
+
+// boundVariety is a Variety resolved against one track list: which pairs of tracks
+// were neighbors in the previous set, and which tracks opened and closed it.
+type boundVariety struct {
+	unit          float64
+	pairs         map[[2]int]bool
+	opener, close int // -1 when not among the tracks
+}
+
+func bindVariety(tracks []track.Track, v Variety) *boundVariety {
+	index := make(map[string]int, len(tracks))
+	for i, t := range tracks {
+		if _, ok := index[t.ID()]; !ok {
+			index[t.ID()] = i
+		}
+	}
+	at := func(t track.Track) int {
+		if i, ok := index[t.ID()]; ok {
+			return i
+		}
+		return -1
+	}
+	b := &boundVariety{unit: varietyUnit, pairs: map[[2]int]bool{}, opener: at(v.Previous[0]),
+		close: at(v.Previous[len(v.Previous)-1])}
+	if v.Weight > 0 {
+		b.unit *= v.Weight
+	}
+	for k := 1; k < len(v.Previous); k++ {
+		a, c := at(v.Previous[k-1]), at(v.Previous[k])
+		if a >= 0 && c >= 0 {
+			b.pairs[[2]int{a, c}], b.pairs[[2]int{c, a}] = true, true
+		}
+	}
+	return b
+}
+
+// cost is the penalty for each reused transition, opener, and closer in perm.
+func (b *boundVariety) cost(perm []int) float64 {
+	total := 0.0
+	b.each(perm, func(...int) { total += b.unit })
+	return total
+}
+
+func (*boundVariety) soft() {}
+
+func (b *boundVariety) conflicts(perm []int, out []bool) {
+	b.each(perm, func(positions ...int) {
+		for _, i := range positions {
+			out[i] = true
+		}
+	})
+}
+
+// each calls fn once for every reused opener or closer, with its position, and every
+// reused transition, with both of its positions.
+func (b *boundVariety) each(perm []int, fn func(positions ...int)) {
+	if len(perm) == 0 {
+		return
+	}
+	if perm[0] == b.opener {
+		fn(0)
+	}
+	if last := len(perm) - 1; last > 0 && perm[last] == b.close {
+		fn(last)
+	}
+	for i := 1; i < len(perm); i++ {
+		if b.pairs[[2]int{perm[i-1], perm[i]}] {
+			fn(i-1, i)
+		}
+	}
+}
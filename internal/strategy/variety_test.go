@@ -0,0 +1,35 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+)
+
+func TestSortVariesFromPreviousSet(t *testing.T) {
+	tracks := chaveTracks(30)
+	ctx := WithSeed(context.Background(), 5)
+
+	for _, name := range []string{defaultStrategyName, flowStrategyName, annealStrategyName} {
+		sorter, _ := Get(name)
+		previous, err := Sort(ctx, sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		prev := previous.Ordered
+		res, err := Sort(WithVariety(ctx, Variety{Previous: prev}), sorter, tracks)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		got := res.Ordered
+		if len(got) != len(prev) {
+			t.Fatalf("%s: got %d tracks, want %d", name, len(got), len(prev))
+		}
+		if got[0].ID() == prev[0].ID() || got[len(got)-1].ID() == prev[len(prev)-1].ID() {
+			t.Errorf("%s: opened on %s and closed on %s, as the previous set did (%s, %s)", name,
+				got[0].Title, got[len(got)-1].Title, prev[0].Title, prev[len(prev)-1].Title)
+		}
+		if pen := bindVariety(got, Variety{Previous: prev}).cost(identity(len(got))); pen > 2*varietyUnit {
+			t.Errorf("%s: the new set keeps %.0f of the previous set's transitions, openers, and closers", name, pen/varietyUnit)
+		}
+	}
+}
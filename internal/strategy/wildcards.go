@@ -0,0 +1,197 @@
+package strategy
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math"
+	"math/rand"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// Wildcard tuning. A track needs a surprise of at least wildcardMinSurprise to be a
+// wildcard; each pick is drawn at random from the wildcardShortlist most surprising
+// tracks that fit somewhere, so runs with different seeds surprise differently.
+const (
+	wildcardMinSurprise = 0.5
+	wildcardShortlist   = 5
+	wildcardSalt        = 0x77696c64
+)
+
+// Wildcard is a track InsertWildcards played on purpose from outside the set.
+type Wildcard struct {
+	Track    track.Track
+	Position int    // 0-based, in the set InsertWildcards returns
+	Reason   string // what makes it a surprise, e.g. "Disco, a genre the set doesn't play"
+}
+
+// InsertWildcards plays up to n tracks from pool that the set would not have picked —
+// another genre family, another era, a favorite the set left out — each between two
+// tracks it still mixes with by the key rules and within DefaultPartnerWindow of
+// tempo, so the surprise doesn't cost a clash. Wildcards go in the body of the set,
+// never as opener or closer and never back to back. Which surprising tracks are
+// picked is drawn from the seed in ctx. Fewer than n are returned when the pool runs
+// out of tracks that both surprise and fit.
+func InsertWildcards(ctx context.Context, set, pool []track.Track, n int) ([]track.Track, []Wildcard) {
+	if n <= 0 || len(set) < 2 {
+		return set, nil
+	}
+	seed, ok := seedFromContext(ctx)
+	if !ok || seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	rng := rand.New(rand.NewSource(seed ^ wildcardSalt))
+	profile := newSetProfile(set, genresFromContext(ctx))
+
+	inSet := map[string]bool{}
+	versions := map[string][]track.Track{} // the set's tracks, by FamilyKey
+	for _, t := range set {
+		inSet[t.ID()] = true
+		versions[FamilyKey(t)] = append(versions[FamilyKey(t)], t)
+	}
+	type candidate struct {
+		t        track.Track
+		surprise float64
+		reason   string
+	}
+	var candidates []candidate
+	for _, t := range pool {
+		if inSet[t.ID()] || slices.ContainsFunc(versions[FamilyKey(t)], func(v track.Track) bool { return SameFamily(t, v) }) {
+			continue
+		}
+		if s, reason := profile.surprise(t); s >= wildcardMinSurprise {
+			candidates = append(candidates, candidate{t, s, reason})
+		}
+	}
+	slices.SortStableFunc(candidates, func(a, b candidate) int { return cmp.Compare(b.surprise, a.surprise) })
+
+	out := slices.Clone(set)
+	wild := map[string]string{} // reason by track ID
+	for range n {
+		type fit struct {
+			c    int
+			slot int
+		}
+		var shortlist []fit
+		for ci, c := range candidates {
+			if _, used := wild[c.t.ID()]; used {
+				continue
+			}
+			if slot := wildcardSlot(out, c.t, wild); slot > 0 {
+				shortlist = append(shortlist, fit{ci, slot})
+				if len(shortlist) == wildcardShortlist {
+					break
+				}
+			}
+		}
+		if len(shortlist) == 0 {
+			break
+		}
+		pick := shortlist[rng.Intn(len(shortlist))]
+		c := candidates[pick.c]
+		out = slices.Insert(out, pick.slot, c.t)
+		wild[c.t.ID()] = c.reason
+	}
+
+	var cards []Wildcard
+	for i, t := range out {
+		if reason, ok := wild[t.ID()]; ok {
+			cards = append(cards, Wildcard{Track: t, Position: i, Reason: reason})
+		}
+	}
+	return out, cards
+}
+
+// wildcardSlot is the smoothest place to insert t into set (t goes before set[slot]),
+// between two tracks it mixes with and away from the other wildcards; 0 when there is
+// none.
+func wildcardSlot(set []track.Track, t track.Track, wild map[string]string) int {
+	best, bestCost := 0, math.Inf(1)
+	for slot := 1; slot < len(set); slot++ {
+		prev, next := set[slot-1], set[slot]
+		if _, ok := wild[prev.ID()]; ok {
+			continue
+		}
+		if _, ok := wild[next.ID()]; ok {
+			continue
+		}
+		if !mixes(prev, t, DefaultPartnerWindow) || !mixes(t, next, DefaultPartnerWindow) {
+			continue
+		}
+		cost := coherenceCost(prev, t, DefaultWeights) + coherenceCost(t, next, DefaultWeights) -
+			coherenceCost(prev, next, DefaultWeights)
+		if cost < bestCost {
+			best, bestCost = slot, cost
+		}
+	}
+	return best
+}
+
+// setProfile is what a set usually plays, for judging how surprising another track
+// would be in it.
+type setProfile struct {
+	tx       *genre.Taxonomy
+	families map[string]bool
+	genres   map[string]bool
+	year     int // median release year; 0 when unknown
+}
+
+func newSetProfile(set []track.Track, tx *genre.Taxonomy) setProfile {
+	p := setProfile{tx: tx, families: map[string]bool{}, genres: map[string]bool{}}
+	var years []int
+	for _, t := range set {
+		if t.Genre != "" {
+			p.families[tx.Family(t.Genre)] = true
+			p.genres[tx.Normalize(t.Genre)] = true
+		}
+		if t.Year != nil {
+			years = append(years, *t.Year)
+		}
+	}
+	if len(years) > 0 {
+		slices.Sort(years)
+		p.year = years[len(years)/2]
+	}
+	return p
+}
+
+// surprise scores how far t strays from the set, with the main reason why: a genre
+// family the set doesn't play counts 1 (a new genre within one of its families half
+// that), an era 15 or more years from the set's usual counts 1 (less when closer), and
+// a favorite (Priority 4 or 5) adds a half, since an old favorite is a welcome
+// surprise.
+func (p setProfile) surprise(t track.Track) (float64, string) {
+	score := 0.0
+	var reasons []string
+	if t.Genre != "" && len(p.families) > 0 {
+		switch {
+		case !p.families[p.tx.Family(t.Genre)]:
+			score++
+			reasons = append(reasons, fmt.Sprintf("%s, a genre the set doesn't play", t.Genre))
+		case !p.genres[p.tx.Normalize(t.Genre)]:
+			score += 0.5
+			reasons = append(reasons, fmt.Sprintf("%s, new to the set", t.Genre))
+		}
+	}
+	if t.Year != nil && p.year > 0 {
+		gap := *t.Year - p.year
+		if era := min(1, math.Abs(float64(gap))/15); era >= 0.5 {
+			score += era
+			dir := "older"
+			if gap > 0 {
+				dir = "newer"
+			}
+			reasons = append(reasons, fmt.Sprintf("from %d, %d years %s than the set's usual", *t.Year, int(math.Abs(float64(gap))), dir))
+		}
+	}
+	if t.Priority != nil && *t.Priority >= 4 {
+		score += 0.5
+		reasons = append(reasons, fmt.Sprintf("a favorite (priority %d)", *t.Priority))
+	}
+	return score, strings.Join(reasons, "; ")
+}
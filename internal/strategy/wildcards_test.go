@@ -0,0 +1,45 @@
+package strategy
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestInsertWildcardsPicksSurprisesThatFit(t *testing.T) {
+	year := func(y int) *int { return &y }
+	set := []track.Track{
+		{Title: "s1", BPM: 122, Energy: 50, Key: mustKey("8A"), Genre: "House", Year: year(2021)},
+		{Title: "s2", BPM: 122, Energy: 55, Key: mustKey("8A"), Genre: "House", Year: year(2020)},
+		{Title: "s3", BPM: 123, Energy: 60, Key: mustKey("9A"), Genre: "Deep House", Year: year(2022)},
+		{Title: "s4", BPM: 123, Energy: 65, Key: mustKey("9A"), Genre: "House", Year: year(2021)},
+		{Title: "s5", BPM: 124, Energy: 70, Key: mustKey("10A"), Genre: "House", Year: year(2021)},
+	}
+	pool := append(set[:0:0], set...)
+	pool = append(pool,
+		track.Track{Title: "disco", BPM: 122, Energy: 55, Key: mustKey("8A"), Genre: "Disco", Year: year(1979)},
+		track.Track{Title: "more house", BPM: 122, Energy: 55, Key: mustKey("8A"), Genre: "House", Year: year(2021)},
+		track.Track{Title: "clash", BPM: 122, Energy: 55, Key: mustKey("3B"), Genre: "Disco", Year: year(1979)},
+		track.Track{Title: "too fast", BPM: 140, Energy: 55, Key: mustKey("9A"), Genre: "Funk", Year: year(1975)},
+	)
+
+	out, wild := InsertWildcards(WithSeed(context.Background(), 1), set, pool, 2)
+	if len(wild) != 1 || wild[0].Track.Title != "disco" {
+		t.Fatalf("wildcards = %+v, want just the disco track (the others don't surprise or don't mix)", wild)
+	}
+	if len(out) != len(set)+1 || out[wild[0].Position].Title != "disco" {
+		t.Errorf("set = %v, want the disco track inserted at %d", out, wild[0].Position)
+	}
+	if wild[0].Position == 0 || wild[0].Position == len(out)-1 {
+		t.Errorf("a wildcard opened or closed the set at %d", wild[0].Position)
+	}
+	if !strings.Contains(wild[0].Reason, "a genre the set doesn't play") || !strings.Contains(wild[0].Reason, "from 1979") {
+		t.Errorf("reason = %q", wild[0].Reason)
+	}
+
+	if same, none := InsertWildcards(context.Background(), set, pool, 0); len(none) != 0 || len(same) != len(set) {
+		t.Error("no wildcards asked for should leave the set alone")
+	}
+}
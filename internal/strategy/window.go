@@ -0,0 +1,44 @@
+package strategy
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// SortWindow re-optimizes positions from..to-1 of an existing ordering and leaves the
+// tracks around them where they are — a repair for one rough patch of an otherwise good
+// set. The window is sorted by s on its own; the result is kept only if it lowers the
+// score of the whole set (so the joins into and out of the window count), otherwise the
+// ordering is returned unchanged with a note saying so.
+//
+// Limits and ordering rules (separation, placement) measure the whole set, so they are
+// not applied to the window. A sort stopped part-way keeps its unplaced tracks at the
+// end of the window and marks the Result partial.
+func SortWindow(ctx context.Context, s Sorter, tracks []track.Track, from, to int) (Result, error) {
+	if from < 0 || to > len(tracks) || from >= to {
+		return Result{}, fmt.Errorf("window %d-%d is outside a %d-track set", from+1, to, len(tracks))
+	}
+
+	windowCtx := context.WithValue(withoutRules(ctx), limitContextKey, 0)
+	window := tracks[from:to]
+	sorted, err := Sort(windowCtx, s, slices.Clone(window))
+	if err != nil {
+		return Result{}, err
+	}
+	resorted := append(sorted.Ordered, unplaced(window, sorted.Ordered)...)
+
+	res := Result{Partial: sorted.Partial, Warnings: sorted.Warnings}
+	candidate := slices.Concat(tracks[:from], resorted, tracks[to:])
+	if mixTotal(candidate, DefaultWeights) < mixTotal(tracks, DefaultWeights)-improvementEps {
+		res.Ordered = candidate
+	} else {
+		res.Ordered = slices.Clone(tracks)
+		res.Notes = append(res.Notes, fmt.Sprintf(
+			"positions %d-%d left as they were: re-sorting them did not improve the set", from+1, to))
+	}
+	res.Confidence = PlacementConfidence(res.Ordered)
+	return res, nil
+}
@@ -0,0 +1,52 @@
+package strategy
+
+import (
+	"context"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestSortWindowKeepsSurroundingsInPlace(t *testing.T) {
+	mk := func(title string, num int, bpm float64) track.Track {
+		return track.Track{Title: title, BPM: bpm, Energy: 60, Key: track.Key{Number: num, Mode: track.ModeA}}
+	}
+	// "rough" sits in the middle of an otherwise stepwise set.
+	tracks := []track.Track{mk("a", 1, 120), mk("b", 2, 121), mk("rough", 9, 128),
+		mk("c", 3, 122), mk("d", 4, 123), mk("e", 5, 124)}
+	ctx := WithSeed(context.Background(), 1)
+
+	res, err := SortWindow(ctx, NewFlowSorter(), tracks, 1, 5)
+	if err != nil {
+		t.Fatalf("SortWindow returned error: %v", err)
+	}
+	if len(res.Ordered) != len(tracks) {
+		t.Fatalf("got %d tracks, want %d", len(res.Ordered), len(tracks))
+	}
+	if res.Ordered[0].Title != "a" || res.Ordered[5].Title != "e" {
+		t.Fatalf("tracks outside the window moved: %v", titles(res.Ordered))
+	}
+	if mixTotal(res.Ordered, DefaultWeights) >= mixTotal(tracks, DefaultWeights) {
+		t.Fatalf("window repair did not improve the set: %v", titles(res.Ordered))
+	}
+
+	again, err := SortWindow(ctx, NewFlowSorter(), res.Ordered, 1, 5)
+	if err != nil {
+		t.Fatalf("second SortWindow returned error: %v", err)
+	}
+	if len(again.Notes) == 0 || titles(again.Ordered) != titles(res.Ordered) {
+		t.Fatalf("an already good window should be left alone with a note: %v %v", again.Notes, titles(again.Ordered))
+	}
+
+	if _, err := SortWindow(ctx, NewFlowSorter(), tracks, 4, 2); err == nil {
+		t.Fatal("expected an error for an empty window")
+	}
+}
+
+func titles(tracks []track.Track) string {
+	out := ""
+	for _, t := range tracks {
+		out += t.Title + " "
+	}
+	return out
+}
@@ -0,0 +1,198 @@
+package track
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Camelot wheel arithmetic. The wheel has 12 numbers in two rings: A (minor) and B
+// (major). One step around the wheel is a perfect fifth; the same number in the other
+// ring is the relative major/minor.
+
+// Steps returns how many clockwise steps around the wheel it takes to get from k's
+// number to to's number (0-11), ignoring mode. 8A to 9B is 1; 9A to 8A is 11.
+func (k Key) Steps(to Key) int {
+	return ((to.Number-k.Number)%12 + 12) % 12
+}
+
+// Offset returns the shortest signed number of steps from k to to, ignoring mode:
+// positive is clockwise, in the range -5 to 6.
+func (k Key) Offset(to Key) int {
+	d := k.Steps(to)
+	if d > 6 {
+		d -= 12
+	}
+	return d
+}
+
+// Distance is how far apart two keys are: the shortest number of wheel steps plus one
+// when the mode changes. Identical keys are 0, neighbors and relatives 1.
+func (k Key) Distance(other Key) int {
+	d := k.Offset(other)
+	if d < 0 {
+		d = -d
+	}
+	if k.Mode != other.Mode {
+		d++
+	}
+	return d
+}
+
+// Compatible reports whether two keys mix harmonically by the standard Camelot rules:
+// the same key, one step either way in the same ring, or the relative major/minor.
+func (k Key) Compatible(other Key) bool {
+	if k.Mode == other.Mode {
+		d := k.Offset(other)
+		return d >= -1 && d <= 1
+	}
+	return k.Number == other.Number
+}
+
+// Neighbors returns the keys compatible with k other than k itself: one step
+// clockwise, one step counter-clockwise, and the relative.
+func (k Key) Neighbors() []Key {
+	return []Key{k.Transpose(1), k.Transpose(-1), k.Relative()}
+}
+
+// Relative returns the relative major/minor: the same number in the other ring.
+func (k Key) Relative() Key {
+	if k.Mode == ModeA {
+		return Key{Number: k.Number, Mode: ModeB}
+	}
+	return Key{Number: k.Number, Mode: ModeA}
+}
+
+// Transpose moves k n steps around the wheel (clockwise when positive), keeping the
+// mode. Each step is a perfect fifth, so Transpose(7) is up a semitone and
+// Transpose(2) is the common "energy boost" move.
+func (k Key) Transpose(n int) Key {
+	return Key{Number: ((k.Number-1+n)%12+12)%12 + 1, Mode: k.Mode}
+}
+
+// notePitch is the pitch class (C = 0) of each natural note.
+var notePitch = map[byte]int{'c': 0, 'd': 2, 'e': 4, 'f': 5, 'g': 7, 'a': 9, 'b': 11}
+
+// Notation is a way of writing keys.
+type Notation string
+
+// The notations ParseKey reads and Key.In writes.
+const (
+	Camelot Notation = "camelot" // 8A, 8B
+	OpenKey Notation = "openkey" // 1m, 1d
+	Musical Notation = "musical" // Am, C
+)
+
+// ParseNotation reads a notation name: camelot, openkey (or "open key"), or musical.
+func ParseNotation(s string) (Notation, error) {
+	switch strings.ToLower(strings.Join(strings.Fields(s), "")) {
+	case "camelot":
+		return Camelot, nil
+	case "openkey":
+		return OpenKey, nil
+	case "musical":
+		return Musical, nil
+	}
+	return "", fmt.Errorf("unknown key notation %q (want camelot, openkey, or musical)", s)
+}
+
+// majorNames and minorNames spell each Camelot number as a musical key, with the
+// sharps and flats Mixed In Key uses.
+var (
+	majorNames = [12]string{"B", "F#", "Db", "Ab", "Eb", "Bb", "F", "C", "G", "D", "A", "E"}
+	minorNames = [12]string{"Abm", "Ebm", "Bbm", "Fm", "Cm", "Gm", "Dm", "Am", "Em", "Bm", "F#m", "C#m"}
+)
+
+// In writes k in notation n; Camelot is the same as String. The zero Key is "".
+func (k Key) In(n Notation) string {
+	switch {
+	case k.Number == 0:
+		return ""
+	case n == OpenKey:
+		return k.OpenKey()
+	case n == Musical:
+		return k.Musical()
+	}
+	return k.String()
+}
+
+// OpenKey writes k in Open Key notation: 8A is "1m", 8B "1d".
+func (k Key) OpenKey() string {
+	if k.Number == 0 {
+		return ""
+	}
+	mode := "d"
+	if k.Mode == ModeA {
+		mode = "m"
+	}
+	return fmt.Sprintf("%d%s", (k.Number+4)%12+1, mode)
+}
+
+// Musical writes k as a musical key: 8A is "Am", 8B "C".
+func (k Key) Musical() string {
+	if k.Number == 0 {
+		return ""
+	}
+	if k.Mode == ModeA {
+		return minorNames[k.Number-1]
+	}
+	return majorNames[k.Number-1]
+}
+
+// parseOpenKey reads Open Key notation: 1d (C major) through 12d, and 1m (A minor)
+// through 12m. Open Key 1 is Camelot 8.
+func parseOpenKey(s string) (Key, bool) {
+	if len(s) < 2 {
+		return Key{}, false
+	}
+	mode := ModeA
+	switch s[len(s)-1] {
+	case 'm':
+	case 'd':
+		mode = ModeB
+	default:
+		return Key{}, false
+	}
+	n, err := strconv.Atoi(s[:len(s)-1])
+	if err != nil || n < 1 || n > 12 {
+		return Key{}, false
+	}
+	return Key{Number: (n+6)%12 + 1, Mode: mode}, true
+}
+
+// parseMusicalKey reads a lowercased musical key: a note, an optional sharp or flat,
+// and a quality — none or "maj"/"major" for major, "m"/"min"/"minor" for minor.
+func parseMusicalKey(s string) (Key, bool) {
+	if s == "" {
+		return Key{}, false
+	}
+	pitch, ok := notePitch[s[0]]
+	if !ok {
+		return Key{}, false
+	}
+	rest := s[1:]
+	switch {
+	case strings.HasPrefix(rest, "#"), strings.HasPrefix(rest, "♯"):
+		pitch++
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "#"), "♯")
+	case strings.HasPrefix(rest, "b"), strings.HasPrefix(rest, "♭"):
+		pitch--
+		rest = strings.TrimPrefix(strings.TrimPrefix(rest, "b"), "♭")
+	}
+	var mode Mode
+	switch strings.TrimSpace(rest) {
+	case "", "maj", "major":
+		mode = ModeB
+	case "m", "min", "minor":
+		mode = ModeA
+	default:
+		return Key{}, false
+	}
+	// A fifth up (7 semitones) is one step clockwise; C major and A minor are 8.
+	ref := 0
+	if mode == ModeA {
+		ref = 9
+	}
+	steps := ((pitch-ref)*7%12 + 12) % 12
+	return Key{Number: (steps+7)%12 + 1, Mode: mode}, true
+}
@@ -0,0 +1,128 @@
+package track_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func key(t *testing.T, s string) track.Key {
+	t.Helper()
+	k, err := track.ParseKey(s)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return k
+}
+
+func TestKeyArithmetic(t *testing.T) {
+	tests := []struct {
+		from, to      string
+		steps, offset int
+		distance      int
+		compatible    bool
+	}{
+		{"8A", "8A", 0, 0, 0, true},
+		{"8A", "9A", 1, 1, 1, true},
+		{"9A", "8A", 11, -1, 1, true},
+		{"12B", "1B", 1, 1, 1, true},
+		{"8A", "8B", 0, 0, 1, true},
+		{"8A", "9B", 1, 1, 2, false},
+		{"8A", "10A", 2, 2, 2, false},
+		{"1A", "7A", 6, 6, 6, false},
+		{"3A", "10A", 7, -5, 5, false},
+	}
+	for _, tc := range tests {
+		a, b := key(t, tc.from), key(t, tc.to)
+		if got := a.Steps(b); got != tc.steps {
+			t.Errorf("%s.Steps(%s) = %d, want %d", tc.from, tc.to, got, tc.steps)
+		}
+		if got := a.Offset(b); got != tc.offset {
+			t.Errorf("%s.Offset(%s) = %d, want %d", tc.from, tc.to, got, tc.offset)
+		}
+		if got := a.Distance(b); got != tc.distance {
+			t.Errorf("%s.Distance(%s) = %d, want %d", tc.from, tc.to, got, tc.distance)
+		}
+		if got := a.Compatible(b); got != tc.compatible {
+			t.Errorf("%s.Compatible(%s) = %v, want %v", tc.from, tc.to, got, tc.compatible)
+		}
+	}
+}
+
+func TestKeyMoves(t *testing.T) {
+	k := key(t, "12A")
+	if got := k.Transpose(1).String(); got != "1A" {
+		t.Errorf("12A.Transpose(1) = %s", got)
+	}
+	if got := k.Transpose(-13).String(); got != "11A" {
+		t.Errorf("12A.Transpose(-13) = %s", got)
+	}
+	if got := k.Relative().String(); got != "12B" {
+		t.Errorf("12A.Relative() = %s", got)
+	}
+	var names []string
+	for _, n := range k.Neighbors() {
+		names = append(names, n.String())
+		if !k.Compatible(n) {
+			t.Errorf("neighbor %s not compatible with 12A", n)
+		}
+	}
+	if !slices.Equal(names, []string{"1A", "11A", "12B"}) {
+		t.Errorf("12A.Neighbors() = %v", names)
+	}
+}
+
+func TestParseKeyNotations(t *testing.T) {
+	tests := map[string]string{
+		"8A": "8A", "12b": "12B",
+		"1m": "8A", "1d": "8B", "6m": "1A", "12d": "7B",
+		"Am": "8A", "C": "8B", "F#m": "11A", "Gbm": "11A", "Db": "3B", "C#": "3B",
+		"Abm": "1A", "B": "1B", "Bb minor": "3A", "E major": "12B", "Ebmaj": "5B",
+		"G♯m": "1A", "Fm": "4A",
+	}
+	for in, want := range tests {
+		got, err := track.ParseKey(in)
+		if err != nil {
+			t.Errorf("ParseKey(%q): %v", in, err)
+			continue
+		}
+		if got.String() != want {
+			t.Errorf("ParseKey(%q) = %s, want %s", in, got, want)
+		}
+	}
+	for _, bad := range []string{"", "H", "13A", "Amaj7", "0d"} {
+		if _, err := track.ParseKey(bad); err == nil {
+			t.Errorf("ParseKey(%q) should fail", bad)
+		}
+	}
+}
+
+func TestKeyIn(t *testing.T) {
+	for n := 1; n <= 12; n++ {
+		for _, mode := range []track.Mode{track.ModeA, track.ModeB} {
+			k := track.Key{Number: n, Mode: mode}
+			for _, notation := range []track.Notation{track.Camelot, track.OpenKey, track.Musical} {
+				s := k.In(notation)
+				got, err := track.ParseKey(s)
+				if err != nil || got != k {
+					t.Errorf("%s in %s is %q, which parses back as %s (%v)", k, notation, s, got, err)
+				}
+			}
+		}
+	}
+	k := track.Key{Number: 8, Mode: track.ModeA}
+	if k.OpenKey() != "1m" || k.Musical() != "Am" || k.Relative().In(track.OpenKey) != "1d" {
+		t.Fatalf("8A = %s / %s, relative %s", k.OpenKey(), k.Musical(), k.Relative().In(track.OpenKey))
+	}
+	if (track.Key{}).In(track.Musical) != "" {
+		t.Fatal("the zero key should be empty in any notation")
+	}
+	tr := track.Track{Key: k, Modulations: []track.Key{{Number: 3, Mode: track.ModeA}}}
+	if got := tr.KeyStringIn(track.Musical); got != "Am/Bbm" {
+		t.Fatalf("KeyStringIn = %q, want Am/Bbm", got)
+	}
+	if n, err := track.ParseNotation("Open Key"); err != nil || n != track.OpenKey {
+		t.Fatalf("ParseNotation(Open Key) = %q, %v", n, err)
+	}
+}
@@ -0,0 +1,100 @@
+package track
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Colors are the color labels a track can carry, Rekordbox's eight track colors; Serato
+// and other software's colors are read as the nearest of them.
+var Colors = []string{"pink", "red", "orange", "yellow", "green", "aqua", "blue", "purple"}
+
+// colorRGB is each of Colors as Rekordbox writes it.
+var colorRGB = map[string]uint32{
+	"pink": 0xFF007F, "red": 0xFF0000, "orange": 0xFFA500, "yellow": 0xFFFF00,
+	"green": 0x00FF00, "aqua": 0x25FDE9, "blue": 0x0000FF, "purple": 0x660099,
+}
+
+// colorSynonyms are other names for Colors.
+var colorSynonyms = map[string]string{
+	"rose": "pink", "magenta": "pink", "cyan": "aqua", "turquoise": "aqua", "teal": "aqua",
+	"violet": "purple", "lilac": "purple",
+}
+
+// ParseColor reads a color label: one of Colors or a common other name for one,
+// ignoring case, or an RGB value as #RRGGBB, 0xRRGGBB, or RRGGBB, which is read as the
+// nearest of Colors. "" and an RGB white, gray, or black are no color.
+func ParseColor(s string) (string, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return "", nil
+	}
+	if _, ok := colorRGB[s]; ok {
+		return s, nil
+	}
+	if name, ok := colorSynonyms[s]; ok {
+		return name, nil
+	}
+	hex := strings.TrimPrefix(strings.TrimPrefix(s, "#"), "0x")
+	if len(hex) == 6 {
+		if rgb, err := strconv.ParseUint(hex, 16, 32); err == nil {
+			return nearestColor(uint32(rgb)), nil
+		}
+	}
+	return "", fmt.Errorf("unknown color %q (want %s, or an RGB value such as #FF0000)", s, strings.Join(Colors, ", "))
+}
+
+// ColorRGB is the RGB value Rekordbox writes for one of Colors, and false for any
+// other name.
+func ColorRGB(name string) (uint32, bool) {
+	rgb, ok := colorRGB[name]
+	return rgb, ok
+}
+
+// nearestColor is the one of Colors whose hue is closest to rgb's, or "" for a white,
+// gray, or black, which have no hue to go by. Going by hue reads the pale colors
+// Serato uses as the colors they are paler versions of.
+func nearestColor(rgb uint32) string {
+	hue, ok := colorHue(rgb)
+	if !ok {
+		return ""
+	}
+	best, bestDist := "", 361.0
+	for _, name := range Colors {
+		h, _ := colorHue(colorRGB[name])
+		d := math.Abs(hue - h)
+		if d > 180 {
+			d = 360 - d
+		}
+		if d < bestDist {
+			best, bestDist = name, d
+		}
+	}
+	return best
+}
+
+// colorHue is rgb's hue in degrees, and false when it is too unsaturated or dark to
+// have one worth going by.
+func colorHue(rgb uint32) (float64, bool) {
+	r, g, b := float64(rgb>>16&0xff)/255, float64(rgb>>8&0xff)/255, float64(rgb&0xff)/255
+	hi, lo := max(r, g, b), min(r, g, b)
+	if hi < 0.2 || hi-lo < 0.15*hi {
+		return 0, false
+	}
+	var h float64
+	switch hi {
+	case r:
+		h = math.Mod((g-b)/(hi-lo), 6)
+	case g:
+		h = (b-r)/(hi-lo) + 2
+	default:
+		h = (r-g)/(hi-lo) + 4
+	}
+	h *= 60
+	if h < 0 {
+		h += 360
+	}
+	return h, true
+}
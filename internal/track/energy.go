@@ -0,0 +1,70 @@
+package track
+
+import (
+	"math"
+
+	"github.com/YakDriver/magicmix/internal/genre"
+)
+
+// Energy inference tuning. Tempo sets the baseline: energyAtSlow at energySlowBPM
+// rising linearly to energyAtFast at energyFastBPM. Genre, loudness, and danceability
+// then nudge it when the source has them.
+const (
+	energySlowBPM = 70.0
+	energyFastBPM = 175.0
+	energyAtSlow  = 25.0
+	energyAtFast  = 85.0
+
+	loudnessPivot = -10.0 // LUFS considered neutral
+	loudnessSlope = 2.0   // energy points per dB above/below the pivot
+	loudnessCap   = 15.0
+
+	danceSlope = 0.25 // energy points per danceability point above/below 50
+)
+
+// genreEnergy nudges the tempo baseline for genres whose energy departs from what
+// their BPM suggests, keyed by canonical genre name. A genre with no entry of its own
+// uses its family's.
+var genreEnergy = map[string]float64{
+	"Hard Dance": 15, "Metal": 15, "Punk": 12, "Hard Techno": 15,
+	"Techno": 10, "Dubstep": 10, "Big Room": 10, "Drum and Bass": 8, "Trance": 8, "EDM": 8,
+	"House": 5, "Rock": 5, "Deep House": -5, "Soul": -5, "R&B": -5, "Jazz": -10,
+	"Folk": -12, "Acoustic": -15, "Downtempo": -15, "Classical": -20, "Ambient": -25,
+}
+
+// defaultGenres resolves genre tags for InferEnergy.
+var defaultGenres = genre.Default()
+
+// InferEnergy estimates a 1-100 energy for a track that has none, from its BPM, its
+// genre, and — when present — its loudness and danceability. It is a rough stand-in
+// so energy-aware strategies still work; a measured value is always better.
+func InferEnergy(t Track) int {
+	return InferEnergyWith(t, defaultGenres)
+}
+
+// InferEnergyWith is InferEnergy resolving genres through a custom taxonomy (e.g. one
+// extended with the user's aliases).
+func InferEnergyWith(t Track, genres *genre.Taxonomy) int {
+	bpm := t.BPM
+	// Very slow readings are usually half-time (a 60 BPM tag on a 120 BPM groove).
+	for bpm > 0 && bpm < energySlowBPM-10 {
+		bpm *= 2
+	}
+	frac := (bpm - energySlowBPM) / (energyFastBPM - energySlowBPM)
+	e := energyAtSlow + (energyAtFast-energyAtSlow)*math.Max(0, math.Min(1, frac))
+
+	if name := genres.Normalize(t.Genre); name != "" {
+		if off, ok := genreEnergy[name]; ok {
+			e += off
+		} else {
+			e += genreEnergy[genres.Family(name)]
+		}
+	}
+	if t.Loudness != nil {
+		e += math.Max(-loudnessCap, math.Min(loudnessCap, (*t.Loudness-loudnessPivot)*loudnessSlope))
+	}
+	if t.Danceability != nil {
+		e += danceSlope * float64(*t.Danceability-50)
+	}
+	return int(math.Round(math.Max(1, math.Min(100, e))))
+}
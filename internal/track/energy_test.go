@@ -0,0 +1,35 @@
+package track
+
+import "testing"
+
+func TestInferEnergyOrdering(t *testing.T) {
+	loud, quiet := -6.0, -16.0
+	dance, still := 85, 20
+
+	cases := []struct {
+		name          string
+		higher, lower Track
+	}{
+		{"faster tempo", Track{BPM: 128}, Track{BPM: 95}},
+		{"half-time reading", Track{BPM: 55}, Track{BPM: 80}},
+		{"techno over ambient", Track{BPM: 120, Genre: "Techno"}, Track{BPM: 120, Genre: "Ambient"}},
+		{"house over deep house", Track{BPM: 122, Genre: "House"}, Track{BPM: 122, Genre: "Deep House"}},
+		{"louder master", Track{BPM: 110, Loudness: &loud}, Track{BPM: 110, Loudness: &quiet}},
+		{"more danceable", Track{BPM: 110, Danceability: &dance}, Track{BPM: 110, Danceability: &still}},
+	}
+	for _, tc := range cases {
+		h, l := InferEnergy(tc.higher), InferEnergy(tc.lower)
+		if h <= l {
+			t.Errorf("%s: got %d <= %d", tc.name, h, l)
+		}
+	}
+}
+
+func TestInferEnergyBounds(t *testing.T) {
+	loud, quiet := 0.0, -40.0
+	top := InferEnergy(Track{BPM: 200, Genre: "hardstyle", Loudness: &loud})
+	bottom := InferEnergy(Track{BPM: 60, Genre: "ambient", Loudness: &quiet})
+	if top > 100 || bottom < 1 {
+		t.Fatalf("energy out of 1-100: top %d bottom %d", top, bottom)
+	}
+}
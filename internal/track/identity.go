@@ -0,0 +1,28 @@
+package track
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+)
+
+// ID is a stable identity for the track's recording, for matching it across runs and
+// libraries: a hash of its Fingerprint when it has one, and otherwise of its
+// RecordingKey (title and artists, normalized) and its length in seconds. Metadata
+// edits that don't change which recording it is — capitalization, accents, artist
+// order, a featured artist moved into the title — leave the ID alone; a different
+// version or a different length changes it. Two copies of one file in a library share
+// an ID.
+func (t Track) ID() string {
+	var src string
+	if t.Fingerprint != "" {
+		src = "fingerprint\x00" + t.Fingerprint
+	} else {
+		src = "recording\x00" + RecordingKey(t.Title, t.Artist)
+		if t.Duration != nil && *t.Duration > 0 {
+			src += "\x00" + strconv.Itoa(*t.Duration)
+		}
+	}
+	sum := sha256.Sum256([]byte(src))
+	return hex.EncodeToString(sum[:8])
+}
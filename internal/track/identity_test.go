@@ -0,0 +1,37 @@
+package track_test
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestTrackID(t *testing.T) {
+	length := func(s int) *int { return &s }
+	base := track.Track{Title: "Cola", Artist: "CamelPhat & Elderbrook", Duration: length(240), BPM: 122}
+	edited := track.Track{Title: "COLA", Artist: "Elderbrook, CamelPhat", Duration: length(240), BPM: 122.5, Energy: 70}
+	if base.ID() != edited.ID() {
+		t.Errorf("metadata edits changed the ID: %s vs %s", base.ID(), edited.ID())
+	}
+	if len(base.ID()) != 16 {
+		t.Errorf("ID %q should be 16 hex digits", base.ID())
+	}
+	for name, other := range map[string]track.Track{
+		"version":   {Title: "Cola (Extended Mix)", Artist: "CamelPhat & Elderbrook", Duration: length(240)},
+		"length":    {Title: "Cola", Artist: "CamelPhat & Elderbrook", Duration: length(410)},
+		"no length": {Title: "Cola", Artist: "CamelPhat & Elderbrook"},
+	} {
+		if other.ID() == base.ID() {
+			t.Errorf("a different %s should change the ID", name)
+		}
+	}
+
+	a := track.Track{Title: "Cola", Artist: "CamelPhat", Fingerprint: "AQADtMmUaUmS"}
+	b := track.Track{Title: "Cola (Radio Edit)", Artist: "Camel Phat", Duration: length(180), Fingerprint: "AQADtMmUaUmS"}
+	if a.ID() != b.ID() {
+		t.Error("tracks with the same fingerprint should share an ID whatever their tags say")
+	}
+	if a.ID() == (track.Track{Title: "Cola", Artist: "CamelPhat"}).ID() {
+		t.Error("a fingerprint should take the place of title and artist")
+	}
+}
@@ -0,0 +1,43 @@
+package track
+
+import (
+	"slices"
+	"strings"
+)
+
+// clearedLicenses are the License values, lower-cased, that clear a track for
+// broadcast.
+var clearedLicenses = []string{"cleared", "clear", "licensed", "approved", "ok", "yes", "y", "true", "1"}
+
+// worldwide are the Regions entries that clear a track everywhere.
+var worldwide = []string{"WW", "WORLD", "WORLDWIDE", "ALL", "GLOBAL"}
+
+// Cleared reports whether t's License clears it for broadcast: "cleared",
+// "licensed", "approved", "yes", and the like. A track with no license on record is
+// not cleared.
+func (t Track) Cleared() bool {
+	return slices.Contains(clearedLicenses, strings.ToLower(strings.TrimSpace(t.License)))
+}
+
+// ClearedIn reports whether t may air in region (a code such as "US"): t lists no
+// regions, or lists region or a worldwide entry ("WW", "worldwide").
+func (t Track) ClearedIn(region string) bool {
+	if len(t.Regions) == 0 {
+		return true
+	}
+	region = strings.ToUpper(strings.TrimSpace(region))
+	return slices.ContainsFunc(t.Regions, func(r string) bool { return r == region || slices.Contains(worldwide, r) })
+}
+
+// ParseRegions reads a regions cell: codes separated by commas, semicolons, pipes, or
+// spaces, upper-cased, blanks and duplicates dropped. It returns nil for an empty
+// cell.
+func ParseRegions(s string) []string {
+	var out []string
+	for _, r := range strings.FieldsFunc(s, func(c rune) bool { return strings.ContainsRune(",;| /", c) }) {
+		if r = strings.ToUpper(r); !slices.Contains(out, r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
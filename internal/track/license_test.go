@@ -0,0 +1,43 @@
+package track_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestCleared(t *testing.T) {
+	for license, want := range map[string]bool{"Cleared": true, " licensed ": true, "yes": true, "pending": false, "": false} {
+		if got := (track.Track{License: license}).Cleared(); got != want {
+			t.Errorf("Cleared(%q) = %v, want %v", license, got, want)
+		}
+	}
+}
+
+func TestClearedIn(t *testing.T) {
+	cases := []struct {
+		regions []string
+		region  string
+		want    bool
+	}{
+		{nil, "US", true},
+		{[]string{"US", "CA"}, "us", true},
+		{[]string{"GB"}, "US", false},
+		{[]string{"WORLDWIDE"}, "DE", true},
+	}
+	for _, c := range cases {
+		if got := (track.Track{Regions: c.regions}).ClearedIn(c.region); got != c.want {
+			t.Errorf("ClearedIn(%v, %q) = %v, want %v", c.regions, c.region, got, c.want)
+		}
+	}
+}
+
+func TestParseRegions(t *testing.T) {
+	if got := track.ParseRegions(" us, GB;us|de "); !slices.Equal(got, []string{"US", "GB", "DE"}) {
+		t.Errorf("ParseRegions = %v", got)
+	}
+	if got := track.ParseRegions(""); got != nil {
+		t.Errorf("ParseRegions of an empty cell = %v, want nil", got)
+	}
+}
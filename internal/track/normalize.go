@@ -0,0 +1,300 @@
+package track
+
+import (
+	"slices"
+	"strings"
+	"unicode"
+)
+
+// versionWords mark a parenthetical or dash suffix as a version descriptor ("Extended
+// Mix", "Radio Edit", "2011 Remaster") rather than part of the song's name.
+var versionWords = []string{
+	"remix", "mix", "edit", "version", "remaster", "remastered", "rework", "dub",
+	"vip", "bootleg", "flip", "instrumental", "acapella", "live", "extended", "radio",
+	"club", "refix", "cover", "demo", "mono", "stereo",
+}
+
+// originalDescriptors name the original version ("Song (Original Mix)"): BaseTitle
+// drops them, but a title carrying one is the song itself, not an alternate version.
+var originalDescriptors = []string{"original", "original mix", "original version"}
+
+// BaseTitle reduces a title to the song it is a version of: lowercased, with version
+// descriptors removed — bracketed ("Song (Extended Mix)", "Song [VIP]") or after a
+// dash ("Song - Radio Edit") — and punctuation and extra spaces collapsed. Two tracks
+// with the same BaseTitle and artist are versions of one song. Featured-artist credits
+// ("(feat. X)") are dropped too, since they name collaborators, not versions.
+func BaseTitle(title string) string {
+	s := stripFeaturing(strings.ToLower(title))
+	s = stripBracketed(s, isVersionDescriptor)
+	if before, after, ok := strings.Cut(s, " - "); ok && isVersionDescriptor(after) {
+		s = before
+	}
+	return collapse(s)
+}
+
+// IsAlternateVersion reports whether a title carries a version descriptor, i.e. it
+// names a remix, edit, or other version rather than the original.
+func IsAlternateVersion(title string) bool {
+	s := stripBracketed(stripFeaturing(strings.ToLower(title)), isOriginalDescriptor)
+	if before, after, ok := strings.Cut(s, " - "); ok && isOriginalDescriptor(after) {
+		s = before
+	}
+	return BaseTitle(title) != collapse(s)
+}
+
+// VersionArtists returns the artists a title's version descriptors credit, normalized
+// as Artists does: the remixer of "Song (Eric Prydz Remix)", both of "Song (A & B
+// Edit)". Descriptors that name no one, such as "Extended Mix", credit nobody.
+func VersionArtists(title string) []string {
+	var names []string
+	s := stripBracketed(strings.ToLower(title), func(inner string) bool {
+		if isVersionDescriptor(inner) {
+			names = append(names, inner)
+		}
+		return true
+	})
+	if _, after, ok := strings.Cut(s, " - "); ok && isVersionDescriptor(after) {
+		names = append(names, after)
+	}
+	var out []string
+	for _, name := range names {
+		var rest []string
+		for _, w := range strings.Fields(name) {
+			word := collapse(w)
+			if word != "" && (slices.Contains(versionWords, word) || word == "original" || strings.Trim(word, "0123456789") == "") {
+				continue
+			}
+			rest = append(rest, w)
+		}
+		for _, a := range Artists(strings.Join(rest, " ")) {
+			if !slices.Contains(out, a) {
+				out = append(out, a)
+			}
+		}
+	}
+	return out
+}
+
+// featuringMarkers introduce a featured-artist credit in a title or artist string.
+var featuringMarkers = []string{"feat.", "feat", "ft.", "ft", "featuring"}
+
+// stripFeaturing removes a featured-artist credit from a lowercased title, whether
+// bracketed ("song (feat. x) [remix]") or trailing ("song feat. x").
+func stripFeaturing(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		bracketed := strings.HasPrefix(w, "(") || strings.HasPrefix(w, "[")
+		if !slices.Contains(featuringMarkers, strings.TrimLeft(w, "([")) {
+			continue
+		}
+		if !bracketed {
+			return strings.Join(words[:i], " ")
+		}
+		closer := ")"
+		if w[0] == '[' {
+			closer = "]"
+		}
+		for j := i; j < len(words); j++ {
+			if strings.Contains(words[j], closer) {
+				rest := append(append([]string(nil), words[:i]...), words[j+1:]...)
+				return stripFeaturing(strings.Join(rest, " "))
+			}
+		}
+		return strings.Join(words[:i], " ")
+	}
+	return strings.Join(words, " ")
+}
+
+// artistSeparators split a credit into individual artists. "and" is deliberately
+// absent: it is too often part of a name ("Florence and the Machine").
+var artistSeparators = []string{
+	" feat. ", " feat ", " ft. ", " ft ", " featuring ", " & ", " x ", " vs. ", " vs ",
+	" with ", ", ", ",", ";", " / ", "/", " + ",
+}
+
+// NormalizeArtist folds a single artist name for matching: lowercase, diacritics
+// removed ("Beyoncé" = "Beyonce"), a leading "the" dropped, and punctuation and
+// spacing collapsed.
+func NormalizeArtist(name string) string {
+	s := collapse(foldDiacritics(strings.ToLower(name)))
+	return strings.TrimPrefix(s, "the ")
+}
+
+// Artists splits an artist credit into its individual, normalized artists, so "A feat.
+// B", "A & B", and "a x b" all yield [a b]. Order follows the credit; duplicates and
+// blanks are dropped.
+func Artists(credit string) []string {
+	parts := []string{strings.ToLower(credit)}
+	for _, sep := range artistSeparators {
+		var next []string
+		for _, p := range parts {
+			next = append(next, strings.Split(p, sep)...)
+		}
+		parts = next
+	}
+	var out []string
+	for _, p := range parts {
+		if a := NormalizeArtist(p); a != "" && !slices.Contains(out, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// SharesArtist reports whether two credits have any artist in common after
+// normalization, so a collaboration matches each of its artists.
+func SharesArtist(a, b string) bool {
+	other := Artists(b)
+	for _, x := range Artists(a) {
+		if slices.Contains(other, x) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordingKey identifies a recording across libraries that spell it differently:
+// title and artists folded like NormalizeArtist, featured artists moved from the title
+// to the credit, and the credit's artists taken in sorted order. Unlike BaseTitle it
+// keeps version descriptors, so an extended mix and the original have different keys.
+func RecordingKey(title, artist string) string {
+	lower := strings.ToLower(title)
+	artists := Artists(artist)
+	for _, a := range Artists(featuredIn(lower)) {
+		if !slices.Contains(artists, a) {
+			artists = append(artists, a)
+		}
+	}
+	slices.Sort(artists)
+	return collapse(foldDiacritics(stripFeaturing(lower))) + "|" + strings.Join(artists, ",")
+}
+
+// reissueMarkers mark a bracketed or dash suffix as naming another release or cut of
+// the same recording ("2011 Remaster", "Radio Edit", "Mono") rather than a different
+// version such as a remix.
+var reissueMarkers = []string{
+	"remaster", "remastered", "reissue", "mono", "stereo", "radio edit", "single version",
+	"album version", "explicit", "clean",
+}
+
+// DuplicateKey identifies copies of one recording in a library: RecordingKey with
+// reissue descriptors removed, so "Song", "Song - Remastered 2011", and "Song (Radio
+// Edit)" by one artist share a key, while "Song (Extended Mix)" does not.
+func DuplicateKey(title, artist string) string {
+	s := stripBracketed(strings.ToLower(title), isReissueDescriptor)
+	if before, after, ok := strings.Cut(s, " - "); ok && isReissueDescriptor(after) {
+		s = before
+	}
+	return RecordingKey(s, artist)
+}
+
+func isReissueDescriptor(s string) bool {
+	words := " " + collapse(s) + " "
+	for _, m := range reissueMarkers {
+		if strings.Contains(words, " "+m+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// featuredIn returns the artists named by a featured-artist credit in a lowercased
+// title ("x" for "song (feat. x) [remix]"), or "" when there is none.
+func featuredIn(s string) string {
+	words := strings.Fields(s)
+	for i, w := range words {
+		if !slices.Contains(featuringMarkers, strings.TrimLeft(w, "([")) {
+			continue
+		}
+		bracketed := strings.HasPrefix(w, "(") || strings.HasPrefix(w, "[")
+		var credit []string
+		for _, c := range words[i+1:] {
+			if end := strings.IndexAny(c, ")]"); end >= 0 && bracketed {
+				return strings.Join(append(credit, c[:end]), " ")
+			}
+			credit = append(credit, c)
+		}
+		return strings.Join(credit, " ")
+	}
+	return ""
+}
+
+// diacriticFolds maps common accented Latin letters to their base letter.
+var diacriticFolds = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ę': 'e', 'ě': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i',
+	'ñ': 'n', 'ń': 'n', 'ň': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u', 'ů': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ś': 's', 'š': 's', 'ß': 's',
+	'ź': 'z', 'ż': 'z', 'ž': 'z',
+	'ł': 'l', 'ř': 'r', 'đ': 'd',
+}
+
+func foldDiacritics(s string) string {
+	return strings.Map(func(r rune) rune {
+		if base, ok := diacriticFolds[r]; ok {
+			return base
+		}
+		return r
+	}, s)
+}
+
+// stripBracketed removes (...) and [...] groups that describe a version, keeping ones
+// that are part of the name, such as "(I Can't Get No) Satisfaction". describes says
+// which groups to remove.
+func stripBracketed(s string, describes func(string) bool) string {
+	var b strings.Builder
+	for len(s) > 0 {
+		open := strings.IndexAny(s, "([")
+		if open < 0 {
+			b.WriteString(s)
+			break
+		}
+		closer := ")"
+		if s[open] == '[' {
+			closer = "]"
+		}
+		end := strings.Index(s[open:], closer)
+		if end < 0 {
+			b.WriteString(s)
+			break
+		}
+		end += open
+		b.WriteString(s[:open])
+		if inner := s[open+1 : end]; !describes(inner) {
+			b.WriteString(inner)
+		}
+		s = s[end+1:]
+	}
+	return b.String()
+}
+
+func isVersionDescriptor(s string) bool {
+	if isOriginalDescriptor(s) {
+		return true
+	}
+	for _, w := range strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		if slices.Contains(versionWords, w) {
+			return true
+		}
+	}
+	return false
+}
+
+func isOriginalDescriptor(s string) bool {
+	return slices.Contains(originalDescriptors, collapse(s))
+}
+
+// collapse keeps letters and digits, turning every other run of characters into a
+// single space.
+func collapse(s string) string {
+	return strings.Join(strings.FieldsFunc(s, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}), " ")
+}
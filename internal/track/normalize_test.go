@@ -0,0 +1,155 @@
+package track_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestBaseTitle(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Strobe", "strobe"},
+		{"Strobe (Club Edit)", "strobe"},
+		{"Strobe [Extended Mix]", "strobe"},
+		{"Strobe - Radio Edit", "strobe"},
+		{"Strobe (Eric Prydz Remix)", "strobe"},
+		{"Blue Monday '88 - 2011 Remaster", "blue monday 88"},
+		{"(I Can't Get No) Satisfaction", "i can t get no satisfaction"},
+		{"Song - Part Two", "song part two"},
+		{"Strobe (Original Mix)", "strobe"},
+		{"Strobe - Original", "strobe"},
+	}
+	for _, tc := range tests {
+		if got := track.BaseTitle(tc.input); got != tc.want {
+			t.Errorf("BaseTitle(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+func TestIsAlternateVersion(t *testing.T) {
+	if track.IsAlternateVersion("Strobe") {
+		t.Error("plain title reported as an alternate version")
+	}
+	if !track.IsAlternateVersion("Strobe (Club Edit)") {
+		t.Error("edit not reported as an alternate version")
+	}
+	if track.IsAlternateVersion("Strobe (Original Mix)") {
+		t.Error("the original mix reported as an alternate version")
+	}
+}
+
+func TestVersionArtists(t *testing.T) {
+	tests := []struct {
+		title string
+		want  []string
+	}{
+		{"Strobe (Eric Prydz Remix)", []string{"eric prydz"}},
+		{"Strobe [Dubfire & Oliver Huntemann Extended Mix]", []string{"dubfire", "oliver huntemann"}},
+		{"Strobe - Beyoncé Edit", []string{"beyonce"}},
+		{"Strobe (Extended Mix)", nil},
+		{"Strobe (Original Mix)", nil},
+		{"Blue Monday - 2011 Remaster", nil},
+		{"Strobe (feat. Someone)", nil},
+	}
+	for _, tc := range tests {
+		if got := track.VersionArtists(tc.title); !slices.Equal(got, tc.want) {
+			t.Errorf("VersionArtists(%q) = %q, want %q", tc.title, got, tc.want)
+		}
+	}
+}
+
+func TestBaseTitleDropsFeaturing(t *testing.T) {
+	for _, title := range []string{"Song (feat. Someone)", "Song feat. Someone", "Song [ft. A & B]"} {
+		if got := track.BaseTitle(title); got != "song" {
+			t.Errorf("BaseTitle(%q) = %q, want song", title, got)
+		}
+		if track.IsAlternateVersion(title) {
+			t.Errorf("IsAlternateVersion(%q) = true, want false", title)
+		}
+	}
+	if got := track.BaseTitle("Song (feat. Someone) [VIP]"); got != "song" {
+		t.Errorf("BaseTitle with feat and VIP = %q, want song", got)
+	}
+}
+
+func TestArtists(t *testing.T) {
+	tests := []struct {
+		credit string
+		want   []string
+	}{
+		{"Daft Punk", []string{"daft punk"}},
+		{"Calvin Harris feat. Rihanna", []string{"calvin harris", "rihanna"}},
+		{"Skrillex & Diplo", []string{"skrillex", "diplo"}},
+		{"Disclosure x Sam Smith", []string{"disclosure", "sam smith"}},
+		{"BEYONCÉ, Jay-Z", []string{"beyonce", "jay z"}},
+		{"The Chemical Brothers", []string{"chemical brothers"}},
+		{"Florence and the Machine", []string{"florence and the machine"}},
+	}
+	for _, tc := range tests {
+		got := track.Artists(tc.credit)
+		if len(got) != len(tc.want) {
+			t.Errorf("Artists(%q) = %q, want %q", tc.credit, got, tc.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != tc.want[i] {
+				t.Errorf("Artists(%q) = %q, want %q", tc.credit, got, tc.want)
+				break
+			}
+		}
+	}
+}
+
+func TestSharesArtist(t *testing.T) {
+	if !track.SharesArtist("Calvin Harris feat. Rihanna", "rihanna") {
+		t.Error("collaboration should share its featured artist")
+	}
+	if !track.SharesArtist("Beyoncé", "BEYONCE") {
+		t.Error("diacritic and case variants should match")
+	}
+	if track.SharesArtist("Skrillex", "Diplo") {
+		t.Error("distinct artists should not match")
+	}
+}
+
+func TestRecordingKey(t *testing.T) {
+	same := [][2]string{
+		{"Cola", "CamelPhat & Elderbrook"},
+		{"COLA", "Elderbrook, CamelPhat"},
+		{"Colá", "camelphat x elderbrook"},
+	}
+	want := track.RecordingKey(same[0][0], same[0][1])
+	for _, c := range same[1:] {
+		if got := track.RecordingKey(c[0], c[1]); got != want {
+			t.Errorf("RecordingKey(%q, %q) = %q, want %q", c[0], c[1], got, want)
+		}
+	}
+	if track.RecordingKey("Cola (Extended Mix)", "CamelPhat") == track.RecordingKey("Cola", "CamelPhat") {
+		t.Error("different versions should not share a key")
+	}
+	if track.RecordingKey("Sweet Nothing", "Calvin Harris feat. Florence Welch") !=
+		track.RecordingKey("Sweet Nothing (feat. Florence Welch)", "Calvin Harris") {
+		t.Error("a featured artist in the title should match one in the credit")
+	}
+	if track.RecordingKey("Song (feat. X) [VIP]", "A") != track.RecordingKey("Song [VIP]", "A & X") {
+		t.Error("a bracketed featured credit mid-title should move to the credit")
+	}
+}
+
+func TestDuplicateKey(t *testing.T) {
+	want := track.DuplicateKey("1979", "The Smashing Pumpkins")
+	for _, title := range []string{"1979 - Remastered 2012", "1979 (Remastered)", "1979 [Radio Edit]", "1979 - Mono"} {
+		if got := track.DuplicateKey(title, "Smashing Pumpkins"); got != want {
+			t.Errorf("DuplicateKey(%q) = %q, want %q", title, got, want)
+		}
+	}
+	for _, title := range []string{"1979 (Extended Mix)", "1979 - Vocal Remix", "1980"} {
+		if track.DuplicateKey(title, "The Smashing Pumpkins") == want {
+			t.Errorf("DuplicateKey(%q) matches the original; it is a different version", title)
+		}
+	}
+}
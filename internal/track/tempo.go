@@ -0,0 +1,71 @@
+package track
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+// TempoMatch says which tempo relationships count as a match when comparing two
+// tracks' BPM. A DJ can mix an 87 BPM track into a 174 BPM one at double time, or
+// a 96 into a 128 by playing four beats over three.
+type TempoMatch string
+
+const (
+	// TempoDirect compares tempos as they are.
+	TempoDirect TempoMatch = "direct"
+	// TempoHalfDouble also matches half and double time.
+	TempoHalfDouble TempoMatch = "half-double"
+	// TempoThreeFour also matches half and double time and 3:4 (and 4:3).
+	TempoThreeFour TempoMatch = "three-four"
+)
+
+// ParseTempoMatch reads a TempoMatch name; "" is TempoDirect.
+func ParseTempoMatch(s string) (TempoMatch, error) {
+	switch m := TempoMatch(strings.ToLower(strings.TrimSpace(s))); m {
+	case "":
+		return TempoDirect, nil
+	case TempoDirect, TempoHalfDouble, TempoThreeFour:
+		return m, nil
+	}
+	return "", fmt.Errorf("unknown tempo match %q (want direct, half-double, or three-four)", s)
+}
+
+// ratios are the multiples of the outgoing tempo m lets the incoming one match.
+func (m TempoMatch) ratios() []float64 {
+	switch m {
+	case TempoHalfDouble:
+		return []float64{1, 2, 0.5}
+	case TempoThreeFour:
+		return []float64{1, 2, 0.5, 0.75, 4.0 / 3}
+	}
+	return []float64{1}
+}
+
+// Matches are the tempos m lets a track at from mix into, from itself first; TempoGap
+// is the distance to the nearest of them. A tempo that isn't known (0) matches only
+// itself.
+func (m TempoMatch) Matches(from float64) []float64 {
+	if from <= 0 {
+		return []float64{from}
+	}
+	matches := make([]float64, 0, len(m.ratios()))
+	for _, r := range m.ratios() {
+		matches = append(matches, from*r)
+	}
+	return matches
+}
+
+// TempoGap is how many BPM the tempo to is from the nearest tempo m relates to from:
+// with TempoHalfDouble, 87 to 174 is 0 and 87 to 170 is 4. With TempoDirect it is
+// just the difference.
+func TempoGap(from, to float64, m TempoMatch) float64 {
+	gap := math.Abs(to - from)
+	if from <= 0 || to <= 0 {
+		return gap
+	}
+	for _, r := range m.ratios()[1:] {
+		gap = math.Min(gap, math.Abs(to-from*r))
+	}
+	return gap
+}
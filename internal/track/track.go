@@ -2,8 +2,10 @@ package track
 
 import (
 	"fmt"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 )
 
 // Mode identifies whether a key is in the "A" (minor) or "B" (major) side of the Camelot wheel.
@@ -20,28 +22,38 @@ type Key struct {
 	Mode   Mode
 }
 
-// ParseKey converts a string such as 1A into a Key instance.
+// ParseKey reads a key in any of the notations DJ software writes — Camelot ("8A"),
+// Open Key ("1m", "1d"), or musical ("Am", "F#m", "Db", "Bb minor", "C major") — and
+// returns it as the Camelot key it names.
 func ParseKey(input string) (Key, error) {
+	if k, ok := parseCamelot(input); ok {
+		return k, nil
+	}
+	s := strings.ToLower(strings.TrimSpace(input))
+	if k, ok := parseOpenKey(s); ok {
+		return k, nil
+	}
+	if k, ok := parseMusicalKey(s); ok {
+		return k, nil
+	}
+	return Key{}, fmt.Errorf("invalid key %q: want Camelot (8A), Open Key (1m), or musical (Am) notation", input)
+}
+
+// parseCamelot reads Camelot notation: 1A through 12B.
+func parseCamelot(input string) (Key, bool) {
 	cleaned := strings.TrimSpace(strings.ToUpper(input))
 	if len(cleaned) < 2 || len(cleaned) > 3 {
-		return Key{}, fmt.Errorf("invalid key format: %q", input)
+		return Key{}, false
 	}
-
 	mode := Mode(cleaned[len(cleaned)-1:])
 	if mode != ModeA && mode != ModeB {
-		return Key{}, fmt.Errorf("invalid key mode: %q", input)
-	}
-
-	numberPart := cleaned[:len(cleaned)-1]
-	number, err := strconv.Atoi(numberPart)
-	if err != nil {
-		return Key{}, fmt.Errorf("invalid key number: %w", err)
+		return Key{}, false
 	}
-	if number < 1 || number > 12 {
-		return Key{}, fmt.Errorf("key number out of range: %d", number)
+	number, err := strconv.Atoi(cleaned[:len(cleaned)-1])
+	if err != nil || number < 1 || number > 12 {
+		return Key{}, false
 	}
-
-	return Key{Number: number, Mode: mode}, nil
+	return Key{Number: number, Mode: mode}, true
 }
 
 func (k Key) String() string {
@@ -60,16 +72,66 @@ func (k Key) String() string {
 type Track struct {
 	Title  string
 	Artist string
-	BPM    float64
+	BPM    float64 // tempo; for a variable-tempo track, the tempo it starts at
 	Energy int
 	Key    Key
 
+	// Modulations are the keys a track moves to after Key, in order (8A then 3A for
+	// "8A/3A"); nil when it stays in one key. Transitions into the track match Key,
+	// transitions out its last key.
+	Modulations []Key
+
+	// BPMEnd is the tempo a variable-tempo track (a 100→128 build) ends at; 0 when the
+	// tempo is steady. Transitions into the track match BPM, transitions out BPMEnd.
+	BPMEnd float64
+
 	Danceability *int // 0-100, higher = more danceable
 	Valence      *int // 0-100, higher = more positive/happy in mood
 	Popularity   *int // 0-100, higher = more popular
 	Acousticness *int // 0-100, higher = more acoustic
 	Duration     *int // track length in seconds
 	Year         *int // release year (e.g. 2024)
+	Phrase       *int // bars per phrase (e.g. 16 or 32)
+	Intro        *int // seconds of mixable intro before the track proper starts
+	Outro        *int // seconds of mixable outro after the track proper ends
+	Priority     *int // 1-5, how much the DJ wants it played: 5 a favorite, 1 filler
+	Cues         *int // hot and memory cues the DJ has set; 0 for none, nil when unknown
+
+	// Color is the color label the DJ gave the track in their DJ software, one of
+	// Colors ("red"); "" when it has none.
+	Color string
+
+	Added *time.Time // when the track entered the library ("date added"); nil when unknown
+
+	// License is the track's broadcast clearance as the source gives it ("cleared",
+	// "pending"); "" when unknown. See Cleared.
+	License string
+
+	// Regions are the territories the track is cleared for, upper-cased ("US", "GB");
+	// nil when the source sets no limit. See ClearedIn.
+	Regions []string
+
+	Location string // path or URL of the audio file, for playlist output; "" when absent
+
+	// Fingerprint identifies the recording by its audio (an AcoustID fingerprint, say),
+	// as given by the source; "" when absent. ID prefers it to title and artist.
+	Fingerprint string
+
+	Genre    string   // free-text genre as given by the source; "" when absent
+	Album    string   // the album or EP the track was released on; "" when absent
+	Loudness *float64 // integrated loudness in LUFS (e.g. -8.5)
+
+	// EnergyInferred marks an Energy estimated by InferEnergy because the source had
+	// none, so output and reports can say so.
+	EnergyInferred bool
+
+	// Locked marks a track of the DJ's hand-built backbone: the set keeps the locked
+	// tracks in the order the source lists them, whatever it plays between them.
+	Locked bool
+
+	// Tags are free-form lowercase labels (e.g. "singalong", "vocal") used by filter
+	// expressions and placement rules. nil when the source had no tags.
+	Tags []string
 
 	// Raw is the original CSV row this track was parsed from, kept so output can be a
 	// faithful pass-through of the input (same columns and order). nil when the track
@@ -84,8 +146,18 @@ func (t Track) Clone() Track {
 		Title:  t.Title,
 		Artist: t.Artist,
 		BPM:    t.BPM,
+		BPMEnd: t.BPMEnd,
 		Energy: t.Energy,
 		Key:    t.Key,
+
+		Color:          t.Color,
+		License:        t.License,
+		Location:       t.Location,
+		Fingerprint:    t.Fingerprint,
+		Genre:          t.Genre,
+		Album:          t.Album,
+		EnergyInferred: t.EnergyInferred,
+		Locked:         t.Locked,
 	}
 	clone.Danceability = copyIntPtr(t.Danceability)
 	clone.Valence = copyIntPtr(t.Valence)
@@ -93,12 +165,135 @@ func (t Track) Clone() Track {
 	clone.Acousticness = copyIntPtr(t.Acousticness)
 	clone.Duration = copyIntPtr(t.Duration)
 	clone.Year = copyIntPtr(t.Year)
+	clone.Phrase = copyIntPtr(t.Phrase)
+	clone.Intro = copyIntPtr(t.Intro)
+	clone.Outro = copyIntPtr(t.Outro)
+	clone.Priority = copyIntPtr(t.Priority)
+	clone.Cues = copyIntPtr(t.Cues)
+	if t.Loudness != nil {
+		v := *t.Loudness
+		clone.Loudness = &v
+	}
+	if t.Added != nil {
+		v := *t.Added
+		clone.Added = &v
+	}
+	if t.Modulations != nil {
+		clone.Modulations = append([]Key(nil), t.Modulations...)
+	}
+	if t.Tags != nil {
+		clone.Tags = append([]string(nil), t.Tags...)
+	}
+	if t.Regions != nil {
+		clone.Regions = append([]string(nil), t.Regions...)
+	}
 	if t.Raw != nil {
 		clone.Raw = append([]string(nil), t.Raw...)
 	}
 	return clone
 }
 
+// EntryKey is the key a transition into the track has to match.
+func (t Track) EntryKey() Key { return t.Key }
+
+// ExitKey is the key a transition out of the track leaves from: the last modulation
+// for a track that changes key, otherwise Key.
+func (t Track) ExitKey() Key {
+	if n := len(t.Modulations); n > 0 {
+		return t.Modulations[n-1]
+	}
+	return t.Key
+}
+
+// AnyKeyTag is the tag that marks a track as key-agnostic: a sweep, an ambient bed,
+// or a sound effect with no tonal center to clash with.
+const AnyKeyTag = "anykey"
+
+// AnyKey reports whether the track is tagged AnyKeyTag, so it mixes into and out of
+// any key whatever its Key says.
+func (t Track) AnyKey() bool { return slices.Contains(t.Tags, AnyKeyTag) }
+
+// Keys returns every key the track plays in, in order.
+func (t Track) Keys() []Key {
+	return append([]Key{t.Key}, t.Modulations...)
+}
+
+// KeyString formats the key as "8A" or, for a track that modulates, "8A/3A".
+func (t Track) KeyString() string {
+	return t.KeyStringIn(Camelot)
+}
+
+// KeyStringIn formats the key like KeyString, in notation n: "1m/8m" in Open Key.
+func (t Track) KeyStringIn(n Notation) string {
+	parts := make([]string, 0, 1+len(t.Modulations))
+	for _, k := range t.Keys() {
+		parts = append(parts, k.In(n))
+	}
+	return strings.Join(parts, "/")
+}
+
+// ParseKeys reads a key cell in any notation ParseKey reads: "8A", or a list such as "8A/3A" (also "8A→3A") for a
+// track that changes key. It returns the first key and the modulations after it.
+func ParseKeys(s string) (Key, []Key, error) {
+	parts := strings.Split(strings.ReplaceAll(s, "→", "/"), "/")
+	first, err := ParseKey(parts[0])
+	if err != nil {
+		return Key{}, nil, err
+	}
+	var mods []Key
+	for _, p := range parts[1:] {
+		k, err := ParseKey(p)
+		if err != nil {
+			return Key{}, nil, err
+		}
+		mods = append(mods, k)
+	}
+	return first, mods, nil
+}
+
+// EntryBPM is the tempo a transition into the track has to match.
+func (t Track) EntryBPM() float64 { return t.BPM }
+
+// ExitBPM is the tempo a transition out of the track leaves from: BPMEnd for a
+// variable-tempo track, otherwise BPM.
+func (t Track) ExitBPM() float64 {
+	if t.BPMEnd > 0 {
+		return t.BPMEnd
+	}
+	return t.BPM
+}
+
+// TempoString formats the tempo as "124" or, for a variable-tempo track, "100-128".
+func (t Track) TempoString() string {
+	s := strconv.FormatFloat(t.BPM, 'f', -1, 64)
+	if t.BPMEnd > 0 {
+		s += "-" + strconv.FormatFloat(t.BPMEnd, 'f', -1, 64)
+	}
+	return s
+}
+
+// ParseTempo reads a tempo cell: "124", or a range such as "100-128" (or
+// "100→128") for a track that changes tempo. end is 0 for a steady tempo.
+func ParseTempo(s string) (start, end float64, err error) {
+	s = strings.TrimSpace(s)
+	lo, hi, ranged := strings.Cut(strings.ReplaceAll(s, "→", "-"), "-")
+	start, err = strconv.ParseFloat(strings.TrimSpace(lo), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bpm %q: %w", s, err)
+	}
+	if !ranged {
+		return start, 0, nil
+	}
+	end, err = strconv.ParseFloat(strings.TrimSpace(hi), 64)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid bpm %q: %w", s, err)
+	}
+	if end == start {
+		end = 0
+	}
+	return start, end, nil
+}
+
 func copyIntPtr(p *int) *int {
 	if p == nil {
 		return nil
@@ -1,6 +1,8 @@
 package track_test
 
 import (
+	"math"
+	"slices"
 	"testing"
 
 	"github.com/YakDriver/magicmix/internal/track"
@@ -44,3 +46,97 @@ func TestKeyString(t *testing.T) {
 		t.Fatalf("Key.String() = %s, want 7A", got)
 	}
 }
+
+func TestParseTempo(t *testing.T) {
+	tests := []struct {
+		in         string
+		start, end float64
+		wantErr    bool
+	}{
+		{"124", 124, 0, false},
+		{" 100-128 ", 100, 128, false},
+		{"100 → 128", 100, 128, false},
+		{"120-120", 120, 0, false},
+		{"fast", 0, 0, true},
+		{"100-", 0, 0, true},
+	}
+	for _, tc := range tests {
+		start, end, err := track.ParseTempo(tc.in)
+		if (err != nil) != tc.wantErr {
+			t.Fatalf("ParseTempo(%q) error = %v, wantErr %v", tc.in, err, tc.wantErr)
+		}
+		if err == nil && (start != tc.start || end != tc.end) {
+			t.Errorf("ParseTempo(%q) = %v, %v; want %v, %v", tc.in, start, end, tc.start, tc.end)
+		}
+	}
+	if got := (track.Track{BPM: 100, BPMEnd: 128}).TempoString(); got != "100-128" {
+		t.Errorf("TempoString = %q", got)
+	}
+}
+
+func TestParseKeys(t *testing.T) {
+	first, mods, err := track.ParseKeys("8A/3A")
+	if err != nil {
+		t.Fatalf("ParseKeys: %v", err)
+	}
+	tr := track.Track{Key: first, Modulations: mods}
+	if tr.EntryKey().String() != "8A" || tr.ExitKey().String() != "3A" || tr.KeyString() != "8A/3A" {
+		t.Fatalf("unexpected keys: entry %s exit %s string %s", tr.EntryKey(), tr.ExitKey(), tr.KeyString())
+	}
+	if _, mods, _ := track.ParseKeys("5B"); mods != nil {
+		t.Fatalf("single key should have no modulations, got %v", mods)
+	}
+	if _, _, err := track.ParseKeys("8A/13A"); err == nil {
+		t.Fatal("expected error for invalid modulation")
+	}
+}
+
+func TestTempoGap(t *testing.T) {
+	tests := []struct {
+		from, to float64
+		m        track.TempoMatch
+		want     float64
+	}{
+		{87, 174, track.TempoDirect, 87},
+		{87, 174, track.TempoHalfDouble, 0},
+		{174, 87, track.TempoHalfDouble, 0},
+		{87, 170, track.TempoHalfDouble, 4},
+		{128, 124, track.TempoHalfDouble, 4},
+		{128, 96, track.TempoHalfDouble, 32},
+		{128, 96, track.TempoThreeFour, 0},
+		{96, 128, track.TempoThreeFour, 0},
+		{0, 120, track.TempoHalfDouble, 120},
+	}
+	for _, tc := range tests {
+		if got := track.TempoGap(tc.from, tc.to, tc.m); math.Abs(got-tc.want) > 1e-9 {
+			t.Errorf("TempoGap(%v, %v, %s) = %v, want %v", tc.from, tc.to, tc.m, got, tc.want)
+		}
+	}
+	if got := track.TempoHalfDouble.Matches(120); !slices.Equal(got, []float64{120, 240, 60}) {
+		t.Errorf("Matches(120) = %v, want 120 first, then double and half", got)
+	}
+	if got := track.TempoThreeFour.Matches(0); !slices.Equal(got, []float64{0}) {
+		t.Errorf("Matches(0) = %v, want just 0", got)
+	}
+	if m, err := track.ParseTempoMatch(" Half-Double "); err != nil || m != track.TempoHalfDouble {
+		t.Errorf("ParseTempoMatch = %q, %v", m, err)
+	}
+	if _, err := track.ParseTempoMatch("triplet"); err == nil {
+		t.Error("an unknown tempo match should fail")
+	}
+}
+
+func TestParseColor(t *testing.T) {
+	for in, want := range map[string]string{
+		"Red": "red", " violet ": "purple", "0xFF0000": "red", "#25FDE9": "aqua",
+		"FF9999": "red", "#99BBFF": "blue", "#FFDD99": "orange", "#DDFF99": "yellow", "#FFFFFF": "", "": "",
+	} {
+		got, err := track.ParseColor(in)
+		if err != nil || got != want {
+			t.Errorf("ParseColor(%q) = %q, %v; want %q", in, got, err, want)
+		}
+	}
+	if _, err := track.ParseColor("chartreuse-ish"); err == nil {
+		t.Error("an unknown name should be an error")
+	}
+}
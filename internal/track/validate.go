@@ -0,0 +1,132 @@
+package track
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Tempos outside this range are valid but flagged as warnings; they are usually
+// half/double readings or typos.
+const (
+	SuspiciousBPMLo = 50.0
+	SuspiciousBPMHi = 220.0
+)
+
+// Severity says whether an Issue makes a track unusable or merely suspicious.
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+	return "error"
+}
+
+// Issue is one problem with a track. Index is the track's position for ValidateAll
+// and -1 for Validate.
+type Issue struct {
+	Index    int
+	Field    string
+	Severity Severity
+	Message  string
+}
+
+func (i Issue) Error() string {
+	if i.Index >= 0 {
+		return fmt.Sprintf("track %d: %s: %s", i.Index+1, i.Field, i.Message)
+	}
+	return fmt.Sprintf("%s: %s", i.Field, i.Message)
+}
+
+// Issues is a list of validation issues.
+type Issues []Issue
+
+// Err joins the error-severity issues into one error, or returns nil when there are
+// none (warnings alone are not an error).
+func (is Issues) Err() error {
+	var errs []error
+	for _, i := range is {
+		if i.Severity == SeverityError {
+			errs = append(errs, i)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate applies the checks the CSV reader does — a valid Camelot key (and
+// modulations), a positive tempo, energy and the optional 0-100 signals in range,
+// positive durations and phrase lengths — plus a non-empty title, for programs that
+// build tracks themselves. An unusual tempo is a warning.
+func (t Track) Validate() Issues {
+	var is Issues
+	add := func(sev Severity, field, format string, args ...any) {
+		is = append(is, Issue{Index: -1, Field: field, Severity: sev, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if t.Title == "" {
+		add(SeverityError, "title", "empty")
+	}
+	for _, k := range t.Keys() {
+		switch {
+		case k == Key{}:
+			add(SeverityError, "key", "missing")
+		case !k.valid():
+			add(SeverityError, "key", "invalid Camelot key %d%s", k.Number, k.Mode)
+		}
+	}
+	switch {
+	case t.BPM <= 0:
+		add(SeverityError, "bpm", "must be positive, got %v", t.BPM)
+	case t.BPMEnd < 0:
+		add(SeverityError, "bpm", "end tempo must be positive, got %v", t.BPMEnd)
+	default:
+		for _, bpm := range []float64{t.BPM, t.BPMEnd} {
+			if bpm != 0 && (bpm < SuspiciousBPMLo || bpm > SuspiciousBPMHi) {
+				add(SeverityWarning, "bpm", "unusual BPM %s for %q", t.TempoString(), t.Title)
+				break
+			}
+		}
+	}
+	if t.Energy < 0 || t.Energy > 100 {
+		add(SeverityError, "energy", "out of range 0-100: %d", t.Energy)
+	}
+	for _, s := range []struct {
+		field string
+		v     *int
+	}{
+		{"danceability", t.Danceability}, {"valence", t.Valence},
+		{"popularity", t.Popularity}, {"acousticness", t.Acousticness},
+	} {
+		if s.v != nil && (*s.v < 0 || *s.v > 100) {
+			add(SeverityError, s.field, "out of range 0-100: %d", *s.v)
+		}
+	}
+	if t.Duration != nil && *t.Duration <= 0 {
+		add(SeverityError, "length", "must be positive, got %d", *t.Duration)
+	}
+	if t.Phrase != nil && *t.Phrase <= 0 {
+		add(SeverityError, "phrase", "must be positive, got %d", *t.Phrase)
+	}
+	return is
+}
+
+// ValidateAll validates every track, setting each Issue's Index.
+func ValidateAll(tracks []Track) Issues {
+	var all Issues
+	for i, t := range tracks {
+		for _, issue := range t.Validate() {
+			issue.Index = i
+			all = append(all, issue)
+		}
+	}
+	return all
+}
+
+func (k Key) valid() bool {
+	return k.Number >= 1 && k.Number <= 12 && (k.Mode == ModeA || k.Mode == ModeB)
+}
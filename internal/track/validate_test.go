@@ -0,0 +1,58 @@
+package track_test
+
+import (
+	"testing"
+
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+func TestValidate(t *testing.T) {
+	good := track.Track{Title: "A", BPM: 124, Energy: 60, Key: track.Key{Number: 8, Mode: track.ModeA}}
+	if is := good.Validate(); len(is) != 0 {
+		t.Fatalf("valid track reported %v", is)
+	}
+
+	bad := -5
+	tests := []struct {
+		name  string
+		mod   func(*track.Track)
+		field string
+		sev   track.Severity
+	}{
+		{"empty title", func(t *track.Track) { t.Title = "" }, "title", track.SeverityError},
+		{"missing key", func(t *track.Track) { t.Key = track.Key{} }, "key", track.SeverityError},
+		{"bad modulation", func(t *track.Track) { t.Modulations = []track.Key{{Number: 13, Mode: track.ModeA}} }, "key", track.SeverityError},
+		{"zero bpm", func(t *track.Track) { t.BPM = 0 }, "bpm", track.SeverityError},
+		{"unusual bpm", func(t *track.Track) { t.BPM = 300 }, "bpm", track.SeverityWarning},
+		{"energy range", func(t *track.Track) { t.Energy = 101 }, "energy", track.SeverityError},
+		{"valence range", func(t *track.Track) { t.Valence = &bad }, "valence", track.SeverityError},
+		{"length", func(t *track.Track) { t.Duration = &bad }, "length", track.SeverityError},
+	}
+	for _, tc := range tests {
+		tr := good
+		tc.mod(&tr)
+		is := tr.Validate()
+		if len(is) != 1 || is[0].Field != tc.field || is[0].Severity != tc.sev || is[0].Index != -1 {
+			t.Errorf("%s: got %+v, want one %s %s issue", tc.name, is, tc.sev, tc.field)
+		}
+	}
+}
+
+func TestValidateAll(t *testing.T) {
+	key := track.Key{Number: 1, Mode: track.ModeB}
+	tracks := []track.Track{
+		{Title: "ok", BPM: 120, Energy: 50, Key: key},
+		{Title: "", BPM: 300, Energy: 50, Key: key},
+	}
+	is := track.ValidateAll(tracks)
+	if len(is) != 2 || is[0].Index != 1 || is[1].Index != 1 {
+		t.Fatalf("got %+v, want two issues on track index 1", is)
+	}
+	err := is.Err()
+	if err == nil || err.Error() != "track 2: title: empty" {
+		t.Fatalf("Err() = %v, want only the title error", err)
+	}
+	if (track.Issues{is[1]}).Err() != nil {
+		t.Fatal("warnings alone should not be an error")
+	}
+}
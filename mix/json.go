@@ -0,0 +1,163 @@
+package mix
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+// SortRequest is the JSON SortJSON takes. Tracks are objects whose fields are named
+// like CSV columns ("title", "bpm", "key", "energy", "duration_seconds", …), as a JSON
+// --input is; CSV, in place of Tracks, is the text of a CSV library. The other fields
+// are Options.
+type SortRequest struct {
+	Tracks           json.RawMessage `json:"tracks,omitempty"`
+	CSV              string          `json:"csv,omitempty"`
+	Strategy         string          `json:"strategy,omitempty"`
+	Seed             int64           `json:"seed,omitempty"`
+	Limit            int             `json:"limit,omitempty"`
+	TempoMatch       TempoMatch      `json:"tempo_match,omitempty"`
+	AuditDeterminism bool            `json:"audit_determinism,omitempty"`
+}
+
+// SortResponse is the JSON SortJSON returns: the set as a JSON playlist's tracks
+// (each with its position and suggested crossfade), the seed it was sorted from, what
+// the sort had to say, and its Summary. A request given as CSV also gets the set back
+// as CSV, with the input's columns. Error is set, and nothing else, when the sort
+// failed.
+type SortResponse struct {
+	Tracks   []playlistio.Track `json:"tracks,omitempty"`
+	CSV      string             `json:"csv,omitempty"`
+	Seed     int64              `json:"seed,omitempty"`
+	Partial  bool               `json:"partial,omitempty"`
+	Unplaced []playlistio.Track `json:"unplaced,omitempty"`
+	Notes    []string           `json:"notes,omitempty"`
+	Warnings []string           `json:"warnings,omitempty"`
+	Summary  *Summary           `json:"summary,omitempty"`
+	Error    string             `json:"error,omitempty"`
+}
+
+// ScoreRequest is the JSON ScoreJSON takes: a set, in order, as Tracks or CSV as in a
+// SortRequest.
+type ScoreRequest struct {
+	Tracks json.RawMessage `json:"tracks,omitempty"`
+	CSV    string          `json:"csv,omitempty"`
+}
+
+// ScoreResponse is the JSON ScoreJSON returns: the set's score on the mix score and
+// the evaluate rubric, and a note on each transition, as a setlist records them.
+type ScoreResponse struct {
+	Score       *playlistio.SetlistScore    `json:"score,omitempty"`
+	Transitions []playlistio.TransitionNote `json:"transitions,omitempty"`
+	Warnings    []string                    `json:"warnings,omitempty"`
+	Error       string                      `json:"error,omitempty"`
+}
+
+// SortJSON orders the tracks of a JSON SortRequest with Order and returns the
+// SortResponse as JSON. With ScoreJSON it is the API of the C library
+// (cmd/libmagicmix) and the WebAssembly build (cmd/magicmix-wasm), so Python,
+// JavaScript, and other tooling can sort in-process with one call. Errors come back in
+// the response's Error field rather than as a Go error, so a caller across a C or
+// JavaScript boundary always has JSON to read.
+func SortJSON(ctx context.Context, request []byte) []byte {
+	resp, err := sortJSON(ctx, request)
+	if err != nil {
+		resp = SortResponse{Error: err.Error()}
+	}
+	return marshalResponse(resp, func(err error) any { return SortResponse{Error: err.Error()} })
+}
+
+// ScoreJSON scores the set of a JSON ScoreRequest in the order given and returns the
+// ScoreResponse as JSON; see SortJSON.
+func ScoreJSON(ctx context.Context, request []byte) []byte {
+	resp, err := scoreJSON(ctx, request)
+	if err != nil {
+		resp = ScoreResponse{Error: err.Error()}
+	}
+	return marshalResponse(resp, func(err error) any { return ScoreResponse{Error: err.Error()} })
+}
+
+func marshalResponse(resp any, failed func(error) any) []byte {
+	out, err := json.Marshal(resp)
+	if err != nil {
+		out, _ = json.Marshal(failed(err))
+	}
+	return out
+}
+
+// requestTracks reads a request's tracks from its JSON tracks or its CSV text.
+func requestTracks(ctx context.Context, tracks json.RawMessage, csv string) (Playlist, error) {
+	switch {
+	case tracks != nil && csv != "":
+		return Playlist{}, errors.New(`read request: give "tracks" or "csv", not both`)
+	case csv != "":
+		return csvio.ParsePlaylist(ctx, []byte(csv))
+	case tracks != nil:
+		return playlistio.ParseJSON(ctx, tracks)
+	}
+	return Playlist{}, errors.New(`read request: want a "tracks" array or "csv" text`)
+}
+
+func sortJSON(ctx context.Context, request []byte) (SortResponse, error) {
+	var req SortRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return SortResponse{}, fmt.Errorf("read request: %w", err)
+	}
+	if req.TempoMatch != "" {
+		if _, err := track.ParseTempoMatch(string(req.TempoMatch)); err != nil {
+			return SortResponse{}, fmt.Errorf("tempo_match: %w", err)
+		}
+	}
+	pl, err := requestTracks(ctx, req.Tracks, req.CSV)
+	if err != nil {
+		return SortResponse{}, err
+	}
+	seed := req.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	res, err := Order(ctx, pl.Tracks, Options{Strategy: req.Strategy, Seed: seed, Limit: req.Limit,
+		TempoMatch: req.TempoMatch, AuditDeterminism: req.AuditDeterminism})
+	if err != nil {
+		return SortResponse{}, err
+	}
+	summary := res.Summary()
+	summary.Warnings = append(slices.Clone(pl.Warnings), summary.Warnings...)
+	resp := SortResponse{Tracks: playlistio.JSONTracks(res.Ordered), Seed: seed, Partial: res.Partial,
+		Notes: res.Notes, Warnings: append(pl.Warnings, res.Warnings...), Summary: &summary}
+	if len(res.Unplaced) > 0 {
+		resp.Unplaced = playlistio.JSONTracks(res.Unplaced)
+	}
+	if req.CSV != "" {
+		var b strings.Builder
+		if err := csvio.WriteInFormat(&b, Playlist{Header: pl.Header, CRLF: pl.CRLF, Tracks: res.Ordered}); err != nil {
+			return SortResponse{}, err
+		}
+		resp.CSV = b.String()
+	}
+	return resp, nil
+}
+
+func scoreJSON(ctx context.Context, request []byte) (ScoreResponse, error) {
+	var req ScoreRequest
+	if err := json.Unmarshal(request, &req); err != nil {
+		return ScoreResponse{}, fmt.Errorf("read request: %w", err)
+	}
+	pl, err := requestTracks(ctx, req.Tracks, req.CSV)
+	if err != nil {
+		return ScoreResponse{}, err
+	}
+	doc, err := playlistio.NewSetlist(ctx, pl, playlistio.Manifest{})
+	if err != nil {
+		return ScoreResponse{}, err
+	}
+	return ScoreResponse{Score: &doc.Score, Transitions: doc.Transitions, Warnings: pl.Warnings}, nil
+}
@@ -0,0 +1,309 @@
+// Package mix is magicmix's public Go API: it orders tracks into a set the way the
+// magicmix command does, for programs that would rather not shell out to it.
+//
+//	tracks, err := mix.Load(ctx, "library.csv")
+//	...
+//	res, err := mix.Order(ctx, tracks, mix.Options{Strategy: "flow", Seed: 42, Limit: 30})
+//	...
+//	err = mix.Save(ctx, "set.csv", res.Ordered)
+//
+// The types are aliases of magicmix's own, so values pass freely between this package
+// and custom strategies registered with Register, or formats registered with
+// RegisterReader and RegisterWriter.
+package mix
+
+import (
+	"context"
+	"time"
+
+	"github.com/YakDriver/magicmix/internal/csvio"
+	"github.com/YakDriver/magicmix/internal/playlistio"
+	"github.com/YakDriver/magicmix/internal/strategy"
+	"github.com/YakDriver/magicmix/internal/track"
+)
+
+type (
+	// Track is one song and the metadata magicmix orders by.
+	Track = track.Track
+	// Key is a Camelot key such as 8A.
+	Key = track.Key
+	// Mode is the A (minor) or B (major) side of the Camelot wheel.
+	Mode = track.Mode
+	// Notation is a way of writing keys, for Key.In and Track.KeyStringIn.
+	Notation = track.Notation
+	// TempoMatch names which tempo relationships count as close, for Options.
+	TempoMatch = track.TempoMatch
+
+	// Sorter arranges tracks; every strategy implements it.
+	Sorter = strategy.Sorter
+	// Factory constructs a Sorter for the registry.
+	Factory = strategy.Factory
+	// StrategyInfo describes a registered strategy.
+	StrategyInfo = strategy.Info
+	// Registry is a set of strategies of its own, apart from the shared one Register
+	// adds to (see NewRegistry and Options.Registry).
+	Registry = strategy.Registry
+
+	// Result is an ordered set plus what the run had to say about it: warnings, how
+	// confident each placement is, and, if the sort stopped early, the tracks it never
+	// placed.
+	Result = strategy.Result
+	// Confidence rates one placement of a Result.
+	Confidence = strategy.Confidence
+	// Summary is a Result in one object for wrappers and GUIs (see Result.Summary):
+	// counts, mix score, warnings, and timing, with the output paths when a caller
+	// that writes the set fills them in.
+	Summary = strategy.Summary
+	// Progress is a snapshot of a running Order, for Options.Progress.
+	Progress = strategy.Progress
+	// NondeterminismError is the error Order returns when Options.AuditDeterminism
+	// finds two runs that differ.
+	NondeterminismError = strategy.NondeterminismError
+	// Format names a file format in the reader and writer registries, e.g. "csv",
+	// "m3u8", or "rekordbox".
+	Format = playlistio.Format
+	// FormatInfo describes a registered format and the file extensions that mean it.
+	FormatInfo = playlistio.FormatInfo
+	// Reader reads a library file in one format.
+	Reader = playlistio.Reader
+	// ReadOptions are settings a Reader may honor.
+	ReadOptions = playlistio.ReadOptions
+	// Writer writes an ordered set to a file in one format.
+	Writer = playlistio.Writer
+	// Playlist is what a Reader returns and a Writer takes: the tracks, plus the
+	// source's CSV header and line endings and any warnings from reading it.
+	Playlist = csvio.Playlist
+
+	// MixState is the default strategy's state machine, for a scheduler that keeps the
+	// order itself (see NewMixState).
+	MixState = strategy.MixState
+	// Candidate is a track MixState scored for playing next, with its category and
+	// score broken down; lower totals are better.
+	Candidate = strategy.DecisionCandidate
+	// MixSnapshot is where a MixState stands: its energy cycle and how long since each
+	// kind of key move.
+	MixSnapshot = strategy.DecisionState
+	// Transition is the move between two tracks' keys, tempos, and energies.
+	Transition = strategy.Transition
+
+	// Tuning holds the default strategy's weights, cycle lengths, and variety
+	// thresholds.
+	Tuning = strategy.Tuning
+)
+
+// The two sides of the Camelot wheel.
+const (
+	ModeA = track.ModeA
+	ModeB = track.ModeB
+)
+
+// The key notations ParseKey reads and Key.In writes.
+const (
+	Camelot = track.Camelot
+	OpenKey = track.OpenKey
+	Musical = track.Musical
+)
+
+// The tempo relationships Options.TempoMatch can allow.
+const (
+	TempoDirect     = track.TempoDirect
+	TempoHalfDouble = track.TempoHalfDouble
+	TempoThreeFour  = track.TempoThreeFour
+)
+
+// DefaultTuning is the default strategy's stock tuning; copy it and change what you
+// need.
+var DefaultTuning = strategy.DefaultTuning
+
+// DefaultStrategy is the strategy Order uses when Options leaves it blank, the same
+// one the magicmix command defaults to.
+const DefaultStrategy = "default"
+
+// Options controls Order.
+type Options struct {
+	// Strategy names a registered strategy (see Strategies); blank means
+	// DefaultStrategy.
+	Strategy string
+	// Registry, when set, is where Strategy is looked up instead of the shared
+	// registry, so a component can use its own strategies, or its own DefaultStrategy,
+	// whatever others in the program register.
+	Registry *Registry
+	// Seed makes a run repeatable; 0 picks a time-based seed.
+	Seed int64
+	// Limit caps how many tracks the set holds; 0 keeps them all.
+	Limit int
+	// Tuning, when set, replaces DefaultTuning for the default strategy; other
+	// strategies ignore it.
+	Tuning *Tuning
+	// TempoMatch says which tempo relationships the default strategy treats as close,
+	// such as TempoHalfDouble; blank compares tempos directly.
+	TempoMatch TempoMatch
+	// Progress, when set, hears how far the sort has got: tracks placed of the total
+	// and the best score so far. It runs on the sorting goroutine and should return
+	// quickly; cancel ctx from it to stop the sort with a partial Result.
+	Progress func(Progress)
+	// AuditDeterminism runs the sort twice from the seed and fails with a
+	// *NondeterminismError unless both runs give the same Result, for callers who rely
+	// on a seed to reproduce a set. The sort takes twice as long.
+	AuditDeterminism bool
+}
+
+// Order arranges tracks with the chosen strategy. Ordering rules apply as they do on
+// the command line, and a sort stopped by ctx's deadline returns the tracks it had
+// placed with Result.Partial set rather than an error.
+func Order(ctx context.Context, tracks []Track, opts Options) (Result, error) {
+	name := opts.Strategy
+	if name == "" {
+		name = DefaultStrategy
+	}
+	var sorter Sorter
+	var err error
+	if opts.Registry != nil {
+		sorter, err = opts.Registry.Get(name)
+	} else {
+		sorter, err = strategy.Get(name)
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	ctx, err = opts.planning(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	ctx = strategy.WithProgress(ctx, opts.Progress)
+	if opts.AuditDeterminism {
+		ctx = strategy.WithDeterminismAudit(ctx)
+	}
+	res, err := strategy.Sort(ctx, sorter, tracks)
+	if err != nil {
+		return Result{}, err
+	}
+	if opts.Limit > 0 && opts.Limit < len(res.Ordered) {
+		res.Ordered, res.Confidence = res.Ordered[:opts.Limit], res.Confidence[:opts.Limit]
+	}
+	return res, nil
+}
+
+// planning adds the options that shape how tracks are planned — seed, limit, tuning,
+// and tempo match — to ctx.
+func (opts Options) planning(ctx context.Context) (context.Context, error) {
+	seed := opts.Seed
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	ctx = strategy.WithLimit(strategy.WithSeed(ctx, seed), opts.Limit)
+	if opts.Tuning != nil {
+		if err := opts.Tuning.Validate(); err != nil {
+			return nil, err
+		}
+		ctx = strategy.WithTuning(ctx, *opts.Tuning)
+	}
+	if opts.TempoMatch != "" {
+		ctx = strategy.WithTempoMatch(ctx, opts.TempoMatch)
+	}
+	return ctx, nil
+}
+
+// NewMixState starts the default strategy's state machine over pool, for a scheduler
+// that decides the order itself and asks magicmix only to score and suggest: tell it
+// each track that plays with Advance, and ask Suggest for the best next tracks or
+// Score for any one. Limit, Tuning, and TempoMatch in opts apply as they do to Order;
+// Strategy, Progress, and AuditDeterminism don't.
+//
+//	state, err := mix.NewMixState(ctx, library, mix.Options{})
+//	...
+//	state.Advance(nowPlaying)
+//	for _, c := range state.Suggest(5) {
+//		fmt.Println(c.Title, c.Category, c.Total)
+//	}
+func NewMixState(ctx context.Context, pool []Track, opts Options) (*MixState, error) {
+	ctx, err := opts.planning(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return strategy.NewMixState(ctx, pool), nil
+}
+
+// ParseKey reads a key in Camelot ("8A"), Open Key ("1m"), or musical ("Am")
+// notation as the Camelot key it names.
+func ParseKey(s string) (Key, error) {
+	return track.ParseKey(s)
+}
+
+// Register adds a strategy, or replaces one of the same name, so Order and the
+// registry functions can find it.
+func Register(info StrategyInfo, factory Factory) {
+	strategy.RegisterInfo(info, factory)
+}
+
+// NewRegistry returns a registry of its own holding the built-in strategies, for
+// Options.Registry. Strategies registered in it, with its RegisterInfo, are seen only
+// by runs that use it, and it doesn't see those registered with Register. Registering
+// a name it already holds replaces that strategy for its users alone; a factory can
+// close over configuration, as NewDefaultSorter's tuning:
+//
+//	reg := mix.NewRegistry()
+//	reg.RegisterInfo(mix.StrategyInfo{Name: mix.DefaultStrategy},
+//		func() mix.Sorter { return mix.NewDefaultSorter(myTuning) })
+//	res, err := mix.Order(ctx, tracks, mix.Options{Registry: reg})
+func NewRegistry() *Registry {
+	return strategy.NewRegistry()
+}
+
+// NewDefaultSorter returns the default strategy fixed to tuning t, whatever
+// Options.Tuning says, for registering under a name of its own.
+func NewDefaultSorter(t Tuning) Sorter {
+	return strategy.NewDefaultSorterWith(t)
+}
+
+// NewSorter returns a fresh instance of a registered strategy.
+func NewSorter(name string) (Sorter, error) {
+	return strategy.Get(name)
+}
+
+// Strategies describes every registered strategy, sorted by name.
+func Strategies() []StrategyInfo {
+	return strategy.Infos()
+}
+
+// Load reads a track CSV, matching columns by header name as the magicmix command
+// does.
+func Load(ctx context.Context, path string) ([]Track, error) {
+	return csvio.Load(ctx, path)
+}
+
+// Save writes tracks as CSV in magicmix's own columns.
+func Save(ctx context.Context, path string, tracks []Track) error {
+	return csvio.Save(ctx, path, tracks)
+}
+
+// RegisterReader adds a readable format, or replaces the reader of one with the same
+// name, for LoadAs.
+func RegisterReader(info FormatInfo, r Reader) {
+	playlistio.RegisterReader(info, r)
+}
+
+// RegisterWriter adds a writable format, or replaces the writer of one with the same
+// name, for SaveAs.
+func RegisterWriter(info FormatInfo, w Writer) {
+	playlistio.RegisterWriter(info, w)
+}
+
+// LoadAs reads path with the reader registered for format; a blank format goes by the
+// file's extension (CSV when none matches).
+func LoadAs(ctx context.Context, path string, format Format) ([]Track, error) {
+	if format == "" {
+		format = playlistio.InputFormatOf(path)
+	}
+	pl, err := playlistio.Load(ctx, path, format, ReadOptions{})
+	return pl.Tracks, err
+}
+
+// SaveAs writes tracks to path with the writer registered for format; a blank format
+// goes by the file's extension (CSV when none matches).
+func SaveAs(ctx context.Context, path string, format Format, tracks []Track) error {
+	if format == "" {
+		format = playlistio.FormatOf(path)
+	}
+	return playlistio.Save(ctx, path, format, Playlist{Tracks: tracks})
+}
@@ -0,0 +1,219 @@
+package mix_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/YakDriver/magicmix/mix"
+)
+
+func sampleTracks() []mix.Track {
+	var tracks []mix.Track
+	for i, key := range []string{"8A", "9A", "8B", "10A", "3B", "9B"} {
+		k, _ := mix.ParseKey(key)
+		tracks = append(tracks, mix.Track{
+			Title: string(rune('A' + i)), Artist: "Artist", BPM: 120 + float64(i), Energy: 50 + 5*i, Key: k,
+		})
+	}
+	return tracks
+}
+
+func TestOrderHonorsLimitAndSeed(t *testing.T) {
+	opts := mix.Options{Strategy: "flow", Seed: 7, Limit: 4}
+	first, err := mix.Order(context.Background(), sampleTracks(), opts)
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if len(first.Ordered) != 4 || len(first.Confidence) != 4 {
+		t.Fatalf("got %d tracks (%d ratings), want 4", len(first.Ordered), len(first.Confidence))
+	}
+	again, err := mix.Order(context.Background(), sampleTracks(), opts)
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if !slices.EqualFunc(first.Ordered, again.Ordered, func(a, b mix.Track) bool { return a.Title == b.Title }) {
+		t.Fatal("the same seed should give the same order")
+	}
+	opts.AuditDeterminism = true
+	if _, err := mix.Order(context.Background(), sampleTracks(), opts); err != nil {
+		t.Fatalf("Order under audit: %v", err)
+	}
+
+	if _, err := mix.Order(context.Background(), sampleTracks(), mix.Options{Strategy: "nope"}); err == nil {
+		t.Fatal("an unknown strategy should be an error")
+	}
+}
+
+type reverse struct{}
+
+func (reverse) Name() string { return "reverse" }
+
+func (reverse) Sort(_ context.Context, tracks []mix.Track) ([]mix.Track, error) {
+	out := slices.Clone(tracks)
+	slices.Reverse(out)
+	return out, nil
+}
+
+func TestRegisterCustomStrategy(t *testing.T) {
+	mix.Register(mix.StrategyInfo{Name: "reverse", Description: "last track first"},
+		func() mix.Sorter { return reverse{} })
+	if !slices.ContainsFunc(mix.Strategies(), func(i mix.StrategyInfo) bool { return i.Name == "reverse" }) {
+		t.Fatal("registered strategy not listed")
+	}
+	res, err := mix.Order(context.Background(), sampleTracks(), mix.Options{Strategy: "reverse"})
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if res.Ordered[0].Title != "F" {
+		t.Fatalf("first track = %q, want F", res.Ordered[0].Title)
+	}
+}
+
+func TestRegistryKeepsStrategiesApart(t *testing.T) {
+	reg := mix.NewRegistry()
+	reg.RegisterInfo(mix.StrategyInfo{Name: "backwards", Description: "last track first"},
+		func() mix.Sorter { return reverse{} })
+	res, err := mix.Order(context.Background(), sampleTracks(), mix.Options{Strategy: "backwards", Registry: reg})
+	if err != nil {
+		t.Fatalf("Order: %v", err)
+	}
+	if res.Ordered[0].Title != "F" {
+		t.Fatalf("first track = %q, want F", res.Ordered[0].Title)
+	}
+	if _, err := mix.Order(context.Background(), sampleTracks(), mix.Options{Strategy: "backwards"}); err == nil {
+		t.Error("a registry's strategy is visible to the shared registry")
+	}
+
+	tuning := mix.DefaultTuning
+	reg.RegisterInfo(mix.StrategyInfo{Name: mix.DefaultStrategy}, func() mix.Sorter { return mix.NewDefaultSorter(tuning) })
+	if _, err := mix.Order(context.Background(), sampleTracks(), mix.Options{Registry: reg, Seed: 1}); err != nil {
+		t.Fatalf("Order with the registry's default: %v", err)
+	}
+	if !slices.ContainsFunc(mix.Strategies(), func(i mix.StrategyInfo) bool { return i.Name == mix.DefaultStrategy && i.Description != "" }) {
+		t.Error("replacing the default in a registry replaced the shared one")
+	}
+}
+
+func TestSortJSON(t *testing.T) {
+	request := `{"strategy": "flow", "seed": 7, "limit": 2, "tracks": [
+		{"title": "A", "bpm": 120, "key": "8A", "energy": 50},
+		{"title": "B", "bpm": 122, "key": "9A", "energy": 60},
+		{"title": "C", "bpm": 121, "key": "8B", "energy": 40}]}`
+	var resp mix.SortResponse
+	if err := json.Unmarshal(mix.SortJSON(context.Background(), []byte(request)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" || len(resp.Tracks) != 2 || resp.Seed != 7 || resp.Tracks[0].Position != 1 {
+		t.Errorf("response = %+v", resp)
+	}
+	if s := resp.Summary; s == nil || s.Strategy != "flow" || s.Input != 3 || s.Tracks != 2 || s.Dropped != 1 {
+		t.Errorf("summary = %+v; want flow's 2 tracks of 3", s)
+	}
+
+	csvRequest := `{"seed": 7, "csv": "Title,BPM,Key,Energy,Label\nA,120,8A,50,x\nB,122,9A,60,y\n"}`
+	resp = mix.SortResponse{}
+	if err := json.Unmarshal(mix.SortJSON(context.Background(), []byte(csvRequest)), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Error != "" || !strings.HasPrefix(resp.CSV, "Title,BPM,Key,Energy,Label\n") || len(resp.Tracks) != 2 {
+		t.Errorf("CSV response = %+v", resp)
+	}
+
+	var score mix.ScoreResponse
+	if err := json.Unmarshal(mix.ScoreJSON(context.Background(), []byte(request)), &score); err != nil {
+		t.Fatal(err)
+	}
+	if score.Error != "" || score.Score == nil || len(score.Transitions) != 2 {
+		t.Errorf("score response = %+v", score)
+	}
+
+	for _, bad := range []string{`nope`, `{"seed": 1}`, `{"tracks": [], "strategy": "nope"}`,
+		`{"tracks": [], "csv": "Title\nA\n"}`} {
+		var resp mix.SortResponse
+		if err := json.Unmarshal(mix.SortJSON(context.Background(), []byte(bad)), &resp); err != nil || resp.Error == "" {
+			t.Errorf("SortJSON(%s): want an error in the response, got %+v (%v)", bad, resp, err)
+		}
+	}
+}
+
+func TestLoadSaveRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "set.csv")
+	if err := mix.Save(context.Background(), path, sampleTracks()); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	tracks, err := mix.Load(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if len(tracks) != 6 || tracks[3].Key != (mix.Key{Number: 10, Mode: mix.ModeA}) {
+		t.Fatalf("round trip lost data: %+v", tracks)
+	}
+}
+
+func TestRegisterCustomFormat(t *testing.T) {
+	info := mix.FormatInfo{Name: "titles", Description: "one title per line", Extensions: []string{".titles"}}
+	mix.RegisterWriter(info, func(_ context.Context, path string, pl mix.Playlist) error {
+		var b strings.Builder
+		for _, tr := range pl.Tracks {
+			b.WriteString(tr.Title + "\n")
+		}
+		return os.WriteFile(path, []byte(b.String()), 0o644)
+	})
+	mix.RegisterReader(info, func(_ context.Context, path string, _ mix.ReadOptions) (mix.Playlist, error) {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return mix.Playlist{}, err
+		}
+		var pl mix.Playlist
+		for title := range strings.Lines(string(data)) {
+			pl.Tracks = append(pl.Tracks, mix.Track{Title: strings.TrimSpace(title)})
+		}
+		return pl, nil
+	})
+
+	path := filepath.Join(t.TempDir(), "set.titles")
+	if err := mix.SaveAs(context.Background(), path, "", sampleTracks()); err != nil {
+		t.Fatalf("SaveAs: %v", err)
+	}
+	tracks, err := mix.LoadAs(context.Background(), path, "")
+	if err != nil {
+		t.Fatalf("LoadAs: %v", err)
+	}
+	if len(tracks) != 6 || tracks[5].Title != "F" {
+		t.Fatalf("round trip through the custom format lost data: %+v", tracks)
+	}
+	if err := mix.SaveAs(context.Background(), path, "pls", sampleTracks()); err == nil {
+		t.Fatal("SaveAs with an unregistered format should fail")
+	}
+}
+
+func TestMixState(t *testing.T) {
+	tracks := sampleTracks()
+	state, err := mix.NewMixState(context.Background(), tracks, mix.Options{Seed: 1})
+	if err != nil {
+		t.Fatalf("NewMixState: %v", err)
+	}
+	state.Advance(tracks[0])
+	next := state.Suggest(2)
+	if len(next) != 2 || next[0].Title == tracks[0].Title || next[0].Category != "step+1" {
+		t.Fatalf("after 8A, suggested %+v; want 9A first, by a step", next)
+	}
+	if c := state.Score(tracks[4]); c.Category != "other" || c.Total <= next[0].Total {
+		t.Errorf("8A to 3B scored %+v; want a worse move than %+v", c, next[0])
+	}
+	state.Advance(tracks[1])
+	if s := state.Snapshot(); s.Prev != tracks[1].Title || s.Remaining != len(tracks)-2 {
+		t.Errorf("snapshot %+v after two tracks", s)
+	}
+
+	bad := mix.DefaultTuning
+	bad.KeyWeight = -1
+	if _, err := mix.NewMixState(context.Background(), tracks, mix.Options{Tuning: &bad}); err == nil {
+		t.Error("invalid tuning: want an error")
+	}
+}